@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/eventdiff"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// errDifferent reports that compare found differences; main exits with 1
+// without printing it again.
+var errDifferent = errors.New("event sequences differ")
+
+// runReplay writes the client stream the current translators produce for the
+// captured upstream stream of a fixture.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the translated stream to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		usage()
+		return fmt.Errorf("expected exactly one fixture path")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	stream, err := replayFixture(data)
+	if err != nil {
+		return fmt.Errorf("replay %s: %w", fs.Arg(0), err)
+	}
+	return writeOutput(*output, stream)
+}
+
+// runCompare prints the structural differences between two translated
+// streams. Each input is either a translated SSE stream, e.g. written by the
+// replay command of another release, or a stream fixture, which is replayed
+// with the current translators first.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		usage()
+		return fmt.Errorf("expected an old and a new stream")
+	}
+	var streams [2][]eventdiff.Event
+	for i, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if gjson.GetBytes(data, "upstream_response").Exists() {
+			if data, err = replayFixture(data); err != nil {
+				return fmt.Errorf("replay %s: %w", path, err)
+			}
+		}
+		streams[i] = eventdiff.ParseEvents(data)
+	}
+
+	changes := eventdiff.Diff(streams[0], streams[1])
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	fmt.Fprintf(os.Stderr, "fixturetool: %d old event(s), %d new event(s), %d difference(s)\n", len(streams[0]), len(streams[1]), len(changes))
+	if len(changes) > 0 {
+		return errDifferent
+	}
+	return nil
+}
+
+// replayFixture runs the upstream stream captured in a fixture through the
+// stream translator from its target format back to its source format.
+func replayFixture(data []byte) ([]byte, error) {
+	fixture := gjson.ParseBytes(data)
+	if !fixture.Get("stream").Bool() {
+		return nil, fmt.Errorf("not a stream fixture")
+	}
+	upstream := fixture.Get("upstream_response")
+	if upstream.Type != gjson.String {
+		return nil, fmt.Errorf("fixture has no captured upstream stream")
+	}
+	var out bytes.Buffer
+	err := sdktranslator.ConvertStream(context.Background(), strings.NewReader(upstream.String()), &out, sdktranslator.StreamOptions{
+		From:            sdktranslator.FromString(fixture.Get("target_format").String()),
+		To:              sdktranslator.FromString(fixture.Get("source_format").String()),
+		Model:           fixture.Get("model").String(),
+		OriginalRequest: []byte(fixture.Get("request").Raw),
+		Request:         []byte(fixture.Get("upstream_request").Raw),
+	})
+	return out.Bytes(), err
+}
@@ -0,0 +1,108 @@
+// Package main provides fixturetool, a helper for working with captured
+// request/response fixtures outside of the server.
+//
+// Usage:
+//
+//	fixturetool anonymize [-hash-tools] [-o output] <fixture>
+//	fixturetool reduce -url <endpoint> [-format claude|openai] [-H header]... [-status code] [-match text] [-o output] <fixture>
+//	fixturetool replay [-o output] <stream fixture>
+//	fixturetool compare <old> <new>
+//
+// anonymize writes the fixture to stdout unless -o is given. User text is
+// replaced by length-preserving filler, credentials and ARNs are scrubbed, and
+// roles, block types and tool call pairing are preserved so the result still
+// reproduces translation issues when attached to a bug report.
+//
+// reduce replays a failing request (the "request" field of a fixture, or a raw
+// request body) against an endpoint and bisects it down to the smallest
+// transcript that still fails the same way.
+//
+// replay runs the upstream stream captured in a fixture through the current
+// translators and writes the client stream. compare prints the structural
+// differences between two such streams; a stream fixture given to compare is
+// replayed first. Replaying the same fixture with two releases and comparing
+// the outputs shows how translation changed between them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/anonymize"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "anonymize":
+		if err := runAnonymize(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "fixturetool: %v\n", err)
+			os.Exit(1)
+		}
+	case "reduce":
+		if err := runReduce(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "fixturetool: %v\n", err)
+			os.Exit(1)
+		}
+	case "replay":
+		if err := runReplay(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "fixturetool: %v\n", err)
+			os.Exit(1)
+		}
+	case "compare":
+		if err := runCompare(os.Args[2:]); err != nil {
+			if err != errDifferent {
+				fmt.Fprintf(os.Stderr, "fixturetool: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "fixturetool: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fixturetool anonymize [-hash-tools] [-o output] <fixture>")
+	fmt.Fprintln(os.Stderr, "       fixturetool reduce -url <endpoint> [-format claude|openai] [-H header]... [-status code] [-match text] [-o output] <fixture>")
+	fmt.Fprintln(os.Stderr, "       fixturetool replay [-o output] <stream fixture>")
+	fmt.Fprintln(os.Stderr, "       fixturetool compare <old> <new>")
+}
+
+func runAnonymize(args []string) error {
+	fs := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	hashTools := fs.Bool("hash-tools", false, "Replace tool names with stable hashes")
+	output := fs.String("o", "", "Write the anonymized fixture to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		usage()
+		return fmt.Errorf("expected exactly one fixture path")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	out, err := anonymize.Fixture(data, anonymize.Options{HashToolNames: *hashTools})
+	if err != nil {
+		return fmt.Errorf("anonymize %s: %w", fs.Arg(0), err)
+	}
+	return writeOutput(*output, out)
+}
+
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/reduce"
+	"github.com/tidwall/gjson"
+)
+
+type headerFlags []string
+
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("header %q must be in \"Name: value\" form", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+func runReduce(args []string) error {
+	fs := flag.NewFlagSet("reduce", flag.ContinueOnError)
+	endpoint := fs.String("url", "", "Endpoint that rejects the request (e.g. http://127.0.0.1:8317/v1/messages)")
+	format := fs.String("format", "", "Request schema: claude or openai (default: fixture source_format)")
+	status := fs.Int("status", 0, "Status code that identifies the failure (default: the status of the original request)")
+	match := fs.String("match", "", "Text the failing response body must contain")
+	output := fs.String("o", "", "Write the reduced request to this file instead of stdout")
+	timeout := fs.Duration("timeout", 2*time.Minute, "Timeout for each replayed request")
+	var headers headerFlags
+	fs.Var(&headers, "H", "Extra request header (repeatable), e.g. -H \"Authorization: Bearer sk-...\"")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *endpoint == "" {
+		usage()
+		return fmt.Errorf("expected -url and exactly one fixture path")
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	payload := data
+	if request := gjson.GetBytes(data, "request"); request.IsObject() {
+		payload = []byte(request.Raw)
+		if *format == "" {
+			*format = gjson.GetBytes(data, "source_format").String()
+		}
+	}
+	if *format == "" {
+		*format = "claude"
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	send := func(ctx context.Context, body []byte) (int, string, error) {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, *endpoint, bytes.NewReader(body))
+		if errReq != nil {
+			return 0, "", errReq
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for _, header := range headers {
+			name, value, _ := strings.Cut(header, ":")
+			req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		resp, errDo := client.Do(req)
+		if errDo != nil {
+			return 0, "", errDo
+		}
+		defer func() { _ = resp.Body.Close() }()
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody), nil
+	}
+
+	ctx := context.Background()
+	if *status == 0 {
+		code, _, errSend := send(ctx, payload)
+		if errSend != nil {
+			return errSend
+		}
+		if code < 400 {
+			return fmt.Errorf("original request succeeded with status %d; nothing to reduce", code)
+		}
+		*status = code
+	}
+	reproduces := func(ctx context.Context, candidate []byte) (bool, error) {
+		code, body, errSend := send(ctx, candidate)
+		if errSend != nil {
+			return false, errSend
+		}
+		return code == *status && (*match == "" || strings.Contains(body, *match)), nil
+	}
+
+	reduced, stats, err := reduce.Request(ctx, *format, payload, reproduces)
+	if err != nil {
+		return err
+	}
+	summary, _ := json.Marshal(stats)
+	fmt.Fprintf(os.Stderr, "fixturetool: reduced request (status %d): %s\n", *status, summary)
+	var pretty bytes.Buffer
+	if errIndent := json.Indent(&pretty, reduced, "", "  "); errIndent == nil {
+		reduced = append(pretty.Bytes(), '\n')
+	}
+	return writeOutput(*output, reduced)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/diagnose"
+)
+
+// runLint implements "lint [-format claude|openai] [-max-description n] <request.json>".
+// It prints the issues the proxy would repair or drop in a client payload and
+// returns the process exit code: 0 when the payload is clean, 1 when warnings
+// were printed and 2 on usage errors.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := fs.String("format", "", "Payload schema (claude or openai); detected when empty")
+	maxDescription := fs.Int("max-description", diagnose.DefaultMaxToolDescription, "Warn about tool descriptions longer than this")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "usage: lint [-format claude|openai] [-max-description n] <request.json>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lint: %v\n", err)
+		return 2
+	}
+	if *format == "" {
+		*format = diagnose.DetectFormat(data)
+	}
+	if *format != "claude" && *format != "openai" {
+		fmt.Fprintf(os.Stderr, "lint: %s is not an OpenAI or Anthropic request; pass -format\n", fs.Arg(0))
+		return 2
+	}
+
+	warnings := diagnose.Lint(*format, data, diagnose.LintOptions{MaxToolDescription: *maxDescription})
+	for _, warning := range warnings {
+		fmt.Printf("%s: %s\n", fs.Arg(0), warning)
+	}
+	if len(warnings) > 0 {
+		return 1
+	}
+	return 0
+}
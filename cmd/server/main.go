@@ -51,6 +51,13 @@ func init() {
 // It parses command-line flags, loads configuration, and starts the appropriate
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "usage" {
+		os.Exit(runUsage(os.Args[2:]))
+	}
+
 	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
 	// Command-line flags to control the application's behavior.
@@ -65,6 +72,7 @@ func main() {
 	var antigravityLogin bool
 	var projectID string
 	var vertexImport string
+	var encryptAuth bool
 	var configPath string
 	var password string
 
@@ -81,6 +89,7 @@ func main() {
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
+	flag.BoolVar(&encryptAuth, "encrypt-auth", false, "Encrypt plaintext auth files with CLIPROXY_AUTH_ENCRYPTION_KEY")
 	flag.StringVar(&password, "password", "", "")
 
 	flag.CommandLine.Usage = func() {
@@ -405,6 +414,9 @@ func main() {
 		}
 	}
 	usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
+	if err = usage.ConfigureStore(cfg.UsageStore); err != nil {
+		log.Errorf("failed to open usage store: %v", err)
+	}
 	coreauth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 
 	if err = logging.ConfigureLogOutput(cfg); err != nil {
@@ -415,7 +427,7 @@ func main() {
 	log.Infof("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
 	// Set the log level based on the configuration.
-	util.SetLogLevel(cfg)
+	logging.SetLogLevel(cfg)
 
 	if resolvedAuthDir, errResolveAuthDir := util.ResolveAuthDir(cfg.AuthDir); errResolveAuthDir != nil {
 		log.Errorf("failed to resolve auth directory: %v", errResolveAuthDir)
@@ -423,6 +435,10 @@ func main() {
 	} else {
 		cfg.AuthDir = resolvedAuthDir
 	}
+	if errKey := misc.ValidateCredentialKey(); errKey != nil {
+		log.Errorf("invalid auth encryption key: %v", errKey)
+		return
+	}
 	managementasset.SetCurrentConfig(cfg)
 
 	// Create login options to be used in authentication flows.
@@ -450,6 +466,9 @@ func main() {
 	if vertexImport != "" {
 		// Handle Vertex service account import
 		cmd.DoVertexImport(cfg, vertexImport)
+	} else if encryptAuth {
+		// Encrypt existing auth files at rest
+		cmd.DoEncryptAuthFiles(cfg)
 	} else if login {
 		// Handle Google/Gemini login
 		cmd.DoLogin(cfg, projectID, options)
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// runUsage implements "usage export [-config file] [-format csv|json] [-from day] [-to day] [-o file]".
+// It exports the daily usage kept by the file usage store configured in the
+// config file, which works while the server is running or stopped, and
+// returns the process exit code.
+func runUsage(args []string) int {
+	if len(args) == 0 || args[0] != "export" {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: usage export [-config file] [-format csv|json] [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-o file]")
+		return 2
+	}
+	fs := flag.NewFlagSet("usage export", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Configure File Path")
+	format := fs.String("format", "csv", "Output format: csv or json")
+	from := fs.String("from", "", "First day to export (YYYY-MM-DD)")
+	to := fs.String("to", "", "Last day to export (YYYY-MM-DD)")
+	output := fs.String("o", "", "Write to this file instead of stdout")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "usage export: unknown format %q\n", *format)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "usage export: %v\n", err)
+		return 1
+	}
+	if !strings.EqualFold(strings.TrimSpace(cfg.UsageStore.Type), usage.StoreFile) {
+		fmt.Fprintln(os.Stderr, "usage export: the configured usage store is not persistent; set usage-store.type to file, or use /v0/management/usage/daily on the running server")
+		return 1
+	}
+	store, err := usage.LoadFileStore(cfg.UsageStore.Path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "usage export: %v\n", err)
+		return 1
+	}
+	rows, _ := store.Rows(*from, *to)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, errCreate := os.Create(*output)
+		if errCreate != nil {
+			fmt.Fprintf(os.Stderr, "usage export: %v\n", errCreate)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		w = file
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(rows)
+	} else {
+		err = usage.WriteCSV(w, rows)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "usage export: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -81,7 +81,7 @@ func ReconcileProviders(oldCfg, newCfg *config.Config, existing []sdkaccess.Prov
 	}
 
 	if len(result) == 0 {
-		if inline := sdkConfig.MakeInlineAPIKeyProvider(newCfg.APIKeys); inline != nil {
+		if inline := sdkConfig.MakeInlineAPIKeyProvider(newCfg.ClientAPIKeys()); inline != nil {
 			key := providerIdentifier(inline)
 			if key != "" {
 				if oldCfgProvider, ok := oldCfgMap[key]; ok {
@@ -176,8 +176,8 @@ func accessProviderMap(cfg *config.Config) map[string]*sdkConfig.AccessProvider
 		}
 		result[key] = providerCfg
 	}
-	if len(result) == 0 && len(cfg.APIKeys) > 0 {
-		if provider := sdkConfig.MakeInlineAPIKeyProvider(cfg.APIKeys); provider != nil {
+	if keys := cfg.ClientAPIKeys(); len(result) == 0 && len(keys) > 0 {
+		if provider := sdkConfig.MakeInlineAPIKeyProvider(keys); provider != nil {
 			if key := providerIdentifier(provider); key != "" {
 				result[key] = provider
 			}
@@ -197,8 +197,8 @@ func collectProviderEntries(cfg *config.Config) []*sdkConfig.AccessProvider {
 			entries = append(entries, providerCfg)
 		}
 	}
-	if len(entries) == 0 && len(cfg.APIKeys) > 0 {
-		if inline := sdkConfig.MakeInlineAPIKeyProvider(cfg.APIKeys); inline != nil {
+	if keys := cfg.ClientAPIKeys(); len(entries) == 0 && len(keys) > 0 {
+		if inline := sdkConfig.MakeInlineAPIKeyProvider(keys); inline != nil {
 			entries = append(entries, inline)
 		}
 	}
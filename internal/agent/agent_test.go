@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := map[string]float64{
+		"1 + 2 * 3":       7,
+		"(2 + 3) * 4.5":   22.5,
+		"-2^2":            -4,
+		"2^3^2":           512,
+		"10 % 4 - -1":     3,
+		"  7 / 2  ":       3.5,
+		"((1))":           1,
+		"2 * (3 + 4) / 7": 2,
+	}
+	for expression, want := range cases {
+		if got, err := Evaluate(expression); err != nil || got != want {
+			t.Errorf("Evaluate(%q) = %v, %v; want %v", expression, got, err, want)
+		}
+	}
+	for _, expression := range []string{"", "1 +", "(1", "1 / 0", "2 x 3"} {
+		if _, err := Evaluate(expression); err == nil {
+			t.Errorf("Evaluate(%q) succeeded", expression)
+		}
+	}
+}
+
+func TestToolLoopSteps(t *testing.T) {
+	tools := map[string]Tool{mathToolName: mathTool{}}
+	request := InjectTools([]byte(`{"model":"m","messages":[{"role":"user","content":"what is 6*7?"}]}`), Tools([]string{mathToolName}))
+	if gjson.GetBytes(request, "tools.0.name").String() != mathToolName {
+		t.Fatalf("tools not injected: %s", request)
+	}
+
+	first := []byte(`{"content":[{"type":"text","text":"Let me compute."},{"type":"tool_use","id":"t1","name":"math","input":{"expression":"6*7"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`)
+	calls, ok := PendingCalls(first, tools)
+	if !ok || len(calls) != 1 {
+		t.Fatalf("calls = %+v, ok = %v", calls, ok)
+	}
+	if _, ok = PendingCalls([]byte(`{"content":[{"type":"tool_use","id":"t2","name":"Bash","input":{}}],"stop_reason":"tool_use"}`), tools); ok {
+		t.Fatalf("client tool call was claimed by the proxy")
+	}
+	results := Execute(context.Background(), calls, tools)
+	if results[0].Output != "42" || results[0].IsError {
+		t.Fatalf("results = %+v", results)
+	}
+
+	request = Continue(request, first, results)
+	messages := gjson.GetBytes(request, "messages").Array()
+	if len(messages) != 3 || messages[2].Get("content.0.tool_use_id").String() != "t1" || messages[2].Get("content.0.content").String() != "42" {
+		t.Fatalf("continued request = %s", request)
+	}
+
+	var transcript Transcript
+	transcript.Add(first, results)
+	final := transcript.Finish([]byte(`{"content":[{"type":"text","text":"42"}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":2}}`))
+	types := []string{}
+	for _, block := range gjson.GetBytes(final, "content").Array() {
+		types = append(types, block.Get("type").String())
+	}
+	if strings.Join(types, ",") != "text,cliproxy_agent_tool_use,cliproxy_agent_tool_result,text" || gjson.GetBytes(final, "usage.input_tokens").Int() != 30 {
+		t.Fatalf("final = %s", final)
+	}
+
+	resent := FlattenSteps([]byte(`{"messages":[{"role":"assistant","content":` + gjson.GetBytes(final, "content").Raw + `}]}`))
+	if strings.Contains(string(resent), StepToolUse) || !strings.Contains(gjson.GetBytes(resent, "messages.0.content.1.text").String(), "called tool math") {
+		t.Fatalf("flattened = %s", resent)
+	}
+}
+
+func TestFlattenStepsKeepsServerToolBlocks(t *testing.T) {
+	content := `[{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{"query":"go"}},` +
+		`{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[]},{"type":"text","text":"done"}]`
+	payload := []byte(`{"messages":[{"role":"assistant","content":` + content + `}]}`)
+	if out := FlattenSteps(payload); string(out) != string(payload) {
+		t.Fatalf("server tool blocks rewritten: %s", out)
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:80", "10.1.2.3:443", "[::1]:80", "169.254.169.254:80"} {
+		if refusePrivateAddress("tcp", address, nil) == nil {
+			t.Errorf("%s was allowed", address)
+		}
+	}
+	if err := refusePrivateAddress("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("public address refused: %v", err)
+	}
+}
+
+type echoTool struct{}
+
+func (echoTool) Name() string   { return "echo" }
+func (echoTool) Schema() Schema { return Schema{Description: "Echo the input"} }
+func (echoTool) Execute(_ context.Context, args []byte) (string, error) {
+	return string(args), nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register(echoTool{})
+	defer Unregister("echo")
+	if names := strings.Join(Names(), ","); names != "echo,http_fetch,math,time" {
+		t.Fatalf("names = %s", names)
+	}
+	tools := Tools([]string{"time", "missing", "echo"})
+	if len(tools) != 2 || tools[0].Name() != "time" || tools[1].Name() != "echo" {
+		t.Fatalf("tools = %v", tools)
+	}
+}
+
+func TestTimeTool(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	if out, err := (timeTool{}).Execute(context.Background(), []byte(`{}`)); err != nil || out != "2026-03-01T12:00:00Z (Sunday)" {
+		t.Fatalf("utc: %q, %v", out, err)
+	}
+	if _, err := (timeTool{}).Execute(context.Background(), []byte(`{"timezone":"Nowhere/City"}`)); err == nil {
+		t.Fatalf("unknown time zone accepted")
+	}
+}
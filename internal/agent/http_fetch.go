@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	httpFetchToolName = "http_fetch"
+	httpFetchMaxBytes = 64 << 10
+	httpFetchTimeout  = 15 * time.Second
+)
+
+// httpFetchTool downloads a public web page. Connections to loopback, private
+// and link-local addresses are refused so the model cannot reach the proxy's
+// own network.
+type httpFetchTool struct {
+	client *http.Client
+}
+
+func newHTTPFetchTool() httpFetchTool {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: refusePrivateAddress}
+	return httpFetchTool{client: &http.Client{
+		Timeout:   httpFetchTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}}
+}
+
+func (httpFetchTool) Name() string { return httpFetchToolName }
+
+func (httpFetchTool) Schema() Schema {
+	return Schema{
+		Description: "Fetch a public http(s) URL and return the response body as text (truncated to 64 KiB).",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"url": map[string]any{"type": "string", "description": "The http or https URL to fetch"},
+			},
+			"required": []string{"url"},
+		},
+	}
+}
+
+func (t httpFetchTool) Execute(ctx context.Context, args []byte) (string, error) {
+	target, err := url.Parse(gjson.GetBytes(args, "url").String())
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "", fmt.Errorf("url must be an absolute http or https URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpFetchMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "HTTP %d %s\n\n", resp.StatusCode, resp.Header.Get("Content-Type"))
+	if len(body) > httpFetchMaxBytes {
+		out.Write(body[:httpFetchMaxBytes])
+		out.WriteString("\n[... truncated]")
+	} else {
+		out.Write(body)
+	}
+	return out.String(), nil
+}
+
+// refusePrivateAddress is a net.Dialer control hook rejecting non-public addresses.
+func refusePrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Block types used to show proxy-executed tool steps in the final response.
+// They are proxy-specific so they never collide with Anthropic's own server
+// tool blocks (web_search's server_tool_use and its results), which are passed
+// through untouched.
+const (
+	StepToolUse    = "cliproxy_agent_tool_use"
+	StepToolResult = "cliproxy_agent_tool_result"
+)
+
+// Call is a pending call of a proxy-executed tool.
+type Call struct {
+	ID    string
+	Name  string
+	Input []byte
+}
+
+// Result is the outcome of a Call.
+type Result struct {
+	Call
+	Output  string
+	IsError bool
+}
+
+// InjectTools adds the definitions of tools to a Claude Messages request.
+func InjectTools(payload []byte, tools []Tool) []byte {
+	for _, tool := range tools {
+		schema := tool.Schema()
+		definition := map[string]any{
+			"name":         tool.Name(),
+			"description":  schema.Description,
+			"input_schema": schema.InputSchema,
+		}
+		if updated, err := sjson.SetBytes(payload, "tools.-1", definition); err == nil {
+			payload = updated
+		}
+	}
+	return payload
+}
+
+// ClientToolNames returns the names of the tools declared by the client.
+func ClientToolNames(payload []byte) map[string]struct{} {
+	names := make(map[string]struct{})
+	for _, tool := range gjson.GetBytes(payload, "tools").Array() {
+		if name := tool.Get("name").String(); name != "" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// PendingCalls returns the tool calls of a Claude Messages response that the
+// proxy should execute. ok is false unless the response stopped for tool use
+// and every call targets one of tools; otherwise the response belongs to the
+// client as is.
+func PendingCalls(response []byte, tools map[string]Tool) (calls []Call, ok bool) {
+	if gjson.GetBytes(response, "stop_reason").String() != "tool_use" {
+		return nil, false
+	}
+	for _, block := range gjson.GetBytes(response, "content").Array() {
+		if block.Get("type").String() != "tool_use" {
+			continue
+		}
+		name := block.Get("name").String()
+		if _, known := tools[name]; !known {
+			return nil, false
+		}
+		calls = append(calls, Call{ID: block.Get("id").String(), Name: name, Input: []byte(block.Get("input").Raw)})
+	}
+	return calls, len(calls) > 0
+}
+
+// Execute runs calls in order. Tool failures become error results so the model
+// can react to them.
+func Execute(ctx context.Context, calls []Call, tools map[string]Tool) []Result {
+	results := make([]Result, 0, len(calls))
+	for _, call := range calls {
+		output, err := tools[call.Name].Execute(ctx, call.Input)
+		if err != nil {
+			results = append(results, Result{Call: call, Output: err.Error(), IsError: true})
+			continue
+		}
+		results = append(results, Result{Call: call, Output: output})
+	}
+	return results
+}
+
+// Continue appends the assistant turn of response and a user turn carrying the
+// tool results to request.
+func Continue(request, response []byte, results []Result) []byte {
+	assistant := `{"role":"assistant","content":[]}`
+	assistant, _ = sjson.SetRaw(assistant, "content", gjson.GetBytes(response, "content").Raw)
+	user := `{"role":"user","content":[]}`
+	for _, result := range results {
+		block := map[string]any{"type": "tool_result", "tool_use_id": result.ID, "content": result.Output}
+		if result.IsError {
+			block["is_error"] = true
+		}
+		user, _ = sjson.Set(user, "content.-1", block)
+	}
+	out := request
+	for _, message := range []string{assistant, user} {
+		if updated, err := sjson.SetRawBytes(out, "messages.-1", []byte(message)); err == nil {
+			out = updated
+		}
+	}
+	return out
+}
+
+// Transcript collects the intermediate steps of a tool loop.
+type Transcript struct {
+	blocks       []string
+	inputTokens  int64
+	outputTokens int64
+}
+
+// Add records an intermediate response and the results of its tool calls.
+// Text is kept, tool calls become StepToolUse blocks followed by their
+// StepToolResult; thinking blocks are dropped because their signatures are
+// only valid for the turn that produced them.
+func (t *Transcript) Add(response []byte, results []Result) {
+	byID := make(map[string]Result, len(results))
+	for _, result := range results {
+		byID[result.ID] = result
+	}
+	for _, block := range gjson.GetBytes(response, "content").Array() {
+		switch block.Get("type").String() {
+		case "text":
+			t.blocks = append(t.blocks, block.Raw)
+		case "tool_use":
+			step, _ := sjson.Set(block.Raw, "type", StepToolUse)
+			t.blocks = append(t.blocks, step)
+			result := byID[block.Get("id").String()]
+			out, _ := json.Marshal(map[string]any{"type": StepToolResult, "tool_use_id": result.ID, "content": result.Output, "is_error": result.IsError})
+			t.blocks = append(t.blocks, string(out))
+		}
+	}
+	t.inputTokens += gjson.GetBytes(response, "usage.input_tokens").Int()
+	t.outputTokens += gjson.GetBytes(response, "usage.output_tokens").Int()
+}
+
+// Finish prepends the recorded steps to the content of the final response and
+// adds their token usage to it.
+func (t *Transcript) Finish(final []byte) []byte {
+	if len(t.blocks) == 0 {
+		return final
+	}
+	content := gjson.GetBytes(final, "content").Array()
+	raw := append([]string(nil), t.blocks...)
+	for _, block := range content {
+		raw = append(raw, block.Raw)
+	}
+	out, err := sjson.SetRawBytes(final, "content", []byte("["+strings.Join(raw, ",")+"]"))
+	if err != nil {
+		return final
+	}
+	out, _ = sjson.SetBytes(out, "usage.input_tokens", gjson.GetBytes(final, "usage.input_tokens").Int()+t.inputTokens)
+	out, _ = sjson.SetBytes(out, "usage.output_tokens", gjson.GetBytes(final, "usage.output_tokens").Int()+t.outputTokens)
+	return out
+}
+
+// FlattenSteps rewrites StepToolUse and StepToolResult blocks that clients send
+// back in earlier assistant turns into text, since upstreams do not know these
+// block types. The model still sees which tools ran and what they returned.
+func FlattenSteps(payload []byte) []byte {
+	out := payload
+	for i, message := range gjson.GetBytes(payload, "messages").Array() {
+		content := message.Get("content")
+		if message.Get("role").String() != "assistant" || !content.IsArray() {
+			continue
+		}
+		changed := false
+		raw := make([]string, 0, len(content.Array()))
+		for _, block := range content.Array() {
+			var text string
+			switch block.Get("type").String() {
+			case StepToolUse:
+				text = fmt.Sprintf("[called tool %s with %s]", block.Get("name").String(), block.Get("input").Raw)
+			case StepToolResult:
+				text = "[tool result]\n" + block.Get("content").String()
+			default:
+				raw = append(raw, block.Raw)
+				continue
+			}
+			changed = true
+			textBlock, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+			raw = append(raw, textBlock)
+		}
+		if changed {
+			if updated, err := sjson.SetRawBytes(out, fmt.Sprintf("messages.%d.content", i), []byte("["+strings.Join(raw, ",")+"]")); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+const mathToolName = "math"
+
+// mathTool evaluates arithmetic expressions.
+type mathTool struct{}
+
+func (mathTool) Name() string { return mathToolName }
+
+func (mathTool) Schema() Schema {
+	return Schema{
+		Description: "Evaluate an arithmetic expression with + - * / % ^ and parentheses, e.g. \"(2 + 3) * 4.5\".",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"expression": map[string]any{"type": "string", "description": "The expression to evaluate"},
+			},
+			"required": []string{"expression"},
+		},
+	}
+}
+
+func (mathTool) Execute(_ context.Context, args []byte) (string, error) {
+	value, err := Evaluate(gjson.GetBytes(args, "expression").String())
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+// Evaluate computes an arithmetic expression. ^ is exponentiation and binds
+// tighter than unary minus, so -2^2 is -4.
+func Evaluate(expression string) (float64, error) {
+	p := &exprParser{input: expression}
+	value, err := p.sum()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) sum() (float64, error) {
+	left, err := p.product()
+	for err == nil {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		var right float64
+		if right, err = p.product(); err == nil {
+			if op == '+' {
+				left += right
+			} else {
+				left -= right
+			}
+		}
+	}
+	return 0, err
+}
+
+func (p *exprParser) product() (float64, error) {
+	left, err := p.unary()
+	for err == nil {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		var right float64
+		if right, err = p.unary(); err != nil {
+			break
+		}
+		switch {
+		case op == '*':
+			left *= right
+		case right == 0:
+			return 0, fmt.Errorf("division by zero")
+		case op == '/':
+			left /= right
+		default:
+			left = math.Mod(left, right)
+		}
+	}
+	return 0, err
+}
+
+func (p *exprParser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.unary()
+		return -value, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+func (p *exprParser) power() (float64, error) {
+	base, err := p.operand()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (p *exprParser) operand() (float64, error) {
+	if p.peek() == '(' {
+		p.pos++
+		value, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return value, nil
+	}
+	start := p.pos
+	for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos >= len(p.input) {
+			return 0, fmt.Errorf("unexpected end of expression")
+		}
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(p.input[start:p.pos]), 64)
+}
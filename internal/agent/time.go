@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const timeToolName = "time"
+
+// now is replaced in tests.
+var now = time.Now
+
+// timeTool reports the current date and time.
+type timeTool struct{}
+
+func (timeTool) Name() string { return timeToolName }
+
+func (timeTool) Schema() Schema {
+	return Schema{
+		Description: "Return the current date and time, in UTC or in the given IANA time zone (e.g. \"Europe/Berlin\").",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"timezone": map[string]any{"type": "string", "description": "IANA time zone name; defaults to UTC"},
+			},
+		},
+	}
+}
+
+func (timeTool) Execute(_ context.Context, args []byte) (string, error) {
+	location := time.UTC
+	if name := gjson.GetBytes(args, "timezone").String(); name != "" {
+		loaded, err := time.LoadLocation(name)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %q", name)
+		}
+		location = loaded
+	}
+	current := now().In(location)
+	return fmt.Sprintf("%s (%s)", current.Format(time.RFC3339), current.Weekday()), nil
+}
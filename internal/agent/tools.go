@@ -0,0 +1,92 @@
+// Package agent implements the server-side tool loop ("agent mode"): tool
+// calls for tools the proxy implements itself are executed locally and the
+// conversation is sent upstream again until the model produces a final answer.
+package agent
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Schema describes a tool to the model.
+type Schema struct {
+	// Description tells the model what the tool does.
+	Description string
+	// InputSchema is the JSON schema of the tool input.
+	InputSchema map[string]any
+}
+
+// Tool is a tool the proxy can execute on behalf of the model.
+type Tool interface {
+	// Name is the tool name advertised to the model.
+	Name() string
+	// Schema describes the tool and its input.
+	Schema() Schema
+	// Execute runs the tool with the JSON arguments produced by the model and
+	// returns the text handed back as the tool result.
+	Execute(ctx context.Context, args []byte) (string, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Tool)
+)
+
+func init() {
+	Register(mathTool{})
+	Register(timeTool{})
+	Register(newHTTPFetchTool())
+}
+
+// Register adds a tool to the registry, replacing any tool with the same name.
+func Register(tool Tool) {
+	if tool == nil || tool.Name() == "" {
+		return
+	}
+	registryMu.Lock()
+	registry[tool.Name()] = tool
+	registryMu.Unlock()
+}
+
+// Unregister removes the tool with the given name from the registry.
+func Unregister(name string) {
+	registryMu.Lock()
+	delete(registry, name)
+	registryMu.Unlock()
+}
+
+// Lookup returns the registered tool with the given name.
+func Lookup(name string) (Tool, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	tool, ok := registry[name]
+	return tool, ok
+}
+
+// Names returns the names of all registered tools in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Tools returns the registered tools with the given names, or all of them when
+// names is empty. Unknown names are ignored.
+func Tools(names []string) []Tool {
+	if len(names) == 0 {
+		names = Names()
+	}
+	var tools []Tool
+	for _, name := range names {
+		if tool, ok := Lookup(name); ok {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
@@ -0,0 +1,193 @@
+// Package anonymize rewrites captured request/response fixtures so they can be
+// shared publicly. User-authored text is replaced by length-preserving filler,
+// credentials are scrubbed, and the structure that matters for reproducing
+// translation bugs (roles, block types, tool call pairing) is kept intact.
+package anonymize
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sanitize"
+)
+
+// Options tunes the anonymizer.
+type Options struct {
+	// HashToolNames replaces tool and function names with a stable hash so the
+	// same name maps to the same placeholder everywhere in the fixture.
+	HashToolNames bool
+}
+
+// structuralKeys hold protocol values that are preserved verbatim (after
+// credential scrubbing) because they describe shape rather than content.
+var structuralKeys = map[string]struct{}{
+	"role": {}, "type": {}, "id": {}, "tool_use_id": {}, "tool_call_id": {}, "call_id": {},
+	"model": {}, "media_type": {}, "mime_type": {}, "mimeType": {}, "stop_reason": {},
+	"finish_reason": {}, "finishReason": {}, "object": {}, "status": {}, "provider": {},
+	"source_format": {}, "target_format": {}, "captured_at": {}, "event": {}, "format": {},
+}
+
+// toolNameKeys identify fields carrying tool or function names.
+var toolNameKeys = map[string]struct{}{"name": {}}
+
+// binaryKeys hold base64 payloads (images, documents) that are blanked while
+// keeping their length.
+var binaryKeys = map[string]struct{}{"data": {}, "image_url": {}, "url": {}}
+
+// secretPatterns are the shared credential detectors plus AWS resource names,
+// which identify accounts.
+var secretPatterns = append(sanitize.SecretPatterns(),
+	regexp.MustCompile(`arn:aws[a-z-]*:[a-z0-9-]+:[a-z0-9-]*:[0-9]*:[A-Za-z0-9/_+=.@:-]+`),
+)
+
+const lorem = "loremipsumdolorsitametconsecteturadipiscingelitseddoeiusmodtemporincididuntutlaboreetdoloremagnaaliqua"
+
+// Fixture anonymizes a JSON document.
+func Fixture(data []byte, opts Options) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	a := &anonymizer{opts: opts}
+	doc = a.value("", doc)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type anonymizer struct {
+	opts Options
+}
+
+func (a *anonymizer) value(key string, v any) any {
+	switch typed := v.(type) {
+	case map[string]any:
+		for k, child := range typed {
+			typed[k] = a.value(k, child)
+		}
+		return typed
+	case []any:
+		for i, child := range typed {
+			typed[i] = a.value(key, child)
+		}
+		return typed
+	case string:
+		return a.text(key, typed)
+	default:
+		return v
+	}
+}
+
+func (a *anonymizer) text(key, s string) string {
+	if _, ok := structuralKeys[key]; ok {
+		return scrubSecrets(s)
+	}
+	if _, ok := toolNameKeys[key]; ok {
+		if a.opts.HashToolNames {
+			return hashName(s)
+		}
+		return scrubSecrets(s)
+	}
+	if _, ok := binaryKeys[key]; ok && looksBinary(s) {
+		return blankBinary(s)
+	}
+	// Embedded JSON (tool arguments) and raw SSE captures keep their structure.
+	if nested, ok := a.nestedJSON(s); ok {
+		return nested
+	}
+	if strings.Contains(s, "\ndata:") || strings.HasPrefix(s, "data:") || strings.HasPrefix(s, "event:") {
+		return a.sse(s)
+	}
+	return filler(s)
+}
+
+func (a *anonymizer) nestedJSON(s string) (string, bool) {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) < 2 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return "", false
+	}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	var doc any
+	if decoder.Decode(&doc) != nil || decoder.More() {
+		return "", false
+	}
+	out, err := json.Marshal(a.value("", doc))
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+func (a *anonymizer) sse(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		payload, found := strings.CutPrefix(line, "data:")
+		if !found {
+			continue
+		}
+		if nested, ok := a.nestedJSON(payload); ok {
+			lines[i] = "data: " + nested
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// filler replaces every non-space rune with lorem text, keeping the rune count
+// and whitespace layout of the original.
+func filler(s string) string {
+	var b strings.Builder
+	i := 0
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteByte(lorem[i%len(lorem)])
+		i++
+	}
+	return b.String()
+}
+
+func scrubSecrets(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			return strings.Repeat("x", len(match))
+		})
+	}
+	return s
+}
+
+func hashName(name string) string {
+	if name == "" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return "tool_" + hex.EncodeToString(sum[:4])
+}
+
+func looksBinary(s string) bool {
+	if strings.HasPrefix(s, "data:") && strings.Contains(s, ";base64,") {
+		return true
+	}
+	return len(s) >= 64 && !strings.ContainsAny(s, " \n")
+}
+
+func blankBinary(s string) string {
+	if prefix, data, found := strings.Cut(s, ";base64,"); found && strings.HasPrefix(prefix, "data:") {
+		return prefix + ";base64," + strings.Repeat("A", len(data))
+	}
+	return strings.Repeat("A", len(s))
+}
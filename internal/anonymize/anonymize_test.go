@@ -0,0 +1,65 @@
+package anonymize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestFixturePreservesStructure(t *testing.T) {
+	input := `{
+		"provider": "claude",
+		"request": {
+			"model": "claude-sonnet-4",
+			"messages": [
+				{"role": "user", "content": [{"type": "text", "text": "deploy to arn:aws:iam::123456789012:role/Admin now"}]},
+				{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "run_shell", "input": {"cmd": "ls -la"}}]},
+				{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": "secret output"}]}
+			]
+		},
+		"upstream_request": {"messages": [{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "run_shell", "arguments": "{\"cmd\":\"cat sk-abcdefghijklmnopqrstuvwx\"}"}}]}]}
+	}`
+
+	out, err := Fixture([]byte(input), Options{HashToolNames: true})
+	if err != nil {
+		t.Fatalf("Fixture() error = %v", err)
+	}
+	doc := gjson.ParseBytes(out)
+
+	text := doc.Get("request.messages.0.content.0.text").String()
+	original := "deploy to arn:aws:iam::123456789012:role/Admin now"
+	if len(text) != len(original) || strings.Contains(text, "arn:") || strings.Count(text, " ") != strings.Count(original, " ") {
+		t.Fatalf("text not anonymized with preserved length: %q", text)
+	}
+	if got := doc.Get("request.messages.1.content.0.type").String(); got != "tool_use" {
+		t.Fatalf("block type = %q, want tool_use", got)
+	}
+	if doc.Get("request.messages.1.content.0.id").String() != doc.Get("request.messages.2.content.0.tool_use_id").String() {
+		t.Fatal("tool_use/tool_result pairing was not preserved")
+	}
+	name := doc.Get("request.messages.1.content.0.name").String()
+	if name == "run_shell" || name != doc.Get("upstream_request.messages.0.tool_calls.0.function.name").String() {
+		t.Fatalf("tool names not hashed consistently: %q", name)
+	}
+	args := doc.Get("upstream_request.messages.0.tool_calls.0.function.arguments").String()
+	if !gjson.Valid(args) || strings.Contains(args, "sk-") || !gjson.Get(args, "cmd").Exists() {
+		t.Fatalf("nested arguments not anonymized structurally: %q", args)
+	}
+	if got := doc.Get("request.model").String(); got != "claude-sonnet-4" {
+		t.Fatalf("model = %q, want preserved", got)
+	}
+}
+
+func TestFixtureKeepsToolNamesByDefault(t *testing.T) {
+	out, err := Fixture([]byte(`{"tools":[{"name":"Read","description":"read a file"}]}`), Options{})
+	if err != nil {
+		t.Fatalf("Fixture() error = %v", err)
+	}
+	if got := gjson.GetBytes(out, "tools.0.name").String(); got != "Read" {
+		t.Fatalf("tool name = %q, want Read", got)
+	}
+	if got := gjson.GetBytes(out, "tools.0.description").String(); got == "read a file" {
+		t.Fatal("description was not anonymized")
+	}
+}
@@ -347,7 +347,7 @@ func (h *Handler) listAuthFilesFromDisk(c *gin.Context) {
 
 			// Read file to get type field
 			full := filepath.Join(h.cfg.AuthDir, name)
-			if data, errRead := os.ReadFile(full); errRead == nil {
+			if data, errRead := misc.ReadCredentialFile(full); errRead == nil {
 				typeValue := gjson.GetBytes(data, "type").String()
 				emailValue := gjson.GetBytes(data, "email").String()
 				fileData["type"] = typeValue
@@ -521,7 +521,7 @@ func (h *Handler) DownloadAuthFile(c *gin.Context) {
 		return
 	}
 	full := filepath.Join(h.cfg.AuthDir, name)
-	data, err := os.ReadFile(full)
+	data, err := misc.ReadCredentialFile(full)
 	if err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
@@ -562,6 +562,12 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			c.JSON(500, gin.H{"error": fmt.Sprintf("failed to read saved file: %v", errRead)})
 			return
 		}
+		if misc.CredentialEncryptionEnabled() {
+			if errWrite := writeAuthFile(dst, data); errWrite != nil {
+				c.JSON(500, gin.H{"error": fmt.Sprintf("failed to write file: %v", errWrite)})
+				return
+			}
+		}
 		if errReg := h.registerAuthFromFile(ctx, dst, data); errReg != nil {
 			c.JSON(500, gin.H{"error": errReg.Error()})
 			return
@@ -589,7 +595,7 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 			dst = abs
 		}
 	}
-	if errWrite := os.WriteFile(dst, data, 0o600); errWrite != nil {
+	if errWrite := writeAuthFile(dst, data); errWrite != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("failed to write file: %v", errWrite)})
 		return
 	}
@@ -685,6 +691,16 @@ func (h *Handler) authIDForPath(path string) string {
 	return path
 }
 
+// writeAuthFile stores an uploaded auth file, encrypting it when auth file
+// encryption is enabled.
+func writeAuthFile(path string, data []byte) error {
+	sealed, err := misc.EncryptCredentials(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, 0o600)
+}
+
 func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []byte) error {
 	if h.authManager == nil {
 		return nil
@@ -699,6 +715,10 @@ func (h *Handler) registerAuthFromFile(ctx context.Context, path string, data []
 			return fmt.Errorf("failed to read auth file: %w", err)
 		}
 	}
+	data, errDecrypt := misc.DecryptCredentials(data)
+	if errDecrypt != nil {
+		return fmt.Errorf("failed to read auth file: %w", errDecrypt)
+	}
 	metadata := make(map[string]any)
 	if err := json.Unmarshal(data, &metadata); err != nil {
 		return fmt.Errorf("invalid auth file: %w", err)
@@ -0,0 +1,41 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetCircuitBreakers lists the per-account circuit breaker state and counters.
+func (h *Handler) GetCircuitBreakers(c *gin.Context) {
+	enabled := h.cfg != nil && h.cfg.CircuitBreaker.Enabled
+	accounts := []coreauth.CircuitBreakerStatus{}
+	if h.authManager != nil {
+		accounts = append(accounts, h.authManager.CircuitBreakers()...)
+	}
+	open := 0
+	for i := range accounts {
+		if accounts[i].State != "closed" {
+			open++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "open": open, "accounts": accounts})
+}
+
+// ResetCircuitBreaker closes the circuit of one account, identified by auth ID or auth index.
+func (h *Handler) ResetCircuitBreaker(c *gin.Context) {
+	var body struct {
+		ID string `json:"id"`
+	}
+	if errBindJSON := c.ShouldBindJSON(&body); errBindJSON != nil || strings.TrimSpace(body.ID) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	if h.authManager == nil || !h.authManager.ResetCircuitBreaker(strings.TrimSpace(body.ID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
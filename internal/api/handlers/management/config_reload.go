@@ -0,0 +1,29 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
+)
+
+// GetConfigReloadStatus reports the outcome of the latest configuration reloads.
+func (h *Handler) GetConfigReloadStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, watcher.CurrentReloadStatus())
+}
+
+// ReloadConfig reloads the configuration file immediately. Requests in flight
+// finish with the configuration they started with.
+func (h *Handler) ReloadConfig(c *gin.Context) {
+	err := watcher.ReloadNow(watcher.ReloadSourceAPI)
+	if errors.Is(err, watcher.ErrNoWatcher) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "reload_failed", "message": err.Error(), "status": watcher.CurrentReloadStatus()})
+		return
+	}
+	c.JSON(http.StatusOK, watcher.CurrentReloadStatus())
+}
@@ -0,0 +1,150 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+)
+
+// GetFeatureFlags lists the configured feature flags and the known flag
+// definitions. With ?api-key=... it also reports the effective value of every
+// known flag for that client key.
+func (h *Handler) GetFeatureFlags(c *gin.Context) {
+	flags := []config.FeatureFlag{}
+	flags = append(flags, h.cfg.FeatureFlags...)
+	response := gin.H{"feature-flags": flags, "known": featureflags.Known()}
+	if key, ok := c.GetQuery("api-key"); ok {
+		effective := make(map[string]bool)
+		for _, def := range featureflags.Known() {
+			effective[def.Name] = featureflags.Enabled(flags, def.Name, key)
+		}
+		response["effective"] = effective
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PutFeatureFlags replaces the feature flag list.
+func (h *Handler) PutFeatureFlags(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.FeatureFlag
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.FeatureFlag `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	seen := make(map[string]struct{}, len(arr))
+	for i := range arr {
+		if errValidate := validateFeatureFlag(&arr[i]); errValidate != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errValidate.Error()})
+			return
+		}
+		if _, dup := seen[arr[i].Name]; dup {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("duplicate feature flag %q", arr[i].Name)})
+			return
+		}
+		seen[arr[i].Name] = struct{}{}
+	}
+	h.cfg.FeatureFlags = arr
+	h.persist(c)
+}
+
+// PatchFeatureFlag updates one flag by name, adding it when it is not configured yet.
+func (h *Handler) PatchFeatureFlag(c *gin.Context) {
+	type featureFlagPatch struct {
+		Enabled   *bool            `json:"enabled"`
+		Rollout   *int             `json:"rollout"`
+		Overrides *map[string]bool `json:"overrides"`
+	}
+	var body struct {
+		Name  string            `json:"name"`
+		Value *featureFlagPatch `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	name := featureflags.Normalize(body.Name)
+	targetIndex := -1
+	for i := range h.cfg.FeatureFlags {
+		if featureflags.Normalize(h.cfg.FeatureFlags[i].Name) == name {
+			targetIndex = i
+			break
+		}
+	}
+	entry := config.FeatureFlag{Name: name}
+	if targetIndex >= 0 {
+		entry = h.cfg.FeatureFlags[targetIndex]
+	}
+	if body.Value.Enabled != nil {
+		entry.Enabled = *body.Value.Enabled
+	}
+	if body.Value.Rollout != nil {
+		entry.Rollout = *body.Value.Rollout
+	}
+	if body.Value.Overrides != nil {
+		entry.Overrides = *body.Value.Overrides
+	}
+	if err := validateFeatureFlag(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if targetIndex >= 0 {
+		h.cfg.FeatureFlags[targetIndex] = entry
+	} else {
+		h.cfg.FeatureFlags = append(h.cfg.FeatureFlags, entry)
+	}
+	h.persist(c)
+}
+
+// DeleteFeatureFlag removes a flag by ?name=..., restoring its default behavior.
+func (h *Handler) DeleteFeatureFlag(c *gin.Context) {
+	name := featureflags.Normalize(c.Query("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+	out := make([]config.FeatureFlag, 0, len(h.cfg.FeatureFlags))
+	for _, flag := range h.cfg.FeatureFlags {
+		if featureflags.Normalize(flag.Name) != name {
+			out = append(out, flag)
+		}
+	}
+	if len(out) == len(h.cfg.FeatureFlags) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+		return
+	}
+	h.cfg.FeatureFlags = out
+	h.persist(c)
+}
+
+// validateFeatureFlag normalizes the flag name and rejects unknown names and
+// out-of-range rollouts.
+func validateFeatureFlag(flag *config.FeatureFlag) error {
+	flag.Name = featureflags.Normalize(flag.Name)
+	if !featureflags.IsKnown(flag.Name) {
+		return fmt.Errorf("unknown feature flag %q", flag.Name)
+	}
+	if flag.Rollout < 0 || flag.Rollout > 100 {
+		return fmt.Errorf("rollout for %q must be between 0 and 100", flag.Name)
+	}
+	for key := range flag.Overrides {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("override keys for %q must not be empty", flag.Name)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package management
 
 import (
 	"bufio"
+	"compress/gzip"
 	"fmt"
 	"math"
 	"net/http"
@@ -211,6 +212,7 @@ func (h *Handler) GetRequestErrorLogs(c *gin.Context) {
 
 // GetRequestLogByID finds and downloads a request log file by its request ID.
 // The ID is matched against the suffix of log file names (format: *-{requestID}.log).
+// Logs compressed by retention (*-{requestID}.log.gz) are served decompressed.
 func (h *Handler) GetRequestLogByID(c *gin.Context) {
 	if h == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
@@ -257,7 +259,7 @@ func (h *Handler) GetRequestLogByID(c *gin.Context) {
 			continue
 		}
 		name := entry.Name()
-		if strings.HasSuffix(name, suffix) {
+		if strings.HasSuffix(name, suffix) || strings.HasSuffix(name, suffix+".gz") {
 			matchedFile = name
 			break
 		}
@@ -294,7 +296,26 @@ func (h *Handler) GetRequestLogByID(c *gin.Context) {
 		return
 	}
 
-	c.FileAttachment(fullPath, matchedFile)
+	if !strings.HasSuffix(matchedFile, ".gz") {
+		c.FileAttachment(fullPath, matchedFile)
+		return
+	}
+	file, errOpen := os.Open(fullPath)
+	if errOpen != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read log file: %v", errOpen)})
+		return
+	}
+	defer func() { _ = file.Close() }()
+	reader, errGzip := gzip.NewReader(file)
+	if errGzip != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to decompress log file: %v", errGzip)})
+		return
+	}
+	defer func() { _ = reader.Close() }()
+	name := strings.TrimSuffix(matchedFile, ".gz")
+	c.DataFromReader(http.StatusOK, -1, "text/plain; charset=utf-8", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", name),
+	})
 }
 
 // DownloadRequestErrorLog downloads a specific error request log file by name.
@@ -581,3 +602,23 @@ func timestampRotationOrder(name string) (int64, bool) {
 	}
 	return math.MaxInt64 - parsed.Unix(), true
 }
+
+// GetLogsUsage reports disk usage of the logs directory per artifact type together
+// with the configured size limit and high-water mark state.
+func (h *Handler) GetLogsUsage(c *gin.Context) {
+	if h == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "handler unavailable"})
+		return
+	}
+	if h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "configuration unavailable"})
+		return
+	}
+	dir := h.logDirectory()
+	if strings.TrimSpace(dir) == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "log directory not configured"})
+		return
+	}
+	maxBytes := int64(h.cfg.LogsMaxTotalSizeMB) * 1024 * 1024
+	c.JSON(http.StatusOK, logging.MeasureLogDirUsage(dir, maxBytes, h.cfg.LogRetention.HighWaterMarkPercent))
+}
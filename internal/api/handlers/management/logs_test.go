@@ -0,0 +1,54 @@
+package management
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestGetRequestLogByIDFindsPlainAndCompressedLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "v1-chat-2026-10-16-abc123.log"), []byte("plain log"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(dir, "v1-chat-2026-10-15-old456.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(file)
+	_, _ = zw.Write([]byte("compressed log"))
+	_ = zw.Close()
+	_ = file.Close()
+
+	h := &Handler{cfg: &config.Config{}, logDir: dir}
+	fetch := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/request-log-by-id/"+id, nil)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+		h.GetRequestLogByID(c)
+		return w
+	}
+
+	if w := fetch("abc123"); w.Code != http.StatusOK || w.Body.String() != "plain log" {
+		t.Fatalf("plain log: %d %q", w.Code, w.Body.String())
+	}
+	w := fetch("old456")
+	if w.Code != http.StatusOK || w.Body.String() != "compressed log" {
+		t.Fatalf("compressed log: %d %q", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, `"v1-chat-2026-10-15-old456.log"`) {
+		t.Fatalf("Content-Disposition = %q", disposition)
+	}
+	if w = fetch("missing"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing log: %d", w.Code)
+	}
+}
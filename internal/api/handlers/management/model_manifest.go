@@ -0,0 +1,52 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelmanifest"
+)
+
+// GetModelManifest reports the remote model manifest sync state.
+func (h *Handler) GetModelManifest(c *gin.Context) {
+	syncer := modelmanifest.Current()
+	if syncer == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "status": syncer.Status()})
+}
+
+// RefreshModelManifest revalidates the remote model manifest immediately.
+func (h *Handler) RefreshModelManifest(c *gin.Context) {
+	syncer := modelmanifest.Current()
+	if syncer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model manifest sync is disabled"})
+		return
+	}
+	if err := syncer.Refresh(c.Request.Context()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "refresh_failed", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": syncer.Status()})
+}
+
+// ApproveModelManifest applies the manifest awaiting approval.
+func (h *Handler) ApproveModelManifest(c *gin.Context) {
+	syncer := modelmanifest.Current()
+	if syncer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model manifest sync is disabled"})
+		return
+	}
+	version, err := syncer.Approve()
+	if errors.Is(err, modelmanifest.ErrNoPendingManifest) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"approved_version": version})
+}
@@ -0,0 +1,64 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/inspect"
+	"github.com/tidwall/gjson"
+)
+
+// GetInspectedRequests lists the requests kept by the request inspector, newest first.
+func (h *Handler) GetInspectedRequests(c *gin.Context) {
+	enabled := h.cfg != nil && h.cfg.RequestInspector.Enabled
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "requests": inspect.Default().List()})
+}
+
+// GetInspectedRequest returns one inspected request with its request and response bodies.
+func (h *Handler) GetInspectedRequest(c *gin.Context) {
+	record, request, response, ok := inspect.Default().Get(strings.TrimSpace(c.Param("id")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+	c.JSON(http.StatusOK, inspectedPair(record, request, response))
+}
+
+// DownloadInspectedRequest serves the request/response pair of one inspected
+// request as a JSON attachment.
+func (h *Handler) DownloadInspectedRequest(c *gin.Context) {
+	record, request, response, ok := inspect.Default().Get(strings.TrimSpace(c.Param("id")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+	data, err := json.MarshalIndent(inspectedPair(record, request, response), "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "request-"+record.ID+".json"))
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// DeleteInspectedRequests clears the request inspector history.
+func (h *Handler) DeleteInspectedRequests(c *gin.Context) {
+	inspect.Default().Clear()
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func inspectedPair(record inspect.Record, request, response []byte) gin.H {
+	return gin.H{"record": record, "request": inspectedBody(request), "response": inspectedBody(response)}
+}
+
+// inspectedBody embeds JSON bodies as JSON and anything else (SSE streams,
+// truncated bodies) as a string.
+func inspectedBody(body []byte) any {
+	if len(body) > 0 && gjson.ValidBytes(body) {
+		return json.RawMessage(body)
+	}
+	return string(body)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 type usageExportPayload struct {
@@ -27,11 +28,49 @@ func (h *Handler) GetUsageStatistics(c *gin.Context) {
 		snapshot = h.usageStats.Snapshot()
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"usage":           snapshot,
-		"failed_requests": snapshot.FailureCount,
+		"usage":                snapshot,
+		"failed_requests":      snapshot.FailureCount,
+		"stream_aborts":        usage.StreamAborts(),
+		"quarantined_events":   usage.QuarantinedEvents(),
+		"echo_strips":          usage.EchoStrips(),
+		"hedges":               usage.Hedges(),
+		"traffic_splits":       usage.TrafficArms(),
+		"rate_limit_queue":     usage.RateLimitQueue(),
+		"upstream_connections": util.UpstreamConnections(),
 	})
 }
 
+// GetDailyUsage returns the daily usage per API key and model kept by the
+// usage store, optionally limited to the days between the from and to query
+// parameters (YYYY-MM-DD, inclusive). format=csv returns CSV instead of JSON.
+func (h *Handler) GetDailyUsage(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	for _, day := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", day); day != "" && err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be dates as YYYY-MM-DD"})
+			return
+		}
+	}
+	rows, err := usage.CurrentStore().Rows(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="usage.csv"`)
+		c.Status(http.StatusOK)
+		if err = usage.WriteCSV(c.Writer, rows); err != nil {
+			_ = c.Error(err)
+		}
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"usage": rows})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
 // ExportUsageStatistics returns a complete usage snapshot for backup/migration.
 func (h *Handler) ExportUsageStatistics(c *gin.Context) {
 	var snapshot usage.StatisticsSnapshot
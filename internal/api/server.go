@@ -7,11 +7,13 @@ package api
 import (
 	"context"
 	"crypto/subtle"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,6 +26,8 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/health"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/inspect"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
@@ -152,6 +156,9 @@ type Server struct {
 	// management handler
 	mgmt *managementHandlers.Handler
 
+	// health evaluates the readiness probe.
+	health *health.Checker
+
 	// ampModule is the Amp routing module for model mapping hot-reload
 	ampModule *ampmodule.AmpModule
 
@@ -258,6 +265,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	misc.SetCodexInstructionsEnabled(cfg.CodexInstructionsEnabled)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
+	s.health = health.NewChecker(authManager, cfg.Health)
 	if optionState.localPassword != "" {
 		s.mgmt.SetLocalPassword(optionState.localPassword)
 	}
@@ -309,6 +317,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 // It defines the endpoints and associates them with their respective handlers.
 func (s *Server) setupRoutes() {
 	s.engine.GET("/management.html", s.serveManagementControlPanel)
+	s.engine.GET("/inspector.html", s.serveRequestInspector)
 	openaiHandlers := openai.NewOpenAIAPIHandler(s.handlers)
 	geminiHandlers := gemini.NewGeminiAPIHandler(s.handlers)
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
@@ -321,10 +330,17 @@ func (s *Server) setupRoutes() {
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
+		v1.GET("/chat/stream", openaiHandlers.ChatStreamWebSocket)
 		v1.POST("/completions", openaiHandlers.Completions)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		v1.POST("/canonicalize", s.handlers.Canonicalize)
+		v1.POST("/batches", s.handlers.CreateBatch)
+		v1.GET("/batches", s.handlers.ListBatches)
+		v1.GET("/batches/:id", s.handlers.GetBatch)
+		v1.POST("/batches/:id/cancel", s.handlers.CancelBatch)
+		v1.GET("/batches/:id/output", s.handlers.GetBatchOutput)
 	}
 
 	// Gemini compatible API routes
@@ -349,6 +365,12 @@ func (s *Server) setupRoutes() {
 	})
 	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
 
+	// Kubernetes-style liveness and readiness probes
+	s.engine.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	s.engine.GET("/readyz", s.handleReadiness)
+
 	// OAuth callback endpoints (reuse main server port)
 	// These endpoints receive provider redirects and persist
 	// the short-lived code/state for the waiting goroutine.
@@ -477,9 +499,25 @@ func (s *Server) registerManagementRoutes() {
 	{
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
+		mgmt.GET("/usage/daily", s.mgmt.GetDailyUsage)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/model-manifest", s.mgmt.GetModelManifest)
+		mgmt.POST("/model-manifest/refresh", s.mgmt.RefreshModelManifest)
+		mgmt.POST("/model-manifest/approve", s.mgmt.ApproveModelManifest)
+		mgmt.GET("/circuit-breakers", s.mgmt.GetCircuitBreakers)
+		mgmt.POST("/circuit-breakers/reset", s.mgmt.ResetCircuitBreaker)
+		mgmt.GET("/feature-flags", s.mgmt.GetFeatureFlags)
+		mgmt.PUT("/feature-flags", s.mgmt.PutFeatureFlags)
+		mgmt.PATCH("/feature-flags", s.mgmt.PatchFeatureFlag)
+		mgmt.DELETE("/feature-flags", s.mgmt.DeleteFeatureFlag)
+		mgmt.GET("/requests", s.mgmt.GetInspectedRequests)
+		mgmt.DELETE("/requests", s.mgmt.DeleteInspectedRequests)
+		mgmt.GET("/requests/:id", s.mgmt.GetInspectedRequest)
+		mgmt.GET("/requests/:id/download", s.mgmt.DownloadInspectedRequest)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
+		mgmt.GET("/config/reload", s.mgmt.GetConfigReloadStatus)
+		mgmt.POST("/config/reload", s.mgmt.ReloadConfig)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
 		mgmt.GET("/latest-version", s.mgmt.GetLatestVersion)
 
@@ -526,6 +564,7 @@ func (s *Server) registerManagementRoutes() {
 
 		mgmt.GET("/logs", s.mgmt.GetLogs)
 		mgmt.DELETE("/logs", s.mgmt.DeleteLogs)
+		mgmt.GET("/logs/usage", s.mgmt.GetLogsUsage)
 		mgmt.GET("/request-error-logs", s.mgmt.GetRequestErrorLogs)
 		mgmt.GET("/request-error-logs/:name", s.mgmt.DownloadRequestErrorLog)
 		mgmt.GET("/request-log-by-id/:id", s.mgmt.GetRequestLogByID)
@@ -661,6 +700,16 @@ func (s *Server) serveManagementControlPanel(c *gin.Context) {
 	c.File(filePath)
 }
 
+// serveRequestInspector serves the embedded live request inspector page. The
+// page itself is static; its data comes from the management API.
+func (s *Server) serveRequestInspector(c *gin.Context) {
+	if s.cfg == nil || s.cfg.RemoteManagement.DisableControlPanel {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", inspect.Page())
+}
+
 func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
 	if timeout <= 0 || onTimeout == nil {
 		return
@@ -677,6 +726,16 @@ func (s *Server) enableKeepAlive(timeout time.Duration, onTimeout func()) {
 	go s.watchKeepAlive()
 }
 
+// handleReadiness reports per-account status and answers 503 until requests can be served.
+func (s *Server) handleReadiness(c *gin.Context) {
+	report := s.health.Check(c.Request.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
 func (s *Server) handleKeepAlive(c *gin.Context) {
 	if s.localPassword != "" {
 		provided := strings.TrimSpace(c.GetHeader("Authorization"))
@@ -740,15 +799,16 @@ func (s *Server) watchKeepAlive() {
 }
 
 // unifiedModelsHandler creates a unified handler for the /v1/models endpoint
-// that routes to different handlers based on the User-Agent header.
-// If User-Agent starts with "claude-cli", it routes to Claude handler,
-// otherwise it routes to OpenAI handler.
+// that routes to different handlers based on the client.
+// Anthropic clients (User-Agent starting with "claude-cli", or any request
+// carrying the anthropic-version header the Anthropic SDKs send) get the
+// Anthropic-shaped listing; everything else gets the OpenAI-shaped one.
 func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, claudeHandler *claude.ClaudeCodeAPIHandler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userAgent := c.GetHeader("User-Agent")
 
-		// Route to Claude handler if User-Agent starts with "claude-cli"
-		if strings.HasPrefix(userAgent, "claude-cli") {
+		// Route to Claude handler for Anthropic clients
+		if strings.HasPrefix(userAgent, "claude-cli") || c.GetHeader("anthropic-version") != "" {
 			// log.Debugf("Routing /v1/models to Claude handler for User-Agent: %s", userAgent)
 			claudeHandler.ClaudeModels(c)
 		} else {
@@ -775,6 +835,17 @@ func (s *Server) Start() error {
 		if cert == "" || key == "" {
 			return fmt.Errorf("failed to start HTTPS server: tls.cert or tls.key is empty")
 		}
+		if clientCA := strings.TrimSpace(s.cfg.TLS.ClientCA); clientCA != "" {
+			pool, errPool := util.LoadCertPool(clientCA)
+			if errPool != nil {
+				return fmt.Errorf("failed to start HTTPS server: tls.client-ca: %v", errPool)
+			}
+			clientAuth := tls.VerifyClientCertIfGiven
+			if s.cfg.TLS.RequireClientCert {
+				clientAuth = tls.RequireAndVerifyClientCert
+			}
+			s.server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, ClientCAs: pool, ClientAuth: clientAuth}
+		}
 		log.Debugf("Starting API server on %s with TLS", s.server.Addr)
 		if errServeTLS := s.server.ListenAndServeTLS(cert, key); errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
 			return fmt.Errorf("failed to start HTTPS server: %v", errServeTLS)
@@ -877,7 +948,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
-	if oldCfg == nil || oldCfg.LoggingToFile != cfg.LoggingToFile || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB {
+	if oldCfg == nil || oldCfg.LoggingToFile != cfg.LoggingToFile || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB || oldCfg.LogRetention != cfg.LogRetention {
 		if err := logging.ConfigureLogOutput(cfg); err != nil {
 			log.Errorf("failed to reconfigure log output: %v", err)
 		} else {
@@ -890,6 +961,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 				if oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB {
 					log.Debugf("logs_max_total_size_mb updated from %d to %d", oldCfg.LogsMaxTotalSizeMB, cfg.LogsMaxTotalSizeMB)
 				}
+				if oldCfg.LogRetention != cfg.LogRetention {
+					log.Debug("log_retention updated")
+				}
 			}
 		}
 	}
@@ -903,6 +977,14 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if oldCfg != nil && oldCfg.UsageStore != cfg.UsageStore {
+		if err := usage.ConfigureStore(cfg.UsageStore); err != nil {
+			log.Errorf("failed to switch usage store: %v", err)
+		} else {
+			log.Debugf("usage-store updated to %q", cfg.UsageStore.Type)
+		}
+	}
+
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 		if oldCfg != nil {
@@ -925,9 +1007,9 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
 
-	// Update log level dynamically when debug flag changes
-	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
-		util.SetLogLevel(cfg)
+	// Update log level dynamically when debug flag or logging settings change
+	if oldCfg == nil || oldCfg.Debug != cfg.Debug || !reflect.DeepEqual(oldCfg.Logging, cfg.Logging) {
+		logging.SetLogLevel(cfg)
 		if oldCfg != nil {
 			log.Debugf("debug mode updated from %t to %t", oldCfg.Debug, cfg.Debug)
 		} else {
@@ -969,6 +1051,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 
 	s.applyAccessConfig(oldCfg, cfg)
 	s.cfg = cfg
+	s.health.SetConfig(cfg.Health)
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	if oldCfg != nil && s.wsAuthChanged != nil && oldCfg.WebsocketAuth != cfg.WebsocketAuth {
 		s.wsAuthChanged(oldCfg.WebsocketAuth, cfg.WebsocketAuth)
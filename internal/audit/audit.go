@@ -0,0 +1,259 @@
+// Package audit writes one structured record per API request: who called
+// which model, the outcome and token usage, and the prompt and completion as
+// digests or full text. Records are JSON lines in a rotating file and can be
+// encrypted with AES-256-GCM.
+package audit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Content policies.
+const (
+	ContentNone = "none"
+	ContentHash = "hash"
+	ContentFull = "full"
+)
+
+const (
+	defaultPath         = "audit/audit.log"
+	defaultMaxBodyBytes = 1 << 20
+	defaultMaxSizeMB    = 100
+)
+
+// Usage holds the token counts of a request.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+// Record is one audit log entry.
+type Record struct {
+	Time          time.Time `json:"time"`
+	RequestID     string    `json:"request_id,omitempty"`
+	ShadowOf      string    `json:"shadow_of,omitempty"`
+	Client        string    `json:"client,omitempty"`
+	Tenant        string    `json:"tenant,omitempty"`
+	KeyHash       string    `json:"key_sha256,omitempty"`
+	ClientIP      string    `json:"client_ip,omitempty"`
+	Format        string    `json:"format"`
+	Model         string    `json:"model"`
+	UpstreamModel string    `json:"upstream_model,omitempty"`
+	Providers     []string  `json:"providers,omitempty"`
+	Stream        bool      `json:"stream"`
+	Status        int       `json:"status"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	LatencyMS     int64     `json:"latency_ms"`
+	Usage         Usage     `json:"usage"`
+	StopReason    string    `json:"stop_reason,omitempty"`
+
+	PromptHash          string `json:"prompt_sha256,omitempty"`
+	CompletionHash      string `json:"completion_sha256,omitempty"`
+	Prompt              string `json:"prompt,omitempty"`
+	Completion          string `json:"completion,omitempty"`
+	PromptTruncated     bool   `json:"prompt_truncated,omitempty"`
+	CompletionTruncated bool   `json:"completion_truncated,omitempty"`
+}
+
+// encryptedRecord is the line written for a record when encryption is on.
+type encryptedRecord struct {
+	Nonce string `json:"nonce"`
+	Data  string `json:"data"`
+}
+
+// Logger appends audit records to a rotating file. It is safe for concurrent use.
+type Logger struct {
+	mu           sync.Mutex
+	out          *lumberjack.Logger
+	aead         cipher.AEAD
+	content      string
+	maxBodyBytes int
+	settings     config.AuditConfig
+	path         string
+}
+
+// Open creates a logger for cfg. Relative paths are resolved against baseDir.
+func Open(cfg config.AuditConfig, baseDir string) (*Logger, error) {
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		path = defaultPath
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	content := strings.ToLower(strings.TrimSpace(cfg.Content))
+	switch content {
+	case "":
+		content = ContentHash
+	case ContentNone, ContentHash, ContentFull:
+	default:
+		return nil, fmt.Errorf("audit: unknown content policy %q", cfg.Content)
+	}
+	l := &Logger{content: content, maxBodyBytes: cfg.MaxBodyBytes, settings: cfg, path: path}
+	if l.maxBodyBytes <= 0 {
+		l.maxBodyBytes = defaultMaxBodyBytes
+	}
+	if key := strings.TrimSpace(cfg.EncryptionKey); key != "" {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		l.aead = aead
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("audit: create directory: %w", err)
+	}
+	maxSize := cfg.MaxSizeMB
+	if maxSize <= 0 {
+		maxSize = defaultMaxSizeMB
+	}
+	l.out = &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxAge:     max(cfg.MaxAgeDays, 0),
+		MaxBackups: max(cfg.MaxFiles, 0),
+		Compress:   cfg.Compress,
+	}
+	return l, nil
+}
+
+// Path returns the file the logger writes to.
+func (l *Logger) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Matches reports whether the logger was opened with cfg.
+func (l *Logger) Matches(cfg config.AuditConfig) bool {
+	return l != nil && l.settings == cfg
+}
+
+// Content returns the content policy.
+func (l *Logger) Content() string {
+	if l == nil {
+		return ContentNone
+	}
+	return l.content
+}
+
+// MaxBodyBytes returns the cap applied to prompts and completions in full mode.
+func (l *Logger) MaxBodyBytes() int {
+	if l == nil {
+		return 0
+	}
+	return l.maxBodyBytes
+}
+
+// Write appends record to the log.
+func (l *Logger) Write(record *Record) error {
+	if l == nil || record == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("audit: encode record: %w", err)
+	}
+	if l.aead != nil {
+		nonce := make([]byte, l.aead.NonceSize())
+		if _, err = rand.Read(nonce); err != nil {
+			return fmt.Errorf("audit: generate nonce: %w", err)
+		}
+		sealed := l.aead.Seal(nil, nonce, line, nil)
+		line, err = json.Marshal(encryptedRecord{
+			Nonce: base64.StdEncoding.EncodeToString(nonce),
+			Data:  base64.StdEncoding.EncodeToString(sealed),
+		})
+		if err != nil {
+			return fmt.Errorf("audit: encode record: %w", err)
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.out.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the current file.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out.Close()
+}
+
+// Decrypt returns the JSON record of an encrypted audit line.
+func Decrypt(key string, line []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	var enc encryptedRecord
+	if err = json.Unmarshal(line, &enc); err != nil {
+		return nil, fmt.Errorf("audit: decode line: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(enc.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("audit: decode nonce: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(enc.Data)
+	if err != nil {
+		return nil, fmt.Errorf("audit: decode data: %w", err)
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("audit: invalid nonce length %d", len(nonce))
+	}
+	return aead.Open(nil, nonce, data, nil)
+}
+
+// Digest returns the hex SHA-256 digest of data.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func newAEAD(key string) (cipher.AEAD, error) {
+	raw, err := misc.DecodeEncryptionKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("audit: encryption-key: %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+var defaultLogger atomic.Pointer[Logger]
+
+// SetDefault installs the logger used by the API handlers; nil disables auditing.
+func SetDefault(l *Logger) {
+	defaultLogger.Store(l)
+}
+
+// Default returns the installed logger, or nil when auditing is disabled.
+func Default() *Logger {
+	return defaultLogger.Load()
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestLoggerWritesEncryptedRecords(t *testing.T) {
+	dir := t.TempDir()
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	logger, err := Open(config.AuditConfig{Enabled: true, Path: "audit.log", EncryptionKey: key}, dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if logger.Content() != ContentHash {
+		t.Fatalf("default content policy = %q", logger.Content())
+	}
+	record := &Record{Model: "gpt-5", Status: 200, Outcome: "success", PromptHash: Digest([]byte("hi"))}
+	if err = logger.Write(record); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = logger.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	file, err := os.Open(logger.Path())
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatalf("no record written")
+	}
+	line := scanner.Bytes()
+	if strings.Contains(string(line), "gpt-5") {
+		t.Fatalf("record was written in clear text: %s", line)
+	}
+	plain, err := Decrypt(key, line)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	var got Record
+	if err = json.Unmarshal(plain, &got); err != nil || got.Model != "gpt-5" || got.PromptHash != record.PromptHash {
+		t.Fatalf("decrypted record = %s (%v)", plain, err)
+	}
+}
+
+func TestOpenRejectsInvalidSettings(t *testing.T) {
+	if _, err := Open(config.AuditConfig{Content: "everything"}, t.TempDir()); err == nil {
+		t.Fatalf("expected an error for an unknown content policy")
+	}
+	if _, err := Open(config.AuditConfig{EncryptionKey: "c2hvcnQ="}, t.TempDir()); err == nil {
+		t.Fatalf("expected an error for a short key")
+	}
+}
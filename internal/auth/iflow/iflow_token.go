@@ -1,10 +1,7 @@
 package iflow
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 )
@@ -27,18 +24,8 @@ type IFlowTokenStorage struct {
 func (ts *IFlowTokenStorage) SaveTokenToFile(authFilePath string) error {
 	misc.LogSavingCredentials(authFilePath)
 	ts.Type = "iflow"
-	if err := os.MkdirAll(filepath.Dir(authFilePath), 0o700); err != nil {
-		return fmt.Errorf("iflow token: create directory failed: %w", err)
-	}
-
-	f, err := os.Create(authFilePath)
-	if err != nil {
-		return fmt.Errorf("iflow token: create file failed: %w", err)
-	}
-	defer func() { _ = f.Close() }()
-
-	if err = json.NewEncoder(f).Encode(ts); err != nil {
-		return fmt.Errorf("iflow token: encode token failed: %w", err)
+	if err := misc.WriteCredentialFile(authFilePath, ts); err != nil {
+		return fmt.Errorf("iflow token: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,399 @@
+// Package batch runs OpenAI-style batches: a JSONL file of requests processed in
+// the background with bounded concurrency and rate, whose results are fetched
+// later.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch states, as reported by the OpenAI batch API.
+const (
+	StatusInProgress = "in_progress"
+	StatusCompleted  = "completed"
+	StatusCancelling = "cancelling"
+	StatusCancelled  = "cancelled"
+)
+
+// Caller identifies the client that created a batch. The requests of the batch
+// run on its behalf, and only the same API key can see the batch.
+type Caller struct {
+	// APIKey is the client API key, empty when client auth is disabled.
+	APIKey string
+	// ClientIP is the address the batch was created from.
+	ClientIP string
+	// Keys are the request values set by client authentication.
+	Keys map[string]any
+}
+
+// Runner executes one request of a batch against url (e.g. "/v1/chat/completions")
+// on behalf of caller and returns the HTTP status and response body.
+type Runner func(ctx context.Context, caller Caller, url string, body []byte) (int, []byte)
+
+// Limits bounds how a batch is processed.
+type Limits struct {
+	// Concurrency is the number of requests of one batch in flight at once.
+	Concurrency int
+	// RequestsPerMinute caps the request rate across all batches. <= 0 is unlimited.
+	RequestsPerMinute int
+	// MaxRequests caps the number of lines in one batch.
+	MaxRequests int
+	// Retention is how long finished batches are kept.
+	Retention time.Duration
+}
+
+// RequestCounts summarises the progress of a batch.
+type RequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Batch is the public view of a batch.
+type Batch struct {
+	ID            string            `json:"id"`
+	Object        string            `json:"object"`
+	Status        string            `json:"status"`
+	CreatedAt     int64             `json:"created_at"`
+	CompletedAt   int64             `json:"completed_at,omitempty"`
+	CancelledAt   int64             `json:"cancelled_at,omitempty"`
+	RequestCounts RequestCounts     `json:"request_counts"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// item is one request line and its outcome.
+type item struct {
+	customID string
+	url      string
+	body     json.RawMessage
+	done     bool
+	status   int
+	response json.RawMessage
+	err      string
+}
+
+type job struct {
+	Batch
+	caller   Caller
+	items    []item
+	cancel   context.CancelFunc
+	finished time.Time
+}
+
+// Manager keeps batches in memory and processes them.
+type Manager struct {
+	mu     sync.Mutex
+	jobs   map[string]*job
+	run    Runner
+	limits func() Limits
+
+	rateMu   sync.Mutex
+	nextSlot time.Time
+}
+
+// NewManager returns a manager that executes requests with run. limits is
+// consulted whenever a batch is created, so configuration reloads apply to
+// new batches.
+func NewManager(run Runner, limits func() Limits) *Manager {
+	return &Manager{jobs: make(map[string]*job), run: run, limits: limits}
+}
+
+// inputLine is one line of the batch input file.
+type inputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Create parses a JSONL input and starts processing it on behalf of caller.
+// Lines without a url use defaultURL; supported reports whether an url can be
+// executed.
+func (m *Manager) Create(caller Caller, input []byte, defaultURL string, supported func(url string) bool, metadata map[string]string) (Batch, error) {
+	limits := m.limits()
+	var items []item
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64<<10), 32<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var line inputLine
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return Batch{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if line.URL == "" {
+			line.URL = defaultURL
+		}
+		switch {
+		case line.Method != "" && !strings.EqualFold(line.Method, "POST"):
+			return Batch{}, fmt.Errorf("line %d: only POST requests are supported", lineNo)
+		case !supported(line.URL):
+			return Batch{}, fmt.Errorf("line %d: unsupported url %q", lineNo, line.URL)
+		case len(line.Body) == 0 || line.Body[0] != '{':
+			return Batch{}, fmt.Errorf("line %d: body must be a JSON object", lineNo)
+		}
+		if line.CustomID == "" {
+			line.CustomID = fmt.Sprintf("request-%d", len(items)+1)
+		}
+		if seen[line.CustomID] {
+			return Batch{}, fmt.Errorf("line %d: duplicate custom_id %q", lineNo, line.CustomID)
+		}
+		seen[line.CustomID] = true
+		items = append(items, item{customID: line.CustomID, url: line.URL, body: line.Body})
+		if limits.MaxRequests > 0 && len(items) > limits.MaxRequests {
+			return Batch{}, fmt.Errorf("batch exceeds %d requests", limits.MaxRequests)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Batch{}, fmt.Errorf("read input: %w", err)
+	}
+	if len(items) == 0 {
+		return Batch{}, fmt.Errorf("batch input is empty")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Batch: Batch{
+			ID:            "batch_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			Object:        "batch",
+			Status:        StatusInProgress,
+			CreatedAt:     time.Now().Unix(),
+			RequestCounts: RequestCounts{Total: len(items)},
+			Metadata:      metadata,
+		},
+		caller: caller,
+		items:  items,
+		cancel: cancel,
+	}
+	m.mu.Lock()
+	m.purgeLocked(limits.Retention)
+	m.jobs[j.ID] = j
+	view := j.Batch
+	m.mu.Unlock()
+	go m.process(ctx, j, limits)
+	return view, nil
+}
+
+func (m *Manager) process(ctx context.Context, j *job, limits Limits) {
+	workers := limits.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	// Cancelling ctx only stops dispatch; requests already handed to a worker
+	// run to completion.
+	runCtx := context.WithoutCancel(ctx)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				m.runItem(runCtx, j, i)
+			}
+		}()
+	}
+feed:
+	for i := range j.items {
+		if !m.waitSlot(ctx, limits.RequestsPerMinute) {
+			break
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	j.finished = now
+	if j.Status == StatusCancelling {
+		j.Status = StatusCancelled
+		j.CancelledAt = now.Unix()
+		return
+	}
+	j.Status = StatusCompleted
+	j.CompletedAt = now.Unix()
+}
+
+func (m *Manager) runItem(ctx context.Context, j *job, i int) {
+	m.mu.Lock()
+	url, body := j.items[i].url, j.items[i].body
+	m.mu.Unlock()
+	status, response := m.run(ctx, j.caller, url, body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it := &j.items[i]
+	it.done = true
+	it.status = status
+	if json.Valid(response) {
+		it.response = response
+	} else {
+		it.response, _ = json.Marshal(string(response))
+	}
+	if status >= 200 && status < 300 {
+		j.RequestCounts.Completed++
+		return
+	}
+	j.RequestCounts.Failed++
+	it.err = fmt.Sprintf("request failed with status %d", status)
+}
+
+// waitSlot blocks until the shared rate limit admits another request.
+func (m *Manager) waitSlot(ctx context.Context, perMinute int) bool {
+	if perMinute <= 0 {
+		return ctx.Err() == nil
+	}
+	interval := time.Minute / time.Duration(perMinute)
+	m.rateMu.Lock()
+	now := time.Now()
+	if m.nextSlot.Before(now) {
+		m.nextSlot = now
+	}
+	wait := m.nextSlot.Sub(now)
+	m.nextSlot = m.nextSlot.Add(interval)
+	m.rateMu.Unlock()
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// purgeLocked drops finished batches older than retention.
+func (m *Manager) purgeLocked(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	for id, j := range m.jobs {
+		if !j.finished.IsZero() && time.Since(j.finished) > retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// jobLocked returns the batch id when it was created with apiKey.
+func (m *Manager) jobLocked(apiKey, id string) (*job, bool) {
+	j, ok := m.jobs[id]
+	if !ok || j.caller.APIKey != apiKey {
+		return nil, false
+	}
+	return j, true
+}
+
+// Get returns a batch created with apiKey by ID.
+func (m *Manager) Get(apiKey, id string) (Batch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobLocked(apiKey, id)
+	if !ok {
+		return Batch{}, false
+	}
+	return j.Batch, true
+}
+
+// List returns the batches created with apiKey, newest first.
+func (m *Manager) List(apiKey string) []Batch {
+	m.mu.Lock()
+	m.purgeLocked(m.limits().Retention)
+	out := make([]Batch, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if j.caller.APIKey == apiKey {
+			out = append(out, j.Batch)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].CreatedAt != out[k].CreatedAt {
+			return out[i].CreatedAt > out[k].CreatedAt
+		}
+		return out[i].ID > out[k].ID
+	})
+	return out
+}
+
+// Cancel stops a batch created with apiKey. Requests already in flight finish;
+// the rest are skipped.
+func (m *Manager) Cancel(apiKey, id string) (Batch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobLocked(apiKey, id)
+	if !ok {
+		return Batch{}, false
+	}
+	if j.Status == StatusInProgress {
+		j.Status = StatusCancelling
+		j.cancel()
+	}
+	return j.Batch, true
+}
+
+// outputLine is one line of the batch output file.
+type outputLine struct {
+	ID       string          `json:"id"`
+	CustomID string          `json:"custom_id"`
+	Response *outputResponse `json:"response"`
+	Error    *outputError    `json:"error"`
+}
+
+type outputResponse struct {
+	StatusCode int             `json:"status_code"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type outputError struct {
+	Message string `json:"message"`
+}
+
+// Output returns the results of the finished requests of a batch created with
+// apiKey as JSONL, in input order.
+func (m *Manager) Output(apiKey, id string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobLocked(apiKey, id)
+	if !ok {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	for i, it := range j.items {
+		if !it.done {
+			continue
+		}
+		line := outputLine{
+			ID:       fmt.Sprintf("%s_req_%d", j.ID, i+1),
+			CustomID: it.customID,
+			Response: &outputResponse{StatusCode: it.status, Body: it.response},
+		}
+		if it.err != "" {
+			line.Error = &outputError{Message: it.err}
+		}
+		encoded, _ := json.Marshal(line)
+		buf.Write(encoded)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), true
+}
@@ -0,0 +1,132 @@
+package batch
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func waitFor(t *testing.T, m *Manager, id, status string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if b, _ := m.Get("", id); b.Status == status {
+			return b
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	b, _ := m.Get("", id)
+	t.Fatalf("batch status = %q, want %q", b.Status, status)
+	return b
+}
+
+func TestBatchRunsRequestsAndReportsResults(t *testing.T) {
+	run := func(_ context.Context, _ Caller, url string, body []byte) (int, []byte) {
+		if gjson.GetBytes(body, "fail").Bool() {
+			return 429, []byte(`{"error":{"message":"slow down"}}`)
+		}
+		return 200, []byte(`{"url":"` + url + `"}`)
+	}
+	m := NewManager(run, func() Limits { return Limits{Concurrency: 2} })
+	input := `{"custom_id":"a","method":"POST","url":"/v1/messages","body":{"model":"m"}}
+{"custom_id":"b","body":{"model":"m","fail":true}}
+
+{"custom_id":"c","body":{"model":"m"}}`
+	supported := func(url string) bool { return url == "/v1/messages" || url == "/v1/chat/completions" }
+	created, err := m.Create(Caller{}, []byte(input), "/v1/chat/completions", supported, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	done := waitFor(t, m, created.ID, StatusCompleted)
+	if done.RequestCounts != (RequestCounts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Fatalf("counts = %+v", done.RequestCounts)
+	}
+	output, _ := m.Output("", created.ID)
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("output = %s", output)
+	}
+	if gjson.Get(lines[0], "response.body.url").String() != "/v1/messages" || gjson.Get(lines[2], "response.body.url").String() != "/v1/chat/completions" {
+		t.Fatalf("output = %s", output)
+	}
+	if gjson.Get(lines[1], "custom_id").String() != "b" || gjson.Get(lines[1], "response.status_code").Int() != 429 || !gjson.Get(lines[1], "error.message").Exists() {
+		t.Fatalf("failed line = %s", lines[1])
+	}
+
+	if _, err = m.Create(Caller{}, []byte(`{"custom_id":"x","url":"/v1/files","body":{}}`), "", supported, nil); err == nil {
+		t.Fatalf("unsupported url accepted")
+	}
+	if _, err = m.Create(Caller{}, []byte(`{"custom_id":"x","body":{}}`+"\n"+`{"custom_id":"x","body":{}}`), "/v1/messages", supported, nil); err == nil {
+		t.Fatalf("duplicate custom_id accepted")
+	}
+}
+
+func TestBatchCancelSkipsPendingRequests(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 5)
+	run := func(ctx context.Context, _ Caller, _ string, _ []byte) (int, []byte) {
+		started <- struct{}{}
+		<-release
+		if ctx.Err() != nil {
+			return 499, []byte(`{}`)
+		}
+		return 200, []byte(`{}`)
+	}
+	m := NewManager(run, func() Limits { return Limits{Concurrency: 1} })
+	input := strings.Repeat(`{"body":{"model":"m"}}`+"\n", 5)
+	created, err := m.Create(Caller{}, []byte(input), "/v1/chat/completions", func(string) bool { return true }, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	<-started
+	if b, _ := m.Cancel("", created.ID); b.Status != StatusCancelling {
+		t.Fatalf("status after cancel = %q", b.Status)
+	}
+	close(release)
+	done := waitFor(t, m, created.ID, StatusCancelled)
+	if done.RequestCounts.Completed >= 5 {
+		t.Fatalf("cancelled batch ran every request: %+v", done.RequestCounts)
+	}
+	if done.RequestCounts.Completed == 0 || done.RequestCounts.Failed != 0 {
+		t.Fatalf("in-flight request did not finish after cancel: %+v", done.RequestCounts)
+	}
+}
+
+func TestBatchIsScopedToItsCaller(t *testing.T) {
+	ran := make(chan Caller, 1)
+	run := func(_ context.Context, caller Caller, _ string, _ []byte) (int, []byte) {
+		ran <- caller
+		return 200, []byte(`{}`)
+	}
+	m := NewManager(run, func() Limits { return Limits{Concurrency: 1} })
+	owner := Caller{APIKey: "key-a", ClientIP: "10.0.0.1"}
+	created, err := m.Create(owner, []byte(`{"body":{"model":"m"}}`), "/v1/chat/completions", func(string) bool { return true }, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := <-ran; got.APIKey != owner.APIKey || got.ClientIP != owner.ClientIP {
+		t.Fatalf("runner caller = %+v", got)
+	}
+
+	if _, ok := m.Get("key-b", created.ID); ok {
+		t.Fatal("another key can read the batch")
+	}
+	if _, ok := m.Output("key-b", created.ID); ok {
+		t.Fatal("another key can read the batch output")
+	}
+	if _, ok := m.Cancel("key-b", created.ID); ok {
+		t.Fatal("another key can cancel the batch")
+	}
+	if list := m.List("key-b"); len(list) != 0 {
+		t.Fatalf("another key lists %v", list)
+	}
+	if list := m.List("key-a"); len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("owner lists %v", list)
+	}
+	if _, ok := m.Get("key-a", created.ID); !ok {
+		t.Fatal("owner cannot read the batch")
+	}
+}
@@ -0,0 +1,217 @@
+// Package circuit implements a keyed circuit breaker. Each key (an upstream
+// account, a fallback backend, ...) moves from closed to open after a number of
+// consecutive failures, rejects requests while open, and admits a single probe
+// request once its cooldown has elapsed. The probe's outcome closes or reopens
+// the circuit.
+package circuit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the breaker state of one key.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+// String returns the lower-case state name used in logs and APIs.
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type circuit struct {
+	state    State
+	failures int
+	trips    int64
+	rejected int64
+	openedAt time.Time
+	probing  bool
+}
+
+// Snapshot describes the state of one key.
+type Snapshot struct {
+	Key                 string     `json:"key"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Trips               int64      `json:"trips"`
+	Rejected            int64      `json:"rejected"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	ProbeInFlight       bool       `json:"probe_in_flight,omitempty"`
+}
+
+// Breaker tracks circuits by key. The zero value is not usable; call New.
+type Breaker struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time
+}
+
+// New returns a breaker using the wall clock.
+func New() *Breaker {
+	return &Breaker{circuits: make(map[string]*circuit), now: time.Now}
+}
+
+// SetClock replaces the time source.
+func (b *Breaker) SetClock(now func() time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.now = now
+}
+
+func (b *Breaker) circuit(key string) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+// Ready reports, without changing state, whether Allow would admit a request for key.
+func (b *Breaker) Ready(key string, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		return true
+	}
+	switch c.state {
+	case Open:
+		return b.now().Sub(c.openedAt) >= cooldown
+	case HalfOpen:
+		return !c.probing
+	default:
+		return true
+	}
+}
+
+// Allow reports whether a request may be sent for key. Once the cooldown of an
+// open circuit has elapsed a single probe is admitted; probe is true for it.
+// Rejected requests are counted.
+func (b *Breaker) Allow(key string, cooldown time.Duration) (allowed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	switch c.state {
+	case Open:
+		if b.now().Sub(c.openedAt) < cooldown {
+			c.rejected++
+			return false, false
+		}
+		c.state = HalfOpen
+		c.probing = true
+		return true, true
+	case HalfOpen:
+		if c.probing {
+			c.rejected++
+			return false, false
+		}
+		c.probing = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// Reject counts a request turned away because the circuit for key is not ready.
+func (b *Breaker) Reject(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.circuit(key).rejected++
+}
+
+// Success closes the circuit for key and reports whether it was not closed before.
+func (b *Breaker) Success(key string) (recovered bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok {
+		return false
+	}
+	recovered = c.state != Closed
+	c.state, c.failures, c.probing, c.openedAt = Closed, 0, false, time.Time{}
+	return recovered
+}
+
+// Failure records a failed request and opens the circuit once threshold
+// consecutive failures are reached. A failed probe reopens it immediately.
+func (b *Breaker) Failure(key string, threshold int) (opened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(key)
+	c.failures++
+	c.probing = false
+	if c.state == HalfOpen || (c.state == Closed && c.failures >= threshold) {
+		c.state = Open
+		c.openedAt = b.now()
+		c.trips++
+		return true
+	}
+	return false
+}
+
+// Release ends an in-flight probe that produced no verdict (for example a
+// client error), letting the next request probe instead.
+func (b *Breaker) Release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		c.probing = false
+	}
+}
+
+// Reset forgets key, closing its circuit and clearing its counters.
+func (b *Breaker) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, key)
+}
+
+// RetryAt returns when an open circuit for key admits its probe.
+func (b *Breaker) RetryAt(key string, cooldown time.Duration) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[key]
+	if !ok || c.state != Open {
+		return time.Time{}, false
+	}
+	return c.openedAt.Add(cooldown), true
+}
+
+// Snapshots returns the state of every tracked key, sorted by key.
+func (b *Breaker) Snapshots() []Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Snapshot, 0, len(b.circuits))
+	for key, c := range b.circuits {
+		var openedAt *time.Time
+		if !c.openedAt.IsZero() {
+			opened := c.openedAt
+			openedAt = &opened
+		}
+		out = append(out, Snapshot{
+			Key:                 key,
+			State:               c.state.String(),
+			ConsecutiveFailures: c.failures,
+			Trips:               c.trips,
+			Rejected:            c.rejected,
+			OpenedAt:            openedAt,
+			ProbeInFlight:       c.probing,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
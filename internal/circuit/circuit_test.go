@@ -0,0 +1,49 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpenProbeAndRecover(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := New()
+	b.SetClock(func() time.Time { return now })
+
+	for i := 0; i < 2; i++ {
+		if opened := b.Failure("m", 2); opened != (i == 1) {
+			t.Fatalf("failure %d: opened = %v", i, opened)
+		}
+	}
+	if b.Ready("m", time.Minute) {
+		t.Fatal("open circuit reported ready")
+	}
+	if allowed, _ := b.Allow("m", time.Minute); allowed {
+		t.Fatal("open circuit admitted a request")
+	}
+
+	now = now.Add(time.Minute)
+	if allowed, probe := b.Allow("m", time.Minute); !allowed || !probe {
+		t.Fatalf("expected a probe after the cooldown, got allowed=%v probe=%v", allowed, probe)
+	}
+	if allowed, _ := b.Allow("m", time.Minute); allowed {
+		t.Fatal("second request admitted while a probe is in flight")
+	}
+	if !b.Failure("m", 2) {
+		t.Fatal("failed probe did not reopen the circuit")
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow("m", time.Minute)
+	if !b.Success("m") {
+		t.Fatal("successful probe did not report recovery")
+	}
+	if allowed, probe := b.Allow("m", time.Minute); !allowed || probe {
+		t.Fatalf("circuit not closed after recovery: allowed=%v probe=%v", allowed, probe)
+	}
+
+	snaps := b.Snapshots()
+	if len(snaps) != 1 || snaps[0].State != "closed" || snaps[0].Trips != 2 || snaps[0].Rejected != 2 {
+		t.Fatalf("unexpected snapshot %+v", snaps)
+	}
+}
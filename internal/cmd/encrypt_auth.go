@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// DoEncryptAuthFiles encrypts the plaintext auth files in the auth directory
+// with the key from CLIPROXY_AUTH_ENCRYPTION_KEY. Files that are already
+// encrypted are left alone, so the command can be run repeatedly.
+func DoEncryptAuthFiles(cfg *config.Config) {
+	if !misc.CredentialEncryptionEnabled() {
+		log.Errorf("encrypt-auth: %s is not set", misc.CredentialKeyEnv)
+		return
+	}
+	authDir, errResolve := util.ResolveAuthDir(cfg.AuthDir)
+	if errResolve != nil {
+		log.Errorf("encrypt-auth: resolve auth directory failed: %v", errResolve)
+		return
+	}
+	encrypted, skipped, failed := 0, 0, 0
+	errWalk := filepath.WalkDir(authDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".json") {
+			return nil
+		}
+		done, errEncrypt := encryptAuthFile(path)
+		switch {
+		case errEncrypt != nil:
+			failed++
+			log.Errorf("encrypt-auth: %s: %v", d.Name(), errEncrypt)
+		case done:
+			encrypted++
+		default:
+			skipped++
+		}
+		return nil
+	})
+	if errWalk != nil {
+		log.Errorf("encrypt-auth: walk auth directory failed: %v", errWalk)
+		return
+	}
+	fmt.Printf("Encrypted %d auth file(s), %d already encrypted or empty, %d failed\n", encrypted, skipped, failed)
+}
+
+// encryptAuthFile rewrites one plaintext auth file encrypted. The new content
+// is written next to the file and renamed over it, so an interrupted run never
+// leaves a partial file behind.
+func encryptAuthFile(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if len(data) == 0 || misc.IsEncryptedCredentials(data) {
+		return false, nil
+	}
+	sealed, err := misc.EncryptCredentials(data)
+	if err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return false, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
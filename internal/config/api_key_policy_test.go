@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfigReadsAPIKeysFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	content := `api-keys:
+  - "plain"
+api-key-policies:
+  - api-key: "team-a"
+    models: ["claude-*"]
+api-keys-file: "keys.yaml"
+`
+	keys := `- api-key: "team-b"
+  name: "Team B"
+  requests-per-minute: 30
+- api-key: ""
+`
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "keys.yaml"), []byte(keys), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.ClientAPIKeys(); !slices.Equal(got, []string{"plain", "team-a", "team-b"}) {
+		t.Fatalf("ClientAPIKeys() = %v", got)
+	}
+	if policy := cfg.KeyPolicy("team-b"); policy == nil || policy.RequestsPerMinute != 30 || !policy.AllowsModel("gpt-5") {
+		t.Fatalf("KeyPolicy(team-b) = %+v", policy)
+	}
+	if policy := cfg.KeyPolicy("team-a"); policy.AllowsModel("gpt-5") || !policy.AllowsModel("claude-opus-4-1") {
+		t.Fatalf("team-a allowlist not applied: %+v", policy)
+	}
+}
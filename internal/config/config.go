@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -51,9 +52,20 @@ type Config struct {
 	// When exceeded, the oldest log files are deleted until within the limit. Set to 0 to disable.
 	LogsMaxTotalSizeMB int `yaml:"logs-max-total-size-mb" json:"logs-max-total-size-mb"`
 
+	// LogRetention configures time-based rotation, compression and per-artifact retention
+	// for files under the logs directory.
+	LogRetention LogRetentionConfig `yaml:"log-retention" json:"log-retention"`
+
+	// Logging selects the application log format and per-module level overrides.
+	Logging LoggingConfig `yaml:"logging,omitempty" json:"logging,omitempty"`
+
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStore selects where daily usage per API key and model is kept for
+	// billing reconciliation.
+	UsageStore UsageStoreConfig `yaml:"usage-store,omitempty" json:"usage-store,omitempty"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -68,6 +80,27 @@ type Config struct {
 	// Routing controls credential selection behavior.
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 
+	// CircuitBreaker stops routing to accounts whose upstream keeps failing.
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit-breaker,omitempty" json:"circuit-breaker,omitempty"`
+
+	// SharedState configures optional state shared between proxy replicas.
+	// Changes require a restart.
+	SharedState SharedStateConfig `yaml:"shared-state,omitempty" json:"shared-state,omitempty"`
+
+	// ConversationStore persists conversation state across restarts.
+	// Changes require a restart.
+	ConversationStore ConversationStoreConfig `yaml:"conversation-store,omitempty" json:"conversation-store,omitempty"`
+
+	// Audit writes a structured record of every API request for compliance.
+	Audit AuditConfig `yaml:"audit,omitempty" json:"audit,omitempty"`
+
+	// Health configures the /readyz readiness probe.
+	Health HealthConfig `yaml:"health,omitempty" json:"health,omitempty"`
+
+	// ModelManifest configures periodic sync of additional model definitions from a
+	// signed remote manifest. Changes require a restart.
+	ModelManifest ModelManifestConfig `yaml:"model-manifest,omitempty" json:"model-manifest,omitempty"`
+
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
@@ -120,6 +153,58 @@ type TLSConfig struct {
 	Cert string `yaml:"cert" json:"cert"`
 	// Key is the path to the TLS private key file.
 	Key string `yaml:"key" json:"key"`
+	// ClientCA is the path to a PEM bundle used to verify client certificates.
+	// Setting it enables mutual TLS.
+	ClientCA string `yaml:"client-ca,omitempty" json:"client-ca,omitempty"`
+	// RequireClientCert rejects connections without a valid client certificate.
+	// When false, a client certificate is verified only if presented.
+	RequireClientCert bool `yaml:"require-client-cert,omitempty" json:"require-client-cert,omitempty"`
+}
+
+// LogRetentionConfig controls rotation and retention of files under the logs directory.
+type LogRetentionConfig struct {
+	// RotateIntervalHours rotates main.log at this interval in addition to the 10 MB size limit.
+	// 0 disables time-based rotation.
+	RotateIntervalHours int `yaml:"rotate-interval-hours" json:"rotate-interval-hours"`
+	// Compress gzips rotated main.log segments and request logs older than one hour.
+	Compress bool `yaml:"compress" json:"compress"`
+	// HighWaterMarkPercent logs a warning when the logs directory exceeds this percentage of
+	// logs-max-total-size-mb. 0 disables the alert.
+	HighWaterMarkPercent int `yaml:"high-water-mark-percent" json:"high-water-mark-percent"`
+	// Main applies to rotated main.log segments.
+	Main LogRetentionPolicy `yaml:"main" json:"main"`
+	// Request applies to per-request log files written when request-log is enabled.
+	Request LogRetentionPolicy `yaml:"request" json:"request"`
+	// Error applies to forced error-*.log request logs.
+	Error LogRetentionPolicy `yaml:"error" json:"error"`
+}
+
+// LogRetentionPolicy limits how long and how many files of one artifact type are kept.
+// Zero values disable the corresponding limit.
+type LogRetentionPolicy struct {
+	// MaxAgeHours deletes files older than this many hours.
+	MaxAgeHours int `yaml:"max-age-hours" json:"max-age-hours"`
+	// MaxFiles keeps at most this many files, deleting the oldest first.
+	MaxFiles int `yaml:"max-files" json:"max-files"`
+}
+
+// UsageStoreConfig configures the usage store.
+type UsageStoreConfig struct {
+	// Type is "memory" (default; lost on restart) or "file".
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// Path is the file of the "file" store, created when missing.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// LoggingConfig configures how application log entries are rendered and filtered.
+type LoggingConfig struct {
+	// JSON writes one JSON object per entry instead of the bracketed text format.
+	JSON bool `yaml:"json" json:"json"`
+	// Levels overrides the log level for individual packages, keyed by package path
+	// relative to the module root (e.g. "sdk/cliproxy/auth") or by its last element
+	// (e.g. "executor"). Values are logrus level names such as "debug" or "warn".
+	Levels map[string]string `yaml:"levels,omitempty" json:"levels,omitempty"`
 }
 
 // RemoteManagement holds management API configuration under 'remote-management'.
@@ -152,6 +237,121 @@ type RoutingConfig struct {
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
 }
 
+// CircuitBreakerConfig configures the per-account circuit breaker.
+type CircuitBreakerConfig struct {
+	// Enabled turns the breaker on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// FailureThreshold is the number of consecutive 5xx or timeout failures that
+	// opens an account's circuit. Defaults to 5.
+	FailureThreshold int `yaml:"failure-threshold,omitempty" json:"failure-threshold,omitempty"`
+
+	// CooldownSeconds is how long an open circuit rejects requests before a
+	// single probe request is allowed. Defaults to 30.
+	CooldownSeconds int `yaml:"cooldown-seconds,omitempty" json:"cooldown-seconds,omitempty"`
+}
+
+// HealthConfig configures the readiness probe.
+type HealthConfig struct {
+	// PingURLs maps provider keys (e.g. "claude") to an upstream URL requested with
+	// one of the provider's ready credentials. Readiness then also requires at least
+	// one provider to answer its ping.
+	PingURLs map[string]string `yaml:"ping-urls,omitempty" json:"ping-urls,omitempty"`
+
+	// PingIntervalSeconds is how long ping results are reused. Defaults to 60.
+	PingIntervalSeconds int `yaml:"ping-interval-seconds,omitempty" json:"ping-interval-seconds,omitempty"`
+
+	// IncludeIdentities adds auth IDs and labels to the per-account report.
+	IncludeIdentities bool `yaml:"include-identities,omitempty" json:"include-identities,omitempty"`
+}
+
+// ModelManifestConfig configures the remote model manifest sync.
+type ModelManifestConfig struct {
+	// URL is the manifest location. Sync is disabled when empty.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// PublicKey is the base64-encoded Ed25519 key the manifest must be signed with.
+	PublicKey string `yaml:"public-key,omitempty" json:"public-key,omitempty"`
+
+	// RefreshSeconds is the revalidation interval. Defaults to 3600.
+	RefreshSeconds int `yaml:"refresh-seconds,omitempty" json:"refresh-seconds,omitempty"`
+
+	// RequireApproval holds newly fetched manifests until they are approved
+	// through the management API.
+	RequireApproval bool `yaml:"require-approval,omitempty" json:"require-approval,omitempty"`
+}
+
+// SharedStateConfig configures the backend used to coordinate multiple replicas
+// running behind a load balancer.
+type SharedStateConfig struct {
+	// Redis enables the Redis backend when Addr is set.
+	Redis RedisConfig `yaml:"redis,omitempty" json:"redis,omitempty"`
+}
+
+// ConversationStoreConfig configures the on-disk conversation store.
+type ConversationStoreConfig struct {
+	// Path is the store file. Relative paths are resolved against the config
+	// file directory. Empty disables persistence.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// RetentionHours drops entries not updated for this many hours.
+	// <= 0 keeps them until they are overwritten. Default is 0.
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+}
+
+// AuditConfig configures the audit log.
+type AuditConfig struct {
+	// Enabled turns on audit records.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Path is the audit log file. Relative paths are resolved against the
+	// config file directory. Defaults to "audit/audit.log".
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Content selects what is kept of prompts and completions: "none",
+	// "hash" (SHA-256 digests, the default) or "full".
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
+
+	// MaxBodyBytes caps each prompt and completion stored in "full" mode.
+	// Defaults to 1 MiB.
+	MaxBodyBytes int `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+
+	// EncryptionKey is a base64-encoded 32-byte key. When set, every record
+	// is encrypted with AES-256-GCM.
+	EncryptionKey string `yaml:"encryption-key,omitempty" json:"encryption-key,omitempty"`
+
+	// MaxSizeMB rotates the file at this size. Defaults to 100.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// MaxAgeDays deletes rotated files older than this many days. 0 keeps them.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// MaxFiles keeps at most this many rotated files. 0 keeps them all.
+	MaxFiles int `yaml:"max-files,omitempty" json:"max-files,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+}
+
+// RedisConfig holds Redis connection settings.
+type RedisConfig struct {
+	// Addr is the host:port of the Redis server. Empty disables shared state.
+	Addr string `yaml:"addr,omitempty" json:"addr,omitempty"`
+
+	// Username and Password authenticate against Redis ACLs when set.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// DB selects the Redis logical database.
+	DB int `yaml:"db,omitempty" json:"db,omitempty"`
+
+	// TLS enables TLS for the connection.
+	TLS bool `yaml:"tls,omitempty" json:"tls,omitempty"`
+
+	// KeyPrefix namespaces all keys and channels. Defaults to "cliproxy:".
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
+}
+
 // OAuthModelAlias defines a model ID alias for a specific channel.
 // It maps the upstream model name (Name) to the client-visible alias (Alias).
 // When Fork is true, the alias is added as an additional model in listings while
@@ -428,6 +628,11 @@ type OpenAICompatibility struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent to this provider.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ToolManifestDelta sends, after the first turn of a conversation, only the
+	// tools that changed since the upstream last accepted the tool list. The
+	// upstream must be another instance of this proxy.
+	ToolManifestDelta bool `yaml:"tool-manifest-delta,omitempty" json:"tool-manifest-delta,omitempty"`
 }
 
 // OpenAICompatibilityAPIKey represents an API key configuration with optional proxy setting.
@@ -506,16 +711,29 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	cfg.DisableCooling = false
 	cfg.AmpCode.RestrictManagementToLocalhost = false // Default to false: API key auth is sufficient
 	cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
-	if err = yaml.Unmarshal(data, &cfg); err != nil {
+	var root yaml.Node
+	var fromEnv map[string]bool
+	if err = yaml.Unmarshal(data, &root); err == nil && root.Kind != 0 {
+		// Interpolate ${VAR} references before decoding into the typed config.
+		if fromEnv, err = expandEnvNodes(&root, configFile); err == nil {
+			err = root.Decode(&cfg)
+		}
+	}
+	if err != nil {
 		if optional {
 			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
 			return &Config{}, nil
 		}
+		var validation *ValidationError
+		if errors.As(err, &validation) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	warnUnknownKeys(configFile, data)
 
 	var legacy legacyConfigData
-	if errLegacy := yaml.Unmarshal(data, &legacy); errLegacy == nil {
+	if errLegacy := root.Decode(&legacy); root.Kind != 0 && errLegacy == nil {
 		if cfg.migrateLegacyGeminiKeys(legacy.LegacyGeminiKeys) {
 			cfg.legacyMigrationPending = true
 		}
@@ -537,8 +755,11 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		cfg.RemoteManagement.SecretKey = hashed
 
 		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
-		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		// Preserve YAML comments and ordering; update only the nested key. A key taken
+		// from the environment stays there.
+		if !fromEnv["remote-management.secret-key"] {
+			_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		}
 	}
 
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
@@ -549,10 +770,16 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	if cfg.LogsMaxTotalSizeMB < 0 {
 		cfg.LogsMaxTotalSizeMB = 0
 	}
+	cfg.SanitizeLogRetention()
 
 	// Sync request authentication providers with inline API keys for backwards compatibility.
 	syncInlineAccessProvider(&cfg)
 
+	// Load client key policies kept outside the config file.
+	if err = cfg.loadAPIKeysFile(configFile); err != nil {
+		return nil, err
+	}
+
 	// Sanitize Gemini API key configuration and migrate legacy entries.
 	cfg.SanitizeGeminiKeys()
 
@@ -577,6 +804,14 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	// Reject values that cannot work, pointing at their line in the file.
+	if errs := cfg.Validate(); len(errs) > 0 {
+		if optional {
+			return &Config{}, nil
+		}
+		return nil, &ValidationError{Errors: locateFields(errs, configFile, &root)}
+	}
+
 	if cfg.legacyMigrationPending {
 		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
 		if !optional && configFile != "" {
@@ -593,6 +828,25 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	return &cfg, nil
 }
 
+// SanitizeLogRetention clamps negative log retention values to zero (disabled).
+func (cfg *Config) SanitizeLogRetention() {
+	if cfg == nil {
+		return
+	}
+	r := &cfg.LogRetention
+	clamp := func(v *int) {
+		if *v < 0 {
+			*v = 0
+		}
+	}
+	clamp(&r.RotateIntervalHours)
+	clamp(&r.HighWaterMarkPercent)
+	for _, policy := range []*LogRetentionPolicy{&r.Main, &r.Request, &r.Error} {
+		clamp(&policy.MaxAgeHours)
+		clamp(&policy.MaxFiles)
+	}
+}
+
 // SanitizePayloadRules validates raw JSON payload rule params and drops invalid rules.
 func (cfg *Config) SanitizePayloadRules() {
 	if cfg == nil {
@@ -795,6 +1049,34 @@ func syncInlineAccessProvider(cfg *Config) {
 	cfg.Access.Providers = nil
 }
 
+// loadAPIKeysFile reads the api-keys-file policies, resolving a relative path
+// against the directory of configFile.
+func (cfg *Config) loadAPIKeysFile(configFile string) error {
+	path := strings.TrimSpace(cfg.APIKeysFile)
+	if path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(path) && configFile != "" {
+		path = filepath.Join(filepath.Dir(configFile), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read api-keys-file: %w", err)
+	}
+	var policies []APIKeyPolicy
+	if err = yaml.Unmarshal(data, &policies); err != nil {
+		return fmt.Errorf("failed to parse api-keys-file %s: %w", path, err)
+	}
+	cfg.fileAPIKeyPolicies = policies[:0]
+	for _, policy := range policies {
+		policy.APIKey = strings.TrimSpace(policy.APIKey)
+		if policy.APIKey != "" {
+			cfg.fileAPIKeyPolicies = append(cfg.fileAPIKeyPolicies, policy)
+		}
+	}
+	return nil
+}
+
 // looksLikeBcrypt returns true if the provided string appears to be a bcrypt hash.
 func looksLikeBcrypt(s string) bool {
 	return len(s) > 4 && (s[:4] == "$2a$" || s[:4] == "$2b$" || s[:4] == "$2y$")
@@ -925,7 +1207,9 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 	pruneMappingToGeneratedKeys(original.Content[0], generated.Content[0], "oauth-excluded-models")
 
 	// Merge generated into original in-place, preserving comments/order of existing nodes.
+	templates := expandEnvTemplates(&original)
 	mergeMappingPreserve(original.Content[0], generated.Content[0])
+	restoreEnvTemplates(templates)
 	normalizeCollectionNodeStyles(original.Content[0])
 
 	// Write back.
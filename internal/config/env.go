@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// envReference matches ${VAR}, ${VAR:-default} and the escape $${.
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnvValue replaces ${VAR} with the value of the environment variable
+// VAR and ${VAR:-default} with default when VAR is unset or empty. $${ stands
+// for a literal ${. It fails when a variable without default is unset.
+func expandEnvValue(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var missing []string
+	out := envReference.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		sub := envReference.FindStringSubmatch(match)
+		env, set := os.LookupEnv(sub[1])
+		switch {
+		case sub[2] != "" && env == "":
+			return sub[3]
+		case !set:
+			missing = append(missing, sub[1])
+		}
+		return env
+	})
+	if len(missing) > 0 {
+		return value, fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// expandEnvNodes interpolates environment variables into the scalar values of
+// a parsed config document. Keys are left alone. It returns the paths of the
+// values that changed, or the unset variables with their location.
+func expandEnvNodes(root *yaml.Node, file string) (map[string]bool, error) {
+	expanded := make(map[string]bool)
+	var errs []FieldError
+	walkNodes(root, "", func(path string, _, node *yaml.Node) {
+		if node.Kind != yaml.ScalarNode || !strings.Contains(node.Value, "${") {
+			return
+		}
+		value, err := expandEnvValue(node.Value)
+		if err != nil {
+			errs = append(errs, FieldError{File: file, Line: node.Line, Field: path, Message: err.Error()})
+			return
+		}
+		if value == node.Value {
+			return
+		}
+		node.Value = value
+		if node.Style == 0 {
+			// Let plain values resolve again, so "${PORT}" decodes into an int.
+			node.Tag = ""
+		}
+		expanded[path] = true
+	})
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return expanded, nil
+}
+
+// expandEnvTemplates expands the scalar nodes of a document that reference
+// environment variables and returns them with their raw text, so a merge
+// matches them against the values the config was loaded with.
+func expandEnvTemplates(root *yaml.Node) map[*yaml.Node]string {
+	templates := make(map[*yaml.Node]string)
+	walkNodes(root, "", func(_ string, _, node *yaml.Node) {
+		if node.Kind != yaml.ScalarNode || !strings.Contains(node.Value, "${") {
+			return
+		}
+		if value, err := expandEnvValue(node.Value); err == nil {
+			templates[node] = node.Value
+			node.Value = value
+		}
+	})
+	return templates
+}
+
+// restoreEnvTemplates puts the raw text back into template nodes whose value
+// still equals its expansion, so saving the config keeps ${VAR} references
+// instead of writing the secrets they resolve to.
+func restoreEnvTemplates(templates map[*yaml.Node]string) {
+	for node, raw := range templates {
+		if value, err := expandEnvValue(raw); err == nil && node.Kind == yaml.ScalarNode && node.Value == value {
+			node.Value = raw
+			node.Tag = "!!str"
+		}
+	}
+}
+
+// unknownField matches the errors of a strict decode that name a field
+// missing from the config types.
+var unknownField = regexp.MustCompile(`^line (\d+): field (.+) not found in type`)
+
+// legacyKeys are accepted for backwards compatibility although the config
+// types no longer declare them.
+var legacyKeys = map[string]bool{
+	"auth":                                 true,
+	"generative-language-api-key":          true,
+	"api-keys":                             true,
+	"amp-upstream-url":                     true,
+	"amp-upstream-api-key":                 true,
+	"amp-restrict-management-to-localhost": true,
+	"amp-model-mappings":                   true,
+	"oauth-model-mappings":                 true,
+}
+
+// warnUnknownKeys logs the keys of the config file that no setting reads,
+// which usually are typos.
+func warnUnknownKeys(file string, data []byte) {
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	var probe Config
+	err := dec.Decode(&probe)
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return
+	}
+	for _, msg := range typeErr.Errors {
+		match := unknownField.FindStringSubmatch(msg)
+		if match == nil || legacyKeys[match[2]] {
+			continue
+		}
+		log.Warnf("%s:%s: unknown config key %q is ignored", file, match[1], match[2])
+	}
+}
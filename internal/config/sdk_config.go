@@ -4,11 +4,91 @@
 // debug settings, proxy configuration, and API keys.
 package config
 
+import "strings"
+
+// UpstreamTLSConfig holds client-side TLS settings for upstream connections.
+type UpstreamTLSConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string `yaml:"ca-file,omitempty" json:"ca-file,omitempty"`
+	// CertFile and KeyFile present a client certificate to upstreams that require mTLS.
+	CertFile string `yaml:"cert-file,omitempty" json:"cert-file,omitempty"`
+	KeyFile  string `yaml:"key-file,omitempty" json:"key-file,omitempty"`
+	// PinnedSHA256 lists base64 SHA-256 digests of accepted public keys (SPKI).
+	// When set, one certificate of the verified chain must match a pin.
+	PinnedSHA256 []string `yaml:"pinned-sha256,omitempty" json:"pinned-sha256,omitempty"`
+}
+
+// UpstreamTransportConfig tunes the shared connection pool used for upstream
+// requests. Zero values keep the defaults noted on each field.
+type UpstreamTransportConfig struct {
+	// MaxIdleConns caps idle connections across all hosts. Defaults to 100.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+	// MaxIdleConnsPerHost caps idle connections kept per host. Defaults to 32.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+	// MaxConnsPerHost caps all connections per host. 0 is unlimited.
+	MaxConnsPerHost int `yaml:"max-conns-per-host,omitempty" json:"max-conns-per-host,omitempty"`
+	// IdleConnTimeoutSeconds closes idle connections after this long. Defaults to 90.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+	// DialTimeoutSeconds bounds establishing a TCP connection. Defaults to 30.
+	DialTimeoutSeconds int `yaml:"dial-timeout-seconds,omitempty" json:"dial-timeout-seconds,omitempty"`
+	// TLSHandshakeTimeoutSeconds bounds the TLS handshake. Defaults to 10.
+	TLSHandshakeTimeoutSeconds int `yaml:"tls-handshake-timeout-seconds,omitempty" json:"tls-handshake-timeout-seconds,omitempty"`
+	// ResponseHeaderTimeoutSeconds bounds the wait for response headers once
+	// the request is sent. 0 waits as long as the request context allows.
+	ResponseHeaderTimeoutSeconds int `yaml:"response-header-timeout-seconds,omitempty" json:"response-header-timeout-seconds,omitempty"`
+	// TLSSessionCacheSize is the number of TLS sessions kept for resumption.
+	// Defaults to 256.
+	TLSSessionCacheSize int `yaml:"tls-session-cache-size,omitempty" json:"tls-session-cache-size,omitempty"`
+	// DisableHTTP2 restricts upstream connections to HTTP/1.1.
+	DisableHTTP2 bool `yaml:"disable-http2,omitempty" json:"disable-http2,omitempty"`
+	// HTTP2PingSeconds sends a health-check ping on an HTTP/2 connection that
+	// received nothing for this long. 0 disables pings.
+	HTTP2PingSeconds int `yaml:"http2-ping-seconds,omitempty" json:"http2-ping-seconds,omitempty"`
+	// HTTP2StreamWindowKB and HTTP2ConnectionWindowKB size the HTTP/2 receive
+	// windows. Larger windows keep fast streams from stalling on flow control.
+	HTTP2StreamWindowKB     int `yaml:"http2-stream-window-kb,omitempty" json:"http2-stream-window-kb,omitempty"`
+	HTTP2ConnectionWindowKB int `yaml:"http2-connection-window-kb,omitempty" json:"http2-connection-window-kb,omitempty"`
+}
+
+// FaultInjectionConfig makes upstream requests fail on purpose so retry,
+// circuit breaker and stream recovery paths can be tested. Never enable it in
+// production.
+type FaultInjectionConfig struct {
+	// Enabled turns fault injection on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// AllowHeader lets clients force a fault per request with the
+	// X-CLIProxy-Fault header (rate-limit, timeout, malformed-sse or truncate).
+	AllowHeader bool `yaml:"allow-header,omitempty" json:"allow-header,omitempty"`
+	// RateLimitPercent answers this share of requests with a synthetic 429.
+	RateLimitPercent float64 `yaml:"rate-limit-percent,omitempty" json:"rate-limit-percent,omitempty"`
+	// TimeoutPercent fails this share of requests with a timeout after
+	// TimeoutSeconds (default 30) or when the request is cancelled.
+	TimeoutPercent float64 `yaml:"timeout-percent,omitempty" json:"timeout-percent,omitempty"`
+	TimeoutSeconds int     `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+	// MalformedSSEPercent prepends an invalid event to this share of event streams.
+	MalformedSSEPercent float64 `yaml:"malformed-sse-percent,omitempty" json:"malformed-sse-percent,omitempty"`
+	// TruncatePercent cuts this share of response bodies short.
+	TruncatePercent float64 `yaml:"truncate-percent,omitempty" json:"truncate-percent,omitempty"`
+}
+
 // SDKConfig represents the application's configuration, loaded from a YAML file.
 type SDKConfig struct {
 	// ProxyURL is the URL of an optional proxy server to use for outbound requests.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
 
+	// NoProxy lists hosts, domains and CIDR ranges that bypass the proxy, in
+	// NO_PROXY syntax. The NO_PROXY environment variable applies when empty.
+	NoProxy []string `yaml:"no-proxy,omitempty" json:"no-proxy,omitempty"`
+
+	// UpstreamTLS customises certificate handling for outbound provider connections.
+	UpstreamTLS UpstreamTLSConfig `yaml:"upstream-tls,omitempty" json:"upstream-tls,omitempty"`
+
+	// UpstreamTransport tunes the connection pool shared by upstream requests.
+	UpstreamTransport UpstreamTransportConfig `yaml:"upstream-transport,omitempty" json:"upstream-transport,omitempty"`
+
+	// FaultInjection simulates upstream failures for testing.
+	FaultInjection FaultInjectionConfig `yaml:"fault-injection,omitempty" json:"fault-injection,omitempty"`
+
 	// ForceModelPrefix requires explicit model prefixes (e.g., "teamA/gemini-3-pro-preview")
 	// to target prefixed credentials. When false, unprefixed model requests may use prefixed
 	// credentials as well.
@@ -20,6 +100,21 @@ type SDKConfig struct {
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// APIKeyPolicies defines client keys with their own model allowlist, rate
+	// limit and credential binding. Their keys are accepted like api-keys.
+	APIKeyPolicies []APIKeyPolicy `yaml:"api-key-policies,omitempty" json:"api-key-policies,omitempty"`
+
+	// APIKeysFile names a YAML list of further api-key-policies entries kept
+	// outside this file. Relative paths resolve against the config directory;
+	// the file is read whenever the config is loaded.
+	APIKeysFile string `yaml:"api-keys-file,omitempty" json:"api-keys-file,omitempty"`
+
+	// fileAPIKeyPolicies holds the entries read from APIKeysFile.
+	fileAPIKeyPolicies []APIKeyPolicy
+
+	// Tenants configures the tenants that api-key-policies entries belong to.
+	Tenants []Tenant `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+
 	// Access holds request authentication provider configuration.
 	Access AccessConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 
@@ -29,18 +124,885 @@ type SDKConfig struct {
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// DisableToolPairRepair turns off the automatic repair of orphan tool calls and
+	// tool results in inbound Claude and OpenAI chat transcripts.
+	DisableToolPairRepair bool `yaml:"disable-tool-pair-repair,omitempty" json:"disable-tool-pair-repair,omitempty"`
+
+	// CountTokens selects how token counting endpoints (e.g. /v1/messages/count_tokens)
+	// are served: "upstream" (default) asks the provider, "local" estimates with the
+	// built-in tokenizer without calling upstream, and "fallback" estimates locally
+	// only when the upstream count fails.
+	CountTokens string `yaml:"count-tokens,omitempty" json:"count-tokens,omitempty"`
+
+	// BuiltinTools controls OpenAI built-in tools (code_interpreter, file_search)
+	// sent to upstreams that do not run them: "pass" (default) forwards them,
+	// "convert" declares them as function tools the client then has to run.
+	BuiltinTools string `yaml:"builtin-tools,omitempty" json:"builtin-tools,omitempty"`
+
+	// RoleMap rewrites message roles the inbound schema does not support (for example
+	// named assistants or legacy "function" messages) to supported ones. Keys are
+	// matched case-insensitively; "*" matches any other unsupported role.
+	RoleMap map[string]string `yaml:"role-map,omitempty" json:"role-map,omitempty"`
+
+	// RoleAlternation makes user and assistant turns alternate for upstreams that
+	// reject consecutive turns of one role: "off" (default), "merge" to merge
+	// adjacent same-role turns, or "strict" to also start the history with a user turn.
+	RoleAlternation string `yaml:"role-alternation,omitempty" json:"role-alternation,omitempty"`
+
+	// MidTranscriptSystem handles system messages that appear between turns:
+	// "off" (default) leaves them in place, "system" moves them in order into a
+	// labeled block appended to the system prompt, and "user" moves each into a
+	// labeled block at the start of the next user turn.
+	MidTranscriptSystem string `yaml:"mid-transcript-system,omitempty" json:"mid-transcript-system,omitempty"`
+
+	// AgentMode lets the proxy execute calls of its built-in tools and continue
+	// the conversation itself before answering the client.
+	AgentMode AgentModeConfig `yaml:"agent-mode,omitempty" json:"agent-mode,omitempty"`
+
+	// MCPServers lists Model Context Protocol servers whose tools are offered in
+	// agent mode.
+	MCPServers []MCPServer `yaml:"mcp-servers,omitempty" json:"mcp-servers,omitempty"`
+
+	// ContextFiles controls how project files attached in a request's
+	// context_files field are added to the system prompt.
+	ContextFiles ContextFilesConfig `yaml:"context-files,omitempty" json:"context-files,omitempty"`
+
+	// AssistantPrefill emulates Anthropic assistant prefill on backends that do
+	// not continue a trailing assistant message.
+	AssistantPrefill AssistantPrefillConfig `yaml:"assistant-prefill,omitempty" json:"assistant-prefill,omitempty"`
+
+	// SystemInjections controls the text the proxy adds to system prompts.
+	SystemInjections SystemInjectionsConfig `yaml:"system-injections,omitempty" json:"system-injections,omitempty"`
+
+	// ShellOutput shortens Claude Code shell output and hook result blocks in
+	// requests before they are forwarded.
+	ShellOutput ShellOutputConfig `yaml:"shell-output,omitempty" json:"shell-output,omitempty"`
+
+	// ChoiceFanOut serves OpenAI chat requests asking for n > 1 choices by issuing
+	// parallel single-choice upstream requests and merging the results.
+	ChoiceFanOut ChoiceFanOutConfig `yaml:"choice-fan-out,omitempty" json:"choice-fan-out,omitempty"`
+
+	// Failover routes requests for a model to configured fallback models when its
+	// backend keeps failing, tracking circuit-breaker state per backend.
+	Failover FailoverConfig `yaml:"failover,omitempty" json:"failover,omitempty"`
+
+	// ProviderRoutes route models named "<prefix>/<model>" to the listed providers
+	// only, e.g. "gemini/gemini-2.5-pro" to the Gemini CLI accounts. Model names
+	// registered with the full prefixed name, such as credential prefixes, are
+	// served as registered.
+	ProviderRoutes []ProviderRoute `yaml:"provider-routes,omitempty" json:"provider-routes,omitempty"`
+
+	// TrafficSplits send a weighted share of the requests for a model to other
+	// models, keeping each conversation on the model it was first assigned.
+	TrafficSplits []TrafficSplit `yaml:"traffic-splits,omitempty" json:"traffic-splits,omitempty"`
+
+	// Shadow sends a copy of a share of the requests to another model in the
+	// background, without affecting the client's response.
+	Shadow ShadowConfig `yaml:"shadow,omitempty" json:"shadow,omitempty"`
+
+	// RequestDiagnosis explains upstream rejections that give no reason by
+	// checking the request locally and, optionally, bisecting its history.
+	RequestDiagnosis RequestDiagnosisConfig `yaml:"request-diagnosis,omitempty" json:"request-diagnosis,omitempty"`
+
+	// Idempotency replays the response of a completed non-streaming request to
+	// retries carrying the same Idempotency-Key header.
+	Idempotency IdempotencyConfig `yaml:"idempotency,omitempty" json:"idempotency,omitempty"`
+
+	// RateLimitQueue holds requests back while an upstream model is rate
+	// limited instead of failing them, releasing them fairly across API keys.
+	RateLimitQueue RateLimitQueueConfig `yaml:"rate-limit-queue,omitempty" json:"rate-limit-queue,omitempty"`
+
+	// InlineImages limits the size of inline base64 images forwarded upstream.
+	InlineImages InlineImageConfig `yaml:"inline-images,omitempty" json:"inline-images,omitempty"`
+
+	// Batches configures processing of /v1/batches jobs.
+	Batches BatchConfig `yaml:"batches,omitempty" json:"batches,omitempty"`
+
+	// ModelProfiles shape requests per model. The first profile whose patterns
+	// match the requested model applies.
+	ModelProfiles []ModelProfile `yaml:"model-profiles,omitempty" json:"model-profiles,omitempty"`
+
+	// RequestLimits caps the size of incoming requests before they are forwarded.
+	RequestLimits RequestLimitsConfig `yaml:"request-limits,omitempty" json:"request-limits,omitempty"`
+
+	// HistoryWindow trims conversations that would overflow the model's context
+	// window, measured with the local tokenizer.
+	HistoryWindow HistoryWindowConfig `yaml:"history-window,omitempty" json:"history-window,omitempty"`
+
+	// StrictTranslation rejects requests with 422 when translating them for the
+	// upstream would drop or change sampling parameters, thinking or tools. The
+	// X-CLIProxy-Strict request header overrides it per request.
+	StrictTranslation bool `yaml:"strict-translation,omitempty" json:"strict-translation,omitempty"`
+
+	// Attachments controls document and audio content blocks for backends that
+	// cannot read them.
+	Attachments AttachmentsConfig `yaml:"attachments,omitempty" json:"attachments,omitempty"`
+
+	// PromptGuard configures prompt-injection scanning of tool descriptions and tool results.
+	PromptGuard PromptGuardConfig `yaml:"prompt-guard,omitempty" json:"prompt-guard,omitempty"`
+
+	// Moderation checks prompts and completions with local rules or an external
+	// moderation API and blocks or annotates flagged content.
+	Moderation ModerationConfig `yaml:"moderation,omitempty" json:"moderation,omitempty"`
+
+	// Sanitize configures the content sanitization pipelines applied to request
+	// and response text.
+	Sanitize SanitizeConfig `yaml:"sanitize,omitempty" json:"sanitize,omitempty"`
+
+	// DLP masks credentials in message content and tool results before forwarding.
+	DLP DLPConfig `yaml:"dlp,omitempty" json:"dlp,omitempty"`
+
+	// EchoDedup strips assistant output that starts by repeating the prompt.
+	EchoDedup EchoDedupConfig `yaml:"echo-dedup,omitempty" json:"echo-dedup,omitempty"`
+
+	// FeatureFlags gates individual request-processing behaviors per client API
+	// key, with percentage rollouts. Flags that are not listed keep their default.
+	FeatureFlags []FeatureFlag `yaml:"feature-flags,omitempty" json:"feature-flags,omitempty"`
+
+	// RequestInspector keeps recent requests in memory for the /inspector.html dashboard.
+	RequestInspector RequestInspectorConfig `yaml:"request-inspector,omitempty" json:"request-inspector,omitempty"`
+}
+
+// RequestInspectorConfig configures the in-memory request history.
+type RequestInspectorConfig struct {
+	// Enabled records requests handled by the API handlers.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxEntries is the number of recent requests kept. Defaults to 100.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// MaxBodyBytes caps the stored size of each request and response body.
+	// Defaults to 262144 (256 KiB).
+	MaxBodyBytes int `yaml:"max-body-bytes,omitempty" json:"max-body-bytes,omitempty"`
+}
+
+// FeatureFlag configures the rollout of one named behavior.
+type FeatureFlag struct {
+	// Name identifies the gated behavior (see the featureflags package).
+	Name string `yaml:"name" json:"name"`
+
+	// Enabled turns the behavior on for the rollout share of client keys.
+	// When false the behavior is off except for keys overridden to true.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Rollout is the percentage (1-100) of client API keys the flag is enabled
+	// for. Keys are bucketed by a stable hash, so raising the percentage only
+	// adds keys. 0 enables the flag for every key.
+	Rollout int `yaml:"rollout,omitempty" json:"rollout,omitempty"`
+
+	// Overrides forces the flag on (true) or off (false) for specific client
+	// API keys, regardless of Enabled and Rollout.
+	Overrides map[string]bool `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+}
+
+// AgentModeConfig configures the server-side tool loop.
+type AgentModeConfig struct {
+	// Enabled turns on the tool loop for non-streaming Claude Messages requests.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxDepth caps the number of tool rounds per request. Defaults to 4.
+	MaxDepth int `yaml:"max-depth,omitempty" json:"max-depth,omitempty"`
+
+	// Tools lists the registered tools offered to the model. Empty means all.
+	Tools []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// MCPServer is a Model Context Protocol server reached over Streamable HTTP.
+type MCPServer struct {
+	// Name identifies the server; its tools are named mcp__<name>__<tool>.
+	Name string `yaml:"name" json:"name"`
+
+	// URL is the MCP endpoint.
+	URL string `yaml:"url" json:"url"`
+
+	// Headers are sent with every request, e.g. Authorization.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Disabled skips the server without removing it from the configuration.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// AssistantPrefillConfig configures assistant prefill emulation.
+type AssistantPrefillConfig struct {
+	// Enabled turns on prefill emulation for Claude Messages requests.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Hint replaces the user turn that asks the model to continue the prefill.
+	Hint string `yaml:"hint,omitempty" json:"hint,omitempty"`
+
+	// OmitPrefill returns only the continuation, as the Anthropic API does,
+	// instead of prepending the prefill to the completion.
+	OmitPrefill bool `yaml:"omit-prefill,omitempty" json:"omit-prefill,omitempty"`
+}
+
+// SystemInjectionsConfig configures the text the proxy adds to system prompts.
+// Each injection can be switched per request with the X-CLIProxy-System-Injections
+// header, e.g. "context-files=off, tool-choice=on".
+type SystemInjectionsConfig struct {
+	// MaxBytes caps the total text injected into one request; injections that
+	// do not fit are skipped and context files are truncated. 0 means no cap.
+	MaxBytes int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+
+	// ContextFiles renders files attached in context_files. Enabled by default.
+	// Template placeholder: {{files}}.
+	ContextFiles InjectionPolicy `yaml:"context-files,omitempty" json:"context-files,omitempty"`
+
+	// ToolManifest lists the request's tools with their descriptions. Disabled
+	// by default. Template placeholder: {{tools}}.
+	ToolManifest InjectionPolicy `yaml:"tool-manifest,omitempty" json:"tool-manifest,omitempty"`
+
+	// ToolChoice restates a forced tool_choice as an instruction, for upstreams
+	// that ignore it. Disabled by default. Template placeholder: {{tool}}.
+	ToolChoice InjectionPolicy `yaml:"tool-choice,omitempty" json:"tool-choice,omitempty"`
+}
+
+// InjectionPolicy enables one system prompt injection and customizes its text.
+type InjectionPolicy struct {
+	// Enabled overrides the injection's default when set.
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+
+	// Template replaces the default text; see the injection for placeholders.
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+}
+
+// ContextFilesConfig configures client-attached project context files.
+type ContextFilesConfig struct {
+	// Disabled forwards context_files untouched instead of moving them into
+	// the system prompt.
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// MaxBytes caps the total size of attached file contents. Defaults to 65536.
+	MaxBytes int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+}
+
+// EchoDedupConfig configures removal of a prompt echoed at the start of the
+// assistant output.
+type EchoDedupConfig struct {
+	// Enabled turns echo detection on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MinChars is the shortest echo that is stripped. Defaults to 32.
+	MinChars int `yaml:"min-chars,omitempty" json:"min-chars,omitempty"`
+
+	// Directives lists injected instruction texts that are stripped as well
+	// when the upstream repeats them verbatim.
+	Directives []string `yaml:"directives,omitempty" json:"directives,omitempty"`
+}
+
+// DLPConfig configures secret masking of outbound prompts.
+type DLPConfig struct {
+	// Enabled turns secret masking on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Rules replaces the built-in detectors (AWS keys, private keys, bearer tokens).
+	Rules []DLPRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// Replacement is the text substituted for detected secrets. Defaults to "[MASKED]".
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// DLPRule is a named secret detector.
+type DLPRule struct {
+	Name    string `yaml:"name" json:"name"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// SanitizeConfig holds the ordered filter lists of the sanitization pipeline.
+type SanitizeConfig struct {
+	// Outbound filters run on user, system and tool result text before it is sent upstream.
+	Outbound []SanitizeFilter `yaml:"outbound,omitempty" json:"outbound,omitempty"`
+
+	// Inbound filters run on assistant text before it is returned to the client.
+	Inbound []SanitizeFilter `yaml:"inbound,omitempty" json:"inbound,omitempty"`
+}
+
+// SanitizeFilter configures a single named filter. Supported names are
+// strip-ansi, strip-control, strip-system-reminders, redact-secrets, max-length,
+// trim-trailing-whitespace, normalize-code-fences and replace.
+type SanitizeFilter struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Patterns are the regular expressions used by redact-secrets and replace.
+	// redact-secrets uses built-in credential patterns when empty.
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Replacement is the text substituted for redact-secrets matches. For
+	// replace it may reference capture groups as $1 or ${name}.
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+
+	// MaxLength is the rune limit enforced by max-length.
+	MaxLength int `yaml:"max-length,omitempty" json:"max-length,omitempty"`
+
+	// PreserveCodeBlocks keeps strip-ansi and strip-control from touching
+	// fenced Markdown code blocks. Such filters then skip streaming deltas.
+	PreserveCodeBlocks bool `yaml:"preserve-code-blocks,omitempty" json:"preserve-code-blocks,omitempty"`
+}
+
+// ShellOutputConfig holds the default shell block policy and per-key overrides.
+type ShellOutputConfig struct {
+	ShellOutputPolicy `yaml:",inline" json:",inline"`
+
+	// Keys overrides the policy for specific client API keys.
+	Keys []ShellOutputKeyPolicy `yaml:"keys,omitempty" json:"keys,omitempty"`
+}
+
+// ShellOutputPolicy controls how oversized shell output and hook blocks are handled.
+type ShellOutputPolicy struct {
+	// Action is "forward" (default), "truncate" to keep the head and tail of each
+	// block, or "summarize" to keep only error-like lines and the final lines.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// MinChars is the block size below which blocks are always forwarded. Defaults to 2000.
+	MinChars int `yaml:"min-chars,omitempty" json:"min-chars,omitempty"`
+
+	// HeadChars and TailChars are kept by truncate. Both default to 1000.
+	HeadChars int `yaml:"head-chars,omitempty" json:"head-chars,omitempty"`
+	TailChars int `yaml:"tail-chars,omitempty" json:"tail-chars,omitempty"`
+}
+
+// ShellOutputKeyPolicy overrides the shell block policy for one client API key.
+type ShellOutputKeyPolicy struct {
+	// APIKey is the client key the policy applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	ShellOutputPolicy `yaml:",inline" json:",inline"`
+}
+
+// PolicyForKey returns the shell block policy for the given client API key.
+func (c ShellOutputConfig) PolicyForKey(apiKey string) ShellOutputPolicy {
+	if apiKey != "" {
+		for i := range c.Keys {
+			if c.Keys[i].APIKey == apiKey {
+				return c.Keys[i].ShellOutputPolicy
+			}
+		}
+	}
+	return c.ShellOutputPolicy
+}
+
+// ChoiceFanOutConfig configures fan-out of multi-choice chat completions.
+type ChoiceFanOutConfig struct {
+	// Enabled turns fan-out on for non-streaming OpenAI chat completions.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxChoices is the largest accepted n; larger values are rejected. Defaults to 4.
+	MaxChoices int `yaml:"max-choices,omitempty" json:"max-choices,omitempty"`
+
+	// Concurrency bounds the number of upstream requests in flight per client request.
+	// Defaults to 2.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+}
+
+// FailoverConfig configures fallback chains and the per-backend circuit breaker
+// guarding them.
+type FailoverConfig struct {
+	// Chains lists the fallback models tried, in order, for a requested model.
+	Chains []FailoverChain `yaml:"chains,omitempty" json:"chains,omitempty"`
+
+	// ErrorThreshold is the number of consecutive failures after which a backend's
+	// circuit opens and it is skipped. Defaults to 3.
+	ErrorThreshold int `yaml:"error-threshold,omitempty" json:"error-threshold,omitempty"`
+
+	// OpenSeconds is how long an open circuit skips its backend before a single
+	// probe request is let through. Defaults to 60.
+	OpenSeconds int `yaml:"open-seconds,omitempty" json:"open-seconds,omitempty"`
+}
+
+// FailoverChain maps a requested model to its fallback models.
+type FailoverChain struct {
+	// Model is the requested model name (without thinking suffix).
+	Model string `yaml:"model" json:"model"`
+
+	// Fallbacks are tried in order once the primary fails or its circuit is open.
+	// Each entry may name a model served by any provider (another account pool,
+	// an OpenAI-compatible key, a Claude API key, ...).
+	Fallbacks []string `yaml:"fallbacks" json:"fallbacks"`
+}
+
+// ProviderRoute restricts prefixed model names to a set of providers.
+type ProviderRoute struct {
+	// Prefix is the model name prefix, without the trailing slash.
+	Prefix string `yaml:"prefix" json:"prefix"`
+
+	// Providers are the provider keys serving the routed models, e.g. "gemini-cli",
+	// "codex", "claude" or an openai-compatibility name.
+	Providers []string `yaml:"providers" json:"providers"`
+}
+
+// TrafficSplit divides the requests for one model between weighted arms.
+type TrafficSplit struct {
+	// Model is the requested model name (without thinking suffix).
+	Model string `yaml:"model" json:"model"`
+
+	// Arms are the models actually requested. Weights are relative; arms with a
+	// weight <= 0 receive no traffic.
+	Arms []TrafficArm `yaml:"arms" json:"arms"`
+}
+
+// TrafficArm is one weighted target of a traffic split.
+type TrafficArm struct {
+	Model  string `yaml:"model" json:"model"`
+	Weight int    `yaml:"weight" json:"weight"`
+}
+
+// ShadowConfig configures request shadowing.
+type ShadowConfig struct {
+	// Rules select the requests to shadow. The first rule matching the
+	// requested model applies.
+	Rules []ShadowRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// MaxConcurrent caps the shadow requests in flight; requests beyond it are
+	// not shadowed. Defaults to 4.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+
+	// TimeoutSeconds bounds each shadow request. Defaults to 300.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// ShadowRule shadows a percentage of the requests for a model.
+type ShadowRule struct {
+	// Model is the requested model name (without thinking suffix), or "*" for
+	// every model.
+	Model string `yaml:"model" json:"model"`
+
+	// ShadowModel is the model the copy is sent to. A provider route prefix
+	// (see provider-routes) pins the copy to specific providers.
+	ShadowModel string `yaml:"shadow-model" json:"shadow-model"`
+
+	// Percent is the share of matching requests that are shadowed, 0-100.
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// RequestDiagnosisConfig configures the diagnosis of opaque 400 errors.
+type RequestDiagnosisConfig struct {
+	// Enabled turns the diagnosis on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Patterns are case-insensitive substrings of the upstream error that
+	// trigger a diagnosis. Defaults to "improperly formed request" and
+	// "malformed".
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Bisect replays shortened histories to find the turn that first makes the
+	// request fail. Each probe is a full upstream request.
+	Bisect bool `yaml:"bisect,omitempty" json:"bisect,omitempty"`
+
+	// MaxProbes caps the requests sent by one bisection. Defaults to 6.
+	MaxProbes int `yaml:"max-probes,omitempty" json:"max-probes,omitempty"`
+
+	// Repair retries a matching rejection with progressively more aggressive
+	// rewrites of the history: tool calls of earlier turns stripped, all tool
+	// blocks collapsed to text, then the oldest turns dropped. Each retry is a
+	// full upstream request; the first accepted one is returned.
+	Repair bool `yaml:"repair,omitempty" json:"repair,omitempty"`
+
+	// ExposeDetail adds the diagnosis to the client's error as error.detail.
+	// It is only logged otherwise.
+	ExposeDetail bool `yaml:"expose-detail,omitempty" json:"expose-detail,omitempty"`
+}
+
+// IdempotencyConfig configures Idempotency-Key handling.
+type IdempotencyConfig struct {
+	// Enabled turns duplicate suppression on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// TTLSeconds is how long a completed response is replayed. Defaults to 600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries caps the number of cached responses. Defaults to 1000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// RateLimitQueueConfig configures queuing of requests to rate-limited models.
+type RateLimitQueueConfig struct {
+	// Enabled turns queuing on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// MaxWaitSeconds is how long a request may wait before it fails with 429.
+	// Defaults to 30.
+	MaxWaitSeconds int `yaml:"max-wait-seconds,omitempty" json:"max-wait-seconds,omitempty"`
+
+	// ReleaseIntervalMS spaces the requests released once a rate limit has
+	// passed. Defaults to 200.
+	ReleaseIntervalMS int `yaml:"release-interval-ms,omitempty" json:"release-interval-ms,omitempty"`
+
+	// DefaultRetrySeconds is how long a model is held back after a 429 that
+	// carries no Retry-After header. Defaults to 5.
+	DefaultRetrySeconds int `yaml:"default-retry-seconds,omitempty" json:"default-retry-seconds,omitempty"`
+}
+
+// InlineImageConfig controls downscaling of oversized inline images.
+type InlineImageConfig struct {
+	// MaxBytes is the decoded size above which an inline image is downscaled. <= 0 disables.
+	MaxBytes int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+
+	// MaxDimension caps the longest side (in pixels) of downscaled images. <= 0 keeps the
+	// original dimensions for the first attempt.
+	MaxDimension int `yaml:"max-dimension,omitempty" json:"max-dimension,omitempty"`
+
+	// MaxPixels is the pixel count (width x height) above which an inline image is
+	// downscaled, whatever its size in bytes. <= 0 disables.
+	MaxPixels int `yaml:"max-pixels,omitempty" json:"max-pixels,omitempty"`
+
+	// PreserveFormat keeps PNG images as PNG and JPEG images as JPEG when they are
+	// re-encoded. By default opaque images become JPEG.
+	PreserveFormat bool `yaml:"preserve-format,omitempty" json:"preserve-format,omitempty"`
+}
+
+// BatchConfig bounds how /v1/batches jobs are processed.
+type BatchConfig struct {
+	// Concurrency is the number of requests of one batch in flight at once. Default is 4.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// RequestsPerMinute caps the request rate across all batches. <= 0 is unlimited.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// MaxRequests caps the number of requests in one batch. Default is 10000.
+	MaxRequests int `yaml:"max-requests,omitempty" json:"max-requests,omitempty"`
+
+	// MaxInputBytes caps the size of the JSONL body that creates a batch. Default is 200 MiB.
+	MaxInputBytes int64 `yaml:"max-input-bytes,omitempty" json:"max-input-bytes,omitempty"`
+
+	// RetentionHours is how long finished batches and their results are kept. Default is 24.
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+}
+
+// RequestLimitsConfig caps request size. Zero values disable a limit.
+type RequestLimitsConfig struct {
+	// MaxMessages caps the number of messages in the conversation.
+	MaxMessages int `yaml:"max-messages,omitempty" json:"max-messages,omitempty"`
+
+	// MaxTotalBytes caps the size of the request body after request processing.
+	MaxTotalBytes int `yaml:"max-total-bytes,omitempty" json:"max-total-bytes,omitempty"`
+
+	// MaxTools caps the number of tool declarations.
+	MaxTools int `yaml:"max-tools,omitempty" json:"max-tools,omitempty"`
+
+	// MaxMessageBytes caps the size of a single message.
+	MaxMessageBytes int `yaml:"max-message-bytes,omitempty" json:"max-message-bytes,omitempty"`
+
+	// MaxToolResultBytes splits tool result text longer than this into ordered
+	// parts of at most this size within the same tool result, for backends
+	// that reject oversized content blocks.
+	MaxToolResultBytes int `yaml:"max-tool-result-bytes,omitempty" json:"max-tool-result-bytes,omitempty"`
+
+	// Action is "reject" (default) to answer 413, or "truncate" to drop the
+	// oldest turns until max-messages and max-total-bytes are met. Requests
+	// that still exceed a limit are rejected.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// HistoryWindowConfig fits conversations into the context window of the
+// requested model. The prompt is measured with the local tokenizer; when it
+// does not leave room for the response, the bodies of the oldest tool results
+// are dropped first, then the oldest turns.
+type HistoryWindowConfig struct {
+	// Enabled turns the window on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// ContextTokens is the context window of models whose size is set neither
+	// in a model profile nor in the model registry. 0 leaves their requests
+	// unchanged.
+	ContextTokens int `yaml:"context-tokens,omitempty" json:"context-tokens,omitempty"`
+
+	// ReserveTokens is kept free for the response when the request does not
+	// set its maximum output tokens. Default is 4096.
+	ReserveTokens int `yaml:"reserve-tokens,omitempty" json:"reserve-tokens,omitempty"`
+}
+
+// ModelProfile holds request shaping rules for a set of models.
+type ModelProfile struct {
+	// Models are model name patterns; '*' matches any run of characters.
+	Models []string `yaml:"models" json:"models"`
+
+	// MaxHistoryTurns keeps only the most recent user turns. <= 0 keeps all.
+	MaxHistoryTurns int `yaml:"max-history-turns,omitempty" json:"max-history-turns,omitempty"`
+
+	// MaxTools caps the number of tool declarations forwarded. <= 0 keeps all.
+	MaxTools int `yaml:"max-tools,omitempty" json:"max-tools,omitempty"`
+
+	// ContextTokens is the context window history-window fits requests for
+	// these models into, overriding the size known to the model registry.
+	ContextTokens int `yaml:"context-tokens,omitempty" json:"context-tokens,omitempty"`
+
+	// ToolChoice replaces system-injections.tool-choice for these models.
+	ToolChoice *InjectionPolicy `yaml:"tool-choice,omitempty" json:"tool-choice,omitempty"`
+
+	// Sanitize replaces sanitize.outbound for these models when set.
+	Sanitize []SanitizeFilter `yaml:"sanitize,omitempty" json:"sanitize,omitempty"`
+}
+
+// AttachmentsConfig sets, per content type, what happens to attachments that
+// the translators would otherwise drop. Policies are "pass" (default, forward
+// unchanged), "text" (replace with the extracted text, PDF included) and
+// "marker" (replace with an "[unsupported attachment: ...]" note).
+type AttachmentsConfig struct {
+	// Document applies to document and file blocks.
+	Document string `yaml:"document,omitempty" json:"document,omitempty"`
+
+	// Audio applies to audio blocks. "text" behaves like "marker".
+	Audio string `yaml:"audio,omitempty" json:"audio,omitempty"`
+}
+
+// APIKeyPolicy scopes one client API key.
+type APIKeyPolicy struct {
+	// APIKey is the key clients present.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Name labels the key in logs, e.g. the owning team.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Models lists the models the key may use; '*' matches any run of
+	// characters. Empty allows every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// RequestsPerMinute caps the key's request rate. <= 0 is unlimited.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// Prefix binds the key to credentials with this prefix by routing every
+	// model as "prefix/model".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Tenant assigns the key to a tenant. Keys of different tenants never
+	// share conversation state, and usage is accounted per tenant.
+	Tenant string `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+}
+
+// Tenant holds the settings shared by all keys of one tenant.
+type Tenant struct {
+	// ID matches the tenant of api-key-policies entries.
+	ID string `yaml:"id" json:"id"`
+
+	// RequestsPerMinute caps the combined request rate of the tenant's keys.
+	// <= 0 is unlimited.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// Prefix binds the tenant to dedicated credentials with this prefix, like
+	// the prefix of a key. A key's own prefix takes precedence.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+}
+
+// AllowsModel reports whether the policy permits the given model name.
+func (p *APIKeyPolicy) AllowsModel(model string) bool {
+	if p == nil || len(p.Models) == 0 {
+		return true
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	for _, pattern := range p.Models {
+		if wildcardMatch(strings.ToLower(strings.TrimSpace(pattern)), model) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyPolicy returns the policy configured for a client API key, or nil.
+// Entries in the config file take precedence over api-keys-file entries.
+func (c *SDKConfig) KeyPolicy(apiKey string) *APIKeyPolicy {
+	if c == nil || apiKey == "" {
+		return nil
+	}
+	for _, list := range [][]APIKeyPolicy{c.APIKeyPolicies, c.fileAPIKeyPolicies} {
+		for i := range list {
+			if list[i].APIKey == apiKey {
+				return &list[i]
+			}
+		}
+	}
+	return nil
+}
+
+// TenantOf returns the tenant of a client API key, or nil when the key has
+// none. Tenants named by a key but missing from tenants have no settings.
+func (c *SDKConfig) TenantOf(apiKey string) *Tenant {
+	policy := c.KeyPolicy(apiKey)
+	if policy == nil {
+		return nil
+	}
+	id := strings.TrimSpace(policy.Tenant)
+	if id == "" {
+		return nil
+	}
+	for i := range c.Tenants {
+		if strings.TrimSpace(c.Tenants[i].ID) == id {
+			return &c.Tenants[i]
+		}
+	}
+	return &Tenant{ID: id}
+}
+
+// ClientAPIKeys returns every key accepted from clients: api-keys followed by
+// the keys of api-key-policies and api-keys-file entries.
+func (c *SDKConfig) ClientAPIKeys() []string {
+	if c == nil {
+		return nil
+	}
+	if len(c.APIKeyPolicies) == 0 && len(c.fileAPIKeyPolicies) == 0 {
+		return c.APIKeys
+	}
+	keys := append([]string(nil), c.APIKeys...)
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		seen[key] = struct{}{}
+	}
+	for _, list := range [][]APIKeyPolicy{c.APIKeyPolicies, c.fileAPIKeyPolicies} {
+		for _, policy := range list {
+			if _, ok := seen[policy.APIKey]; ok || policy.APIKey == "" {
+				continue
+			}
+			seen[policy.APIKey] = struct{}{}
+			keys = append(keys, policy.APIKey)
+		}
+	}
+	return keys
+}
+
+// wildcardMatch matches value against pattern, where '*' matches any run of
+// characters.
+func wildcardMatch(pattern, value string) bool {
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, part)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(part):]
+	}
+	return strings.HasSuffix(value, last)
+}
+
+// PromptGuardConfig controls the prompt-injection scanner applied to inbound requests.
+type PromptGuardConfig struct {
+	// Mode is the default policy: "off" (default), "flag" to log findings only,
+	// or "neutralize" to also replace suspicious text before forwarding.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Patterns lists extra regular expressions treated as injection indicators.
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Keys overrides the mode for specific client API keys.
+	Keys []PromptGuardKeyPolicy `yaml:"keys,omitempty" json:"keys,omitempty"`
+}
+
+// PromptGuardKeyPolicy overrides the prompt guard mode for one client API key.
+type PromptGuardKeyPolicy struct {
+	// APIKey is the client key the policy applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Mode is the policy for this key ("off", "flag" or "neutralize").
+	Mode string `yaml:"mode" json:"mode"`
+}
+
+// ModeForKey returns the configured prompt guard mode for the given client API key.
+func (c PromptGuardConfig) ModeForKey(apiKey string) string {
+	if apiKey != "" {
+		for i := range c.Keys {
+			if c.Keys[i].APIKey == apiKey {
+				return c.Keys[i].Mode
+			}
+		}
+	}
+	return c.Mode
+}
+
+// ModerationConfig configures the content moderation stage.
+type ModerationConfig struct {
+	// Enabled turns moderation on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Action is "block" (default) to replace flagged content with a refusal
+	// finishing with "content_filter", or "annotate" to only log it and mark
+	// the response with the X-CLIProxy-Moderation header.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// SkipInbound and SkipOutbound disable checking the latest user prompt and
+	// the model's completion respectively.
+	SkipInbound  bool `yaml:"skip-inbound,omitempty" json:"skip-inbound,omitempty"`
+	SkipOutbound bool `yaml:"skip-outbound,omitempty" json:"skip-outbound,omitempty"`
+
+	// Message is the refusal text returned for blocked content.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// FailClosed blocks content when a provider cannot be reached. By default
+	// provider errors are logged and the content is allowed.
+	FailClosed bool `yaml:"fail-closed,omitempty" json:"fail-closed,omitempty"`
+
+	// StreamCheckBytes is how much new completion text a stream accumulates
+	// between checks. Defaults to 1024; the end of a stream is always checked.
+	StreamCheckBytes int `yaml:"stream-check-bytes,omitempty" json:"stream-check-bytes,omitempty"`
+
+	// Providers are consulted in order; the first one flagging the text decides.
+	Providers []ModerationProvider `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// ModerationProvider configures one moderation backend.
+type ModerationProvider struct {
+	// Type is "rules" for the local rule engine or "api" for an
+	// OpenAI-compatible /v1/moderations endpoint.
+	Type string `yaml:"type" json:"type"`
+
+	// Rules are the local rules of a "rules" provider.
+	Rules []ModerationRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// URL, APIKey and Model configure an "api" provider. URL defaults to
+	// https://api.openai.com/v1/moderations.
+	URL    string `yaml:"url,omitempty" json:"url,omitempty"`
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+	Model  string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// TimeoutSeconds bounds each call to an "api" provider. Defaults to 10.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// Categories limits an "api" provider to these categories. Empty uses the
+	// provider's own flagged verdict.
+	Categories []string `yaml:"categories,omitempty" json:"categories,omitempty"`
+}
+
+// ModerationRule flags text matching a regular expression under a category.
+type ModerationRule struct {
+	Category string `yaml:"category" json:"category"`
+	Pattern  string `yaml:"pattern" json:"pattern"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
 type StreamingConfig struct {
-	// KeepAliveSeconds controls how often the server emits SSE heartbeats (": keep-alive\n\n").
-	// <= 0 disables keep-alives. Default is 0.
+	// KeepAliveSeconds controls how long the upstream may stay silent before the server
+	// emits a heartbeat: an SSE comment (": keep-alive\n\n") for OpenAI/Gemini streams
+	// and a "ping" event for Claude streams. <= 0 disables keep-alives. Default is 0.
 	KeepAliveSeconds int `yaml:"keepalive-seconds,omitempty" json:"keepalive-seconds,omitempty"`
 
 	// BootstrapRetries controls how many times the server may retry a streaming request before any bytes are sent,
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// CancelGraceSeconds delays cancelling the upstream request after the client
+	// disconnects, letting a nearly finished response complete (and its usage be
+	// recorded) instead of being discarded. <= 0 cancels immediately. Default is 0.
+	CancelGraceSeconds int `yaml:"cancel-grace-seconds,omitempty" json:"cancel-grace-seconds,omitempty"`
+
+	// HedgeDelayMS starts a duplicate of a streaming request when the upstream
+	// has produced no data after this many milliseconds; the first stream to
+	// produce data is used and the other is cancelled. <= 0 disables hedging.
+	HedgeDelayMS int `yaml:"hedge-delay-ms,omitempty" json:"hedge-delay-ms,omitempty"`
+
+	// ResumeAttempts is how many times a Claude or OpenAI chat text stream that
+	// breaks off after data was sent is continued: the request is reissued with
+	// the text received so far and the continuation is appended to the stream.
+	// <= 0 disables resuming. Default is 0.
+	ResumeAttempts int `yaml:"resume-attempts,omitempty" json:"resume-attempts,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
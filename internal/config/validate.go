@@ -0,0 +1,257 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a problem with one configuration field.
+type FieldError struct {
+	// File and Line locate the field; Line is 0 when the field is not in the file.
+	File string
+	Line int
+	// Field is the dotted path of the field, e.g. "tenants[0].id".
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(filepath.Base(e.File))
+		if e.Line > 0 {
+			b.WriteString(":" + strconv.Itoa(e.Line))
+		}
+		b.WriteString(": ")
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field + ": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ValidationError lists every problem found in a configuration file.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		lines = append(lines, "  "+fieldErr.Error())
+	}
+	return fmt.Sprintf("invalid configuration (%d problem(s)):\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// Validate checks values that cannot work: out of range numbers, unknown
+// modes, malformed URLs and duplicate tenants. The returned
+// errors carry field paths but no file location.
+func (cfg *Config) Validate() []FieldError {
+	if cfg == nil {
+		return nil
+	}
+	var errs []FieldError
+	add := func(field, msg string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(msg, args...)})
+	}
+	oneOf := func(field, value string, allowed ...string) {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			return
+		}
+		for _, candidate := range allowed {
+			if value == candidate {
+				return
+			}
+		}
+		add(field, "unknown value %q, expected one of %s", value, strings.Join(allowed, ", "))
+	}
+	percent := func(field string, value float64) {
+		if value < 0 || value > 100 {
+			add(field, "must be between 0 and 100, got %v", value)
+		}
+	}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		add("port", "must be between 0 and 65535, got %d", cfg.Port)
+	}
+	if proxyURL := strings.TrimSpace(cfg.ProxyURL); proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		switch {
+		case err != nil:
+			add("proxy-url", "not a valid URL: %v", err)
+		case parsed.Scheme != "http" && parsed.Scheme != "https" && parsed.Scheme != "socks5" && parsed.Scheme != "socks5h":
+			add("proxy-url", "unsupported scheme %q, expected http, https, socks5 or socks5h", parsed.Scheme)
+		}
+	}
+	validateUpstreamTLS(cfg.UpstreamTLS, add)
+	oneOf("routing.strategy", cfg.Routing.Strategy, "round-robin", "fill-first", "fillfirst", "ff")
+	oneOf("count-tokens", cfg.CountTokens, "upstream", "local", "fallback")
+	oneOf("role-alternation", cfg.RoleAlternation, "off", "merge", "strict")
+	oneOf("builtin-tools", cfg.BuiltinTools, "pass", "convert")
+	oneOf("mid-transcript-system", cfg.MidTranscriptSystem, "off", "system", "user")
+	oneOf("usage-store.type", cfg.UsageStore.Type, "memory", "file")
+	if strings.EqualFold(strings.TrimSpace(cfg.UsageStore.Type), "file") && strings.TrimSpace(cfg.UsageStore.Path) == "" {
+		add("usage-store.path", "required for the file store")
+	}
+	oneOf("prompt-guard.mode", cfg.PromptGuard.Mode, "off", "flag", "neutralize")
+	for i, policy := range cfg.PromptGuard.Keys {
+		oneOf(fmt.Sprintf("prompt-guard.keys[%d].mode", i), policy.Mode, "off", "flag", "neutralize")
+	}
+
+	for i, rule := range cfg.Shadow.Rules {
+		percent(fmt.Sprintf("shadow.rules[%d].percent", i), rule.Percent)
+	}
+	nonNegative := func(field string, value int) {
+		if value < 0 {
+			add(field, "must not be negative, got %d", value)
+		}
+	}
+	nonNegative("rate-limit-queue.max-wait-seconds", cfg.RateLimitQueue.MaxWaitSeconds)
+	nonNegative("rate-limit-queue.release-interval-ms", cfg.RateLimitQueue.ReleaseIntervalMS)
+	nonNegative("rate-limit-queue.default-retry-seconds", cfg.RateLimitQueue.DefaultRetrySeconds)
+	nonNegative("history-window.context-tokens", cfg.HistoryWindow.ContextTokens)
+	nonNegative("history-window.reserve-tokens", cfg.HistoryWindow.ReserveTokens)
+	for i, profile := range cfg.ModelProfiles {
+		nonNegative(fmt.Sprintf("model-profiles[%d].context-tokens", i), profile.ContextTokens)
+	}
+	if cfg.Audit.Enabled {
+		oneOf("audit.content", cfg.Audit.Content, "none", "hash", "full")
+		if key := strings.TrimSpace(cfg.Audit.EncryptionKey); key != "" {
+			if _, err := misc.DecodeEncryptionKey(key); err != nil {
+				add("audit.encryption-key", "%v", err)
+			}
+		}
+		nonNegative("audit.max-body-bytes", cfg.Audit.MaxBodyBytes)
+		nonNegative("audit.max-size-mb", cfg.Audit.MaxSizeMB)
+	}
+	for i, split := range cfg.TrafficSplits {
+		if strings.TrimSpace(split.Model) == "" {
+			add(fmt.Sprintf("traffic-splits[%d].model", i), "must not be empty")
+		}
+	}
+
+	faults := cfg.FaultInjection
+	percent("fault-injection.rate-limit-percent", faults.RateLimitPercent)
+	percent("fault-injection.timeout-percent", faults.TimeoutPercent)
+	percent("fault-injection.malformed-sse-percent", faults.MalformedSSEPercent)
+	percent("fault-injection.truncate-percent", faults.TruncatePercent)
+	if total := faults.RateLimitPercent + faults.TimeoutPercent + faults.MalformedSSEPercent + faults.TruncatePercent; total > 100 {
+		add("fault-injection", "fault percentages add up to %v, more than 100", total)
+	}
+
+	tenants := make(map[string]bool, len(cfg.Tenants))
+	for i, tenant := range cfg.Tenants {
+		id := strings.TrimSpace(tenant.ID)
+		switch {
+		case id == "":
+			add(fmt.Sprintf("tenants[%d].id", i), "must not be empty")
+		case tenants[id]:
+			add(fmt.Sprintf("tenants[%d].id", i), "duplicate tenant %q", id)
+		}
+		tenants[id] = true
+	}
+	return errs
+}
+
+// validateUpstreamTLS loads the upstream-tls files and pins, so a setting that
+// would leave upstream connections unverified or unpinned is rejected at load.
+func validateUpstreamTLS(cfg UpstreamTLSConfig, add func(field, msg string, args ...any)) {
+	if caFile := strings.TrimSpace(cfg.CAFile); caFile != "" {
+		data, err := os.ReadFile(caFile)
+		switch {
+		case err != nil:
+			add("upstream-tls.ca-file", "cannot read CA bundle: %v", err)
+		case !x509.NewCertPool().AppendCertsFromPEM(data):
+			add("upstream-tls.ca-file", "%s contains no PEM certificates", caFile)
+		}
+	}
+	certFile, keyFile := strings.TrimSpace(cfg.CertFile), strings.TrimSpace(cfg.KeyFile)
+	switch {
+	case certFile == "" && keyFile == "":
+	case certFile == "":
+		add("upstream-tls.cert-file", "required when key-file is set")
+	case keyFile == "":
+		add("upstream-tls.key-file", "required when cert-file is set")
+	default:
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			add("upstream-tls.cert-file", "cannot load client certificate: %v", err)
+		}
+	}
+	for i, pin := range cfg.PinnedSHA256 {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		if pin == "" {
+			continue
+		}
+		if digest, err := base64.StdEncoding.DecodeString(pin); err != nil || len(digest) != sha256.Size {
+			add(fmt.Sprintf("upstream-tls.pinned-sha256[%d]", i), "%q is not the base64 of a SHA-256 digest", pin)
+		}
+	}
+}
+
+// locateFields sets the file and line of each error from the parsed document.
+// Fields missing from the file are located at their closest parent.
+func locateFields(errs []FieldError, file string, root *yaml.Node) []FieldError {
+	lines := make(map[string]int)
+	walkNodes(root, "", func(path string, key, node *yaml.Node) {
+		if key != nil {
+			node = key
+		}
+		lines[path] = node.Line
+	})
+	for i := range errs {
+		errs[i].File = file
+		for path := errs[i].Field; path != "" && errs[i].Line == 0; {
+			errs[i].Line = lines[path]
+			if cut := strings.LastIndexAny(path, ".["); cut >= 0 {
+				path = path[:cut]
+			} else {
+				path = ""
+			}
+		}
+	}
+	return errs
+}
+
+// walkNodes calls fn for every node of the mapping and sequence tree under
+// node with its dotted path, e.g. "tenants[0].id", and its key node when it is
+// a mapping value.
+func walkNodes(node *yaml.Node, path string, fn func(path string, key, node *yaml.Node)) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkNodes(child, path, fn)
+		}
+		return
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			childPath := node.Content[i].Value
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			fn(childPath, node.Content[i], node.Content[i+1])
+			walkNodes(node.Content[i+1], childPath, fn)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			fn(childPath, nil, child)
+			walkNodes(child, childPath, fn)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return configFile
+}
+
+func TestLoadConfigInterpolatesEnvironment(t *testing.T) {
+	t.Setenv("CLIPROXY_TEST_PORT", "9000")
+	t.Setenv("CLIPROXY_TEST_KEY", "sk-env")
+	configFile := writeConfig(t, `port: ${CLIPROXY_TEST_PORT}
+api-keys:
+  - "${CLIPROXY_TEST_KEY}"
+  - "${CLIPROXY_TEST_UNSET:-fallback}"
+  - "$${LITERAL}"
+`)
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != 9000 {
+		t.Fatalf("Port = %d, want 9000", cfg.Port)
+	}
+	want := []string{"sk-env", "fallback", "${LITERAL}"}
+	if strings.Join(cfg.APIKeys, ",") != strings.Join(want, ",") {
+		t.Fatalf("APIKeys = %v, want %v", cfg.APIKeys, want)
+	}
+
+	cfg.Debug = true
+	if err = SaveConfigPreserveComments(configFile, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	saved, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "sk-env") || !strings.Contains(string(saved), "${CLIPROXY_TEST_KEY}") {
+		t.Fatalf("saved config lost the env reference:\n%s", saved)
+	}
+}
+
+func TestLoadConfigReportsUnsetVariable(t *testing.T) {
+	configFile := writeConfig(t, `port: 8317
+api-keys:
+  - "${CLIPROXY_TEST_MISSING}"
+`)
+
+	_, err := LoadConfig(configFile)
+	var validation *ValidationError
+	if !errors.As(err, &validation) || len(validation.Errors) != 1 {
+		t.Fatalf("err = %v, want one validation error", err)
+	}
+	if got := validation.Errors[0]; got.Line != 3 || got.Field != "api-keys[0]" {
+		t.Fatalf("error = %+v, want api-keys[0] on line 3", got)
+	}
+}
+
+func TestLoadConfigValidatesFields(t *testing.T) {
+	configFile := writeConfig(t, `port: 70000
+routing:
+  strategy: random
+tenants:
+  - id: acme
+  - id: acme
+fault-injection:
+  rate-limit-percent: 80
+  timeout-percent: 40
+`)
+
+	_, err := LoadConfig(configFile)
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	got := make(map[string]int)
+	for _, fieldErr := range validation.Errors {
+		got[fieldErr.Field] = fieldErr.Line
+	}
+	want := map[string]int{
+		"port":             1,
+		"routing.strategy": 3,
+		"tenants[1].id":    6,
+		"fault-injection":  7,
+	}
+	for field, line := range want {
+		if got[field] != line {
+			t.Errorf("%s reported on line %d, want %d (errors: %v)", field, got[field], line, err)
+		}
+	}
+	if !strings.Contains(err.Error(), "config.yaml:1: port:") {
+		t.Fatalf("message %q does not locate the port", err)
+	}
+}
+
+func TestLoadConfigRejectsBrokenUpstreamTLS(t *testing.T) {
+	configFile := writeConfig(t, `upstream-tls:
+  ca-file: /nonexistent/ca.pem
+  key-file: client.key
+  pinned-sha256:
+    - "not-a-digest"
+`)
+
+	_, err := LoadConfig(configFile)
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	got := make(map[string]int)
+	for _, fieldErr := range validation.Errors {
+		got[fieldErr.Field] = fieldErr.Line
+	}
+	want := map[string]int{
+		"upstream-tls.ca-file":          2,
+		"upstream-tls.cert-file":        1,
+		"upstream-tls.pinned-sha256[0]": 5,
+	}
+	for field, line := range want {
+		if got[field] != line {
+			t.Errorf("%s reported on line %d, want %d (errors: %v)", field, got[field], line, err)
+		}
+	}
+}
+
+func TestLoadConfigRejectsMisconfiguredAudit(t *testing.T) {
+	configFile := writeConfig(t, `audit:
+  enabled: true
+  content: everything
+  encryption-key: secret
+`)
+
+	_, err := LoadConfig(configFile)
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	got := make(map[string]int)
+	for _, fieldErr := range validation.Errors {
+		got[fieldErr.Field] = fieldErr.Line
+	}
+	if got["audit.content"] != 3 || got["audit.encryption-key"] != 4 {
+		t.Fatalf("errors = %v", err)
+	}
+
+	disabled := writeConfig(t, `audit:
+  enabled: false
+  encryption-key: secret
+`)
+	if _, err = LoadConfig(disabled); err != nil {
+		t.Fatalf("disabled audit rejected: %v", err)
+	}
+}
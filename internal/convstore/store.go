@@ -0,0 +1,332 @@
+// Package convstore persists conversation state (upstream conversation IDs,
+// summaries and usage statistics) across proxy restarts in an embedded
+// append-only file.
+package convstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRecordBytes caps one line of the store file. Larger values are refused
+// by Put, and larger lines found on load are skipped.
+const maxRecordBytes = 16 << 20
+
+// minCompactBytes is the journal growth below which size alone never
+// triggers a compaction.
+const minCompactBytes = 1 << 20
+
+// Buckets used by the proxy.
+const (
+	// BucketConversations maps client conversation keys to upstream conversation IDs.
+	BucketConversations = "conversations"
+	// BucketSummaries holds history compaction summaries keyed by conversation.
+	BucketSummaries = "summaries"
+	// BucketUsage holds the usage statistics snapshot.
+	BucketUsage = "usage"
+	// BucketPlanMode holds plan mode trackers keyed by conversation.
+	BucketPlanMode = "plan_mode"
+)
+
+// record is one line of the store file. A record without a value deletes the key.
+type record struct {
+	Bucket  string          `json:"b"`
+	Key     string          `json:"k"`
+	Value   json.RawMessage `json:"v,omitempty"`
+	Updated time.Time       `json:"t"`
+}
+
+type entry struct {
+	value   json.RawMessage
+	updated time.Time
+}
+
+// Store is a small key/value store kept in memory and journaled to a file.
+// Entries not updated within the retention period are dropped.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	retention time.Duration
+	file      *os.File
+	entries   map[string]map[string]entry
+	// appended counts journal lines written since the last compaction, and
+	// appendedBytes their size; compactedBytes is the size of the file the
+	// last compaction wrote.
+	appended       int
+	appendedBytes  int64
+	compactedBytes int64
+}
+
+// Open loads the store at path, creating it when missing, and rewrites it
+// without expired or superseded records. retention <= 0 keeps entries forever.
+func Open(path string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("convstore: create directory: %w", err)
+	}
+	s := &Store{path: path, retention: retention, entries: make(map[string]map[string]entry)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("convstore: open %s: %w", s.path, err)
+	}
+	defer func() { _ = f.Close() }()
+	r := bufio.NewReaderSize(f, 64<<10)
+	for lineNo := 1; ; lineNo++ {
+		line, tooLong, errRead := readLine(r)
+		if tooLong {
+			log.Warnf("convstore: skipping line %d of %s: longer than %d bytes", lineNo, s.path, maxRecordBytes)
+		} else if len(line) > 0 {
+			var rec record
+			// A torn final line from a crash is skipped rather than failing startup.
+			if json.Unmarshal(line, &rec) == nil && rec.Bucket != "" {
+				s.applyLocked(rec)
+			}
+		}
+		if errors.Is(errRead, io.EOF) {
+			return nil
+		}
+		if errRead != nil {
+			return fmt.Errorf("convstore: read %s: %w", s.path, errRead)
+		}
+	}
+}
+
+// readLine returns the next line of r without its newline. A line longer than
+// maxRecordBytes is consumed without being kept and reported as too long.
+func readLine(r *bufio.Reader) ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > maxRecordBytes+1 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return bytes.TrimSuffix(line, []byte("\n")), tooLong, err
+	}
+}
+
+func (s *Store) applyLocked(rec record) {
+	if len(rec.Value) == 0 {
+		delete(s.entries[rec.Bucket], rec.Key)
+		return
+	}
+	bucket := s.entries[rec.Bucket]
+	if bucket == nil {
+		bucket = make(map[string]entry)
+		s.entries[rec.Bucket] = bucket
+	}
+	bucket[rec.Key] = entry{value: rec.Value, updated: rec.Updated}
+}
+
+func (s *Store) expired(e entry, now time.Time) bool {
+	return s.retention > 0 && now.Sub(e.updated) > s.retention
+}
+
+// Get returns the value stored under bucket/key.
+func (s *Store) Get(bucket, key string) ([]byte, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[bucket][key]
+	if !ok || s.expired(e, time.Now()) {
+		return nil, false
+	}
+	return append([]byte(nil), e.value...), true
+}
+
+// GetJSON decodes the value stored under bucket/key into v.
+func (s *Store) GetJSON(bucket, key string, v any) bool {
+	data, ok := s.Get(bucket, key)
+	return ok && json.Unmarshal(data, v) == nil
+}
+
+// Put stores value, which must be valid JSON, under bucket/key.
+func (s *Store) Put(bucket, key string, value []byte) error {
+	if s == nil {
+		return nil
+	}
+	if !json.Valid(value) {
+		return fmt.Errorf("convstore: value for %s/%s is not valid JSON", bucket, key)
+	}
+	return s.write(record{Bucket: bucket, Key: key, Value: append(json.RawMessage(nil), value...), Updated: time.Now()})
+}
+
+// PutJSON encodes v and stores it under bucket/key.
+func (s *Store) PutJSON(bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("convstore: encode %s/%s: %w", bucket, key, err)
+	}
+	return s.Put(bucket, key, data)
+}
+
+// Delete removes bucket/key.
+func (s *Store) Delete(bucket, key string) error {
+	if s == nil {
+		return nil
+	}
+	return s.write(record{Bucket: bucket, Key: key, Updated: time.Now()})
+}
+
+func (s *Store) write(rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("convstore: encode record: %w", err)
+	}
+	if len(line) > maxRecordBytes {
+		return fmt.Errorf("convstore: value for %s/%s is larger than %d bytes", rec.Bucket, rec.Key, maxRecordBytes)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("convstore: store is closed")
+	}
+	s.applyLocked(rec)
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("convstore: write %s: %w", s.path, err)
+	}
+	s.appended++
+	s.appendedBytes += int64(len(line) + 1)
+	// Compact once superseded lines outnumber live keys, or once the journal
+	// has grown past the size of the last compacted file.
+	if (s.appended > 1000 && s.appended > 2*s.lenLocked()) || s.appendedBytes > max(s.compactedBytes, minCompactBytes) {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+func (s *Store) lenLocked() int {
+	n := 0
+	for _, bucket := range s.entries {
+		n += len(bucket)
+	}
+	return n
+}
+
+// Compact drops expired entries and rewrites the file with one line per live key.
+func (s *Store) Compact() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.compactLocked()
+}
+
+func (s *Store) compactLocked() error {
+	now := time.Now()
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("convstore: create %s: %w", tmp, err)
+	}
+	w := bufio.NewWriter(f)
+	written := int64(0)
+	for name, bucket := range s.entries {
+		for key, e := range bucket {
+			if s.expired(e, now) {
+				delete(bucket, key)
+				continue
+			}
+			line, _ := json.Marshal(record{Bucket: name, Key: key, Value: e.value, Updated: e.updated})
+			_, _ = w.Write(append(line, '\n'))
+			written += int64(len(line) + 1)
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("convstore: rewrite %s: %w", s.path, err)
+	}
+	if s.file != nil {
+		_ = s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("convstore: reopen %s: %w", s.path, err)
+	}
+	s.appended, s.appendedBytes, s.compactedBytes = 0, 0, written
+	return nil
+}
+
+// Close compacts the store and closes its file.
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.compactLocked()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultStore *Store
+)
+
+// SetDefault installs the process-wide store; nil disables persistence.
+func SetDefault(s *Store) {
+	defaultMu.Lock()
+	defaultStore = s
+	defaultMu.Unlock()
+}
+
+// Default returns the process-wide store, or nil when persistence is disabled.
+// All Store methods are safe to call on a nil store.
+func Default() *Store {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultStore
+}
@@ -0,0 +1,115 @@
+package convstore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.db")
+	store, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err = store.PutJSON(BucketConversations, "a", map[string]string{"id": "conv-1"}); err != nil {
+		t.Fatalf("PutJSON: %v", err)
+	}
+	_ = store.Put(BucketConversations, "b", []byte(`"old"`))
+	_ = store.Put(BucketConversations, "b", []byte(`"new"`))
+	_ = store.Put(BucketSummaries, "gone", []byte(`"x"`))
+	_ = store.Delete(BucketSummaries, "gone")
+	if err = store.Put(BucketUsage, "k", []byte("{")); err == nil {
+		t.Fatalf("invalid JSON was accepted")
+	}
+	// Simulate a crash during the last write.
+	if f, errOpen := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600); errOpen == nil {
+		_, _ = f.WriteString(`{"b":"conversations","k":"c","v":`)
+		_ = f.Close()
+	}
+
+	reopened, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	var got struct{ ID string }
+	if !reopened.GetJSON(BucketConversations, "a", &got) || got.ID != "conv-1" {
+		t.Fatalf("a = %+v", got)
+	}
+	if value, ok := reopened.Get(BucketConversations, "b"); !ok || string(value) != `"new"` {
+		t.Fatalf("b = %s (found %v)", value, ok)
+	}
+	if _, ok := reopened.Get(BucketSummaries, "gone"); ok {
+		t.Fatalf("deleted key is still present")
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("compacted file has %d lines:\n%s", lines, data)
+	}
+}
+
+func TestStoreDropsExpiredEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.db")
+	store, err := Open(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	_ = store.Put(BucketConversations, "fresh", []byte(`1`))
+	store.mu.Lock()
+	store.entries[BucketConversations]["stale"] = entry{value: []byte(`2`), updated: time.Now().Add(-2 * time.Hour)}
+	store.mu.Unlock()
+
+	if _, ok := store.Get(BucketConversations, "stale"); ok {
+		t.Fatalf("expired entry returned")
+	}
+	if err = store.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if _, ok := store.entries[BucketConversations]["stale"]; ok {
+		t.Fatalf("expired entry kept after compaction")
+	}
+	if _, ok := store.Get(BucketConversations, "fresh"); !ok {
+		t.Fatalf("fresh entry dropped")
+	}
+}
+
+func TestNilStoreIsDisabled(t *testing.T) {
+	var store *Store
+	if err := store.PutJSON(BucketUsage, "k", 1); err != nil {
+		t.Fatalf("PutJSON on nil store: %v", err)
+	}
+	if _, ok := store.Get(BucketUsage, "k"); ok {
+		t.Fatalf("nil store returned a value")
+	}
+}
+
+func TestStoreSkipsOverlongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.db")
+	huge := `{"b":"usage","k":"statistics","v":"` + strings.Repeat("x", maxRecordBytes) + `","t":"2026-01-01T00:00:00Z"}`
+	content := `{"b":"conversations","k":"a","v":"first","t":"2026-01-01T00:00:00Z"}` + "\n" + huge + "\n" +
+		`{"b":"conversations","k":"b","v":"second","t":"2026-01-01T00:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	store, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+	for key, want := range map[string]string{"a": `"first"`, "b": `"second"`} {
+		if got, ok := store.Get(BucketConversations, key); !ok || string(got) != want {
+			t.Fatalf("%s = %s, %v", key, got, ok)
+		}
+	}
+	if _, ok := store.Get(BucketUsage, "statistics"); ok {
+		t.Fatal("overlong record was loaded")
+	}
+	if err = store.Put(BucketUsage, "statistics", []byte(`"`+strings.Repeat("x", maxRecordBytes)+`"`)); err == nil {
+		t.Fatal("overlong value was accepted")
+	}
+}
@@ -0,0 +1,239 @@
+// Package diagnose explains requests an upstream rejected without saying why.
+// Check runs local contract checks on a client payload; Bisect replays
+// shortened histories to find the turn that first makes the request fail.
+package diagnose
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// historyPath returns the path of the conversation history in a client schema.
+func historyPath(format string) string {
+	switch format {
+	case "claude", "openai":
+		return "messages"
+	case "openai-response":
+		return "input"
+	case "gemini":
+		return "contents"
+	case "gemini-cli":
+		return "request.contents"
+	default:
+		return ""
+	}
+}
+
+// Check returns the contract problems found in a claude or openai payload,
+// each naming the offending message. Other schemas are not checked.
+func Check(format string, payload []byte) []string {
+	var problems []string
+	add := func(msg string, args ...any) { problems = append(problems, fmt.Sprintf(msg, args...)) }
+	switch format {
+	case "claude":
+		messages := gjson.GetBytes(payload, "messages").Array()
+		if len(messages) == 0 {
+			add("messages is empty")
+		}
+		for i, message := range messages {
+			role := message.Get("role").String()
+			if role != "user" && role != "assistant" {
+				add("messages[%d]: unsupported role %q", i, role)
+			}
+			if i > 0 && messages[i-1].Get("role").String() == role {
+				add("messages[%d]: two consecutive %s turns", i, role)
+			}
+			content := message.Get("content")
+			if isEmptyContent(content) {
+				add("messages[%d]: empty content", i)
+			}
+			content.ForEach(func(key, block gjson.Result) bool {
+				switch block.Get("type").String() {
+				case "text":
+					if strings.TrimSpace(block.Get("text").String()) == "" {
+						add("messages[%d].content[%d]: empty text block", i, key.Int())
+					}
+				case "tool_use":
+					if block.Get("id").String() == "" || block.Get("name").String() == "" {
+						add("messages[%d].content[%d]: tool_use without id or name", i, key.Int())
+					}
+					if !block.Get("input").IsObject() {
+						add("messages[%d].content[%d]: tool_use input is not an object", i, key.Int())
+					}
+				}
+				return true
+			})
+		}
+		if _, repairs := util.RepairClaudeToolPairs(payload); len(repairs) > 0 {
+			problems = append(problems, describeRepairs(repairs)...)
+		}
+		problems = append(problems, checkToolNames(payload, "name")...)
+	case "openai":
+		messages := gjson.GetBytes(payload, "messages").Array()
+		if len(messages) == 0 {
+			add("messages is empty")
+		}
+		for i, message := range messages {
+			role := message.Get("role").String()
+			switch role {
+			case "system", "developer", "user", "assistant", "tool":
+			default:
+				add("messages[%d]: unsupported role %q", i, role)
+			}
+			if role == "tool" && message.Get("tool_call_id").String() == "" {
+				add("messages[%d]: tool message without tool_call_id", i)
+			}
+			calls := message.Get("tool_calls")
+			if role != "assistant" || !calls.Exists() {
+				if isEmptyContent(message.Get("content")) {
+					add("messages[%d]: empty content", i)
+				}
+			}
+			calls.ForEach(func(key, call gjson.Result) bool {
+				if args := call.Get("function.arguments").String(); args != "" && !gjson.Valid(args) {
+					add("messages[%d].tool_calls[%d]: arguments are not valid JSON", i, key.Int())
+				}
+				return true
+			})
+		}
+		if _, repairs := util.RepairOpenAIToolPairs(payload); len(repairs) > 0 {
+			problems = append(problems, describeRepairs(repairs)...)
+		}
+		problems = append(problems, checkToolNames(payload, "function.name")...)
+	}
+	return problems
+}
+
+func isEmptyContent(content gjson.Result) bool {
+	switch {
+	case !content.Exists() || content.Type == gjson.Null:
+		return true
+	case content.Type == gjson.String:
+		return strings.TrimSpace(content.String()) == ""
+	case content.IsArray():
+		return len(content.Array()) == 0
+	default:
+		return false
+	}
+}
+
+func describeRepairs(repairs []util.ToolPairRepair) []string {
+	out := make([]string, 0, len(repairs))
+	for _, repair := range repairs {
+		if repair.Kind == util.ToolPairSynthesizedResult {
+			out = append(out, fmt.Sprintf("messages[%d]: tool call %s has no result", repair.MessageIndex, repair.ToolUseID))
+		} else {
+			out = append(out, fmt.Sprintf("messages[%d]: tool result %s answers no tool call", repair.MessageIndex, repair.ToolUseID))
+		}
+	}
+	return out
+}
+
+func checkToolNames(payload []byte, namePath string) []string {
+	var problems []string
+	seen := map[string]bool{}
+	gjson.GetBytes(payload, "tools").ForEach(func(key, tool gjson.Result) bool {
+		name := tool.Get(namePath).String()
+		switch {
+		case name == "":
+			problems = append(problems, fmt.Sprintf("tools[%d]: missing name", key.Int()))
+		case seen[name]:
+			problems = append(problems, fmt.Sprintf("tools[%d]: duplicate name %q", key.Int(), name))
+		}
+		seen[name] = true
+		return true
+	})
+	return problems
+}
+
+// Finding is the result of a bisection.
+type Finding struct {
+	// Turn and Through delimit the history entries whose inclusion first makes
+	// the request fail. Turn is -1 when even the shortest history fails.
+	Turn    int
+	Through int
+	// Role is the role of the entry at Turn.
+	Role string
+	// Probes is the number of requests sent.
+	Probes int
+}
+
+func (f Finding) String() string {
+	if f.Turn < 0 {
+		return fmt.Sprintf("the request fails with only its first user turn, so that turn, the system prompt, the tools or the settings are the likely cause (%d probe(s))", f.Probes)
+	}
+	if f.Through > f.Turn {
+		return fmt.Sprintf("the request first fails once turns %d-%d are included, the first being the %s turn (%d probe(s))", f.Turn, f.Through, f.Role, f.Probes)
+	}
+	return fmt.Sprintf("the request first fails once turn %d (%s) is included (%d probe(s))", f.Turn, f.Role, f.Probes)
+}
+
+// Bisect finds the history entry that first makes the request fail by binary
+// search over histories cut after a user turn, so every probe is a complete
+// request. fails sends a probe and reports whether it was rejected like the
+// original. It returns false when the history has fewer than two user turns
+// or maxProbes is reached before the search converges.
+func Bisect(format string, payload []byte, maxProbes int, fails func([]byte) bool) (Finding, bool) {
+	path := historyPath(format)
+	if path == "" {
+		return Finding{}, false
+	}
+	items := gjson.GetBytes(payload, path).Array()
+	var cuts []int
+	for i, item := range items {
+		if role := item.Get("role").String(); role == "user" || (role == "" && format != "openai-response") {
+			cuts = append(cuts, i)
+		}
+	}
+	if len(cuts) < 2 {
+		return Finding{}, false
+	}
+	probes := 0
+	probe := func(cut int) (bool, bool) {
+		if probes >= maxProbes {
+			return false, false
+		}
+		probes++
+		raw := make([]string, 0, cut+1)
+		for _, item := range items[:cut+1] {
+			raw = append(raw, item.Raw)
+		}
+		shortened, err := sjson.SetRawBytes(payload, path, []byte("["+strings.Join(raw, ",")+"]"))
+		if err != nil {
+			return false, false
+		}
+		return fails(shortened), true
+	}
+
+	// Invariant: the history cut at cuts[lo] passes, the one cut at cuts[hi]
+	// fails. The full history counts as failing, having been rejected already.
+	lo, hi := -1, len(cuts)
+	if last := cuts[len(cuts)-1]; last == len(items)-1 {
+		hi = len(cuts) - 1
+	}
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		failed, ok := probe(cuts[mid])
+		if !ok {
+			return Finding{}, false
+		}
+		if failed {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	if lo < 0 {
+		return Finding{Turn: -1, Probes: probes}, true
+	}
+	// The culprit lies after the passing cut and up to the failing one.
+	turn, through := cuts[lo]+1, len(items)-1
+	if hi < len(cuts) {
+		through = cuts[hi]
+	}
+	return Finding{Turn: turn, Through: through, Role: items[turn].Get("role").String(), Probes: probes}, true
+}
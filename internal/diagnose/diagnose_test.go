@@ -0,0 +1,67 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestCheckReportsContractProblems(t *testing.T) {
+	claude := []byte(`{"messages":[
+		{"role":"user","content":"hi"},
+		{"role":"user","content":[{"type":"text","text":" "}]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":"x"}]},
+		{"role":"user","content":"go on"}
+	],"tools":[{"name":"read"},{"name":"read"}]}`)
+	problems := strings.Join(Check("claude", claude), "\n")
+	for _, want := range []string{
+		"messages[1]: two consecutive user turns",
+		"messages[1].content[0]: empty text block",
+		"messages[2].content[0]: tool_use input is not an object",
+		"tool call t1 has no result",
+		`tools[1]: duplicate name "read"`,
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("missing %q in:\n%s", want, problems)
+		}
+	}
+
+	openai := []byte(`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{"}}]},{"role":"tool","tool_call_id":"c1","content":"ok"}]}`)
+	if got := Check("openai", openai); len(got) != 1 || !strings.Contains(got[0], "arguments are not valid JSON") {
+		t.Fatalf("openai problems = %v", got)
+	}
+	if got := Check("openai", []byte(`{"messages":[{"role":"user","content":"hi"}]}`)); len(got) != 0 {
+		t.Fatalf("valid request reported %v", got)
+	}
+}
+
+func TestBisectFindsFirstFailingTurn(t *testing.T) {
+	payload := []byte(`{"messages":[
+		{"role":"user","content":"a"},
+		{"role":"assistant","content":"b"},
+		{"role":"user","content":"c"},
+		{"role":"assistant","content":"bad"},
+		{"role":"user","content":"d"},
+		{"role":"assistant","content":"e"},
+		{"role":"user","content":"f"}
+	]}`)
+	fails := func(shortened []byte) bool {
+		return strings.Contains(gjson.GetBytes(shortened, "messages").Raw, "bad")
+	}
+	finding, ok := Bisect("claude", payload, 6, fails)
+	if !ok {
+		t.Fatal("bisection did not converge")
+	}
+	if finding.Turn != 3 || finding.Through != 4 || finding.Role != "assistant" {
+		t.Fatalf("finding = %+v", finding)
+	}
+
+	if _, ok = Bisect("claude", payload, 1, fails); ok {
+		t.Fatal("expected the probe limit to stop the bisection")
+	}
+	finding, ok = Bisect("claude", payload, 6, func([]byte) bool { return true })
+	if !ok || finding.Turn != -1 {
+		t.Fatalf("finding = %+v (%v)", finding, ok)
+	}
+}
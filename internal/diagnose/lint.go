@@ -0,0 +1,149 @@
+package diagnose
+
+import (
+	"fmt"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+)
+
+// DefaultMaxToolDescription is the tool description length above which Lint
+// warns.
+const DefaultMaxToolDescription = 10000
+
+// Warning is an issue Lint found in a client payload.
+type Warning struct {
+	// Path locates the offending value, e.g. "messages[2].content[0]".
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Message
+	}
+	return w.Path + ": " + w.Message
+}
+
+// LintOptions tunes Lint.
+type LintOptions struct {
+	// MaxToolDescription is the longest tool description accepted without a
+	// warning. <= 0 uses DefaultMaxToolDescription.
+	MaxToolDescription int
+}
+
+// claudeBlockTypes are the content block types the proxy translates.
+var claudeBlockTypes = map[string]bool{
+	"text": true, "image": true, "document": true, "tool_use": true, "tool_result": true,
+	"thinking": true, "redacted_thinking": true,
+}
+
+// openAIPartTypes are the content part types the proxy translates.
+var openAIPartTypes = map[string]bool{
+	"text": true, "image_url": true, "input_audio": true, "file": true,
+}
+
+// DetectFormat guesses whether a payload is an Anthropic Messages ("claude")
+// or an OpenAI Chat Completions ("openai") request. It returns "" when the
+// payload looks like neither.
+func DetectFormat(payload []byte) string {
+	root := gjson.ParseBytes(payload)
+	if !root.Get("messages").IsArray() {
+		return ""
+	}
+	if root.Get("system").Exists() || root.Get("tools.0.input_schema").Exists() {
+		return "claude"
+	}
+	if root.Get("tools.0.function").Exists() {
+		return "openai"
+	}
+	for _, message := range root.Get("messages").Array() {
+		for _, block := range message.Get("content").Array() {
+			switch block.Get("type").String() {
+			case "tool_use", "tool_result", "image", "document", "thinking":
+				return "claude"
+			}
+		}
+	}
+	return "openai"
+}
+
+// Lint returns the issues in a claude or openai payload that the proxy will
+// repair, rename or drop on the way upstream: oversized tool descriptions,
+// unsafe tool names, orphan tool results, unsupported content types and a
+// missing user turn. It complements Check, which reports contract errors.
+func Lint(format string, payload []byte, opts LintOptions) []Warning {
+	if opts.MaxToolDescription <= 0 {
+		opts.MaxToolDescription = DefaultMaxToolDescription
+	}
+	var warnings []Warning
+	add := func(path, msg string, args ...any) {
+		warnings = append(warnings, Warning{Path: path, Message: fmt.Sprintf(msg, args...)})
+	}
+	root := gjson.ParseBytes(payload)
+	messages := root.Get("messages").Array()
+
+	nameKey, descriptionKey := "name", "description"
+	if format == "openai" {
+		nameKey, descriptionKey = "function.name", "function.description"
+	}
+	root.Get("tools").ForEach(func(key, tool gjson.Result) bool {
+		if name := tool.Get(nameKey).String(); name != "" && !util.SafeToolName(name) {
+			add(fmt.Sprintf("tools[%d].%s", key.Int(), nameKey), "tool name %q is renamed upstream: use letters, digits, _ and - only, at most %d characters", name, util.ToolNameMaxLength)
+		}
+		if description := tool.Get(descriptionKey).String(); len(description) > opts.MaxToolDescription {
+			add(fmt.Sprintf("tools[%d].%s", key.Int(), descriptionKey), "description is %d characters, more than %d; upstreams may truncate it and it is resent with every request", len(description), opts.MaxToolDescription)
+		}
+		return true
+	})
+
+	hasUser := false
+	for i, message := range messages {
+		role := message.Get("role").String()
+		if role == "user" {
+			hasUser = true
+		}
+		content := message.Get("content")
+		if !content.IsArray() {
+			continue
+		}
+		content.ForEach(func(key, block gjson.Result) bool {
+			path := fmt.Sprintf("messages[%d].content[%d]", i, key.Int())
+			blockType := block.Get("type").String()
+			switch format {
+			case "claude":
+				if !claudeBlockTypes[blockType] {
+					add(path, "content type %q is not supported by the translators and may be dropped", blockType)
+				}
+				if blockType == "tool_result" && role != "user" {
+					add(path, "tool_result in a %q turn; it belongs in the user turn after the tool_use", role)
+				}
+			case "openai":
+				if !openAIPartTypes[blockType] {
+					add(path, "content part type %q is not supported by the translators and may be dropped", blockType)
+				}
+			}
+			return true
+		})
+	}
+	if len(messages) > 0 && !hasUser {
+		add("messages", "no user turn; most upstreams reject a conversation without one")
+	}
+
+	var repairs []util.ToolPairRepair
+	switch format {
+	case "claude":
+		_, repairs = util.RepairClaudeToolPairs(payload)
+	case "openai":
+		_, repairs = util.RepairOpenAIToolPairs(payload)
+	}
+	for _, repair := range repairs {
+		path := fmt.Sprintf("messages[%d]", repair.MessageIndex)
+		if repair.Kind == util.ToolPairSynthesizedResult {
+			add(path, "tool call %s has no result; the proxy adds a placeholder result", repair.ToolUseID)
+		} else {
+			add(path, "tool result %s answers no tool call; the proxy drops it", repair.ToolUseID)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,41 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintReportsPathsOfMangledParts(t *testing.T) {
+	claude := []byte(`{"system":"be brief","messages":[
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{}}]},
+		{"role":"assistant","content":[{"type":"tool_result","tool_use_id":"t9","content":"x"},{"type":"video","source":{}}]}
+	],"tools":[{"name":"server.read/file","description":"` + strings.Repeat("d", 20) + `","input_schema":{}}]}`)
+	if got := DetectFormat(claude); got != "claude" {
+		t.Fatalf("DetectFormat = %q, want claude", got)
+	}
+	var lines []string
+	for _, warning := range Lint("claude", claude, LintOptions{MaxToolDescription: 10}) {
+		lines = append(lines, warning.String())
+	}
+	report := strings.Join(lines, "\n")
+	for _, want := range []string{
+		`tools[0].name: tool name "server.read/file" is renamed upstream`,
+		"tools[0].description: description is 20 characters",
+		`messages[1].content[1]: content type "video" is not supported`,
+		"messages[1].content[0]: tool_result in a \"assistant\" turn",
+		"messages: no user turn",
+		"tool call t1 has no result",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("missing %q in:\n%s", want, report)
+		}
+	}
+
+	openai := []byte(`{"messages":[{"role":"system","content":"x"},{"role":"user","content":[{"type":"text","text":"hi"}]}],"tools":[{"type":"function","function":{"name":"read"}}]}`)
+	if got := DetectFormat(openai); got != "openai" {
+		t.Fatalf("DetectFormat = %q, want openai", got)
+	}
+	if got := Lint("openai", openai, LintOptions{}); len(got) != 0 {
+		t.Fatalf("clean request reported %v", got)
+	}
+}
@@ -0,0 +1,221 @@
+package diagnose
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Transcript repair levels, from least to most aggressive.
+const (
+	// RepairStripToolUses removes the tool calls of earlier assistant turns,
+	// and their results, keeping only the latest tool exchange.
+	RepairStripToolUses = "strip_tool_uses"
+	// RepairCollapseToolResults rewrites every tool call and result as text,
+	// so the history carries no tool blocks at all.
+	RepairCollapseToolResults = "collapse_tool_results"
+	// RepairTruncateHistory collapses tool blocks like RepairCollapseToolResults
+	// and drops the oldest half of the user turns.
+	RepairTruncateHistory = "truncate_history"
+)
+
+// RepairLevels lists the repair levels in the order they should be tried.
+var RepairLevels = []string{RepairStripToolUses, RepairCollapseToolResults, RepairTruncateHistory}
+
+// Repair rewrites the history of a claude or openai payload that an upstream
+// rejected, at the given level. Each level is applied to the payload as given,
+// not on top of the previous one. It reports false when the level changes
+// nothing or the format is not supported.
+func Repair(format string, payload []byte, level string) ([]byte, bool) {
+	if format != "claude" && format != "openai" {
+		return payload, false
+	}
+	var out []byte
+	switch level {
+	case RepairStripToolUses:
+		out = stripToolUses(format, payload)
+	case RepairCollapseToolResults:
+		out = collapseToolBlocks(format, payload)
+	case RepairTruncateHistory:
+		out = collapseToolBlocks(format, payload)
+		if turns := util.CountUserTurns(format, out); turns > 1 {
+			out, _ = util.TrimHistoryTurns(format, out, (turns+1)/2)
+		}
+	default:
+		return payload, false
+	}
+	if string(out) == string(payload) {
+		return payload, false
+	}
+	return out, true
+}
+
+// stripToolUses drops the tool calls of every assistant turn before the one
+// answered by the last user message, then the results left unanswered.
+func stripToolUses(format string, payload []byte) []byte {
+	items := gjson.GetBytes(payload, "messages").Array()
+	live := -1
+	for i, item := range items {
+		if item.Get("role").String() == "assistant" && i+1 < len(items) {
+			live = i
+		}
+	}
+	out := make([]string, 0, len(items))
+	changed := false
+	for i, item := range items {
+		if i >= live || item.Get("role").String() != "assistant" {
+			out = append(out, item.Raw)
+			continue
+		}
+		raw := item.Raw
+		switch format {
+		case "claude":
+			content := item.Get("content")
+			if !content.IsArray() {
+				break
+			}
+			var kept []gjson.Result
+			for _, block := range content.Array() {
+				if block.Get("type").String() != "tool_use" {
+					kept = append(kept, block)
+				}
+			}
+			if len(kept) == len(content.Array()) {
+				break
+			}
+			changed = true
+			if len(kept) == 0 {
+				continue
+			}
+			raw, _ = sjson.SetRaw(raw, "content", joinRaw(kept))
+		case "openai":
+			if !item.Get("tool_calls").Exists() {
+				break
+			}
+			changed = true
+			if strings.TrimSpace(textOf(item.Get("content"))) == "" {
+				continue
+			}
+			raw, _ = sjson.Delete(raw, "tool_calls")
+		}
+		out = append(out, raw)
+	}
+	if !changed {
+		return payload
+	}
+	updated, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload
+	}
+	if format == "claude" {
+		updated, _ = util.RepairClaudeToolPairs(updated)
+	} else {
+		updated, _ = util.RepairOpenAIToolPairs(updated)
+	}
+	updated, _ = util.EnforceRoleAlternation(format, updated, util.RoleAlternationMerge)
+	return updated
+}
+
+// collapseToolBlocks rewrites tool calls and results as text.
+func collapseToolBlocks(format string, payload []byte) []byte {
+	items := gjson.GetBytes(payload, "messages").Array()
+	out := make([]string, 0, len(items))
+	changed := false
+	for _, item := range items {
+		raw := item.Raw
+		switch format {
+		case "claude":
+			content := item.Get("content")
+			if !content.IsArray() {
+				break
+			}
+			blocks := make([]string, 0, len(content.Array()))
+			for _, block := range content.Array() {
+				var text string
+				switch block.Get("type").String() {
+				case "tool_use":
+					text = "[Tool call " + block.Get("name").String() + ": " + rawOrEmptyObject(block.Get("input")) + "]"
+				case "tool_result":
+					text = toolResultText(block.Get("is_error").Bool(), textOf(block.Get("content")))
+				default:
+					blocks = append(blocks, block.Raw)
+					continue
+				}
+				changed = true
+				textBlock, _ := sjson.Set(`{"type":"text"}`, "text", text)
+				blocks = append(blocks, textBlock)
+			}
+			raw, _ = sjson.SetRaw(raw, "content", "["+strings.Join(blocks, ",")+"]")
+		case "openai":
+			switch item.Get("role").String() {
+			case "tool":
+				changed = true
+				raw, _ = sjson.Set(`{"role":"user"}`, "content", toolResultText(false, textOf(item.Get("content"))))
+			case "assistant":
+				calls := item.Get("tool_calls")
+				if !calls.Exists() {
+					break
+				}
+				changed = true
+				var texts []string
+				if text := textOf(item.Get("content")); strings.TrimSpace(text) != "" {
+					texts = append(texts, text)
+				}
+				for _, call := range calls.Array() {
+					texts = append(texts, "[Tool call "+call.Get("function.name").String()+": "+call.Get("function.arguments").String()+"]")
+				}
+				raw, _ = sjson.Delete(raw, "tool_calls")
+				raw, _ = sjson.Set(raw, "content", strings.Join(texts, "\n\n"))
+			}
+		}
+		out = append(out, raw)
+	}
+	if !changed {
+		return payload
+	}
+	updated, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload
+	}
+	updated, _ = util.EnforceRoleAlternation(format, updated, util.RoleAlternationMerge)
+	return updated
+}
+
+func toolResultText(isError bool, text string) string {
+	if isError {
+		return "[Tool error: " + text + "]"
+	}
+	return "[Tool result: " + text + "]"
+}
+
+// textOf returns the text of a string content or the joined text blocks of a
+// block list.
+func textOf(content gjson.Result) string {
+	if !content.IsArray() {
+		return content.String()
+	}
+	var texts []string
+	for _, block := range content.Array() {
+		if text := block.Get("text"); text.Exists() {
+			texts = append(texts, text.String())
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func rawOrEmptyObject(value gjson.Result) string {
+	if !value.Exists() {
+		return "{}"
+	}
+	return value.Raw
+}
+
+func joinRaw(items []gjson.Result) string {
+	raws := make([]string, len(items))
+	for i, item := range items {
+		raws[i] = item.Raw
+	}
+	return "[" + strings.Join(raws, ",") + "]"
+}
@@ -0,0 +1,100 @@
+package diagnose
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRepairLevels(t *testing.T) {
+	claude := []byte(`{"messages":[
+		{"role":"user","content":"list files"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"ls","input":{"dir":"."}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"a.go"}]},
+		{"role":"assistant","content":[{"type":"text","text":"reading"},{"type":"tool_use","id":"t2","name":"read","input":{"file":"a.go"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"package a"}]}]},
+		{"role":"assistant","content":"done"},
+		{"role":"user","content":"thanks"}
+	]}`)
+
+	// Only "done" answers the last user turn, so every tool exchange is history.
+	stripped, ok := Repair("claude", claude, RepairStripToolUses)
+	if !ok {
+		t.Fatal("strip_tool_uses changed nothing")
+	}
+	messages := gjson.GetBytes(stripped, "messages")
+	if got := messages.Get("#.role").Raw; got != `["user","assistant","user","assistant","user"]` {
+		t.Fatalf("roles = %s in %s", got, stripped)
+	}
+	if gjson.GetBytes(stripped, `messages.1.content.#(type=="tool_use")`).Exists() {
+		t.Fatalf("tool_use left in %s", stripped)
+	}
+	if messages.Get("1.content.0.text").String() != "reading" {
+		t.Fatalf("assistant text lost: %s", stripped)
+	}
+
+	collapsed, ok := Repair("claude", claude, RepairCollapseToolResults)
+	if !ok {
+		t.Fatal("collapse_tool_results changed nothing")
+	}
+	if got := gjson.GetBytes(collapsed, "messages.1.content.0.text").String(); got != `[Tool call ls: {"dir":"."}]` {
+		t.Fatalf("tool call text = %q", got)
+	}
+	if got := gjson.GetBytes(collapsed, "messages.4.content.0.text").String(); got != "[Tool result: package a]" {
+		t.Fatalf("tool result text = %q", got)
+	}
+
+	truncated, ok := Repair("claude", claude, RepairTruncateHistory)
+	if !ok {
+		t.Fatal("truncate_history changed nothing")
+	}
+	if got := gjson.GetBytes(truncated, "messages.#.role").Raw; got != `["user","assistant","user"]` {
+		t.Fatalf("roles = %s in %s", got, truncated)
+	}
+	if got := gjson.GetBytes(truncated, "messages.0.content.0.text").String(); got != "[Tool result: package a]" {
+		t.Fatalf("truncated history starts with %q", got)
+	}
+
+	if _, ok := Repair("gemini", claude, RepairStripToolUses); ok {
+		t.Fatal("unsupported format repaired")
+	}
+	plain := []byte(`{"messages":[{"role":"user","content":"hi"}]}`)
+	for _, level := range RepairLevels {
+		if _, ok := Repair("claude", plain, level); ok {
+			t.Fatalf("%s changed a payload without tools", level)
+		}
+	}
+}
+
+func TestRepairOpenAI(t *testing.T) {
+	openai := []byte(`{"messages":[
+		{"role":"system","content":"be brief"},
+		{"role":"user","content":"list"},
+		{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"ls","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"c1","content":"a.go"},
+		{"role":"assistant","content":"one file"},
+		{"role":"user","content":"thanks"}
+	]}`)
+
+	stripped, ok := Repair("openai", openai, RepairStripToolUses)
+	if !ok {
+		t.Fatal("strip_tool_uses changed nothing")
+	}
+	if got := gjson.GetBytes(stripped, "messages.#.role").Raw; got != `["system","user","assistant","user"]` {
+		t.Fatalf("roles = %s in %s", got, stripped)
+	}
+
+	collapsed, ok := Repair("openai", openai, RepairCollapseToolResults)
+	if !ok {
+		t.Fatal("collapse_tool_results changed nothing")
+	}
+	if got := gjson.GetBytes(collapsed, "messages.#.role").Raw; got != `["system","user","assistant","user","assistant","user"]` {
+		t.Fatalf("roles = %s in %s", got, collapsed)
+	}
+	if got := gjson.GetBytes(collapsed, "messages.2.content").String(); got != "[Tool call ls: {}]" {
+		t.Fatalf("tool call text = %q", got)
+	}
+	if got := gjson.GetBytes(collapsed, "messages.3.content").String(); got != "[Tool result: a.go]" {
+		t.Fatalf("tool result text = %q", got)
+	}
+}
@@ -0,0 +1,232 @@
+// Package eventdiff compares the SSE event sequences two translator builds
+// produce for the same captured upstream stream, to pinpoint behavior changes
+// between releases. It is meant for offline use (see cmd/fixturetool).
+//
+// Events are aligned on their kind (the SSE event name and the JSON "type" or
+// "object" field) with a longest common subsequence, so an inserted or missing
+// event does not shift every later comparison. Aligned events are compared
+// field by field; identifiers and timestamps that change on every call are
+// ignored.
+package eventdiff
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Event is one SSE event.
+type Event struct {
+	// Name is the value of the event: field, empty when absent.
+	Name string
+	// Data is the data: payload; multiple data lines are joined by newlines.
+	Data []byte
+}
+
+// Kind identifies the event for alignment.
+func (e Event) Kind() string {
+	kind := e.Name
+	if typ := gjson.GetBytes(e.Data, "type").String(); typ != "" {
+		kind += "/" + typ
+	} else if object := gjson.GetBytes(e.Data, "object").String(); object != "" {
+		kind += "/" + object
+	} else if !gjson.ValidBytes(e.Data) {
+		kind += "/" + string(e.Data)
+	}
+	return kind
+}
+
+// ParseEvents splits an SSE stream into events. Bare JSON lines, as some
+// upstreams stream, count as data-only events.
+func ParseEvents(stream []byte) []Event {
+	var events []Event
+	var current Event
+	var data [][]byte
+	flush := func() {
+		if current.Name != "" || len(data) > 0 {
+			current.Data = bytes.Join(data, []byte("\n"))
+			events = append(events, current)
+		}
+		current, data = Event{}, nil
+	}
+	for _, line := range bytes.Split(stream, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		switch {
+		case len(bytes.TrimSpace(line)) == 0:
+			flush()
+		case bytes.HasPrefix(line, []byte("event:")):
+			current.Name = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			data = append(data, bytes.TrimSpace(line[len("data:"):]))
+		case bytes.HasPrefix(line, []byte(":")), bytes.HasPrefix(line, []byte("id:")), bytes.HasPrefix(line, []byte("retry:")):
+		default:
+			flush()
+			data = append(data, bytes.TrimSpace(line))
+			flush()
+		}
+	}
+	flush()
+	return events
+}
+
+// Change kinds.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is one difference between two event sequences.
+type Change struct {
+	Kind string
+	// OldIndex and NewIndex are the positions of the event in each sequence,
+	// -1 for the side it is missing from.
+	OldIndex int
+	NewIndex int
+	// Event is the kind of the event.
+	Event string
+	// Path, Old and New describe a changed field; Old or New is empty when the
+	// field is missing on that side.
+	Path string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ new[%d] %s", c.NewIndex, c.Event)
+	case Removed:
+		return fmt.Sprintf("- old[%d] %s", c.OldIndex, c.Event)
+	default:
+		old, updated := c.Old, c.New
+		if old == "" {
+			old = "(missing)"
+		}
+		if updated == "" {
+			updated = "(missing)"
+		}
+		return fmt.Sprintf("~ old[%d] new[%d] %s %s: %s -> %s", c.OldIndex, c.NewIndex, c.Event, c.Path, old, updated)
+	}
+}
+
+// volatileKeys hold identifiers and timestamps that differ on every call.
+var volatileKeys = map[string]bool{
+	"id": true, "created": true, "created_at": true, "system_fingerprint": true,
+	"responseId": true, "createTime": true,
+}
+
+// Diff returns the differences between the event sequences old and new, in
+// stream order. It returns nil when they are structurally equal.
+func Diff(old, new []Event) []Change {
+	n, m := len(old), len(new)
+	// lcs[i][j] is the length of the longest common subsequence of old[i:]
+	// and new[j:], comparing event kinds.
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	oldKinds, newKinds := kinds(old), kinds(new)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if oldKinds[i] == newKinds[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var changes []Change
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && oldKinds[i] == newKinds[j]:
+			changes = append(changes, compareEvents(i, j, oldKinds[i], old[i].Data, new[j].Data)...)
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			changes = append(changes, Change{Kind: Added, OldIndex: -1, NewIndex: j, Event: newKinds[j]})
+			j++
+		default:
+			changes = append(changes, Change{Kind: Removed, OldIndex: i, NewIndex: -1, Event: oldKinds[i]})
+			i++
+		}
+	}
+	return changes
+}
+
+func kinds(events []Event) []string {
+	out := make([]string, len(events))
+	for i, event := range events {
+		out[i] = event.Kind()
+	}
+	return out
+}
+
+// compareEvents returns the fields that differ between two aligned events.
+func compareEvents(oldIndex, newIndex int, kind string, old, new []byte) []Change {
+	oldFields, newFields := flatten(old), flatten(new)
+	paths := make([]string, 0, len(oldFields)+len(newFields))
+	for path := range oldFields {
+		paths = append(paths, path)
+	}
+	for path := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var changes []Change
+	for _, path := range paths {
+		if oldFields[path] != newFields[path] {
+			changes = append(changes, Change{Kind: Changed, OldIndex: oldIndex, NewIndex: newIndex, Event: kind, Path: path, Old: oldFields[path], New: newFields[path]})
+		}
+	}
+	return changes
+}
+
+// flatten maps the leaf paths of a JSON payload to their raw values. Non-JSON
+// payloads map to a single "data" field.
+func flatten(payload []byte) map[string]string {
+	fields := make(map[string]string)
+	if !gjson.ValidBytes(payload) {
+		fields["data"] = string(payload)
+		return fields
+	}
+	var walk func(path string, value gjson.Result)
+	walk = func(path string, value gjson.Result) {
+		if !value.IsObject() && !value.IsArray() {
+			fields[path] = value.Raw
+			return
+		}
+		empty := true
+		value.ForEach(func(key, child gjson.Result) bool {
+			empty = false
+			name := key.String()
+			if value.IsArray() {
+				name = fmt.Sprintf("[%d]", key.Int())
+			} else if volatileKeys[name] {
+				return true
+			}
+			childPath := name
+			switch {
+			case path == "":
+			case strings.HasPrefix(name, "["):
+				childPath = path + name
+			default:
+				childPath = path + "." + name
+			}
+			walk(childPath, child)
+			return true
+		})
+		if empty {
+			fields[path] = value.Raw
+		}
+	}
+	walk("", gjson.ParseBytes(payload))
+	return fields
+}
@@ -0,0 +1,56 @@
+package eventdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffAlignsEventsAndReportsFields(t *testing.T) {
+	old := ParseEvents([]byte(`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","model":"m"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`))
+	updated := ParseEvents([]byte(`event: message_start
+data: {"type":"message_start","message":{"id":"msg_2","model":"m"}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"hi"}}
+
+`))
+	if len(old) != 3 || old[1].Name != "content_block_delta" {
+		t.Fatalf("ParseEvents = %+v", old)
+	}
+
+	var lines []string
+	for _, change := range Diff(old, updated) {
+		lines = append(lines, change.String())
+	}
+	want := []string{
+		"+ new[1] ping/ping",
+		"~ old[1] new[2] content_block_delta/content_block_delta index: 0 -> 1",
+		"- old[2] message_stop/message_stop",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Diff =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	if changes := Diff(old, ParseEvents([]byte(strings.ReplaceAll(`event: message_start
+data: {"type":"message_start","message":{"id":"msg_9","model":"m"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}
+
+event: message_stop
+data: {"type":"message_stop"}
+`, "\n", "\r\n")))); len(changes) != 0 {
+		t.Fatalf("equal streams reported %v", changes)
+	}
+}
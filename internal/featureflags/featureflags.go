@@ -0,0 +1,119 @@
+// Package featureflags evaluates runtime feature flags. A flag gates one
+// request-processing behavior and is resolved per client API key: explicit
+// overrides win, otherwise an enabled flag applies to a stable percentage of
+// keys. Flags missing from the configuration use their registered default, so
+// removing a flag from the config restores the built-in behavior.
+package featureflags
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Known flag names.
+const (
+	// ToolPairRepair gates the repair of orphan tool calls and results in
+	// inbound transcripts.
+	ToolPairRepair = "tool-pair-repair"
+	// EchoDedup gates stripping of prompt echoes from assistant output when
+	// echo-dedup is configured.
+	EchoDedup = "echo-dedup"
+	// ToolCallDedup gates merging of duplicated tool calls in non-streaming
+	// responses.
+	ToolCallDedup = "tool-call-dedup"
+	// IncrementalToolArguments streams tool call arguments to the client as
+	// they arrive instead of buffering them until the call is complete.
+	IncrementalToolArguments = "incremental-tool-arguments"
+	// PlanModeTracking reports the plan mode state of Claude Code
+	// conversations in responses.
+	PlanModeTracking = "plan-mode-tracking"
+	// ToolChoiceNone removes tools from requests with tool_choice "none" and
+	// drops tool calls from their responses.
+	ToolChoiceNone = "tool-choice-none"
+	// ToolNameMapping renames tools whose names upstreams may reject and
+	// restores the original names in returned tool calls.
+	ToolNameMapping = "tool-name-mapping"
+	// ToolCallIDMapping replaces tool call IDs upstreams may reject and
+	// restores the client's IDs in returned tool calls.
+	ToolCallIDMapping = "tool-call-id-mapping"
+)
+
+// Definition describes a known flag.
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+var definitions = []Definition{
+	{Name: ToolPairRepair, Description: "Repair unmatched tool calls and tool results in inbound transcripts", Default: true},
+	{Name: EchoDedup, Description: "Strip a prompt echoed at the start of assistant output (requires echo-dedup)", Default: true},
+	{Name: ToolCallDedup, Description: "Merge tool calls repeated with the same tool call ID in non-streaming responses", Default: true},
+	{Name: IncrementalToolArguments, Description: "Stream tool call arguments as they arrive when translating between OpenAI and Claude streams", Default: false},
+	{Name: PlanModeTracking, Description: "Track the plan mode of Claude Code conversations and report it in responses", Default: true},
+	{Name: ToolChoiceNone, Description: "Remove tools from requests with tool_choice none and drop tool calls from their responses", Default: true},
+	{Name: ToolNameMapping, Description: "Rename tools with names upstreams may reject and restore the original names in tool calls", Default: true},
+	{Name: ToolCallIDMapping, Description: "Replace tool call IDs upstreams may reject and restore the client's IDs in tool calls", Default: true},
+}
+
+// Known returns the definitions of all known flags.
+func Known() []Definition {
+	return append([]Definition(nil), definitions...)
+}
+
+// IsKnown reports whether name is a known flag.
+func IsKnown(name string) bool {
+	_, ok := definition(name)
+	return ok
+}
+
+func definition(name string) (Definition, bool) {
+	name = Normalize(name)
+	for _, def := range definitions {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Normalize returns the canonical form of a flag name.
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Enabled reports whether the flag name is on for the client API key.
+// Unconfigured flags return their default (false for unknown names).
+func Enabled(flags []config.FeatureFlag, name, key string) bool {
+	name = Normalize(name)
+	for i := range flags {
+		flag := &flags[i]
+		if Normalize(flag.Name) != name {
+			continue
+		}
+		if value, ok := flag.Overrides[key]; ok && key != "" {
+			return value
+		}
+		if !flag.Enabled {
+			return false
+		}
+		if flag.Rollout <= 0 || flag.Rollout >= 100 {
+			return true
+		}
+		return Bucket(name, key) < flag.Rollout
+	}
+	def, _ := definition(name)
+	return def.Default
+}
+
+// Bucket maps a key to a stable rollout bucket in [0, 100) for the flag. The
+// flag name is part of the hash so different flags roll out to different keys.
+func Bucket(name, key string) int {
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(Normalize(name)))
+	_, _ = hasher.Write([]byte{0})
+	_, _ = hasher.Write([]byte(key))
+	return int(hasher.Sum32() % 100)
+}
@@ -0,0 +1,46 @@
+package featureflags
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestEnabled(t *testing.T) {
+	if !Enabled(nil, ToolPairRepair, "k") || Enabled(nil, "no-such-flag", "k") {
+		t.Fatalf("unconfigured flags must use their default")
+	}
+
+	flags := []config.FeatureFlag{{
+		Name:      " Echo-Dedup ",
+		Enabled:   false,
+		Overrides: map[string]bool{"beta-key": true},
+	}}
+	if Enabled(flags, EchoDedup, "other-key") || !Enabled(flags, EchoDedup, "beta-key") {
+		t.Fatalf("disabled flag must only apply to overridden keys")
+	}
+
+	flags = []config.FeatureFlag{{Name: EchoDedup, Enabled: true, Rollout: 30, Overrides: map[string]bool{"opt-out": false}}}
+	on := 0
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		enabled := Enabled(flags, EchoDedup, key)
+		if enabled != Enabled(flags, EchoDedup, key) {
+			t.Fatalf("rollout is not stable for %s", key)
+		}
+		if enabled {
+			on++
+			// Raising the rollout keeps every key that was already enabled.
+			if !Enabled([]config.FeatureFlag{{Name: EchoDedup, Enabled: true, Rollout: 60}}, EchoDedup, key) {
+				t.Fatalf("%s dropped out when the rollout grew", key)
+			}
+		}
+	}
+	if on < 450 || on > 750 {
+		t.Fatalf("30%% rollout enabled %d of 2000 keys", on)
+	}
+	if Enabled(flags, EchoDedup, "opt-out") {
+		t.Fatalf("override must win over the rollout")
+	}
+}
@@ -0,0 +1,227 @@
+// Package health implements the liveness and readiness probes. Readiness is
+// derived from the credential pool: the proxy is ready when at least one
+// account is enabled, has a fresh token and is not cooling down, and, when
+// upstream pings are configured, at least one provider answered its ping.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPingInterval = time.Minute
+	pingTimeout         = 10 * time.Second
+)
+
+// Account status values reported by the readiness probe.
+const (
+	StatusReady    = "ready"
+	StatusDisabled = "disabled"
+	StatusExpired  = "expired"
+	StatusCooldown = "cooldown"
+	StatusError    = "error"
+)
+
+// AccountReport describes one credential.
+// Index is the stable auth index; ID and Label are only reported when
+// identities are enabled since the probe is unauthenticated.
+type AccountReport struct {
+	Index          string     `json:"auth_index"`
+	ID             string     `json:"id,omitempty"`
+	Label          string     `json:"label,omitempty"`
+	Provider       string     `json:"provider"`
+	Status         string     `json:"status"`
+	Message        string     `json:"message,omitempty"`
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty"`
+	RetryAfter     *time.Time `json:"retry_after,omitempty"`
+}
+
+// PingReport is the cached outcome of an upstream ping.
+type PingReport struct {
+	Provider   string    `json:"provider"`
+	URL        string    `json:"url"`
+	Reachable  bool      `json:"reachable"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Report is the readiness probe body.
+type Report struct {
+	Ready         bool            `json:"ready"`
+	ReadyAccounts int             `json:"ready_accounts"`
+	Accounts      []AccountReport `json:"accounts"`
+	Pings         []PingReport    `json:"pings,omitempty"`
+	CheckedAt     time.Time       `json:"checked_at"`
+}
+
+// Checker evaluates readiness against the auth manager.
+type Checker struct {
+	manager *coreauth.Manager
+
+	mu    sync.Mutex
+	cfg   config.HealthConfig
+	pings map[string]PingReport
+}
+
+// NewChecker returns a checker for manager.
+func NewChecker(manager *coreauth.Manager, cfg config.HealthConfig) *Checker {
+	return &Checker{manager: manager, cfg: cfg, pings: make(map[string]PingReport)}
+}
+
+// SetConfig applies a reloaded health configuration.
+func (c *Checker) SetConfig(cfg config.HealthConfig) {
+	c.mu.Lock()
+	c.cfg = cfg
+	c.mu.Unlock()
+}
+
+// Check builds a readiness report.
+func (c *Checker) Check(ctx context.Context) Report {
+	now := time.Now()
+	report := Report{CheckedAt: now}
+	var auths []*coreauth.Auth
+	if c.manager != nil {
+		auths = c.manager.List()
+	}
+	c.mu.Lock()
+	identities := c.cfg.IncludeIdentities
+	c.mu.Unlock()
+	readyByProvider := make(map[string]*coreauth.Auth)
+	for _, a := range auths {
+		account := accountReport(a, now, identities)
+		if account.Status == StatusReady {
+			report.ReadyAccounts++
+			if _, ok := readyByProvider[account.Provider]; !ok {
+				readyByProvider[account.Provider] = a
+			}
+		}
+		report.Accounts = append(report.Accounts, account)
+	}
+	sort.Slice(report.Accounts, func(i, j int) bool {
+		if report.Accounts[i].Provider != report.Accounts[j].Provider {
+			return report.Accounts[i].Provider < report.Accounts[j].Provider
+		}
+		return report.Accounts[i].Index < report.Accounts[j].Index
+	})
+
+	report.Pings = c.pingProviders(ctx, readyByProvider, now)
+	report.Ready = report.ReadyAccounts > 0
+	if report.Ready && len(report.Pings) > 0 {
+		reachable := false
+		for _, ping := range report.Pings {
+			reachable = reachable || ping.Reachable
+		}
+		report.Ready = reachable
+	}
+	return report
+}
+
+func accountReport(a *coreauth.Auth, now time.Time, identities bool) AccountReport {
+	account := AccountReport{
+		Index:    a.EnsureIndex(),
+		Provider: strings.ToLower(strings.TrimSpace(a.Provider)),
+		Status:   StatusReady,
+		Message:  a.StatusMessage,
+	}
+	if identities {
+		account.ID = a.ID
+		account.Label = a.Label
+	}
+	if expiresAt, ok := a.ExpirationTime(); ok {
+		account.TokenExpiresAt = &expiresAt
+	}
+	switch {
+	case a.Disabled || a.Status == coreauth.StatusDisabled:
+		account.Status = StatusDisabled
+	case account.TokenExpiresAt != nil && !account.TokenExpiresAt.After(now):
+		account.Status = StatusExpired
+	case a.Unavailable && a.NextRetryAfter.After(now):
+		account.Status = StatusCooldown
+		retryAfter := a.NextRetryAfter
+		account.RetryAfter = &retryAfter
+	case a.Status == coreauth.StatusError && a.NextRetryAfter.After(now):
+		account.Status = StatusError
+		retryAfter := a.NextRetryAfter
+		account.RetryAfter = &retryAfter
+	}
+	return account
+}
+
+// pingProviders returns ping results for every configured provider, refreshing
+// results older than the ping interval using one ready credential per provider.
+func (c *Checker) pingProviders(ctx context.Context, ready map[string]*coreauth.Auth, now time.Time) []PingReport {
+	c.mu.Lock()
+	targets := make(map[string]string, len(c.cfg.PingURLs))
+	for provider, url := range c.cfg.PingURLs {
+		if url = strings.TrimSpace(url); url != "" {
+			targets[strings.ToLower(strings.TrimSpace(provider))] = url
+		}
+	}
+	interval := time.Duration(c.cfg.PingIntervalSeconds) * time.Second
+	c.mu.Unlock()
+	if interval <= 0 {
+		interval = defaultPingInterval
+	}
+
+	providers := make([]string, 0, len(targets))
+	for provider := range targets {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	results := make([]PingReport, 0, len(providers))
+	for _, provider := range providers {
+		url := targets[provider]
+		c.mu.Lock()
+		cached, ok := c.pings[provider]
+		c.mu.Unlock()
+		if ok && cached.URL == url && now.Sub(cached.CheckedAt) < interval {
+			results = append(results, cached)
+			continue
+		}
+		result := c.ping(ctx, provider, url, ready[provider], now)
+		c.mu.Lock()
+		c.pings[provider] = result
+		c.mu.Unlock()
+		results = append(results, result)
+	}
+	return results
+}
+
+func (c *Checker) ping(ctx context.Context, provider, url string, auth *coreauth.Auth, now time.Time) PingReport {
+	result := PingReport{Provider: provider, URL: url, CheckedAt: now}
+	if auth == nil {
+		result.Error = "no ready account"
+		return result
+	}
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	req, err := c.manager.NewHttpRequest(ctx, auth, http.MethodGet, url, nil, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp, err := c.manager.HttpRequest(ctx, auth, req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if errClose := resp.Body.Close(); errClose != nil {
+		log.Debugf("health: close ping response body error: %v", errClose)
+	}
+	result.StatusCode = resp.StatusCode
+	// Any answer other than an auth failure or server error proves the
+	// upstream is up and accepts the credential.
+	result.Reachable = resp.StatusCode < http.StatusInternalServerError &&
+		resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden
+	return result
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestChecker_ReportsAccountStatuses(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	auths := []*coreauth.Auth{
+		{ID: "disabled", Provider: "claude", Disabled: true, Status: coreauth.StatusDisabled},
+		{ID: "expired", Provider: "claude", Status: coreauth.StatusActive, Metadata: map[string]any{"expired": past}},
+		{ID: "cooling", Provider: "claude", Status: coreauth.StatusActive, Unavailable: true, NextRetryAfter: time.Now().Add(time.Minute)},
+	}
+	for _, a := range auths {
+		if _, err := manager.Register(ctx, a); err != nil {
+			t.Fatalf("register %s: %v", a.ID, err)
+		}
+	}
+
+	checker := NewChecker(manager, config.HealthConfig{IncludeIdentities: true})
+	report := checker.Check(ctx)
+	if report.Ready || report.ReadyAccounts != 0 {
+		t.Fatalf("expected not ready, got %+v", report)
+	}
+	want := map[string]string{"disabled": StatusDisabled, "expired": StatusExpired, "cooling": StatusCooldown}
+	for _, account := range report.Accounts {
+		if account.Status != want[account.ID] {
+			t.Fatalf("account %s status = %s, want %s", account.ID, account.Status, want[account.ID])
+		}
+	}
+
+	if _, err := manager.Register(ctx, &coreauth.Auth{ID: "ok", Provider: "gemini", Status: coreauth.StatusActive}); err != nil {
+		t.Fatalf("register ok: %v", err)
+	}
+	checker.SetConfig(config.HealthConfig{})
+	report = checker.Check(ctx)
+	if !report.Ready || report.ReadyAccounts != 1 {
+		t.Fatalf("expected ready with one account, got %+v", report)
+	}
+	for _, account := range report.Accounts {
+		if account.ID != "" || account.Index == "" {
+			t.Fatalf("identities must be hidden by default: %+v", account)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package inspect keeps a bounded in-memory history of recent API requests for
+// the request inspector: source format, resolved model, latency, token usage,
+// stop reason and the actions taken by payload passes, together with the
+// request as forwarded and the response as returned to the client.
+package inspect
+
+import (
+	"sync"
+	"time"
+)
+
+// Usage holds the token counts reported by the upstream.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
+
+// Record describes one request. Bodies are only returned by Store.Get.
+type Record struct {
+	ID            string         `json:"id"`
+	Time          time.Time      `json:"time"`
+	Format        string         `json:"format"`
+	Model         string         `json:"model"`
+	UpstreamModel string         `json:"upstream_model,omitempty"`
+	Providers     []string       `json:"providers,omitempty"`
+	Stream        bool           `json:"stream"`
+	Status        int            `json:"status"`
+	LatencyMS     int64          `json:"latency_ms"`
+	Usage         Usage          `json:"usage"`
+	StopReason    string         `json:"stop_reason,omitempty"`
+	Actions       map[string]any `json:"actions,omitempty"`
+	Error         string         `json:"error,omitempty"`
+
+	RequestTruncated  bool `json:"request_truncated,omitempty"`
+	ResponseTruncated bool `json:"response_truncated,omitempty"`
+
+	request  []byte
+	response []byte
+}
+
+// SetRequest stores the forwarded request body, keeping at most maxBytes.
+func (r *Record) SetRequest(body []byte, maxBytes int) {
+	r.request, r.RequestTruncated = capBody(nil, body, maxBytes)
+}
+
+// AppendResponse appends returned response bytes, keeping at most maxBytes in total.
+func (r *Record) AppendResponse(body []byte, maxBytes int) {
+	var truncated bool
+	r.response, truncated = capBody(r.response, body, maxBytes)
+	r.ResponseTruncated = r.ResponseTruncated || truncated
+}
+
+func capBody(dst, body []byte, maxBytes int) ([]byte, bool) {
+	if maxBytes > 0 && len(dst)+len(body) > maxBytes {
+		return append(dst, body[:max(0, maxBytes-len(dst))]...), true
+	}
+	return append(dst, body...), false
+}
+
+// Store is a fixed-size ring of recent records.
+type Store struct {
+	mu      sync.Mutex
+	records []*Record
+	next    int
+	limit   int
+}
+
+var defaultStore = &Store{}
+
+// Default returns the process-wide store used by the API handlers.
+func Default() *Store { return defaultStore }
+
+// Add stores a finished record, evicting the oldest once limit records are held.
+func (s *Store) Add(record *Record, limit int) {
+	if record == nil || limit <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit != limit {
+		s.resize(limit)
+	}
+	if len(s.records) < limit {
+		s.records = append(s.records, record)
+		return
+	}
+	s.records[s.next] = record
+	s.next = (s.next + 1) % limit
+}
+
+// resize keeps the newest records when the configured limit changes.
+func (s *Store) resize(limit int) {
+	ordered := s.ordered()
+	if len(ordered) > limit {
+		ordered = ordered[len(ordered)-limit:]
+	}
+	s.records, s.next, s.limit = ordered, 0, limit
+}
+
+// ordered returns the records from oldest to newest. Callers hold mu.
+func (s *Store) ordered() []*Record {
+	out := make([]*Record, 0, len(s.records))
+	out = append(out, s.records[s.next:]...)
+	return append(out, s.records[:s.next]...)
+}
+
+// List returns copies of the stored records without bodies, newest first.
+func (s *Store) List() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ordered := s.ordered()
+	out := make([]Record, 0, len(ordered))
+	for i := len(ordered) - 1; i >= 0; i-- {
+		record := *ordered[i]
+		record.request, record.response = nil, nil
+		out = append(out, record)
+	}
+	return out
+}
+
+// Get returns the record with the given ID and its request and response bodies.
+func (s *Store) Get(id string) (Record, []byte, []byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.records {
+		if record.ID == id {
+			return *record, record.request, record.response, true
+		}
+	}
+	return Record{}, nil, nil, false
+}
+
+// Clear removes all records.
+func (s *Store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records, s.next, s.limit = nil, 0, 0
+}
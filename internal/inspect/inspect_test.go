@@ -0,0 +1,46 @@
+package inspect
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStoreKeepsNewestRecords(t *testing.T) {
+	store := &Store{}
+	for i := 0; i < 5; i++ {
+		record := &Record{ID: fmt.Sprintf("r%d", i)}
+		record.SetRequest([]byte(`{"model":"m"}`), 5)
+		store.Add(record, 3)
+	}
+	list := store.List()
+	if len(list) != 3 || list[0].ID != "r4" || list[2].ID != "r2" {
+		t.Fatalf("list = %+v", list)
+	}
+	_, request, _, ok := store.Get("r3")
+	if !ok || string(request) != `{"mod` {
+		t.Fatalf("request = %q (found %v)", request, ok)
+	}
+	if _, _, _, ok = store.Get("r1"); ok {
+		t.Fatalf("evicted record is still returned")
+	}
+
+	store.Add(&Record{ID: "r5"}, 2)
+	if list = store.List(); len(list) != 2 || list[0].ID != "r5" || list[1].ID != "r4" {
+		t.Fatalf("after shrinking: %+v", list)
+	}
+}
+
+func TestObserveStreamUsageAndStopReason(t *testing.T) {
+	record := &Record{}
+	record.Observe("claude", []byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n"))
+	record.Observe("claude", []byte("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"},\"usage\":{\"output_tokens\":40}}\n\n"))
+	if record.Usage != (Usage{InputTokens: 12, OutputTokens: 40, TotalTokens: 52}) || record.StopReason != "tool_use" {
+		t.Fatalf("claude: usage=%+v stop=%q", record.Usage, record.StopReason)
+	}
+
+	record = &Record{}
+	record.Observe("openai", []byte(`{"choices":[{"message":{"content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`))
+	if record.Usage.TotalTokens != 5 || record.StopReason != "stop" {
+		t.Fatalf("openai: usage=%+v stop=%q", record.Usage, record.StopReason)
+	}
+}
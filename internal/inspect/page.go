@@ -0,0 +1,9 @@
+package inspect
+
+import _ "embed"
+
+//go:embed inspector.html
+var page []byte
+
+// Page returns the embedded request inspector web page.
+func Page() []byte { return page }
@@ -0,0 +1,83 @@
+package inspect
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+)
+
+// usagePaths lists, per response schema, where input, output and total token
+// counts are reported in response bodies and stream events.
+var usagePaths = map[string][3][]string{
+	"openai": {
+		{"usage.prompt_tokens"},
+		{"usage.completion_tokens"},
+		{"usage.total_tokens"},
+	},
+	"claude": {
+		{"usage.input_tokens", "message.usage.input_tokens"},
+		{"usage.output_tokens", "message.usage.output_tokens"},
+		nil,
+	},
+	"openai-response": {
+		{"usage.input_tokens", "response.usage.input_tokens"},
+		{"usage.output_tokens", "response.usage.output_tokens"},
+		{"usage.total_tokens", "response.usage.total_tokens"},
+	},
+	"gemini": {
+		{"usageMetadata.promptTokenCount", "response.usageMetadata.promptTokenCount"},
+		{"usageMetadata.candidatesTokenCount", "response.usageMetadata.candidatesTokenCount"},
+		{"usageMetadata.totalTokenCount", "response.usageMetadata.totalTokenCount"},
+	},
+}
+
+// stopReasonPaths lists where each response schema reports why generation stopped.
+var stopReasonPaths = map[string][]string{
+	"openai":          {"choices.0.finish_reason"},
+	"claude":          {"stop_reason", "delta.stop_reason"},
+	"openai-response": {"response.status", "status"},
+	"gemini":          {"candidates.0.finishReason", "response.candidates.0.finishReason"},
+}
+
+// Observe updates usage and stop reason from a response body or stream chunk
+// of the given schema. Stream chunks may be bare JSON or SSE lines; later
+// non-empty values win, so cumulative usage in final events is kept.
+func (r *Record) Observe(format string, payload []byte) {
+	if format == "gemini-cli" {
+		format = "gemini"
+	}
+	if gjson.ValidBytes(payload) {
+		r.observeJSON(format, payload)
+		return
+	}
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		data, found := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if data = bytes.TrimSpace(data); found && gjson.ValidBytes(data) {
+			r.observeJSON(format, data)
+		}
+	}
+}
+
+func (r *Record) observeJSON(format string, payload []byte) {
+	root := gjson.ParseBytes(payload)
+	if paths, ok := usagePaths[format]; ok {
+		targets := [3]*int64{&r.Usage.InputTokens, &r.Usage.OutputTokens, &r.Usage.TotalTokens}
+		for i, candidates := range paths {
+			for _, path := range candidates {
+				if value := root.Get(path); value.Exists() && value.Int() > 0 {
+					*targets[i] = value.Int()
+					break
+				}
+			}
+		}
+		if len(paths[2]) == 0 {
+			r.Usage.TotalTokens = r.Usage.InputTokens + r.Usage.OutputTokens
+		}
+	}
+	for _, path := range stopReasonPaths[format] {
+		if value := root.Get(path).String(); value != "" && value != "in_progress" {
+			r.StopReason = value
+			break
+		}
+	}
+}
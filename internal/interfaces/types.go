@@ -8,6 +8,8 @@ import sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 // Backwards compatible aliases for translator function types.
 type TranslateRequestFunc = sdktranslator.RequestTransform
 
+type TranslateRequestContextFunc = sdktranslator.RequestContextTransform
+
 type TranslateResponseFunc = sdktranslator.ResponseStreamTransform
 
 type TranslateResponseNonStreamFunc = sdktranslator.ResponseNonStreamTransform
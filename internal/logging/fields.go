@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Correlation fields attached to log entries of a request.
+const (
+	FieldRequestID = "request_id"
+	FieldAuthID    = "auth_id"
+	FieldModel     = "model"
+	FieldStage     = "stage"
+	FieldTenant    = "tenant"
+)
+
+// GinTenantKey is the gin context key holding the tenant of the calling API key.
+const GinTenantKey = "tenant"
+
+// Request processing stages reported in the FieldStage field.
+const (
+	StageRequest  = "request"  // client payload passes and translation to the upstream schema
+	StageUpstream = "upstream" // upstream execution with a selected account
+	StageResponse = "response" // translation and post-processing of the upstream response
+)
+
+// logFieldsKey is the context key for correlation fields.
+type logFieldsKey struct{}
+
+// WithLogFields returns a context whose log entries (see Entry) carry fields in
+// addition to those already attached to ctx. Later values win.
+func WithLogFields(ctx context.Context, fields log.Fields) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	existing, _ := ctx.Value(logFieldsKey{}).(log.Fields)
+	merged := make(log.Fields, len(existing)+len(fields))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+// Entry returns a log entry tagged with the request ID and the correlation
+// fields attached to ctx.
+func Entry(ctx context.Context) *log.Entry {
+	entry := log.NewEntry(log.StandardLogger())
+	if ctx == nil {
+		return entry
+	}
+	if fields, ok := ctx.Value(logFieldsKey{}).(log.Fields); ok && len(fields) > 0 {
+		entry = entry.WithFields(fields)
+	}
+	if requestID := GetRequestID(ctx); requestID != "" {
+		entry = entry.WithField(FieldRequestID, requestID)
+	}
+	return entry
+}
@@ -83,6 +83,9 @@ func GinLogrusLogger() gin.HandlerFunc {
 		}
 
 		entry := log.WithField("request_id", requestID)
+		if tenant := c.GetString(GinTenantKey); tenant != "" {
+			entry = entry.WithField(FieldTenant, tenant)
+		}
 
 		switch {
 		case statusCode >= http.StatusInternalServerError:
@@ -30,7 +30,7 @@ var (
 type LogFormatter struct{}
 
 // logFieldOrder defines the display order for common log fields.
-var logFieldOrder = []string{"provider", "model", "mode", "budget", "level", "original_mode", "original_value", "min", "max", "clamped_to", "error"}
+var logFieldOrder = []string{"auth_id", "provider", "model", "stage", "mode", "budget", "level", "original_mode", "original_value", "min", "max", "clamped_to", "error"}
 
 // Format renders a single log entry with custom formatting.
 func (m *LogFormatter) Format(entry *log.Entry) ([]byte, error) {
@@ -86,7 +86,7 @@ func SetupBaseLogger() {
 	setupOnce.Do(func() {
 		log.SetOutput(os.Stdout)
 		log.SetReportCaller(true)
-		log.SetFormatter(&LogFormatter{})
+		log.SetFormatter(newSettingsFormatter())
 
 		ginInfoWriter = log.StandardLogger().Writer()
 		gin.DefaultWriter = ginInfoWriter
@@ -164,7 +164,7 @@ func ConfigureLogOutput(cfg *config.Config) error {
 			MaxSize:    10,
 			MaxBackups: 0,
 			MaxAge:     0,
-			Compress:   false,
+			Compress:   cfg.LogRetention.Compress,
 		}
 		log.SetOutput(logWriter)
 	} else {
@@ -175,7 +175,7 @@ func ConfigureLogOutput(cfg *config.Config) error {
 		log.SetOutput(os.Stdout)
 	}
 
-	configureLogDirCleanerLocked(logDir, cfg.LogsMaxTotalSizeMB, protectedPath)
+	configureLogDirCleanerLocked(logDir, cfg.LogsMaxTotalSizeMB, protectedPath, cfg.LogRetention)
 	return nil
 }
 
@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// modulePathPrefix is stripped from caller package paths before matching
+// per-module level overrides.
+const modulePathPrefix = "github.com/router-for-me/CLIProxyAPI/v6/"
+
+type moduleLevel struct {
+	module string
+	level  log.Level
+}
+
+// logSettings holds the active level filter and output format.
+type logSettings struct {
+	base    log.Level
+	modules []moduleLevel
+	json    bool
+}
+
+var activeLogSettings atomic.Pointer[logSettings]
+
+// SetLogLevel applies the configured base level (debug or info), the
+// per-module overrides and the output format. The logrus level is raised to
+// the most verbose override so that entries of those modules reach the
+// formatter, which drops entries below their module's level.
+func SetLogLevel(cfg *config.Config) {
+	settings := &logSettings{base: log.InfoLevel, json: cfg.Logging.JSON}
+	if cfg.Debug {
+		settings.base = log.DebugLevel
+	}
+	newLevel := settings.base
+	for module, name := range cfg.Logging.Levels {
+		module = strings.Trim(strings.TrimSpace(module), "/")
+		level, err := log.ParseLevel(strings.TrimSpace(name))
+		if module == "" || err != nil {
+			log.Warnf("logging: ignoring level override %q: %q", module, name)
+			continue
+		}
+		settings.modules = append(settings.modules, moduleLevel{module: module, level: level})
+		if level > newLevel {
+			newLevel = level
+		}
+	}
+	// Longer (more specific) module paths win.
+	sort.Slice(settings.modules, func(i, j int) bool {
+		return len(settings.modules[i].module) > len(settings.modules[j].module)
+	})
+	activeLogSettings.Store(settings)
+
+	currentLevel := log.GetLevel()
+	if currentLevel != newLevel {
+		log.SetLevel(newLevel)
+		log.Infof("log level changed from %s to %s (debug=%t)", currentLevel, newLevel, cfg.Debug)
+	}
+}
+
+// allows reports whether entry meets the level of the module that logged it.
+func (s *logSettings) allows(entry *log.Entry) bool {
+	if len(s.modules) == 0 {
+		return true
+	}
+	level := s.base
+	if pkg := callerPackage(entry.Caller); pkg != "" {
+		for _, override := range s.modules {
+			if pkg == override.module || strings.HasPrefix(pkg, override.module+"/") || filepath.Base(pkg) == override.module {
+				level = override.level
+				break
+			}
+		}
+	}
+	return entry.Level <= level
+}
+
+// callerPackage returns the package path of the logging call relative to the
+// module root, e.g. "sdk/cliproxy/auth".
+func callerPackage(frame *runtime.Frame) string {
+	if frame == nil || frame.Function == "" {
+		return ""
+	}
+	fn := frame.Function
+	slash := strings.LastIndex(fn, "/")
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		fn = fn[:slash+1+dot]
+	}
+	return strings.TrimPrefix(fn, modulePathPrefix)
+}
+
+// settingsFormatter applies the active logSettings and renders entries as text
+// (LogFormatter) or JSON.
+type settingsFormatter struct {
+	text LogFormatter
+	json log.JSONFormatter
+}
+
+func newSettingsFormatter() *settingsFormatter {
+	return &settingsFormatter{json: log.JSONFormatter{
+		TimestampFormat: time.RFC3339Nano,
+		FieldMap:        log.FieldMap{log.FieldKeyMsg: "message", log.FieldKeyFile: "caller"},
+		CallerPrettyfier: func(frame *runtime.Frame) (string, string) {
+			return "", fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		},
+	}}
+}
+
+// Format renders entry, or returns no bytes when its module's level filters it out.
+func (f *settingsFormatter) Format(entry *log.Entry) ([]byte, error) {
+	settings := activeLogSettings.Load()
+	if settings == nil {
+		return f.text.Format(entry)
+	}
+	if !settings.allows(entry) {
+		return nil, nil
+	}
+	if settings.json {
+		return f.json.Format(entry)
+	}
+	return f.text.Format(entry)
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSettingsFormatterModuleLevelsAndJSON(t *testing.T) {
+	previous := log.GetLevel()
+	t.Cleanup(func() {
+		activeLogSettings.Store(nil)
+		log.SetLevel(previous)
+	})
+
+	SetLogLevel(&config.Config{Logging: config.LoggingConfig{
+		JSON:   true,
+		Levels: map[string]string{"sdk/cliproxy/auth": "debug", "executor": "warn"},
+	}})
+	if log.GetLevel() != log.DebugLevel {
+		t.Fatalf("logrus level = %s, want debug for the auth override", log.GetLevel())
+	}
+
+	ctx := WithRequestID(context.Background(), "a1b2c3d4")
+	ctx = WithLogFields(ctx, log.Fields{FieldModel: "gpt-5"})
+	ctx = WithLogFields(ctx, log.Fields{FieldAuthID: "auth-1", FieldStage: StageUpstream})
+	entryAt := func(function string, level log.Level) *log.Entry {
+		entry := Entry(ctx)
+		entry.Level = level
+		entry.Message = "hello"
+		entry.Caller = &runtime.Frame{Function: modulePathPrefix + function, File: "/src/x.go", Line: 7}
+		return entry
+	}
+
+	formatter := newSettingsFormatter()
+	cases := []struct {
+		function string
+		level    log.Level
+		emitted  bool
+	}{
+		{"sdk/cliproxy/auth.(*Manager).Execute", log.DebugLevel, true},
+		{"internal/runtime/executor.(*ClaudeExecutor).Execute", log.InfoLevel, false},
+		{"internal/runtime/executor.(*ClaudeExecutor).Execute", log.WarnLevel, true},
+		{"sdk/api/handlers.(*BaseAPIHandler).ExecuteWithAuthManager", log.DebugLevel, false},
+		{"sdk/api/handlers.(*BaseAPIHandler).ExecuteWithAuthManager", log.InfoLevel, true},
+	}
+	for _, tc := range cases {
+		out, err := formatter.Format(entryAt(tc.function, tc.level))
+		if err != nil {
+			t.Fatalf("format: %v", err)
+		}
+		if emitted := len(out) > 0; emitted != tc.emitted {
+			t.Fatalf("%s at %s: emitted = %v, want %v", tc.function, tc.level, emitted, tc.emitted)
+		}
+	}
+
+	out, _ := formatter.Format(entryAt("sdk/cliproxy/auth.(*Manager).Execute", log.InfoLevel))
+	var line map[string]any
+	if err := json.Unmarshal(out, &line); err != nil {
+		t.Fatalf("output is not JSON: %s", out)
+	}
+	want := map[string]any{"request_id": "a1b2c3d4", "auth_id": "auth-1", "model": "gpt-5", "stage": "upstream", "message": "hello"}
+	for key, value := range want {
+		if line[key] != value {
+			t.Fatalf("%s = %v, want %v (line %s)", key, line[key], value, out)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,15 +16,14 @@ const logDirCleanerInterval = time.Minute
 
 var logDirCleanerCancel context.CancelFunc
 
-func configureLogDirCleanerLocked(logDir string, maxTotalSizeMB int, protectedPath string) {
+func configureLogDirCleanerLocked(logDir string, maxTotalSizeMB int, protectedPath string, retention config.LogRetentionConfig) {
 	stopLogDirCleanerLocked()
 
-	if maxTotalSizeMB <= 0 {
-		return
+	maxBytes := int64(0)
+	if maxTotalSizeMB > 0 {
+		maxBytes = int64(maxTotalSizeMB) * 1024 * 1024
 	}
-
-	maxBytes := int64(maxTotalSizeMB) * 1024 * 1024
-	if maxBytes <= 0 {
+	if maxBytes <= 0 && !retentionEnabled(retention) {
 		return
 	}
 
@@ -34,7 +34,7 @@ func configureLogDirCleanerLocked(logDir string, maxTotalSizeMB int, protectedPa
 
 	ctx, cancel := context.WithCancel(context.Background())
 	logDirCleanerCancel = cancel
-	go runLogDirCleaner(ctx, filepath.Clean(dir), maxBytes, strings.TrimSpace(protectedPath))
+	go runLogDirCleaner(ctx, filepath.Clean(dir), maxBytes, strings.TrimSpace(protectedPath), retention)
 }
 
 func stopLogDirCleanerLocked() {
@@ -45,11 +45,24 @@ func stopLogDirCleanerLocked() {
 	logDirCleanerCancel = nil
 }
 
-func runLogDirCleaner(ctx context.Context, logDir string, maxBytes int64, protectedPath string) {
+func runLogDirCleaner(ctx context.Context, logDir string, maxBytes int64, protectedPath string, retention config.LogRetentionConfig) {
 	ticker := time.NewTicker(logDirCleanerInterval)
 	defer ticker.Stop()
 
+	rotateEvery := time.Duration(retention.RotateIntervalHours) * time.Hour
+	lastRotate := time.Now()
+	highWater := false
+
 	cleanOnce := func() {
+		if rotateEvery > 0 && time.Since(lastRotate) >= rotateEvery {
+			rotateMainLog()
+			lastRotate = time.Now()
+		}
+		if removed, compressed, errRetention := applyLogRetention(logDir, protectedPath, retention, time.Now()); errRetention != nil {
+			log.WithError(errRetention).Warn("logging: failed to apply log retention")
+		} else if removed > 0 || compressed > 0 {
+			log.Debugf("logging: retention removed %d and compressed %d log file(s)", removed, compressed)
+		}
 		deleted, errClean := enforceLogDirSizeLimit(logDir, maxBytes, protectedPath)
 		if errClean != nil {
 			log.WithError(errClean).Warn("logging: failed to enforce log directory size limit")
@@ -58,6 +71,11 @@ func runLogDirCleaner(ctx context.Context, logDir string, maxBytes int64, protec
 		if deleted > 0 {
 			log.Debugf("logging: removed %d old log file(s) to enforce log directory size limit", deleted)
 		}
+		usage := MeasureLogDirUsage(logDir, maxBytes, retention.HighWaterMarkPercent)
+		if usage.AboveHighWater && !highWater {
+			log.Warnf("logging: log directory usage %d bytes exceeds %d%% of the %d byte limit", usage.TotalBytes, retention.HighWaterMarkPercent, maxBytes)
+		}
+		highWater = usage.AboveHighWater
 	}
 
 	cleanOnce()
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
 func TestEnforceLogDirSizeLimitDeletesOldest(t *testing.T) {
@@ -68,3 +70,46 @@ func writeLogFile(t *testing.T, path string, size int, modTime time.Time) {
 		t.Fatalf("set times: %v", err)
 	}
 }
+
+func TestApplyLogRetentionPerArtifact(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	protected := filepath.Join(dir, "main.log")
+	writeLogFile(t, protected, 10, now.Add(-100*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "main-2025-01-01T00-00-00.000.log"), 10, now.Add(-50*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "main-2025-01-02T00-00-00.000.log"), 10, now.Add(-1*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "error-v1-a.log"), 10, now.Add(-3*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "error-v1-b.log"), 10, now.Add(-2*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "v1-chat-completions-old.log"), 10, now.Add(-2*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "v1-chat-completions-new.log"), 10, now.Add(-time.Minute))
+
+	retention := config.LogRetentionConfig{
+		Compress: true,
+		Main:     config.LogRetentionPolicy{MaxAgeHours: 24},
+		Error:    config.LogRetentionPolicy{MaxFiles: 1},
+	}
+	removed, compressed, err := applyLogRetention(dir, protected, retention, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 2 || compressed != 1 {
+		t.Fatalf("expected 2 removed and 1 compressed, got %d and %d", removed, compressed)
+	}
+
+	for _, gone := range []string{"main-2025-01-01T00-00-00.000.log", "error-v1-a.log", "v1-chat-completions-old.log"} {
+		if _, errStat := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(errStat) {
+			t.Fatalf("expected %s to be gone, stat error: %v", gone, errStat)
+		}
+	}
+	for _, kept := range []string{"main.log", "main-2025-01-02T00-00-00.000.log", "error-v1-b.log", "v1-chat-completions-old.log.gz", "v1-chat-completions-new.log"} {
+		if _, errStat := os.Stat(filepath.Join(dir, kept)); errStat != nil {
+			t.Fatalf("expected %s to remain, stat error: %v", kept, errStat)
+		}
+	}
+
+	usage := MeasureLogDirUsage(dir, 40, 50)
+	if usage.Files != 5 || !usage.AboveHighWater {
+		t.Fatalf("unexpected usage snapshot: %+v", usage)
+	}
+}
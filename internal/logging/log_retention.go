@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Log artifact types used for retention policies and usage reporting.
+const (
+	LogArtifactMain    = "main"
+	LogArtifactRequest = "request"
+	LogArtifactError   = "error"
+)
+
+// defaultMainLogName is the active application log; it is never subject to retention.
+const defaultMainLogName = "main.log"
+
+// requestLogCompressAge is how long a request log stays uncompressed so that
+// in-flight streaming writers and recent lookups are not disturbed.
+const requestLogCompressAge = time.Hour
+
+// LogDirUsage reports disk usage of the logs directory.
+type LogDirUsage struct {
+	Dir            string           `json:"dir"`
+	TotalBytes     int64            `json:"total-bytes"`
+	Files          int              `json:"files"`
+	ByArtifact     map[string]int64 `json:"by-artifact"`
+	LimitBytes     int64            `json:"limit-bytes"`
+	HighWaterMark  int              `json:"high-water-mark-percent"`
+	AboveHighWater bool             `json:"above-high-water"`
+	UpdatedAt      time.Time        `json:"updated-at"`
+}
+
+func retentionEnabled(r config.LogRetentionConfig) bool {
+	policyEnabled := func(p config.LogRetentionPolicy) bool { return p.MaxAgeHours > 0 || p.MaxFiles > 0 }
+	return r.RotateIntervalHours > 0 || r.Compress || r.HighWaterMarkPercent > 0 ||
+		policyEnabled(r.Main) || policyEnabled(r.Request) || policyEnabled(r.Error)
+}
+
+// rotateMainLog forces the rotating main.log writer to start a new segment.
+func rotateMainLog() {
+	writerMu.Lock()
+	defer writerMu.Unlock()
+	if logWriter == nil {
+		return
+	}
+	if errRotate := logWriter.Rotate(); errRotate != nil {
+		log.WithError(errRotate).Warn("logging: failed to rotate main log")
+	}
+}
+
+// logArtifactType classifies a log file name. The active main.log is not an
+// artifact and yields an empty type.
+func logArtifactType(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case lower == defaultMainLogName:
+		return ""
+	case strings.HasPrefix(lower, "main-"):
+		return LogArtifactMain
+	case strings.HasPrefix(lower, "error-"):
+		return LogArtifactError
+	default:
+		return LogArtifactRequest
+	}
+}
+
+type retainedFile struct {
+	path    string
+	name    string
+	modTime time.Time
+}
+
+// applyLogRetention enforces per-artifact age/count limits and compresses old
+// request logs when enabled. It returns the number of removed and compressed files.
+func applyLogRetention(logDir, protectedPath string, retention config.LogRetentionConfig, now time.Time) (int, int, error) {
+	entries, errRead := os.ReadDir(logDir)
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return 0, 0, nil
+		}
+		return 0, 0, errRead
+	}
+	protected := ""
+	if protectedPath != "" {
+		protected = filepath.Clean(protectedPath)
+	}
+
+	groups := make(map[string][]retainedFile)
+	for _, entry := range entries {
+		if entry.IsDir() || !isLogFileName(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(logDir, entry.Name())
+		if protected != "" && path == protected {
+			continue
+		}
+		kind := logArtifactType(entry.Name())
+		if kind == "" {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		groups[kind] = append(groups[kind], retainedFile{path: path, name: entry.Name(), modTime: info.ModTime()})
+	}
+
+	policies := map[string]config.LogRetentionPolicy{
+		LogArtifactMain:    retention.Main,
+		LogArtifactRequest: retention.Request,
+		LogArtifactError:   retention.Error,
+	}
+	removed, compressed := 0, 0
+	for kind, files := range groups {
+		policy := policies[kind]
+		// Newest first so MaxFiles keeps the most recent files.
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+		kept := files[:0]
+		for i, file := range files {
+			expired := policy.MaxAgeHours > 0 && now.Sub(file.modTime) > time.Duration(policy.MaxAgeHours)*time.Hour
+			overflow := policy.MaxFiles > 0 && i >= policy.MaxFiles
+			if !expired && !overflow {
+				kept = append(kept, file)
+				continue
+			}
+			if errRemove := os.Remove(file.path); errRemove != nil && !os.IsNotExist(errRemove) {
+				log.WithError(errRemove).Warnf("logging: failed to remove expired log file: %s", file.name)
+				continue
+			}
+			removed++
+		}
+		if !retention.Compress || kind != LogArtifactRequest {
+			continue
+		}
+		for _, file := range kept {
+			if strings.HasSuffix(strings.ToLower(file.name), ".gz") || now.Sub(file.modTime) < requestLogCompressAge {
+				continue
+			}
+			if errCompress := gzipLogFile(file.path); errCompress != nil {
+				log.WithError(errCompress).Warnf("logging: failed to compress log file: %s", file.name)
+				continue
+			}
+			compressed++
+		}
+	}
+	return removed, compressed, nil
+}
+
+// gzipLogFile replaces path with path.gz, preserving the modification time so
+// age-based retention keeps working on the compressed segment.
+func gzipLogFile(path string) error {
+	src, errOpen := os.Open(path)
+	if errOpen != nil {
+		return errOpen
+	}
+	defer func() { _ = src.Close() }()
+	info, errStat := src.Stat()
+	if errStat != nil {
+		return errStat
+	}
+
+	dstPath := path + ".gz"
+	dst, errCreate := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if errCreate != nil {
+		return errCreate
+	}
+	gz := gzip.NewWriter(dst)
+	gz.Name = filepath.Base(path)
+	gz.ModTime = info.ModTime()
+	if _, errCopy := io.Copy(gz, src); errCopy != nil {
+		_ = gz.Close()
+		_ = dst.Close()
+		_ = os.Remove(dstPath)
+		return errCopy
+	}
+	if errClose := gz.Close(); errClose != nil {
+		_ = dst.Close()
+		_ = os.Remove(dstPath)
+		return errClose
+	}
+	if errClose := dst.Close(); errClose != nil {
+		_ = os.Remove(dstPath)
+		return errClose
+	}
+	_ = os.Chtimes(dstPath, info.ModTime(), info.ModTime())
+	_ = src.Close()
+	return os.Remove(path)
+}
+
+// MeasureLogDirUsage computes disk usage of logDir grouped by artifact type and
+// evaluates the high-water mark against maxBytes.
+func MeasureLogDirUsage(logDir string, maxBytes int64, highWaterPercent int) LogDirUsage {
+	usage := LogDirUsage{
+		Dir:           logDir,
+		ByArtifact:    make(map[string]int64),
+		LimitBytes:    maxBytes,
+		HighWaterMark: highWaterPercent,
+		UpdatedAt:     time.Now(),
+	}
+	if entries, errRead := os.ReadDir(logDir); errRead == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || !isLogFileName(entry.Name()) {
+				continue
+			}
+			info, errInfo := entry.Info()
+			if errInfo != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			kind := logArtifactType(entry.Name())
+			if kind == "" {
+				kind = LogArtifactMain
+			}
+			usage.ByArtifact[kind] += info.Size()
+			usage.TotalBytes += info.Size()
+			usage.Files++
+		}
+	}
+	if maxBytes > 0 && highWaterPercent > 0 {
+		usage.AboveHighWater = usage.TotalBytes*100 >= maxBytes*int64(highWaterPercent)
+	}
+	return usage
+}
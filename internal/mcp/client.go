@@ -0,0 +1,229 @@
+// Package mcp is a client for Model Context Protocol servers using the
+// Streamable HTTP transport. Tools exposed by configured servers are
+// registered as agent mode tools, so their calls are executed by the server
+// that provides them.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/tidwall/gjson"
+)
+
+const protocolVersion = "2025-03-26"
+
+// ToolInfo is a tool advertised by an MCP server.
+type ToolInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// Client talks to one MCP server.
+type Client struct {
+	url     string
+	headers map[string]string
+	http    *http.Client
+
+	nextID    atomic.Int64
+	mu        sync.Mutex
+	sessionID string
+}
+
+// NewClient creates a client for the MCP endpoint at url. headers are sent with
+// every request, e.g. for authorization.
+func NewClient(url string, headers map[string]string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{url: url, headers: headers, http: httpClient}
+}
+
+// Initialize performs the MCP handshake.
+func (c *Client) Initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "CLIProxyAPI", "version": buildinfo.Version},
+	}
+	if _, err := c.call(ctx, "initialize", params); err != nil {
+		return err
+	}
+	return c.notify(ctx, "notifications/initialized")
+}
+
+// ListTools returns all tools of the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		result, err := c.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err = json.Unmarshal(result, &page); err != nil {
+			return nil, fmt.Errorf("mcp: decode tools/list result: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool invokes a tool and returns its text content. isError reports a
+// tool-level failure, which is passed to the model rather than treated as a
+// transport error.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (text string, isError bool, err error) {
+	if len(bytes.TrimSpace(arguments)) == 0 {
+		arguments = json.RawMessage(`{}`)
+	}
+	result, err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments})
+	if err != nil {
+		return "", false, err
+	}
+	var parts []string
+	for _, item := range gjson.GetBytes(result, "content").Array() {
+		switch item.Get("type").String() {
+		case "text":
+			parts = append(parts, item.Get("text").String())
+		case "resource":
+			parts = append(parts, item.Get("resource.text").String())
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", item.Get("type").String()))
+		}
+	}
+	return strings.Join(parts, "\n"), gjson.GetBytes(result, "isError").Bool(), nil
+}
+
+// call sends a JSON-RPC request and returns its result.
+func (c *Client) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := c.nextID.Add(1)
+	resp, err := c.post(ctx, map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var message []byte
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		message, err = readEventStreamResponse(resp.Body, id)
+	} else {
+		message, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp: %s: %w", method, err)
+	}
+	if errObj := gjson.GetBytes(message, "error"); errObj.Exists() {
+		return nil, fmt.Errorf("mcp: %s: %s (code %d)", method, errObj.Get("message").String(), errObj.Get("code").Int())
+	}
+	result := gjson.GetBytes(message, "result")
+	if !result.Exists() {
+		return nil, fmt.Errorf("mcp: %s: response has no result", method)
+	}
+	return json.RawMessage(result.Raw), nil
+}
+
+// notify sends a JSON-RPC notification.
+func (c *Client) notify(ctx context.Context, method string) error {
+	resp, err := c.post(ctx, map[string]any{"jsonrpc": "2.0", "method": method})
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (c *Client) post(ctx context.Context, message any) (*http.Response, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	c.mu.Lock()
+	if c.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.sessionID)
+	}
+	c.mu.Unlock()
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mcp: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("mcp: server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if session := resp.Header.Get("Mcp-Session-Id"); session != "" {
+		c.mu.Lock()
+		c.sessionID = session
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// readEventStreamResponse returns the JSON-RPC response with the given id from
+// an SSE body, skipping server requests and notifications sent before it.
+func readEventStreamResponse(body io.Reader, id int64) ([]byte, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var data bytes.Buffer
+	flush := func() []byte {
+		defer data.Reset()
+		if data.Len() == 0 {
+			return nil
+		}
+		message := data.Bytes()
+		if gjson.GetBytes(message, "id").Int() == id && (gjson.GetBytes(message, "result").Exists() || gjson.GetBytes(message, "error").Exists()) {
+			return bytes.Clone(message)
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if message := flush(); message != nil {
+				return message, nil
+			}
+			continue
+		}
+		if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data.Write(bytes.TrimSpace(payload))
+		}
+	}
+	if message := flush(); message != nil {
+		return message, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agent"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// refreshInterval is how long tool lists are reused before servers are asked again.
+	refreshInterval = 5 * time.Minute
+	connectTimeout  = 15 * time.Second
+	callTimeout     = 60 * time.Second
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolName returns the agent tool name of an MCP tool, "mcp__<server>__<tool>".
+func ToolName(server, tool string) string {
+	return "mcp__" + unsafeNameChars.ReplaceAllString(server, "_") + "__" + unsafeNameChars.ReplaceAllString(tool, "_")
+}
+
+// Manager keeps the agent tool registry in line with the configured MCP servers.
+type Manager struct {
+	mu          sync.Mutex
+	fingerprint string
+	refreshed   time.Time
+	registered  []string
+	httpClient  *http.Client
+}
+
+var defaultManager = &Manager{httpClient: &http.Client{Timeout: callTimeout}}
+
+// Default returns the process-wide manager.
+func Default() *Manager {
+	return defaultManager
+}
+
+// Sync registers the tools of servers as agent tools and removes tools of
+// servers that are gone. Tool lists are fetched again when the configuration
+// changes or after refreshInterval. A server that cannot be reached is logged
+// and skipped until the next refresh.
+func (m *Manager) Sync(ctx context.Context, servers []config.MCPServer) {
+	fingerprint := serversFingerprint(servers)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if fingerprint == m.fingerprint && time.Since(m.refreshed) < refreshInterval {
+		return
+	}
+
+	var names []string
+	for _, server := range servers {
+		if server.Disabled || server.Name == "" || server.URL == "" {
+			continue
+		}
+		tools, err := m.connect(ctx, server)
+		if err != nil {
+			log.Warnf("mcp: server %s: %v", server.Name, err)
+			continue
+		}
+		for _, tool := range tools {
+			agent.Register(tool)
+			names = append(names, tool.Name())
+		}
+		log.Debugf("mcp: server %s provides %d tool(s)", server.Name, len(tools))
+	}
+	current := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		current[name] = struct{}{}
+	}
+	for _, name := range m.registered {
+		if _, ok := current[name]; !ok {
+			agent.Unregister(name)
+		}
+	}
+	m.registered = names
+	m.fingerprint = fingerprint
+	m.refreshed = time.Now()
+}
+
+func (m *Manager) connect(ctx context.Context, server config.MCPServer) ([]agent.Tool, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), connectTimeout)
+	defer cancel()
+	client := NewClient(server.URL, server.Headers, m.httpClient)
+	if err := client.Initialize(ctx); err != nil {
+		return nil, err
+	}
+	infos, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]agent.Tool, 0, len(infos))
+	for _, info := range infos {
+		tools = append(tools, &remoteTool{name: ToolName(server.Name, info.Name), info: info, client: client})
+	}
+	return tools, nil
+}
+
+func serversFingerprint(servers []config.MCPServer) string {
+	data, _ := json.Marshal(servers)
+	return string(data)
+}
+
+// remoteTool is an agent tool backed by an MCP server.
+type remoteTool struct {
+	name   string
+	info   ToolInfo
+	client *Client
+}
+
+func (t *remoteTool) Name() string { return t.name }
+
+func (t *remoteTool) Schema() agent.Schema {
+	schema := t.info.InputSchema
+	if schema == nil {
+		schema = map[string]any{"type": "object"}
+	}
+	return agent.Schema{Description: t.info.Description, InputSchema: schema}
+}
+
+func (t *remoteTool) Execute(ctx context.Context, args []byte) (string, error) {
+	text, isError, err := t.client.CallTool(ctx, t.info.Name, json.RawMessage(args))
+	if err != nil {
+		return "", fmt.Errorf("calling MCP tool %s: %w", t.info.Name, err)
+	}
+	if isError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
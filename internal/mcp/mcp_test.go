@@ -0,0 +1,81 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agent"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		id := gjson.GetBytes(body, "id").Raw
+		method := gjson.GetBytes(body, "method").String()
+		if method != "initialize" && r.Header.Get("Mcp-Session-Id") != "s1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		switch method {
+		case "initialize":
+			w.Header().Set("Mcp-Session-Id", "s1")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-03-26","capabilities":{"tools":{}}}}`, id)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/list":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"tools\":[{\"name\":\"search.docs\",\"description\":\"Search\",\"inputSchema\":{\"type\":\"object\"}}]}}\n\n", id)
+		case "tools/call":
+			query := gjson.GetBytes(body, "params.arguments.q").String()
+			isError := query == ""
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"content":[{"type":"text","text":"found %s"}],"isError":%v}}`, id, query, isError)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, id)
+		}
+	}))
+}
+
+func TestManagerRegistersServerTools(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	manager := &Manager{httpClient: server.Client()}
+	servers := []config.MCPServer{{Name: "docs", URL: server.URL}}
+	manager.Sync(context.Background(), servers)
+
+	tool, ok := agent.Lookup("mcp__docs__search_docs")
+	if !ok {
+		t.Fatalf("tool not registered; registry has %v", agent.Names())
+	}
+	if out, err := tool.Execute(context.Background(), []byte(`{"q":"mcp"}`)); err != nil || out != "found mcp" {
+		t.Fatalf("Execute = %q, %v", out, err)
+	}
+	if _, err := tool.Execute(context.Background(), []byte(`{}`)); err == nil || err.Error() != "found " {
+		t.Fatalf("tool error not surfaced: %v", err)
+	}
+
+	manager.Sync(context.Background(), nil)
+	if _, ok = agent.Lookup("mcp__docs__search_docs"); ok {
+		t.Fatalf("tool of removed server is still registered")
+	}
+}
+
+func TestClientReportsRPCErrors(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, nil, server.Client())
+	if err := client.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if _, err := client.call(context.Background(), "prompts/list", map[string]any{}); err == nil {
+		t.Fatalf("expected method not found error")
+	}
+}
@@ -0,0 +1,167 @@
+package misc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// CredentialKeyEnv names the environment variable holding the key used to
+// encrypt auth files at rest: 32 bytes, base64-encoded, the same convention as
+// the audit log's encryption-key. Files are written in plaintext when it is unset.
+const CredentialKeyEnv = "CLIPROXY_AUTH_ENCRYPTION_KEY"
+
+// credentialEnvelopeField marks an encrypted auth file and names its cipher.
+const credentialEnvelopeField = "cliproxy_encrypted"
+
+const credentialCipher = "aes-256-gcm"
+
+// ErrCredentialKeyMissing reports an encrypted auth file read without a key.
+var ErrCredentialKeyMissing = errors.New("auth file is encrypted but " + CredentialKeyEnv + " is not set")
+
+// DecodeEncryptionKey decodes an at-rest encryption key, which must be 32
+// bytes, base64-encoded. It is shared by auth file and audit log encryption.
+func DecodeEncryptionKey(value string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("encryption key must be 32 bytes, base64-encoded")
+	}
+	return raw, nil
+}
+
+// credentialKey decodes the configured AES-256 key. It returns nil when no key
+// is configured.
+func credentialKey() ([]byte, error) {
+	value := strings.TrimSpace(os.Getenv(CredentialKeyEnv))
+	if value == "" {
+		return nil, nil
+	}
+	key, err := DecodeEncryptionKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", CredentialKeyEnv, err)
+	}
+	return key, nil
+}
+
+// ValidateCredentialKey reports an error when CLIPROXY_AUTH_ENCRYPTION_KEY is
+// set but is not a valid key. It is checked at startup so a bad key fails fast
+// instead of on the first auth file write.
+func ValidateCredentialKey() error {
+	_, err := credentialKey()
+	return err
+}
+
+// CredentialEncryptionEnabled reports whether new auth files are encrypted.
+func CredentialEncryptionEnabled() bool {
+	return strings.TrimSpace(os.Getenv(CredentialKeyEnv)) != ""
+}
+
+// IsEncryptedCredentials reports whether data is an encrypted auth file.
+func IsEncryptedCredentials(data []byte) bool {
+	return gjson.GetBytes(data, credentialEnvelopeField).Exists()
+}
+
+// EncryptCredentials seals the JSON of an auth file when encryption is enabled
+// and returns data unchanged otherwise. The result is still a JSON object, so
+// encrypted files keep their .json names and are picked up by the watcher.
+func EncryptCredentials(data []byte) ([]byte, error) {
+	if IsEncryptedCredentials(data) {
+		return data, nil
+	}
+	key, err := credentialKey()
+	if err != nil || key == nil {
+		return data, err
+	}
+	gcm, err := credentialCipherFor(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, data, nil)
+	return json.Marshal(map[string]string{
+		credentialEnvelopeField: credentialCipher,
+		"data":                  base64.StdEncoding.EncodeToString(sealed),
+	})
+}
+
+// DecryptCredentials opens an encrypted auth file. Plaintext files are returned
+// unchanged, so callers can use it on every auth file they read.
+func DecryptCredentials(data []byte) ([]byte, error) {
+	if !IsEncryptedCredentials(data) {
+		return data, nil
+	}
+	if c := gjson.GetBytes(data, credentialEnvelopeField).String(); c != credentialCipher {
+		return nil, fmt.Errorf("unsupported auth file cipher %q", c)
+	}
+	key, err := credentialKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrCredentialKeyMissing
+	}
+	sealed, err := base64.StdEncoding.DecodeString(gjson.GetBytes(data, "data").String())
+	if err != nil {
+		return nil, fmt.Errorf("decode encrypted auth file: %w", err)
+	}
+	gcm, err := credentialCipherFor(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted auth file is truncated")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt auth file: %w", err)
+	}
+	return plain, nil
+}
+
+func credentialCipherFor(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadCredentialFile reads an auth file and decrypts it when needed.
+func ReadCredentialFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptCredentials(data)
+}
+
+// WriteCredentialFile writes v as the JSON of an auth file, creating its
+// directory and encrypting it when encryption is enabled.
+func WriteCredentialFile(path string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if raw, err = EncryptCredentials(raw); err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	if err = os.WriteFile(path, raw, 0o600); err != nil {
+		return fmt.Errorf("failed to write token to file: %w", err)
+	}
+	return nil
+}
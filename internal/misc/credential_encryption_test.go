@@ -0,0 +1,90 @@
+package misc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testCredentialKey(fill byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, 32))
+}
+
+func TestCredentialEncryptionRoundTrip(t *testing.T) {
+	t.Setenv(CredentialKeyEnv, testCredentialKey(1))
+	plain := []byte(`{"type":"claude","access_token":"tok"}`)
+
+	sealed, err := EncryptCredentials(plain)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !IsEncryptedCredentials(sealed) || strings.Contains(string(sealed), "tok") {
+		t.Fatalf("sealed file leaks plaintext: %s", sealed)
+	}
+	again, err := EncryptCredentials(sealed)
+	if err != nil || string(again) != string(sealed) {
+		t.Fatalf("encrypting an encrypted file should be a no-op")
+	}
+	opened, err := DecryptCredentials(sealed)
+	if err != nil || string(opened) != string(plain) {
+		t.Fatalf("decrypt = %s, %v", opened, err)
+	}
+
+	t.Setenv(CredentialKeyEnv, testCredentialKey(2))
+	if _, err = DecryptCredentials(sealed); err == nil {
+		t.Fatal("decrypt with the wrong key should fail")
+	}
+	t.Setenv(CredentialKeyEnv, "")
+	if _, err = DecryptCredentials(sealed); !errors.Is(err, ErrCredentialKeyMissing) {
+		t.Fatalf("decrypt without key = %v", err)
+	}
+}
+
+func TestCredentialFilesPlaintextWithoutKey(t *testing.T) {
+	t.Setenv(CredentialKeyEnv, "")
+	path := filepath.Join(t.TempDir(), "nested", "auth.json")
+	if err := WriteCredentialFile(path, map[string]string{"type": "codex"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if string(raw) != `{"type":"codex"}` {
+		t.Fatalf("file = %s", raw)
+	}
+
+	t.Setenv(CredentialKeyEnv, testCredentialKey(1))
+	if err := WriteCredentialFile(path, map[string]string{"type": "codex"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	raw, _ = os.ReadFile(path)
+	if !IsEncryptedCredentials(raw) {
+		t.Fatalf("file not encrypted: %s", raw)
+	}
+	data, err := ReadCredentialFile(path)
+	if err != nil || string(data) != `{"type":"codex"}` {
+		t.Fatalf("read = %s, %v", data, err)
+	}
+}
+
+func TestCredentialKeyMustBe32Base64Bytes(t *testing.T) {
+	t.Setenv(CredentialKeyEnv, "")
+	if err := ValidateCredentialKey(); err != nil {
+		t.Fatalf("unset key: %v", err)
+	}
+	for _, key := range []string{"secret", base64.StdEncoding.EncodeToString([]byte("too short"))} {
+		t.Setenv(CredentialKeyEnv, key)
+		if err := ValidateCredentialKey(); err == nil {
+			t.Fatalf("key %q accepted", key)
+		}
+		if _, err := EncryptCredentials([]byte(`{"type":"codex"}`)); err == nil {
+			t.Fatalf("encrypt with key %q succeeded", key)
+		}
+	}
+	t.Setenv(CredentialKeyEnv, testCredentialKey(3))
+	if err := ValidateCredentialKey(); err != nil {
+		t.Fatalf("valid key rejected: %v", err)
+	}
+}
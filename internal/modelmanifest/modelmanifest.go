@@ -0,0 +1,320 @@
+// Package modelmanifest keeps the model registry in sync with a signed remote
+// manifest listing model definitions per provider. The last accepted manifest
+// is cached on disk and served immediately on startup (stale-while-revalidate)
+// while the remote copy is fetched in the background; fetch failures keep the
+// current definitions in place. Optionally, new manifests are held until an
+// administrator approves them.
+package modelmanifest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRefreshInterval = time.Hour
+	maxManifestSize        = 4 << 20
+	httpUserAgent          = "CLIProxyAPI-model-manifest"
+)
+
+// ErrNoPendingManifest is returned by Approve when nothing awaits approval.
+var ErrNoPendingManifest = errors.New("no manifest pending approval")
+
+// Manifest lists model definitions keyed by provider (e.g. "claude", "gemini").
+type Manifest struct {
+	// Version must increase with every published manifest.
+	Version int64 `json:"version"`
+	// Models holds the definitions merged into each provider's static list.
+	Models map[string][]*registry.ModelInfo `json:"models"`
+}
+
+// envelope is the signed wire format.
+type envelope struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"`
+}
+
+// Verify checks the envelope signature against publicKey and decodes the manifest.
+func Verify(data []byte, publicKey ed25519.PublicKey) (*Manifest, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("decode manifest envelope: %w", err)
+	}
+	if len(env.Manifest) == 0 || env.Signature == "" {
+		return nil, errors.New("manifest envelope is missing manifest or signature")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(env.Signature))
+	if err != nil {
+		return nil, fmt.Errorf("decode manifest signature: %w", err)
+	}
+	if !ed25519.Verify(publicKey, env.Manifest, signature) {
+		return nil, errors.New("manifest signature verification failed")
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(env.Manifest, &manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	for provider, models := range manifest.Models {
+		for _, model := range models {
+			if model == nil || strings.TrimSpace(model.ID) == "" {
+				return nil, fmt.Errorf("manifest provider %q contains a model without id", provider)
+			}
+		}
+	}
+	return &manifest, nil
+}
+
+// Status describes the syncer state for the management API.
+type Status struct {
+	URL             string    `json:"url"`
+	ActiveVersion   int64     `json:"active_version"`
+	PendingVersion  int64     `json:"pending_version,omitempty"`
+	RequireApproval bool      `json:"require_approval"`
+	LastFetch       time.Time `json:"last_fetch,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// Syncer fetches, verifies and applies manifests.
+type Syncer struct {
+	url             string
+	publicKey       ed25519.PublicKey
+	interval        time.Duration
+	requireApproval bool
+	cachePath       string
+	client          *http.Client
+	onApply         func()
+
+	mu          sync.Mutex
+	active      *Manifest
+	pending     *Manifest
+	pendingData []byte
+	etag        string
+	lastFetch   time.Time
+	lastErr     string
+}
+
+var current atomic.Pointer[Syncer]
+
+// Current returns the running syncer, or nil when manifest sync is disabled.
+func Current() *Syncer { return current.Load() }
+
+// New builds a syncer from cfg. cachePath stores the last accepted manifest and
+// onApply is invoked after new definitions were applied to the registry.
+func New(cfg config.ModelManifestConfig, proxyURL, cachePath string, onApply func()) (*Syncer, error) {
+	url := strings.TrimSpace(cfg.URL)
+	if url == "" {
+		return nil, errors.New("model manifest url is empty")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(cfg.PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("model manifest public-key must be a base64 Ed25519 public key")
+	}
+	interval := time.Duration(cfg.RefreshSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if proxyURL = strings.TrimSpace(proxyURL); proxyURL != "" {
+		util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: proxyURL}, client)
+	}
+	return &Syncer{
+		url:             url,
+		publicKey:       ed25519.PublicKey(key),
+		interval:        interval,
+		requireApproval: cfg.RequireApproval,
+		cachePath:       cachePath,
+		client:          client,
+		onApply:         onApply,
+	}, nil
+}
+
+// Start applies the cached manifest, then revalidates the remote copy every
+// interval until ctx is cancelled.
+func (s *Syncer) Start(ctx context.Context) {
+	current.Store(s)
+	if err := s.loadCache(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("model manifest: ignoring cached manifest: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Refresh(ctx); err != nil {
+				log.Warnf("model manifest: refresh failed, keeping current definitions: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh fetches the remote manifest once. A newer manifest is applied, or
+// held for approval when approval is required.
+func (s *Syncer) Refresh(ctx context.Context) error {
+	data, etag, notModified, err := s.fetch(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFetch = time.Now()
+	if err != nil {
+		s.lastErr = err.Error()
+		return err
+	}
+	s.lastErr = ""
+	if notModified {
+		return nil
+	}
+	manifest, err := Verify(data, s.publicKey)
+	if err != nil {
+		s.lastErr = err.Error()
+		return err
+	}
+	s.etag = etag
+	if s.active != nil && manifest.Version <= s.active.Version {
+		return nil
+	}
+	if s.requireApproval {
+		if s.pending == nil || manifest.Version > s.pending.Version {
+			s.pending, s.pendingData = manifest, data
+			log.Infof("model manifest: version %d awaiting approval", manifest.Version)
+		}
+		return nil
+	}
+	s.applyLocked(manifest, data)
+	return nil
+}
+
+// Approve applies the manifest awaiting approval and returns its version.
+func (s *Syncer) Approve() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return 0, ErrNoPendingManifest
+	}
+	manifest, data := s.pending, s.pendingData
+	s.pending, s.pendingData = nil, nil
+	s.applyLocked(manifest, data)
+	return manifest.Version, nil
+}
+
+// Status reports the current sync state.
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{
+		URL:             s.url,
+		RequireApproval: s.requireApproval,
+		LastFetch:       s.lastFetch,
+		LastError:       s.lastErr,
+	}
+	if s.active != nil {
+		status.ActiveVersion = s.active.Version
+	}
+	if s.pending != nil {
+		status.PendingVersion = s.pending.Version
+	}
+	return status
+}
+
+func (s *Syncer) applyLocked(manifest *Manifest, data []byte) {
+	s.active = manifest
+	registry.SetManifestModels(manifest.Models)
+	if s.cachePath != "" {
+		if err := writeFileAtomic(s.cachePath, data); err != nil {
+			log.Warnf("model manifest: failed to cache manifest: %v", err)
+		}
+	}
+	log.Infof("model manifest: applied version %d", manifest.Version)
+	if s.onApply != nil {
+		go s.onApply()
+	}
+}
+
+// loadCache applies the previously accepted manifest without approval; it was
+// either approved or auto-applied when it was first fetched.
+func (s *Syncer) loadCache() error {
+	if s.cachePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.cachePath)
+	if err != nil {
+		return err
+	}
+	manifest, err := Verify(data, s.publicKey)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyLocked(manifest, data)
+	return nil
+}
+
+func (s *Syncer) fetch(ctx context.Context) ([]byte, string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	req.Header.Set("Accept", "application/json")
+	s.mu.Lock()
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	s.mu.Unlock()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Debugf("model manifest: close response body error: %v", errClose)
+		}
+	}()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, "", false, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, "", false, fmt.Errorf("manifest exceeds %d bytes", maxManifestSize)
+	}
+	return bytes.TrimSpace(data), resp.Header.Get("ETag"), false, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
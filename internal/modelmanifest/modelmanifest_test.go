@@ -0,0 +1,79 @@
+package modelmanifest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func signedManifest(t *testing.T, key ed25519.PrivateKey, manifest string) []byte {
+	t.Helper()
+	data, err := json.Marshal(envelope{
+		Manifest:  json.RawMessage(manifest),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(manifest))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSyncer_ApprovalAndStaleCache(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	var body atomic.Value
+	body.Store(signedManifest(t, priv, `{"version":1,"models":{"claude":[{"id":"claude-manifest-test","object":"model","owned_by":"anthropic","type":"claude"}]}}`))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body.Load().([]byte))
+	}))
+	defer server.Close()
+	defer registry.SetManifestModels(nil)
+
+	cfg := config.ModelManifestConfig{URL: server.URL, PublicKey: base64.StdEncoding.EncodeToString(pub), RequireApproval: true}
+	cachePath := filepath.Join(t.TempDir(), "model-manifest.cache")
+	syncer, err := New(cfg, "", cachePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = syncer.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if got := registry.MergeManifestModels("claude", nil); len(got) != 0 {
+		t.Fatalf("manifest applied before approval: %+v", got)
+	}
+	if version, errApprove := syncer.Approve(); errApprove != nil || version != 1 {
+		t.Fatalf("approve = %d, %v", version, errApprove)
+	}
+	if got := registry.MergeManifestModels("claude", nil); len(got) != 1 || got[0].ID != "claude-manifest-test" {
+		t.Fatalf("manifest models not applied: %+v", got)
+	}
+
+	// A tampered manifest is rejected and the current definitions stay in place.
+	body.Store([]byte(`{"manifest":{"version":2,"models":{}},"signature":"AAAA"}`))
+	if err = syncer.Refresh(context.Background()); err == nil {
+		t.Fatalf("expected signature failure")
+	}
+	if syncer.Status().ActiveVersion != 1 || registry.LookupStaticModelInfo("claude-manifest-test") == nil {
+		t.Fatalf("active manifest lost after failed refresh")
+	}
+
+	// A fresh syncer serves the cached manifest before any fetch succeeds.
+	registry.SetManifestModels(nil)
+	server.Close()
+	restarted, _ := New(cfg, "", cachePath, nil)
+	if err = restarted.loadCache(); err != nil {
+		t.Fatalf("load cache: %v", err)
+	}
+	if restarted.Status().ActiveVersion != 1 || registry.LookupStaticModelInfo("claude-manifest-test") == nil {
+		t.Fatalf("cached manifest not applied on startup")
+	}
+}
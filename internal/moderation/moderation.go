@@ -0,0 +1,222 @@
+// Package moderation checks prompt and completion text against content
+// policies. A Chain consults providers in order: the local rule engine matches
+// regular expressions, and API providers call an OpenAI-compatible
+// /v1/moderations endpoint. Helpers extract the text to check from each client
+// schema and build refusals that finish with a content_filter reason.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Action selects what happens to flagged content.
+type Action string
+
+const (
+	// ActionBlock replaces flagged content with a refusal.
+	ActionBlock Action = "block"
+	// ActionAnnotate only logs and marks flagged content.
+	ActionAnnotate Action = "annotate"
+)
+
+// ParseAction normalizes a configured action. Unknown values block.
+func ParseAction(value string) Action {
+	if Action(strings.ToLower(strings.TrimSpace(value))) == ActionAnnotate {
+		return ActionAnnotate
+	}
+	return ActionBlock
+}
+
+// DefaultMessage is the refusal text used when none is configured.
+const DefaultMessage = "This content was blocked by the content policy."
+
+// Verdict is the outcome of a moderation check.
+type Verdict struct {
+	// Flagged reports whether the text violates the policy.
+	Flagged bool `json:"flagged"`
+	// Provider names the provider that flagged the text.
+	Provider string `json:"provider,omitempty"`
+	// Categories lists the violated categories.
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Provider checks text against a content policy.
+type Provider interface {
+	// Name identifies the provider in verdicts and logs.
+	Name() string
+	// Moderate returns the verdict for text.
+	Moderate(ctx context.Context, text string) (Verdict, error)
+}
+
+// Chain consults providers in order and returns the first flagged verdict.
+type Chain []Provider
+
+// Moderate checks text with every provider until one flags it. Provider errors
+// are joined and returned together with the verdict of the remaining providers.
+func (c Chain) Moderate(ctx context.Context, text string) (Verdict, error) {
+	if strings.TrimSpace(text) == "" {
+		return Verdict{}, nil
+	}
+	var errs []error
+	for _, provider := range c {
+		verdict, err := provider.Moderate(ctx, text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		if verdict.Flagged {
+			verdict.Provider = provider.Name()
+			return verdict, errors.Join(errs...)
+		}
+	}
+	return Verdict{}, errors.Join(errs...)
+}
+
+// Rule flags text matching Pattern under Category.
+type Rule struct {
+	Category string
+	Pattern  string
+}
+
+type rule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// Rules is the local rule engine. It flags text matching any of its patterns.
+type Rules struct {
+	rules []rule
+}
+
+// NewRules compiles rules. Invalid patterns are skipped and reported; rules
+// without a category are reported under "policy".
+func NewRules(rules []Rule) (*Rules, []error) {
+	r := &Rules{}
+	var errs []error
+	for _, rl := range rules {
+		re, err := regexp.Compile(rl.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pattern %q: %w", rl.Pattern, err))
+			continue
+		}
+		category := strings.TrimSpace(rl.Category)
+		if category == "" {
+			category = "policy"
+		}
+		r.rules = append(r.rules, rule{category: category, pattern: re})
+	}
+	return r, errs
+}
+
+// Name implements Provider.
+func (r *Rules) Name() string { return "rules" }
+
+// Moderate implements Provider.
+func (r *Rules) Moderate(_ context.Context, text string) (Verdict, error) {
+	var verdict Verdict
+	for _, rl := range r.rules {
+		if rl.pattern.MatchString(text) && !slices.Contains(verdict.Categories, rl.category) {
+			verdict.Flagged = true
+			verdict.Categories = append(verdict.Categories, rl.category)
+		}
+	}
+	return verdict, nil
+}
+
+// DefaultAPIURL is the moderation endpoint used when an API provider has no URL.
+const DefaultAPIURL = "https://api.openai.com/v1/moderations"
+
+// API calls an OpenAI-compatible moderation endpoint.
+type API struct {
+	// URL is the moderation endpoint.
+	URL string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	// Model is sent as the moderation model when set.
+	Model string
+	// Categories restricts flagging to these categories. Empty trusts the
+	// endpoint's flagged field.
+	Categories []string
+	// Client performs the requests; its timeout bounds each check.
+	Client *http.Client
+}
+
+// Name implements Provider.
+func (a *API) Name() string { return "api" }
+
+// Moderate implements Provider.
+func (a *API) Moderate(ctx context.Context, text string) (Verdict, error) {
+	body := map[string]any{"input": text}
+	if a.Model != "" {
+		body["model"] = a.Model
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return Verdict{}, err
+	}
+	url := a.URL
+	if url == "" {
+		url = DefaultAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	}
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Verdict{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Verdict{}, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	results := gjson.GetBytes(data, "results")
+	if !results.IsArray() {
+		return Verdict{}, fmt.Errorf("unexpected response: %s", data)
+	}
+	var verdict Verdict
+	restricted := len(a.Categories) > 0
+	results.ForEach(func(_, result gjson.Result) bool {
+		if !restricted && !result.Get("flagged").Bool() {
+			return true
+		}
+		if !restricted {
+			verdict.Flagged = true
+		}
+		result.Get("categories").ForEach(func(name, flagged gjson.Result) bool {
+			category := name.String()
+			if flagged.Bool() && !slices.Contains(verdict.Categories, category) && (!restricted || slices.Contains(a.Categories, category)) {
+				verdict.Categories = append(verdict.Categories, category)
+			}
+			return true
+		})
+		return true
+	})
+	if len(verdict.Categories) > 0 {
+		verdict.Flagged = true
+	}
+	return verdict, nil
+}
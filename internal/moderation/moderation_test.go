@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestChainConsultsProvidersInOrder(t *testing.T) {
+	var inputs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		inputs = append(inputs, gjson.GetBytes(body, "input").String())
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(`{"results":[{"flagged":true,"categories":{"violence":true,"harassment":false,"self-harm":true}}]}`))
+	}))
+	defer server.Close()
+
+	rules, errs := NewRules([]Rule{{Category: "secrets", Pattern: `(?i)launch codes`}, {Pattern: "("}})
+	if len(errs) != 1 {
+		t.Fatalf("errs = %v, want the invalid pattern reported", errs)
+	}
+	chain := Chain{rules, &API{URL: server.URL, APIKey: "key", Client: server.Client()}}
+
+	verdict, err := chain.Moderate(context.Background(), "share the LAUNCH CODES")
+	if err != nil || !verdict.Flagged || verdict.Provider != "rules" || verdict.Categories[0] != "secrets" {
+		t.Fatalf("rules verdict = %+v, %v", verdict, err)
+	}
+	if len(inputs) != 0 {
+		t.Fatalf("api consulted after rules flagged: %v", inputs)
+	}
+
+	verdict, err = chain.Moderate(context.Background(), "something else")
+	if err != nil || verdict.Provider != "api" || len(verdict.Categories) != 2 {
+		t.Fatalf("api verdict = %+v, %v", verdict, err)
+	}
+
+	restricted := &API{URL: server.URL, APIKey: "key", Categories: []string{"harassment"}, Client: server.Client()}
+	if verdict, _ = restricted.Moderate(context.Background(), "x"); verdict.Flagged {
+		t.Fatalf("restricted verdict = %+v, want not flagged", verdict)
+	}
+}
+
+func TestPromptTextUsesLatestUserMessage(t *testing.T) {
+	cases := map[string]string{
+		"claude":          `{"messages":[{"role":"user","content":"old"},{"role":"assistant","content":"a"},{"role":"user","content":[{"type":"tool_result","content":"tool"},{"type":"text","text":"new"}]}]}`,
+		"openai":          `{"messages":[{"role":"user","content":"old"},{"role":"user","content":[{"type":"text","text":"new"}]}]}`,
+		"openai-response": `{"input":[{"role":"user","content":"old"},{"role":"user","content":[{"type":"input_text","text":"new"}]}]}`,
+		"gemini-cli":      `{"request":{"contents":[{"role":"user","parts":[{"text":"old"}]},{"role":"user","parts":[{"text":"new"}]}]}}`,
+	}
+	for format, payload := range cases {
+		if got := PromptText(format, []byte(payload)); got != "new" {
+			t.Errorf("%s: PromptText = %q, want %q", format, got, "new")
+		}
+	}
+}
+
+func TestStreamStopClosesOpenClaudeBlock(t *testing.T) {
+	stream := NewStream("claude")
+	stream.Observe([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":2,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	if final := stream.Observe([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":2,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello\"}}\n\n")); final {
+		t.Fatalf("text delta reported as final")
+	}
+	if stream.Pending() != 5 || stream.Checked() != "hello" || stream.Pending() != 0 {
+		t.Fatalf("unexpected pending text")
+	}
+	stop, ok := stream.Stop()
+	if !ok || !strings.HasPrefix(string(stop), "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":2}") || !strings.Contains(string(stop), `"stop_reason":"refusal"`) {
+		t.Fatalf("stop = %q", stop)
+	}
+}
@@ -0,0 +1,199 @@
+package moderation
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// FinishReason is the OpenAI finish reason reported for moderated content.
+// Claude clients receive the stop reason "refusal" and Gemini clients "SAFETY".
+const FinishReason = "content_filter"
+
+// Refusal returns a complete non-streaming response in the given client schema
+// carrying message and finishing with the content filter reason. It reports
+// false for schemas without one.
+func Refusal(format, model, message string) ([]byte, bool) {
+	var out string
+	switch format {
+	case "openai":
+		out = fmt.Sprintf(`{"id":"chatcmpl-moderation","object":"chat.completion","created":%d,"choices":[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}],"usage":{"prompt_tokens":0,"completion_tokens":0,"total_tokens":0}}`, time.Now().Unix())
+		out, _ = sjson.Set(out, "choices.0.message.content", message)
+	case "claude":
+		out = `{"id":"msg_moderation","type":"message","role":"assistant","content":[{"type":"text","text":""}],"stop_reason":"refusal","stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0}}`
+		out, _ = sjson.Set(out, "content.0.text", message)
+	case "openai-response":
+		out = fmt.Sprintf(`{"id":"resp_moderation","object":"response","created_at":%d,"status":"incomplete","incomplete_details":{"reason":"content_filter"},"output":[{"type":"message","id":"msg_moderation","status":"incomplete","role":"assistant","content":[{"type":"output_text","text":"","annotations":[]}]}]}`, time.Now().Unix())
+		out, _ = sjson.Set(out, "output.0.content.0.text", message)
+	case "gemini":
+		out = `{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"finishReason":"SAFETY","index":0}]}`
+		out, _ = sjson.Set(out, "candidates.0.content.parts.0.text", message)
+	default:
+		return nil, false
+	}
+	out, _ = sjson.Set(out, "model", model)
+	return []byte(out), true
+}
+
+// RefusalStream returns the stream chunks of a refusal in the given client
+// schema, framed the way the executors deliver them to the handlers. It
+// reports false for schemas without one.
+func RefusalStream(format, model, message string) ([][]byte, bool) {
+	switch format {
+	case "openai":
+		stream := &Stream{format: format, id: "chatcmpl-moderation", model: model}
+		first := stream.openAIChunk(`{"role":"assistant","content":""}`, "null")
+		first, _ = sjson.SetBytes(first, "choices.0.delta.content", message)
+		return [][]byte{first, stream.openAIChunk(`{}`, `"content_filter"`)}, true
+	case "claude":
+		start, _ := sjson.Set(`{"type":"message_start","message":{"id":"msg_moderation","type":"message","role":"assistant","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0}}}`, "message.model", model)
+		delta, _ := sjson.Set(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":""}}`, "delta.text", message)
+		return [][]byte{
+			claudeEvent("message_start", start),
+			claudeEvent("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`),
+			claudeEvent("content_block_delta", delta),
+			[]byte(claudeStop(0)),
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// Stream follows a streamed completion in a client schema so its text can be
+// checked before each chunk is forwarded.
+type Stream struct {
+	format    string
+	text      strings.Builder
+	checked   int
+	openBlock int
+	id        string
+	model     string
+}
+
+// NewStream returns a follower for a stream in the given client schema.
+func NewStream(format string) *Stream {
+	return &Stream{format: format, openBlock: -1}
+}
+
+// Observe records the text of one chunk and reports whether the chunk ends
+// the completion.
+func (s *Stream) Observe(chunk []byte) (final bool) {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(line), []byte("data:")))
+		if len(line) == 0 || !gjson.ValidBytes(line) {
+			continue
+		}
+		if s.observeEvent(gjson.ParseBytes(line)) {
+			final = true
+		}
+	}
+	return final
+}
+
+func (s *Stream) observeEvent(event gjson.Result) (final bool) {
+	switch s.format {
+	case "openai":
+		if s.id == "" {
+			s.id, s.model = event.Get("id").String(), event.Get("model").String()
+		}
+		event.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			s.text.WriteString(choice.Get("delta.content").String())
+			if choice.Get("finish_reason").String() != "" {
+				final = true
+			}
+			return true
+		})
+	case "claude":
+		switch event.Get("type").String() {
+		case "content_block_start":
+			s.openBlock = int(event.Get("index").Int())
+		case "content_block_delta":
+			s.text.WriteString(event.Get("delta.text").String())
+		case "content_block_stop":
+			s.openBlock = -1
+		case "message_delta", "message_stop":
+			final = true
+		}
+	case "openai-response":
+		switch event.Get("type").String() {
+		case "response.output_text.delta":
+			s.text.WriteString(event.Get("delta").String())
+		case "response.completed", "response.incomplete", "response.failed":
+			final = true
+		}
+	case "gemini", "gemini-cli":
+		if s.format == "gemini-cli" {
+			event = event.Get("response")
+		}
+		event.Get("candidates").ForEach(func(_, candidate gjson.Result) bool {
+			candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+				if !part.Get("thought").Bool() {
+					s.text.WriteString(part.Get("text").String())
+				}
+				return true
+			})
+			if candidate.Get("finishReason").String() != "" {
+				final = true
+			}
+			return true
+		})
+	}
+	return final
+}
+
+// Pending returns the number of text bytes observed since the last Checked.
+func (s *Stream) Pending() int { return s.text.Len() - s.checked }
+
+// streamOverlap is how much already checked text is checked again, so matches
+// spanning two checks are found.
+const streamOverlap = 256
+
+// Checked marks the observed text as checked and returns the text to check:
+// everything since the previous check plus some overlap with it.
+func (s *Stream) Checked() string {
+	text := s.text.String()
+	start := max(s.checked-streamOverlap, 0)
+	s.checked = len(text)
+	return text[start:]
+}
+
+// Stop returns the chunk that ends the stream with the content filter reason
+// in place of the rest of the completion. It reports false for schemas whose
+// streams cannot be ended that way.
+func (s *Stream) Stop() ([]byte, bool) {
+	switch s.format {
+	case "openai":
+		return s.openAIChunk(`{}`, `"content_filter"`), true
+	case "claude":
+		return []byte(claudeStop(s.openBlock)), true
+	default:
+		return nil, false
+	}
+}
+
+func (s *Stream) openAIChunk(delta, finishReason string) []byte {
+	out := fmt.Sprintf(`{"id":"","object":"chat.completion.chunk","created":%d,"model":"","choices":[{"index":0,"delta":%s,"finish_reason":%s}]}`, time.Now().Unix(), delta, finishReason)
+	out, _ = sjson.Set(out, "id", s.id)
+	out, _ = sjson.Set(out, "model", s.model)
+	return []byte(out)
+}
+
+// claudeStop closes the content block at index, if any, and the message with
+// the refusal stop reason.
+func claudeStop(index int) string {
+	var b strings.Builder
+	if index >= 0 {
+		b.Write(claudeEvent("content_block_stop", fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, index)))
+	}
+	b.Write(claudeEvent("message_delta", `{"type":"message_delta","delta":{"stop_reason":"refusal","stop_sequence":null},"usage":{"output_tokens":0}}`))
+	b.Write(claudeEvent("message_stop", `{"type":"message_stop"}`))
+	return b.String()
+}
+
+func claudeEvent(name, data string) []byte {
+	return []byte("event: " + name + "\ndata: " + data + "\n\n")
+}
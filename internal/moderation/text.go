@@ -0,0 +1,115 @@
+package moderation
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// PromptText returns the text of the latest user message in a request of the
+// given client schema. Earlier turns were checked when they were sent, and tool
+// results are not user input, so neither is included.
+func PromptText(format string, payload []byte) string {
+	root := gjson.ParseBytes(payload)
+	var b strings.Builder
+	switch format {
+	case "claude", "openai":
+		messages := root.Get("messages").Array()
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Get("role").String() == "user" {
+				appendContentText(&b, messages[i].Get("content"), "text")
+				break
+			}
+		}
+	case "openai-response":
+		input := root.Get("input")
+		if input.Type == gjson.String {
+			return input.String()
+		}
+		items := input.Array()
+		for i := len(items) - 1; i >= 0; i-- {
+			if items[i].Get("role").String() == "user" {
+				appendContentText(&b, items[i].Get("content"), "input_text")
+				break
+			}
+		}
+	case "gemini", "gemini-cli":
+		if format == "gemini-cli" {
+			root = root.Get("request")
+		}
+		contents := root.Get("contents").Array()
+		for i := len(contents) - 1; i >= 0; i-- {
+			if role := contents[i].Get("role").String(); role == "user" || role == "" {
+				appendPartsText(&b, contents[i].Get("parts"))
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// CompletionText returns the assistant text of a non-streaming response in the
+// given client schema.
+func CompletionText(format string, payload []byte) string {
+	root := gjson.ParseBytes(payload)
+	var b strings.Builder
+	switch format {
+	case "openai":
+		root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			appendText(&b, choice.Get("message.content").String())
+			return true
+		})
+	case "claude":
+		appendContentText(&b, root.Get("content"), "text")
+	case "openai-response":
+		root.Get("output").ForEach(func(_, item gjson.Result) bool {
+			if item.Get("type").String() == "message" {
+				appendContentText(&b, item.Get("content"), "output_text")
+			}
+			return true
+		})
+	case "gemini", "gemini-cli":
+		if format == "gemini-cli" {
+			root = root.Get("response")
+		}
+		root.Get("candidates").ForEach(func(_, candidate gjson.Result) bool {
+			appendPartsText(&b, candidate.Get("content.parts"))
+			return true
+		})
+	}
+	return b.String()
+}
+
+// appendContentText appends a string content or the text of its parts of the
+// given type.
+func appendContentText(b *strings.Builder, content gjson.Result, partType string) {
+	if content.Type == gjson.String {
+		appendText(b, content.String())
+		return
+	}
+	content.ForEach(func(_, part gjson.Result) bool {
+		if part.Get("type").String() == partType {
+			appendText(b, part.Get("text").String())
+		}
+		return true
+	})
+}
+
+func appendPartsText(b *strings.Builder, parts gjson.Result) {
+	parts.ForEach(func(_, part gjson.Result) bool {
+		if !part.Get("thought").Bool() {
+			appendText(b, part.Get("text").String())
+		}
+		return true
+	})
+}
+
+func appendText(b *strings.Builder, text string) {
+	if text == "" {
+		return
+	}
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteString(text)
+}
@@ -0,0 +1,264 @@
+// Package planmode tracks the plan mode of coding agent conversations.
+// Claude Code enters plan mode when the user switches to it or the model calls
+// EnterPlanMode, and leaves it through an ExitPlanMode call carrying the plan,
+// which the user approves or rejects in the tool result. A Tracker is advanced
+// with the transcript messages it has not seen yet, and trackers are kept per
+// conversation and persisted in the conversation store.
+package planmode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/convstore"
+	"github.com/tidwall/gjson"
+)
+
+// Phase is the plan mode state of a conversation.
+type Phase string
+
+// Phases.
+const (
+	// PhaseInactive means the conversation is not in plan mode.
+	PhaseInactive Phase = "inactive"
+	// PhasePlanning means plan mode is active and no plan awaits approval.
+	PhasePlanning Phase = "planning"
+	// PhasePending means a plan was presented and awaits the user's answer.
+	PhasePending Phase = "pending_approval"
+)
+
+// Tool names and the reminder Claude Code adds while plan mode is active.
+const (
+	enterTool      = "EnterPlanMode"
+	exitTool       = "ExitPlanMode"
+	activeReminder = "Plan mode is active"
+)
+
+// Tracker is the plan mode state machine of one conversation.
+type Tracker struct {
+	Phase Phase `json:"phase"`
+	// Plan is the last plan presented through ExitPlanMode.
+	Plan string `json:"plan,omitempty"`
+	// ExitCallID is the tool call ID of the pending ExitPlanMode call.
+	ExitCallID string    `json:"exit_call_id,omitempty"`
+	EnteredAt  time.Time `json:"entered_at,omitempty"`
+	Approved   int       `json:"approved"`
+	Rejected   int       `json:"rejected"`
+
+	// Messages is the number of transcript messages applied so far and
+	// Fingerprint identifies the last of them, so a rewritten history is
+	// replayed from the start.
+	Messages    int    `json:"messages"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// New returns an inactive tracker.
+func New() *Tracker {
+	return &Tracker{Phase: PhaseInactive}
+}
+
+// Enter switches to planning unless plan mode is already active.
+func (t *Tracker) Enter(now time.Time) {
+	if t.Phase != PhaseInactive {
+		return
+	}
+	t.Phase = PhasePlanning
+	t.EnteredAt = now
+}
+
+// Exit records a plan presented for approval by the tool call callID.
+func (t *Tracker) Exit(callID, plan string) {
+	t.Phase = PhasePending
+	t.ExitCallID = callID
+	t.Plan = plan
+}
+
+// Resolve applies the user's answer to the pending plan. An approved plan
+// leaves plan mode, a rejected one returns to planning. Answers to other tool
+// calls are ignored.
+func (t *Tracker) Resolve(callID string, approved bool) {
+	if t.Phase != PhasePending || callID != t.ExitCallID {
+		return
+	}
+	t.ExitCallID = ""
+	if approved {
+		t.Phase = PhaseInactive
+		t.EnteredAt = time.Time{}
+		t.Approved++
+		return
+	}
+	t.Phase = PhasePlanning
+	t.Rejected++
+}
+
+// Pending reports whether a plan awaits approval.
+func (t *Tracker) Pending() bool {
+	return t.Phase == PhasePending
+}
+
+// Active reports whether the conversation is in plan mode.
+func (t *Tracker) Active() bool {
+	return t.Phase != PhaseInactive
+}
+
+// Observe applies the Claude Messages transcript messages added since the last
+// call. When the known part of the transcript changed, the tracker is reset
+// and the whole transcript is replayed.
+func (t *Tracker) Observe(messages gjson.Result, now time.Time) {
+	list := messages.Array()
+	if t.Messages > len(list) || (t.Messages > 0 && fingerprint(list[t.Messages-1]) != t.Fingerprint) {
+		*t = *New()
+	}
+	for _, message := range list[t.Messages:] {
+		t.apply(message, now)
+	}
+	t.Messages = len(list)
+	if len(list) > 0 {
+		t.Fingerprint = fingerprint(list[len(list)-1])
+	}
+}
+
+func (t *Tracker) apply(message gjson.Result, now time.Time) {
+	content := message.Get("content")
+	if content.Type == gjson.String {
+		if message.Get("role").String() == "user" && strings.Contains(content.String(), activeReminder) {
+			t.Enter(now)
+		}
+		return
+	}
+	for _, block := range content.Array() {
+		switch block.Get("type").String() {
+		case "text":
+			if message.Get("role").String() == "user" && strings.Contains(block.Get("text").String(), activeReminder) {
+				t.Enter(now)
+			}
+		case "tool_use":
+			switch block.Get("name").String() {
+			case enterTool:
+				t.Enter(now)
+			case exitTool:
+				t.Enter(now)
+				t.Exit(block.Get("id").String(), block.Get("input.plan").String())
+			}
+		case "tool_result":
+			t.Resolve(block.Get("tool_use_id").String(), !block.Get("is_error").Bool())
+		}
+	}
+}
+
+// fingerprint identifies a message by its role and block contents. Cache
+// control markers are left out because clients move them between requests.
+func fingerprint(message gjson.Result) string {
+	h := sha256.New()
+	h.Write([]byte(message.Get("role").String()))
+	content := message.Get("content")
+	if content.Type == gjson.String {
+		h.Write([]byte{0})
+		h.Write([]byte(content.String()))
+	}
+	for _, block := range content.Array() {
+		for _, field := range []string{"type", "id", "tool_use_id", "name", "text", "input", "content"} {
+			h.Write([]byte{0})
+			h.Write([]byte(block.Get(field).Raw))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idleTimeout is how long a conversation's tracker stays in memory unused.
+const idleTimeout = 24 * time.Hour
+
+// sweepInterval is how often idle trackers are evicted.
+const sweepInterval = 10 * time.Minute
+
+type cachedTracker struct {
+	tracker  Tracker
+	lastUsed time.Time
+}
+
+// conversationLock serializes updates of one conversation. It is removed from
+// the map once no update holds or waits for it.
+type conversationLock struct {
+	sync.Mutex
+	refs int
+}
+
+var trackers = struct {
+	sync.Mutex
+	byKey     map[string]*cachedTracker
+	lastSweep time.Time
+	// conversation holds the locks of conversations being updated.
+	conversation map[string]*conversationLock
+}{byKey: make(map[string]*cachedTracker), conversation: make(map[string]*conversationLock)}
+
+// Update loads the tracker of the conversation key, advances it with messages
+// and stores it. It returns a copy of the updated tracker.
+func Update(key string, messages gjson.Result, now time.Time) Tracker {
+	lock := acquireConversation(key)
+	lock.Lock()
+	defer releaseConversation(key, lock)
+	tracker := Load(key)
+	tracker.Observe(messages, now)
+	save(key, tracker, now)
+	return *tracker
+}
+
+// Load returns a copy of the tracker of the conversation key, or a new tracker
+// when the conversation is unknown.
+func Load(key string) *Tracker {
+	trackers.Lock()
+	cached, ok := trackers.byKey[key]
+	trackers.Unlock()
+	if ok {
+		tracker := cached.tracker
+		return &tracker
+	}
+	tracker := New()
+	if convstore.Default().GetJSON(convstore.BucketPlanMode, key, tracker) {
+		return tracker
+	}
+	return New()
+}
+
+// save caches the tracker and persists it. Trackers idle for longer than
+// idleTimeout are evicted from the cache at most once per sweepInterval, so a
+// request does not walk every conversation.
+func save(key string, tracker *Tracker, now time.Time) {
+	trackers.Lock()
+	trackers.byKey[key] = &cachedTracker{tracker: *tracker, lastUsed: now}
+	if now.Sub(trackers.lastSweep) >= sweepInterval {
+		trackers.lastSweep = now
+		for other, cached := range trackers.byKey {
+			if now.Sub(cached.lastUsed) > idleTimeout {
+				delete(trackers.byKey, other)
+			}
+		}
+	}
+	trackers.Unlock()
+	_ = convstore.Default().PutJSON(convstore.BucketPlanMode, key, tracker)
+}
+
+func acquireConversation(key string) *conversationLock {
+	trackers.Lock()
+	defer trackers.Unlock()
+	lock, ok := trackers.conversation[key]
+	if !ok {
+		lock = &conversationLock{}
+		trackers.conversation[key] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+func releaseConversation(key string, lock *conversationLock) {
+	lock.Unlock()
+	trackers.Lock()
+	defer trackers.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(trackers.conversation, key)
+	}
+}
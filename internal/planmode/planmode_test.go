@@ -0,0 +1,125 @@
+package planmode
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	enterTurn    = `{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"EnterPlanMode","input":{}}]}`
+	enterResult  = `{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"Entered plan mode"}]}`
+	exitTurn     = `{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"ExitPlanMode","input":{"plan":"1. fix"}}]}`
+	rejectResult = `{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","is_error":true,"content":"no"}]}`
+	exitAgain    = `{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"ExitPlanMode","input":{"plan":"2. fix"}}]}`
+	approveTurn  = `{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","content":"approved"}]}`
+)
+
+func transcript(messages ...string) gjson.Result {
+	raw := "["
+	for i, message := range messages {
+		if i > 0 {
+			raw += ","
+		}
+		raw += message
+	}
+	return gjson.Parse(raw + "]")
+}
+
+func TestTrackerLifecycle(t *testing.T) {
+	now := time.Now()
+	tracker := New()
+	steps := []struct {
+		messages []string
+		phase    Phase
+	}{
+		{[]string{`{"role":"user","content":"hi"}`}, PhaseInactive},
+		{[]string{`{"role":"user","content":"hi"}`, enterTurn, enterResult}, PhasePlanning},
+		{[]string{`{"role":"user","content":"hi"}`, enterTurn, enterResult, exitTurn}, PhasePending},
+		{[]string{`{"role":"user","content":"hi"}`, enterTurn, enterResult, exitTurn, rejectResult}, PhasePlanning},
+		{[]string{`{"role":"user","content":"hi"}`, enterTurn, enterResult, exitTurn, rejectResult, exitAgain, approveTurn}, PhaseInactive},
+	}
+	for i, step := range steps {
+		tracker.Observe(transcript(step.messages...), now)
+		if tracker.Phase != step.phase {
+			t.Fatalf("step %d: phase = %s, want %s", i, tracker.Phase, step.phase)
+		}
+	}
+	if tracker.Approved != 1 || tracker.Rejected != 1 || tracker.Plan != "2. fix" {
+		t.Fatalf("unexpected tracker %+v", tracker)
+	}
+}
+
+func TestTrackerReplaysRewrittenHistory(t *testing.T) {
+	now := time.Now()
+	tracker := New()
+	tracker.Observe(transcript(`{"role":"user","content":"hi"}`, exitTurn), now)
+	if !tracker.Pending() {
+		t.Fatalf("expected pending plan, got %s", tracker.Phase)
+	}
+
+	// Cache control markers do not count as a history change.
+	tracker.Observe(transcript(`{"role":"user","content":"hi"}`,
+		`{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"ExitPlanMode","input":{"plan":"1. fix"},"cache_control":{"type":"ephemeral"}}]}`), now)
+	if !tracker.Pending() || tracker.Messages != 2 {
+		t.Fatalf("expected unchanged tracker, got %+v", tracker)
+	}
+
+	tracker.Observe(transcript(`{"role":"user","content":"start over"}`), now)
+	if tracker.Active() || tracker.Messages != 1 {
+		t.Fatalf("expected reset tracker, got %+v", tracker)
+	}
+}
+
+func TestTrackerEntersOnPlanModeReminder(t *testing.T) {
+	tracker := New()
+	tracker.Observe(transcript(`{"role":"user","content":[{"type":"text","text":"<system-reminder>Plan mode is active.</system-reminder>"}]}`), time.Now())
+	if tracker.Phase != PhasePlanning {
+		t.Fatalf("phase = %s, want planning", tracker.Phase)
+	}
+}
+
+func TestUpdateKeepsTrackersPerConversation(t *testing.T) {
+	now := time.Now()
+	Update("conv-a", transcript(enterTurn), now)
+	if got := Update("conv-a", transcript(enterTurn, enterResult, exitTurn), now); !got.Pending() {
+		t.Fatalf("conv-a phase = %s, want pending", got.Phase)
+	}
+	if got := Update("conv-b", transcript(`{"role":"user","content":"hi"}`), now); got.Active() {
+		t.Fatalf("conv-b phase = %s, want inactive", got.Phase)
+	}
+	if got := Load("conv-a"); got.Messages != 3 {
+		t.Fatalf("conv-a messages = %d, want 3", got.Messages)
+	}
+}
+
+func TestUpdateReleasesLocksAndSweepsIdleTrackers(t *testing.T) {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Update("conv-locks", transcript(`{"role":"user","content":"hi"}`), start)
+		}()
+	}
+	wg.Wait()
+	trackers.Lock()
+	locks := len(trackers.conversation)
+	trackers.Unlock()
+	if locks != 0 {
+		t.Fatalf("%d conversation locks left after all updates finished", locks)
+	}
+
+	Update("conv-idle", transcript(`{"role":"user","content":"hi"}`), start)
+	Update("conv-fresh", transcript(`{"role":"user","content":"hi"}`), start.Add(idleTimeout+sweepInterval))
+	trackers.Lock()
+	_, idle := trackers.byKey["conv-idle"]
+	_, fresh := trackers.byKey["conv-fresh"]
+	trackers.Unlock()
+	if idle || !fresh {
+		t.Fatalf("after sweep: idle cached = %v, fresh cached = %v", idle, fresh)
+	}
+}
@@ -0,0 +1,361 @@
+// Package promptguard scans tool metadata and tool results for prompt-injection
+// attempts before a request is forwarded upstream. Tool descriptions and tool
+// outputs usually come from third-party MCP servers, so the proxy treats them as
+// untrusted text and can either flag or neutralize suspicious instructions.
+package promptguard
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Mode selects how the scanner reacts to a match.
+type Mode string
+
+const (
+	// ModeOff disables scanning.
+	ModeOff Mode = "off"
+	// ModeFlag reports findings but leaves the payload untouched.
+	ModeFlag Mode = "flag"
+	// ModeNeutralize reports findings and replaces the matched text.
+	ModeNeutralize Mode = "neutralize"
+)
+
+// Replacement is the text substituted for neutralized matches.
+const Replacement = "[removed: possible prompt injection]"
+
+// ParseMode normalizes a configured mode string. Unknown values disable scanning.
+func ParseMode(value string) Mode {
+	switch Mode(strings.ToLower(strings.TrimSpace(value))) {
+	case ModeFlag:
+		return ModeFlag
+	case ModeNeutralize:
+		return ModeNeutralize
+	default:
+		return ModeOff
+	}
+}
+
+// Finding describes a single suspicious match.
+type Finding struct {
+	// Location is the JSON path of the scanned field.
+	Location string `json:"location"`
+	// Kind is either "tool_description" or "tool_result".
+	Kind string `json:"kind"`
+	// Rule names the pattern or heuristic that matched.
+	Rule string `json:"rule"`
+	// Excerpt is a short snippet around the match.
+	Excerpt string `json:"excerpt"`
+}
+
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var builtinRules = []rule{
+	{"ignore-previous", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,40}\b(previous|prior|above|earlier|all|system|original)\b[^.\n]{0,20}\b(instructions?|prompts?|rules|messages|directions)\b`)},
+	{"role-reassignment", regexp.MustCompile(`(?i)\byou are now\b|\bfrom now on,? you\b|\bact as (?:an? )?(?:unrestricted|jailbroken|different)\b`)},
+	{"new-instructions", regexp.MustCompile(`(?i)\b(new|updated|real|actual) (system )?instructions?\s*:`)},
+	// <system-reminder> is left out: Claude Code itself appends it to tool
+	// results and user turns.
+	{"fake-role-tag", regexp.MustCompile(`(?i)<\s*/?\s*(system|im_start|im_end|instructions?)\s*>|\n\s*(Human|Assistant|System)\s*:`)},
+	{"conceal-from-user", regexp.MustCompile(`(?i)\b(do not|don't|never)\s+(tell|inform|mention|reveal|show)\b[^.\n]{0,30}\b(user|human)\b`)},
+	{"exfiltration", regexp.MustCompile(`(?i)\b(send|post|upload|exfiltrate|forward)\b[^.\n]{0,60}\b(api[_ -]?keys?|credentials?|secrets?|tokens?|passwords?|\.env|ssh keys?)\b`)},
+}
+
+// imperativeVerbs feeds the density heuristic: a tool description that is
+// mostly second-person commands aimed at the model is unusual.
+var imperativeVerbs = regexp.MustCompile(`(?i)\b(you must|you should|always|never|immediately|before (responding|answering)|without asking|do not ask)\b`)
+
+const imperativeThreshold = 4
+
+// descriptionCacheLimit bounds the number of tool description verdicts kept per
+// scanner. Clients resend the same tool list on every turn, so caching by
+// content turns rescans of large MCP tool sets into map lookups.
+const descriptionCacheLimit = 4096
+
+// parallelScanThreshold is the number of uncached texts above which a payload
+// is scanned concurrently.
+const parallelScanThreshold = 32
+
+// Scanner applies the built-in rules plus any configured extra patterns.
+// It is safe for concurrent use.
+type Scanner struct {
+	rules []rule
+
+	mu           sync.RWMutex
+	descriptions map[string]scanResult
+}
+
+// scanResult is the outcome of ScanText for one text.
+type scanResult struct {
+	names       []string
+	excerpts    []string
+	neutralized string
+}
+
+var (
+	defaultScanner     *Scanner
+	defaultScannerOnce sync.Once
+)
+
+// Default returns a scanner using only the built-in rules.
+func Default() *Scanner {
+	defaultScannerOnce.Do(func() {
+		defaultScanner = &Scanner{rules: builtinRules}
+	})
+	return defaultScanner
+}
+
+// NewScanner builds a scanner with the built-in rules and extra regular
+// expressions. Invalid expressions are skipped and returned as errors.
+func NewScanner(patterns []string) (*Scanner, []error) {
+	s := &Scanner{rules: append([]rule(nil), builtinRules...)}
+	var errs []error
+	for _, raw := range patterns {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		compiled, err := regexp.Compile(raw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.rules = append(s.rules, rule{name: "custom:" + raw, pattern: compiled})
+	}
+	return s, errs
+}
+
+// ScanText returns the rule names matched in text, together with a neutralized
+// copy of the text.
+func (s *Scanner) ScanText(text string) ([]string, []string, string) {
+	if s == nil || strings.TrimSpace(text) == "" {
+		return nil, nil, text
+	}
+	var names, excerpts []string
+	out := text
+	for _, r := range s.rules {
+		loc := r.pattern.FindStringIndex(out)
+		if loc == nil {
+			continue
+		}
+		names = append(names, r.name)
+		excerpts = append(excerpts, excerpt(out, loc[0], loc[1]))
+		out = r.pattern.ReplaceAllString(out, Replacement)
+	}
+	if len(imperativeVerbs.FindAllStringIndex(text, -1)) >= imperativeThreshold {
+		names = append(names, "imperative-density")
+		excerpts = append(excerpts, excerpt(text, 0, 0))
+	}
+	return names, excerpts, out
+}
+
+// Scan inspects tool descriptions and tool results in payload according to the
+// request schema identified by format. In ModeNeutralize the returned payload
+// has matched spans replaced; otherwise it is the input unchanged.
+func (s *Scanner) Scan(format string, payload []byte, mode Mode) ([]byte, []Finding) {
+	if s == nil || mode == ModeOff || len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return payload, nil
+	}
+	var findings []Finding
+	out := payload
+	targets := targetsFor(format, payload)
+	results := s.scanTargets(targets)
+	for idx, target := range targets {
+		result := results[idx]
+		if len(result.names) == 0 {
+			continue
+		}
+		for i := range result.names {
+			findings = append(findings, Finding{Location: target.path, Kind: target.kind, Rule: result.names[i], Excerpt: result.excerpts[i]})
+		}
+		if mode == ModeNeutralize && result.neutralized != target.text {
+			if updated, err := sjson.SetBytes(out, target.path, result.neutralized); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out, findings
+}
+
+// scanTargets scans every target, reusing cached verdicts for tool descriptions
+// and spreading the remaining work across goroutines for large payloads.
+func (s *Scanner) scanTargets(targets []scanTarget) []scanResult {
+	results := make([]scanResult, len(targets))
+	pending := make([]int, 0, len(targets))
+	s.mu.RLock()
+	for i, target := range targets {
+		if target.kind == "tool_description" {
+			if cached, ok := s.descriptions[target.text]; ok {
+				results[i] = cached
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+	s.mu.RUnlock()
+	if len(pending) == 0 {
+		return results
+	}
+
+	scan := func(i int) {
+		names, excerpts, neutralized := s.ScanText(targets[i].text)
+		results[i] = scanResult{names: names, excerpts: excerpts, neutralized: neutralized}
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if len(pending) < parallelScanThreshold || workers < 2 {
+		for _, i := range pending {
+			scan(i)
+		}
+	} else {
+		next := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range next {
+					scan(i)
+				}
+			}()
+		}
+		for _, i := range pending {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+	}
+
+	s.mu.Lock()
+	for _, i := range pending {
+		if targets[i].kind != "tool_description" {
+			continue
+		}
+		if s.descriptions == nil || len(s.descriptions) >= descriptionCacheLimit {
+			s.descriptions = make(map[string]scanResult)
+		}
+		s.descriptions[targets[i].text] = results[i]
+	}
+	s.mu.Unlock()
+	return results
+}
+
+type scanTarget struct {
+	path string
+	kind string
+	text string
+}
+
+func targetsFor(format string, payload []byte) []scanTarget {
+	var targets []scanTarget
+	add := func(path, kind string, value gjson.Result) {
+		if value.Type == gjson.String {
+			targets = append(targets, scanTarget{path: path, kind: kind, text: value.String()})
+		}
+	}
+	addContent := func(path, kind string, value gjson.Result) {
+		if value.Type == gjson.String {
+			add(path, kind, value)
+			return
+		}
+		if value.IsArray() {
+			value.ForEach(func(idx, part gjson.Result) bool {
+				add(path+"."+idx.String()+".text", kind, part.Get("text"))
+				return true
+			})
+		}
+	}
+
+	root := gjson.ParseBytes(payload)
+	switch format {
+	case "claude":
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			add("tools."+i.String()+".description", "tool_description", tool.Get("description"))
+			return true
+		})
+		root.Get("messages").ForEach(func(i, msg gjson.Result) bool {
+			msg.Get("content").ForEach(func(j, block gjson.Result) bool {
+				if block.Get("type").String() == "tool_result" {
+					addContent("messages."+i.String()+".content."+j.String()+".content", "tool_result", block.Get("content"))
+				}
+				return true
+			})
+			return true
+		})
+	case "openai":
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			add("tools."+i.String()+".function.description", "tool_description", tool.Get("function.description"))
+			return true
+		})
+		root.Get("messages").ForEach(func(i, msg gjson.Result) bool {
+			if msg.Get("role").String() == "tool" {
+				addContent("messages."+i.String()+".content", "tool_result", msg.Get("content"))
+			}
+			return true
+		})
+	case "openai-response":
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			add("tools."+i.String()+".description", "tool_description", tool.Get("description"))
+			return true
+		})
+		root.Get("input").ForEach(func(i, item gjson.Result) bool {
+			if item.Get("type").String() == "function_call_output" {
+				add("input."+i.String()+".output", "tool_result", item.Get("output"))
+			}
+			return true
+		})
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if format == "gemini-cli" {
+			prefix = "request."
+			root = root.Get("request")
+		}
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			tool.Get("functionDeclarations").ForEach(func(j, decl gjson.Result) bool {
+				add(prefix+"tools."+i.String()+".functionDeclarations."+j.String()+".description", "tool_description", decl.Get("description"))
+				return true
+			})
+			return true
+		})
+		root.Get("contents").ForEach(func(i, content gjson.Result) bool {
+			content.Get("parts").ForEach(func(j, part gjson.Result) bool {
+				response := part.Get("functionResponse.response")
+				if !response.Exists() {
+					return true
+				}
+				base := prefix + "contents." + i.String() + ".parts." + j.String() + ".functionResponse.response"
+				response.ForEach(func(key, value gjson.Result) bool {
+					add(base+"."+key.String(), "tool_result", value)
+					return true
+				})
+				return true
+			})
+			return true
+		})
+	}
+	return targets
+}
+
+func excerpt(text string, start, end int) string {
+	const window = 40
+	from := start - window
+	if from < 0 {
+		from = 0
+	}
+	to := end + window
+	if to > len(text) {
+		to = len(text)
+	}
+	snippet := strings.Join(strings.Fields(text[from:to]), " ")
+	if len(snippet) > 2*window+40 {
+		snippet = snippet[:2*window+40]
+	}
+	return strings.ToValidUTF8(snippet, "")
+}
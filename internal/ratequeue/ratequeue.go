@@ -0,0 +1,218 @@
+// Package ratequeue holds back requests to a rate-limited upstream instead of
+// failing them. Once an upstream answers 429, requests for the same key (a
+// model) wait until its Retry-After has passed and are then released one at a
+// time, taking turns between the callers (client API keys) that wait, so a
+// single busy client cannot starve the others.
+package ratequeue
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned by Wait when the request could not be released
+// within the allowed wait.
+var ErrTimeout = errors.New("ratequeue: wait timed out")
+
+// waiter is one queued request.
+type waiter struct {
+	ready chan struct{}
+}
+
+type queue struct {
+	// until is the earliest time the next request may be released.
+	until time.Time
+	// callers lists the callers with waiters in turn order; waiting holds
+	// their waiters in arrival order.
+	callers []string
+	waiting map[string][]*waiter
+	timer   *time.Timer
+}
+
+func (q *queue) depth() int {
+	n := 0
+	for _, waiters := range q.waiting {
+		n += len(waiters)
+	}
+	return n
+}
+
+// Scheduler queues requests per key. The zero value is not usable; call New.
+type Scheduler struct {
+	mu       sync.Mutex
+	queues   map[string]*queue
+	interval time.Duration
+	observe  func(key string, depth int)
+}
+
+// New returns a Scheduler that, once a throttle has passed, releases one
+// request per interval.
+func New(interval time.Duration) *Scheduler {
+	return &Scheduler{queues: make(map[string]*queue), interval: interval}
+}
+
+// SetInterval changes the spacing between released requests.
+func (s *Scheduler) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	s.interval = interval
+	s.mu.Unlock()
+}
+
+// SetObserver registers fn to be called with the new depth of a key whenever
+// a request joins or leaves its queue. fn runs with the scheduler locked and
+// must not call back into it.
+func (s *Scheduler) SetObserver(fn func(key string, depth int)) {
+	s.mu.Lock()
+	s.observe = fn
+	s.mu.Unlock()
+}
+
+// Throttle holds back requests for key for d, as an upstream 429 with
+// Retry-After d asks. A throttle never shortens one already in place.
+func (s *Scheduler) Throttle(key string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queues[key]
+	if q == nil {
+		q = &queue{waiting: make(map[string][]*waiter)}
+		s.queues[key] = q
+	}
+	if until := time.Now().Add(d); until.After(q.until) {
+		q.until = until
+	}
+	s.dispatch(key, q)
+}
+
+// Wait returns once a request for key from caller may be sent: at once when
+// key is not throttled and nobody waits, otherwise when its turn comes. It
+// fails with ErrTimeout after maxWait, or with the context error. queued
+// reports whether the request had to wait.
+func (s *Scheduler) Wait(ctx context.Context, key, caller string, maxWait time.Duration) (queued bool, err error) {
+	s.mu.Lock()
+	q := s.queues[key]
+	if q == nil || (q.depth() == 0 && !time.Now().Before(q.until)) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	w := &waiter{ready: make(chan struct{})}
+	if len(q.waiting[caller]) == 0 {
+		q.callers = append(q.callers, caller)
+	}
+	q.waiting[caller] = append(q.waiting[caller], w)
+	s.changed(key, q)
+	s.dispatch(key, q)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return true, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = ErrTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-w.ready:
+		// Released while giving up; let the next request have the turn.
+		q.until = time.Now()
+		s.dispatch(key, q)
+	default:
+		s.remove(q, caller, w)
+		s.changed(key, q)
+	}
+	return true, err
+}
+
+// dispatch releases the next waiter of q when its throttle has passed, or
+// arms a timer for when it will. It must be called with s.mu held.
+func (s *Scheduler) dispatch(key string, q *queue) {
+	if len(q.callers) == 0 {
+		if q.timer != nil {
+			q.timer.Stop()
+			q.timer = nil
+		}
+		return
+	}
+	now := time.Now()
+	if now.Before(q.until) {
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+		q.timer = time.AfterFunc(q.until.Sub(now), func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.queues[key] == q {
+				s.dispatch(key, q)
+			}
+		})
+		return
+	}
+	caller := q.callers[0]
+	waiters := q.waiting[caller]
+	w := waiters[0]
+	if len(waiters) == 1 {
+		delete(q.waiting, caller)
+		q.callers = q.callers[1:]
+	} else {
+		q.waiting[caller] = waiters[1:]
+		q.callers = append(q.callers[1:], caller)
+	}
+	close(w.ready)
+	s.changed(key, q)
+	q.until = now.Add(s.interval)
+	s.dispatch(key, q)
+}
+
+func (s *Scheduler) changed(key string, q *queue) {
+	if s.observe != nil {
+		s.observe(key, q.depth())
+	}
+}
+
+func (s *Scheduler) remove(q *queue, caller string, w *waiter) {
+	waiters := q.waiting[caller]
+	for i, candidate := range waiters {
+		if candidate == w {
+			waiters = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) > 0 {
+		q.waiting[caller] = waiters
+		return
+	}
+	delete(q.waiting, caller)
+	for i, c := range q.callers {
+		if c == caller {
+			q.callers = append(q.callers[:i:i], q.callers[i+1:]...)
+			break
+		}
+	}
+}
+
+// Depth returns the number of waiting requests per key, omitting keys
+// nobody waits for.
+func (s *Scheduler) Depth() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int)
+	keys := make([]string, 0, len(s.queues))
+	for key := range s.queues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if n := s.queues[key].depth(); n > 0 {
+			out[key] = n
+		}
+	}
+	return out
+}
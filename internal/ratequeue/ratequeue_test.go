@@ -0,0 +1,94 @@
+package ratequeue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_PassesThroughWhenNotThrottled(t *testing.T) {
+	s := New(time.Millisecond)
+	queued, err := s.Wait(context.Background(), "m", "a", time.Second)
+	if queued || err != nil {
+		t.Fatalf("Wait = %v, %v; want immediate release", queued, err)
+	}
+}
+
+func TestScheduler_HonorsRetryAfterAndAlternatesCallers(t *testing.T) {
+	s := New(5 * time.Millisecond)
+	s.Throttle("m", 50*time.Millisecond)
+	start := time.Now()
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(caller string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Wait(context.Background(), "m", caller, time.Second); err != nil {
+				t.Errorf("Wait(%s): %v", caller, err)
+				return
+			}
+			mu.Lock()
+			order = append(order, caller)
+			mu.Unlock()
+		}()
+	}
+	// Join one at a time, so arrival order is fixed.
+	for i, caller := range []string{"busy", "busy", "busy", "quiet"} {
+		enqueue(caller)
+		for s.Depth()["m"] != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if depth := s.Depth()["m"]; depth != 4 {
+		t.Fatalf("depth = %d, want 4", depth)
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("released after %s, before the Retry-After passed", elapsed)
+	}
+	want := []string{"busy", "quiet", "busy", "busy"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("release order = %v, want %v", order, want)
+		}
+	}
+	if depth := s.Depth(); len(depth) != 0 {
+		t.Fatalf("depth after release = %v", depth)
+	}
+}
+
+func TestScheduler_TimesOutAndLeavesQueue(t *testing.T) {
+	s := New(time.Millisecond)
+	var depths []int
+	s.SetObserver(func(key string, depth int) { depths = append(depths, depth) })
+	s.Throttle("m", time.Hour)
+
+	queued, err := s.Wait(context.Background(), "m", "a", 10*time.Millisecond)
+	if !queued || !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait = %v, %v; want ErrTimeout", queued, err)
+	}
+	if depth := s.Depth(); len(depth) != 0 {
+		t.Fatalf("depth after timeout = %v", depth)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(depths) != 2 || depths[0] != 1 || depths[1] != 0 {
+		t.Fatalf("observed depths = %v, want [1 0]", depths)
+	}
+}
+
+func TestScheduler_ContextCancel(t *testing.T) {
+	s := New(time.Millisecond)
+	s.Throttle("m", time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Wait(ctx, "m", "a", time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, want context.Canceled", err)
+	}
+}
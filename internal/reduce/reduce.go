@@ -0,0 +1,192 @@
+// Package reduce shrinks a failing request to a minimal transcript that still
+// reproduces an upstream rejection. It is meant for offline diagnosis (see
+// cmd/fixturetool) and never runs on the request path.
+//
+// Reduction follows the ddmin delta-debugging scheme over three levels: whole
+// messages, content blocks inside the remaining messages, and tool
+// definitions. After every candidate edit tool calls and results are re-paired
+// so candidates stay well-formed and only the original failure is chased.
+package reduce
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Predicate reports whether a candidate payload still reproduces the failure.
+type Predicate func(ctx context.Context, payload []byte) (bool, error)
+
+// Stats summarizes a reduction.
+type Stats struct {
+	Attempts         int `json:"attempts"`
+	OriginalMessages int `json:"original_messages"`
+	ReducedMessages  int `json:"reduced_messages"`
+	OriginalTools    int `json:"original_tools"`
+	ReducedTools     int `json:"reduced_tools"`
+	OriginalBytes    int `json:"original_bytes"`
+	ReducedBytes     int `json:"reduced_bytes"`
+}
+
+// Request reduces payload, expressed in the given schema ("claude" or
+// "openai"), while reproduces keeps returning true. The original payload must
+// reproduce the failure.
+func Request(ctx context.Context, format string, payload []byte, reproduces Predicate) ([]byte, Stats, error) {
+	if format != "claude" && format != "openai" {
+		return nil, Stats{}, fmt.Errorf("reduce: unsupported format %q", format)
+	}
+	r := &reducer{format: format, check: reproduces}
+	stats := Stats{
+		OriginalMessages: len(gjson.GetBytes(payload, "messages").Array()),
+		OriginalTools:    len(gjson.GetBytes(payload, "tools").Array()),
+		OriginalBytes:    len(payload),
+	}
+	ok, err := r.try(ctx, payload)
+	if err != nil {
+		return nil, stats, err
+	}
+	if !ok {
+		return nil, stats, fmt.Errorf("reduce: original request does not reproduce the failure")
+	}
+
+	current := payload
+	if current, err = r.reduceArray(ctx, current, "messages"); err != nil {
+		return nil, stats, err
+	}
+	messages := gjson.GetBytes(current, "messages").Array()
+	for i := range messages {
+		path := fmt.Sprintf("messages.%d.content", i)
+		if !gjson.GetBytes(current, path).IsArray() {
+			continue
+		}
+		if current, err = r.reduceArray(ctx, current, path); err != nil {
+			return nil, stats, err
+		}
+	}
+	if current, err = r.reduceArray(ctx, current, "tools"); err != nil {
+		return nil, stats, err
+	}
+
+	stats.Attempts = r.attempts
+	stats.ReducedMessages = len(gjson.GetBytes(current, "messages").Array())
+	stats.ReducedTools = len(gjson.GetBytes(current, "tools").Array())
+	stats.ReducedBytes = len(current)
+	return current, stats, nil
+}
+
+type reducer struct {
+	format   string
+	check    Predicate
+	attempts int
+}
+
+func (r *reducer) try(ctx context.Context, payload []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.attempts++
+	return r.check(ctx, payload)
+}
+
+// reduceArray runs ddmin over the elements of the JSON array at path, keeping
+// the smallest subset for which the failure reproduces.
+func (r *reducer) reduceArray(ctx context.Context, payload []byte, path string) ([]byte, error) {
+	if len(gjson.GetBytes(payload, path).Array()) < 2 {
+		return payload, nil
+	}
+	raw := rawItems(gjson.GetBytes(payload, path))
+
+	current := payload
+	granularity := 2
+	for len(raw) >= 2 {
+		chunks := split(raw, granularity)
+		reduced := false
+		// Try each complement first: removing one chunk at a time converges
+		// faster on transcripts where the trigger is a single message.
+		for i := range chunks {
+			candidateItems := complement(chunks, i)
+			candidate, ok := r.build(current, path, candidateItems)
+			if !ok {
+				continue
+			}
+			reproduces, err := r.try(ctx, candidate)
+			if err != nil {
+				return nil, err
+			}
+			if reproduces {
+				// Re-read the array: pairing repair may have added placeholders.
+				raw, current = rawItems(gjson.GetBytes(candidate, path)), candidate
+				granularity = max(granularity-1, 2)
+				reduced = true
+				break
+			}
+		}
+		if reduced {
+			continue
+		}
+		if granularity >= len(raw) {
+			break
+		}
+		granularity = min(granularity*2, len(raw))
+	}
+	return current, nil
+}
+
+// build replaces the array at path and re-pairs tool calls and results.
+func (r *reducer) build(payload []byte, path string, items []string) ([]byte, bool) {
+	if len(items) == 0 {
+		return nil, false
+	}
+	candidate, err := sjson.SetRawBytes(payload, path, []byte("["+strings.Join(items, ",")+"]"))
+	if err != nil {
+		return nil, false
+	}
+	switch r.format {
+	case "claude":
+		candidate, _ = util.RepairClaudeToolPairs(candidate)
+	case "openai":
+		candidate, _ = util.RepairOpenAIToolPairs(candidate)
+	}
+	return candidate, true
+}
+
+func split(items []string, n int) [][]string {
+	if n > len(items) {
+		n = len(items)
+	}
+	chunks := make([][]string, 0, n)
+	size, rem := len(items)/n, len(items)%n
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + size
+		if i < rem {
+			end++
+		}
+		chunks = append(chunks, items[start:end])
+		start = end
+	}
+	return chunks
+}
+
+func complement(chunks [][]string, skip int) []string {
+	var out []string
+	for i, chunk := range chunks {
+		if i != skip {
+			out = append(out, chunk...)
+		}
+	}
+	return out
+}
+
+func rawItems(array gjson.Result) []string {
+	items := array.Array()
+	raw := make([]string, len(items))
+	for i, item := range items {
+		raw[i] = item.Raw
+	}
+	return raw
+}
@@ -0,0 +1,55 @@
+package reduce
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRequestFindsMinimalTranscript(t *testing.T) {
+	var messages []string
+	for i := 0; i < 12; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		text := fmt.Sprintf("turn %d", i)
+		if i == 7 {
+			text = "BAD"
+		}
+		messages = append(messages, fmt.Sprintf(`{"role":%q,"content":[{"type":"text","text":"filler"},{"type":"text","text":%q}]}`, role, text))
+	}
+	payload := []byte(`{"model":"m","tools":[{"name":"a"},{"name":"b"}],"messages":[` + strings.Join(messages, ",") + `]}`)
+
+	reproduces := func(_ context.Context, candidate []byte) (bool, error) {
+		return strings.Contains(string(candidate), "BAD"), nil
+	}
+	out, stats, err := Request(context.Background(), "claude", payload, reproduces)
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if got := gjson.GetBytes(out, "messages.#").Int(); got != 1 {
+		t.Fatalf("reduced messages = %d, want 1: %s", got, out)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content.#").Int(); got != 1 {
+		t.Fatalf("reduced blocks = %d, want 1: %s", got, out)
+	}
+	if got := gjson.GetBytes(out, "tools.#").Int(); got != 1 {
+		t.Fatalf("reduced tools = %d, want 1", got)
+	}
+	if stats.OriginalMessages != 12 || stats.ReducedMessages != 1 || stats.Attempts == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestRequestRejectsNonReproducingInput(t *testing.T) {
+	_, _, err := Request(context.Background(), "claude", []byte(`{"messages":[]}`), func(context.Context, []byte) (bool, error) {
+		return false, nil
+	})
+	if err == nil {
+		t.Fatal("expected an error when the original request does not reproduce")
+	}
+}
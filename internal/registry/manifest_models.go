@@ -0,0 +1,66 @@
+package registry
+
+import "sync"
+
+// manifestModels holds model definitions delivered by a remote model manifest,
+// keyed by provider. They extend the static definitions so new upstream models
+// can be served without upgrading the proxy.
+var (
+	manifestMu     sync.RWMutex
+	manifestModels map[string][]*ModelInfo
+)
+
+// SetManifestModels replaces the manifest-provided model definitions.
+func SetManifestModels(models map[string][]*ModelInfo) {
+	next := make(map[string][]*ModelInfo, len(models))
+	for provider, list := range models {
+		next[provider] = cloneModelInfosUnique(list)
+	}
+	manifestMu.Lock()
+	manifestModels = next
+	manifestMu.Unlock()
+}
+
+// MergeManifestModels returns models extended with the manifest definitions for
+// provider. A manifest entry replaces a static entry with the same ID.
+func MergeManifestModels(provider string, models []*ModelInfo) []*ModelInfo {
+	manifestMu.RLock()
+	extra := manifestModels[provider]
+	manifestMu.RUnlock()
+	if len(extra) == 0 {
+		return models
+	}
+	merged := make([]*ModelInfo, 0, len(models)+len(extra))
+	index := make(map[string]int, len(models)+len(extra))
+	for _, model := range models {
+		if model == nil {
+			continue
+		}
+		index[model.ID] = len(merged)
+		merged = append(merged, model)
+	}
+	for _, model := range extra {
+		copyModel := cloneModelInfo(model)
+		if pos, ok := index[model.ID]; ok {
+			merged[pos] = copyModel
+			continue
+		}
+		index[model.ID] = len(merged)
+		merged = append(merged, copyModel)
+	}
+	return merged
+}
+
+// lookupManifestModel searches the manifest definitions for modelID.
+func lookupManifestModel(modelID string) *ModelInfo {
+	manifestMu.RLock()
+	defer manifestMu.RUnlock()
+	for _, models := range manifestModels {
+		for _, model := range models {
+			if model != nil && model.ID == modelID {
+				return model
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package registry
+
+import "strings"
+
+// ModelCapabilities summarizes what a model accepts, for model listings that
+// clients use to configure themselves. Values are best-effort estimates from
+// the model metadata and family.
+type ModelCapabilities struct {
+	// ContextWindow is the input token limit, 0 when unknown.
+	ContextWindow int  `json:"context_window,omitempty"`
+	Tools         bool `json:"tools"`
+	Images        bool `json:"images"`
+	Thinking      bool `json:"thinking"`
+}
+
+// imageModelTypes are the model types whose models all accept images.
+var imageModelTypes = map[string]bool{
+	"claude": true, "gemini": true, "gemini-cli": true, "vertex": true, "aistudio": true, "antigravity": true,
+}
+
+// Capabilities returns the capabilities of the model. Models defined in the
+// config without metadata borrow it from the static definition of their
+// upstream model.
+func (m *ModelInfo) Capabilities() ModelCapabilities {
+	if m == nil {
+		return ModelCapabilities{}
+	}
+	info := m
+	if info.ContextLength == 0 && info.InputTokenLimit == 0 {
+		upstream := m.UpstreamID
+		if upstream == "" {
+			upstream = m.ID
+		}
+		if static := LookupStaticModelInfo(upstream); static != nil {
+			info = static
+		}
+	}
+	id := strings.ToLower(m.ID + " " + m.UpstreamID)
+
+	caps := ModelCapabilities{
+		ContextWindow: info.ContextLength,
+		Tools:         true,
+		Thinking:      info.Thinking != nil || m.Thinking != nil,
+	}
+	if caps.ContextWindow == 0 {
+		caps.ContextWindow = info.InputTokenLimit
+	}
+	if len(info.SupportedParameters) > 0 {
+		caps.Tools = false
+		for _, param := range info.SupportedParameters {
+			if param == "tools" {
+				caps.Tools = true
+			}
+		}
+	}
+	switch {
+	case imageModelTypes[info.Type] || imageModelTypes[m.Type]:
+		caps.Images = true
+	case strings.Contains(id, "claude"), strings.Contains(id, "gemini"), strings.Contains(id, "gpt-4o"),
+		strings.Contains(id, "gpt-4.1"), strings.Contains(id, "gpt-5"), strings.Contains(id, "-vl"):
+		caps.Images = true
+	}
+	return caps
+}
@@ -0,0 +1,21 @@
+package registry
+
+import "testing"
+
+func TestModelCapabilities(t *testing.T) {
+	claude := &ModelInfo{ID: "claude-sonnet-4-5-20250929", Type: "claude", ContextLength: 200000, Thinking: &ThinkingSupport{Min: 1024}}
+	if got := claude.Capabilities(); got != (ModelCapabilities{ContextWindow: 200000, Tools: true, Images: true, Thinking: true}) {
+		t.Fatalf("claude capabilities = %+v", got)
+	}
+
+	plain := &ModelInfo{ID: "text-only", Type: "openai-compatibility", SupportedParameters: []string{"temperature"}}
+	if got := plain.Capabilities(); got != (ModelCapabilities{}) {
+		t.Fatalf("plain capabilities = %+v", got)
+	}
+
+	// A config alias borrows the metadata of its upstream model.
+	alias := &ModelInfo{ID: "fast", Type: "gemini", UpstreamID: "gemini-2.5-flash"}
+	if got := alias.Capabilities(); got.ContextWindow == 0 || !got.Images {
+		t.Fatalf("alias capabilities = %+v", got)
+	}
+}
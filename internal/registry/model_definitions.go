@@ -852,6 +852,9 @@ func LookupStaticModelInfo(modelID string) *ModelInfo {
 	if modelID == "" {
 		return nil
 	}
+	if m := lookupManifestModel(modelID); m != nil {
+		return m
+	}
 
 	allModels := [][]*ModelInfo{
 		GetClaudeModels(),
@@ -52,6 +52,9 @@ type ModelInfo struct {
 	// This is optional and currently used for Gemini thinking budget normalization.
 	Thinking *ThinkingSupport `json:"thinking,omitempty"`
 
+	// UpstreamID is the model name sent upstream when ID is an alias of it.
+	UpstreamID string `json:"upstream_id,omitempty"`
+
 	// UserDefined indicates this model was defined through config file's models[]
 	// array (e.g., openai-compatibility.*.models[], *-api-key.models[]).
 	// UserDefined models have thinking configuration passed through without validation.
@@ -1024,6 +1027,10 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if len(model.SupportedParameters) > 0 {
 			result["supported_parameters"] = model.SupportedParameters
 		}
+		if model.UpstreamID != "" {
+			result["upstream_model"] = model.UpstreamID
+		}
+		result["capabilities"] = model.Capabilities()
 		return result
 
 	case "claude":
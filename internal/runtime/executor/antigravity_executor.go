@@ -4,7 +4,6 @@
 package executor
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/sha256"
@@ -345,8 +344,8 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 					log.Errorf("antigravity executor: close response body error: %v", errClose)
 				}
 			}()
-			scanner := bufio.NewScanner(resp.Body)
-			scanner.Buffer(nil, streamScannerBuffer)
+			scanner, releaseScanner := newStreamScanner(resp.Body)
+			defer releaseScanner()
 			for scanner.Scan() {
 				line := scanner.Bytes()
 				appendAPIResponseChunk(ctx, e.cfg, line)
@@ -364,7 +363,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 					reporter.publish(ctx, detail)
 				}
 
-				out <- cliproxyexecutor.StreamChunk{Payload: payload}
+				out <- cliproxyexecutor.StreamChunk{Payload: bytes.Clone(payload)}
 			}
 			if errScan := scanner.Err(); errScan != nil {
 				recordAPIResponseError(ctx, e.cfg, errScan)
@@ -711,8 +710,9 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 					log.Errorf("antigravity executor: close response body error: %v", errClose)
 				}
 			}()
-			scanner := bufio.NewScanner(resp.Body)
-			scanner.Buffer(nil, streamScannerBuffer)
+			scanner, releaseScanner := newStreamScanner(resp.Body)
+			defer releaseScanner()
+			originalRequest := bytes.Clone(opts.OriginalRequest)
 			var param any
 			for scanner.Scan() {
 				line := scanner.Bytes()
@@ -731,12 +731,12 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 					reporter.publish(ctx, detail)
 				}
 
-				chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, bytes.Clone(payload), &param)
+				chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, originalRequest, translated, bytes.Clone(payload), &param)
 				for i := range chunks {
 					out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[i])}
 				}
 			}
-			tail := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, []byte("[DONE]"), &param)
+			tail := sdktranslator.TranslateStream(ctx, to, from, req.Model, originalRequest, translated, []byte("[DONE]"), &param)
 			for i := range tail {
 				out <- cliproxyexecutor.StreamChunk{Payload: []byte(tail[i])}
 			}
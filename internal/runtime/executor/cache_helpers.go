@@ -1,8 +1,13 @@
 package executor
 
 import (
+	"context"
 	"sync"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/convstore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 )
 
 type codexCache struct {
@@ -48,11 +53,19 @@ func purgeExpiredCodexCache() {
 }
 
 // getCodexCache retrieves a cached entry, returning ok=false if not found or expired.
+// Entries missing from memory are looked up in the conversation store, so
+// sessions keep their upstream conversation ID across restarts.
 func getCodexCache(key string) (codexCache, bool) {
 	codexCacheCleanupOnce.Do(startCodexCacheCleanup)
 	codexCacheMu.RLock()
 	cache, ok := codexCacheMap[key]
 	codexCacheMu.RUnlock()
+	if !ok && convstore.Default().GetJSON(convstore.BucketConversations, codexStoreKey(key), &cache) {
+		ok = true
+		codexCacheMu.Lock()
+		codexCacheMap[key] = cache
+		codexCacheMu.Unlock()
+	}
 	if !ok || cache.Expire.Before(time.Now()) {
 		return codexCache{}, false
 	}
@@ -65,4 +78,24 @@ func setCodexCache(key string, cache codexCache) {
 	codexCacheMu.Lock()
 	codexCacheMap[key] = cache
 	codexCacheMu.Unlock()
+	_ = convstore.Default().PutJSON(convstore.BucketConversations, codexStoreKey(key), cache)
+}
+
+// codexStoreKey namespaces codex prompt cache keys in the conversation store.
+func codexStoreKey(key string) string {
+	return "codex:" + key
+}
+
+// tenantScopedKey prefixes a conversation key with the tenant of the calling
+// API key, so tenants that reuse a client session ID never share state.
+func tenantScopedKey(ctx context.Context, key string) string {
+	if ctx == nil {
+		return key
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if tenant := ginCtx.GetString(logging.GinTenantKey); tenant != "" {
+			return "tenant:" + tenant + ":" + key
+		}
+	}
+	return key
 }
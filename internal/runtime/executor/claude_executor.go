@@ -1,7 +1,6 @@
 package executor
 
 import (
-	"bufio"
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
@@ -212,6 +211,18 @@ func (e *ClaudeExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, r
 		data,
 		&param,
 	)
+	captureFixture(fixturePair{
+		Provider:          e.Identifier(),
+		SourceFormat:      from.String(),
+		TargetFormat:      to.String(),
+		Model:             baseModel,
+		Stream:            stream,
+		Request:           originalPayload,
+		TranslatedRequest: originalTranslated,
+		UpstreamRequest:   bodyForTranslation,
+		UpstreamResponse:  data,
+		Response:          []byte(out),
+	})
 	resp = cliproxyexecutor.Response{Payload: []byte(out)}
 	return resp, nil
 }
@@ -320,8 +331,8 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 
 		// If from == to (Claude → Claude), directly forward the SSE stream without translation
 		if from == to {
-			scanner := bufio.NewScanner(decodedBody)
-			scanner.Buffer(nil, 52_428_800) // 50MB
+			scanner, releaseScanner := newStreamScanner(decodedBody)
+			defer releaseScanner()
 			for scanner.Scan() {
 				line := scanner.Bytes()
 				appendAPIResponseChunk(ctx, e.cfg, line)
@@ -346,15 +357,21 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 		}
 
 		// For other formats, use translation
-		scanner := bufio.NewScanner(decodedBody)
-		scanner.Buffer(nil, 52_428_800) // 50MB
+		scanner, releaseScanner := newStreamScanner(decodedBody)
+		defer releaseScanner()
+		originalRequest := bytes.Clone(opts.OriginalRequest)
 		var param any
+		var guard claudeStreamGuard
 		for scanner.Scan() {
 			line := scanner.Bytes()
 			appendAPIResponseChunk(ctx, e.cfg, line)
 			if detail, ok := parseClaudeStreamUsage(line); ok {
 				reporter.publish(ctx, detail)
 			}
+			if eventType, reason := guard.check(line); reason != "" {
+				quarantineUpstreamEvent(ctx, e.Identifier(), eventType, reason, line)
+				continue
+			}
 			if isClaudeOAuthToken(apiKey) {
 				line = stripClaudeToolPrefixFromStreamLine(line, claudeToolPrefix)
 			}
@@ -363,7 +380,7 @@ func (e *ClaudeExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 				to,
 				from,
 				req.Model,
-				bytes.Clone(opts.OriginalRequest),
+				originalRequest,
 				bodyForTranslation,
 				bytes.Clone(line),
 				&param,
@@ -698,6 +715,12 @@ func claudeCreds(a *cliproxyauth.Auth) (apiKey, baseURL string) {
 			apiKey = v
 		}
 	}
+	// OAuth accounts may pin their own endpoint in the auth file.
+	if baseURL == "" && a.Metadata != nil {
+		if v, ok := a.Metadata["base_url"].(string); ok {
+			baseURL = strings.TrimSuffix(strings.TrimSpace(v), "/")
+		}
+	}
 	return
 }
 
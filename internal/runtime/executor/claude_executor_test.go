@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"testing"
 
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/tidwall/gjson"
 )
 
@@ -49,3 +50,14 @@ func TestStripClaudeToolPrefixFromStreamLine(t *testing.T) {
 		t.Fatalf("content_block.name = %q, want %q", got, "alpha")
 	}
 }
+
+func TestClaudeCredsUsesAccountBaseURL(t *testing.T) {
+	auth := &cliproxyauth.Auth{Metadata: map[string]any{"access_token": "tok", "base_url": "https://eu.example.com/ "}}
+	if key, base := claudeCreds(auth); key != "tok" || base != "https://eu.example.com" {
+		t.Fatalf("claudeCreds = %q, %q", key, base)
+	}
+	auth.Attributes = map[string]string{"base_url": "https://configured.example.com"}
+	if _, base := claudeCreds(auth); base != "https://configured.example.com" {
+		t.Fatalf("configured base URL lost: %q", base)
+	}
+}
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sanitize"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// fixtureCaptureEnv enables fixture capture when set to a non-empty value.
+	// A value of "1" or "true" writes into defaultFixtureDir; any other value is
+	// treated as the target directory.
+	fixtureCaptureEnv = "CLIPROXY_CAPTURE_FIXTURES"
+	defaultFixtureDir = "test/testdata/fixtures"
+)
+
+// capturedFixture is the on-disk layout of a captured request/response pair.
+// The field names mirror the loader in test/shared so goldens can be replayed
+// against the translator registry.
+type capturedFixture struct {
+	Provider          string          `json:"provider"`
+	SourceFormat      string          `json:"source_format"`
+	TargetFormat      string          `json:"target_format"`
+	Model             string          `json:"model"`
+	Stream            bool            `json:"stream"`
+	CapturedAt        string          `json:"captured_at"`
+	Request           json.RawMessage `json:"request"`
+	TranslatedRequest json.RawMessage `json:"translated_request"`
+	UpstreamRequest   json.RawMessage `json:"upstream_request"`
+	UpstreamResponse  json.RawMessage `json:"upstream_response"`
+	Response          json.RawMessage `json:"response"`
+}
+
+// fixturePair groups the payloads recorded by an executor for a single call.
+type fixturePair struct {
+	Provider          string
+	SourceFormat      string
+	TargetFormat      string
+	Model             string
+	Stream            bool
+	Request           []byte
+	TranslatedRequest []byte
+	UpstreamRequest   []byte
+	UpstreamResponse  []byte
+	Response          []byte
+}
+
+// fixtureIdentityPaths lists JSON paths that carry user identifiers and are
+// replaced by a fixed placeholder before a fixture is written.
+var fixtureIdentityPaths = []string{"metadata.user_id", "user", "prompt_cache_key", "safety_identifier"}
+
+// fixtureCaptureDir returns the capture directory, or an empty string when
+// capture mode is disabled.
+func fixtureCaptureDir() string {
+	value := strings.TrimSpace(os.Getenv(fixtureCaptureEnv))
+	switch strings.ToLower(value) {
+	case "", "0", "false", "off":
+		return ""
+	case "1", "true", "on":
+		return defaultFixtureDir
+	default:
+		return value
+	}
+}
+
+// captureFixture writes a sanitized request/response pair when capture mode is
+// enabled. File names are derived from the provider, formats, model and a hash of
+// the sanitized inbound request so repeated traffic overwrites the same golden.
+func captureFixture(pair fixturePair) {
+	dir := fixtureCaptureDir()
+	if dir == "" {
+		return
+	}
+	fixture := capturedFixture{
+		Provider:          pair.Provider,
+		SourceFormat:      pair.SourceFormat,
+		TargetFormat:      pair.TargetFormat,
+		Model:             pair.Model,
+		Stream:            pair.Stream,
+		CapturedAt:        time.Now().UTC().Format(time.RFC3339),
+		Request:           sanitizeFixturePayload(pair.Request),
+		TranslatedRequest: sanitizeFixturePayload(pair.TranslatedRequest),
+		UpstreamRequest:   sanitizeFixturePayload(pair.UpstreamRequest),
+		UpstreamResponse:  sanitizeFixturePayload(pair.UpstreamResponse),
+		Response:          sanitizeFixturePayload(pair.Response),
+	}
+	data, errMarshal := json.MarshalIndent(fixture, "", "  ")
+	if errMarshal != nil {
+		log.Debugf("fixture capture: marshal failed: %v", errMarshal)
+		return
+	}
+	if errMkdir := os.MkdirAll(dir, 0o755); errMkdir != nil {
+		log.Debugf("fixture capture: create directory %s failed: %v", dir, errMkdir)
+		return
+	}
+	path := filepath.Join(dir, fixtureFileName(pair, fixture.Request))
+	if errWrite := os.WriteFile(path, data, 0o644); errWrite != nil {
+		log.Debugf("fixture capture: write %s failed: %v", path, errWrite)
+	}
+}
+
+// fixtureFileName builds a stable, filesystem safe name for a fixture.
+func fixtureFileName(pair fixturePair, sanitizedRequest []byte) string {
+	sum := sha256.Sum256(sanitizedRequest)
+	parts := []string{pair.Provider, pair.SourceFormat, pair.TargetFormat, pair.Model}
+	if pair.Stream {
+		parts = append(parts, "stream")
+	}
+	for i := range parts {
+		parts[i] = fixtureNameSegment(parts[i])
+	}
+	return strings.Join(parts, "_") + "_" + hex.EncodeToString(sum[:6]) + ".json"
+}
+
+func fixtureNameSegment(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return "unknown"
+	}
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// sanitizeFixturePayload scrubs identifiers and credential-like strings from a
+// payload. Non-JSON payloads (for example raw SSE streams) are stored as a JSON
+// string so the fixture file always stays valid JSON.
+func sanitizeFixturePayload(payload []byte) json.RawMessage {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return json.RawMessage("null")
+	}
+	out := payload
+	if gjson.ValidBytes(out) {
+		for _, path := range fixtureIdentityPaths {
+			if gjson.GetBytes(out, path).Type == gjson.String {
+				if updated, errSet := sjson.SetBytes(out, path, "redacted"); errSet == nil {
+					out = updated
+				}
+			}
+		}
+	}
+	for _, pattern := range sanitize.SecretPatterns() {
+		out = pattern.ReplaceAll(out, []byte("REDACTED"))
+	}
+	if gjson.ValidBytes(out) {
+		return json.RawMessage(out)
+	}
+	quoted, errMarshal := json.Marshal(string(out))
+	if errMarshal != nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(quoted)
+}
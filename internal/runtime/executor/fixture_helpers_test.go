@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestCaptureFixture_WritesSanitizedStableFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(fixtureCaptureEnv, dir)
+
+	pair := fixturePair{
+		Provider:          "claude",
+		SourceFormat:      "openai",
+		TargetFormat:      "claude",
+		Model:             "claude-sonnet-4-5",
+		Request:           []byte(`{"model":"m","user":"alice@example.com","messages":[{"role":"user","content":"key sk-abcdefghijklmnopqrstuvwxyz"}]}`),
+		TranslatedRequest: []byte(`{"model":"m","metadata":{"user_id":"user_123"}}`),
+		UpstreamResponse:  []byte("event: message_start\ndata: {}\n"),
+		Response:          []byte(`{"id":"x"}`),
+	}
+	captureFixture(pair)
+	captureFixture(pair)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected a single stable fixture file, got %d", len(entries))
+	}
+	name := entries[0].Name()
+	if !strings.HasPrefix(name, "claude_openai_claude_claude-sonnet-4-5_") {
+		t.Fatalf("unexpected fixture name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if strings.Contains(string(data), "alice@example.com") || strings.Contains(string(data), "sk-abcdefghijklmnopqrstuvwxyz") || strings.Contains(string(data), "user_123") {
+		t.Fatalf("fixture was not sanitized: %s", data)
+	}
+	if got := gjson.GetBytes(data, "upstream_response").String(); !strings.HasPrefix(got, "event: message_start") {
+		t.Fatalf("expected SSE payload stored as string, got %q", got)
+	}
+}
+
+func TestCaptureFixture_DisabledByDefault(t *testing.T) {
+	t.Setenv(fixtureCaptureEnv, "")
+	if dir := fixtureCaptureDir(); dir != "" {
+		t.Fatalf("expected capture disabled, got dir %q", dir)
+	}
+}
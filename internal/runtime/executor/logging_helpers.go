@@ -149,7 +149,7 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 	if cfg == nil || !cfg.RequestLog {
 		return
 	}
-	data := bytes.TrimSpace(bytes.Clone(chunk))
+	data := bytes.TrimSpace(chunk)
 	if len(data) == 0 {
 		return
 	}
@@ -173,7 +173,7 @@ func appendAPIResponseChunk(ctx context.Context, cfg *config.Config, chunk []byt
 	if attempt.bodyHasContent {
 		attempt.response.WriteString("\n\n")
 	}
-	attempt.response.WriteString(string(data))
+	attempt.response.Write(data)
 	attempt.bodyHasContent = true
 
 	updateAggregatedResponse(ginCtx, attempts)
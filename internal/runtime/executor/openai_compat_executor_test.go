@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/toolmanifest"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAICompatToolManifestDeltaFallsBackToFullList(t *testing.T) {
+	var mu sync.Mutex
+	store := toolmanifest.NewStore(8, time.Hour)
+	var toolCounts []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		toolCounts = append(toolCounts, gjson.GetBytes(body, "tools.#").Int())
+		if _, err := store.Expand(body); err != nil {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintf(w, `{"error":{"code":%q}}`, toolmanifest.MismatchCode)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{OpenAICompatibility: []config.OpenAICompatibility{{Name: "peer", ToolManifestDelta: true}}}
+	executor := NewOpenAICompatExecutor("peer", cfg)
+	auth := &cliproxyauth.Auth{ID: "peer-auth", Provider: "peer", Attributes: map[string]string{"base_url": server.URL}}
+	tools := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		tools = append(tools, fmt.Sprintf(`{"type":"function","function":{"name":"tool_%d","description":%q}}`, i, strings.Repeat("x", 100)))
+	}
+	payload := []byte(`{"model":"m","messages":[{"role":"user","content":"manifest test"}],"tools":[` + strings.Join(tools, ",") + `]}`)
+	execute := func() {
+		_, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{Model: "m", Payload: payload}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai")})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+	}
+
+	execute()
+	execute()
+	// The upstream restarts and loses the list: the delta is rejected and resent in full.
+	mu.Lock()
+	store = toolmanifest.NewStore(8, time.Hour)
+	mu.Unlock()
+	execute()
+	if want := []int64{5, 0, 0, 5}; fmt.Sprint(toolCounts) != fmt.Sprint(want) {
+		t.Fatalf("tools sent per request = %v, want %v", toolCounts, want)
+	}
+}
+
+func TestOpenAICompatStreamRequestsUsage(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"id\":\"c1\",\"object\":\"chat.completion.chunk\",\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1,\"total_tokens\":4}}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("peer", &config.Config{})
+	auth := &cliproxyauth.Auth{ID: "peer-auth", Provider: "peer", Attributes: map[string]string{"base_url": server.URL}}
+	payload := []byte(`{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	for _, source := range []string{"claude", "openai"} {
+		stream, err := executor.ExecuteStream(context.Background(), auth, cliproxyexecutor.Request{Model: "m", Payload: payload}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString(source), Stream: true})
+		if err != nil {
+			t.Fatalf("%s: execute stream: %v", source, err)
+		}
+		for range stream {
+		}
+		requested := gjson.GetBytes(<-bodies, "stream_options.include_usage").Bool()
+		if requested != (source != "openai") {
+			t.Fatalf("%s: include_usage requested = %v", source, requested)
+		}
+	}
+}
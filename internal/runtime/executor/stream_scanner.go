@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// streamScannerInitialBuffer is the size of pooled line buffers. Longer lines
+// grow the scanner's buffer up to streamScannerBuffer as before.
+const streamScannerInitialBuffer = 64 << 10
+
+// streamBufferPool recycles the line buffers of upstream stream scanners, so
+// concurrent sessions do not each allocate and grow a fresh buffer.
+var streamBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, streamScannerInitialBuffer)
+		return &buf
+	},
+}
+
+// newStreamScanner returns a line scanner for an upstream stream using a
+// pooled buffer. Call release once the scanner and the lines it returned are
+// no longer used.
+func newStreamScanner(r io.Reader) (scanner *bufio.Scanner, release func()) {
+	buf := streamBufferPool.Get().(*[]byte)
+	scanner = bufio.NewScanner(r)
+	scanner.Buffer(*buf, streamScannerBuffer)
+	return scanner, func() { streamBufferPool.Put(buf) }
+}
@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// openAIStreamBody returns an OpenAI-compatible SSE body with n content deltas.
+func openAIStreamBody(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-5\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"token %d\"}}]}\n\n", i)
+	}
+	b.WriteString("data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"choices\":[],\"usage\":{\"prompt_tokens\":100,\"completion_tokens\":50,\"total_tokens\":150}}\n\n")
+	b.WriteString("data: [DONE]\n\n")
+	return b.Bytes()
+}
+
+// BenchmarkOpenAIStreamScan covers the per-line work every OpenAI-compatible
+// stream does before translation: scanning, usage parsing and shape checks.
+func BenchmarkOpenAIStreamScan(b *testing.B) {
+	body := openAIStreamBody(20000)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		scanner, release := newStreamScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			parseOpenAIStreamUsage(line)
+			if len(line) == 0 {
+				continue
+			}
+			if _, reason := checkOpenAIStreamLine(line); reason != "" {
+				b.Fatalf("unexpected reason %q", reason)
+			}
+		}
+		release()
+	}
+}
+
+func TestStreamLineChecksAllocationBudget(t *testing.T) {
+	line := []byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"hi"}}]}`)
+	allocs := testing.AllocsPerRun(100, func() {
+		parseOpenAIStreamUsage(line)
+		checkOpenAIStreamLine(line)
+	})
+	if allocs > 1 {
+		t.Fatalf("stream line checks: %.0f allocs, budget 1", allocs)
+	}
+}
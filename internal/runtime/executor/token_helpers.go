@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 	"github.com/tiktoken-go/tokenizer"
 )
 
@@ -234,3 +235,37 @@ func addIfNotEmpty(segments *[]string, value string) {
 		*segments = append(*segments, trimmed)
 	}
 }
+
+// imageTokenEstimate approximates the prompt cost of one image. Upstreams bill
+// images by pixel area rather than by the size of their base64 payload.
+const imageTokenEstimate = 1600
+
+// CountPromptTokens estimates the prompt tokens of an OpenAI chat completions
+// payload locally, without calling an upstream. Inline images are counted at a
+// flat estimate instead of tokenizing their base64 data.
+func CountPromptTokens(model string, payload []byte) (int64, error) {
+	enc, err := tokenizerForModel(model)
+	if err != nil {
+		return 0, fmt.Errorf("tokenizer init failed: %w", err)
+	}
+	images := int64(0)
+	stripped := payload
+	gjson.GetBytes(payload, "messages").ForEach(func(i, message gjson.Result) bool {
+		message.Get("content").ForEach(func(j, part gjson.Result) bool {
+			if part.Get("type").String() == "image_url" {
+				images++
+				path := "messages." + i.String() + ".content." + j.String() + ".image_url.url"
+				if updated, errSet := sjson.SetBytes(stripped, path, ""); errSet == nil {
+					stripped = updated
+				}
+			}
+			return true
+		})
+		return true
+	})
+	count, err := countOpenAIChatTokens(enc, stripped)
+	if err != nil {
+		return 0, err
+	}
+	return count + images*imageTokenEstimate, nil
+}
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// claudeStreamEventTypes lists the Claude Messages SSE event payload types that
+// translators understand.
+var claudeStreamEventTypes = map[string]struct{}{
+	"message_start": {}, "message_delta": {}, "message_stop": {},
+	"content_block_start": {}, "content_block_delta": {}, "content_block_stop": {},
+	"ping": {}, "error": {},
+}
+
+// claudeContentBlockTypes lists content block types accepted in content_block_start.
+var claudeContentBlockTypes = map[string]struct{}{
+	"text": {}, "tool_use": {}, "thinking": {}, "redacted_thinking": {},
+	"server_tool_use": {}, "web_search_tool_result": {}, "web_fetch_tool_result": {},
+}
+
+// claudeDeltaTypes lists delta types accepted in content_block_delta.
+var claudeDeltaTypes = map[string]struct{}{
+	"text_delta": {}, "input_json_delta": {}, "thinking_delta": {}, "signature_delta": {}, "citations_delta": {},
+}
+
+// checkClaudeStreamLine validates a Claude SSE line before translation. Only
+// "data:" lines are inspected; the returned reason is empty for known events.
+func checkClaudeStreamLine(line []byte) (eventType, reason string) {
+	payload, ok := sseDataPayload(line)
+	if !ok {
+		return "", ""
+	}
+	if !gjson.ValidBytes(payload) {
+		return "", "invalid_json"
+	}
+	root := gjson.ParseBytes(payload)
+	eventType = root.Get("type").String()
+	if _, known := claudeStreamEventTypes[eventType]; !known {
+		return eventType, "unknown_event"
+	}
+	switch eventType {
+	case "content_block_start":
+		if _, known := claudeContentBlockTypes[root.Get("content_block.type").String()]; !known {
+			return eventType + "/" + root.Get("content_block.type").String(), "unknown_content_block"
+		}
+	case "content_block_delta":
+		if _, known := claudeDeltaTypes[root.Get("delta.type").String()]; !known {
+			return eventType + "/" + root.Get("delta.type").String(), "unknown_delta"
+		}
+	}
+	return eventType, ""
+}
+
+// claudeStreamGuard checks the lines of one Claude stream. It remembers the
+// indices of content blocks whose start was quarantined, so their deltas and
+// stop are quarantined too instead of reaching translators as orphans.
+type claudeStreamGuard struct {
+	dropped map[int64]struct{}
+}
+
+// check validates a Claude SSE line like checkClaudeStreamLine, also
+// rejecting events of dropped content blocks.
+func (g *claudeStreamGuard) check(line []byte) (eventType, reason string) {
+	eventType, reason = checkClaudeStreamLine(line)
+	switch {
+	case reason == "unknown_content_block":
+		payload, _ := sseDataPayload(line)
+		if g.dropped == nil {
+			g.dropped = make(map[int64]struct{})
+		}
+		g.dropped[gjson.GetBytes(payload, "index").Int()] = struct{}{}
+	case len(g.dropped) > 0 && (eventType == "content_block_delta" || eventType == "content_block_stop" || reason == "unknown_delta"):
+		payload, _ := sseDataPayload(line)
+		index := gjson.GetBytes(payload, "index").Int()
+		if _, dropped := g.dropped[index]; dropped {
+			if eventType == "content_block_stop" {
+				delete(g.dropped, index)
+			}
+			return eventType, "dropped_content_block"
+		}
+	}
+	return eventType, reason
+}
+
+// checkOpenAIStreamLine validates an OpenAI-compatible chat completion chunk.
+// A chunk must carry choices, usage or an error object to be translated.
+func checkOpenAIStreamLine(line []byte) (eventType, reason string) {
+	payload, ok := sseDataPayload(line)
+	if !ok || bytes.Equal(payload, []byte("[DONE]")) {
+		return "", ""
+	}
+	if !gjson.ValidBytes(payload) {
+		return "", "invalid_json"
+	}
+	eventType = gjson.GetBytes(payload, "object").String()
+	if object := eventType; object != "" && object != "chat.completion.chunk" && object != "chat.completion" {
+		return object, "unknown_object"
+	}
+	// GetBytes copies what it returns, so count the choices instead of
+	// copying the array out of every chunk.
+	if !gjson.GetBytes(payload, "choices.#").Exists() && !gjson.GetBytes(payload, "usage").IsObject() && !gjson.GetBytes(payload, "error").Exists() {
+		return eventType, "unknown_shape"
+	}
+	return eventType, ""
+}
+
+func sseDataPayload(line []byte) ([]byte, bool) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return nil, false
+	}
+	payload := bytes.TrimSpace(line[len("data:"):])
+	return payload, len(payload) > 0
+}
+
+// quarantineUpstreamEvent keeps an unrecognized upstream event out of the
+// translated stream. The raw line is already part of the request log; here it is
+// counted and logged at debug level so new upstream shapes can be investigated.
+func quarantineUpstreamEvent(ctx context.Context, provider, eventType, reason string, line []byte) {
+	usage.RecordQuarantinedEvent(provider, eventType)
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+	excerpt := line
+	if len(excerpt) > 256 {
+		excerpt = excerpt[:256]
+	}
+	logging.Entry(ctx).WithFields(log.Fields{
+		"provider":   provider,
+		"event_type": eventType,
+		"reason":     reason,
+	}).Debugf("quarantined upstream event: %s", excerpt)
+}
@@ -0,0 +1,62 @@
+package executor
+
+import "testing"
+
+func TestCheckClaudeStreamLine(t *testing.T) {
+	cases := []struct {
+		line   string
+		reason string
+	}{
+		{`event: content_block_delta`, ""},
+		{`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`, ""},
+		{`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"t","name":"x","input":{}}}`, ""},
+		{`data: {"type":"content_block_delta","index":0,"delta":{"type":"mystery_delta","text":"noise"}}`, "unknown_delta"},
+		{`data: {"type":"telemetry","payload":"noise"}`, "unknown_event"},
+		{`data: {not json`, "invalid_json"},
+	}
+	for _, tc := range cases {
+		if _, reason := checkClaudeStreamLine([]byte(tc.line)); reason != tc.reason {
+			t.Errorf("checkClaudeStreamLine(%q) reason = %q, want %q", tc.line, reason, tc.reason)
+		}
+	}
+}
+
+func TestCheckOpenAIStreamLine(t *testing.T) {
+	cases := []struct {
+		line   string
+		reason string
+	}{
+		{`data: [DONE]`, ""},
+		{`data: {"object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"hi"}}]}`, ""},
+		{`data: {"choices":[],"usage":{"prompt_tokens":1}}`, ""},
+		{`data: {"object":"heartbeat"}`, "unknown_object"},
+		{`data: {"status":"processing"}`, "unknown_shape"},
+	}
+	for _, tc := range cases {
+		if _, reason := checkOpenAIStreamLine([]byte(tc.line)); reason != tc.reason {
+			t.Errorf("checkOpenAIStreamLine(%q) reason = %q, want %q", tc.line, reason, tc.reason)
+		}
+	}
+}
+
+func TestClaudeStreamGuardDropsEventsOfQuarantinedBlocks(t *testing.T) {
+	var guard claudeStreamGuard
+	lines := []struct {
+		line   string
+		reason string
+	}{
+		{`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`, ""},
+		{`data: {"type":"content_block_start","index":1,"content_block":{"type":"mcp_tool_use","id":"m","name":"x","input":{}}}`, "unknown_content_block"},
+		{`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{}"}}`, "dropped_content_block"},
+		{`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`, ""},
+		{`data: {"type":"content_block_stop","index":1}`, "dropped_content_block"},
+		{`data: {"type":"content_block_stop","index":0}`, ""},
+		{`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`, ""},
+		{`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"ok"}}`, ""},
+	}
+	for i, tc := range lines {
+		if _, reason := guard.check([]byte(tc.line)); reason != tc.reason {
+			t.Errorf("line %d reason = %q, want %q", i, reason, tc.reason)
+		}
+	}
+}
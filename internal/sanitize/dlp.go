@@ -0,0 +1,89 @@
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DefaultMaskReplacement is substituted for secrets when no replacement is configured.
+const DefaultMaskReplacement = "[MASKED]"
+
+type dlpRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// SecretMasker masks secrets in request text before it leaves the proxy.
+type SecretMasker struct {
+	rules       []dlpRule
+	replacement string
+}
+
+// NewSecretMasker compiles the given rules, falling back to DefaultDLPRules when
+// rules is empty. Invalid rules are reported and skipped.
+func NewSecretMasker(rules []config.DLPRule, replacement string) (*SecretMasker, []error) {
+	if len(rules) == 0 {
+		rules = DefaultDLPRules
+	}
+	if replacement == "" {
+		replacement = DefaultMaskReplacement
+	}
+	m := &SecretMasker{replacement: replacement}
+	var errs []error
+	for i, rule := range rules {
+		name := strings.TrimSpace(rule.Name)
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i+1)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dlp rule %s: %w", name, err))
+			continue
+		}
+		m.rules = append(m.rules, dlpRule{name: name, re: re})
+	}
+	return m, errs
+}
+
+// Mask replaces secrets in message content and tool results of a request in
+// the given schema. It returns the rewritten payload and the number of
+// redactions per rule name.
+func (m *SecretMasker) Mask(format string, payload []byte) ([]byte, map[string]int) {
+	if m == nil || len(m.rules) == 0 || len(payload) == 0 {
+		return payload, nil
+	}
+	out := payload
+	var counts map[string]int
+	for _, path := range requestTextPaths(format, payload) {
+		value := gjson.GetBytes(out, path)
+		if value.Type != gjson.String {
+			continue
+		}
+		text := value.String()
+		changed := false
+		for _, rule := range m.rules {
+			matches := rule.re.FindAllStringIndex(text, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+			counts[rule.name] += len(matches)
+			text = rule.re.ReplaceAllLiteralString(text, m.replacement)
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if updated, err := sjson.SetBytes(out, path, text); err == nil {
+			out = updated
+		}
+	}
+	return out, counts
+}
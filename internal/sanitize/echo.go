@@ -0,0 +1,351 @@
+package sanitize
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const defaultEchoMinChars = 32
+
+// EchoStripper removes a leading repeat of the prompt from assistant output.
+// Some upstreams start their answer by echoing the last user message or an
+// injected directive verbatim; the stripper holds back output while it still
+// matches such a candidate and drops the match once the real answer begins.
+//
+// Matching is conservative: a candidate is stripped when it was repeated in
+// full, or when at least MinChars of it were repeated up to a line break.
+// Anything else is released unchanged. A stripper serves a single response
+// and is not safe for concurrent use.
+type EchoStripper struct {
+	format     string
+	candidates []string
+	minChars   int
+
+	held     string
+	decided  bool
+	trimLead bool
+	removed  string
+}
+
+// NewEchoStripper returns a stripper for the response to request, or nil when
+// echo detection is disabled or the request has no candidate text.
+func NewEchoStripper(cfg config.EchoDedupConfig, format string, request []byte) *EchoStripper {
+	if !cfg.Enabled {
+		return nil
+	}
+	minChars := cfg.MinChars
+	if minChars <= 0 {
+		minChars = defaultEchoMinChars
+	}
+	var candidates []string
+	for _, text := range append([]string{lastUserText(format, request)}, cfg.Directives...) {
+		text = strings.TrimSpace(text)
+		if len(text) >= minChars && !slices.Contains(candidates, text) {
+			candidates = append(candidates, text)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return &EchoStripper{format: format, candidates: candidates, minChars: minChars}
+}
+
+// Response strips an echo from a complete response body. It reports whether
+// text was removed.
+func (s *EchoStripper) Response(payload []byte) ([]byte, bool) {
+	if s == nil || len(payload) == 0 {
+		return payload, false
+	}
+	paths := s.textPaths(payload)
+	if len(paths) == 0 {
+		return payload, false
+	}
+	text := gjson.GetBytes(payload, paths[0]).String()
+	out := s.feed(text, true)
+	if s.removed == "" {
+		return payload, false
+	}
+	if updated, err := sjson.SetBytes(payload, paths[0], out); err == nil {
+		payload = updated
+	}
+	return payload, true
+}
+
+// Chunk strips an echo from a stream chunk, which may be bare JSON or SSE
+// lines. Text that may still turn out to be an echo is held back and emitted
+// with a later chunk. It reports whether this chunk completed a strip.
+func (s *EchoStripper) Chunk(payload []byte) ([]byte, bool) {
+	if s == nil || len(payload) == 0 || !s.active() {
+		return payload, false
+	}
+	stripped := s.removed != ""
+	if gjson.ValidBytes(payload) {
+		payload, _ = s.event(payload)
+		return payload, !stripped && s.removed != ""
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	out := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		trimmed := bytes.TrimSpace(data)
+		if !found || !gjson.ValidBytes(trimmed) {
+			out = append(out, line)
+			continue
+		}
+		updated, release := s.event(trimmed)
+		if len(release) > 0 {
+			// Emit released text ahead of the event (and its event: line).
+			at := len(out)
+			if at > 0 && bytes.HasPrefix(out[at-1], []byte("event:")) {
+				at--
+			}
+			out = slices.Insert(out, at, release...)
+		}
+		if !bytes.Equal(updated, trimmed) {
+			separator := ""
+			if len(data) > 0 && data[0] == ' ' {
+				separator = " "
+			}
+			line = append([]byte("data:"+separator), updated...)
+		}
+		out = append(out, line)
+	}
+	return bytes.Join(out, []byte("\n")), !stripped && s.removed != ""
+}
+
+// active reports whether later chunks may still need rewriting.
+func (s *EchoStripper) active() bool {
+	if !s.decided || s.trimLead {
+		return true
+	}
+	// Responses API events repeat the full text after the deltas.
+	return s.removed != "" && s.format == "openai-response"
+}
+
+// event rewrites one stream event. Held text that has to be released by an
+// event without a text field is merged into it when the schema allows it,
+// and otherwise returned as SSE lines to emit before the event.
+func (s *EchoStripper) event(data []byte) ([]byte, [][]byte) {
+	root := gjson.ParseBytes(data)
+	if s.format == "openai-response" && root.Get("type").String() != "response.output_text.delta" {
+		if s.removed != "" {
+			for _, path := range responseTextPaths(s.format, data) {
+				text := gjson.GetBytes(data, path).String()
+				if trimmed, ok := strings.CutPrefix(text, s.removed); ok {
+					data, _ = sjson.SetBytes(data, path, trimmed)
+				}
+			}
+		}
+		if !s.decided && root.Get("type").String() == "response.output_text.done" {
+			if release := s.feed("", true); release != "" {
+				delta := []byte(`{"type":"response.output_text.delta"}`)
+				for _, key := range []string{"item_id", "output_index", "content_index"} {
+					if value := root.Get(key); value.Exists() {
+						delta, _ = sjson.SetRawBytes(delta, key, []byte(value.Raw))
+					}
+				}
+				delta, _ = sjson.SetBytes(delta, "delta", release)
+				return data, sseEvent("response.output_text.delta", delta)
+			}
+		}
+		return data, nil
+	}
+
+	final := s.finalEvent(root)
+	paths := s.textPaths(data)
+	for i, path := range paths {
+		text := gjson.GetBytes(data, path).String()
+		if out := s.feed(text, final && i == len(paths)-1); out != text {
+			data, _ = sjson.SetBytes(data, path, out)
+		}
+	}
+	if !final || s.decided {
+		return data, nil
+	}
+	release := s.feed("", true)
+	if release == "" {
+		return data, nil
+	}
+	switch s.format {
+	case "claude":
+		delta := []byte(`{"type":"content_block_delta","delta":{"type":"text_delta"}}`)
+		delta, _ = sjson.SetBytes(delta, "index", root.Get("index").Int())
+		delta, _ = sjson.SetBytes(delta, "delta.text", release)
+		return data, sseEvent("content_block_delta", delta)
+	case "openai":
+		data, _ = sjson.SetBytes(data, "choices.0.delta.content", release)
+	case "gemini", "gemini-cli":
+		path := geminiCandidatePrefix(s.format, data) + "content.parts"
+		part, _ := sjson.Set(`{}`, "text", release)
+		parts := "[" + part + "]"
+		if existing := strings.TrimSpace(gjson.GetBytes(data, path).Raw); len(existing) > 2 {
+			parts = "[" + part + "," + existing[1:]
+		}
+		data, _ = sjson.SetRawBytes(data, path, []byte(parts))
+	}
+	return data, nil
+}
+
+// feed consumes the next piece of assistant text and returns the text to emit
+// now. final marks the end of the text.
+func (s *EchoStripper) feed(text string, final bool) string {
+	if s.decided {
+		if !s.trimLead {
+			return text
+		}
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		s.removed += text[:len(text)-len(trimmed)]
+		if trimmed != "" {
+			s.trimLead = false
+		}
+		return trimmed
+	}
+	s.held += text
+	lead := len(s.held) - len(strings.TrimLeftFunc(s.held, unicode.IsSpace))
+	body := s.held[lead:]
+	if body == "" && !final {
+		return ""
+	}
+	pending, cut := false, 0
+	for _, candidate := range s.candidates {
+		n := commonPrefixLen(body, candidate)
+		switch {
+		case n == len(candidate):
+			cut = max(cut, n)
+		case n == len(body) && !final:
+			pending = true
+		case n >= s.minChars && candidate[n-1] == '\n':
+			cut = max(cut, n)
+		}
+	}
+	if pending {
+		return ""
+	}
+	s.decided = true
+	held := s.held
+	s.held = ""
+	if cut == 0 {
+		return held
+	}
+	s.removed = held[:lead+cut]
+	s.trimLead = true
+	return s.feed(held[lead+cut:], final)
+}
+
+// finalEvent reports whether a stream event ends the current text.
+func (s *EchoStripper) finalEvent(root gjson.Result) bool {
+	switch s.format {
+	case "claude":
+		return root.Get("type").String() == "content_block_stop"
+	case "openai":
+		return root.Get("choices.0.finish_reason").String() != ""
+	case "gemini", "gemini-cli":
+		if s.format == "gemini-cli" && root.Get("response").Exists() {
+			root = root.Get("response")
+		}
+		return root.Get("candidates.0.finishReason").String() != ""
+	}
+	return false
+}
+
+// textPaths returns the answer text paths of the first choice or candidate,
+// skipping Gemini thought parts.
+func (s *EchoStripper) textPaths(data []byte) []string {
+	prefix := ""
+	switch s.format {
+	case "openai":
+		prefix = "choices.0."
+	case "gemini", "gemini-cli":
+		prefix = geminiCandidatePrefix(s.format, data)
+	}
+	var paths []string
+	for _, path := range responseTextPaths(s.format, data) {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if strings.HasPrefix(s.format, "gemini") && gjson.GetBytes(data, strings.TrimSuffix(path, ".text")+".thought").Bool() {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func geminiCandidatePrefix(format string, data []byte) string {
+	if format == "gemini-cli" && gjson.GetBytes(data, "response").Exists() {
+		return "response.candidates.0."
+	}
+	return "candidates.0."
+}
+
+func sseEvent(name string, data []byte) [][]byte {
+	return [][]byte{[]byte("event: " + name), append([]byte("data: "), data...), nil}
+}
+
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// lastUserText returns the text of the last user message in a request of the
+// given schema, joining multiple text parts with newlines.
+func lastUserText(format string, request []byte) string {
+	root := gjson.ParseBytes(request)
+	var last gjson.Result
+	switch format {
+	case "openai", "claude":
+		root.Get("messages").ForEach(func(_, msg gjson.Result) bool {
+			if msg.Get("role").String() == "user" {
+				last = msg.Get("content")
+			}
+			return true
+		})
+	case "openai-response":
+		input := root.Get("input")
+		if input.Type == gjson.String {
+			return input.String()
+		}
+		input.ForEach(func(_, item gjson.Result) bool {
+			if item.Get("role").String() == "user" {
+				last = item.Get("content")
+			}
+			return true
+		})
+	case "gemini", "gemini-cli":
+		contents := root.Get("contents")
+		if format == "gemini-cli" && root.Get("request").Exists() {
+			contents = root.Get("request.contents")
+		}
+		contents.ForEach(func(_, content gjson.Result) bool {
+			if content.Get("role").String() == "user" {
+				last = content.Get("parts")
+			}
+			return true
+		})
+	}
+	if last.Type == gjson.String {
+		return last.String()
+	}
+	var texts []string
+	last.ForEach(func(_, part gjson.Result) bool {
+		switch part.Get("type").String() {
+		case "text", "input_text", "":
+			if text := part.Get("text"); text.Type == gjson.String {
+				texts = append(texts, text.String())
+			}
+		}
+		return true
+	})
+	return strings.Join(texts, "\n")
+}
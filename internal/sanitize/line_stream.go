@@ -0,0 +1,206 @@
+package sanitize
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// LineStream applies the per-line filters of a pipeline to a streamed
+// response. Assistant text is held back until its line is complete, so the
+// filters see the same lines as on the complete response; trailing blank lines
+// are held too, so end-of-text handling matches as well. Held text is released
+// when its text ends. A LineStream serves a single response and is not safe
+// for concurrent use.
+type LineStream struct {
+	pipeline *Pipeline
+	format   string
+	held     map[string]heldText
+}
+
+// LineStream returns a line-buffering rewriter for a stream in the given
+// schema, or nil when the pipeline has no per-line filters.
+func (p *Pipeline) LineStream(format string) *LineStream {
+	if !p.hasPerLine() {
+		return nil
+	}
+	switch format {
+	case "openai", "claude", "openai-response", "gemini", "gemini-cli":
+	default:
+		return nil
+	}
+	return &LineStream{pipeline: p, format: format, held: make(map[string]heldText)}
+}
+
+// Chunk rewrites one stream chunk, which may be bare JSON or SSE lines.
+func (s *LineStream) Chunk(payload []byte) []byte {
+	if s == nil || len(payload) == 0 {
+		return payload
+	}
+	if gjson.ValidBytes(payload) {
+		out, _ := s.event(payload)
+		return out
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	out := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		trimmed := bytes.TrimSpace(data)
+		if !found || !gjson.ValidBytes(trimmed) {
+			out = append(out, line)
+			continue
+		}
+		updated, release := s.event(trimmed)
+		if len(release) > 0 {
+			at := len(out)
+			if at > 0 && bytes.HasPrefix(out[at-1], []byte("event:")) {
+				at--
+			}
+			out = slices.Insert(out, at, release...)
+		}
+		if !bytes.Equal(updated, trimmed) {
+			line = append([]byte("data: "), updated...)
+		}
+		out = append(out, line)
+	}
+	return bytes.Join(out, []byte("\n"))
+}
+
+// event rewrites one stream event. Held text released by an event without a
+// text field is merged into it when the schema allows it, and otherwise
+// returned as SSE lines to emit before the event.
+func (s *LineStream) event(data []byte) ([]byte, [][]byte) {
+	root := gjson.ParseBytes(data)
+	switch s.format {
+	case "openai":
+		root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+			final := choice.Get("finish_reason").String() != ""
+			content := choice.Get("delta.content")
+			if content.Type != gjson.String && !final {
+				return true
+			}
+			text := content.String()
+			out := s.feed("choice:"+choice.Get("index").String(), text, final)
+			if out != text || (content.Type != gjson.String && out != "") {
+				data, _ = sjson.SetBytes(data, "choices."+i.String()+".delta.content", out)
+			}
+			return true
+		})
+	case "claude":
+		key := "block:" + root.Get("index").String()
+		switch root.Get("type").String() {
+		case "content_block_delta":
+			if root.Get("delta.type").String() == "text_delta" {
+				data, _ = sjson.SetBytes(data, "delta.text", s.feed(key, root.Get("delta.text").String(), false))
+			}
+		case "content_block_stop":
+			if release := s.feed(key, "", true); release != "" {
+				delta := []byte(`{"type":"content_block_delta","delta":{"type":"text_delta"}}`)
+				delta, _ = sjson.SetBytes(delta, "index", root.Get("index").Int())
+				delta, _ = sjson.SetBytes(delta, "delta.text", release)
+				return data, sseEvent("content_block_delta", delta)
+			}
+		}
+	case "openai-response":
+		key := root.Get("item_id").String() + "/" + root.Get("content_index").String()
+		switch root.Get("type").String() {
+		case "response.output_text.delta":
+			data, _ = sjson.SetBytes(data, "delta", s.feed(key, root.Get("delta").String(), false))
+			return data, nil
+		case "response.output_text.done":
+			release := s.feed(key, "", true)
+			data, _ = sjson.SetBytes(data, "text", s.pipeline.runLines(root.Get("text").String(), false, true))
+			if release == "" {
+				return data, nil
+			}
+			delta := []byte(`{"type":"response.output_text.delta"}`)
+			for _, field := range []string{"item_id", "output_index", "content_index"} {
+				if value := root.Get(field); value.Exists() {
+					delta, _ = sjson.SetRawBytes(delta, field, []byte(value.Raw))
+				}
+			}
+			delta, _ = sjson.SetBytes(delta, "delta", release)
+			return data, sseEvent("response.output_text.delta", delta)
+		}
+		// Item and response snapshots repeat complete texts.
+		for _, path := range responseTextPaths(s.format, data) {
+			data, _ = sjson.SetBytes(data, path, s.pipeline.runLines(gjson.GetBytes(data, path).String(), false, true))
+		}
+	case "gemini", "gemini-cli":
+		prefix := geminiCandidatePrefix(s.format, data)
+		final := gjson.GetBytes(data, prefix+"finishReason").String() != ""
+		var paths []string
+		gjson.GetBytes(data, prefix+"content.parts").ForEach(func(i, part gjson.Result) bool {
+			if part.Get("text").Type == gjson.String && !part.Get("thought").Bool() {
+				paths = append(paths, prefix+"content.parts."+i.String()+".text")
+			}
+			return true
+		})
+		for i, path := range paths {
+			text := gjson.GetBytes(data, path).String()
+			if out := s.feed("candidate", text, final && i == len(paths)-1); out != text {
+				data, _ = sjson.SetBytes(data, path, out)
+			}
+		}
+		if final && len(paths) == 0 {
+			if release := s.feed("candidate", "", true); release != "" {
+				path := prefix + "content.parts"
+				part, _ := sjson.Set(`{}`, "text", release)
+				parts := "[" + part + "]"
+				if existing := strings.TrimSpace(gjson.GetBytes(data, path).Raw); len(existing) > 2 {
+					parts = "[" + part + "," + existing[1:]
+				}
+				data, _ = sjson.SetRawBytes(data, path, []byte(parts))
+			}
+		}
+	}
+	return data, nil
+}
+
+// feed appends the next piece of the text identified by key and returns the
+// filtered text that can be emitted now. final marks the end of the text.
+func (s *LineStream) feed(key, text string, final bool) string {
+	held := s.held[key]
+	text = held.text + text
+	if final {
+		delete(s.held, key)
+		if text == "" {
+			return ""
+		}
+		return s.pipeline.runLines(text, held.cont, true)
+	}
+	cut := completeLines(text, held.cont)
+	if cut == 0 {
+		s.held[key] = heldText{text: text, cont: held.cont}
+		return ""
+	}
+	s.held[key] = heldText{text: text[cut:], cont: true}
+	return s.pipeline.runLines(text[:cut], held.cont, false)
+}
+
+// heldText is text of a stream that is not emitted yet. cont marks text that
+// starts with the line break ending the last emitted line.
+type heldText struct {
+	text string
+	cont bool
+}
+
+// completeLines returns the offset of the line break that ends the last
+// complete, non-blank line of text, or 0 when there is none. The line break
+// itself and any blank lines after it stay held, so that end-of-text filters
+// can still remove them.
+func completeLines(text string, cont bool) int {
+	segments := strings.Split(text, "\n")
+	offset, cut := 0, 0
+	for i, segment := range segments[:len(segments)-1] {
+		offset += len(segment)
+		if strings.TrimSpace(segment) != "" && (i > 0 || !cont) {
+			cut = offset
+		}
+		offset++
+	}
+	return cut
+}
@@ -0,0 +1,236 @@
+package sanitize
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Request applies the pipeline to user-authored text in a request payload of the
+// given schema (system prompts, message text and tool results). It returns the
+// rewritten payload and the number of text fields that changed.
+func (p *Pipeline) Request(format string, payload []byte) ([]byte, int) {
+	if p.Empty() || len(payload) == 0 {
+		return payload, 0
+	}
+	return p.rewrite(payload, requestTextPaths(format, payload), false)
+}
+
+// requestTextPaths lists the JSON paths of user-authored string fields in a
+// request of the given schema.
+func requestTextPaths(format string, payload []byte) []string {
+	var paths []string
+	root := gjson.ParseBytes(payload)
+	switch format {
+	case "claude":
+		paths = appendTextOrBlocks(paths, root.Get("system"), "system")
+		root.Get("messages").ForEach(func(i, msg gjson.Result) bool {
+			base := "messages." + i.String() + ".content"
+			content := msg.Get("content")
+			if content.Type == gjson.String {
+				paths = append(paths, base)
+				return true
+			}
+			content.ForEach(func(j, block gjson.Result) bool {
+				blockPath := base + "." + j.String()
+				switch block.Get("type").String() {
+				case "text":
+					paths = append(paths, blockPath+".text")
+				case "tool_result":
+					paths = appendTextOrBlocks(paths, block.Get("content"), blockPath+".content")
+				}
+				return true
+			})
+			return true
+		})
+	case "openai":
+		root.Get("messages").ForEach(func(i, msg gjson.Result) bool {
+			paths = appendTextOrBlocks(paths, msg.Get("content"), "messages."+i.String()+".content")
+			return true
+		})
+	case "openai-response":
+		if root.Get("instructions").Type == gjson.String {
+			paths = append(paths, "instructions")
+		}
+		input := root.Get("input")
+		if input.Type == gjson.String {
+			paths = append(paths, "input")
+			break
+		}
+		input.ForEach(func(i, item gjson.Result) bool {
+			base := "input." + i.String()
+			if item.Get("type").String() == "function_call_output" && item.Get("output").Type == gjson.String {
+				paths = append(paths, base+".output")
+				return true
+			}
+			paths = appendTextOrBlocks(paths, item.Get("content"), base+".content")
+			return true
+		})
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if format == "gemini-cli" {
+			prefix = "request."
+			root = root.Get("request")
+		}
+		for _, key := range []string{"systemInstruction", "system_instruction"} {
+			paths = appendGeminiParts(paths, root.Get(key+".parts"), prefix+key+".parts")
+		}
+		root.Get("contents").ForEach(func(i, content gjson.Result) bool {
+			paths = appendGeminiParts(paths, content.Get("parts"), prefix+"contents."+i.String()+".parts")
+			return true
+		})
+	}
+	return paths
+}
+
+// Response applies the pipeline to assistant text in a response of the given
+// schema. Streaming chunks may be bare JSON or SSE lines ("event:"/"data:");
+// for them only the filters that are safe on fragments are used.
+func (p *Pipeline) Response(format string, payload []byte, stream bool) ([]byte, int) {
+	if p.Empty() || len(payload) == 0 {
+		return payload, 0
+	}
+	if !stream || gjson.ValidBytes(payload) {
+		return p.responseJSON(format, payload, stream)
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	total := 0
+	for i, line := range lines {
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		if !found {
+			continue
+		}
+		trimmed := bytes.TrimSpace(data)
+		if !gjson.ValidBytes(trimmed) {
+			continue
+		}
+		updated, changed := p.responseJSON(format, trimmed, true)
+		if changed == 0 {
+			continue
+		}
+		total += changed
+		separator := ""
+		if len(data) > 0 && data[0] == ' ' {
+			separator = " "
+		}
+		lines[i] = append([]byte("data:"+separator), updated...)
+	}
+	if total == 0 {
+		return payload, 0
+	}
+	return bytes.Join(lines, []byte("\n")), total
+}
+
+func (p *Pipeline) responseJSON(format string, payload []byte, stream bool) ([]byte, int) {
+	return p.rewrite(payload, responseTextPaths(format, payload), stream)
+}
+
+// responseTextPaths lists the JSON paths of assistant text in a response body
+// or stream event of the given schema, in output order.
+func responseTextPaths(format string, payload []byte) []string {
+	var paths []string
+	root := gjson.ParseBytes(payload)
+	switch format {
+	case "claude":
+		if root.Get("delta.type").String() == "text_delta" {
+			paths = append(paths, "delta.text")
+		}
+		root.Get("content").ForEach(func(i, block gjson.Result) bool {
+			if block.Get("type").String() == "text" {
+				paths = append(paths, "content."+i.String()+".text")
+			}
+			return true
+		})
+	case "openai":
+		root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+			base := "choices." + i.String()
+			for _, key := range []string{".message.content", ".delta.content"} {
+				if choice.Get(strings.TrimPrefix(key, ".")).Type == gjson.String {
+					paths = append(paths, base+key)
+				}
+			}
+			return true
+		})
+	case "openai-response":
+		switch root.Get("type").String() {
+		case "response.output_text.delta":
+			paths = append(paths, "delta")
+		case "response.output_text.done":
+			paths = append(paths, "text")
+		}
+		output, base := root.Get("output"), "output"
+		if !output.Exists() {
+			output, base = root.Get("response.output"), "response.output"
+		}
+		output.ForEach(func(i, item gjson.Result) bool {
+			item.Get("content").ForEach(func(j, part gjson.Result) bool {
+				if part.Get("type").String() == "output_text" {
+					paths = append(paths, base+"."+i.String()+".content."+j.String()+".text")
+				}
+				return true
+			})
+			return true
+		})
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if format == "gemini-cli" && root.Get("response").Exists() {
+			prefix = "response."
+			root = root.Get("response")
+		}
+		root.Get("candidates").ForEach(func(i, candidate gjson.Result) bool {
+			paths = appendGeminiParts(paths, candidate.Get("content.parts"), prefix+"candidates."+i.String()+".content.parts")
+			return true
+		})
+	}
+	return paths
+}
+
+func (p *Pipeline) rewrite(payload []byte, paths []string, delta bool) ([]byte, int) {
+	out := payload
+	changed := 0
+	for _, path := range paths {
+		original := gjson.GetBytes(out, path)
+		if original.Type != gjson.String {
+			continue
+		}
+		filtered := p.run(original.String(), delta)
+		if filtered == original.String() {
+			continue
+		}
+		updated, err := sjson.SetBytes(out, path, filtered)
+		if err != nil {
+			continue
+		}
+		out = updated
+		changed++
+	}
+	return out, changed
+}
+
+// appendTextOrBlocks adds path when value is a string, or the text of every
+// text-typed block when value is an array of content blocks.
+func appendTextOrBlocks(paths []string, value gjson.Result, path string) []string {
+	if value.Type == gjson.String {
+		return append(paths, path)
+	}
+	value.ForEach(func(i, block gjson.Result) bool {
+		switch block.Get("type").String() {
+		case "text", "input_text":
+			paths = append(paths, path+"."+i.String()+".text")
+		}
+		return true
+	})
+	return paths
+}
+
+func appendGeminiParts(paths []string, parts gjson.Result, path string) []string {
+	parts.ForEach(func(i, part gjson.Result) bool {
+		if part.Get("text").Type == gjson.String {
+			paths = append(paths, path+"."+i.String()+".text")
+		}
+		return true
+	})
+	return paths
+}
@@ -0,0 +1,267 @@
+// Package sanitize implements the configurable content sanitization pipeline.
+// A pipeline is an ordered list of named filters that rewrite text; it is applied
+// to user-authored request text before it is forwarded upstream and to assistant
+// text before it is returned to the client.
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Filter names accepted in configuration.
+const (
+	FilterStripANSI            = "strip-ansi"
+	FilterStripControl         = "strip-control"
+	FilterStripSystemReminders = "strip-system-reminders"
+	FilterRedactSecrets        = "redact-secrets"
+	FilterMaxLength            = "max-length"
+	FilterTrimTrailingSpace    = "trim-trailing-whitespace"
+	FilterNormalizeCodeFences  = "normalize-code-fences"
+	FilterReplace              = "replace"
+)
+
+// DefaultSecretReplacement replaces matches of redact-secrets when the filter
+// does not configure its own replacement.
+const DefaultSecretReplacement = "[REDACTED]"
+
+var (
+	systemReminderPattern = regexp.MustCompile(`(?s)<system-reminder>.*?</system-reminder>\s*`)
+	codeFencePattern      = regexp.MustCompile("^([ \t]*)(`{3,}|~{3,})[ \t]*([^`\\s]*)[ \t]*$")
+)
+
+type step struct {
+	name  string
+	apply func(string) string
+	// wholeText marks filters that only make sense on complete texts and are
+	// skipped for streaming deltas.
+	wholeText bool
+	// perLine marks filters that rewrite one line at a time. Streams run them
+	// on complete lines through a LineStream instead of on deltas.
+	perLine bool
+	// end, when set, runs once on the end of a text after the per-line pass.
+	end func(string) string
+}
+
+// Pipeline applies an ordered list of filters. The zero value and a nil
+// pipeline are valid and leave text unchanged.
+type Pipeline struct {
+	steps []step
+}
+
+// New builds a pipeline from configuration. Unknown filter names and invalid
+// patterns are reported and skipped so one bad entry does not disable the rest.
+func New(filters []config.SanitizeFilter) (*Pipeline, []error) {
+	p := &Pipeline{}
+	var errs []error
+	for _, filter := range filters {
+		name := strings.ToLower(strings.TrimSpace(filter.Name))
+		switch name {
+		case FilterStripANSI, FilterStripControl:
+			apply := StripTerminalEscapes
+			if name == FilterStripControl {
+				apply = stripControl
+			}
+			if filter.PreserveCodeBlocks {
+				// Fences are only known on complete texts.
+				p.steps = append(p.steps, step{name: name, apply: func(s string) string {
+					return outsideCodeFences(s, apply)
+				}, wholeText: true})
+				continue
+			}
+			p.steps = append(p.steps, step{name: name, apply: apply})
+		case FilterStripSystemReminders:
+			p.steps = append(p.steps, step{name: name, apply: func(s string) string {
+				return systemReminderPattern.ReplaceAllString(s, "")
+			}, wholeText: true})
+		case FilterRedactSecrets:
+			patterns := filter.Patterns
+			if len(patterns) == 0 {
+				patterns = DefaultSecretPatterns
+			}
+			replacement := filter.Replacement
+			if replacement == "" {
+				replacement = DefaultSecretReplacement
+			}
+			var compiled []*regexp.Regexp
+			for _, pattern := range patterns {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					continue
+				}
+				compiled = append(compiled, re)
+			}
+			p.steps = append(p.steps, step{name: name, apply: func(s string) string {
+				for _, re := range compiled {
+					s = re.ReplaceAllLiteralString(s, replacement)
+				}
+				return s
+			}})
+		case FilterMaxLength:
+			limit := filter.MaxLength
+			if limit <= 0 {
+				errs = append(errs, fmt.Errorf("%s: max-length must be positive", name))
+				continue
+			}
+			p.steps = append(p.steps, step{name: name, apply: func(s string) string {
+				return truncateRunes(s, limit)
+			}, wholeText: true})
+		case FilterTrimTrailingSpace:
+			p.steps = append(p.steps, step{name: name, apply: func(s string) string {
+				return strings.TrimRight(s, " \t\r")
+			}, perLine: true, end: func(s string) string {
+				return strings.TrimRightFunc(s, unicode.IsSpace)
+			}})
+		case FilterNormalizeCodeFences:
+			p.steps = append(p.steps, step{name: name, apply: normalizeCodeFence, perLine: true})
+		case FilterReplace:
+			if len(filter.Patterns) == 0 {
+				errs = append(errs, fmt.Errorf("%s: patterns are required", name))
+				continue
+			}
+			var compiled []*regexp.Regexp
+			for _, pattern := range filter.Patterns {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					continue
+				}
+				compiled = append(compiled, re)
+			}
+			replacement := filter.Replacement
+			p.steps = append(p.steps, step{name: name, apply: func(s string) string {
+				for _, re := range compiled {
+					s = re.ReplaceAllString(s, replacement)
+				}
+				return s
+			}, perLine: true})
+		default:
+			errs = append(errs, fmt.Errorf("unknown sanitize filter %q", filter.Name))
+		}
+	}
+	return p, errs
+}
+
+// Empty reports whether the pipeline has no filters.
+func (p *Pipeline) Empty() bool {
+	return p == nil || len(p.steps) == 0
+}
+
+// Names returns the filter names in application order.
+func (p *Pipeline) Names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, 0, len(p.steps))
+	for _, s := range p.steps {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// Text runs every filter on a complete text.
+func (p *Pipeline) Text(s string) string {
+	return p.run(s, false)
+}
+
+// Delta runs the filters that are safe on a streaming fragment.
+func (p *Pipeline) Delta(s string) string {
+	return p.run(s, true)
+}
+
+func (p *Pipeline) run(s string, delta bool) string {
+	if p == nil {
+		return s
+	}
+	for _, st := range p.steps {
+		switch {
+		case delta && (st.wholeText || st.perLine):
+		case st.perLine:
+			s = st.lines(s, false, true)
+		default:
+			s = st.apply(s)
+		}
+	}
+	return s
+}
+
+// lines applies a per-line filter to every line of s. cont marks s as
+// starting with the line break of a line that was already filtered. The end
+// hook runs when last marks the end of the text.
+func (st step) lines(s string, cont, last bool) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		if i > 0 || !cont {
+			lines[i] = st.apply(lines[i])
+		}
+	}
+	s = strings.Join(lines, "\n")
+	if last && st.end != nil {
+		s = st.end(s)
+	}
+	return s
+}
+
+// hasPerLine reports whether the pipeline has filters that need a LineStream
+// on streamed responses.
+func (p *Pipeline) hasPerLine() bool {
+	return p != nil && slices.ContainsFunc(p.steps, func(st step) bool { return st.perLine })
+}
+
+// runLines applies the per-line filters to text made of complete lines, see
+// step.lines.
+func (p *Pipeline) runLines(text string, cont, last bool) string {
+	for _, st := range p.steps {
+		if st.perLine {
+			text = st.lines(text, cont, last)
+		}
+	}
+	return text
+}
+
+// normalizeCodeFence rewrites a Markdown fence line to its canonical form,
+// with the info string attached to the fence and no trailing whitespace.
+// Indentation is kept because it ties the block to an enclosing list item.
+func normalizeCodeFence(line string) string {
+	match := codeFencePattern.FindStringSubmatch(line)
+	if match == nil {
+		return line
+	}
+	return match[1] + match[2] + match[3]
+}
+
+// stripControl removes terminal escape sequences, then the C0/C1 control
+// characters left except tab, newline and carriage return.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			return -1
+		default:
+			return r
+		}
+	}, StripTerminalEscapes(s))
+}
+
+func truncateRunes(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	count := 0
+	for i := range s {
+		if count == limit {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
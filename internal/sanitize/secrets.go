@@ -0,0 +1,66 @@
+package sanitize
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// SecretDetector is a named pattern matching one kind of credential.
+type SecretDetector struct {
+	Name    string
+	Pattern string
+}
+
+// SecretDetectors is the one set of credential detectors shared by the
+// redact-secrets filter, outbound secret masking, fixture capture and the
+// fixture anonymizer.
+var SecretDetectors = []SecretDetector{
+	{Name: "aws-access-key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws-secret-key", Pattern: `(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`},
+	{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "bearer-token", Pattern: `(?i)\bbearer\s+[A-Za-z0-9_\-.=~+/]{16,}`},
+	{Name: "openai-key", Pattern: `\bsk-[A-Za-z0-9_\-]{16,}`},
+	{Name: "github-token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}`},
+	{Name: "google-api-key", Pattern: `\bAIza[0-9A-Za-z_\-]{30,}`},
+	{Name: "google-oauth-token", Pattern: `\bya29\.[0-9A-Za-z_\-.]+`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`},
+}
+
+// DefaultSecretPatterns are used by redact-secrets when no patterns are configured.
+var DefaultSecretPatterns = secretDetectorPatterns()
+
+// DefaultDLPRules are the secret detectors used by secret masking when none are configured.
+var DefaultDLPRules = secretDetectorRules()
+
+var compiledSecretDetectors = compileSecretDetectors()
+
+// SecretPatterns returns the compiled SecretDetectors, in order.
+func SecretPatterns() []*regexp.Regexp {
+	return slices.Clone(compiledSecretDetectors)
+}
+
+func secretDetectorPatterns() []string {
+	patterns := make([]string, 0, len(SecretDetectors))
+	for _, detector := range SecretDetectors {
+		patterns = append(patterns, detector.Pattern)
+	}
+	return patterns
+}
+
+func secretDetectorRules() []config.DLPRule {
+	rules := make([]config.DLPRule, 0, len(SecretDetectors))
+	for _, detector := range SecretDetectors {
+		rules = append(rules, config.DLPRule{Name: detector.Name, Pattern: detector.Pattern})
+	}
+	return rules
+}
+
+func compileSecretDetectors() []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(SecretDetectors))
+	for _, detector := range SecretDetectors {
+		compiled = append(compiled, regexp.MustCompile(detector.Pattern))
+	}
+	return compiled
+}
@@ -0,0 +1,173 @@
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Shell block actions.
+const (
+	ShellBlockForward   = "forward"
+	ShellBlockTruncate  = "truncate"
+	ShellBlockSummarize = "summarize"
+)
+
+const (
+	defaultShellBlockMinChars  = 2000
+	defaultShellBlockHeadChars = 1000
+	defaultShellBlockTailChars = 1000
+	summaryNotableLines        = 10
+	summaryTailLines           = 3
+)
+
+// shellBlockTags are the tagged blocks Claude Code uses for shell command
+// output, background shell polling results and hook output.
+var shellBlockTags = []string{
+	"bash-stdout", "bash-stderr",
+	"local-command-stdout", "local-command-stderr",
+	"stdout", "stderr",
+	"user-prompt-submit-hook",
+	"system-reminder",
+}
+
+var (
+	shellBlockPatterns = compileShellBlockPatterns()
+	hookReminder       = regexp.MustCompile(`(?i)\bhook\b`)
+	notableLine        = regexp.MustCompile(`(?i)\b(error|fail(ed|ure)?|warn(ing)?|exception|panic|traceback|fatal)\b`)
+)
+
+func compileShellBlockPatterns() map[string]*regexp.Regexp {
+	patterns := make(map[string]*regexp.Regexp, len(shellBlockTags))
+	for _, tag := range shellBlockTags {
+		patterns[tag] = regexp.MustCompile(`(?s)(<` + tag + `>)(.*?)(</` + tag + `>)`)
+	}
+	return patterns
+}
+
+// ShellBlockPolicy shortens oversized shell output and hook result blocks in
+// request text so they do not crowd out the conversation.
+type ShellBlockPolicy struct {
+	action   string
+	minChars int
+	head     int
+	tail     int
+}
+
+// NewShellBlockPolicy returns nil when cfg forwards blocks unchanged.
+func NewShellBlockPolicy(cfg config.ShellOutputPolicy) *ShellBlockPolicy {
+	action := strings.ToLower(strings.TrimSpace(cfg.Action))
+	if action != ShellBlockTruncate && action != ShellBlockSummarize {
+		return nil
+	}
+	p := &ShellBlockPolicy{action: action, minChars: cfg.MinChars, head: cfg.HeadChars, tail: cfg.TailChars}
+	if p.minChars <= 0 {
+		p.minChars = defaultShellBlockMinChars
+	}
+	if p.head <= 0 {
+		p.head = defaultShellBlockHeadChars
+	}
+	if p.tail <= 0 {
+		p.tail = defaultShellBlockTailChars
+	}
+	return p
+}
+
+// Apply rewrites oversized blocks in the request text of payload and returns
+// the payload together with the number of rewritten blocks.
+func (p *ShellBlockPolicy) Apply(format string, payload []byte) ([]byte, int) {
+	if p == nil || len(payload) == 0 {
+		return payload, 0
+	}
+	out := payload
+	total := 0
+	for _, path := range requestTextPaths(format, payload) {
+		value := gjson.GetBytes(out, path)
+		if value.Type != gjson.String {
+			continue
+		}
+		text, n := p.Text(value.String())
+		if n == 0 {
+			continue
+		}
+		updated, err := sjson.SetBytes(out, path, text)
+		if err != nil {
+			continue
+		}
+		out = updated
+		total += n
+	}
+	return out, total
+}
+
+// Text rewrites oversized blocks in text and reports how many were rewritten.
+// System reminders are only treated as hook output when they mention a hook.
+func (p *ShellBlockPolicy) Text(text string) (string, int) {
+	if p == nil {
+		return text, 0
+	}
+	count := 0
+	for _, tag := range shellBlockTags {
+		if !strings.Contains(text, "<"+tag+">") {
+			continue
+		}
+		pattern := shellBlockPatterns[tag]
+		text = pattern.ReplaceAllStringFunc(text, func(block string) string {
+			parts := pattern.FindStringSubmatch(block)
+			body := parts[2]
+			if len(body) < p.minChars || (tag == "system-reminder" && !hookReminder.MatchString(body)) {
+				return block
+			}
+			count++
+			return parts[1] + p.shorten(tag, body) + parts[3]
+		})
+	}
+	return text, count
+}
+
+func (p *ShellBlockPolicy) shorten(tag, body string) string {
+	if p.action == ShellBlockSummarize {
+		return summarizeBlock(tag, body)
+	}
+	runes := []rune(body)
+	if len(runes) <= p.head+p.tail {
+		return body
+	}
+	omitted := len(runes) - p.head - p.tail
+	return string(runes[:p.head]) + fmt.Sprintf("\n[... %d characters omitted ...]\n", omitted) + string(runes[len(runes)-p.tail:])
+}
+
+// summarizeBlock replaces a block with its size, the lines that look like
+// errors or warnings, and the final lines, which usually carry the outcome.
+func summarizeBlock(tag, body string) string {
+	lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n[%s summarized: %d lines, %d characters]\n", tag, len(lines), len(body))
+	tailStart := len(lines) - summaryTailLines
+	if tailStart < 0 {
+		tailStart = 0
+	}
+	notable := 0
+	for _, line := range lines[:tailStart] {
+		if notable == summaryNotableLines {
+			break
+		}
+		if notableLine.MatchString(line) {
+			b.WriteString(line)
+			b.WriteByte('\n')
+			notable++
+		}
+	}
+	if tailStart > 0 {
+		b.WriteString("[...]\n")
+	}
+	for _, line := range lines[tailStart:] {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
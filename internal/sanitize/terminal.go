@@ -0,0 +1,152 @@
+package sanitize
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// StripTerminalEscapes removes terminal escape sequences from s: CSI
+// sequences (colors, cursor movement, erasing) with all their parameter and
+// intermediate bytes, OSC, DCS, SOS, PM and APC strings up to their
+// terminator, and the short ESC sequences such as charset selection. Both the
+// 7-bit (ESC [) and 8-bit (U+009B) introducers are recognized. An unterminated
+// sequence is dropped up to the end of s, so no parameter bytes are left
+// behind as "[1m" remnants.
+func StripTerminalEscapes(s string) string {
+	if !strings.ContainsAny(s, "\x1b\u009b\u009d\u0090\u0098\u009e\u009f") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch r {
+		case 0x1b:
+			i = skipEscape(s, i+size)
+		case 0x9b:
+			i = skipCSI(s, i+size)
+		case 0x9d, 0x90, 0x98, 0x9e, 0x9f:
+			i = skipString(s, i+size)
+		default:
+			b.WriteString(s[i : i+size])
+			i += size
+		}
+	}
+	return b.String()
+}
+
+// skipEscape returns the index after the escape sequence whose ESC ends
+// before i.
+func skipEscape(s string, i int) int {
+	if i >= len(s) {
+		return i
+	}
+	switch s[i] {
+	case '[':
+		return skipCSI(s, i+1)
+	case ']', 'P', 'X', '^', '_':
+		return skipString(s, i+1)
+	}
+	// nF sequences (ESC ( B, ...) take intermediate bytes before the final
+	// byte; Fp, Fe and Fs sequences (ESC 7, ESC =, ESC c) are a single byte.
+	for i < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+		i++
+	}
+	if i < len(s) && s[i] >= 0x30 && s[i] <= 0x7e {
+		i++
+	}
+	return i
+}
+
+// skipCSI returns the index after the parameter, intermediate and final
+// bytes of a control sequence starting at i.
+func skipCSI(s string, i int) int {
+	for i < len(s) && s[i] >= 0x20 && s[i] <= 0x3f {
+		i++
+	}
+	if i < len(s) && s[i] >= 0x40 && s[i] <= 0x7e {
+		i++
+	}
+	return i
+}
+
+// skipString returns the index after the string terminator (ESC \, U+009C or
+// BEL) of a control string starting at i.
+func skipString(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case s[i] == 0x07:
+			return i + 1
+		case s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\':
+			return i + 2
+		case strings.HasPrefix(s[i:], "\u009c"):
+			return i + len("\u009c")
+		}
+		i++
+	}
+	return i
+}
+
+// outsideCodeFences applies fn to the parts of s outside fenced Markdown code
+// blocks, leaving the blocks, fence lines included, unchanged. An unclosed
+// block runs to the end of s.
+func outsideCodeFences(s string, fn func(string) string) string {
+	if !strings.Contains(s, "```") && !strings.Contains(s, "~~~") {
+		return fn(s)
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	fence := ""
+	start := 0
+	flush := func(end int, inside bool) {
+		if inside {
+			b.WriteString(s[start:end])
+		} else {
+			b.WriteString(fn(s[start:end]))
+		}
+		start = end
+	}
+	for pos := 0; pos < len(s); {
+		end := strings.IndexByte(s[pos:], '\n')
+		if end < 0 {
+			end = len(s)
+		} else {
+			end += pos + 1
+		}
+		marker := fenceMarker(s[pos:end])
+		switch {
+		case fence == "" && marker != "":
+			flush(pos, false)
+			fence = marker
+		case fence != "" && marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) && closingFence(s[pos:end]):
+			flush(end, true)
+			fence = ""
+		}
+		pos = end
+	}
+	flush(len(s), fence != "")
+	return b.String()
+}
+
+// fenceMarker returns the run of backticks or tildes opening line when it is
+// a code fence line, or "".
+func fenceMarker(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// closingFence reports whether a fence line carries nothing after its marker.
+func closingFence(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	return strings.TrimSpace(strings.TrimLeft(trimmed, trimmed[:1])) == ""
+}
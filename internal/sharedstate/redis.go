@@ -0,0 +1,179 @@
+// Package sharedstate provides backends that let several proxy replicas share
+// account health and credential rotation state.
+package sharedstate
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultKeyPrefix = "cliproxy:"
+	// opTimeout bounds Redis calls made on the request path so an unhealthy
+	// backend falls back to local state quickly.
+	opTimeout = 250 * time.Millisecond
+)
+
+// CooldownEvent is the message exchanged between replicas when a credential
+// model becomes unavailable or recovers.
+type CooldownEvent struct {
+	Origin string    `json:"origin"`
+	AuthID string    `json:"auth_id"`
+	Model  string    `json:"model"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// ApplyFunc receives cooldowns announced by other replicas.
+type ApplyFunc func(authID, model string, until time.Time, reason string)
+
+// Redis shares cooldowns through keys with a TTL (so replicas starting later can
+// catch up) plus a pub/sub channel for immediate propagation, and keeps
+// round-robin cursors in INCR counters.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	origin string
+}
+
+// NewRedis connects to Redis and verifies the connection.
+func NewRedis(ctx context.Context, cfg config.RedisConfig) (*Redis, error) {
+	addr := strings.TrimSpace(cfg.Addr)
+	if addr == "" {
+		return nil, fmt.Errorf("sharedstate: redis addr is empty")
+	}
+	opts := &redis.Options{
+		Addr:     addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	client := redis.NewClient(opts)
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("sharedstate: redis ping failed: %w", err)
+	}
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = defaultKeyPrefix
+	}
+	return &Redis{client: client, prefix: prefix, origin: newOrigin()}, nil
+}
+
+func newOrigin() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
+
+func (r *Redis) channel() string { return r.prefix + "cooldowns" }
+
+func (r *Redis) cooldownKey(authID, model string) string {
+	return r.prefix + "cooldown:" + authID + ":" + model
+}
+
+// PublishModelCooldown stores and broadcasts a cooldown. Failures are logged.
+func (r *Redis) PublishModelCooldown(ctx context.Context, authID, model string, until time.Time, reason string) {
+	event := CooldownEvent{Origin: r.origin, AuthID: authID, Model: model, Until: until, Reason: reason}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	opCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opTimeout)
+	defer cancel()
+	pipe := r.client.TxPipeline()
+	key := r.cooldownKey(authID, model)
+	if ttl := time.Until(until); !until.IsZero() && ttl > 0 {
+		pipe.Set(opCtx, key, payload, ttl)
+	} else {
+		pipe.Del(opCtx, key)
+	}
+	pipe.Publish(opCtx, r.channel(), payload)
+	if _, err = pipe.Exec(opCtx); err != nil {
+		log.Debugf("sharedstate: publish cooldown for %s/%s failed: %v", authID, model, err)
+	}
+}
+
+// NextCursor increments the shared round-robin counter for key.
+func (r *Redis) NextCursor(ctx context.Context, key string) (int64, bool) {
+	opCtx, cancel := context.WithTimeout(ctx, opTimeout)
+	defer cancel()
+	value, err := r.client.Incr(opCtx, r.prefix+"rr:"+key).Result()
+	if err != nil {
+		log.Debugf("sharedstate: cursor increment for %s failed: %v", key, err)
+		return 0, false
+	}
+	return value, true
+}
+
+// Run loads the cooldowns currently stored in Redis, then applies cooldowns
+// published by other replicas until ctx is cancelled.
+func (r *Redis) Run(ctx context.Context, apply ApplyFunc) {
+	if apply == nil {
+		return
+	}
+	sub := r.client.Subscribe(ctx, r.channel())
+	defer func() { _ = sub.Close() }()
+
+	iter := r.client.Scan(ctx, 0, r.prefix+"cooldown:*", 100).Iterator()
+	for iter.Next(ctx) {
+		payload, err := r.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			continue
+		}
+		r.dispatch(payload, apply, true)
+	}
+	if err := iter.Err(); err != nil && ctx.Err() == nil {
+		log.Warnf("sharedstate: loading cooldowns failed: %v", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			r.dispatch([]byte(msg.Payload), apply, false)
+		}
+	}
+}
+
+func (r *Redis) dispatch(payload []byte, apply ApplyFunc, includeOwn bool) {
+	var event CooldownEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Debugf("sharedstate: ignoring malformed cooldown event: %v", err)
+		return
+	}
+	if event.AuthID == "" || event.Model == "" || (!includeOwn && event.Origin == r.origin) {
+		return
+	}
+	apply(event.AuthID, event.Model, event.Until, event.Reason)
+}
+
+// Close releases the Redis connection.
+func (r *Redis) Close() error {
+	if r == nil || r.client == nil {
+		return nil
+	}
+	return r.client.Close()
+}
@@ -222,13 +222,16 @@ func (s *PostgresStore) Save(ctx context.Context, auth *cliproxyauth.Auth) (stri
 		if errMarshal != nil {
 			return "", fmt.Errorf("postgres store: marshal metadata: %w", errMarshal)
 		}
-		if existing, errRead := os.ReadFile(path); errRead == nil {
+		if existing, errRead := misc.ReadCredentialFile(path); errRead == nil {
 			if jsonEqual(existing, raw) {
 				return path, nil
 			}
 		} else if errRead != nil && !errors.Is(errRead, fs.ErrNotExist) {
 			return "", fmt.Errorf("postgres store: read existing metadata: %w", errRead)
 		}
+		if raw, errMarshal = misc.EncryptCredentials(raw); errMarshal != nil {
+			return "", fmt.Errorf("postgres store: encrypt metadata: %w", errMarshal)
+		}
 		tmp := path + ".tmp"
 		if errWrite := os.WriteFile(tmp, raw, 0o600); errWrite != nil {
 			return "", fmt.Errorf("postgres store: write temp auth file: %w", errWrite)
@@ -284,8 +287,13 @@ func (s *PostgresStore) List(ctx context.Context) ([]*cliproxyauth.Auth, error)
 			log.WithError(errPath).Warnf("postgres store: skipping auth %s outside spool", id)
 			continue
 		}
+		plain, errDecrypt := misc.DecryptCredentials([]byte(payload))
+		if errDecrypt != nil {
+			log.WithError(errDecrypt).Warnf("postgres store: skipping auth %s", id)
+			continue
+		}
 		metadata := make(map[string]any)
-		if err = json.Unmarshal([]byte(payload), &metadata); err != nil {
+		if err = json.Unmarshal(plain, &metadata); err != nil {
 			log.WithError(err).Warnf("postgres store: skipping auth %s with invalid json", id)
 			continue
 		}
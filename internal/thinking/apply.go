@@ -3,12 +3,17 @@ package thinking
 
 import (
 	"strings"
+	"sync"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
 
+// providerAppliersMu guards providerAppliers, which providers may register
+// while requests are being served.
+var providerAppliersMu sync.RWMutex
+
 // providerAppliers maps provider names to their ProviderApplier implementations.
 var providerAppliers = map[string]ProviderApplier{
 	"gemini":      nil,
@@ -23,11 +28,15 @@ var providerAppliers = map[string]ProviderApplier{
 // GetProviderApplier returns the ProviderApplier for the given provider name.
 // Returns nil if the provider is not registered.
 func GetProviderApplier(provider string) ProviderApplier {
+	providerAppliersMu.RLock()
+	defer providerAppliersMu.RUnlock()
 	return providerAppliers[provider]
 }
 
 // RegisterProvider registers a provider applier by name.
 func RegisterProvider(name string, applier ProviderApplier) {
+	providerAppliersMu.Lock()
+	defer providerAppliersMu.Unlock()
 	providerAppliers[name] = applier
 }
 
@@ -0,0 +1,298 @@
+// Package toolmanifest shrinks repeated tool declarations between two proxies.
+// The sending side remembers, per conversation, the tool list the upstream has
+// accepted and afterwards sends only the tools that changed, together with the
+// ordered tool names and the hashes of the previous and the new list. The
+// receiving side keeps recently seen tool lists by hash, rebuilds the full list
+// and rejects the request with a mismatch error when it cannot, upon which the
+// sender resends the full list.
+package toolmanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Field is the request body field carrying the manifest description.
+const Field = "cliproxy_tool_manifest"
+
+// MismatchCode is the error code of a rejected delta.
+const MismatchCode = "tool_manifest_mismatch"
+
+// ErrMismatch reports a delta whose base list is unknown or whose rebuilt list
+// does not match its hash.
+var ErrMismatch = errors.New("tool manifest mismatch: resend the full tool list")
+
+// Manifest is an ordered tool list with per-tool digests.
+type Manifest struct {
+	// Hash identifies the whole list, including its order.
+	Hash string
+	// Names lists the tool names in order.
+	Names []string
+	// Tools maps tool names to their raw JSON declarations.
+	Tools map[string]string
+
+	digests map[string]string
+}
+
+// FromPayload builds the manifest of the tools array of a request. It returns
+// nil when the request has no tools or tools without a unique name.
+func FromPayload(payload []byte) *Manifest {
+	tools := gjson.GetBytes(payload, "tools")
+	if !tools.IsArray() {
+		return nil
+	}
+	m := &Manifest{Tools: make(map[string]string), digests: make(map[string]string)}
+	list := sha256.New()
+	ok := true
+	tools.ForEach(func(_, tool gjson.Result) bool {
+		name := tool.Get("function.name").String()
+		if name == "" {
+			name = tool.Get("name").String()
+		}
+		if _, duplicate := m.Tools[name]; name == "" || duplicate {
+			ok = false
+			return false
+		}
+		digest := sha256.Sum256([]byte(tool.Raw))
+		m.Names = append(m.Names, name)
+		m.Tools[name] = tool.Raw
+		m.digests[name] = hex.EncodeToString(digest[:])
+		list.Write([]byte(name))
+		list.Write([]byte{0})
+		list.Write(digest[:])
+		return true
+	})
+	if !ok || len(m.Names) == 0 {
+		return nil
+	}
+	m.Hash = hex.EncodeToString(list.Sum(nil))[:32]
+	return m
+}
+
+// toolsRaw returns the JSON array of the named tools, taking each from delta
+// when present and from base otherwise.
+func toolsRaw(names []string, delta, base map[string]string) (string, bool) {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		raw, ok := delta[name]
+		if !ok {
+			if raw, ok = base[name]; !ok {
+				return "", false
+			}
+		}
+		parts = append(parts, raw)
+	}
+	return "[" + strings.Join(parts, ",") + "]", true
+}
+
+type entry struct {
+	manifest *Manifest
+	seen     time.Time
+}
+
+// expiringMap is a small mutex-guarded map whose entries expire after ttl and
+// whose size is capped by evicting the least recently seen entry.
+type expiringMap struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	ttl     time.Duration
+	max     int
+}
+
+func (e *expiringMap) get(key string, now time.Time) *Manifest {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	current, ok := e.entries[key]
+	if !ok || now.Sub(current.seen) > e.ttl {
+		delete(e.entries, key)
+		return nil
+	}
+	current.seen = now
+	e.entries[key] = current
+	return current.manifest
+}
+
+func (e *expiringMap) put(key string, m *Manifest, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.entries == nil {
+		e.entries = make(map[string]entry)
+	}
+	if _, exists := e.entries[key]; !exists && len(e.entries) >= e.max {
+		oldest := ""
+		for k, v := range e.entries {
+			if now.Sub(v.seen) > e.ttl {
+				delete(e.entries, k)
+				continue
+			}
+			if oldest == "" || v.seen.Before(e.entries[oldest].seen) {
+				oldest = k
+			}
+		}
+		if len(e.entries) >= e.max && oldest != "" {
+			delete(e.entries, oldest)
+		}
+	}
+	e.entries[key] = entry{manifest: m, seen: now}
+}
+
+func (e *expiringMap) remove(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.entries, key)
+}
+
+// Sessions remembers the tool list last accepted by the upstream of each
+// conversation. It is the sending side.
+type Sessions struct {
+	m expiringMap
+}
+
+// NewSessions returns a session cache keeping up to max conversations for ttl.
+func NewSessions(max int, ttl time.Duration) *Sessions {
+	return &Sessions{m: expiringMap{ttl: ttl, max: max}}
+}
+
+// Request is a request body prepared for sending.
+type Request struct {
+	// Body is the body to send.
+	Body []byte
+	// Delta reports whether Body carries only the changed tools.
+	Delta bool
+
+	key      string
+	full     []byte
+	manifest *Manifest
+}
+
+// Prepare returns the body to send for the conversation. The full body is
+// marked with the manifest hash so the receiver keeps the list; once the
+// upstream has accepted a list, later bodies carry only the changed tools.
+func (s *Sessions) Prepare(key string, payload []byte) *Request {
+	m := FromPayload(payload)
+	if m == nil || key == "" {
+		return &Request{Body: payload}
+	}
+	full, err := sjson.SetBytes(payload, Field+".hash", m.Hash)
+	if err != nil {
+		return &Request{Body: payload}
+	}
+	req := &Request{Body: full, key: key, full: full, manifest: m}
+	prev := s.m.get(key, time.Now())
+	if prev == nil {
+		return req
+	}
+	changed := make(map[string]string)
+	for _, name := range m.Names {
+		if prev.digests[name] != m.digests[name] {
+			changed[name] = m.Tools[name]
+		}
+	}
+	changedNames := make([]string, 0, len(changed))
+	for _, name := range m.Names {
+		if _, ok := changed[name]; ok {
+			changedNames = append(changedNames, name)
+		}
+	}
+	tools, _ := toolsRaw(changedNames, changed, nil)
+	delta, err := sjson.SetRawBytes(payload, "tools", []byte(tools))
+	if err == nil {
+		delta, err = sjson.SetBytes(delta, Field, map[string]any{"hash": m.Hash, "base": prev.Hash, "names": m.Names})
+	}
+	if err != nil || len(delta) >= len(full) {
+		return req
+	}
+	req.Body, req.Delta = delta, true
+	return req
+}
+
+// Full switches a rejected delta request back to the full tool list and
+// forgets the conversation's list.
+func (s *Sessions) Full(req *Request) {
+	if req == nil || !req.Delta {
+		return
+	}
+	s.m.remove(req.key)
+	req.Body, req.Delta = req.full, false
+}
+
+// Accepted records that the upstream accepted the request's tool list.
+func (s *Sessions) Accepted(req *Request) {
+	if req == nil || req.manifest == nil {
+		return
+	}
+	s.m.put(req.key, req.manifest, time.Now())
+}
+
+// Store keeps recently received tool lists by hash. It is the receiving side.
+type Store struct {
+	m expiringMap
+}
+
+// NewStore returns a store keeping up to max tool lists for ttl.
+func NewStore(max int, ttl time.Duration) *Store {
+	return &Store{m: expiringMap{ttl: ttl, max: max}}
+}
+
+// Expand rebuilds the full tool list of a request carrying the manifest field
+// and removes the field. Requests without it are returned unchanged. It
+// returns ErrMismatch when the list cannot be rebuilt.
+func (s *Store) Expand(payload []byte) ([]byte, error) {
+	field := gjson.GetBytes(payload, Field)
+	if !field.Exists() {
+		return payload, nil
+	}
+	out, err := sjson.DeleteBytes(payload, Field)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	hash := field.Get("hash").String()
+	if base := field.Get("base").String(); base != "" {
+		prev := s.m.get(base, now)
+		if prev == nil {
+			return nil, ErrMismatch
+		}
+		delta := FromPayload(out)
+		var changed map[string]string
+		if delta != nil {
+			changed = delta.Tools
+		}
+		var names []string
+		field.Get("names").ForEach(func(_, name gjson.Result) bool {
+			names = append(names, name.String())
+			return true
+		})
+		tools, ok := toolsRaw(names, changed, prev.Tools)
+		if !ok {
+			return nil, ErrMismatch
+		}
+		if out, err = sjson.SetRawBytes(out, "tools", []byte(tools)); err != nil {
+			return nil, err
+		}
+	}
+	m := FromPayload(out)
+	if m == nil || m.Hash != hash {
+		return nil, ErrMismatch
+	}
+	s.m.put(m.Hash, m, now)
+	return out, nil
+}
+
+var (
+	defaultSessions = NewSessions(4096, time.Hour)
+	defaultStore    = NewStore(1024, time.Hour)
+)
+
+// DefaultSessions returns the process-wide sending side cache.
+func DefaultSessions() *Sessions { return defaultSessions }
+
+// DefaultStore returns the process-wide receiving side store.
+func DefaultStore() *Store { return defaultStore }
@@ -0,0 +1,79 @@
+package toolmanifest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func request(descriptions ...string) []byte {
+	tools := make([]string, 0, len(descriptions))
+	for i, description := range descriptions {
+		tools = append(tools, fmt.Sprintf(`{"type":"function","function":{"name":"tool_%d","description":%q,"parameters":{"type":"object"}}}`, i, description))
+	}
+	return []byte(`{"model":"m","messages":[{"role":"user","content":"hi"}],"tools":[` + strings.Join(tools, ",") + `]}`)
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	sessions := NewSessions(8, time.Hour)
+	store := NewStore(8, time.Hour)
+	send := func(payload []byte) (*Request, []byte, error) {
+		req := sessions.Prepare("conv", payload)
+		out, err := store.Expand(req.Body)
+		if err == nil {
+			sessions.Accepted(req)
+		}
+		return req, out, err
+	}
+
+	first := request(strings.Repeat("a", 200), strings.Repeat("b", 200), strings.Repeat("c", 200))
+	req, out, err := send(first)
+	if err != nil || req.Delta || gjson.GetBytes(out, "tools").Raw != gjson.GetBytes(first, "tools").Raw {
+		t.Fatalf("first turn: delta=%v err=%v", req.Delta, err)
+	}
+	if gjson.GetBytes(out, Field).Exists() {
+		t.Fatalf("manifest field forwarded: %s", out)
+	}
+
+	second := request(strings.Repeat("a", 200), "changed", strings.Repeat("c", 200))
+	req, out, err = send(second)
+	if err != nil || !req.Delta || gjson.GetBytes(req.Body, "tools.#").Int() != 1 {
+		t.Fatalf("second turn: delta=%v err=%v body=%s", req.Delta, err, req.Body)
+	}
+	if gjson.GetBytes(out, "tools").Raw != gjson.GetBytes(second, "tools").Raw {
+		t.Fatalf("rebuilt tools differ: %s", out)
+	}
+
+	// A receiver that lost the base list rejects the delta; the full list goes through.
+	req = sessions.Prepare("conv", request(strings.Repeat("a", 200), "changed again", strings.Repeat("c", 200)))
+	if _, err = NewStore(8, time.Hour).Expand(req.Body); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("err = %v, want ErrMismatch", err)
+	}
+	sessions.Full(req)
+	if req.Delta || gjson.GetBytes(req.Body, "tools.#").Int() != 3 {
+		t.Fatalf("full resend body = %s", req.Body)
+	}
+	if _, err = NewStore(8, time.Hour).Expand(req.Body); err != nil {
+		t.Fatalf("full resend rejected: %v", err)
+	}
+}
+
+func TestStoreEvictsOldestList(t *testing.T) {
+	store := NewStore(2, time.Hour)
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		payload := request(fmt.Sprintf("tool list %d", i))
+		m := FromPayload(payload)
+		if _, err := store.Expand([]byte(strings.Replace(string(payload), `"model":"m"`, `"model":"m","`+Field+`":{"hash":"`+m.Hash+`"}`, 1))); err != nil {
+			t.Fatalf("expand %d: %v", i, err)
+		}
+		hashes = append(hashes, m.Hash)
+	}
+	if store.m.get(hashes[0], time.Now()) != nil || store.m.get(hashes[2], time.Now()) == nil {
+		t.Fatalf("expected the oldest list to be evicted")
+	}
+}
@@ -0,0 +1,245 @@
+// Package toolschema validates tool call arguments produced by a model against
+// the JSON Schema the client declared for the tool. It implements the subset of
+// JSON Schema used by function-calling "strict" mode and repairs trivially
+// fixable mismatches instead of rejecting them outright.
+package toolschema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Result reports the outcome of validating one set of arguments.
+type Result struct {
+	// Arguments is the (possibly repaired) JSON object.
+	Arguments []byte
+	// Repairs lists the fixes applied, as "path: description".
+	Repairs []string
+	// Problems lists violations that could not be repaired.
+	Problems []string
+}
+
+// Valid reports whether the arguments satisfy the schema after repairs.
+func (r Result) Valid() bool { return len(r.Problems) == 0 }
+
+// Validate checks args against schema. Supported keywords are type, properties,
+// required, additionalProperties, items and enum. String values holding numbers
+// or booleans are coerced, scalars are coerced to strings, properties rejected
+// by additionalProperties:false are dropped, and missing properties whose type
+// allows null are filled with null.
+func Validate(schema string, args []byte) Result {
+	res := Result{Arguments: args}
+	if strings.TrimSpace(string(args)) == "" {
+		res.Arguments = []byte("{}")
+	}
+	if !gjson.ValidBytes(res.Arguments) {
+		res.Problems = append(res.Problems, "arguments are not valid JSON")
+		return res
+	}
+	v := &validator{out: string(res.Arguments)}
+	v.check("", gjson.Parse(schema), gjson.Parse(v.out))
+	res.Arguments = []byte(v.out)
+	res.Repairs = v.repairs
+	res.Problems = v.problems
+	return res
+}
+
+type validator struct {
+	out      string
+	repairs  []string
+	problems []string
+}
+
+func display(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return "$." + path
+}
+
+func join(path, key string) string {
+	key = strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`).Replace(key)
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (v *validator) set(path string, value any, note string) {
+	var err error
+	if path == "" {
+		return
+	}
+	if v.out, err = sjson.Set(v.out, path, value); err == nil {
+		v.repairs = append(v.repairs, display(path)+": "+note)
+	}
+}
+
+func (v *validator) check(path string, schema, value gjson.Result) {
+	if !schema.IsObject() {
+		return
+	}
+	types := schemaTypes(schema)
+	if len(types) > 0 && !matchesType(types, value) {
+		coerced, ok := coerce(types, value)
+		if !ok {
+			v.problems = append(v.problems, fmt.Sprintf("%s: expected %s, got %s", display(path), strings.Join(types, " or "), jsonType(value)))
+			return
+		}
+		v.set(path, coerced, "coerced "+jsonType(value)+" to "+strings.Join(types, " or "))
+		value = gjson.Get(v.out, path)
+	}
+	if enum := schema.Get("enum"); enum.IsArray() && value.Exists() {
+		found := false
+		enum.ForEach(func(_, item gjson.Result) bool {
+			found = item.Raw == value.Raw || (item.Type == value.Type && item.Value() == value.Value())
+			return !found
+		})
+		if !found {
+			v.problems = append(v.problems, fmt.Sprintf("%s: value %s is not one of %s", display(path), value.Raw, enum.Raw))
+		}
+	}
+	switch {
+	case value.IsObject():
+		v.checkObject(path, schema, value)
+	case value.IsArray():
+		if items := schema.Get("items"); items.IsObject() {
+			value.ForEach(func(i, item gjson.Result) bool {
+				v.check(join(path, i.String()), items, item)
+				return true
+			})
+		}
+	}
+}
+
+func (v *validator) checkObject(path string, schema, value gjson.Result) {
+	properties := schema.Get("properties")
+	if schema.Get("additionalProperties").Type == gjson.False {
+		value.ForEach(func(key, _ gjson.Result) bool {
+			if !properties.Get(gjson.Escape(key.String())).Exists() {
+				var err error
+				childPath := join(path, key.String())
+				if v.out, err = sjson.Delete(v.out, childPath); err == nil {
+					v.repairs = append(v.repairs, display(childPath)+": removed unknown property")
+				}
+			}
+			return true
+		})
+	}
+	schema.Get("required").ForEach(func(_, name gjson.Result) bool {
+		key := name.String()
+		if value.Get(gjson.Escape(key)).Exists() {
+			return true
+		}
+		childPath := join(path, key)
+		if containsType(schemaTypes(properties.Get(gjson.Escape(key))), "null") {
+			v.set(childPath, nil, "filled missing nullable property with null")
+			return true
+		}
+		v.problems = append(v.problems, display(childPath)+": missing required property")
+		return true
+	})
+	properties.ForEach(func(key, propSchema gjson.Result) bool {
+		childPath := join(path, key.String())
+		if child := gjson.Get(v.out, childPath); child.Exists() {
+			v.check(childPath, propSchema, child)
+		}
+		return true
+	})
+}
+
+func schemaTypes(schema gjson.Result) []string {
+	t := schema.Get("type")
+	if t.IsArray() {
+		var types []string
+		t.ForEach(func(_, item gjson.Result) bool {
+			types = append(types, item.String())
+			return true
+		})
+		return types
+	}
+	if t.Type == gjson.String {
+		return []string{t.String()}
+	}
+	return nil
+}
+
+func containsType(types []string, want string) bool {
+	for _, t := range types {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value gjson.Result) string {
+	switch {
+	case !value.Exists():
+		return "nothing"
+	case value.IsObject():
+		return "object"
+	case value.IsArray():
+		return "array"
+	}
+	switch value.Type {
+	case gjson.String:
+		return "string"
+	case gjson.Number:
+		return "number"
+	case gjson.True, gjson.False:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func matchesType(types []string, value gjson.Result) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		switch {
+		case t == actual:
+			return true
+		case t == "integer" && actual == "number" && value.Float() == float64(value.Int()):
+			return true
+		case t == "number" && actual == "number":
+			return true
+		}
+	}
+	return false
+}
+
+// coerce converts value to the first compatible schema type.
+func coerce(types []string, value gjson.Result) (any, bool) {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if value.Type == gjson.String {
+				if n, err := strconv.ParseInt(strings.TrimSpace(value.Str), 10, 64); err == nil {
+					return n, true
+				}
+			}
+		case "number":
+			if value.Type == gjson.String {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value.Str), 64); err == nil {
+					return f, true
+				}
+			}
+		case "boolean":
+			if value.Type == gjson.String {
+				if b, err := strconv.ParseBool(strings.TrimSpace(value.Str)); err == nil {
+					return b, true
+				}
+			}
+		case "string":
+			if value.Type == gjson.Number || value.Type == gjson.True || value.Type == gjson.False {
+				return value.Raw, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package toolschema
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const weatherSchema = `{"type":"object","additionalProperties":false,"required":["city","days","metric","note"],
+	"properties":{"city":{"type":"string"},"days":{"type":"integer"},"metric":{"type":"boolean"},
+	"note":{"type":["string","null"]},"tags":{"type":"array","items":{"type":"string","enum":["a","b"]}}}}`
+
+func TestValidate_RepairsTrivialIssues(t *testing.T) {
+	res := Validate(weatherSchema, []byte(`{"city":"Paris","days":"3","metric":"true","extra":1}`))
+	if !res.Valid() {
+		t.Fatalf("unexpected problems: %v", res.Problems)
+	}
+	out := gjson.ParseBytes(res.Arguments)
+	if out.Get("days").Type != gjson.Number || out.Get("days").Int() != 3 {
+		t.Fatalf("days not coerced: %s", res.Arguments)
+	}
+	if out.Get("metric").Type != gjson.True {
+		t.Fatalf("metric not coerced: %s", res.Arguments)
+	}
+	if out.Get("extra").Exists() {
+		t.Fatalf("unknown property kept: %s", res.Arguments)
+	}
+	if note := out.Get("note"); !note.Exists() || note.Type != gjson.Null {
+		t.Fatalf("nullable property not filled: %s", res.Arguments)
+	}
+	if len(res.Repairs) != 4 {
+		t.Fatalf("repairs = %v, want 4", res.Repairs)
+	}
+}
+
+func TestValidate_ReportsUnrepairableProblems(t *testing.T) {
+	res := Validate(weatherSchema, []byte(`{"days":"soon","metric":true,"note":null,"tags":["c"]}`))
+	if res.Valid() {
+		t.Fatalf("expected problems, got valid result %s", res.Arguments)
+	}
+	if len(res.Problems) != 3 {
+		t.Fatalf("problems = %v, want missing city, bad days and bad tag", res.Problems)
+	}
+	if Validate(weatherSchema, []byte(`{not json`)).Valid() {
+		t.Fatalf("invalid JSON must be reported")
+	}
+}
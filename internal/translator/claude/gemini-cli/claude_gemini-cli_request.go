@@ -7,6 +7,7 @@ package geminiCLI
 
 import (
 	"bytes"
+	"context"
 
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/claude/gemini"
 	"github.com/tidwall/gjson"
@@ -23,13 +24,14 @@ import (
 // 4. Delegates to the Gemini-to-Claude conversion function for further processing
 //
 // Parameters:
+//   - ctx: The context carrying any deterministic identifier source
 //   - modelName: The name of the model to use for the request
 //   - rawJSON: The raw JSON request data from the Gemini CLI API
 //   - stream: A boolean indicating if the request is for a streaming response
 //
 // Returns:
 //   - []byte: The transformed request data in Claude Code API format
-func ConvertGeminiCLIRequestToClaude(modelName string, inputRawJSON []byte, stream bool) []byte {
+func ConvertGeminiCLIRequestToClaude(ctx context.Context, modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := bytes.Clone(inputRawJSON)
 
 	modelResult := gjson.GetBytes(rawJSON, "model")
@@ -43,5 +45,5 @@ func ConvertGeminiCLIRequestToClaude(modelName string, inputRawJSON []byte, stre
 		rawJSON, _ = sjson.DeleteBytes(rawJSON, "systemInstruction")
 	}
 	// Delegate to the Gemini-to-Claude conversion function for further processing
-	return ConvertGeminiRequestToClaude(modelName, rawJSON, stream)
+	return ConvertGeminiRequestToClaude(ctx, modelName, rawJSON, stream)
 }
@@ -7,7 +7,7 @@ import (
 )
 
 func init() {
-	translator.Register(
+	translator.RegisterContext(
 		GeminiCLI,
 		Claude,
 		ConvertGeminiCLIRequestToClaude,
@@ -7,26 +7,18 @@ package gemini
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math/big"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	user    = ""
-	account = ""
-	session = ""
-)
-
 // ConvertGeminiRequestToClaude parses and transforms a Gemini API request into Claude Code API format.
 // It extracts the model name, system instruction, message contents, and tool declarations
 // from the raw JSON request and returns them in the format expected by the Claude Code API.
@@ -39,27 +31,20 @@ var (
 // 6. Tool declaration and tool choice configuration mapping
 //
 // Parameters:
+//   - ctx: The context carrying any deterministic identifier source
 //   - modelName: The name of the model to use for the request
 //   - rawJSON: The raw JSON request data from the Gemini API
 //   - stream: A boolean indicating if the request is for a streaming response
 //
 // Returns:
 //   - []byte: The transformed request data in Claude Code API format
-func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream bool) []byte {
+func ConvertGeminiRequestToClaude(ctx context.Context, modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := bytes.Clone(inputRawJSON)
 
-	if account == "" {
-		u, _ := uuid.NewRandom()
-		account = u.String()
-	}
-	if session == "" {
-		u, _ := uuid.NewRandom()
-		session = u.String()
-	}
-	if user == "" {
-		sum := sha256.Sum256([]byte(account + session))
-		user = hex.EncodeToString(sum[:])
-	}
+	// The synthetic Claude Code account and session stay stable per process.
+	account, session := util.ProcessUUID(ctx, "claude-account"), util.ProcessUUID(ctx, "claude-session")
+	userSum := sha256.Sum256([]byte(account + session))
+	user := hex.EncodeToString(userSum[:])
 	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
 
 	// Base Claude message payload
@@ -70,14 +55,7 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Helper for generating tool call IDs in the form: toolu_<alphanum>
 	// This ensures unique identifiers for tool calls in the Claude Code format
 	genToolCallID := func() string {
-		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-		var b strings.Builder
-		// 24 chars random suffix for uniqueness
-		for i := 0; i < 24; i++ {
-			n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
-			b.WriteByte(letters[n.Int64()])
-		}
-		return "toolu_" + b.String()
+		return "toolu_" + util.RandomAlphanumeric(ctx, 24)
 	}
 
 	// FIFO queue to store tool call IDs for matching with tool results
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -53,7 +54,7 @@ type ConvertAnthropicResponseToGeminiParams struct {
 //
 // Returns:
 //   - []string: A slice of strings, each containing a Gemini-compatible JSON response
-func ConvertClaudeResponseToGemini(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+func ConvertClaudeResponseToGemini(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &ConvertAnthropicResponseToGeminiParams{
 			Model:      modelName,
@@ -86,7 +87,7 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 
 	// Set creation time to current time if not provided
 	if (*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt == 0 {
-		(*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt = time.Now().Unix()
+		(*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt = util.Now(ctx).Unix()
 	}
 	template, _ = sjson.Set(template, "createTime", time.Unix((*param).(*ConvertAnthropicResponseToGeminiParams).CreatedAt, 0).Format(time.RFC3339Nano))
 
@@ -276,7 +277,7 @@ func ConvertClaudeResponseToGemini(_ context.Context, modelName string, original
 //
 // Returns:
 //   - string: A Gemini-compatible JSON response containing all message content and metadata
-func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertClaudeResponseToGeminiNonStream(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	// Base Gemini response template for non-streaming with default values
 	template := `{"candidates":[{"content":{"role":"model","parts":[]},"finishReason":"STOP"}],"usageMetadata":{"trafficType":"PROVISIONED_THROUGHPUT"},"modelVersion":"","createTime":"","responseId":""}`
 
@@ -333,7 +334,7 @@ func ConvertClaudeResponseToGeminiNonStream(_ context.Context, modelName string,
 				newParam.Model = message.Get("model").String()
 
 				// Set creation time to current time if not provided
-				createdAt = time.Now().Unix()
+				createdAt = util.Now(ctx).Unix()
 				newParam.CreatedAt = createdAt
 			}
 
@@ -7,25 +7,18 @@ package chat_completions
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math/big"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	user    = ""
-	account = ""
-	session = ""
-)
-
 // ConvertOpenAIRequestToClaude parses and transforms an OpenAI Chat Completions API request into Claude Code API format.
 // It extracts the model name, system instruction, message contents, and tool declarations
 // from the raw JSON request and returns them in the format expected by the Claude Code API.
@@ -37,28 +30,28 @@ var (
 // 5. Stop sequence and streaming configuration handling
 //
 // Parameters:
+//   - ctx: The context carrying any deterministic identifier source
 //   - modelName: The name of the model to use for the request
 //   - rawJSON: The raw JSON request data from the OpenAI API
 //   - stream: A boolean indicating if the request is for a streaming response
 //
 // Returns:
 //   - []byte: The transformed request data in Claude Code API format
-func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream bool) []byte {
+func ConvertOpenAIRequestToClaude(ctx context.Context, modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := bytes.Clone(inputRawJSON)
 
-	if account == "" {
-		u, _ := uuid.NewRandom()
-		account = u.String()
-	}
-	if session == "" {
-		u, _ := uuid.NewRandom()
-		session = u.String()
+	// The synthetic Claude Code account and session stay stable per process.
+	account, session := util.ProcessUUID(ctx, "claude-account"), util.ProcessUUID(ctx, "claude-session")
+	userSum := sha256.Sum256([]byte(account + session))
+	user := hex.EncodeToString(userSum[:])
+	// Derive the user segment from the client-supplied OpenAI "user" so upstream abuse
+	// attribution stays per end user while keeping the Claude Code user_id format.
+	userHash := user
+	if clientUser := strings.TrimSpace(gjson.GetBytes(rawJSON, "user").String()); clientUser != "" {
+		sum := sha256.Sum256([]byte(clientUser))
+		userHash = hex.EncodeToString(sum[:])
 	}
-	if user == "" {
-		sum := sha256.Sum256([]byte(account + session))
-		user = hex.EncodeToString(sum[:])
-	}
-	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
+	userID := fmt.Sprintf("user_%s_account_%s_session_%s", userHash, account, session)
 
 	// Base Claude Code API template with default max_tokens value
 	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
@@ -89,14 +82,7 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	// Helper for generating tool call IDs in the form: toolu_<alphanum>
 	// This ensures unique identifiers for tool calls in the Claude Code format
 	genToolCallID := func() string {
-		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-		var b strings.Builder
-		// 24 chars random suffix for uniqueness
-		for i := 0; i < 24; i++ {
-			n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
-			b.WriteByte(letters[n.Int64()])
-		}
-		return "toolu_" + b.String()
+		return "toolu_" + util.RandomAlphanumeric(ctx, 24)
 	}
 
 	// Model mapping to specify which Claude Code model to use
@@ -117,6 +103,11 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 		out, _ = sjson.Set(out, "top_p", topP.Float())
 	}
 
+	// Top K setting, an extension some OpenAI-compatible clients send
+	if topK := root.Get("top_k"); topK.Exists() && topK.Type == gjson.Number {
+		out, _ = sjson.Set(out, "top_k", topK.Int())
+	}
+
 	// Stop sequences configuration for custom termination conditions
 	if stop := root.Get("stop"); stop.Exists() {
 		if stop.IsArray() {
@@ -145,7 +136,9 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 			contentResult := message.Get("content")
 
 			switch role {
-			case "system":
+			case "system", "developer":
+				// The OpenAI "developer" role carries system-level guidance and is merged
+				// into the system prompt block.
 				if systemMessageIndex == -1 {
 					systemMsg := `{"role":"user","content":[]}`
 					out, _ = sjson.SetRaw(out, "messages.-1", systemMsg)
@@ -315,5 +308,13 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
+	// Claude expresses parallel_tool_calls=false as disable_parallel_tool_use on tool_choice.
+	if parallel := root.Get("parallel_tool_calls"); parallel.Exists() && !parallel.Bool() && gjson.Get(out, "tools").Exists() && root.Get("tool_choice").String() != "none" {
+		if !gjson.Get(out, "tool_choice").Exists() {
+			out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
+		}
+		out, _ = sjson.Set(out, "tool_choice.disable_parallel_tool_use", true)
+	}
+
 	return []byte(out)
 }
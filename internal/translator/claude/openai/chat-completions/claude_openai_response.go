@@ -10,8 +10,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -27,6 +27,8 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	FinishReason string
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// Forward tool arguments as they arrive instead of at content_block_stop
+	IncrementalToolArguments bool
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -49,12 +51,13 @@ type ToolCallAccumulator struct {
 //
 // Returns:
 //   - []string: A slice of strings, each containing an OpenAI-compatible JSON response
-func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+func ConvertClaudeResponseToOpenAI(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &ConvertAnthropicResponseToOpenAIParams{
-			CreatedAt:    0,
-			ResponseID:   "",
-			FinishReason: "",
+			CreatedAt:                0,
+			ResponseID:               "",
+			FinishReason:             "",
+			IncrementalToolArguments: util.IncrementalToolArguments(ctx),
 		}
 	}
 
@@ -87,7 +90,7 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		// Initialize response with message metadata when a new message begins
 		if message := root.Get("message"); message.Exists() {
 			(*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID = message.Get("id").String()
-			(*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt = time.Now().Unix()
+			(*param).(*ConvertAnthropicResponseToOpenAIParams).CreatedAt = util.Now(ctx).Unix()
 
 			template, _ = sjson.Set(template, "id", (*param).(*ConvertAnthropicResponseToOpenAIParams).ResponseID)
 			template, _ = sjson.Set(template, "model", modelName)
@@ -123,6 +126,16 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					Name: toolName,
 				}
 
+				if (*param).(*ConvertAnthropicResponseToOpenAIParams).IncrementalToolArguments {
+					// Announce the tool call now; arguments follow as deltas
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.id", toolCallID)
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.type", "function")
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.name", toolName)
+					template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", "")
+					return []string{template}
+				}
+
 				// Don't output anything yet - wait for complete tool call
 				return []string{}
 			}
@@ -155,6 +168,11 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 					if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
 						if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
 							accumulator.Arguments.WriteString(partialJSON.String())
+							if (*param).(*ConvertAnthropicResponseToOpenAIParams).IncrementalToolArguments && partialJSON.String() != "" {
+								template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
+								template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", partialJSON.String())
+								return []string{template}
+							}
 						}
 					}
 				}
@@ -172,7 +190,17 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 		// End of content block - output complete tool call if it's a tool_use block
 		index := int(root.Get("index").Int())
 		if (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator != nil {
-			if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists {
+			if accumulator, exists := (*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator[index]; exists && (*param).(*ConvertAnthropicResponseToOpenAIParams).IncrementalToolArguments {
+				// Arguments were already streamed; only close the JSON if it was cut short
+				delete((*param).(*ConvertAnthropicResponseToOpenAIParams).ToolCallsAccumulator, index)
+				suffix, _ := util.CloseJSONSuffix(accumulator.Arguments.String())
+				if suffix == "" {
+					return []string{}
+				}
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.index", index)
+				template, _ = sjson.Set(template, "choices.0.delta.tool_calls.0.function.arguments", suffix)
+				return []string{template}
+			} else if exists {
 				// Build complete tool call with accumulated arguments
 				arguments := accumulator.Arguments.String()
 				if arguments == "" {
@@ -267,7 +295,7 @@ func mapAnthropicStopReasonToOpenAI(anthropicReason string) string {
 //
 // Returns:
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
-func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertClaudeResponseToOpenAINonStream(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	chunks := make([][]byte, 0)
 
 	lines := bytes.Split(rawJSON, []byte("\n"))
@@ -299,7 +327,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 			if message := root.Get("message"); message.Exists() {
 				messageID = message.Get("id").String()
 				model = message.Get("model").String()
-				createdAt = time.Now().Unix()
+				createdAt = util.Now(ctx).Unix()
 			}
 
 		case "content_block_start":
@@ -2,25 +2,18 @@ package responses
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"math/big"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-var (
-	user    = ""
-	account = ""
-	session = ""
-)
-
 // ConvertOpenAIResponsesRequestToClaude transforms an OpenAI Responses API request
 // into a Claude Messages API request using only gjson/sjson for JSON handling.
 // It supports:
@@ -31,22 +24,21 @@ var (
 // - tools[].parameters -> tools[].input_schema
 // - max_output_tokens -> max_tokens
 // - stream passthrough via parameter
-func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte, stream bool) []byte {
+func ConvertOpenAIResponsesRequestToClaude(ctx context.Context, modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := bytes.Clone(inputRawJSON)
 
-	if account == "" {
-		u, _ := uuid.NewRandom()
-		account = u.String()
-	}
-	if session == "" {
-		u, _ := uuid.NewRandom()
-		session = u.String()
+	// The synthetic Claude Code account and session stay stable per process.
+	account, session := util.ProcessUUID(ctx, "claude-account"), util.ProcessUUID(ctx, "claude-session")
+	userSum := sha256.Sum256([]byte(account + session))
+	user := hex.EncodeToString(userSum[:])
+	// Derive the user segment from the client-supplied OpenAI "user" so upstream abuse
+	// attribution stays per end user while keeping the Claude Code user_id format.
+	userHash := user
+	if clientUser := strings.TrimSpace(gjson.GetBytes(rawJSON, "user").String()); clientUser != "" {
+		sum := sha256.Sum256([]byte(clientUser))
+		userHash = hex.EncodeToString(sum[:])
 	}
-	if user == "" {
-		sum := sha256.Sum256([]byte(account + session))
-		user = hex.EncodeToString(sum[:])
-	}
-	userID := fmt.Sprintf("user_%s_account_%s_session_%s", user, account, session)
+	userID := fmt.Sprintf("user_%s_account_%s_session_%s", userHash, account, session)
 
 	// Base Claude message payload
 	out := fmt.Sprintf(`{"model":"","max_tokens":32000,"messages":[],"metadata":{"user_id":"%s"}}`, userID)
@@ -76,13 +68,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 
 	// Helper for generating tool call IDs when missing
 	genToolCallID := func() string {
-		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-		var b strings.Builder
-		for i := 0; i < 24; i++ {
-			n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
-			b.WriteByte(letters[n.Int64()])
-		}
-		return "toolu_" + b.String()
+		return "toolu_" + util.RandomAlphanumeric(ctx, 24)
 	}
 
 	// Model
@@ -111,7 +97,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 	if instructionsText == "" {
 		if input := root.Get("input"); input.Exists() && input.IsArray() {
 			input.ForEach(func(_, item gjson.Result) bool {
-				if strings.EqualFold(item.Get("role").String(), "system") {
+				if isSystemRole(item.Get("role").String()) {
 					var builder strings.Builder
 					if parts := item.Get("content"); parts.Exists() && parts.IsArray() {
 						parts.ForEach(func(_, part gjson.Result) bool {
@@ -142,7 +128,7 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 	// input array processing
 	if input := root.Get("input"); input.Exists() && input.IsArray() {
 		input.ForEach(func(_, item gjson.Result) bool {
-			if extractedFromSystem && strings.EqualFold(item.Get("role").String(), "system") {
+			if extractedFromSystem && isSystemRole(item.Get("role").String()) {
 				return true
 			}
 			typ := item.Get("type").String()
@@ -336,5 +322,19 @@ func ConvertOpenAIResponsesRequestToClaude(modelName string, inputRawJSON []byte
 		}
 	}
 
+	// Claude expresses parallel_tool_calls=false as disable_parallel_tool_use on tool_choice.
+	if parallel := root.Get("parallel_tool_calls"); parallel.Exists() && !parallel.Bool() && gjson.Get(out, "tools").Exists() && root.Get("tool_choice").String() != "none" {
+		if !gjson.Get(out, "tool_choice").Exists() {
+			out, _ = sjson.SetRaw(out, "tool_choice", `{"type":"auto"}`)
+		}
+		out, _ = sjson.Set(out, "tool_choice.disable_parallel_tool_use", true)
+	}
+
 	return []byte(out)
 }
+
+// isSystemRole reports whether an input role carries system-level guidance.
+// The OpenAI "developer" role is treated the same as "system".
+func isSystemRole(role string) bool {
+	return strings.EqualFold(role, "system") || strings.EqualFold(role, "developer")
+}
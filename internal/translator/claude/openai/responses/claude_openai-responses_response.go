@@ -6,8 +6,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -76,7 +76,7 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 	case "message_start":
 		if msg := root.Get("message"); msg.Exists() {
 			st.ResponseID = msg.Get("id").String()
-			st.CreatedAt = time.Now().Unix()
+			st.CreatedAt = util.Now(ctx).Unix()
 			// Reset per-message aggregation state
 			st.TextBuf.Reset()
 			st.ReasoningBuf.Reset()
@@ -432,7 +432,7 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 }
 
 // ConvertClaudeResponseToOpenAIResponsesNonStream aggregates Claude SSE into a single OpenAI Responses JSON.
-func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertClaudeResponseToOpenAIResponsesNonStream(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	// Aggregate Claude SSE lines into a single OpenAI Responses JSON (non-stream)
 	// We follow the same aggregation logic as the streaming variant but produce
 	// one final object matching docs/out.json structure.
@@ -488,7 +488,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		case "message_start":
 			if msg := root.Get("message"); msg.Exists() {
 				responseID = msg.Get("id").String()
-				createdAt = time.Now().Unix()
+				createdAt = util.Now(ctx).Unix()
 				if usage := msg.Get("usage"); usage.Exists() {
 					inputTokens = usage.Get("input_tokens").Int()
 				}
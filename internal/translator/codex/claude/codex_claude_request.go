@@ -8,11 +8,11 @@ package claude
 import (
 	"bytes"
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -148,7 +148,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 							if short, ok := toolMap[name]; ok {
 								name = short
 							} else {
-								name = shortenNameIfNeeded(name)
+								name = common.ShortenToolName(name)
 							}
 							functionCallMessage, _ = sjson.Set(functionCallMessage, "name", name)
 						}
@@ -175,7 +175,11 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	toolsResult := rootResult.Get("tools")
 	if toolsResult.IsArray() {
 		template, _ = sjson.SetRaw(template, "tools", `[]`)
-		template, _ = sjson.Set(template, "tool_choice", `auto`)
+		if rootResult.Get("tool_choice.type").String() == "none" {
+			template, _ = sjson.Set(template, "tool_choice", `none`)
+		} else {
+			template, _ = sjson.Set(template, "tool_choice", `auto`)
+		}
 		toolResults := toolsResult.Array()
 		// Build short name map from declared tools
 		var names []string
@@ -185,7 +189,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				names = append(names, n)
 			}
 		}
-		shortMap := buildShortNameMap(names)
+		shortMap := common.BuildShortToolNameMap(names)
 		for i := 0; i < len(toolResults); i++ {
 			toolResult := toolResults[i]
 			// Special handling: map Claude web search tool to Codex web_search
@@ -202,7 +206,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 				if short, ok := shortMap[name]; ok {
 					name = short
 				} else {
-					name = shortenNameIfNeeded(name)
+					name = common.ShortenToolName(name)
 				}
 				tool, _ = sjson.Set(tool, "name", name)
 			}
@@ -215,7 +219,7 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	}
 
 	// Add additional configuration parameters for the Codex API.
-	template, _ = sjson.Set(template, "parallel_tool_calls", true)
+	template, _ = sjson.Set(template, "parallel_tool_calls", !rootResult.Get("tool_choice.disable_parallel_tool_use").Bool())
 
 	// Convert thinking.budget_tokens to reasoning.effort.
 	reasoningEffort := "medium"
@@ -263,79 +267,6 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 	return []byte(template)
 }
 
-// shortenNameIfNeeded applies a simple shortening rule for a single name.
-func shortenNameIfNeeded(name string) string {
-	const limit = 64
-	if len(name) <= limit {
-		return name
-	}
-	if strings.HasPrefix(name, "mcp__") {
-		idx := strings.LastIndex(name, "__")
-		if idx > 0 {
-			cand := "mcp__" + name[idx+2:]
-			if len(cand) > limit {
-				return cand[:limit]
-			}
-			return cand
-		}
-	}
-	return name[:limit]
-}
-
-// buildShortNameMap ensures uniqueness of shortened names within a request.
-func buildShortNameMap(names []string) map[string]string {
-	const limit = 64
-	used := map[string]struct{}{}
-	m := map[string]string{}
-
-	baseCandidate := func(n string) string {
-		if len(n) <= limit {
-			return n
-		}
-		if strings.HasPrefix(n, "mcp__") {
-			idx := strings.LastIndex(n, "__")
-			if idx > 0 {
-				cand := "mcp__" + n[idx+2:]
-				if len(cand) > limit {
-					cand = cand[:limit]
-				}
-				return cand
-			}
-		}
-		return n[:limit]
-	}
-
-	makeUnique := func(cand string) string {
-		if _, ok := used[cand]; !ok {
-			return cand
-		}
-		base := cand
-		for i := 1; ; i++ {
-			suffix := "_" + strconv.Itoa(i)
-			allowed := limit - len(suffix)
-			if allowed < 0 {
-				allowed = 0
-			}
-			tmp := base
-			if len(tmp) > allowed {
-				tmp = tmp[:allowed]
-			}
-			tmp = tmp + suffix
-			if _, ok := used[tmp]; !ok {
-				return tmp
-			}
-		}
-	}
-
-	for _, n := range names {
-		cand := baseCandidate(n)
-		uniq := makeUnique(cand)
-		used[uniq] = struct{}{}
-		m[n] = uniq
-	}
-	return m
-}
-
 // buildReverseMapFromClaudeOriginalToShort builds original->short map, used to map tool_use names to short.
 func buildReverseMapFromClaudeOriginalToShort(original []byte) map[string]string {
 	tools := gjson.GetBytes(original, "tools")
@@ -352,7 +283,7 @@ func buildReverseMapFromClaudeOriginalToShort(original []byte) map[string]string
 		}
 	}
 	if len(names) > 0 {
-		m = buildShortNameMap(names)
+		m = common.BuildShortToolNameMap(names)
 	}
 	return m
 }
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -123,6 +124,9 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 		if cachedTokens > 0 {
 			template, _ = sjson.Set(template, "usage.cache_read_input_tokens", cachedTokens)
 		}
+		if reasoningTokens := rootResult.Get("response.usage.output_tokens_details.reasoning_tokens").Int(); reasoningTokens > 0 {
+			template, _ = sjson.Set(template, "usage.thinking_tokens", reasoningTokens)
+		}
 
 		output = "event: message_delta\n"
 		output += fmt.Sprintf("data: %s\n\n", template)
@@ -214,6 +218,9 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 	if cachedTokens > 0 {
 		out, _ = sjson.Set(out, "usage.cache_read_input_tokens", cachedTokens)
 	}
+	if reasoningTokens := responseData.Get("usage.output_tokens_details.reasoning_tokens").Int(); reasoningTokens > 0 {
+		out, _ = sjson.Set(out, "usage.thinking_tokens", reasoningTokens)
+	}
 
 	hasToolCall := false
 
@@ -355,7 +362,7 @@ func buildReverseMapFromClaudeOriginalShortToOriginal(original []byte) map[strin
 		}
 	}
 	if len(names) > 0 {
-		m := buildShortNameMap(names)
+		m := common.BuildShortToolNameMap(names)
 		for orig, short := range m {
 			rev[short] = orig
 		}
@@ -0,0 +1,58 @@
+// Package common holds helpers shared by the Codex request and response
+// translators.
+package common
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ToolNameLimit is the longest function name the Codex API accepts.
+const ToolNameLimit = 64
+
+// ShortenToolName applies the shortening rule for a single tool name. Names
+// within ToolNameLimit are returned unchanged; longer "mcp__" names keep the
+// prefix and their last "__" segment, and anything else is truncated.
+func ShortenToolName(name string) string {
+	if len(name) <= ToolNameLimit {
+		return name
+	}
+	if strings.HasPrefix(name, "mcp__") {
+		if idx := strings.LastIndex(name, "__"); idx > 0 {
+			name = "mcp__" + name[idx+2:]
+			if len(name) <= ToolNameLimit {
+				return name
+			}
+		}
+	}
+	return name[:ToolNameLimit]
+}
+
+// BuildShortToolNameMap maps each name to a short name that is unique within
+// the request. Collisions after shortening get "_1", "_2", ... suffixes.
+func BuildShortToolNameMap(names []string) map[string]string {
+	used := make(map[string]struct{}, len(names))
+	m := make(map[string]string, len(names))
+	for _, name := range names {
+		short := uniqueToolName(ShortenToolName(name), used)
+		used[short] = struct{}{}
+		m[name] = short
+	}
+	return m
+}
+
+func uniqueToolName(candidate string, used map[string]struct{}) string {
+	if _, ok := used[candidate]; !ok {
+		return candidate
+	}
+	for i := 1; ; i++ {
+		suffix := "_" + strconv.Itoa(i)
+		base := candidate
+		if allowed := ToolNameLimit - len(suffix); len(base) > allowed {
+			base = base[:max(allowed, 0)]
+		}
+		if _, ok := used[base+suffix]; !ok {
+			return base + suffix
+		}
+	}
+}
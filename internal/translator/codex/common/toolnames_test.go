@@ -0,0 +1,33 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShortenToolName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	cases := map[string]string{
+		"read_file": "read_file",
+		long:        long[:ToolNameLimit],
+		"mcp__" + strings.Repeat("s", 60) + "__run": "mcp__run",
+		"mcp__server__" + long:                      ("mcp__" + long)[:ToolNameLimit],
+	}
+	for in, want := range cases {
+		if got := ShortenToolName(in); got != want {
+			t.Errorf("ShortenToolName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBuildShortToolNameMapKeepsNamesUnique(t *testing.T) {
+	a := strings.Repeat("x", 64) + "_a"
+	b := strings.Repeat("x", 64) + "_b"
+	m := BuildShortToolNameMap([]string{"short", a, b})
+	if m["short"] != "short" {
+		t.Fatalf("short name changed: %q", m["short"])
+	}
+	if m[a] != strings.Repeat("x", 64) || m[b] != strings.Repeat("x", 62)+"_1" {
+		t.Fatalf("collisions not resolved: %q, %q", m[a], m[b])
+	}
+}
@@ -7,6 +7,7 @@ package geminiCLI
 
 import (
 	"bytes"
+	"context"
 
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/gemini"
 	"github.com/tidwall/gjson"
@@ -23,13 +24,14 @@ import (
 // 4. Delegates to the Gemini-to-Codex conversion function for further processing
 //
 // Parameters:
+//   - ctx: The context carrying any deterministic identifier source
 //   - modelName: The name of the model to use for the request
 //   - rawJSON: The raw JSON request data from the Gemini CLI API
 //   - stream: A boolean indicating if the request is for a streaming response
 //
 // Returns:
 //   - []byte: The transformed request data in Codex API format
-func ConvertGeminiCLIRequestToCodex(modelName string, inputRawJSON []byte, stream bool) []byte {
+func ConvertGeminiCLIRequestToCodex(ctx context.Context, modelName string, inputRawJSON []byte, stream bool) []byte {
 	rawJSON := bytes.Clone(inputRawJSON)
 
 	rawJSON = []byte(gjson.GetBytes(rawJSON, "request").Raw)
@@ -39,5 +41,5 @@ func ConvertGeminiCLIRequestToCodex(modelName string, inputRawJSON []byte, strea
 		rawJSON, _ = sjson.DeleteBytes(rawJSON, "systemInstruction")
 	}
 
-	return ConvertGeminiRequestToCodex(modelName, rawJSON, stream)
+	return ConvertGeminiRequestToCodex(ctx, modelName, rawJSON, stream)
 }
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -299,7 +300,7 @@ func buildReverseMapFromGeminiOriginal(original []byte) map[string]string {
 		}
 	}
 	if len(names) > 0 {
-		m := buildShortNameMap(names)
+		m := common.BuildShortToolNameMap(names)
 		for orig, short := range m {
 			rev[short] = orig
 		}
@@ -9,10 +9,8 @@ package chat_completions
 import (
 	"bytes"
 
-	"strconv"
-	"strings"
-
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -63,7 +61,9 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	} else {
 		out, _ = sjson.Set(out, "reasoning.effort", "medium")
 	}
-	out, _ = sjson.Set(out, "parallel_tool_calls", true)
+	// Parallel tool calls stay on unless the client turned them off.
+	parallelToolCalls := gjson.GetBytes(rawJSON, "parallel_tool_calls")
+	out, _ = sjson.Set(out, "parallel_tool_calls", !parallelToolCalls.Exists() || parallelToolCalls.Bool())
 	out, _ = sjson.Set(out, "reasoning.summary", "auto")
 	out, _ = sjson.Set(out, "include", []string{"reasoning.encrypted_content"})
 
@@ -90,7 +90,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 				}
 			}
 			if len(names) > 0 {
-				originalToolNameMap = buildShortNameMap(names)
+				originalToolNameMap = common.BuildShortToolNameMap(names)
 			}
 		}
 	}
@@ -212,7 +212,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 									if short, ok := originalToolNameMap[name]; ok {
 										name = short
 									} else {
-										name = shortenNameIfNeeded(name)
+										name = common.ShortenToolName(name)
 									}
 									funcCall, _ = sjson.Set(funcCall, "name", name)
 								}
@@ -296,7 +296,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 						if short, ok := originalToolNameMap[name]; ok {
 							name = short
 						} else {
-							name = shortenNameIfNeeded(name)
+							name = common.ShortenToolName(name)
 						}
 						item, _ = sjson.Set(item, "name", name)
 					}
@@ -330,7 +330,7 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 					if short, ok := originalToolNameMap[name]; ok {
 						name = short
 					} else {
-						name = shortenNameIfNeeded(name)
+						name = common.ShortenToolName(name)
 					}
 				}
 				choice := `{}`
@@ -349,81 +349,3 @@ func ConvertOpenAIRequestToCodex(modelName string, inputRawJSON []byte, stream b
 	out, _ = sjson.Set(out, "store", false)
 	return []byte(out)
 }
-
-// shortenNameIfNeeded applies the simple shortening rule for a single name.
-// If the name length exceeds 64, it will try to preserve the "mcp__" prefix and last segment.
-// Otherwise it truncates to 64 characters.
-func shortenNameIfNeeded(name string) string {
-	const limit = 64
-	if len(name) <= limit {
-		return name
-	}
-	if strings.HasPrefix(name, "mcp__") {
-		// Keep prefix and last segment after '__'
-		idx := strings.LastIndex(name, "__")
-		if idx > 0 {
-			candidate := "mcp__" + name[idx+2:]
-			if len(candidate) > limit {
-				return candidate[:limit]
-			}
-			return candidate
-		}
-	}
-	return name[:limit]
-}
-
-// buildShortNameMap generates unique short names (<=64) for the given list of names.
-// It preserves the "mcp__" prefix with the last segment when possible and ensures uniqueness
-// by appending suffixes like "~1", "~2" if needed.
-func buildShortNameMap(names []string) map[string]string {
-	const limit = 64
-	used := map[string]struct{}{}
-	m := map[string]string{}
-
-	baseCandidate := func(n string) string {
-		if len(n) <= limit {
-			return n
-		}
-		if strings.HasPrefix(n, "mcp__") {
-			idx := strings.LastIndex(n, "__")
-			if idx > 0 {
-				cand := "mcp__" + n[idx+2:]
-				if len(cand) > limit {
-					cand = cand[:limit]
-				}
-				return cand
-			}
-		}
-		return n[:limit]
-	}
-
-	makeUnique := func(cand string) string {
-		if _, ok := used[cand]; !ok {
-			return cand
-		}
-		base := cand
-		for i := 1; ; i++ {
-			suffix := "_" + strconv.Itoa(i)
-			allowed := limit - len(suffix)
-			if allowed < 0 {
-				allowed = 0
-			}
-			tmp := base
-			if len(tmp) > allowed {
-				tmp = tmp[:allowed]
-			}
-			tmp = tmp + suffix
-			if _, ok := used[tmp]; !ok {
-				return tmp
-			}
-		}
-	}
-
-	for _, n := range names {
-		cand := baseCandidate(n)
-		uniq := makeUnique(cand)
-		used[uniq] = struct{}{}
-		m[n] = uniq
-	}
-	return m
-}
@@ -8,8 +8,9 @@ package chat_completions
 import (
 	"bytes"
 	"context"
-	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/codex/common"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -164,14 +165,14 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 //
 // Returns:
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
-func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+func ConvertCodexResponseToOpenAINonStream(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	rootResult := gjson.ParseBytes(rawJSON)
 	// Verify this is a response.completed event
 	if rootResult.Get("type").String() != "response.completed" {
 		return ""
 	}
 
-	unixTimestamp := time.Now().Unix()
+	unixTimestamp := util.Now(ctx).Unix()
 
 	responseResult := rootResult.Get("response")
 
@@ -324,7 +325,7 @@ func buildReverseMapFromOriginalOpenAI(original []byte) map[string]string {
 			}
 		}
 		if len(names) > 0 {
-			m := buildShortNameMap(names)
+			m := common.BuildShortToolNameMap(names)
 			for orig, short := range m {
 				rev[short] = orig
 			}
@@ -17,7 +17,9 @@ func ConvertOpenAIResponsesRequestToCodex(modelName string, inputRawJSON []byte,
 
 	rawJSON, _ = sjson.SetBytes(rawJSON, "stream", true)
 	rawJSON, _ = sjson.SetBytes(rawJSON, "store", false)
-	rawJSON, _ = sjson.SetBytes(rawJSON, "parallel_tool_calls", true)
+	if parallelToolCalls := gjson.GetBytes(rawJSON, "parallel_tool_calls"); !parallelToolCalls.Exists() || parallelToolCalls.Bool() {
+		rawJSON, _ = sjson.SetBytes(rawJSON, "parallel_tool_calls", true)
+	}
 	rawJSON, _ = sjson.SetBytes(rawJSON, "include", []string{"reasoning.encrypted_content"})
 	// Codex Responses rejects token limit fields, so strip them out before forwarding.
 	rawJSON, _ = sjson.DeleteBytes(rawJSON, "max_output_tokens")
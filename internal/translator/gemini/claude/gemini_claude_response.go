@@ -86,6 +86,11 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 		if responseIDResult := gjson.GetBytes(rawJSON, "responseId"); responseIDResult.Exists() {
 			messageStartTemplate, _ = sjson.Set(messageStartTemplate, "message.id", responseIDResult.String())
 		}
+		// Gemini reports prompt usage from the first chunk on, so input tokens
+		// are known before any output is generated.
+		if promptTokens := gjson.GetBytes(rawJSON, "usageMetadata.promptTokenCount"); promptTokens.Exists() {
+			messageStartTemplate, _ = sjson.Set(messageStartTemplate, "message.usage.input_tokens", promptTokens.Int())
+		}
 		output = output + fmt.Sprintf("data: %s\n\n\n", messageStartTemplate)
 
 		(*param).(*Params).HasFirstResponse = true
@@ -256,6 +261,10 @@ func ConvertGeminiResponseToClaude(_ context.Context, _ string, originalRequestR
 				thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
 				template, _ = sjson.Set(template, "usage.output_tokens", candidatesTokenCountResult.Int()+thoughtsTokenCount)
 				template, _ = sjson.Set(template, "usage.input_tokens", usageResult.Get("promptTokenCount").Int())
+				// Thinking is billed as output; thinking_tokens reports its share.
+				if thoughtsTokenCount > 0 {
+					template, _ = sjson.Set(template, "usage.thinking_tokens", thoughtsTokenCount)
+				}
 
 				output = output + template + "\n\n\n"
 			}
@@ -289,6 +298,9 @@ func ConvertGeminiResponseToClaudeNonStream(_ context.Context, _ string, origina
 	outputTokens := root.Get("usageMetadata.candidatesTokenCount").Int() + root.Get("usageMetadata.thoughtsTokenCount").Int()
 	out, _ = sjson.Set(out, "usage.input_tokens", inputTokens)
 	out, _ = sjson.Set(out, "usage.output_tokens", outputTokens)
+	if thoughtsTokenCount := root.Get("usageMetadata.thoughtsTokenCount").Int(); thoughtsTokenCount > 0 {
+		out, _ = sjson.Set(out, "usage.thinking_tokens", thoughtsTokenCount)
+	}
 
 	parts := root.Get("candidates.0.content.parts")
 	textBuilder := strings.Builder{}
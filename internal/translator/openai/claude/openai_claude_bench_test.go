@@ -0,0 +1,103 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeClaudeTranscript builds a Claude Code style request with the given
+// number of tool round trips and tool declarations.
+func largeClaudeTranscript(turns, tools int) []byte {
+	var b strings.Builder
+	b.WriteString(`{"model":"claude-sonnet-4","max_tokens":8192,"stream":true,"system":[{"type":"text","text":"You are a coding agent."}],"messages":[`)
+	output := strings.Repeat("src/pkg/file.go:42: matched line of grep output\n", 40)
+	for i := 0; i < turns; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"role":"user","content":[{"type":"text","text":"step %d: keep going"}]},`, i)
+		fmt.Fprintf(&b, `{"role":"assistant","content":[{"type":"thinking","thinking":"considering step %d"},{"type":"text","text":"Running a search."},{"type":"tool_use","id":"toolu_%d","name":"Grep","input":{"pattern":"func main","path":"src"}}]},`, i, i)
+		fmt.Fprintf(&b, `{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_%d","content":%q}]}`, i, output)
+	}
+	b.WriteString(`],"tools":[`)
+	for i := 0; i < tools; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"name":"tool_%d","description":"Tool number %d","input_schema":{"type":"object","properties":{"path":{"type":"string","description":"File path"},"limit":{"type":"integer"}},"required":["path"]}}`, i, i)
+	}
+	b.WriteString(`],"metadata":{"user_id":"user_bench"}}`)
+	return []byte(b.String())
+}
+
+func BenchmarkConvertClaudeRequestToOpenAI_LargeTranscript(b *testing.B) {
+	payload := largeClaudeTranscript(200, 40)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ConvertClaudeRequestToOpenAI("gpt-5", payload, true)
+	}
+}
+
+// openAIStreamChunks returns a text stream of n content deltas followed by a
+// tool call and the finish and usage chunks.
+func openAIStreamChunks(n int) [][]byte {
+	chunks := make([][]byte, 0, n+4)
+	for i := 0; i < n; i++ {
+		chunks = append(chunks, []byte(fmt.Sprintf(`data: {"id":"chatcmpl-1","model":"gpt-5","created":1,"choices":[{"index":0,"delta":{"content":"token %d with \"quotes\" and text "}}]}`, i)))
+	}
+	chunks = append(chunks,
+		[]byte(`data: {"id":"chatcmpl-1","model":"gpt-5","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"Bash","arguments":"{\"command\":\"ls\"}"}}]}}]}`),
+		[]byte(`data: {"id":"chatcmpl-1","model":"gpt-5","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`),
+		[]byte(`data: {"id":"chatcmpl-1","model":"gpt-5","choices":[],"usage":{"prompt_tokens":100,"completion_tokens":50,"total_tokens":150}}`),
+		[]byte(`data: [DONE]`),
+	)
+	return chunks
+}
+
+func BenchmarkConvertOpenAIResponseToClaude_Stream(b *testing.B) {
+	original := largeClaudeTranscript(200, 40)
+	chunks := openAIStreamChunks(20000)
+	size := 0
+	for _, chunk := range chunks {
+		size += len(chunk)
+	}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		var param any
+		for _, chunk := range chunks {
+			ConvertOpenAIResponseToClaude(ctx, "gpt-5", original, nil, chunk, &param)
+		}
+	}
+}
+
+// The allocation budgets guard the translator hot path against regressions
+// such as appending to growing JSON documents or re-marshaling parsed values.
+// Raise them only together with benchmark numbers that justify it.
+func TestTranslatorAllocationBudget(t *testing.T) {
+	payload := largeClaudeTranscript(50, 10)
+	requestAllocs := testing.AllocsPerRun(5, func() {
+		ConvertClaudeRequestToOpenAI("gpt-5", payload, true)
+	})
+	// 150 messages and 10 tools.
+	if budget := 40.0 * 160; requestAllocs > budget {
+		t.Errorf("request translation: %.0f allocs, budget %.0f", requestAllocs, budget)
+	}
+
+	ctx := context.Background()
+	original := []byte(`{"stream":true}`)
+	chunks := openAIStreamChunks(1)
+	var param any
+	ConvertOpenAIResponseToClaude(ctx, "gpt-5", original, nil, chunks[0], &param)
+	chunkAllocs := testing.AllocsPerRun(100, func() {
+		ConvertOpenAIResponseToClaude(ctx, "gpt-5", original, nil, chunks[0], &param)
+	})
+	if budget := 8.0; chunkAllocs > budget {
+		t.Errorf("stream chunk translation: %.0f allocs, budget %.0f", chunkAllocs, budget)
+	}
+	t.Logf("request %.0f allocs, chunk %.0f allocs", requestAllocs, chunkAllocs)
+}
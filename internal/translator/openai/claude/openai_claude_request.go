@@ -84,34 +84,36 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
-	// Process messages and system
-	var messagesJSON = "[]"
+	// Process messages and system. Messages are collected as raw JSON and
+	// joined once, since appending to a growing array with sjson copies it
+	// on every call.
+	var messages []string
 
 	// Handle system message first
-	systemMsgJSON := `{"role":"system","content":[]}`
+	var systemItems []string
 	if system := root.Get("system"); system.Exists() {
 		if system.Type == gjson.String {
 			if system.String() != "" {
 				oldSystem := `{"type":"text","text":""}`
 				oldSystem, _ = sjson.Set(oldSystem, "text", system.String())
-				systemMsgJSON, _ = sjson.SetRaw(systemMsgJSON, "content.-1", oldSystem)
+				systemItems = append(systemItems, oldSystem)
 			}
 		} else if system.Type == gjson.JSON {
 			if system.IsArray() {
 				systemResults := system.Array()
 				for i := 0; i < len(systemResults); i++ {
 					if contentItem, ok := convertClaudeContentPart(systemResults[i]); ok {
-						systemMsgJSON, _ = sjson.SetRaw(systemMsgJSON, "content.-1", contentItem)
+						systemItems = append(systemItems, contentItem)
 					}
 				}
 			}
 		}
 	}
-	messagesJSON, _ = sjson.SetRaw(messagesJSON, "-1", systemMsgJSON)
+	messages = append(messages, `{"role":"system","content":`+joinRawArray(systemItems)+`}`)
 
 	// Process Anthropic messages
-	if messages := root.Get("messages"); messages.Exists() && messages.IsArray() {
-		messages.ForEach(func(_, message gjson.Result) bool {
+	if claudeMessages := root.Get("messages"); claudeMessages.Exists() && claudeMessages.IsArray() {
+		claudeMessages.ForEach(func(_, message gjson.Result) bool {
 			role := message.Get("role").String()
 			contentResult := message.Get("content")
 
@@ -119,7 +121,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			if contentResult.Exists() && contentResult.IsArray() {
 				var contentItems []string
 				var reasoningParts []string // Accumulate thinking text for reasoning_content
-				var toolCalls []interface{}
+				var toolCalls []string
 				var toolResults []string // Collect tool_result messages to emit after the main message
 
 				contentResult.ForEach(func(_, part gjson.Result) bool {
@@ -159,7 +161,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 								toolCallJSON, _ = sjson.Set(toolCallJSON, "function.arguments", "{}")
 							}
 
-							toolCalls = append(toolCalls, gjson.Parse(toolCallJSON).Value())
+							toolCalls = append(toolCalls, toolCallJSON)
 						}
 
 					case "tool_result":
@@ -186,9 +188,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				// OpenAI requires: tool messages MUST immediately follow the assistant message with tool_calls.
 				// Therefore, we emit tool_result messages FIRST (they respond to the previous assistant's tool_calls),
 				// then emit the current message's content.
-				for _, toolResultJSON := range toolResults {
-					messagesJSON, _ = sjson.Set(messagesJSON, "-1", gjson.Parse(toolResultJSON).Value())
-				}
+				messages = append(messages, toolResults...)
 
 				// For assistant messages: emit a single unified message with content, tool_calls, and reasoning_content
 				// This avoids splitting into multiple assistant messages which breaks OpenAI tool-call adjacency
@@ -198,11 +198,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 						// Add content (as array if we have items, empty string if reasoning-only)
 						if hasContent {
-							contentArrayJSON := "[]"
-							for _, contentItem := range contentItems {
-								contentArrayJSON, _ = sjson.SetRaw(contentArrayJSON, "-1", contentItem)
-							}
-							msgJSON, _ = sjson.SetRaw(msgJSON, "content", contentArrayJSON)
+							msgJSON, _ = sjson.SetRaw(msgJSON, "content", joinRawArray(contentItems))
 						} else {
 							// Ensure content field exists for OpenAI compatibility
 							msgJSON, _ = sjson.Set(msgJSON, "content", "")
@@ -215,10 +211,10 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 						// Add tool_calls if present (in same message as content)
 						if hasToolCalls {
-							msgJSON, _ = sjson.Set(msgJSON, "tool_calls", toolCalls)
+							msgJSON, _ = sjson.SetRaw(msgJSON, "tool_calls", joinRawArray(toolCalls))
 						}
 
-						messagesJSON, _ = sjson.Set(messagesJSON, "-1", gjson.Parse(msgJSON).Value())
+						messages = append(messages, msgJSON)
 					}
 				} else {
 					// For non-assistant roles: emit content message if we have content
@@ -227,13 +223,9 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						msgJSON := `{"role":""}`
 						msgJSON, _ = sjson.Set(msgJSON, "role", role)
 
-						contentArrayJSON := "[]"
-						for _, contentItem := range contentItems {
-							contentArrayJSON, _ = sjson.SetRaw(contentArrayJSON, "-1", contentItem)
-						}
-						msgJSON, _ = sjson.SetRaw(msgJSON, "content", contentArrayJSON)
+						msgJSON, _ = sjson.SetRaw(msgJSON, "content", joinRawArray(contentItems))
 
-						messagesJSON, _ = sjson.Set(messagesJSON, "-1", gjson.Parse(msgJSON).Value())
+						messages = append(messages, msgJSON)
 					} else if hasToolResults && !hasContent {
 						// tool_results already emitted above, no additional user message needed
 					}
@@ -244,7 +236,7 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 				msgJSON := `{"role":"","content":""}`
 				msgJSON, _ = sjson.Set(msgJSON, "role", role)
 				msgJSON, _ = sjson.Set(msgJSON, "content", contentResult.String())
-				messagesJSON, _ = sjson.Set(messagesJSON, "-1", gjson.Parse(msgJSON).Value())
+				messages = append(messages, msgJSON)
 			}
 
 			return true
@@ -252,13 +244,11 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 	}
 
 	// Set messages
-	if gjson.Parse(messagesJSON).IsArray() && len(gjson.Parse(messagesJSON).Array()) > 0 {
-		out, _ = sjson.SetRaw(out, "messages", messagesJSON)
-	}
+	out, _ = sjson.SetRaw(out, "messages", joinRawArray(messages))
 
 	// Process tools - convert Anthropic tools to OpenAI functions
 	if tools := root.Get("tools"); tools.Exists() && tools.IsArray() {
-		var toolsJSON = "[]"
+		var toolsJSON []string
 
 		tools.ForEach(func(_, tool gjson.Result) bool {
 			openAIToolJSON := `{"type":"function","function":{"name":"","description":""}}`
@@ -267,15 +257,15 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 
 			// Convert Anthropic input_schema to OpenAI function parameters
 			if inputSchema := tool.Get("input_schema"); inputSchema.Exists() {
-				openAIToolJSON, _ = sjson.Set(openAIToolJSON, "function.parameters", inputSchema.Value())
+				openAIToolJSON, _ = sjson.SetRaw(openAIToolJSON, "function.parameters", inputSchema.Raw)
 			}
 
-			toolsJSON, _ = sjson.Set(toolsJSON, "-1", gjson.Parse(openAIToolJSON).Value())
+			toolsJSON = append(toolsJSON, openAIToolJSON)
 			return true
 		})
 
-		if gjson.Parse(toolsJSON).IsArray() && len(gjson.Parse(toolsJSON).Array()) > 0 {
-			out, _ = sjson.SetRaw(out, "tools", toolsJSON)
+		if len(toolsJSON) > 0 {
+			out, _ = sjson.SetRaw(out, "tools", joinRawArray(toolsJSON))
 		}
 	}
 
@@ -286,6 +276,8 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			out, _ = sjson.Set(out, "tool_choice", "auto")
 		case "any":
 			out, _ = sjson.Set(out, "tool_choice", "required")
+		case "none":
+			out, _ = sjson.Set(out, "tool_choice", "none")
 		case "tool":
 			// Specific tool choice
 			toolName := toolChoice.Get("name").String()
@@ -298,14 +290,21 @@ func ConvertClaudeRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 		}
 	}
 
-	// Handle user parameter (for tracking)
+	// Handle user parameter (for tracking); Anthropic clients send it as metadata.user_id.
 	if user := root.Get("user"); user.Exists() {
 		out, _ = sjson.Set(out, "user", user.String())
+	} else if userID := root.Get("metadata.user_id"); userID.Exists() && userID.String() != "" {
+		out, _ = sjson.Set(out, "user", userID.String())
 	}
 
 	return []byte(out)
 }
 
+// joinRawArray returns a JSON array of the given raw JSON values.
+func joinRawArray(items []string) string {
+	return "[" + strings.Join(items, ",") + "]"
+}
+
 func convertClaudeContentPart(part gjson.Result) (string, bool) {
 	partType := part.Get("type").String()
 
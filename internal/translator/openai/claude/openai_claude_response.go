@@ -9,7 +9,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
@@ -18,6 +20,7 @@ import (
 
 var (
 	dataTag = []byte("data:")
+	doneTag = []byte("[DONE]")
 )
 
 // ConvertOpenAIResponseToAnthropicParams holds parameters for response conversion
@@ -51,6 +54,11 @@ type ConvertOpenAIResponseToAnthropicParams struct {
 	ThinkingContentBlockIndex int
 	// Next available content block index
 	NextContentBlockIndex int
+	// Forward tool arguments as they arrive instead of at the end of the call
+	IncrementalToolArguments bool
+	// Whether the original request asked for a stream, resolved on the first
+	// chunk so the request body is not searched again for every chunk
+	Stream bool
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -58,6 +66,8 @@ type ToolCallAccumulator struct {
 	ID        string
 	Name      string
 	Arguments strings.Builder
+	// Number of argument bytes already forwarded in incremental mode
+	Forwarded int
 }
 
 // ConvertOpenAIResponseToClaude converts OpenAI streaming response format to Anthropic API format.
@@ -72,7 +82,7 @@ type ToolCallAccumulator struct {
 //
 // Returns:
 //   - []string: A slice of strings, each containing an Anthropic-compatible JSON response.
-func ConvertOpenAIResponseToClaude(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+func ConvertOpenAIResponseToClaude(ctx context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
 		*param = &ConvertOpenAIResponseToAnthropicParams{
 			MessageID:                   "",
@@ -89,6 +99,10 @@ func ConvertOpenAIResponseToClaude(_ context.Context, _ string, originalRequestR
 			TextContentBlockIndex:       -1,
 			ThinkingContentBlockIndex:   -1,
 			NextContentBlockIndex:       0,
+			IncrementalToolArguments:    util.IncrementalToolArguments(ctx),
+		}
+		if streamResult := gjson.GetBytes(originalRequestRawJSON, "stream"); streamResult.Exists() && streamResult.Type != gjson.False {
+			(*param).(*ConvertOpenAIResponseToAnthropicParams).Stream = true
 		}
 	}
 
@@ -98,17 +112,14 @@ func ConvertOpenAIResponseToClaude(_ context.Context, _ string, originalRequestR
 	rawJSON = bytes.TrimSpace(rawJSON[5:])
 
 	// Check if this is the [DONE] marker
-	rawStr := strings.TrimSpace(string(rawJSON))
-	if rawStr == "[DONE]" {
+	if bytes.Equal(rawJSON, doneTag) {
 		return convertOpenAIDoneToAnthropic((*param).(*ConvertOpenAIResponseToAnthropicParams))
 	}
 
-	streamResult := gjson.GetBytes(originalRequestRawJSON, "stream")
-	if !streamResult.Exists() || (streamResult.Exists() && streamResult.Type == gjson.False) {
-		return convertOpenAINonStreamingToAnthropic(rawJSON)
-	} else {
-		return convertOpenAIStreamingChunkToAnthropic(rawJSON, (*param).(*ConvertOpenAIResponseToAnthropicParams))
+	if params := (*param).(*ConvertOpenAIResponseToAnthropicParams); params.Stream {
+		return convertOpenAIStreamingChunkToAnthropic(rawJSON, params)
 	}
+	return convertOpenAINonStreamingToAnthropic(rawJSON)
 }
 
 // convertOpenAIStreamingChunkToAnthropic converts OpenAI streaming chunk to Anthropic streaming events
@@ -127,9 +138,25 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 		param.CreatedAt = root.Get("created").Int()
 	}
 
+	choice := root.Get("choices.0")
+
 	// Emit message_start on the very first chunk, regardless of whether it has a role field.
 	// Some providers (like Copilot) may send tool_calls in the first chunk without a role field.
-	if delta := root.Get("choices.0.delta"); delta.Exists() {
+	if delta := choice.Get("delta"); delta.Exists() {
+		// Read the delta fields in one pass over the object.
+		var reasoning, content, toolCalls gjson.Result
+		delta.ForEach(func(key, value gjson.Result) bool {
+			switch key.Str {
+			case "reasoning_content":
+				reasoning = value
+			case "content":
+				content = value
+			case "tool_calls":
+				toolCalls = value
+			}
+			return true
+		})
+
 		if !param.MessageStarted {
 			// Send message_start event
 			messageStartJSON := `{"type":"message_start","message":{"id":"","type":"message","role":"assistant","model":"","content":[],"stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":0,"output_tokens":0}}}`
@@ -142,7 +169,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 		}
 
 		// Handle reasoning content delta
-		if reasoning := delta.Get("reasoning_content"); reasoning.Exists() {
+		if reasoning.Exists() {
 			for _, reasoningText := range collectOpenAIReasoningTexts(reasoning) {
 				if reasoningText == "" {
 					continue
@@ -159,15 +186,12 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 					param.ThinkingContentBlockStarted = true
 				}
 
-				thinkingDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":""}}`
-				thinkingDeltaJSON, _ = sjson.Set(thinkingDeltaJSON, "index", param.ThinkingContentBlockIndex)
-				thinkingDeltaJSON, _ = sjson.Set(thinkingDeltaJSON, "delta.thinking", reasoningText)
-				results = append(results, "event: content_block_delta\ndata: "+thinkingDeltaJSON+"\n\n")
+				results = append(results, contentBlockDeltaEvent(param.ThinkingContentBlockIndex, "thinking_delta", "thinking", reasoningText))
 			}
 		}
 
 		// Handle content delta
-		if content := delta.Get("content"); content.Exists() && content.String() != "" {
+		if text := content.String(); text != "" {
 			// Send content_block_start for text if not already sent
 			if !param.TextContentBlockStarted {
 				stopThinkingContentBlock(param, &results)
@@ -181,17 +205,14 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 				param.TextContentBlockStarted = true
 			}
 
-			contentDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":""}}`
-			contentDeltaJSON, _ = sjson.Set(contentDeltaJSON, "index", param.TextContentBlockIndex)
-			contentDeltaJSON, _ = sjson.Set(contentDeltaJSON, "delta.text", content.String())
-			results = append(results, "event: content_block_delta\ndata: "+contentDeltaJSON+"\n\n")
+			results = append(results, contentBlockDeltaEvent(param.TextContentBlockIndex, "text_delta", "text", text))
 
 			// Accumulate content
-			param.ContentAccumulator.WriteString(content.String())
+			param.ContentAccumulator.WriteString(text)
 		}
 
 		// Handle tool calls
-		if toolCalls := delta.Get("tool_calls"); toolCalls.Exists() && toolCalls.IsArray() {
+		if toolCalls.IsArray() {
 			if param.ToolCallsAccumulator == nil {
 				param.ToolCallsAccumulator = make(map[int]*ToolCallAccumulator)
 			}
@@ -236,6 +257,12 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 							accumulator.Arguments.WriteString(argsText)
 						}
 					}
+					if param.IncrementalToolArguments && accumulator.Name != "" {
+						if pending := accumulator.Arguments.String()[accumulator.Forwarded:]; pending != "" {
+							results = append(results, inputJSONDeltaEvent(blockIndex, pending))
+							accumulator.Forwarded = accumulator.Arguments.Len()
+						}
+					}
 				}
 
 				return true
@@ -244,7 +271,7 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 	}
 
 	// Handle finish_reason (but don't send message_delta/message_stop yet)
-	if finishReason := root.Get("choices.0.finish_reason"); finishReason.Exists() && finishReason.String() != "" {
+	if finishReason := choice.Get("finish_reason"); finishReason.Exists() && finishReason.String() != "" {
 		reason := finishReason.String()
 		param.FinishReason = reason
 
@@ -266,12 +293,9 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 				accumulator := param.ToolCallsAccumulator[index]
 				blockIndex := param.toolContentBlockIndex(index)
 
-				// Send complete input_json_delta with all accumulated arguments
-				if accumulator.Arguments.Len() > 0 {
-					inputDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`
-					inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "index", blockIndex)
-					inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "delta.partial_json", util.FixJSON(accumulator.Arguments.String()))
-					results = append(results, "event: content_block_delta\ndata: "+inputDeltaJSON+"\n\n")
+				// Send the remaining tool input (all of it unless streamed incrementally)
+				if partial := finalToolInput(param, accumulator); partial != "" {
+					results = append(results, inputJSONDeltaEvent(blockIndex, partial))
 				}
 
 				contentBlockStopJSON := `{"type":"content_block_stop","index":0}`
@@ -300,6 +324,9 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 			if cachedTokens > 0 {
 				messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "usage.cache_read_input_tokens", cachedTokens)
 			}
+			if reasoningTokens := usage.Get("completion_tokens_details.reasoning_tokens").Int(); reasoningTokens > 0 {
+				messageDeltaJSON, _ = sjson.Set(messageDeltaJSON, "usage.thinking_tokens", reasoningTokens)
+			}
 			results = append(results, "event: message_delta\ndata: "+messageDeltaJSON+"\n\n")
 			param.MessageDeltaSent = true
 
@@ -310,6 +337,122 @@ func convertOpenAIStreamingChunkToAnthropic(rawJSON []byte, param *ConvertOpenAI
 	return results
 }
 
+// finalToolInput returns the tool input still to be sent when a tool call ends.
+// Buffered arguments are sent whole after repairing single-quoted JSON; in
+// incremental mode only the unsent tail is returned, plus whatever closes the
+// streamed JSON.
+func finalToolInput(param *ConvertOpenAIResponseToAnthropicParams, accumulator *ToolCallAccumulator) string {
+	arguments := accumulator.Arguments.String()
+	if !param.IncrementalToolArguments {
+		if arguments == "" {
+			return ""
+		}
+		return util.FixJSON(arguments)
+	}
+	pending := arguments[accumulator.Forwarded:]
+	accumulator.Forwarded = len(arguments)
+	if arguments == "" {
+		return ""
+	}
+	suffix, _ := util.CloseJSONSuffix(arguments)
+	return pending + suffix
+}
+
+// inputJSONDeltaEvent builds an input_json_delta event for a tool_use block.
+func inputJSONDeltaEvent(blockIndex int, partial string) string {
+	return contentBlockDeltaEvent(blockIndex, "input_json_delta", "partial_json", partial)
+}
+
+// contentBlockDeltaEvent builds a content_block_delta event. The event is
+// written directly rather than through sjson since it is emitted for nearly
+// every upstream chunk.
+func contentBlockDeltaEvent(blockIndex int, deltaType, field, value string) string {
+	var b strings.Builder
+	b.Grow(len(value) + 128)
+	b.WriteString(`event: content_block_delta
+data: {"type":"content_block_delta","index":`)
+	b.WriteString(strconv.Itoa(blockIndex))
+	b.WriteString(`,"delta":{"type":"`)
+	b.WriteString(deltaType)
+	b.WriteString(`","`)
+	b.WriteString(field)
+	b.WriteString(`":`)
+	writeJSONString(&b, value)
+	b.WriteString("}}\n\n")
+	return b.String()
+}
+
+// writeJSONString writes s as a JSON string, encoded the way sjson encodes
+// string values: verbatim when nothing needs escaping, otherwise as
+// encoding/json would, including its HTML-safe escapes.
+func writeJSONString(b *strings.Builder, s string) {
+	const hex = "0123456789abcdef"
+	b.WriteByte('"')
+	if !needsJSONEscape(s) {
+		b.WriteString(s)
+		b.WriteByte('"')
+		return
+	}
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			default:
+				b.WriteString(`\u00`)
+				b.WriteByte(hex[c>>4])
+				b.WriteByte(hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteString(s[start:i])
+			b.WriteRune(utf8.RuneError)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b.WriteString(s[start:i])
+			b.WriteString(`\u202`)
+			b.WriteByte(hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b.WriteString(s[start:])
+	b.WriteByte('"')
+}
+
+// needsJSONEscape mirrors the check sjson uses to decide whether a string
+// value is written verbatim.
+func needsJSONEscape(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > 0x7f || c == '"' || c == '\\' {
+			return true
+		}
+	}
+	return false
+}
+
 // convertOpenAIDoneToAnthropic handles the [DONE] marker and sends final events
 func convertOpenAIDoneToAnthropic(param *ConvertOpenAIResponseToAnthropicParams) []string {
 	var results []string
@@ -330,11 +473,8 @@ func convertOpenAIDoneToAnthropic(param *ConvertOpenAIResponseToAnthropicParams)
 			accumulator := param.ToolCallsAccumulator[index]
 			blockIndex := param.toolContentBlockIndex(index)
 
-			if accumulator.Arguments.Len() > 0 {
-				inputDeltaJSON := `{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":""}}`
-				inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "index", blockIndex)
-				inputDeltaJSON, _ = sjson.Set(inputDeltaJSON, "delta.partial_json", util.FixJSON(accumulator.Arguments.String()))
-				results = append(results, "event: content_block_delta\ndata: "+inputDeltaJSON+"\n\n")
+			if partial := finalToolInput(param, accumulator); partial != "" {
+				results = append(results, inputJSONDeltaEvent(blockIndex, partial))
 			}
 
 			contentBlockStopJSON := `{"type":"content_block_stop","index":0}`
@@ -425,6 +565,9 @@ func convertOpenAINonStreamingToAnthropic(rawJSON []byte) []string {
 		if cachedTokens > 0 {
 			out, _ = sjson.Set(out, "usage.cache_read_input_tokens", cachedTokens)
 		}
+		if reasoningTokens := usage.Get("completion_tokens_details.reasoning_tokens").Int(); reasoningTokens > 0 {
+			out, _ = sjson.Set(out, "usage.thinking_tokens", reasoningTokens)
+		}
 	}
 
 	return []string{out}
@@ -675,6 +818,9 @@ func ConvertOpenAIResponseToClaudeNonStream(_ context.Context, _ string, origina
 		if cachedTokens > 0 {
 			out, _ = sjson.Set(out, "usage.cache_read_input_tokens", cachedTokens)
 		}
+		if reasoningTokens := respUsage.Get("completion_tokens_details.reasoning_tokens").Int(); reasoningTokens > 0 {
+			out, _ = sjson.Set(out, "usage.thinking_tokens", reasoningTokens)
+		}
 	}
 
 	if !stopReasonSet {
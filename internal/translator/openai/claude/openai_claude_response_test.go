@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func TestConvertOpenAIResponseToClaude_IncrementalToolArguments(t *testing.T) {
+	chunks := []string{
+		`data: {"id":"c1","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"Bash","arguments":"{\"comm"}}]}}]}`,
+		`data: {"id":"c1","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"and\":\"ls"}}]}}]}`,
+		`data: {"id":"c1","model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+	run := func(ctx context.Context) []string {
+		var param any
+		original := []byte(`{"stream":true}`)
+		var fragments []string
+		for _, chunk := range chunks {
+			for _, event := range ConvertOpenAIResponseToClaude(ctx, "m", original, nil, []byte(chunk), &param) {
+				data := event[strings.Index(event, "data: ")+len("data: "):]
+				if delta := gjson.Get(data, "delta"); delta.Get("type").String() == "input_json_delta" {
+					fragments = append(fragments, delta.Get("partial_json").String())
+				}
+			}
+		}
+		return fragments
+	}
+
+	if got := run(context.Background()); len(got) != 1 || got[0] != `{"command":"ls` {
+		t.Fatalf("buffered fragments = %q", got)
+	}
+	got := run(context.WithValue(context.Background(), util.IncrementalToolArgumentsKey, true))
+	if len(got) != 3 || got[0] != `{"comm` || got[1] != `and":"ls` || got[2] != `"}` {
+		t.Fatalf("incremental fragments = %q", got)
+	}
+}
+
+func TestWriteJSONStringMatchesSjson(t *testing.T) {
+	for _, value := range []string{"plain <b>&", "quote \" and \\ backslash", "line\nbreak\ttab\r\x01", "unicode é 世界   ", "bad \xff utf8 <x>", ""} {
+		want, _ := sjson.Set(`{"v":""}`, "v", value)
+		var b strings.Builder
+		b.WriteString(`{"v":`)
+		writeJSONString(&b, value)
+		b.WriteString(`}`)
+		if got := b.String(); got != want {
+			t.Errorf("writeJSONString(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestConvertOpenAIResponseToClaude_ThinkingTokens(t *testing.T) {
+	chunks := []string{
+		`data: {"id":"c1","model":"m","choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+		`data: {"id":"c1","model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: {"id":"c1","model":"m","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":40,"completion_tokens_details":{"reasoning_tokens":32}}}`,
+	}
+	var param any
+	var usage gjson.Result
+	for _, chunk := range chunks {
+		for _, event := range ConvertOpenAIResponseToClaude(context.Background(), "m", []byte(`{"stream":true}`), nil, []byte(chunk), &param) {
+			data := event[strings.Index(event, "data: ")+len("data: "):]
+			if gjson.Get(data, "type").String() == "message_delta" {
+				usage = gjson.Get(data, "usage")
+			}
+		}
+	}
+	if usage.Get("output_tokens").Int() != 40 || usage.Get("thinking_tokens").Int() != 32 {
+		t.Fatalf("stream usage = %s", usage.Raw)
+	}
+
+	out := ConvertOpenAIResponseToClaudeNonStream(context.Background(), "m", nil, nil, []byte(`{"id":"c1","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":40,"completion_tokens_details":{"reasoning_tokens":32}}}`), nil)
+	if got := gjson.Get(out, "usage.thinking_tokens").Int(); got != 32 {
+		t.Fatalf("non-stream usage = %s", gjson.Get(out, "usage").Raw)
+	}
+}
@@ -25,6 +25,18 @@ func Register(from, to string, request interfaces.TranslateRequestFunc, response
 	registry.Register(sdktranslator.FromString(from), sdktranslator.FromString(to), request, response)
 }
 
+// RegisterContext registers a translator whose request function receives a
+// context, for conversions that synthesize identifiers or timestamps.
+//
+// Parameters:
+//   - from: The source API format identifier
+//   - to: The target API format identifier
+//   - request: The context-aware request translation function
+//   - response: The response translation function
+func RegisterContext(from, to string, request interfaces.TranslateRequestContextFunc, response interfaces.TranslateResponse) {
+	registry.RegisterContext(sdktranslator.FromString(from), sdktranslator.FromString(to), request, response)
+}
+
 // Request translates a request from one API format to another.
 //
 // Parameters:
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 )
 
@@ -35,7 +36,8 @@ type LoggerPlugin struct {
 func NewLoggerPlugin() *LoggerPlugin { return &LoggerPlugin{stats: defaultRequestStatistics} }
 
 // HandleUsage implements coreusage.Plugin.
-// It updates the in-memory statistics store whenever a usage record is received.
+// It updates the in-memory statistics and the usage store whenever a usage
+// record is received.
 //
 // Parameters:
 //   - ctx: The context for the usage record
@@ -48,6 +50,7 @@ func (p *LoggerPlugin) HandleUsage(ctx context.Context, record coreusage.Record)
 		return
 	}
 	p.stats.Record(ctx, record)
+	recordToStore(ctx, record)
 }
 
 // SetStatisticsEnabled toggles whether in-memory statistics are recorded.
@@ -65,7 +68,8 @@ type RequestStatistics struct {
 	failureCount  int64
 	totalTokens   int64
 
-	apis map[string]*apiStats
+	apis    map[string]*apiStats
+	tenants map[string]*TenantSnapshot
 
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
@@ -92,6 +96,7 @@ type RequestDetail struct {
 	Timestamp time.Time  `json:"timestamp"`
 	Source    string     `json:"source"`
 	AuthIndex string     `json:"auth_index"`
+	Tenant    string     `json:"tenant,omitempty"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
 }
@@ -114,6 +119,9 @@ type StatisticsSnapshot struct {
 
 	APIs map[string]APISnapshot `json:"apis"`
 
+	// Tenants totals the requests of API keys assigned to a tenant.
+	Tenants map[string]TenantSnapshot `json:"tenants,omitempty"`
+
 	RequestsByDay  map[string]int64 `json:"requests_by_day"`
 	RequestsByHour map[string]int64 `json:"requests_by_hour"`
 	TokensByDay    map[string]int64 `json:"tokens_by_day"`
@@ -127,6 +135,13 @@ type APISnapshot struct {
 	Models        map[string]ModelSnapshot `json:"models"`
 }
 
+// TenantSnapshot summarises metrics for a single tenant.
+type TenantSnapshot struct {
+	TotalRequests int64 `json:"total_requests"`
+	FailureCount  int64 `json:"failure_count"`
+	TotalTokens   int64 `json:"total_tokens"`
+}
+
 // ModelSnapshot summarises metrics for a specific model.
 type ModelSnapshot struct {
 	TotalRequests int64           `json:"total_requests"`
@@ -143,6 +158,7 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
 		apis:           make(map[string]*apiStats),
+		tenants:        make(map[string]*TenantSnapshot),
 		requestsByDay:  make(map[string]int64),
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
@@ -196,13 +212,16 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
+	requestDetail := RequestDetail{
 		Timestamp: timestamp,
 		Source:    record.Source,
 		AuthIndex: record.AuthIndex,
+		Tenant:    resolveTenant(ctx),
 		Tokens:    detail,
 		Failed:    failed,
-	})
+	}
+	s.updateAPIStats(stats, modelName, requestDetail)
+	s.updateTenantStats(requestDetail)
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -223,6 +242,22 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
+func (s *RequestStatistics) updateTenantStats(detail RequestDetail) {
+	if detail.Tenant == "" {
+		return
+	}
+	stats, ok := s.tenants[detail.Tenant]
+	if !ok {
+		stats = &TenantSnapshot{}
+		s.tenants[detail.Tenant] = stats
+	}
+	stats.TotalRequests++
+	if detail.Failed {
+		stats.FailureCount++
+	}
+	stats.TotalTokens += detail.Tokens.TotalTokens
+}
+
 // Snapshot returns a copy of the aggregated metrics for external consumption.
 func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result := StatisticsSnapshot{}
@@ -256,6 +291,12 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 		}
 		result.APIs[apiName] = apiSnapshot
 	}
+	if len(s.tenants) > 0 {
+		result.Tenants = make(map[string]TenantSnapshot, len(s.tenants))
+		for tenant, stats := range s.tenants {
+			result.Tenants[tenant] = *stats
+		}
+	}
 
 	result.RequestsByDay = make(map[string]int64, len(s.requestsByDay))
 	for k, v := range s.requestsByDay {
@@ -287,6 +328,19 @@ type MergeResult struct {
 	Skipped int64 `json:"skipped"`
 }
 
+// LimitDetails keeps only the n most recent request details of each model.
+// Totals are left unchanged.
+func (s *StatisticsSnapshot) LimitDetails(n int) {
+	for _, api := range s.APIs {
+		for modelName, model := range api.Models {
+			if len(model.Details) > n {
+				model.Details = model.Details[len(model.Details)-n:]
+				api.Models[modelName] = model
+			}
+		}
+	}
+}
+
 // MergeSnapshot merges an exported statistics snapshot into the current store.
 // Existing data is preserved and duplicate request details are skipped.
 func (s *RequestStatistics) MergeSnapshot(snapshot StatisticsSnapshot) MergeResult {
@@ -365,6 +419,7 @@ func (s *RequestStatistics) recordImported(apiName, modelName string, stats *api
 	s.totalTokens += totalTokens
 
 	s.updateAPIStats(stats, modelName, detail)
+	s.updateTenantStats(detail)
 
 	dayKey := detail.Timestamp.Format("2006-01-02")
 	hourKey := detail.Timestamp.Hour()
@@ -394,6 +449,17 @@ func dedupKey(apiName, modelName string, detail RequestDetail) string {
 	)
 }
 
+// resolveTenant returns the tenant of the API key that made the request.
+func resolveTenant(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ginCtx.GetString(logging.GinTenantKey)
+	}
+	return ""
+}
+
 func resolveAPIIdentifier(ctx context.Context, record coreusage.Record) string {
 	if ctx != nil {
 		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
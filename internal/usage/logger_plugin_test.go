@@ -0,0 +1,21 @@
+package usage
+
+import "testing"
+
+func TestStatisticsSnapshotLimitDetails(t *testing.T) {
+	details := []RequestDetail{{Source: "a"}, {Source: "b"}, {Source: "c"}}
+	snapshot := StatisticsSnapshot{APIs: map[string]APISnapshot{
+		"key": {Models: map[string]ModelSnapshot{
+			"big":   {TotalRequests: 3, Details: details},
+			"small": {TotalRequests: 1, Details: details[:1]},
+		}},
+	}}
+	snapshot.LimitDetails(2)
+	big := snapshot.APIs["key"].Models["big"]
+	if len(big.Details) != 2 || big.Details[0].Source != "b" || big.TotalRequests != 3 {
+		t.Fatalf("big = %+v", big)
+	}
+	if small := snapshot.APIs["key"].Models["small"]; len(small.Details) != 1 {
+		t.Fatalf("small = %+v", small)
+	}
+}
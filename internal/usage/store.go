@@ -0,0 +1,291 @@
+package usage
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Usage store types.
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+)
+
+// DailyUsage is the usage of one API key and model on one day (UTC).
+type DailyUsage struct {
+	Day             string `json:"day"`
+	APIKey          string `json:"api_key"`
+	Model           string `json:"model"`
+	Requests        int64  `json:"requests"`
+	Failures        int64  `json:"failures"`
+	InputTokens     int64  `json:"input_tokens"`
+	OutputTokens    int64  `json:"output_tokens"`
+	ReasoningTokens int64  `json:"reasoning_tokens"`
+	CachedTokens    int64  `json:"cached_tokens"`
+	TotalTokens     int64  `json:"total_tokens"`
+}
+
+func (u *DailyUsage) add(delta DailyUsage) {
+	u.Requests += delta.Requests
+	u.Failures += delta.Failures
+	u.InputTokens += delta.InputTokens
+	u.OutputTokens += delta.OutputTokens
+	u.ReasoningTokens += delta.ReasoningTokens
+	u.CachedTokens += delta.CachedTokens
+	u.TotalTokens += delta.TotalTokens
+}
+
+// Store persists daily usage per API key and model for billing
+// reconciliation. Unlike RequestStatistics it keeps no per-request detail, so
+// it stays small over long periods.
+type Store interface {
+	// Add adds delta to the row of its day, API key and model.
+	Add(delta DailyUsage) error
+	// Rows returns the rows whose day is within [from, to], sorted by day,
+	// API key and model. Empty bounds are open.
+	Rows(from, to string) ([]DailyUsage, error)
+	Close() error
+}
+
+// MemoryStore is a Store that lives as long as the process.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	rows map[[3]string]*DailyUsage
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rows: make(map[[3]string]*DailyUsage)}
+}
+
+// Add implements Store.
+func (s *MemoryStore) Add(delta DailyUsage) error {
+	key := [3]string{delta.Day, delta.APIKey, delta.Model}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row, ok := s.rows[key]
+	if !ok {
+		row = &DailyUsage{Day: delta.Day, APIKey: delta.APIKey, Model: delta.Model}
+		s.rows[key] = row
+	}
+	row.add(delta)
+	return nil
+}
+
+// Rows implements Store.
+func (s *MemoryStore) Rows(from, to string) ([]DailyUsage, error) {
+	s.mu.RLock()
+	rows := make([]DailyUsage, 0, len(s.rows))
+	for _, row := range s.rows {
+		if (from == "" || row.Day >= from) && (to == "" || row.Day <= to) {
+			rows = append(rows, *row)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.APIKey != b.APIKey {
+			return a.APIKey < b.APIKey
+		}
+		return a.Model < b.Model
+	})
+	return rows, nil
+}
+
+// Close implements Store.
+func (s *MemoryStore) Close() error { return nil }
+
+// FileStore is a Store backed by an append-only file of JSON lines, one per
+// recorded delta, which is read back into memory when the store is opened.
+type FileStore struct {
+	*MemoryStore
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileStore opens or creates the usage file at path.
+func OpenFileStore(path string) (*FileStore, error) {
+	store, err := LoadFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("usage store: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("usage store: %w", err)
+	}
+	return &FileStore{MemoryStore: store, file: file}, nil
+}
+
+// LoadFileStore reads the usage file at path into a MemoryStore without
+// opening it for writing, for offline export. A missing file is empty.
+func LoadFileStore(path string) (*MemoryStore, error) {
+	store := NewMemoryStore()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("usage store: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var delta DailyUsage
+		if err = json.Unmarshal(scanner.Bytes(), &delta); err != nil {
+			// A torn last line after a crash must not lose the rest.
+			log.Warnf("usage store: skipping %s:%d: %v", path, line, err)
+			continue
+		}
+		_ = store.Add(delta)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("usage store: %w", err)
+	}
+	return store, nil
+}
+
+// Add implements Store.
+func (s *FileStore) Add(delta DailyUsage) error {
+	line, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	_, err = s.file.Write(append(line, '\n'))
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("usage store: %w", err)
+	}
+	return s.MemoryStore.Add(delta)
+}
+
+// Close implements Store.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// OpenStore opens the store described by cfg. An empty type selects
+// StoreMemory.
+func OpenStore(cfg config.UsageStoreConfig) (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+	case "", StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreFile:
+		if strings.TrimSpace(cfg.Path) == "" {
+			return nil, fmt.Errorf("usage store: file store needs a path")
+		}
+		return OpenFileStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("usage store: unknown type %q", cfg.Type)
+	}
+}
+
+var activeStore atomic.Pointer[storeHolder]
+
+type storeHolder struct{ store Store }
+
+func init() {
+	activeStore.Store(&storeHolder{store: NewMemoryStore()})
+}
+
+// ConfigureStore opens the store described by cfg and makes it the one usage
+// records go to, closing the previous one. The previous store stays active
+// when the new one cannot be opened.
+func ConfigureStore(cfg config.UsageStoreConfig) error {
+	store, err := OpenStore(cfg)
+	if err != nil {
+		return err
+	}
+	if previous := activeStore.Swap(&storeHolder{store: store}); previous != nil {
+		if errClose := previous.store.Close(); errClose != nil {
+			log.Warnf("usage store: closing previous store: %v", errClose)
+		}
+	}
+	return nil
+}
+
+// CurrentStore returns the store usage records go to.
+func CurrentStore() Store { return activeStore.Load().store }
+
+// recordToStore adds a usage record to the active store.
+func recordToStore(ctx context.Context, record coreusage.Record) {
+	timestamp := record.RequestedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	apiKey := record.APIKey
+	if apiKey == "" {
+		apiKey = resolveAPIIdentifier(ctx, record)
+	}
+	model := record.Model
+	if model == "" {
+		model = "unknown"
+	}
+	tokens := normaliseDetail(record.Detail)
+	delta := DailyUsage{
+		Day:             timestamp.UTC().Format("2006-01-02"),
+		APIKey:          apiKey,
+		Model:           model,
+		Requests:        1,
+		InputTokens:     tokens.InputTokens,
+		OutputTokens:    tokens.OutputTokens,
+		ReasoningTokens: tokens.ReasoningTokens,
+		CachedTokens:    tokens.CachedTokens,
+		TotalTokens:     tokens.TotalTokens,
+	}
+	if record.Failed || !resolveSuccess(ctx) {
+		delta.Failures = 1
+	}
+	if err := CurrentStore().Add(delta); err != nil {
+		log.Warnf("%v", err)
+	}
+}
+
+// usageCSVHeader is the header row of WriteCSV.
+var usageCSVHeader = []string{"day", "api_key", "model", "requests", "failures", "input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens"}
+
+// WriteCSV writes rows as CSV with a header row.
+func WriteCSV(w io.Writer, rows []DailyUsage) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(usageCSVHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{row.Day, row.APIKey, row.Model}
+		for _, n := range []int64{row.Requests, row.Failures, row.InputTokens, row.OutputTokens, row.ReasoningTokens, row.CachedTokens, row.TotalTokens} {
+			record = append(record, strconv.FormatInt(n, 10))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestFileStorePersistsDailyUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage", "usage.jsonl")
+	if err := ConfigureStore(config.UsageStoreConfig{Type: StoreFile, Path: path}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ConfigureStore(config.UsageStoreConfig{}) })
+
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, record := range []coreusage.Record{
+		{APIKey: "k1", Model: "m1", RequestedAt: day, Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 5}},
+		{APIKey: "k1", Model: "m1", RequestedAt: day, Failed: true},
+		{APIKey: "k2", Model: "m1", RequestedAt: day.AddDate(0, 0, 1), Detail: coreusage.Detail{InputTokens: 1, OutputTokens: 1}},
+	} {
+		recordToStore(context.Background(), record)
+	}
+
+	store, err := LoadFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := store.Rows("2026-03-01", "2026-03-01")
+	if len(rows) != 1 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if row := rows[0]; row.APIKey != "k1" || row.Requests != 2 || row.Failures != 1 || row.InputTokens != 10 || row.TotalTokens != 15 {
+		t.Fatalf("row = %+v", row)
+	}
+
+	all, _ := store.Rows("", "")
+	var out bytes.Buffer
+	if err = WriteCSV(&out, all); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(usageCSVHeader, ",") || lines[2] != "2026-03-02,k2,m1,1,0,1,1,0,0,2" {
+		t.Fatalf("csv = %q", out.String())
+	}
+}
+
+func TestOpenStoreRejectsBadConfig(t *testing.T) {
+	if _, err := OpenStore(config.UsageStoreConfig{Type: StoreFile}); err == nil {
+		t.Fatal("file store without path accepted")
+	}
+	if _, err := OpenStore(config.UsageStoreConfig{Type: "sqlite"}); err == nil {
+		t.Fatal("unknown type accepted")
+	}
+}
+
+func TestFileStoreConcurrentAdds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const writers, adds = 16, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < adds; j++ {
+				if errAdd := store.Add(DailyUsage{Day: "2026-01-01", APIKey: "k", Model: "m", Requests: 1, TotalTokens: 2}); errAdd != nil {
+					t.Error(errAdd)
+				}
+				if j%10 == 0 {
+					_, _ = store.Rows("", "")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := reloaded.Rows("", "")
+	if len(rows) != 1 || rows[0].Requests != writers*adds || rows[0].TotalTokens != 2*writers*adds {
+		t.Fatalf("rows after concurrent adds = %+v", rows)
+	}
+}
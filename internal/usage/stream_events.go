@@ -0,0 +1,257 @@
+package usage
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// StreamAbortSnapshot reports how streaming requests ended after the downstream
+// client disconnected.
+type StreamAbortSnapshot struct {
+	// ClientDisconnects counts streams whose client went away before completion.
+	ClientDisconnects int64 `json:"client_disconnects"`
+	// UpstreamCancelled counts disconnects that cancelled the upstream call.
+	UpstreamCancelled int64 `json:"upstream_cancelled"`
+	// CompletedInGrace counts disconnects where the upstream finished within the
+	// configured grace period and was therefore not cancelled.
+	CompletedInGrace int64 `json:"completed_in_grace"`
+}
+
+var streamAborts struct {
+	disconnects atomic.Int64
+	cancelled   atomic.Int64
+	completed   atomic.Int64
+}
+
+// RecordStreamAbort records a client disconnect. completed reports whether the
+// upstream stream finished during the grace period instead of being cancelled.
+func RecordStreamAbort(completed bool) {
+	streamAborts.disconnects.Add(1)
+	if completed {
+		streamAborts.completed.Add(1)
+		return
+	}
+	streamAborts.cancelled.Add(1)
+}
+
+// StreamAborts returns the current client disconnect counters.
+func StreamAborts() StreamAbortSnapshot {
+	return StreamAbortSnapshot{
+		ClientDisconnects: streamAborts.disconnects.Load(),
+		UpstreamCancelled: streamAborts.cancelled.Load(),
+		CompletedInGrace:  streamAborts.completed.Load(),
+	}
+}
+
+var quarantinedEvents struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// RecordQuarantinedEvent counts an upstream stream event that was withheld from
+// translation because it did not match a known schema.
+func RecordQuarantinedEvent(provider, eventType string) {
+	if eventType == "" {
+		eventType = "unknown"
+	}
+	quarantinedEvents.mu.Lock()
+	defer quarantinedEvents.mu.Unlock()
+	if quarantinedEvents.counts == nil {
+		quarantinedEvents.counts = make(map[string]int64)
+	}
+	quarantinedEvents.counts[provider+":"+eventType]++
+}
+
+// QuarantinedEvents returns the number of quarantined upstream events keyed by
+// "provider:event_type".
+func QuarantinedEvents() map[string]int64 {
+	quarantinedEvents.mu.Lock()
+	defer quarantinedEvents.mu.Unlock()
+	out := make(map[string]int64, len(quarantinedEvents.counts))
+	for key, count := range quarantinedEvents.counts {
+		out[key] = count
+	}
+	return out
+}
+
+var echoStrips struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// RecordEchoStrip counts a response whose leading prompt echo was removed.
+func RecordEchoStrip(format string) {
+	echoStrips.mu.Lock()
+	defer echoStrips.mu.Unlock()
+	if echoStrips.counts == nil {
+		echoStrips.counts = make(map[string]int64)
+	}
+	echoStrips.counts[format]++
+}
+
+// EchoStrips returns the number of stripped prompt echoes keyed by response format.
+func EchoStrips() map[string]int64 {
+	echoStrips.mu.Lock()
+	defer echoStrips.mu.Unlock()
+	out := make(map[string]int64, len(echoStrips.counts))
+	for key, count := range echoStrips.counts {
+		out[key] = count
+	}
+	return out
+}
+
+// HedgeSnapshot reports how often streaming requests were hedged.
+type HedgeSnapshot struct {
+	// Candidates counts streams started with hedging enabled.
+	Candidates int64 `json:"candidates"`
+	// Hedged counts streams for which a duplicate request was started.
+	Hedged int64 `json:"hedged"`
+	// HedgeWins counts hedged streams where the duplicate produced data first.
+	HedgeWins int64 `json:"hedge_wins"`
+}
+
+var hedges struct {
+	candidates atomic.Int64
+	hedged     atomic.Int64
+	wins       atomic.Int64
+}
+
+// RecordHedgeCandidate counts a stream started with hedging enabled.
+func RecordHedgeCandidate() {
+	hedges.candidates.Add(1)
+}
+
+// RecordHedge counts a stream for which a duplicate request was started.
+// won reports whether the duplicate produced data first.
+func RecordHedge(won bool) {
+	hedges.hedged.Add(1)
+	if won {
+		hedges.wins.Add(1)
+	}
+}
+
+// Hedges returns the current hedging counters.
+func Hedges() HedgeSnapshot {
+	return HedgeSnapshot{
+		Candidates: hedges.candidates.Load(),
+		Hedged:     hedges.hedged.Load(),
+		HedgeWins:  hedges.wins.Load(),
+	}
+}
+
+// TrafficArmSnapshot reports the requests routed to one arm of a traffic split.
+type TrafficArmSnapshot struct {
+	// Requests counts executed requests assigned to the arm.
+	Requests int64 `json:"requests"`
+	// Failures counts requests that ended with an upstream error.
+	Failures int64 `json:"failures"`
+	// TotalLatencyMs sums the request latencies, so the mean latency is
+	// TotalLatencyMs / Requests.
+	TotalLatencyMs int64 `json:"total_latency_ms"`
+}
+
+var trafficArms struct {
+	mu     sync.Mutex
+	counts map[string]map[string]TrafficArmSnapshot
+}
+
+// RecordTrafficArm records a request the traffic split for model assigned to arm.
+func RecordTrafficArm(model, arm string, failed bool, latencyMs int64) {
+	trafficArms.mu.Lock()
+	defer trafficArms.mu.Unlock()
+	if trafficArms.counts == nil {
+		trafficArms.counts = make(map[string]map[string]TrafficArmSnapshot)
+	}
+	arms := trafficArms.counts[model]
+	if arms == nil {
+		arms = make(map[string]TrafficArmSnapshot)
+		trafficArms.counts[model] = arms
+	}
+	snapshot := arms[arm]
+	snapshot.Requests++
+	if failed {
+		snapshot.Failures++
+	}
+	snapshot.TotalLatencyMs += latencyMs
+	arms[arm] = snapshot
+}
+
+// TrafficArms returns the per-arm counters keyed by split model and arm model.
+func TrafficArms() map[string]map[string]TrafficArmSnapshot {
+	trafficArms.mu.Lock()
+	defer trafficArms.mu.Unlock()
+	out := make(map[string]map[string]TrafficArmSnapshot, len(trafficArms.counts))
+	for model, arms := range trafficArms.counts {
+		copied := make(map[string]TrafficArmSnapshot, len(arms))
+		for arm, snapshot := range arms {
+			copied[arm] = snapshot
+		}
+		out[model] = copied
+	}
+	return out
+}
+
+// RateLimitQueueSnapshot reports the requests held back because their model
+// was rate limited upstream.
+type RateLimitQueueSnapshot struct {
+	// Throttles counts upstream 429 responses that held a model back.
+	Throttles int64 `json:"throttles"`
+	// Queued counts requests that had to wait.
+	Queued int64 `json:"queued"`
+	// TimedOut counts queued requests that failed after waiting too long.
+	TimedOut int64 `json:"timed_out"`
+	// Depth is the number of requests waiting now, per model.
+	Depth map[string]int `json:"depth"`
+}
+
+var rateLimitQueue struct {
+	throttles atomic.Int64
+	queued    atomic.Int64
+	timedOut  atomic.Int64
+	mu        sync.Mutex
+	depth     map[string]int
+}
+
+// RecordRateLimitThrottle counts a 429 that held a model back.
+func RecordRateLimitThrottle() {
+	rateLimitQueue.throttles.Add(1)
+}
+
+// RecordRateLimitQueued counts a request that waited for a rate-limited
+// model. timedOut reports whether it gave up.
+func RecordRateLimitQueued(timedOut bool) {
+	rateLimitQueue.queued.Add(1)
+	if timedOut {
+		rateLimitQueue.timedOut.Add(1)
+	}
+}
+
+// SetRateLimitQueueDepth records the number of requests waiting for model.
+func SetRateLimitQueueDepth(model string, depth int) {
+	rateLimitQueue.mu.Lock()
+	defer rateLimitQueue.mu.Unlock()
+	if depth <= 0 {
+		delete(rateLimitQueue.depth, model)
+		return
+	}
+	if rateLimitQueue.depth == nil {
+		rateLimitQueue.depth = make(map[string]int)
+	}
+	rateLimitQueue.depth[model] = depth
+}
+
+// RateLimitQueue returns the current rate limit queue counters.
+func RateLimitQueue() RateLimitQueueSnapshot {
+	rateLimitQueue.mu.Lock()
+	depth := make(map[string]int, len(rateLimitQueue.depth))
+	for model, n := range rateLimitQueue.depth {
+		depth[model] = n
+	}
+	rateLimitQueue.mu.Unlock()
+	return RateLimitQueueSnapshot{
+		Throttles: rateLimitQueue.throttles.Load(),
+		Queued:    rateLimitQueue.queued.Load(),
+		TimedOut:  rateLimitQueue.timedOut.Load(),
+		Depth:     depth,
+	}
+}
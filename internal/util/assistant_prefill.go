@@ -0,0 +1,86 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ClaudeAssistantPrefill returns the text of a trailing assistant message in a
+// Claude Messages request. Only messages made of text (a string or text
+// blocks) count as prefill; a trailing tool_use turn does not.
+func ClaudeAssistantPrefill(payload []byte) (string, bool) {
+	messages := gjson.GetBytes(payload, "messages").Array()
+	if len(messages) == 0 {
+		return "", false
+	}
+	last := messages[len(messages)-1]
+	if last.Get("role").String() != "assistant" {
+		return "", false
+	}
+	content := last.Get("content")
+	if content.Type == gjson.String {
+		return content.String(), content.String() != ""
+	}
+	var text strings.Builder
+	for _, block := range content.Array() {
+		if block.Get("type").String() != "text" {
+			return "", false
+		}
+		text.WriteString(block.Get("text").String())
+	}
+	return text.String(), text.Len() > 0
+}
+
+// PrependClaudeText adds text to the start of the first text block of a
+// Claude Messages response, inserting a text block when there is none.
+func PrependClaudeText(response []byte, text string) []byte {
+	for i, block := range gjson.GetBytes(response, "content").Array() {
+		if block.Get("type").String() == "text" {
+			out, err := sjson.SetBytes(response, fmt.Sprintf("content.%d.text", i), text+block.Get("text").String())
+			if err != nil {
+				return response
+			}
+			return out
+		}
+	}
+	content := gjson.GetBytes(response, "content").Array()
+	raw := make([]string, 0, len(content)+1)
+	block, _ := sjson.Set(`{"type":"text","text":""}`, "text", text)
+	raw = append(raw, block)
+	for _, existing := range content {
+		raw = append(raw, existing.Raw)
+	}
+	out, err := sjson.SetRawBytes(response, "content", []byte("["+strings.Join(raw, ",")+"]"))
+	if err != nil {
+		return response
+	}
+	return out
+}
+
+// PrependClaudeStreamText adds text to the first text_delta event found in a
+// Claude SSE chunk. It reports whether the chunk contained one.
+func PrependClaudeStreamText(chunk []byte, text string) ([]byte, bool) {
+	lines := bytes.Split(chunk, []byte("\n"))
+	for i, line := range lines {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		event := gjson.ParseBytes(data)
+		if event.Get("type").String() != "content_block_delta" || event.Get("delta.type").String() != "text_delta" {
+			continue
+		}
+		updated, err := sjson.SetBytes(data, "delta.text", text+event.Get("delta.text").String())
+		if err != nil {
+			return chunk, false
+		}
+		lines[i] = append([]byte("data: "), updated...)
+		return bytes.Join(lines, []byte("\n")), true
+	}
+	return chunk, false
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestClaudeAssistantPrefill(t *testing.T) {
+	prefill, ok := ClaudeAssistantPrefill([]byte(`{"messages":[{"role":"user","content":"Name a color."},{"role":"assistant","content":[{"type":"text","text":"The color is"}]}]}`))
+	if !ok || prefill != "The color is" {
+		t.Fatalf("prefill = %q, %v", prefill, ok)
+	}
+	if _, ok = ClaudeAssistantPrefill([]byte(`{"messages":[{"role":"assistant","content":[{"type":"tool_use","id":"t","name":"x","input":{}}]}]}`)); ok {
+		t.Fatalf("tool_use turn treated as prefill")
+	}
+	if _, ok = ClaudeAssistantPrefill([]byte(`{"messages":[{"role":"user","content":"hi"}]}`)); ok {
+		t.Fatalf("user turn treated as prefill")
+	}
+}
+
+func TestPrependClaudeText(t *testing.T) {
+	out := PrependClaudeText([]byte(`{"content":[{"type":"thinking","thinking":"t"},{"type":"text","text":" blue."}]}`), "The color is")
+	if gjson.GetBytes(out, "content.1.text").String() != "The color is blue." {
+		t.Fatalf("response = %s", out)
+	}
+	out = PrependClaudeText([]byte(`{"content":[]}`), "The color is")
+	if gjson.GetBytes(out, "content.0.text").String() != "The color is" {
+		t.Fatalf("empty response = %s", out)
+	}
+
+	chunk := []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" blue\"}}\n\n")
+	out, ok := PrependClaudeStreamText(chunk, "The color is")
+	if !ok || gjson.Get(string(out[len("event: content_block_delta\ndata: "):]), "delta.text").String() != "The color is blue" {
+		t.Fatalf("stream chunk = %q", out)
+	}
+	if _, ok = PrependClaudeStreamText([]byte("event: ping\ndata: {\"type\":\"ping\"}\n\n"), "x"); ok {
+		t.Fatalf("ping event modified")
+	}
+}
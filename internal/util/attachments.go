@@ -0,0 +1,166 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Attachment kinds and the policies that can be applied to them.
+const (
+	AttachmentDocument = "document"
+	AttachmentAudio    = "audio"
+
+	// AttachmentPass forwards the block unchanged.
+	AttachmentPass = "pass"
+	// AttachmentText replaces the block with its extracted text, falling back
+	// to a marker when no text can be extracted.
+	AttachmentText = "text"
+	// AttachmentMarker replaces the block with an "[unsupported attachment: ...]" note.
+	AttachmentMarker = "marker"
+)
+
+// attachment describes a document or audio content block.
+type attachment struct {
+	kind      string
+	name      string
+	mediaType string
+	// text is the readable content, when the block carries or encodes text.
+	text string
+}
+
+func (a attachment) label() string {
+	label := a.kind
+	if a.name != "" {
+		label += " " + a.name
+	}
+	if a.mediaType != "" {
+		label += " (" + a.mediaType + ")"
+	}
+	return label
+}
+
+// RewriteAttachments applies policy to the document and audio blocks of a
+// request in the given schema (claude, openai or openai-response). policy
+// returns the policy for an attachment kind. It returns the rewritten payload
+// and the number of blocks replaced, by kind.
+func RewriteAttachments(format string, payload []byte, policy func(kind string) string) ([]byte, map[string]int) {
+	var listPath, textType string
+	switch format {
+	case "claude":
+		listPath, textType = "messages", "text"
+	case "openai":
+		listPath, textType = "messages", "text"
+	case "openai-response":
+		listPath, textType = "input", "input_text"
+	default:
+		return payload, nil
+	}
+	out := payload
+	var replaced map[string]int
+	gjson.GetBytes(payload, listPath).ForEach(func(i, msg gjson.Result) bool {
+		msg.Get("content").ForEach(func(j, block gjson.Result) bool {
+			att, ok := parseAttachment(format, block)
+			if !ok {
+				return true
+			}
+			var text string
+			switch policy(att.kind) {
+			case AttachmentText:
+				if strings.TrimSpace(att.text) != "" {
+					text = fmt.Sprintf("[attachment: %s]\n%s", att.label(), strings.TrimSpace(att.text))
+					break
+				}
+				fallthrough
+			case AttachmentMarker:
+				text = fmt.Sprintf("[unsupported attachment: %s]", att.label())
+			default:
+				return true
+			}
+			path := listPath + "." + i.String() + ".content." + j.String()
+			if updated, err := sjson.SetBytes(out, path, map[string]string{"type": textType, "text": text}); err == nil {
+				out = updated
+				if replaced == nil {
+					replaced = make(map[string]int)
+				}
+				replaced[att.kind]++
+			}
+			return true
+		})
+		return true
+	})
+	return out, replaced
+}
+
+// parseAttachment recognises document and audio blocks of a schema.
+func parseAttachment(format string, block gjson.Result) (attachment, bool) {
+	switch blockType := block.Get("type").String(); {
+	case format == "claude" && blockType == "document":
+		att := attachment{kind: AttachmentDocument, name: block.Get("title").String(), mediaType: block.Get("source.media_type").String()}
+		source := block.Get("source")
+		switch source.Get("type").String() {
+		case "text":
+			att.text = source.Get("data").String()
+		case "content":
+			var parts []string
+			source.Get("content").ForEach(func(_, part gjson.Result) bool {
+				if part.Get("type").String() == "text" {
+					parts = append(parts, part.Get("text").String())
+				}
+				return true
+			})
+			att.text = strings.Join(parts, "\n")
+		case "base64":
+			att.text = decodedText(att.mediaType, source.Get("data").String())
+		case "url":
+			if att.name == "" {
+				att.name = source.Get("url").String()
+			}
+		}
+		return att, true
+	case format == "openai" && blockType == "file":
+		att := attachment{kind: AttachmentDocument, name: block.Get("file.filename").String()}
+		att.mediaType, att.text = dataURLText(block.Get("file.file_data").String())
+		return att, true
+	case format == "openai-response" && blockType == "input_file":
+		att := attachment{kind: AttachmentDocument, name: block.Get("filename").String()}
+		att.mediaType, att.text = dataURLText(block.Get("file_data").String())
+		return att, true
+	case blockType == "input_audio" || (format == "claude" && blockType == "audio"):
+		att := attachment{kind: AttachmentAudio}
+		if audioFormat := block.Get("input_audio.format").String(); audioFormat != "" {
+			att.mediaType = "audio/" + audioFormat
+		} else {
+			att.mediaType = block.Get("source.media_type").String()
+		}
+		return att, true
+	}
+	return attachment{}, false
+}
+
+// dataURLText returns the media type and text of a base64 data URL.
+func dataURLText(url string) (string, string) {
+	mediaType, data, ok := splitDataURL(url)
+	if !ok {
+		return "", ""
+	}
+	return mediaType, decodedText(mediaType, data)
+}
+
+// decodedText decodes base64 data of a textual or PDF media type.
+func decodedText(mediaType, data string) string {
+	if !strings.HasPrefix(mediaType, "text/") && mediaType != "application/pdf" && mediaType != "application/json" {
+		return ""
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return ""
+	}
+	if mediaType == "application/pdf" {
+		return PDFText(raw)
+	}
+	return string(raw)
+}
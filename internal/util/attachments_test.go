@@ -0,0 +1,61 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func testPDF(t *testing.T, content string) []byte {
+	t.Helper()
+	var stream bytes.Buffer
+	w := zlib.NewWriter(&stream)
+	_, _ = w.Write([]byte(content))
+	_ = w.Close()
+	return []byte(fmt.Sprintf("%%PDF-1.4\n4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n%%%%EOF", stream.Len(), stream.Bytes()))
+}
+
+func TestPDFText(t *testing.T) {
+	pdf := testPDF(t, "BT /F1 12 Tf 72 712 Td (Quarterly \\(Q3\\) report) Tj 0 -14 Td [(Rev) -20 (enue: 42)] TJ ET")
+	if got := PDFText(pdf); got != "Quarterly (Q3) report\nRevenue: 42" {
+		t.Fatalf("PDFText = %q", got)
+	}
+}
+
+func TestRewriteAttachments(t *testing.T) {
+	pdf := base64.StdEncoding.EncodeToString(testPDF(t, "BT (Hello PDF) Tj ET"))
+	payload := []byte(`{"messages":[{"role":"user","content":[` +
+		`{"type":"document","title":"a.pdf","source":{"type":"base64","media_type":"application/pdf","data":"` + pdf + `"}},` +
+		`{"type":"document","source":{"type":"url","url":"https://example.com/x.pdf"}},` +
+		`{"type":"audio","source":{"type":"base64","media_type":"audio/wav","data":"AAAA"}},` +
+		`{"type":"text","text":"summarize"}]}]}`)
+	out, replaced := RewriteAttachments("claude", payload, func(kind string) string {
+		if kind == AttachmentAudio {
+			return AttachmentPass
+		}
+		return AttachmentText
+	})
+	if replaced[AttachmentDocument] != 2 || replaced[AttachmentAudio] != 0 {
+		t.Fatalf("replaced = %v", replaced)
+	}
+	content := gjson.GetBytes(out, "messages.0.content")
+	if got := content.Get("0.text").String(); got != "[attachment: document a.pdf (application/pdf)]\nHello PDF" {
+		t.Fatalf("pdf block = %q", got)
+	}
+	if got := content.Get("1.text").String(); got != "[unsupported attachment: document https://example.com/x.pdf]" {
+		t.Fatalf("url block = %q", got)
+	}
+	if content.Get("2.type").String() != "audio" || content.Get("3.text").String() != "summarize" {
+		t.Fatalf("content = %s", content.Raw)
+	}
+
+	openai := []byte(`{"messages":[{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"AAAA","format":"mp3"}}]}]}`)
+	out, _ = RewriteAttachments("openai", openai, func(string) string { return AttachmentMarker })
+	if got := gjson.GetBytes(out, "messages.0.content.0.text").String(); got != "[unsupported attachment: audio (audio/mp3)]" {
+		t.Fatalf("openai audio = %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Built-in tool handling levels.
+const (
+	// BuiltinToolsPass forwards built-in tools as sent.
+	BuiltinToolsPass = "pass"
+	// BuiltinToolsConvert declares built-in tools as function tools.
+	BuiltinToolsConvert = "convert"
+)
+
+// builtinToolDeclaration is the function tool a built-in tool is declared as.
+type builtinToolDeclaration struct {
+	description string
+	parameters  string
+}
+
+// builtinToolDeclarations lists the OpenAI built-in tool types that upstreams
+// other than OpenAI do not run, with the function they are declared as. The
+// model's calls of such a function reach the client as ordinary function
+// calls, which the client has to run.
+var builtinToolDeclarations = map[string]builtinToolDeclaration{
+	"code_interpreter": {
+		description: "Run Python code in a sandboxed interpreter and return its standard output, errors and the names of any files it writes. State is kept between calls in the same conversation.",
+		parameters:  `{"type":"object","properties":{"code":{"type":"string","description":"The Python code to execute."}},"required":["code"]}`,
+	},
+	"file_search": {
+		description: "Search the files attached to the conversation and return the most relevant passages together with the names of the files they come from.",
+		parameters:  `{"type":"object","properties":{"query":{"type":"string","description":"What to search for."},"max_num_results":{"type":"integer","description":"The maximum number of passages to return."}},"required":["query"]}`,
+	},
+}
+
+// IsBuiltinToolType reports whether typ is an OpenAI built-in tool type that
+// ConvertBuiltinTools declares as a function.
+func IsBuiltinToolType(typ string) bool {
+	_, ok := builtinToolDeclarations[typ]
+	return ok
+}
+
+// ConvertBuiltinTools replaces the code_interpreter and file_search tools of
+// an openai or openai-response request with function tools of the same name,
+// and a tool_choice forcing one with a choice of that function. It returns
+// the request and the converted tool types; other formats are returned
+// unchanged.
+func ConvertBuiltinTools(format string, payload []byte) ([]byte, []string) {
+	if format != "openai" && format != "openai-response" {
+		return payload, nil
+	}
+	var converted []string
+	out := payload
+	gjson.GetBytes(payload, "tools").ForEach(func(i, tool gjson.Result) bool {
+		typ := tool.Get("type").String()
+		declaration, ok := builtinToolDeclarations[typ]
+		if !ok {
+			return true
+		}
+		fn := `{"type":"function"}`
+		base := ""
+		if format == "openai" {
+			base = "function."
+		}
+		fn, _ = sjson.Set(fn, base+"name", typ)
+		fn, _ = sjson.Set(fn, base+"description", declaration.description)
+		fn, _ = sjson.SetRaw(fn, base+"parameters", declaration.parameters)
+		if updated, err := sjson.SetRawBytes(out, fmt.Sprintf("tools.%d", i.Int()), []byte(fn)); err == nil {
+			out = updated
+			if !slices.Contains(converted, typ) {
+				converted = append(converted, typ)
+			}
+		}
+		return true
+	})
+	if typ := gjson.GetBytes(out, "tool_choice.type").String(); slices.Contains(converted, typ) {
+		choice := `{"type":"function"}`
+		if format == "openai" {
+			choice, _ = sjson.Set(choice, "function.name", typ)
+		} else {
+			choice, _ = sjson.Set(choice, "name", typ)
+		}
+		out, _ = sjson.SetRawBytes(out, "tool_choice", []byte(choice))
+	}
+	return out, converted
+}
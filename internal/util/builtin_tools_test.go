@@ -0,0 +1,50 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertBuiltinTools(t *testing.T) {
+	payload := []byte(`{"tools":[{"type":"code_interpreter","container":{"type":"auto"}},{"type":"function","function":{"name":"read"}},{"type":"file_search"}],"tool_choice":{"type":"code_interpreter"}}`)
+
+	out, converted := ConvertBuiltinTools("openai", payload)
+	if len(converted) != 2 || converted[0] != "code_interpreter" || converted[1] != "file_search" {
+		t.Fatalf("converted = %v", converted)
+	}
+	if got := gjson.GetBytes(out, "tools.#.function.name").Raw; got != `["code_interpreter","read","file_search"]` {
+		t.Fatalf("tool names = %s", got)
+	}
+	if gjson.GetBytes(out, "tools.0.container").Exists() || gjson.GetBytes(out, "tools.0.function.parameters.required.0").String() != "code" {
+		t.Fatalf("code_interpreter declaration = %s", gjson.GetBytes(out, "tools.0").Raw)
+	}
+	if got := gjson.GetBytes(out, "tool_choice").Raw; got != `{"type":"function","function":{"name":"code_interpreter"}}` {
+		t.Fatalf("tool_choice = %s", got)
+	}
+
+	out, _ = ConvertBuiltinTools("openai-response", []byte(`{"tools":[{"type":"file_search","vector_store_ids":["vs_1"]}]}`))
+	if gjson.GetBytes(out, "tools.0.name").String() != "file_search" || gjson.GetBytes(out, "tools.0.parameters.required.0").String() != "query" {
+		t.Fatalf("responses declaration = %s", out)
+	}
+
+	if _, converted = ConvertBuiltinTools("claude", payload); converted != nil {
+		t.Fatalf("claude request converted %v", converted)
+	}
+}
+
+func TestAuditTranslationReportsDroppedBuiltinTools(t *testing.T) {
+	in := []byte(`{"tools":[{"type":"code_interpreter"}]}`)
+	losses := AuditTranslation("openai", "claude", in, []byte(`{}`))
+	if len(losses) != 1 || losses[0].Field != "tools.code_interpreter" || losses[0].Change != "dropped" {
+		t.Fatalf("losses = %+v", losses)
+	}
+	if losses = AuditTranslation("openai", "codex", in, []byte(`{"tools":[{"type":"code_interpreter"}]}`)); len(losses) != 0 {
+		t.Fatalf("pass-through reported %+v", losses)
+	}
+
+	converted, _ := ConvertBuiltinTools("openai", in)
+	if losses = AuditTranslation("openai", "claude", converted, []byte(`{"tools":[{"name":"code_interpreter","description":"`+builtinToolDeclarations["code_interpreter"].description+`"}]}`)); len(losses) != 0 {
+		t.Fatalf("converted tool reported %+v", losses)
+	}
+}
@@ -0,0 +1,142 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ContextFilesField is the request field (top level or inside "metadata") in
+// which clients attach project files such as CLAUDE.md.
+const ContextFilesField = "context_files"
+
+// ContextFile is one attached file as reported in execution metadata.
+type ContextFile struct {
+	Path      string `json:"path"`
+	Bytes     int    `json:"bytes"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// TakeContextFiles removes the files listed in the request's context_files
+// field (or metadata.context_files) and renders them as <context_file> blocks
+// for the system prompt. Each entry is {"path": "...", "content": "..."};
+// entries without content are ignored. File contents are limited to maxBytes
+// in total (no limit when maxBytes <= 0), truncating the file that crosses the
+// limit and dropping the rest. The field is always removed because upstreams
+// reject unknown request fields.
+func TakeContextFiles(payload []byte, maxBytes int) ([]byte, string, []ContextFile) {
+	root := gjson.ParseBytes(payload)
+	var entries []gjson.Result
+	var fields []string
+	for _, path := range []string{ContextFilesField, "metadata." + ContextFilesField} {
+		if value := root.Get(path); value.Exists() {
+			fields = append(fields, path)
+			entries = append(entries, value.Array()...)
+		}
+	}
+	if len(fields) == 0 {
+		return payload, "", nil
+	}
+	out := payload
+	for _, path := range fields {
+		if updated, err := sjson.DeleteBytes(out, path); err == nil {
+			out = updated
+		}
+	}
+
+	var block strings.Builder
+	var files []ContextFile
+	remaining := maxBytes
+	for _, entry := range entries {
+		content := entry.Get("content").String()
+		if content == "" || (maxBytes > 0 && remaining <= 0) {
+			continue
+		}
+		path := strings.TrimSpace(entry.Get("path").String())
+		if path == "" {
+			path = fmt.Sprintf("file-%d", len(files)+1)
+		}
+		file := ContextFile{Path: path, Bytes: len(content)}
+		if maxBytes > 0 && len(content) > remaining {
+			content = truncateUTF8(content, remaining) + fmt.Sprintf("\n[... %d bytes truncated]", len(content)-remaining)
+			file.Truncated = true
+		}
+		remaining -= file.Bytes
+		fmt.Fprintf(&block, "<context_file path=%q>\n%s\n</context_file>\n", path, strings.TrimRight(content, "\n"))
+		files = append(files, file)
+	}
+	return out, strings.TrimRight(block.String(), "\n"), files
+}
+
+// AppendSystemText adds text to the system prompt of a request in the given
+// schema (claude, openai, openai-response, gemini or gemini-cli). It reports
+// false for other schemas.
+func AppendSystemText(format string, payload []byte, text string) ([]byte, bool) {
+	var (
+		out []byte
+		err error
+	)
+	switch format {
+	case "claude":
+		system := gjson.GetBytes(payload, "system")
+		switch {
+		case system.IsArray():
+			out, err = sjson.SetBytes(payload, "system.-1", map[string]any{"type": "text", "text": text})
+		case system.Type == gjson.String && system.String() != "":
+			out, err = sjson.SetBytes(payload, "system", system.String()+"\n\n"+text)
+		default:
+			out, err = sjson.SetBytes(payload, "system", text)
+		}
+	case "openai":
+		// Insert after any leading system messages so client instructions stay first.
+		messages := gjson.GetBytes(payload, "messages").Array()
+		insertAt := 0
+		for insertAt < len(messages) && messages[insertAt].Get("role").String() == "system" {
+			insertAt++
+		}
+		raw := make([]string, 0, len(messages)+1)
+		for i, msg := range messages {
+			if i == insertAt {
+				raw = append(raw, "")
+			}
+			raw = append(raw, msg.Raw)
+		}
+		if insertAt == len(messages) {
+			raw = append(raw, "")
+		}
+		message, _ := sjson.Set(`{"role":"system"}`, "content", text)
+		raw[insertAt] = message
+		out, err = sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(raw, ",")+"]"))
+	case "openai-response":
+		instructions := gjson.GetBytes(payload, "instructions").String()
+		if instructions != "" {
+			text = instructions + "\n\n" + text
+		}
+		out, err = sjson.SetBytes(payload, "instructions", text)
+	case "gemini", "gemini-cli":
+		base := "systemInstruction"
+		if format == "gemini-cli" {
+			base = "request.systemInstruction"
+		}
+		out, err = sjson.SetBytes(payload, base+".parts.-1", map[string]any{"text": text})
+	default:
+		return payload, false
+	}
+	if err != nil {
+		return payload, false
+	}
+	return out, true
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncateUTF8(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
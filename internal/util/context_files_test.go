@@ -0,0 +1,46 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestTakeContextFiles(t *testing.T) {
+	payload := []byte(`{"system":"Be brief.","metadata":{"user_id":"u","context_files":[{"path":"CLAUDE.md","content":"Use tabs."},{"path":"docs/big.md","content":"0123456789abcdef"},{"path":"skipped.md","content":"x"}]},"messages":[]}`)
+	out, block, files := TakeContextFiles(payload, 20)
+	if len(files) != 2 || files[0].Path != "CLAUDE.md" || files[1].Truncated != true {
+		t.Fatalf("files = %+v", files)
+	}
+	if gjson.GetBytes(out, "metadata.context_files").Exists() || gjson.GetBytes(out, "metadata.user_id").String() != "u" {
+		t.Fatalf("metadata not cleaned: %s", out)
+	}
+	if !strings.HasPrefix(block, "<context_file path=\"CLAUDE.md\">\nUse tabs.\n</context_file>") ||
+		!strings.Contains(block, "0123456789a\n[... 5 bytes truncated]") || strings.Contains(block, "skipped.md") {
+		t.Fatalf("block = %q", block)
+	}
+
+	out, _, files = TakeContextFiles([]byte(`{"context_files":[{"path":"a.go","content":"package a"}]}`), 0)
+	if len(files) != 1 || gjson.GetBytes(out, "context_files").Exists() {
+		t.Fatalf("top-level field: files=%+v out=%s", files, out)
+	}
+}
+
+func TestAppendSystemText(t *testing.T) {
+	out, ok := AppendSystemText("claude", []byte(`{"system":"Be brief."}`), "extra")
+	if !ok || gjson.GetBytes(out, "system").String() != "Be brief.\n\nextra" {
+		t.Fatalf("claude = %s", out)
+	}
+
+	payload := []byte(`{"messages":[{"role":"system","content":"sys"},{"role":"user","content":"hi"}]}`)
+	out, ok = AppendSystemText("openai", payload, "extra")
+	messages := gjson.GetBytes(out, "messages").Array()
+	if !ok || len(messages) != 3 || messages[1].Get("role").String() != "system" || messages[1].Get("content").String() != "extra" {
+		t.Fatalf("openai = %s", out)
+	}
+
+	if _, ok = AppendSystemText("unknown", payload, "extra"); ok {
+		t.Fatalf("unknown schema reported success")
+	}
+}
@@ -0,0 +1,161 @@
+package util
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// FaultHeader forces a fault on the upstream requests of a client request when
+// fault injection allows it.
+const FaultHeader = "X-CLIProxy-Fault"
+
+// Injected faults.
+const (
+	FaultRateLimit    = "rate-limit"
+	FaultTimeout      = "timeout"
+	FaultMalformedSSE = "malformed-sse"
+	FaultTruncate     = "truncate"
+)
+
+const defaultFaultTimeout = 30 * time.Second
+
+// faultRandom returns a number in [0, 100); tests replace it.
+var faultRandom = func() float64 { return rand.Float64() * 100 }
+
+// faultTransport fails a share of upstream requests on purpose.
+type faultTransport struct {
+	base http.RoundTripper
+	cfg  config.FaultInjectionConfig
+}
+
+// faultTimeoutError is returned for injected timeouts. It reports itself as a
+// net.Error timeout, like a real one.
+type faultTimeoutError struct{}
+
+func (faultTimeoutError) Error() string   { return "injected fault: upstream timeout" }
+func (faultTimeoutError) Timeout() bool   { return true }
+func (faultTimeoutError) Temporary() bool { return true }
+
+func (t faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault := t.pick(req)
+	if fault == "" {
+		return t.base.RoundTrip(req)
+	}
+	log.Warnf("fault injection: %s on %s %s", fault, req.Method, req.URL.Host)
+	switch fault {
+	case FaultRateLimit:
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		body := `{"error":{"message":"injected fault: rate limited","type":"rate_limit_error"}}`
+		return &http.Response{
+			Status:        "429 Too Many Requests",
+			StatusCode:    http.StatusTooManyRequests,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}, "Retry-After": {"1"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	case FaultTimeout:
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		wait := defaultFaultTimeout
+		if t.cfg.TimeoutSeconds > 0 {
+			wait = time.Duration(t.cfg.TimeoutSeconds) * time.Second
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+			return nil, faultTimeoutError{}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp, err
+	}
+	switch fault {
+	case FaultMalformedSSE:
+		if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(strings.NewReader("data: {\"injected\":\n\n"), resp.Body), resp.Body}
+		}
+	case FaultTruncate:
+		resp.Body = &truncatedBody{ReadCloser: resp.Body}
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+	}
+	return resp, nil
+}
+
+// pick returns the fault to inject into req, if any.
+func (t faultTransport) pick(req *http.Request) string {
+	if t.cfg.AllowHeader {
+		if ginCtx, ok := req.Context().Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			switch fault := strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(FaultHeader))); fault {
+			case FaultRateLimit, FaultTimeout, FaultMalformedSSE, FaultTruncate:
+				return fault
+			}
+		}
+	}
+	roll := faultRandom()
+	for _, fault := range []struct {
+		name    string
+		percent float64
+	}{
+		{FaultRateLimit, t.cfg.RateLimitPercent},
+		{FaultTimeout, t.cfg.TimeoutPercent},
+		{FaultMalformedSSE, t.cfg.MalformedSSEPercent},
+		{FaultTruncate, t.cfg.TruncatePercent},
+	} {
+		if fault.percent <= 0 {
+			continue
+		}
+		if roll < fault.percent {
+			return fault.name
+		}
+		roll -= fault.percent
+	}
+	return ""
+}
+
+// truncatedBody returns half of the first read and then fails as if the
+// connection had dropped.
+type truncatedBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.read {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b.read = true
+	n, err := b.ReadCloser.Read(p)
+	if n > 1 {
+		n /= 2
+	}
+	if n > 0 && (err == nil || err == io.EOF) {
+		return n, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestFaultTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"ok\":true}\n\n")
+	}))
+	defer upstream.Close()
+
+	previous := faultRandom
+	defer func() { faultRandom = previous }()
+	rt := faultTransport{base: http.DefaultTransport, cfg: config.FaultInjectionConfig{
+		Enabled: true, AllowHeader: true, RateLimitPercent: 10, MalformedSSEPercent: 10, TimeoutSeconds: 1,
+	}}
+	get := func(ctx context.Context) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+		return rt.RoundTrip(req)
+	}
+
+	faultRandom = func() float64 { return 5 }
+	resp, err := get(context.Background())
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected injected 429, got %v (%v)", resp, err)
+	}
+
+	faultRandom = func() float64 { return 15 }
+	resp, err = get(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.HasPrefix(string(body), "data: {\"injected\":\n\n") || !strings.Contains(string(body), `{"ok":true}`) {
+		t.Fatalf("malformed event not prepended: %q", body)
+	}
+
+	faultRandom = func() float64 { return 50 }
+	if resp, err = get(context.Background()); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request outside the fault rates failed: %v (%v)", resp, err)
+	}
+
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Request.Header.Set(FaultHeader, "truncate")
+	resp, err = get(context.WithValue(context.Background(), "gin", ginCtx))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = io.ReadAll(resp.Body); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("body not truncated: %v", err)
+	}
+
+	ginCtx.Request.Header.Set(FaultHeader, "timeout")
+	_, err = get(context.WithValue(context.Background(), "gin", ginCtx))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected injected timeout, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// TrimHistoryTurns keeps the last maxTurns user turns of a conversation in the
+// given schema, with everything that follows them. A turn starts at a user
+// message that is not a tool result, so tool calls stay paired with their
+// results. OpenAI system and developer messages are always kept. It returns
+// the rewritten payload and the number of messages removed.
+func TrimHistoryTurns(format string, payload []byte, maxTurns int) ([]byte, int) {
+	if maxTurns <= 0 {
+		return payload, 0
+	}
+	listPath, list := ConversationItems(format, payload)
+	if listPath == "" {
+		return payload, 0
+	}
+	starts := userTurnStarts(format, list)
+	if len(starts) <= maxTurns {
+		return payload, 0
+	}
+	cut := starts[len(starts)-maxTurns]
+	kept := make([]string, 0, len(list)-cut)
+	for i, item := range list {
+		if i >= cut || (format == "openai" && isInstructionMessage(item)) {
+			kept = append(kept, item.Raw)
+		}
+	}
+	out, err := sjson.SetRawBytes(payload, listPath, []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return payload, 0
+	}
+	return out, len(list) - len(kept)
+}
+
+// ConversationItems returns the path of the message list of a request in the
+// given schema and its items. The path is empty for unsupported schemas or
+// when the request has no message list.
+func ConversationItems(format string, payload []byte) (string, []gjson.Result) {
+	listPath := "messages"
+	switch format {
+	case "openai-response":
+		listPath = "input"
+	case "gemini":
+		listPath = "contents"
+	case "gemini-cli":
+		listPath = "request.contents"
+	case "claude", "openai":
+	default:
+		return "", nil
+	}
+	items := gjson.GetBytes(payload, listPath)
+	if !items.IsArray() {
+		return "", nil
+	}
+	return listPath, items.Array()
+}
+
+// CountUserTurns returns the number of user turns in a request, as counted by
+// TrimHistoryTurns.
+func CountUserTurns(format string, payload []byte) int {
+	_, list := ConversationItems(format, payload)
+	return len(userTurnStarts(format, list))
+}
+
+func userTurnStarts(format string, list []gjson.Result) []int {
+	var starts []int
+	for i, item := range list {
+		if startsUserTurn(format, item) {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+func isInstructionMessage(item gjson.Result) bool {
+	role := item.Get("role").String()
+	return role == "system" || role == "developer"
+}
+
+// startsUserTurn reports whether item is a user message carrying new input
+// rather than tool results.
+func startsUserTurn(format string, item gjson.Result) bool {
+	if item.Get("role").String() != "user" {
+		return false
+	}
+	switch format {
+	case "claude":
+		for _, block := range item.Get("content").Array() {
+			if block.Get("type").String() == "tool_result" {
+				return false
+			}
+		}
+	case "openai-response":
+		if t := item.Get("type").String(); t != "" && t != "message" {
+			return false
+		}
+	case "gemini", "gemini-cli":
+		for _, part := range item.Get("parts").Array() {
+			if part.Get("functionResponse").Exists() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// LimitTools keeps at most maxTools tool declarations of a request in the
+// given schema. A tool the request forces through tool_choice is kept even
+// beyond the limit. It returns the rewritten payload and the names of the
+// dropped tools.
+func LimitTools(format string, payload []byte, maxTools int) ([]byte, []string) {
+	if maxTools <= 0 {
+		return payload, nil
+	}
+	tools := gjson.GetBytes(payload, "tools")
+	if !tools.IsArray() || len(tools.Array()) <= maxTools {
+		return payload, nil
+	}
+	var forced string
+	switch format {
+	case "claude":
+		forced = gjson.GetBytes(payload, "tool_choice.name").String()
+	case "openai":
+		forced = gjson.GetBytes(payload, "tool_choice.function.name").String()
+	case "openai-response":
+		forced = gjson.GetBytes(payload, "tool_choice.name").String()
+	default:
+		return payload, nil
+	}
+	var kept []string
+	var dropped []string
+	for _, tool := range tools.Array() {
+		name := tool.Get("name").String()
+		if format == "openai" {
+			name = tool.Get("function.name").String()
+		}
+		if len(kept) < maxTools || (forced != "" && name == forced) {
+			kept = append(kept, tool.Raw)
+			continue
+		}
+		dropped = append(dropped, name)
+	}
+	out, err := sjson.SetRawBytes(payload, "tools", []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return payload, nil
+	}
+	return out, dropped
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestTrimHistoryTurnsKeepsToolPairs(t *testing.T) {
+	payload := []byte(`{"messages":[
+		{"role":"user","content":"one"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"ls","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"a"}]},
+		{"role":"assistant","content":"done"},
+		{"role":"user","content":"two"},
+		{"role":"assistant","content":"ok"},
+		{"role":"user","content":"three"}]}`)
+	out, removed := TrimHistoryTurns("claude", payload, 2)
+	if removed != 4 {
+		t.Fatalf("removed = %d", removed)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content").String(); got != "two" {
+		t.Fatalf("first kept message = %q", got)
+	}
+
+	// The tool result does not start a turn, so the whole tool exchange
+	// belongs to the first turn.
+	out, removed = TrimHistoryTurns("claude", payload, 3)
+	if removed != 0 {
+		t.Fatalf("three turns removed %d message(s): %s", removed, out)
+	}
+
+	openai := []byte(`{"messages":[{"role":"system","content":"s"},{"role":"user","content":"one"},{"role":"assistant","content":"a"},{"role":"user","content":"two"}]}`)
+	out, removed = TrimHistoryTurns("openai", openai, 1)
+	if removed != 2 || gjson.GetBytes(out, "messages.0.role").String() != "system" || gjson.GetBytes(out, "messages.1.content").String() != "two" {
+		t.Fatalf("openai trim: removed=%d %s", removed, out)
+	}
+}
+
+func TestLimitToolsKeepsForcedTool(t *testing.T) {
+	payload := []byte(`{"tool_choice":{"type":"tool","name":"d"},"tools":[{"name":"a"},{"name":"b"},{"name":"c"},{"name":"d"}]}`)
+	out, dropped := LimitTools("claude", payload, 2)
+	if len(dropped) != 1 || dropped[0] != "c" {
+		t.Fatalf("dropped = %v", dropped)
+	}
+	if names := gjson.GetBytes(out, "tools.#.name").String(); names != `["a","b","d"]` {
+		t.Fatalf("tools = %s", names)
+	}
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// idSource is a seeded generator carried on the context of a deterministic
+// translation.
+type idSource struct {
+	seed    []byte
+	counter atomic.Uint64
+	now     func() time.Time
+}
+
+func (s *idSource) next(label string) []byte {
+	n := s.counter.Add(1)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	sum := sha256.Sum256(append(append(append([]byte{}, s.seed...), label...), buf[:]...))
+	return sum[:]
+}
+
+type idSourceKey struct{}
+
+var (
+	processUUIDs     sync.Map
+	deterministicNS  = uuid.MustParse("6ba7b812-9dad-11d1-80b4-00c04fd430c8")
+	defaultFixedTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// WithDeterministicIDs returns a context under which identifier generation
+// and the clock are replaced by a source derived from seed, so the same input
+// translates to byte-identical output. A nil now pins the clock to a fixed
+// instant. Only work handed this context is affected.
+func WithDeterministicIDs(ctx context.Context, seed string, now func() time.Time) context.Context {
+	if now == nil {
+		now = func() time.Time { return defaultFixedTime }
+	}
+	return context.WithValue(ctx, idSourceKey{}, &idSource{seed: []byte(seed), now: now})
+}
+
+func idSourceFrom(ctx context.Context) *idSource {
+	if ctx == nil {
+		return nil
+	}
+	src, _ := ctx.Value(idSourceKey{}).(*idSource)
+	return src
+}
+
+// RandomAlphanumeric returns n characters from [a-zA-Z0-9], suitable for
+// synthesized tool call identifiers. They are seeded when ctx carries a
+// deterministic source.
+func RandomAlphanumeric(ctx context.Context, n int) string {
+	out := make([]byte, n)
+	if src := idSourceFrom(ctx); src != nil {
+		var pool []byte
+		for i := range out {
+			if len(pool) == 0 {
+				pool = src.next("alnum")
+			}
+			out[i] = alphanumeric[int(pool[0])%len(alphanumeric)]
+			pool = pool[1:]
+		}
+		return string(out)
+	}
+	limit := big.NewInt(int64(len(alphanumeric)))
+	for i := range out {
+		idx, _ := rand.Int(rand.Reader, limit)
+		out[i] = alphanumeric[idx.Int64()]
+	}
+	return string(out)
+}
+
+// NewUUID returns a random UUID, or a seeded one when ctx carries a
+// deterministic source.
+func NewUUID(ctx context.Context) string {
+	if src := idSourceFrom(ctx); src != nil {
+		return uuid.NewSHA1(deterministicNS, src.next("uuid")).String()
+	}
+	return uuid.NewString()
+}
+
+// ProcessUUID returns a UUID that stays stable for the lifetime of the process
+// for the given name (e.g. the synthetic Claude Code account and session). When
+// ctx carries a deterministic source it is derived from the seed and name instead.
+func ProcessUUID(ctx context.Context, name string) string {
+	if src := idSourceFrom(ctx); src != nil {
+		return uuid.NewSHA1(deterministicNS, append(append([]byte{}, src.seed...), name...)).String()
+	}
+	if value, ok := processUUIDs.Load(name); ok {
+		return value.(string)
+	}
+	value, _ := processUUIDs.LoadOrStore(name, uuid.NewString())
+	return value.(string)
+}
+
+// Now returns the current time, or the injected clock when ctx carries a
+// deterministic source.
+func Now(ctx context.Context) time.Time {
+	if src := idSourceFrom(ctx); src != nil {
+		return src.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,341 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/draw"
+	_ "image/gif" // register GIF decoder for inline image downscaling
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// downscaleJPEGQuality is the JPEG quality used when re-encoding opaque images.
+const downscaleJPEGQuality = 85
+
+// Decoding limits. Headers are checked before an image is decoded so a small
+// file declaring huge dimensions cannot make the decoder allocate gigabytes;
+// larger images are forwarded untouched.
+const (
+	maxDecodeDimension = 16384
+	maxDecodePixels    = 40_000_000
+)
+
+// ImageLimits are the thresholds above which inline images are downscaled.
+type ImageLimits struct {
+	// MaxBytes is the decoded size above which an image is downscaled. <= 0 disables.
+	MaxBytes int
+	// MaxDimension caps the longest side of downscaled images. <= 0 keeps it.
+	MaxDimension int
+	// MaxPixels is the pixel count above which an image is downscaled. <= 0 disables.
+	MaxPixels int
+	// PreserveFormat re-encodes PNG and JPEG images in their own format
+	// instead of choosing JPEG for opaque and PNG for transparent images.
+	PreserveFormat bool
+}
+
+// ImageTransform describes one downscaled inline image.
+type ImageTransform struct {
+	Path       string `json:"path"`
+	FromType   string `json:"from_type"`
+	ToType     string `json:"to_type"`
+	FromBytes  int    `json:"from_bytes"`
+	ToBytes    int    `json:"to_bytes"`
+	FromWidth  int    `json:"from_width"`
+	FromHeight int    `json:"from_height"`
+	ToWidth    int    `json:"to_width"`
+	ToHeight   int    `json:"to_height"`
+}
+
+// DownscaleInlineImage shrinks a base64 encoded image whose decoded size exceeds
+// maxBytes. The longest side is reduced to maxDimension (when > 0) and halved
+// further until the encoded result fits or the image becomes tiny. Opaque
+// images are re-encoded as JPEG, images with transparency as PNG.
+//
+// It returns the new base64 data, its media type and true when a smaller image
+// was produced. Unsupported formats (e.g. WebP) are left untouched.
+func DownscaleInlineImage(data string, maxBytes, maxDimension int) (string, string, bool) {
+	newData, transform, ok := downscaleImage(data, ImageLimits{MaxBytes: maxBytes, MaxDimension: maxDimension})
+	return newData, transform.ToType, ok
+}
+
+// downscaleImage shrinks a base64 encoded image that exceeds the byte or pixel
+// limit. An image over the byte limit is only replaced by a smaller encoding;
+// one over the pixel limit is replaced once its pixel count fits.
+func downscaleImage(data string, limits ImageLimits) (string, ImageTransform, bool) {
+	overBytes := limits.MaxBytes > 0 && base64.StdEncoding.DecodedLen(len(data)) > limits.MaxBytes
+	if !overBytes && limits.MaxPixels <= 0 {
+		return "", ImageTransform{}, false
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", ImageTransform{}, false
+	}
+	overBytes = limits.MaxBytes > 0 && len(raw) > limits.MaxBytes
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return "", ImageTransform{}, false
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxDecodeDimension || cfg.Height > maxDecodeDimension ||
+		cfg.Width*cfg.Height > maxDecodePixels {
+		return "", ImageTransform{}, false
+	}
+	overPixels := limits.MaxPixels > 0 && cfg.Width*cfg.Height > limits.MaxPixels
+	if !overBytes && !overPixels {
+		return "", ImageTransform{}, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return "", ImageTransform{}, false
+	}
+
+	bounds := img.Bounds()
+	longest := max(bounds.Dx(), bounds.Dy())
+	target := longest
+	if limits.MaxDimension > 0 && target > limits.MaxDimension {
+		target = limits.MaxDimension
+	}
+	if overPixels {
+		scale := math.Sqrt(float64(limits.MaxPixels) / float64(bounds.Dx()*bounds.Dy()))
+		target = min(target, int(float64(longest)*scale))
+	}
+	asJPEG := isOpaque(img)
+	if limits.PreserveFormat && (format == "jpeg" || format == "png") {
+		asJPEG = format == "jpeg"
+	}
+	mediaType := "image/jpeg"
+	if !asJPEG {
+		mediaType = "image/png"
+	}
+
+	var best []byte
+	var bestImg image.Image
+	for target >= 64 {
+		resized := resizeImage(img, target)
+		encoded, errEncode := encodeImage(resized, asJPEG)
+		if errEncode != nil {
+			return "", ImageTransform{}, false
+		}
+		if best == nil || len(encoded) < len(best) {
+			best, bestImg = encoded, resized
+		}
+		if limits.MaxBytes <= 0 || len(encoded) <= limits.MaxBytes {
+			break
+		}
+		target /= 2
+	}
+	if best == nil || (!overPixels && len(best) >= len(raw)) {
+		return "", ImageTransform{}, false
+	}
+	return base64.StdEncoding.EncodeToString(best), ImageTransform{
+		FromType:   "image/" + format,
+		ToType:     mediaType,
+		FromBytes:  len(raw),
+		ToBytes:    len(best),
+		FromWidth:  bounds.Dx(),
+		FromHeight: bounds.Dy(),
+		ToWidth:    bestImg.Bounds().Dx(),
+		ToHeight:   bestImg.Bounds().Dy(),
+	}, true
+}
+
+// ShrinkInlineImages walks the inline images of a request payload in the given
+// schema and downscales those above maxBytes. It returns the rewritten payload
+// and the number of images replaced.
+func ShrinkInlineImages(format string, payload []byte, maxBytes, maxDimension int) ([]byte, int) {
+	out, transforms := ProcessInlineImages(format, payload, ImageLimits{MaxBytes: maxBytes, MaxDimension: maxDimension})
+	return out, len(transforms)
+}
+
+// ProcessInlineImages walks the inline images of a request payload in the
+// given schema and downscales those above the limits. It returns the rewritten
+// payload and a description of each image replaced.
+func ProcessInlineImages(format string, payload []byte, limits ImageLimits) ([]byte, []ImageTransform) {
+	if (limits.MaxBytes <= 0 && limits.MaxPixels <= 0) || len(payload) == 0 {
+		return payload, nil
+	}
+	out := payload
+	var transforms []ImageTransform
+	replaceDataURL := func(path string, value gjson.Result) {
+		mediaType, data, ok := splitDataURL(value.String())
+		if !ok || !strings.HasPrefix(mediaType, "image/") {
+			return
+		}
+		if newData, transform, shrunk := downscaleImage(data, limits); shrunk {
+			if updated, err := sjson.SetBytes(out, path, "data:"+transform.ToType+";base64,"+newData); err == nil {
+				out = updated
+				transform.Path = path
+				transforms = append(transforms, transform)
+			}
+		}
+	}
+	replaceBase64 := func(dataPath, typePath string, value gjson.Result) {
+		if newData, transform, shrunk := downscaleImage(value.String(), limits); shrunk {
+			updated, err := sjson.SetBytes(out, dataPath, newData)
+			if err != nil {
+				return
+			}
+			if updated, err = sjson.SetBytes(updated, typePath, transform.ToType); err != nil {
+				return
+			}
+			out = updated
+			transform.Path = dataPath
+			transforms = append(transforms, transform)
+		}
+	}
+
+	root := gjson.ParseBytes(payload)
+	switch format {
+	case "claude":
+		root.Get("messages").ForEach(func(i, msg gjson.Result) bool {
+			msg.Get("content").ForEach(func(j, block gjson.Result) bool {
+				if block.Get("type").String() == "image" && block.Get("source.type").String() == "base64" {
+					base := "messages." + i.String() + ".content." + j.String() + ".source"
+					replaceBase64(base+".data", base+".media_type", block.Get("source.data"))
+				}
+				return true
+			})
+			return true
+		})
+	case "openai":
+		root.Get("messages").ForEach(func(i, msg gjson.Result) bool {
+			msg.Get("content").ForEach(func(j, part gjson.Result) bool {
+				if part.Get("type").String() == "image_url" {
+					replaceDataURL("messages."+i.String()+".content."+j.String()+".image_url.url", part.Get("image_url.url"))
+				}
+				return true
+			})
+			return true
+		})
+	case "openai-response":
+		root.Get("input").ForEach(func(i, item gjson.Result) bool {
+			item.Get("content").ForEach(func(j, part gjson.Result) bool {
+				if part.Get("type").String() == "input_image" {
+					replaceDataURL("input."+i.String()+".content."+j.String()+".image_url", part.Get("image_url"))
+				}
+				return true
+			})
+			return true
+		})
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if format == "gemini-cli" {
+			prefix = "request."
+			root = root.Get("request")
+		}
+		root.Get("contents").ForEach(func(i, content gjson.Result) bool {
+			content.Get("parts").ForEach(func(j, part gjson.Result) bool {
+				base := prefix + "contents." + i.String() + ".parts." + j.String()
+				for _, key := range []string{"inlineData", "inline_data"} {
+					inline := part.Get(key)
+					if !inline.Exists() {
+						continue
+					}
+					typeKey := "mimeType"
+					if !inline.Get(typeKey).Exists() {
+						typeKey = "mime_type"
+					}
+					if strings.HasPrefix(inline.Get(typeKey).String(), "image/") {
+						replaceBase64(base+"."+key+".data", base+"."+key+"."+typeKey, inline.Get("data"))
+					}
+				}
+				return true
+			})
+			return true
+		})
+	}
+	return out, transforms
+}
+
+func splitDataURL(url string) (string, string, bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+func encodeImage(img image.Image, asJPEG bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: downscaleJPEGQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// resizeImage scales img so its longest side equals longest, averaging the
+// source pixels covered by each destination pixel (box filter). The source is
+// converted a band of rows at a time with draw.Draw, which has fast paths for
+// the decoders' image types, and averaged on the band's pixel buffer.
+func resizeImage(img image.Image, longest int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= longest && h <= longest {
+		return img
+	}
+	var dw, dh int
+	if w >= h {
+		dw, dh = longest, h*longest/w
+	} else {
+		dw, dh = w*longest/h, longest
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	band := image.NewRGBA(image.Rect(0, 0, w, (h+dh-1)/dh))
+	for y := 0; y < dh; y++ {
+		sy0 := y * h / dh
+		sy1 := (y + 1) * h / dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		rows := sy1 - sy0
+		draw.Draw(band, image.Rect(0, 0, w, rows), img, image.Pt(bounds.Min.X, bounds.Min.Y+sy0), draw.Src)
+		out := dst.Pix[y*dst.Stride:]
+		for x := 0; x < dw; x++ {
+			sx0 := x * w / dw
+			sx1 := (x + 1) * w / dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var r, g, b, a uint64
+			for row := 0; row < rows; row++ {
+				pix := band.Pix[row*band.Stride+sx0*4 : row*band.Stride+sx1*4]
+				for i := 0; i < len(pix); i += 4 {
+					r += uint64(pix[i])
+					g += uint64(pix[i+1])
+					b += uint64(pix[i+2])
+					a += uint64(pix[i+3])
+				}
+			}
+			n := uint64(rows * (sx1 - sx0))
+			out[x*4] = uint8(r / n)
+			out[x*4+1] = uint8(g / n)
+			out[x*4+2] = uint8(b / n)
+			out[x*4+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func noisyPNGBase64(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			seed = seed*1664525 + 1013904223
+			img.Set(x, y, color.RGBA{R: uint8(seed >> 24), G: uint8(seed >> 16), B: uint8(seed >> 8), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestShrinkInlineImages_Claude(t *testing.T) {
+	data := noisyPNGBase64(t, 400, 300)
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + data + `"}},{"type":"text","text":"describe"}]}]}`)
+
+	out, count := ShrinkInlineImages("claude", payload, 20*1024, 256)
+	if count != 1 {
+		t.Fatalf("expected 1 downscaled image, got %d", count)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content.0.source.media_type").String(); got != "image/jpeg" {
+		t.Fatalf("expected jpeg media type, got %q", got)
+	}
+	raw, err := base64.StdEncoding.DecodeString(gjson.GetBytes(out, "messages.0.content.0.source.data").String())
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if cfg.Width > 256 || cfg.Height > 256 {
+		t.Fatalf("expected longest side <= 256, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestShrinkInlineImages_OpenAIDataURLUnderLimit(t *testing.T) {
+	data := noisyPNGBase64(t, 16, 16)
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + data + `"}}]}]}`)
+	out, count := ShrinkInlineImages("openai", payload, 1<<20, 0)
+	if count != 0 || !strings.Contains(string(out), data) {
+		t.Fatalf("small image must be left untouched")
+	}
+}
+
+func TestProcessInlineImages_PixelLimitPreservesFormat(t *testing.T) {
+	data := noisyPNGBase64(t, 400, 300)
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + data + `"}}]}]}`)
+
+	out, transforms := ProcessInlineImages("claude", payload, ImageLimits{MaxPixels: 200 * 150, PreserveFormat: true})
+	if len(transforms) != 1 {
+		t.Fatalf("expected 1 transform, got %d", len(transforms))
+	}
+	tr := transforms[0]
+	if tr.Path != "messages.0.content.0.source.data" || tr.FromType != "image/png" || tr.ToType != "image/png" {
+		t.Fatalf("unexpected transform %+v", tr)
+	}
+	if tr.FromWidth != 400 || tr.FromHeight != 300 || tr.ToWidth*tr.ToHeight > 200*150 {
+		t.Fatalf("unexpected dimensions %+v", tr)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content.0.source.media_type").String(); got != "image/png" {
+		t.Fatalf("expected png media type, got %q", got)
+	}
+
+	if _, transforms = ProcessInlineImages("claude", payload, ImageLimits{MaxPixels: 400 * 300}); len(transforms) != 0 {
+		t.Fatalf("image within the pixel limit was changed: %+v", transforms)
+	}
+}
+
+func TestDownscaleSkipsImagesDeclaringHugeDimensions(t *testing.T) {
+	raw, _ := base64.StdEncoding.DecodeString(noisyPNGBase64(t, 8, 8))
+	// Rewrite the IHDR chunk (after the 8-byte signature, 4-byte length and
+	// type) to declare 50000x50000 and fix up its checksum.
+	binary.BigEndian.PutUint32(raw[16:], 50000)
+	binary.BigEndian.PutUint32(raw[20:], 50000)
+	binary.BigEndian.PutUint32(raw[29:], crc32.ChecksumIEEE(raw[12:29]))
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(raw)); err != nil || cfg.Width != 50000 {
+		t.Fatalf("crafted header not accepted: %v %+v", err, cfg)
+	}
+	if _, _, ok := downscaleImage(base64.StdEncoding.EncodeToString(raw), ImageLimits{MaxBytes: 1, MaxPixels: 1}); ok {
+		t.Fatal("image over the decode cap was decoded")
+	}
+}
+
+func TestResizeImageAveragesBoxes(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 14, 12))
+	for x := 10; x < 14; x++ {
+		src.SetNRGBA(x, 10, color.NRGBA{R: 200, A: 255})
+		src.SetNRGBA(x, 11, color.NRGBA{B: 100, A: 255})
+	}
+	src.SetNRGBA(13, 11, color.NRGBA{})
+	dst := resizeImage(src, 2)
+	if b := dst.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("bounds = %v", b)
+	}
+	left := color.RGBAModel.Convert(dst.At(0, 0)).(color.RGBA)
+	right := color.RGBAModel.Convert(dst.At(1, 0)).(color.RGBA)
+	if left != (color.RGBA{R: 100, B: 50, A: 255}) || right != (color.RGBA{R: 100, B: 25, A: 191}) {
+		t.Fatalf("left = %v, right = %v", left, right)
+	}
+}
@@ -0,0 +1,192 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strings"
+)
+
+// maxPDFStreamBytes bounds the size of a single inflated PDF stream.
+const maxPDFStreamBytes = 8 << 20
+
+// PDFText extracts the text shown by the content streams of a PDF. It is a
+// best-effort reader: uncompressed and FlateDecode streams are supported and
+// literal strings drawn with Tj, TJ, ' and " are collected. Hex strings, which
+// usually need the font's CMap to decode, are skipped.
+func PDFText(pdf []byte) string {
+	var out strings.Builder
+	rest := pdf
+	for {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		dict := rest[:start]
+		if open := bytes.LastIndex(dict, []byte("<<")); open >= 0 {
+			dict = dict[open:]
+		}
+		body := rest[start+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+		content, ok := pdfStreamContent(dict, body[:end])
+		if !ok {
+			continue
+		}
+		if text := pdfContentText(content); strings.TrimSpace(text) != "" {
+			out.WriteString(text)
+			out.WriteString("\n")
+		}
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// pdfStreamContent decodes a stream body according to its dictionary.
+func pdfStreamContent(dict, body []byte) ([]byte, bool) {
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return body, true
+	}
+	if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Contains(dict, []byte("/DCTDecode")) {
+		return nil, false
+	}
+	r, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+	defer func() { _ = r.Close() }()
+	content, err := io.ReadAll(io.LimitReader(r, maxPDFStreamBytes))
+	if err != nil && len(content) == 0 {
+		return nil, false
+	}
+	return content, true
+}
+
+// pdfContentText walks the operators of a content stream and returns the text
+// it draws, starting a new line for line-moving operators and text objects.
+func pdfContentText(content []byte) string {
+	var out strings.Builder
+	var pending []string
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteString("\n")
+		}
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, next := pdfLiteralString(content, i)
+			pending = append(pending, s)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			// Hex string: skip to the closing bracket.
+			if end := bytes.IndexByte(content[i:], '>'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(content)
+			}
+		case c == '/':
+			// Name object: skip it so it is not mistaken for an operator.
+			i++
+			for i < len(content) && (isPDFOperatorByte(content[i]) || (content[i] >= '0' && content[i] <= '9')) {
+				i++
+			}
+		case c == '%':
+			if end := bytes.IndexByte(content[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(content)
+			}
+		case isPDFOperatorByte(c):
+			j := i
+			for j < len(content) && isPDFOperatorByte(content[j]) {
+				j++
+			}
+			switch op := string(content[i:j]); op {
+			case "Tj", "TJ":
+				out.WriteString(strings.Join(pending, ""))
+			case "'", "\"":
+				newline()
+				out.WriteString(strings.Join(pending, ""))
+			case "T*", "ET":
+				newline()
+			case "Td", "TD":
+				newline()
+			}
+			pending = pending[:0]
+			i = j
+		default:
+			i++
+		}
+	}
+	return out.String()
+}
+
+func isPDFOperatorByte(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*' || c == '\'' || c == '"'
+}
+
+// pdfLiteralString decodes the literal string starting at content[start] ('(')
+// and returns it with the index just past its closing parenthesis.
+func pdfLiteralString(content []byte, start int) (string, int) {
+	var b strings.Builder
+	depth := 0
+	i := start
+	for ; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '(':
+			if depth > 0 {
+				b.WriteByte(c)
+			}
+			depth++
+			continue
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(c)
+			continue
+		case '\\':
+			if i+1 >= len(content) {
+				continue
+			}
+			i++
+			switch e := content[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					value := 0
+					n := 0
+					for n < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7' {
+						value = value*8 + int(content[i]-'0')
+						i++
+						n++
+					}
+					i--
+					// PDFDocEncoding matches Latin-1 for printable characters.
+					b.WriteRune(rune(value & 0xFF))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+			continue
+		}
+		b.WriteRune(rune(c))
+	}
+	return b.String(), i
+}
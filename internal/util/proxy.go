@@ -5,51 +5,90 @@ package util
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpproxy"
 	"golang.org/x/net/proxy"
 )
 
 // SetProxy configures the provided HTTP client with proxy settings from the configuration.
-// It supports SOCKS5, HTTP, and HTTPS proxies. The function modifies the client's transport
-// to route requests through the configured proxy server.
+// It supports SOCKS5, HTTP, and HTTPS proxies. The function sets the client's transport to
+// the shared upstream transport for the configured proxy, so upstream TLS and connection
+// pool settings apply with or without a proxy.
 func SetProxy(cfg *config.SDKConfig, httpClient *http.Client) *http.Client {
-	var transport *http.Transport
-	// Attempt to parse the proxy URL from the configuration.
-	proxyURL, errParse := url.Parse(cfg.ProxyURL)
-	if errParse == nil {
-		// Handle different proxy schemes.
-		if proxyURL.Scheme == "socks5" {
-			// Configure SOCKS5 proxy with optional authentication.
-			var proxyAuth *proxy.Auth
-			if proxyURL.User != nil {
-				username := proxyURL.User.Username()
-				password, _ := proxyURL.User.Password()
-				proxyAuth = &proxy.Auth{User: username, Password: password}
-			}
-			dialer, errSOCKS5 := proxy.SOCKS5("tcp", proxyURL.Host, proxyAuth, proxy.Direct)
-			if errSOCKS5 != nil {
-				log.Errorf("create SOCKS5 dialer failed: %v", errSOCKS5)
-				return httpClient
-			}
-			// Set up a custom transport using the SOCKS5 dialer.
-			transport = &http.Transport{
-				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					return dialer.Dial(network, addr)
-				},
-			}
-		} else if proxyURL.Scheme == "http" || proxyURL.Scheme == "https" {
-			// Configure HTTP or HTTPS proxy.
-			transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	transport, err := SharedUpstreamTransport(cfg, cfg.ProxyURL)
+	if err != nil {
+		log.Errorf("configure proxy failed: %v", err)
+		return httpClient
+	}
+	httpClient.Transport = transport
+	return httpClient
+}
+
+// NewProxyTransport returns a transport that sends requests through proxyURL
+// (socks5, socks5h, http or https, with optional user:password credentials).
+// Hosts matching noProxy connect directly; entries use the NO_PROXY syntax
+// (host names, ".domain" suffixes, IPs and CIDR ranges, optionally with a
+// port). When noProxy is empty the NO_PROXY environment variable is used.
+func NewProxyTransport(proxyURL string, noProxy []string) (*http.Transport, error) {
+	parsed, err := url.Parse(strings.TrimSpace(proxyURL))
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy URL: %w", err)
+	}
+	bypass := proxyBypass(noProxy)
+	switch parsed.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if parsed.User != nil {
+			password, _ := parsed.User.Password()
+			auth = &proxy.Auth{User: parsed.User.Username(), Password: password}
 		}
+		dialer, errSOCKS5 := proxy.SOCKS5("tcp", parsed.Host, auth, proxy.Direct)
+		if errSOCKS5 != nil {
+			return nil, fmt.Errorf("create SOCKS5 dialer: %w", errSOCKS5)
+		}
+		direct := &net.Dialer{}
+		return &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if bypass != nil && bypass(addr) {
+					return direct.DialContext(ctx, network, addr)
+				}
+				return dialer.Dial(network, addr)
+			},
+		}, nil
+	case "http", "https":
+		return &http.Transport{Proxy: func(req *http.Request) (*url.URL, error) {
+			if bypass != nil && bypass(req.URL.Host) {
+				return nil, nil
+			}
+			return parsed, nil
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", parsed.Scheme)
 	}
-	// If a new transport was created, apply it to the HTTP client.
-	if transport != nil {
-		httpClient.Transport = transport
+}
+
+// proxyBypass returns a matcher for hosts ("host" or "host:port") that must
+// not be proxied, or nil when no exclusions are configured.
+func proxyBypass(noProxy []string) func(hostport string) bool {
+	list := strings.TrimSpace(strings.Join(noProxy, ","))
+	if list == "" {
+		list = httpproxy.FromEnvironment().NoProxy
+	}
+	if list == "" {
+		return nil
+	}
+	// httpproxy evaluates NO_PROXY against a target URL; a placeholder proxy
+	// makes it return nil exactly for the excluded hosts.
+	proxyFunc := (&httpproxy.Config{HTTPProxy: "http://proxy.invalid", HTTPSProxy: "http://proxy.invalid", NoProxy: list}).ProxyFunc()
+	return func(hostport string) bool {
+		target, err := proxyFunc(&url.URL{Scheme: "https", Host: hostport})
+		return err == nil && target == nil
 	}
-	return httpClient
 }
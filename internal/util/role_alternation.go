@@ -0,0 +1,209 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Role alternation levels.
+const (
+	// RoleAlternationOff leaves the history alone.
+	RoleAlternationOff = "off"
+	// RoleAlternationMerge merges adjacent turns of the same role.
+	RoleAlternationMerge = "merge"
+	// RoleAlternationStrict merges like RoleAlternationMerge and makes the
+	// history start with a user turn, inserting a placeholder turn if needed.
+	RoleAlternationStrict = "strict"
+)
+
+// RoleAlternationPlaceholder is the text of inserted turns.
+const RoleAlternationPlaceholder = "Continue."
+
+// Role alternation fix kinds.
+const (
+	RoleAlternationMerged   = "merged"
+	RoleAlternationInserted = "inserted"
+)
+
+// RoleAlternationFix records one change made to enforce role alternation.
+type RoleAlternationFix struct {
+	// Kind is RoleAlternationMerged or RoleAlternationInserted.
+	Kind string `json:"kind"`
+	Role string `json:"role"`
+	// MessageIndex is the index in the original history of the merged turn,
+	// or of the turn the placeholder was inserted before.
+	MessageIndex int `json:"message_index"`
+}
+
+// EnforceRoleAlternation makes the user and assistant turns of a claude,
+// openai, gemini or gemini-cli history alternate, as some upstreams require.
+// Turns are merged, never dropped: text is joined and blocks, parts and tool
+// calls are concatenated, with claude tool_result blocks kept first in their
+// user turn. OpenAI system and developer messages do not take part, and tool
+// messages count as the user side without being merged. Other formats and
+// levels are returned unchanged.
+func EnforceRoleAlternation(format string, payload []byte, level string) ([]byte, []RoleAlternationFix) {
+	if level != RoleAlternationMerge && level != RoleAlternationStrict {
+		return payload, nil
+	}
+	path, contentKey := "", ""
+	switch format {
+	case "claude", "openai":
+		path, contentKey = "messages", "content"
+	case "gemini":
+		path, contentKey = "contents", "parts"
+	case "gemini-cli":
+		path, contentKey = "request.contents", "parts"
+	default:
+		return payload, nil
+	}
+	messages := gjson.GetBytes(payload, path)
+	if !messages.IsArray() {
+		return payload, nil
+	}
+	var fixes []RoleAlternationFix
+	out := make([]string, 0, len(messages.Array()))
+	lastRole, lastIndex := "", -1
+	started := false
+	for i, message := range messages.Array() {
+		role := alternationRole(format, message)
+		switch {
+		case role == "":
+			// System messages keep their place; turns around them still merge.
+			out = append(out, message.Raw)
+			continue
+		case !started && level == RoleAlternationStrict && role != "user":
+			out = append(out, placeholderTurn(format, "user"))
+			fixes = append(fixes, RoleAlternationFix{Kind: RoleAlternationInserted, Role: "user", MessageIndex: i})
+		}
+		started = true
+		if role == lastRole && message.Get("role").String() != "tool" && gjson.Get(out[lastIndex], "role").String() != "tool" {
+			out[lastIndex] = mergeTurns(format, contentKey, gjson.Parse(out[lastIndex]), message)
+			fixes = append(fixes, RoleAlternationFix{Kind: RoleAlternationMerged, Role: role, MessageIndex: i})
+			continue
+		}
+		out = append(out, message.Raw)
+		lastRole, lastIndex = role, len(out)-1
+	}
+	if len(fixes) == 0 {
+		return payload, nil
+	}
+	updated, err := sjson.SetRawBytes(payload, path, []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, nil
+	}
+	return updated, fixes
+}
+
+// alternationRole returns the side of the conversation a message is on:
+// "user", "assistant" (or "model" for gemini), or "" for messages outside the
+// alternation.
+func alternationRole(format string, message gjson.Result) string {
+	role := message.Get("role").String()
+	switch format {
+	case "openai":
+		switch role {
+		case "user", "tool":
+			return "user"
+		case "assistant":
+			return "assistant"
+		}
+		return ""
+	case "gemini", "gemini-cli":
+		if role == "model" {
+			return "model"
+		}
+		return "user"
+	default:
+		return role
+	}
+}
+
+func placeholderTurn(format, role string) string {
+	turn := `{}`
+	turn, _ = sjson.Set(turn, "role", role)
+	if format == "gemini" || format == "gemini-cli" {
+		turn, _ = sjson.SetRaw(turn, "parts", `[]`)
+		turn, _ = sjson.Set(turn, "parts.-1.text", RoleAlternationPlaceholder)
+		return turn
+	}
+	turn, _ = sjson.Set(turn, "content", RoleAlternationPlaceholder)
+	return turn
+}
+
+// mergeTurns appends the content of next to prev.
+func mergeTurns(format, contentKey string, prev, next gjson.Result) string {
+	merged := prev.Raw
+	if format == "gemini" || format == "gemini-cli" {
+		parts := append(prev.Get("parts").Array(), next.Get("parts").Array()...)
+		merged, _ = sjson.SetRaw(merged, "parts", rawArray(parts))
+		return merged
+	}
+
+	content := mergeContent(prev.Get(contentKey), next.Get(contentKey))
+	if format == "claude" && prev.Get("role").String() == "user" && gjson.Parse(content).IsArray() {
+		// tool_result blocks must open the user turn that follows tool_use.
+		var results, rest []gjson.Result
+		for _, block := range gjson.Parse(content).Array() {
+			if block.Get("type").String() == "tool_result" {
+				results = append(results, block)
+			} else {
+				rest = append(rest, block)
+			}
+		}
+		content = rawArray(append(results, rest...))
+	}
+	if content == "" {
+		merged, _ = sjson.Delete(merged, contentKey)
+	} else {
+		merged, _ = sjson.SetRaw(merged, contentKey, content)
+	}
+	if calls := append(prev.Get("tool_calls").Array(), next.Get("tool_calls").Array()...); len(calls) > 0 {
+		merged, _ = sjson.SetRaw(merged, "tool_calls", rawArray(calls))
+	}
+	return merged
+}
+
+// mergeContent joins two message contents. Two strings are joined by a blank
+// line; otherwise strings become text blocks and the lists are concatenated.
+// It returns "" when both are empty.
+func mergeContent(a, b gjson.Result) string {
+	isText := func(c gjson.Result) bool { return c.Type == gjson.String || !c.Exists() || c.Type == gjson.Null }
+	if isText(a) && isText(b) {
+		var texts []string
+		for _, c := range []gjson.Result{a, b} {
+			if text := c.String(); strings.TrimSpace(text) != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) == 0 {
+			if a.Exists() {
+				return a.Raw
+			}
+			return b.Raw
+		}
+		joined, _ := sjson.Set(`{}`, "v", strings.Join(texts, "\n\n"))
+		return gjson.Get(joined, "v").Raw
+	}
+	var blocks []gjson.Result
+	for _, c := range []gjson.Result{a, b} {
+		switch {
+		case c.IsArray():
+			blocks = append(blocks, c.Array()...)
+		case c.Type == gjson.String && strings.TrimSpace(c.String()) != "":
+			block, _ := sjson.Set(`{"type":"text"}`, "text", c.String())
+			blocks = append(blocks, gjson.Parse(block))
+		}
+	}
+	return rawArray(blocks)
+}
+
+func rawArray(items []gjson.Result) string {
+	raws := make([]string, len(items))
+	for i, item := range items {
+		raws[i] = item.Raw
+	}
+	return "[" + strings.Join(raws, ",") + "]"
+}
@@ -0,0 +1,143 @@
+package util
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestEnforceRoleAlternationMergesClaudeTurns(t *testing.T) {
+	payload := []byte(`{"messages":[
+		{"role":"assistant","content":"hello"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{}}]},
+		{"role":"user","content":"also this"},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}
+	]}`)
+
+	out, fixes := EnforceRoleAlternation("claude", payload, RoleAlternationStrict)
+	if len(fixes) != 3 || fixes[0].Kind != RoleAlternationInserted {
+		t.Fatalf("fixes = %+v", fixes)
+	}
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 3 || messages[0].Get("content").String() != RoleAlternationPlaceholder {
+		t.Fatalf("messages = %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if got := messages[1].Get("content.#.type").Raw; got != `["text","tool_use"]` {
+		t.Fatalf("assistant blocks = %s", got)
+	}
+	if got := messages[2].Get("content.#.type").Raw; got != `["tool_result","text"]` {
+		t.Fatalf("user blocks = %s, want the tool_result first", got)
+	}
+
+	if out, fixes = EnforceRoleAlternation("claude", payload, RoleAlternationOff); fixes != nil || string(out) != string(payload) {
+		t.Fatal("off level changed the payload")
+	}
+}
+
+// randomTranscript builds a history of random roles and content shapes.
+func randomTranscript(rng *rand.Rand, format string) []byte {
+	var messages []string
+	for i := 0; i < rng.IntN(8); i++ {
+		switch format {
+		case "claude":
+			role := []string{"user", "assistant"}[rng.IntN(2)]
+			content := []string{`"text"`, `""`, `[{"type":"text","text":"a"}]`, `[{"type":"tool_use","id":"t","name":"f","input":{}}]`, `[{"type":"tool_result","tool_use_id":"t","content":"r"}]`}[rng.IntN(5)]
+			messages = append(messages, fmt.Sprintf(`{"role":%q,"content":%s}`, role, content))
+		case "openai":
+			switch rng.IntN(5) {
+			case 0:
+				messages = append(messages, `{"role":"system","content":"s"}`)
+			case 1:
+				messages = append(messages, `{"role":"tool","tool_call_id":"c","content":"r"}`)
+			case 2:
+				messages = append(messages, `{"role":"assistant","content":null,"tool_calls":[{"id":"c","type":"function","function":{"name":"f","arguments":"{}"}}]}`)
+			case 3:
+				messages = append(messages, `{"role":"assistant","content":"a"}`)
+			default:
+				messages = append(messages, `{"role":"user","content":[{"type":"text","text":"u"}]}`)
+			}
+		default:
+			role := []string{"user", "model", "function"}[rng.IntN(3)]
+			messages = append(messages, fmt.Sprintf(`{"role":%q,"parts":[{"text":"p"}]}`, role))
+		}
+	}
+	key := "messages"
+	if format == "gemini" {
+		key = "contents"
+	}
+	return []byte(fmt.Sprintf(`{%q:[%s]}`, key, strings.Join(messages, ",")))
+}
+
+// itemCount counts the tool blocks and text characters, tool calls or parts of
+// a history, which merging must preserve.
+func itemCount(format string, payload []byte) int {
+	switch format {
+	case "claude":
+		n := 0
+		text := strings.NewReplacer("\n\n", "", RoleAlternationPlaceholder, "")
+		for _, message := range gjson.GetBytes(payload, "messages").Array() {
+			content := message.Get("content")
+			if content.Type == gjson.String {
+				n += len(text.Replace(content.String()))
+				continue
+			}
+			for _, block := range content.Array() {
+				if block.Get("type").String() == "text" {
+					n += len(text.Replace(block.Get("text").String()))
+				} else {
+					n += 100
+				}
+			}
+		}
+		return n
+	case "openai":
+		return len(gjson.GetBytes(payload, "messages.#.tool_calls|@flatten").Array())
+	default:
+		n := 0
+		for _, part := range gjson.GetBytes(payload, "contents.#.parts|@flatten").Array() {
+			if part.Get("text").String() != RoleAlternationPlaceholder {
+				n++
+			}
+		}
+		return n
+	}
+}
+
+func TestEnforceRoleAlternationInvariant(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, format := range []string{"claude", "openai", "gemini"} {
+		for i := 0; i < 500; i++ {
+			payload := randomTranscript(rng, format)
+			out, _ := EnforceRoleAlternation(format, payload, RoleAlternationStrict)
+			if !gjson.ValidBytes(out) {
+				t.Fatalf("%s: invalid output %s", format, out)
+			}
+			if got, want := itemCount(format, out), itemCount(format, payload); got != want {
+				t.Fatalf("%s: %d items after merging, want %d\nin:  %s\nout: %s", format, got, want, payload, out)
+			}
+
+			path := "messages"
+			if format == "gemini" {
+				path = "contents"
+			}
+			prevSide, prevTool := "", false
+			for j, message := range gjson.GetBytes(out, path).Array() {
+				side := alternationRole(format, message)
+				if side == "" {
+					continue
+				}
+				isTool := message.Get("role").String() == "tool"
+				if prevSide == "" && side != "user" {
+					t.Fatalf("%s: history starts with %s\n%s", format, side, out)
+				}
+				if side == prevSide && !isTool && !prevTool {
+					t.Fatalf("%s: message %d repeats role %s\nin:  %s\nout: %s", format, j, side, payload, out)
+				}
+				prevSide, prevTool = side, isTool
+			}
+		}
+	}
+}
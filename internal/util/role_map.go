@@ -0,0 +1,100 @@
+package util
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// RoleMapFallback is the role-map key matching any role the schema does not know.
+const RoleMapFallback = "*"
+
+// knownRoles lists the message roles each inbound schema understands.
+var knownRoles = map[string]map[string]struct{}{
+	"openai":          {"system": {}, "developer": {}, "user": {}, "assistant": {}, "tool": {}},
+	"openai-response": {"system": {}, "developer": {}, "user": {}, "assistant": {}},
+	"claude":          {"user": {}, "assistant": {}},
+	"gemini":          {"user": {}, "model": {}, "function": {}},
+	"gemini-cli":      {"user": {}, "model": {}, "function": {}},
+}
+
+// RoleMapping records a role rewrite applied to one message.
+type RoleMapping struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	MessageIndex int    `json:"message_index"`
+}
+
+// NormalizeRoles rewrites message roles the schema does not understand using
+// roleMap (keys are matched case-insensitively; "*" matches any unknown role).
+// Known roles are never rewritten. For OpenAI chat payloads the original role
+// is kept in the message "name" when none is set, so named participants stay
+// distinguishable. Unknown roles without a mapping are returned in unmapped.
+func NormalizeRoles(format string, payload []byte, roleMap map[string]string) ([]byte, []RoleMapping, []string) {
+	known, ok := knownRoles[format]
+	if !ok {
+		return payload, nil, nil
+	}
+	lookup := make(map[string]string, len(roleMap))
+	for from, to := range roleMap {
+		lookup[strings.ToLower(strings.TrimSpace(from))] = strings.TrimSpace(to)
+	}
+
+	listPath := "messages"
+	switch format {
+	case "openai-response":
+		listPath = "input"
+	case "gemini":
+		listPath = "contents"
+	case "gemini-cli":
+		listPath = "request.contents"
+	}
+
+	out := payload
+	var mappings []RoleMapping
+	unmappedSet := map[string]struct{}{}
+	gjson.GetBytes(payload, listPath).ForEach(func(i, msg gjson.Result) bool {
+		role := msg.Get("role")
+		if role.Type != gjson.String {
+			return true
+		}
+		name := role.String()
+		if _, isKnown := known[name]; isKnown {
+			return true
+		}
+		target, found := lookup[strings.ToLower(name)]
+		if !found {
+			target, found = lookup[RoleMapFallback]
+		}
+		if !found || target == "" {
+			unmappedSet[name] = struct{}{}
+			return true
+		}
+		if _, valid := known[target]; !valid {
+			unmappedSet[name] = struct{}{}
+			return true
+		}
+		base := listPath + "." + i.String()
+		updated, err := sjson.SetBytes(out, base+".role", target)
+		if err != nil {
+			return true
+		}
+		if format == "openai" && !msg.Get("name").Exists() {
+			if withName, errName := sjson.SetBytes(updated, base+".name", name); errName == nil {
+				updated = withName
+			}
+		}
+		out = updated
+		mappings = append(mappings, RoleMapping{From: name, To: target, MessageIndex: int(i.Int())})
+		return true
+	})
+
+	unmapped := make([]string, 0, len(unmappedSet))
+	for name := range unmappedSet {
+		unmapped = append(unmapped, name)
+	}
+	sort.Strings(unmapped)
+	return out, mappings, unmapped
+}
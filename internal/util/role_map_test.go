@@ -0,0 +1,36 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestNormalizeRoles(t *testing.T) {
+	payload := []byte(`{"messages":[
+		{"role":"system","content":"s"},
+		{"role":"Bob","content":"hello from bob"},
+		{"role":"narrator","content":"meanwhile"},
+		{"role":"critic","content":"?"},
+		{"role":"user","content":"hi"}
+	]}`)
+	out, mappings, unmapped := NormalizeRoles("openai", payload, map[string]string{"bob": "assistant", "narrator": "system"})
+
+	if len(mappings) != 2 || mappings[0].From != "Bob" || mappings[0].To != "assistant" || mappings[0].MessageIndex != 1 {
+		t.Fatalf("unexpected mappings: %+v", mappings)
+	}
+	if got := gjson.GetBytes(out, "messages.1.role").String(); got != "assistant" {
+		t.Fatalf("role = %q, want assistant", got)
+	}
+	if got := gjson.GetBytes(out, "messages.1.name").String(); got != "Bob" {
+		t.Fatalf("name = %q, want original role preserved", got)
+	}
+	if len(unmapped) != 1 || unmapped[0] != "critic" {
+		t.Fatalf("unmapped = %v, want [critic]", unmapped)
+	}
+
+	out, mappings, unmapped = NormalizeRoles("claude", []byte(`{"messages":[{"role":"critic","content":"x"}]}`), map[string]string{"*": "user"})
+	if len(mappings) != 1 || len(unmapped) != 0 || gjson.GetBytes(out, "messages.0.role").String() != "user" {
+		t.Fatalf("fallback mapping not applied: %s %+v %v", out, mappings, unmapped)
+	}
+}
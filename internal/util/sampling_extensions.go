@@ -0,0 +1,65 @@
+package util
+
+import (
+	"sort"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// SamplingExtensionKeys are the request fields that carry provider-specific
+// parameters the client schema has no field for.
+var SamplingExtensionKeys = []string{"extra_body"}
+
+// samplingExtensionFields maps, per client schema, the extension parameters
+// that schema can carry to the field they are moved to. Translators then map
+// those fields to the upstream wherever it supports them.
+var samplingExtensionFields = map[string]map[string]string{
+	"openai": {
+		"top_k": "top_k", "min_p": "min_p", "repetition_penalty": "repetition_penalty",
+		"seed": "seed", "frequency_penalty": "frequency_penalty", "presence_penalty": "presence_penalty",
+	},
+	"claude": {"top_k": "top_k"},
+	"gemini": {
+		"top_k": "generationConfig.topK", "seed": "generationConfig.seed",
+		"frequency_penalty": "generationConfig.frequencyPenalty", "presence_penalty": "generationConfig.presencePenalty",
+	},
+}
+
+// HoistSamplingExtensions moves the sampling parameters of the extension
+// objects of a request (e.g. {"extra_body":{"top_k":40}}) to the fields of
+// its schema, and removes the extension objects. A parameter the request also
+// sets directly keeps the direct value. It returns the request, the moved
+// parameters and the parameters the schema cannot carry, which are dropped;
+// both lists are sorted.
+func HoistSamplingExtensions(format string, request []byte) ([]byte, []string, []string) {
+	fields := samplingExtensionFields[format]
+	out := request
+	var moved, unknown []string
+	for _, key := range SamplingExtensionKeys {
+		extension := gjson.GetBytes(request, key)
+		if !extension.Exists() {
+			continue
+		}
+		extension.ForEach(func(name, value gjson.Result) bool {
+			field, ok := fields[name.String()]
+			if !ok || value.Type != gjson.Number {
+				unknown = append(unknown, name.String())
+				return true
+			}
+			if !gjson.GetBytes(out, field).Exists() {
+				if updated, err := sjson.SetRawBytes(out, field, []byte(value.Raw)); err == nil {
+					out = updated
+				}
+			}
+			moved = append(moved, name.String())
+			return true
+		})
+		if updated, err := sjson.DeleteBytes(out, key); err == nil {
+			out = updated
+		}
+	}
+	sort.Strings(moved)
+	sort.Strings(unknown)
+	return out, moved, unknown
+}
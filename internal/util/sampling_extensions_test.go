@@ -0,0 +1,38 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestHoistSamplingExtensions(t *testing.T) {
+	request := []byte(`{"model":"m","seed":7,"extra_body":{"top_k":40,"repetition_penalty":1.1,"seed":9,"mirostat":2,"guided_json":{}}}`)
+	out, moved, unknown := HoistSamplingExtensions("openai", request)
+	if gjson.GetBytes(out, "extra_body").Exists() {
+		t.Fatalf("extension object kept: %s", out)
+	}
+	if gjson.GetBytes(out, "top_k").Int() != 40 || gjson.GetBytes(out, "repetition_penalty").Float() != 1.1 {
+		t.Fatalf("parameters not moved: %s", out)
+	}
+	if gjson.GetBytes(out, "seed").Int() != 7 {
+		t.Fatalf("direct value overridden: %s", out)
+	}
+	if got := strings.Join(moved, ","); got != "repetition_penalty,seed,top_k" {
+		t.Fatalf("moved = %s", got)
+	}
+	if got := strings.Join(unknown, ","); got != "guided_json,mirostat" {
+		t.Fatalf("unknown = %s", got)
+	}
+
+	out, moved, unknown = HoistSamplingExtensions("claude", []byte(`{"extra_body":{"top_k":5,"min_p":0.1}}`))
+	if gjson.GetBytes(out, "top_k").Int() != 5 || len(moved) != 1 || strings.Join(unknown, ",") != "min_p" {
+		t.Fatalf("claude: %s moved=%v unknown=%v", out, moved, unknown)
+	}
+
+	plain := []byte(`{"model":"m"}`)
+	if out, moved, unknown = HoistSamplingExtensions("openai", plain); string(out) != string(plain) || moved != nil || unknown != nil {
+		t.Fatalf("request without extensions changed: %s", out)
+	}
+}
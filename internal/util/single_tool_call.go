@@ -0,0 +1,229 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ParallelToolCallsDisabled reports whether an OpenAI chat completions or
+// responses request set parallel_tool_calls to false.
+func ParallelToolCallsDisabled(format string, request []byte) bool {
+	if format != "openai" && format != "openai-response" {
+		return false
+	}
+	value := gjson.GetBytes(request, "parallel_tool_calls")
+	return value.Exists() && !value.Bool()
+}
+
+// LimitToolCalls keeps only the first tool call of each turn in a
+// non-streaming openai or openai-response response, the behaviour clients
+// expect from parallel_tool_calls=false. It returns the payload and the number
+// of calls dropped.
+func LimitToolCalls(format string, payload []byte) ([]byte, int) {
+	switch format {
+	case "openai":
+		dropped := 0
+		for i, choice := range gjson.GetBytes(payload, "choices").Array() {
+			calls := choice.Get("message.tool_calls").Array()
+			if len(calls) <= 1 {
+				continue
+			}
+			updated, err := sjson.SetRawBytes(payload, fmt.Sprintf("choices.%d.message.tool_calls", i), []byte("["+calls[0].Raw+"]"))
+			if err != nil {
+				continue
+			}
+			payload = updated
+			dropped += len(calls) - 1
+		}
+		return payload, dropped
+	case "openai-response":
+		return limitResponseOutput(payload, "output")
+	default:
+		return payload, 0
+	}
+}
+
+// limitResponseOutput drops every function_call item after the first from the
+// responses output array at path.
+func limitResponseOutput(payload []byte, path string) ([]byte, int) {
+	items := gjson.GetBytes(payload, path).Array()
+	kept := make([]string, 0, len(items))
+	seen, dropped := false, 0
+	for _, item := range items {
+		if item.Get("type").String() == "function_call" {
+			if seen {
+				dropped++
+				continue
+			}
+			seen = true
+		}
+		kept = append(kept, item.Raw)
+	}
+	if dropped == 0 {
+		return payload, 0
+	}
+	updated, err := sjson.SetRawBytes(payload, path, []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return payload, 0
+	}
+	return updated, dropped
+}
+
+// SingleToolCallStream applies LimitToolCalls to a streamed openai or
+// openai-response response. The first tool call of each choice passes through;
+// deltas and events of later calls are removed.
+type SingleToolCallStream struct {
+	format string
+	// first holds the tool call index kept per chat completion choice.
+	first map[int64]int64
+	// kept is the output index of the function call kept in a responses stream.
+	kept    int64
+	dropped map[int64]struct{}
+	total   int
+}
+
+// NewSingleToolCallStream returns a filter for a stream in the given format.
+func NewSingleToolCallStream(format string) *SingleToolCallStream {
+	return &SingleToolCallStream{format: format, first: make(map[int64]int64), kept: -1, dropped: make(map[int64]struct{})}
+}
+
+// Dropped returns the number of tool calls removed so far.
+func (s *SingleToolCallStream) Dropped() int {
+	if s == nil {
+		return 0
+	}
+	return s.total
+}
+
+// Chunk filters one stream chunk, given either as a bare JSON event or as SSE
+// lines. It returns an empty slice when nothing of the chunk is left.
+func (s *SingleToolCallStream) Chunk(payload []byte) []byte {
+	if s == nil {
+		return payload
+	}
+	return filterStreamEvents(payload, s.event)
+}
+
+// filterStreamEvents applies filter to the JSON events of a stream chunk,
+// given either as a bare JSON event or as SSE lines. Events the filter does
+// not keep are removed together with their event: line. It returns an empty
+// slice when nothing of the chunk is left.
+func filterStreamEvents(payload []byte, filter func([]byte) ([]byte, bool)) []byte {
+	if len(payload) == 0 {
+		return payload
+	}
+	if gjson.ValidBytes(payload) {
+		out, keep := filter(payload)
+		if !keep {
+			return nil
+		}
+		return out
+	}
+	lines := bytes.Split(payload, []byte("\n"))
+	out := make([][]byte, 0, len(lines))
+	changed := false
+	for _, line := range lines {
+		data, found := bytes.CutPrefix(line, []byte("data:"))
+		trimmed := bytes.TrimSpace(data)
+		if !found || !gjson.ValidBytes(trimmed) {
+			out = append(out, line)
+			continue
+		}
+		updated, keep := filter(trimmed)
+		if !keep {
+			if n := len(out); n > 0 && bytes.HasPrefix(out[n-1], []byte("event:")) {
+				out = out[:n-1]
+			}
+			changed = true
+			continue
+		}
+		if !bytes.Equal(updated, trimmed) {
+			line = append([]byte("data: "), updated...)
+			changed = true
+		}
+		out = append(out, line)
+	}
+	if !changed {
+		return payload
+	}
+	if len(bytes.TrimSpace(bytes.Join(out, nil))) == 0 {
+		return nil
+	}
+	return bytes.Join(out, []byte("\n"))
+}
+
+// event filters one JSON event and reports whether it should be kept.
+func (s *SingleToolCallStream) event(payload []byte) ([]byte, bool) {
+	root := gjson.ParseBytes(payload)
+	switch s.format {
+	case "openai":
+		out := payload
+		for i, choice := range root.Get("choices").Array() {
+			calls := choice.Get("delta.tool_calls").Array()
+			if len(calls) == 0 {
+				continue
+			}
+			choiceIndex := choice.Get("index").Int()
+			kept := make([]string, 0, len(calls))
+			for _, call := range calls {
+				index := call.Get("index").Int()
+				first, ok := s.first[choiceIndex]
+				if !ok {
+					s.first[choiceIndex], first = index, index
+				}
+				if index == first {
+					kept = append(kept, call.Raw)
+					continue
+				}
+				// A call is counted once, on the delta that opens it.
+				if call.Get("id").String() != "" || call.Get("function.name").String() != "" {
+					s.total++
+				}
+			}
+			if len(kept) == len(calls) {
+				continue
+			}
+			path := fmt.Sprintf("choices.%d.delta.tool_calls", i)
+			var err error
+			if len(kept) == 0 {
+				out, err = sjson.DeleteBytes(out, path)
+			} else {
+				out, err = sjson.SetRawBytes(out, path, []byte("["+strings.Join(kept, ",")+"]"))
+			}
+			if err != nil {
+				return payload, true
+			}
+		}
+		return out, true
+	case "openai-response":
+		switch root.Get("type").String() {
+		case "response.output_item.added":
+			if root.Get("item.type").String() != "function_call" {
+				return payload, true
+			}
+			index := root.Get("output_index").Int()
+			if s.kept < 0 || s.kept == index {
+				s.kept = index
+				return payload, true
+			}
+			s.dropped[index] = struct{}{}
+			s.total++
+			return payload, false
+		case "response.completed", "response.incomplete":
+			out, _ := limitResponseOutput(payload, "response.output")
+			return out, true
+		}
+		if index := root.Get("output_index"); index.Exists() {
+			if _, drop := s.dropped[index.Int()]; drop {
+				return payload, false
+			}
+		}
+		return payload, true
+	default:
+		return payload, true
+	}
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestLimitToolCalls(t *testing.T) {
+	if !ParallelToolCallsDisabled("openai", []byte(`{"parallel_tool_calls":false}`)) || ParallelToolCallsDisabled("openai", []byte(`{}`)) {
+		t.Fatalf("parallel_tool_calls detection is wrong")
+	}
+
+	payload := []byte(`{"choices":[{"message":{"tool_calls":[{"id":"a"},{"id":"b"},{"id":"c"}]},"finish_reason":"tool_calls"}]}`)
+	out, dropped := LimitToolCalls("openai", payload)
+	if calls := gjson.GetBytes(out, "choices.0.message.tool_calls").Array(); dropped != 2 || len(calls) != 1 || calls[0].Get("id").String() != "a" {
+		t.Fatalf("openai: dropped=%d out=%s", dropped, out)
+	}
+
+	payload = []byte(`{"output":[{"type":"message"},{"type":"function_call","call_id":"a"},{"type":"function_call","call_id":"b"}]}`)
+	out, dropped = LimitToolCalls("openai-response", payload)
+	if output := gjson.GetBytes(out, "output").Array(); dropped != 1 || len(output) != 2 || output[1].Get("call_id").String() != "a" {
+		t.Fatalf("openai-response: dropped=%d out=%s", dropped, out)
+	}
+}
+
+func TestSingleToolCallStream(t *testing.T) {
+	stream := NewSingleToolCallStream("openai")
+	out := stream.Chunk([]byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"a","function":{"name":"Read","arguments":""}},{"index":1,"id":"b","function":{"name":"Write","arguments":""}}]}}]}`))
+	if calls := gjson.GetBytes(out, "choices.0.delta.tool_calls").Array(); len(calls) != 1 || calls[0].Get("id").String() != "a" {
+		t.Fatalf("first chunk = %s", out)
+	}
+	out = stream.Chunk([]byte(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{}"}}]}}]}`))
+	if gjson.GetBytes(out, "choices.0.delta.tool_calls").Exists() || stream.Dropped() != 1 {
+		t.Fatalf("second chunk = %s, dropped = %d", out, stream.Dropped())
+	}
+
+	stream = NewSingleToolCallStream("openai-response")
+	events := []string{
+		"event: response.output_item.added\ndata: {\"type\":\"response.output_item.added\",\"output_index\":0,\"item\":{\"type\":\"function_call\"}}",
+		"event: response.output_item.added\ndata: {\"type\":\"response.output_item.added\",\"output_index\":1,\"item\":{\"type\":\"function_call\"}}",
+		"event: response.function_call_arguments.delta\ndata: {\"type\":\"response.function_call_arguments.delta\",\"output_index\":1,\"delta\":\"{}\"}",
+		"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"output\":[{\"type\":\"function_call\"},{\"type\":\"function_call\"}]}}",
+	}
+	var kept []string
+	for _, event := range events {
+		if out := stream.Chunk([]byte(event)); len(out) > 0 {
+			kept = append(kept, string(out))
+		}
+	}
+	if len(kept) != 2 || !strings.Contains(kept[0], `"output_index":0`) || strings.Count(kept[1], "function_call") != 1 {
+		t.Fatalf("kept events = %q", kept)
+	}
+}
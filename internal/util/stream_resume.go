@@ -0,0 +1,212 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// StreamResume follows a streamed claude or openai text response so it can be
+// continued when the upstream stops before the end of the message. It records
+// the text the client received, builds the continuation request and stitches
+// the continuation onto the stream: the repeated message preamble is dropped,
+// continued text joins the open text block and later blocks are renumbered.
+// A nil StreamResume passes chunks through unchanged.
+type StreamResume struct {
+	format      string
+	text        strings.Builder
+	terminated  bool
+	unresumable bool
+	resumes     int
+
+	// Claude content blocks as seen by the client.
+	openText  int64
+	openOther int
+	nextIndex int64
+
+	// Stitching of the current continuation.
+	continuing bool
+	merged     bool
+	indexes    map[int64]int64
+	dropped    map[int64]struct{}
+
+	// OpenAI chunk id of the original stream.
+	id string
+}
+
+// NewStreamResume returns a tracker for a stream in the given format, or nil
+// for formats that cannot be resumed.
+func NewStreamResume(format string) *StreamResume {
+	if format != "claude" && format != "openai" {
+		return nil
+	}
+	return &StreamResume{format: format, openText: -1}
+}
+
+// Resumes returns the number of continuations started so far.
+func (s *StreamResume) Resumes() int {
+	if s == nil {
+		return 0
+	}
+	return s.resumes
+}
+
+// TextLength returns the number of bytes of assistant text received so far.
+func (s *StreamResume) TextLength() int {
+	if s == nil {
+		return 0
+	}
+	return s.text.Len()
+}
+
+// Interrupted reports whether the stream has not reached its end yet and can
+// be continued: it carries no tool call and stopped outside of a reasoning block.
+func (s *StreamResume) Interrupted() bool {
+	return s != nil && !s.terminated && !s.unresumable && s.openOther == 0
+}
+
+// Continue returns the request that continues the interrupted response: the
+// original request followed by the text received so far as an assistant turn
+// and a user turn carrying hint. Subsequent chunks are stitched onto the
+// stream. It returns false when the stream cannot be continued.
+func (s *StreamResume) Continue(request []byte, hint string) ([]byte, bool) {
+	if !s.Interrupted() {
+		return nil, false
+	}
+	messages := gjson.GetBytes(request, "messages").Array()
+	if len(messages) == 0 || messages[len(messages)-1].Get("role").String() == "assistant" {
+		return nil, false
+	}
+	out := request
+	if text := s.text.String(); text != "" {
+		var err error
+		if out, err = sjson.SetBytes(out, "messages.-1", map[string]any{"role": "assistant", "content": text}); err != nil {
+			return nil, false
+		}
+		if out, err = sjson.SetBytes(out, "messages.-1", map[string]any{"role": "user", "content": hint}); err != nil {
+			return nil, false
+		}
+	}
+	s.resumes++
+	s.continuing, s.merged = true, false
+	s.indexes = make(map[int64]int64)
+	s.dropped = make(map[int64]struct{})
+	return out, true
+}
+
+// Chunk stitches a stream chunk, given either as a bare JSON event or as SSE
+// lines, and records what it carries. It returns an empty slice when nothing
+// of the chunk is left.
+func (s *StreamResume) Chunk(payload []byte) []byte {
+	if s == nil {
+		return payload
+	}
+	return filterStreamEvents(payload, func(event []byte) ([]byte, bool) {
+		if s.continuing {
+			var keep bool
+			if event, keep = s.stitch(event); !keep {
+				return event, false
+			}
+		}
+		s.observe(event)
+		return event, true
+	})
+}
+
+func (s *StreamResume) stitch(event []byte) ([]byte, bool) {
+	root := gjson.ParseBytes(event)
+	switch s.format {
+	case "claude":
+		index := root.Get("index").Int()
+		switch root.Get("type").String() {
+		case "message_start":
+			return event, false
+		case "content_block_start":
+			switch root.Get("content_block.type").String() {
+			case "thinking", "redacted_thinking":
+				s.dropped[index] = struct{}{}
+				return event, false
+			case "text":
+				if s.openText >= 0 && !s.merged {
+					s.merged = true
+					s.indexes[index] = s.openText
+					return event, false
+				}
+			}
+			s.indexes[index] = s.nextIndex
+		case "content_block_delta", "content_block_stop":
+			if _, drop := s.dropped[index]; drop {
+				return event, false
+			}
+		default:
+			return event, true
+		}
+		if mapped, ok := s.indexes[index]; ok && mapped != index {
+			event, _ = sjson.SetBytes(event, "index", mapped)
+		}
+		return event, true
+	case "openai":
+		if s.id != "" && root.Get("id").Exists() {
+			event, _ = sjson.SetBytes(event, "id", s.id)
+		}
+		if root.Get("choices.0.delta.role").Exists() {
+			event, _ = sjson.DeleteBytes(event, "choices.0.delta.role")
+		}
+		return event, true
+	}
+	return event, true
+}
+
+func (s *StreamResume) observe(event []byte) {
+	root := gjson.ParseBytes(event)
+	switch s.format {
+	case "claude":
+		index := root.Get("index").Int()
+		switch root.Get("type").String() {
+		case "content_block_start":
+			s.nextIndex = max(s.nextIndex, index+1)
+			switch root.Get("content_block.type").String() {
+			case "text":
+				s.openText = index
+			case "thinking", "redacted_thinking":
+				s.openOther++
+			default:
+				s.unresumable = true
+			}
+		case "content_block_delta":
+			if root.Get("delta.type").String() == "text_delta" {
+				s.text.WriteString(root.Get("delta.text").String())
+			}
+		case "content_block_stop":
+			if index == s.openText {
+				s.openText = -1
+			} else if s.openOther > 0 {
+				s.openOther--
+			}
+		case "message_delta":
+			if root.Get("delta.stop_reason").String() != "" {
+				s.terminated = true
+			}
+		case "message_stop":
+			s.terminated = true
+		}
+	case "openai":
+		if s.id == "" {
+			s.id = root.Get("id").String()
+		}
+		choices := root.Get("choices").Array()
+		if len(choices) > 1 {
+			s.unresumable = true
+		}
+		for _, choice := range choices {
+			if choice.Get("delta.tool_calls").Exists() {
+				s.unresumable = true
+			}
+			s.text.WriteString(choice.Get("delta.content").String())
+			if choice.Get("finish_reason").String() != "" {
+				s.terminated = true
+			}
+		}
+	}
+}
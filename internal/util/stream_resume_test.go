@@ -0,0 +1,82 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestStreamResumeClaude(t *testing.T) {
+	s := NewStreamResume("claude")
+	for _, event := range []string{
+		`{"type":"message_start","message":{"id":"msg_1"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hello, wor"}}`,
+	} {
+		if out := s.Chunk([]byte(event)); string(out) != event {
+			t.Fatalf("original stream changed: %s", out)
+		}
+	}
+	if !s.Interrupted() {
+		t.Fatal("stream without message_stop not reported as interrupted")
+	}
+	request := []byte(`{"messages":[{"role":"user","content":"greet"}]}`)
+	next, ok := s.Continue(request, "continue")
+	if !ok {
+		t.Fatal("stream not resumable")
+	}
+	if gjson.GetBytes(next, "messages.1.role").String() != "assistant" || gjson.GetBytes(next, "messages.1.content").String() != "Hello, wor" ||
+		gjson.GetBytes(next, "messages.2.content").String() != "continue" {
+		t.Fatalf("unexpected continuation request: %s", next)
+	}
+
+	var got []string
+	for _, event := range []string{
+		`{"type":"message_start","message":{"id":"msg_2"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hm"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"ld!"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+		`{"type":"message_stop"}`,
+	} {
+		if out := s.Chunk([]byte(event)); len(out) > 0 {
+			got = append(got, gjson.GetBytes(out, "type").String()+":"+gjson.GetBytes(out, "index").String())
+		}
+	}
+	want := "content_block_delta:1 content_block_stop:1 content_block_start:2 content_block_stop:2 message_delta: message_stop:"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("stitched events = %v, want %s", got, want)
+	}
+	if s.Interrupted() || s.TextLength() != len("Hello, world!") {
+		t.Fatalf("stitched stream state wrong: interrupted=%v text=%d", s.Interrupted(), s.TextLength())
+	}
+}
+
+func TestStreamResumeOpenAIToolCall(t *testing.T) {
+	s := NewStreamResume("openai")
+	s.Chunk([]byte(`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"t1","function":{"name":"f","arguments":""}}]}}]}`))
+	if s.Interrupted() {
+		t.Fatal("stream with a tool call must not be resumed")
+	}
+
+	s = NewStreamResume("openai")
+	s.Chunk([]byte(`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`))
+	if _, ok := s.Continue([]byte(`{"messages":[{"role":"user","content":"x"}]}`), "continue"); !ok {
+		t.Fatal("text stream not resumable")
+	}
+	out := s.Chunk([]byte(`{"id":"c2","choices":[{"index":0,"delta":{"role":"assistant","content":" there"},"finish_reason":"stop"}]}`))
+	if gjson.GetBytes(out, "id").String() != "c1" || gjson.GetBytes(out, "choices.0.delta.role").Exists() {
+		t.Fatalf("continuation chunk not stitched: %s", out)
+	}
+	if s.Interrupted() {
+		t.Fatal("finished stream reported as interrupted")
+	}
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Targets of system messages found in the middle of a transcript.
+const (
+	// MidTranscriptSystemOff leaves them where they are.
+	MidTranscriptSystemOff = "off"
+	// MidTranscriptSystemToSystem moves them, in order, into a labeled block
+	// appended to the system prompt.
+	MidTranscriptSystemToSystem = "system"
+	// MidTranscriptSystemToUser moves each into a labeled block at the start
+	// of the user turn that follows it, falling back to the system prompt when
+	// no user turn follows.
+	MidTranscriptSystemToUser = "user"
+)
+
+// midTranscriptSystemHeader introduces the block appended to the system prompt.
+const midTranscriptSystemHeader = "The following system messages were given later in the conversation, in order:"
+
+// MergeMidTranscriptSystem moves the system and developer messages of a claude
+// or openai history that follow the first user or assistant turn out of the
+// history, as target asks. Leading system messages stay in place. It returns
+// the payload and the number of messages moved; other formats and targets
+// are returned unchanged.
+func MergeMidTranscriptSystem(format string, payload []byte, target string) ([]byte, int) {
+	if (format != "claude" && format != "openai") || (target != MidTranscriptSystemToSystem && target != MidTranscriptSystemToUser) {
+		return payload, 0
+	}
+	messages := gjson.GetBytes(payload, "messages")
+	if !messages.IsArray() {
+		return payload, 0
+	}
+	var (
+		out      []string
+		toSystem []string
+		pending  []string
+		moved    int
+		started  bool
+	)
+	flush := func(i int) {
+		if len(pending) == 0 {
+			return
+		}
+		var block strings.Builder
+		for _, text := range pending {
+			fmt.Fprintf(&block, "[System message]\n%s\n\n", text)
+		}
+		out[i] = prependUserText(format, gjson.Parse(out[i]), strings.TrimRight(block.String(), "\n"))
+		pending = nil
+	}
+	for _, message := range messages.Array() {
+		role := message.Get("role").String()
+		if role != "system" && role != "developer" {
+			started = true
+			out = append(out, message.Raw)
+			if role == "user" {
+				flush(len(out) - 1)
+			}
+			continue
+		}
+		if !started {
+			out = append(out, message.Raw)
+			continue
+		}
+		moved++
+		text := strings.TrimSpace(systemMessageText(message.Get("content")))
+		if text == "" {
+			continue
+		}
+		if target == MidTranscriptSystemToUser {
+			pending = append(pending, text)
+		} else {
+			toSystem = append(toSystem, text)
+		}
+	}
+	if moved == 0 {
+		return payload, 0
+	}
+	toSystem = append(toSystem, pending...)
+	updated, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, 0
+	}
+	if len(toSystem) > 0 {
+		var block strings.Builder
+		block.WriteString(midTranscriptSystemHeader)
+		for i, text := range toSystem {
+			fmt.Fprintf(&block, "\n\n[System message %d]\n%s", i+1, text)
+		}
+		if withSystem, ok := AppendSystemText(format, updated, block.String()); ok {
+			updated = withSystem
+		}
+	}
+	return updated, moved
+}
+
+// prependUserText puts text at the start of a user turn, after any claude
+// tool_result blocks, which must open the turn.
+func prependUserText(format string, message gjson.Result, text string) string {
+	content := message.Get("content")
+	if content.Type == gjson.String || !content.Exists() {
+		joined := text
+		if existing := content.String(); strings.TrimSpace(existing) != "" {
+			joined += "\n\n" + existing
+		}
+		updated, _ := sjson.Set(message.Raw, "content", joined)
+		return updated
+	}
+	if !content.IsArray() {
+		return message.Raw
+	}
+	textBlock, _ := sjson.Set(`{"type":"text"}`, "text", text)
+	blocks := content.Array()
+	insertAt := 0
+	if format == "claude" {
+		for insertAt < len(blocks) && blocks[insertAt].Get("type").String() == "tool_result" {
+			insertAt++
+		}
+	}
+	raws := make([]string, 0, len(blocks)+1)
+	for i, block := range blocks {
+		if i == insertAt {
+			raws = append(raws, textBlock)
+		}
+		raws = append(raws, block.Raw)
+	}
+	if insertAt == len(blocks) {
+		raws = append(raws, textBlock)
+	}
+	updated, _ := sjson.SetRaw(message.Raw, "content", "["+strings.Join(raws, ",")+"]")
+	return updated
+}
+
+// systemMessageText returns the text of a string content or the joined text
+// blocks of a block list.
+func systemMessageText(content gjson.Result) string {
+	if !content.IsArray() {
+		return content.String()
+	}
+	var texts []string
+	for _, block := range content.Array() {
+		if text := block.Get("text"); text.Exists() {
+			texts = append(texts, text.String())
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestMergeMidTranscriptSystemToSystemPrompt(t *testing.T) {
+	payload := []byte(`{"messages":[
+		{"role":"system","content":"You are terse."},
+		{"role":"user","content":"hi"},
+		{"role":"system","content":"Answer in French."},
+		{"role":"assistant","content":"Bonjour"},
+		{"role":"developer","content":[{"type":"text","text":"Use metric units."}]},
+		{"role":"user","content":"how far is it?"}
+	]}`)
+
+	out, moved := MergeMidTranscriptSystem("openai", payload, MidTranscriptSystemToSystem)
+	if moved != 2 {
+		t.Fatalf("moved = %d, want 2", moved)
+	}
+	roles := gjson.GetBytes(out, "messages.#.role").Raw
+	if roles != `["system","system","user","assistant","user"]` {
+		t.Fatalf("roles = %s", roles)
+	}
+	block := gjson.GetBytes(out, "messages.1.content").String()
+	first, second := strings.Index(block, "[System message 1]\nAnswer in French."), strings.Index(block, "[System message 2]\nUse metric units.")
+	if !strings.HasPrefix(block, midTranscriptSystemHeader) || first < 0 || second < first {
+		t.Fatalf("directives block = %q", block)
+	}
+
+	if out, moved = MergeMidTranscriptSystem("openai", payload, MidTranscriptSystemOff); moved != 0 || string(out) != string(payload) {
+		t.Fatal("off changed the payload")
+	}
+}
+
+func TestMergeMidTranscriptSystemToNextUserTurn(t *testing.T) {
+	payload := []byte(`{"system":"base","messages":[
+		{"role":"user","content":"hi"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{}}]},
+		{"role":"system","content":"Be careful."},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]},
+		{"role":"assistant","content":"done"},
+		{"role":"system","content":"Summarize next."}
+	]}`)
+
+	out, moved := MergeMidTranscriptSystem("claude", payload, MidTranscriptSystemToUser)
+	if moved != 2 {
+		t.Fatalf("moved = %d, want 2", moved)
+	}
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 4 {
+		t.Fatalf("messages = %s", gjson.GetBytes(out, "messages").Raw)
+	}
+	if got := messages[2].Get("content.#.type").Raw; got != `["tool_result","text"]` {
+		t.Fatalf("user blocks = %s, want the tool_result first", got)
+	}
+	if got := messages[2].Get("content.1.text").String(); got != "[System message]\nBe careful." {
+		t.Fatalf("directive = %q", got)
+	}
+	system := gjson.GetBytes(out, "system").String()
+	if !strings.HasPrefix(system, "base\n\n"+midTranscriptSystemHeader) || !strings.HasSuffix(system, "[System message 1]\nSummarize next.") {
+		t.Fatalf("system = %q, want the trailing message appended", system)
+	}
+}
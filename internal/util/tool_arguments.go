@@ -0,0 +1,106 @@
+package util
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// IncrementalToolArgumentsKey is the context key under which request handlers
+// ask stream translators to forward tool call arguments as they arrive instead
+// of buffering them until the tool call is complete.
+const IncrementalToolArgumentsKey = "incremental_tool_arguments"
+
+// IncrementalToolArguments reports whether ctx requests incremental tool
+// argument streaming.
+func IncrementalToolArguments(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	enabled, _ := ctx.Value(IncrementalToolArgumentsKey).(bool)
+	return enabled
+}
+
+// CloseJSONSuffix returns the text to append to streamed tool arguments so the
+// complete arguments form valid JSON. Fragments already sent to the client
+// cannot be rewritten, so truncated input is completed by terminating an open
+// string, filling a dangling value and closing open objects and arrays. Empty
+// input becomes "{}". The boolean reports whether the result is valid JSON.
+func CloseJSONSuffix(partial string) (string, bool) {
+	if strings.TrimSpace(partial) == "" {
+		return "{}", true
+	}
+	if gjson.Valid(partial) {
+		return "", true
+	}
+
+	var open []byte
+	inString, escaped := false, false
+	for i := 0; i < len(partial); i++ {
+		c := partial[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			open = append(open, '}')
+		case '[':
+			open = append(open, ']')
+		case '}', ']':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+
+	var suffix strings.Builder
+	if inString {
+		if escaped {
+			suffix.WriteByte('\\')
+		}
+		suffix.WriteByte('"')
+	}
+	trimmed := strings.TrimRight(partial+suffix.String(), " \t\r\n")
+	if len(open) > 0 && open[len(open)-1] == '}' && strings.HasSuffix(trimmed, `"`) && expectsObjectValue(trimmed) {
+		suffix.WriteString(":null")
+	} else if strings.HasSuffix(trimmed, ":") {
+		suffix.WriteString("null")
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		suffix.WriteByte(open[i])
+	}
+	return suffix.String(), gjson.Valid(partial + suffix.String())
+}
+
+// expectsObjectValue reports whether s ends with an object key that has no
+// value yet, i.e. the last string token follows '{' or ','.
+func expectsObjectValue(s string) bool {
+	// Find the opening quote of the final string token.
+	end := len(s) - 1
+	for i := end - 1; i >= 0; i-- {
+		if s[i] != '"' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && s[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			continue
+		}
+		before := strings.TrimRight(s[:i], " \t\r\n")
+		return strings.HasSuffix(before, "{") || strings.HasSuffix(before, ",")
+	}
+	return false
+}
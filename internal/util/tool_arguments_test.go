@@ -0,0 +1,26 @@
+package util
+
+import "testing"
+
+func TestCloseJSONSuffix(t *testing.T) {
+	cases := []struct {
+		partial string
+		suffix  string
+		valid   bool
+	}{
+		{``, `{}`, true},
+		{`{"command":"ls"}`, ``, true},
+		{`{"command":"ls -la`, `"}`, true},
+		{`{"paths":["a","b"`, `]}`, true},
+		{`{"a":1,"b":`, `null}`, true},
+		{`{"a":1,"ke`, `":null}`, true},
+		{`{"text":"ends with \`, `\"}`, true},
+		{`{'a': 1`, `}`, false},
+	}
+	for _, tc := range cases {
+		suffix, valid := CloseJSONSuffix(tc.partial)
+		if suffix != tc.suffix || valid != tc.valid {
+			t.Errorf("CloseJSONSuffix(%q) = %q, %v; want %q, %v", tc.partial, suffix, valid, tc.suffix, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ToolChoiceNone reports whether a claude, openai or openai-response request
+// forbids tool calls with tool_choice "none".
+func ToolChoiceNone(format string, request []byte) bool {
+	choice := gjson.GetBytes(request, "tool_choice")
+	switch format {
+	case "claude":
+		return choice.Get("type").String() == "none"
+	case "openai", "openai-response":
+		return choice.Type == gjson.String && choice.String() == "none"
+	default:
+		return false
+	}
+}
+
+// StripTools removes the tool declarations and tool_choice of a request that
+// forbids tool calls, so upstreams that ignore tool_choice "none" cannot call
+// them. Transcripts that already contain tool calls or results keep their
+// tools: once translated, Claude and Gemini reject tool history without
+// declarations. It reports whether the request was changed.
+func StripTools(format string, request []byte) ([]byte, bool) {
+	if !gjson.GetBytes(request, "tools").Exists() || hasToolHistory(format, request) {
+		return request, false
+	}
+	out, err := sjson.DeleteBytes(request, "tools")
+	if err != nil {
+		return request, false
+	}
+	if updated, errDelete := sjson.DeleteBytes(out, "tool_choice"); errDelete == nil {
+		out = updated
+	}
+	return out, true
+}
+
+// hasToolHistory reports whether a claude, openai or openai-response request
+// contains an earlier tool call or tool result.
+func hasToolHistory(format string, request []byte) bool {
+	found := false
+	switch format {
+	case "claude":
+		gjson.GetBytes(request, "messages").ForEach(func(_, message gjson.Result) bool {
+			message.Get("content").ForEach(func(_, block gjson.Result) bool {
+				if t := block.Get("type").String(); t == "tool_use" || t == "tool_result" {
+					found = true
+				}
+				return !found
+			})
+			return !found
+		})
+	case "openai":
+		gjson.GetBytes(request, "messages").ForEach(func(_, message gjson.Result) bool {
+			role := message.Get("role").String()
+			found = role == "tool" || role == "function" ||
+				len(message.Get("tool_calls").Array()) > 0 || message.Get("function_call").Exists()
+			return !found
+		})
+	case "openai-response":
+		gjson.GetBytes(request, "input").ForEach(func(_, item gjson.Result) bool {
+			switch item.Get("type").String() {
+			case "function_call", "function_call_output", "custom_tool_call", "custom_tool_call_output":
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// DropToolCalls removes every tool call from a non-streaming claude, openai or
+// openai-response response and reports a plain stop instead of a tool call
+// stop. It returns the payload and the number of calls dropped.
+func DropToolCalls(format string, payload []byte) ([]byte, int) {
+	switch format {
+	case "openai":
+		dropped := 0
+		for i, choice := range gjson.GetBytes(payload, "choices").Array() {
+			calls := choice.Get("message.tool_calls").Array()
+			if len(calls) == 0 {
+				continue
+			}
+			updated, err := sjson.DeleteBytes(payload, fmt.Sprintf("choices.%d.message.tool_calls", i))
+			if err != nil {
+				continue
+			}
+			if choice.Get("finish_reason").String() == "tool_calls" {
+				updated, _ = sjson.SetBytes(updated, fmt.Sprintf("choices.%d.finish_reason", i), "stop")
+			}
+			payload = updated
+			dropped += len(calls)
+		}
+		return payload, dropped
+	case "claude":
+		out, dropped := dropItems(payload, "content", "tool_use")
+		if dropped > 0 && gjson.GetBytes(out, "stop_reason").String() == "tool_use" {
+			out, _ = sjson.SetBytes(out, "stop_reason", "end_turn")
+		}
+		return out, dropped
+	case "openai-response":
+		return dropItems(payload, "output", "function_call")
+	default:
+		return payload, 0
+	}
+}
+
+// dropItems removes the items of the given type from the array at path.
+func dropItems(payload []byte, path, itemType string) ([]byte, int) {
+	items := gjson.GetBytes(payload, path).Array()
+	kept := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Get("type").String() != itemType {
+			kept = append(kept, item.Raw)
+		}
+	}
+	if len(kept) == len(items) {
+		return payload, 0
+	}
+	updated, err := sjson.SetRawBytes(payload, path, []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return payload, 0
+	}
+	return updated, len(items) - len(kept)
+}
+
+// NoToolCallStream applies DropToolCalls to a streamed claude, openai or
+// openai-response response.
+type NoToolCallStream struct {
+	format string
+	// dropped holds the content block or output indexes of removed calls.
+	dropped map[int64]struct{}
+	total   int
+}
+
+// NewNoToolCallStream returns a filter for a stream in the given format.
+func NewNoToolCallStream(format string) *NoToolCallStream {
+	return &NoToolCallStream{format: format, dropped: make(map[int64]struct{})}
+}
+
+// Dropped returns the number of tool calls removed so far.
+func (s *NoToolCallStream) Dropped() int {
+	if s == nil {
+		return 0
+	}
+	return s.total
+}
+
+// Chunk filters one stream chunk, given either as a bare JSON event or as SSE
+// lines. It returns an empty slice when nothing of the chunk is left.
+func (s *NoToolCallStream) Chunk(payload []byte) []byte {
+	if s == nil {
+		return payload
+	}
+	return filterStreamEvents(payload, s.event)
+}
+
+// event filters one JSON event and reports whether it should be kept.
+func (s *NoToolCallStream) event(payload []byte) ([]byte, bool) {
+	root := gjson.ParseBytes(payload)
+	switch s.format {
+	case "openai":
+		out := payload
+		for i, choice := range root.Get("choices").Array() {
+			for _, call := range choice.Get("delta.tool_calls").Array() {
+				if call.Get("id").String() != "" || call.Get("function.name").String() != "" {
+					s.total++
+				}
+			}
+			if choice.Get("delta.tool_calls").Exists() {
+				out, _ = sjson.DeleteBytes(out, fmt.Sprintf("choices.%d.delta.tool_calls", i))
+			}
+			if choice.Get("finish_reason").String() == "tool_calls" {
+				out, _ = sjson.SetBytes(out, fmt.Sprintf("choices.%d.finish_reason", i), "stop")
+			}
+		}
+		return out, true
+	case "claude":
+		switch root.Get("type").String() {
+		case "content_block_start":
+			if root.Get("content_block.type").String() == "tool_use" {
+				s.dropped[root.Get("index").Int()] = struct{}{}
+				s.total++
+				return payload, false
+			}
+		case "content_block_delta", "content_block_stop":
+			if _, drop := s.dropped[root.Get("index").Int()]; drop {
+				return payload, false
+			}
+		case "message_delta":
+			if root.Get("delta.stop_reason").String() == "tool_use" {
+				out, _ := sjson.SetBytes(payload, "delta.stop_reason", "end_turn")
+				return out, true
+			}
+		}
+		return payload, true
+	case "openai-response":
+		switch root.Get("type").String() {
+		case "response.output_item.added":
+			if root.Get("item.type").String() == "function_call" {
+				s.dropped[root.Get("output_index").Int()] = struct{}{}
+				s.total++
+				return payload, false
+			}
+		case "response.completed", "response.incomplete":
+			out, _ := dropItems(payload, "response.output", "function_call")
+			return out, true
+		}
+		if index := root.Get("output_index"); index.Exists() {
+			if _, drop := s.dropped[index.Int()]; drop {
+				return payload, false
+			}
+		}
+		return payload, true
+	default:
+		return payload, true
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestStripTools(t *testing.T) {
+	request := []byte(`{"tools":[{"name":"Bash"}],"tool_choice":{"type":"none"},"messages":[{"role":"user","content":"hi"}]}`)
+	if !ToolChoiceNone("claude", request) || ToolChoiceNone("openai", request) {
+		t.Fatalf("tool_choice none not detected per format")
+	}
+	out, stripped := StripTools("claude", request)
+	if !stripped || gjson.GetBytes(out, "tools").Exists() || gjson.GetBytes(out, "tool_choice").Exists() {
+		t.Fatalf("tools not stripped: %s", out)
+	}
+
+	history := []byte(`{"tools":[{"name":"Bash"}],"tool_choice":{"type":"none"},"messages":[{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{}}]},{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}]}`)
+	if _, stripped = StripTools("claude", history); stripped {
+		t.Fatalf("tools stripped from a transcript with tool history")
+	}
+}
+
+func TestStripToolsKeepsToolsWithOpenAIToolHistory(t *testing.T) {
+	cases := []struct{ format, request string }{
+		{"openai", `{"tools":[{"type":"function","function":{"name":"Bash"}}],"tool_choice":"none","messages":[{"role":"user","content":"run"},{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"Bash","arguments":"{}"}}]},{"role":"tool","tool_call_id":"c1","content":"ok"}]}`},
+		{"openai", `{"tools":[{"type":"function","function":{"name":"Bash"}}],"tool_choice":"none","messages":[{"role":"tool","tool_call_id":"c1","content":"ok"}]}`},
+		{"openai-response", `{"tools":[{"type":"function","name":"Bash"}],"tool_choice":"none","input":[{"role":"user","content":"run"},{"type":"function_call","call_id":"c1","name":"Bash","arguments":"{}"},{"type":"function_call_output","call_id":"c1","output":"ok"}]}`},
+	}
+	for _, tc := range cases {
+		if !ToolChoiceNone(tc.format, []byte(tc.request)) {
+			t.Fatalf("%s: tool_choice none not detected", tc.format)
+		}
+		if _, stripped := StripTools(tc.format, []byte(tc.request)); stripped {
+			t.Fatalf("%s: tools stripped from a transcript with tool history: %s", tc.format, tc.request)
+		}
+	}
+
+	for format, request := range map[string]string{
+		"openai":          `{"tools":[{"type":"function","function":{"name":"Bash"}}],"tool_choice":"none","messages":[{"role":"user","content":"hi"}]}`,
+		"openai-response": `{"tools":[{"type":"function","name":"Bash"}],"tool_choice":"none","input":[{"role":"user","content":"hi"}]}`,
+	} {
+		if out, stripped := StripTools(format, []byte(request)); !stripped || gjson.GetBytes(out, "tools").Exists() {
+			t.Fatalf("%s: tools kept without tool history: %s", format, out)
+		}
+	}
+}
+
+func TestDropToolCalls(t *testing.T) {
+	claude := []byte(`{"content":[{"type":"text","text":"hi"},{"type":"tool_use","id":"t1","name":"Bash","input":{}}],"stop_reason":"tool_use"}`)
+	out, dropped := DropToolCalls("claude", claude)
+	if dropped != 1 || gjson.GetBytes(out, "content.#").Int() != 1 || gjson.GetBytes(out, "stop_reason").String() != "end_turn" {
+		t.Fatalf("claude: dropped %d: %s", dropped, out)
+	}
+
+	openai := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","content":"hi","tool_calls":[{"id":"c1","type":"function","function":{"name":"Bash","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`)
+	out, dropped = DropToolCalls("openai", openai)
+	if dropped != 1 || gjson.GetBytes(out, "choices.0.message.tool_calls").Exists() || gjson.GetBytes(out, "choices.0.finish_reason").String() != "stop" {
+		t.Fatalf("openai: dropped %d: %s", dropped, out)
+	}
+}
+
+func TestNoToolCallStream(t *testing.T) {
+	stream := NewNoToolCallStream("claude")
+	chunks := []string{
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"t1\",\"name\":\"Bash\",\"input\":{}}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n",
+	}
+	var out strings.Builder
+	for _, chunk := range chunks {
+		out.Write(stream.Chunk([]byte(chunk)))
+	}
+	got := out.String()
+	if stream.Dropped() != 1 || strings.Contains(got, "tool_use\"") || strings.Contains(got, "\"index\":1") || !strings.Contains(got, "end_turn") {
+		t.Fatalf("dropped %d, stream:\n%s", stream.Dropped(), got)
+	}
+	if !strings.Contains(got, "\"index\":0") {
+		t.Fatalf("text block removed:\n%s", got)
+	}
+}
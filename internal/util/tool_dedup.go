@@ -0,0 +1,159 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// toolCall is the identity of one tool call in a response.
+type toolCall struct {
+	id   string
+	name string
+	args string
+	raw  string
+}
+
+// toolCallGroup is a set of calls that refer to the same invocation.
+type toolCallGroup struct {
+	// first is the index of the earliest call of the group; the merged call is
+	// emitted at its position so upstream order is preserved.
+	first int
+	// chosen is the index of the most complete call of the group.
+	chosen int
+}
+
+// groupToolCalls merges duplicate tool calls. Calls sharing a tool call ID are
+// the same invocation. A call without an ID is merged into an earlier call of
+// the same tool only when one of their arguments is an unfinished prefix of the
+// other, which is how a re-emitted partial call looks; identical complete calls
+// without IDs are kept, since a model may legitimately repeat a call.
+func groupToolCalls(calls []toolCall) []toolCallGroup {
+	var groups []toolCallGroup
+	byID := make(map[string]int)
+	for i, call := range calls {
+		g := -1
+		if call.id != "" {
+			if existing, ok := byID[call.id]; ok {
+				g = existing
+			}
+		} else {
+			for gi := range groups {
+				other := calls[groups[gi].chosen]
+				if other.name == call.name && partialOf(other.args, call.args) {
+					g = gi
+					break
+				}
+			}
+		}
+		if g < 0 {
+			if call.id != "" {
+				byID[call.id] = len(groups)
+			}
+			groups = append(groups, toolCallGroup{first: i, chosen: i})
+			continue
+		}
+		if moreComplete(call.args, calls[groups[g].chosen].args) {
+			groups[g].chosen = i
+		}
+	}
+	return groups
+}
+
+// partialOf reports whether one of a and b is an unfinished prefix of the other.
+func partialOf(a, b string) bool {
+	if a == b {
+		return false
+	}
+	shorter, longer := a, b
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+	return strings.HasPrefix(longer, shorter) && !gjson.Valid(shorter)
+}
+
+// moreComplete reports whether candidate arguments should replace current:
+// valid JSON wins over invalid, and among invalid arguments the longer wins.
+func moreComplete(candidate, current string) bool {
+	candidateValid, currentValid := gjson.Valid(candidate), gjson.Valid(current)
+	if candidateValid != currentValid {
+		return candidateValid
+	}
+	return !currentValid && len(candidate) > len(current)
+}
+
+// dedupeToolCallList rewrites the JSON array at path, merging duplicate tool
+// calls selected by isCall. It returns the payload and the number of calls removed.
+func dedupeToolCallList(payload []byte, path string, isCall func(gjson.Result) bool, identify func(gjson.Result) toolCall) ([]byte, int) {
+	items := gjson.GetBytes(payload, path).Array()
+	var calls []toolCall
+	callIndex := make([]int, len(items))
+	for i, item := range items {
+		callIndex[i] = -1
+		if isCall(item) {
+			call := identify(item)
+			call.raw = item.Raw
+			callIndex[i] = len(calls)
+			calls = append(calls, call)
+		}
+	}
+	groups := groupToolCalls(calls)
+	if len(groups) == len(calls) {
+		return payload, 0
+	}
+	emitAt := make(map[int]string, len(groups))
+	for _, g := range groups {
+		emitAt[g.first] = calls[g.chosen].raw
+	}
+	out := make([]string, 0, len(items))
+	for i, item := range items {
+		if callIndex[i] < 0 {
+			out = append(out, item.Raw)
+		} else if raw, ok := emitAt[callIndex[i]]; ok {
+			out = append(out, raw)
+		}
+	}
+	updated, err := sjson.SetRawBytes(payload, path, []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, 0
+	}
+	return updated, len(calls) - len(groups)
+}
+
+// DedupeToolCalls removes duplicated tool calls from a non-streaming response
+// in the given schema (claude, openai or openai-response). Calls are matched by
+// their tool call ID, with a fallback for ID-less partial re-emissions (see
+// groupToolCalls); the most complete copy is kept at the position where the
+// call first appeared. It returns the payload and the number of calls removed.
+func DedupeToolCalls(format string, payload []byte) ([]byte, int) {
+	switch format {
+	case "claude":
+		return dedupeToolCallList(payload, "content",
+			func(item gjson.Result) bool { return item.Get("type").String() == "tool_use" },
+			func(item gjson.Result) toolCall {
+				return toolCall{id: item.Get("id").String(), name: item.Get("name").String(), args: item.Get("input").Raw}
+			})
+	case "openai":
+		removed := 0
+		for i := range gjson.GetBytes(payload, "choices").Array() {
+			var n int
+			payload, n = dedupeToolCallList(payload, fmt.Sprintf("choices.%d.message.tool_calls", i),
+				func(gjson.Result) bool { return true },
+				func(item gjson.Result) toolCall {
+					return toolCall{id: item.Get("id").String(), name: item.Get("function.name").String(), args: item.Get("function.arguments").String()}
+				})
+			removed += n
+		}
+		return payload, removed
+	case "openai-response":
+		return dedupeToolCallList(payload, "output",
+			func(item gjson.Result) bool { return item.Get("type").String() == "function_call" },
+			func(item gjson.Result) toolCall {
+				return toolCall{id: item.Get("call_id").String(), name: item.Get("name").String(), args: item.Get("arguments").String()}
+			})
+	default:
+		return payload, 0
+	}
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDedupeToolCalls(t *testing.T) {
+	payload := []byte(`{"content":[` +
+		`{"type":"tool_use","id":"a","name":"Bash","input":{"command":"ls"}},` +
+		`{"type":"text","text":"again"},` +
+		`{"type":"tool_use","id":"b","name":"Bash","input":{"command":"ls"}},` +
+		`{"type":"tool_use","id":"a","name":"Bash","input":{"command":"ls"}}]}`)
+	out, removed := DedupeToolCalls("claude", payload)
+	content := gjson.GetBytes(out, "content").Array()
+	if removed != 1 || len(content) != 3 || content[0].Get("id").String() != "a" || content[2].Get("id").String() != "b" {
+		t.Fatalf("claude: removed=%d content=%s", removed, gjson.GetBytes(out, "content").Raw)
+	}
+
+	payload = []byte(`{"choices":[{"message":{"tool_calls":[` +
+		`{"id":"","function":{"name":"Read","arguments":"{\"path\":\"a"}},` +
+		`{"id":"","function":{"name":"Read","arguments":"{\"path\":\"a.go\"}"}},` +
+		`{"id":"","function":{"name":"Read","arguments":"{\"path\":\"a.go\"}"}},` +
+		`{"id":"x","function":{"name":"Write","arguments":"{\"p"}},` +
+		`{"id":"x","function":{"name":"Write","arguments":"{\"path\":\"b\"}"}}]}}]}`)
+	out, removed = DedupeToolCalls("openai", payload)
+	calls := gjson.GetBytes(out, "choices.0.message.tool_calls").Array()
+	if removed != 2 || len(calls) != 3 {
+		t.Fatalf("openai: removed=%d calls=%s", removed, gjson.GetBytes(out, "choices.0.message.tool_calls").Raw)
+	}
+	if calls[0].Get("function.arguments").String() != `{"path":"a.go"}` || calls[2].Get("function.arguments").String() != `{"path":"b"}` {
+		t.Fatalf("openai: kept incomplete arguments: %s", gjson.GetBytes(out, "choices.0.message.tool_calls").Raw)
+	}
+
+	if _, removed = DedupeToolCalls("gemini", payload); removed != 0 {
+		t.Fatalf("unsupported format removed %d calls", removed)
+	}
+}
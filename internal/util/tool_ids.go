@@ -0,0 +1,181 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ToolCallIDMaxLength is the longest tool call ID every supported upstream
+// accepts.
+const ToolCallIDMaxLength = 64
+
+var toolCallIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// SafeToolCallID reports whether id is accepted by every supported upstream:
+// letters, digits, underscores and dashes, at most ToolCallIDMaxLength
+// characters.
+func SafeToolCallID(id string) bool {
+	return id != "" && len(id) <= ToolCallIDMaxLength && !toolCallIDInvalid.MatchString(id)
+}
+
+// sanitizeToolCallID maps id to a safe ID derived from its hash, so the same
+// client ID gets the same replacement in every request of a conversation.
+func sanitizeToolCallID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return "call_" + hex.EncodeToString(sum[:12])
+}
+
+// SanitizeToolCallIDs replaces the tool call IDs of a claude, openai or
+// openai-response request that are not SafeToolCallID, e.g. "functions.read:0"
+// as some models emit, in tool calls and their results alike. It returns the
+// request and a map from each new ID to the original one, which
+// RestoreToolCallIDs uses on the response. The map is nil when no ID was
+// changed.
+func SanitizeToolCallIDs(format string, request []byte) ([]byte, map[string]string) {
+	var restore map[string]string
+	out := request
+	for _, path := range requestToolCallIDPaths(format, gjson.ParseBytes(request)) {
+		id := gjson.GetBytes(request, path).String()
+		if SafeToolCallID(id) {
+			continue
+		}
+		safe := sanitizeToolCallID(id)
+		if restore == nil {
+			restore = make(map[string]string)
+		}
+		restore[safe] = id
+		if updated, err := sjson.SetBytes(out, path, safe); err == nil {
+			out = updated
+		}
+	}
+	return out, restore
+}
+
+// requestToolCallIDPaths returns the paths of every tool call ID in a request.
+func requestToolCallIDPaths(format string, root gjson.Result) []string {
+	var paths []string
+	add := func(path string, value gjson.Result) {
+		if value.Type == gjson.String {
+			paths = append(paths, path)
+		}
+	}
+	switch format {
+	case "claude":
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			message.Get("content").ForEach(func(j, block gjson.Result) bool {
+				switch block.Get("type").String() {
+				case "tool_use":
+					add(fmt.Sprintf("messages.%d.content.%d.id", i.Int(), j.Int()), block.Get("id"))
+				case "tool_result":
+					add(fmt.Sprintf("messages.%d.content.%d.tool_use_id", i.Int(), j.Int()), block.Get("tool_use_id"))
+				}
+				return true
+			})
+			return true
+		})
+	case "openai":
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			message.Get("tool_calls").ForEach(func(j, call gjson.Result) bool {
+				add(fmt.Sprintf("messages.%d.tool_calls.%d.id", i.Int(), j.Int()), call.Get("id"))
+				return true
+			})
+			add(fmt.Sprintf("messages.%d.tool_call_id", i.Int()), message.Get("tool_call_id"))
+			return true
+		})
+	case "openai-response":
+		root.Get("input").ForEach(func(i, item gjson.Result) bool {
+			switch item.Get("type").String() {
+			case "function_call", "function_call_output":
+				add(fmt.Sprintf("input.%d.call_id", i.Int()), item.Get("call_id"))
+			}
+			return true
+		})
+	}
+	return paths
+}
+
+// RestoreToolCallIDs puts the original tool call IDs back into a
+// non-streaming response or a single stream event, given the map returned by
+// SanitizeToolCallIDs.
+func RestoreToolCallIDs(format string, payload []byte, ids map[string]string) []byte {
+	if len(ids) == 0 || !gjson.ValidBytes(payload) {
+		return payload
+	}
+	out := payload
+	for _, path := range responseToolCallIDPaths(format, gjson.ParseBytes(payload)) {
+		if original, ok := ids[gjson.GetBytes(payload, path).String()]; ok {
+			if updated, err := sjson.SetBytes(out, path, original); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out
+}
+
+// responseToolCallIDPaths returns the paths of the tool call IDs in a
+// response or stream event.
+func responseToolCallIDPaths(format string, root gjson.Result) []string {
+	var paths []string
+	switch format {
+	case "claude":
+		root.Get("content").ForEach(func(i, block gjson.Result) bool {
+			if block.Get("type").String() == "tool_use" {
+				paths = append(paths, fmt.Sprintf("content.%d.id", i.Int()))
+			}
+			return true
+		})
+		if root.Get("content_block.type").String() == "tool_use" {
+			paths = append(paths, "content_block.id")
+		}
+	case "openai":
+		root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+			for _, key := range []string{"message", "delta"} {
+				choice.Get(key + ".tool_calls").ForEach(func(j, _ gjson.Result) bool {
+					paths = append(paths, fmt.Sprintf("choices.%d.%s.tool_calls.%d.id", i.Int(), key, j.Int()))
+					return true
+				})
+			}
+			return true
+		})
+	case "openai-response":
+		for _, path := range []string{"output", "response.output"} {
+			root.Get(path).ForEach(func(i, item gjson.Result) bool {
+				if item.Get("type").String() == "function_call" {
+					paths = append(paths, fmt.Sprintf("%s.%d.call_id", path, i.Int()))
+				}
+				return true
+			})
+		}
+		if root.Get("item.type").String() == "function_call" {
+			paths = append(paths, "item.call_id")
+		}
+	}
+	return paths
+}
+
+// ToolCallIDStream applies RestoreToolCallIDs to a streamed response.
+type ToolCallIDStream struct {
+	format string
+	ids    map[string]string
+}
+
+// NewToolCallIDStream returns a rewriter for a stream in the given format.
+func NewToolCallIDStream(format string, ids map[string]string) *ToolCallIDStream {
+	return &ToolCallIDStream{format: format, ids: ids}
+}
+
+// Chunk rewrites one stream chunk, given either as a bare JSON event or as
+// SSE lines.
+func (s *ToolCallIDStream) Chunk(payload []byte) []byte {
+	if s == nil {
+		return payload
+	}
+	return filterStreamEvents(payload, func(event []byte) ([]byte, bool) {
+		return RestoreToolCallIDs(s.format, event, s.ids), true
+	})
+}
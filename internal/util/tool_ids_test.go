@@ -0,0 +1,56 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSanitizeToolCallIDsClaude(t *testing.T) {
+	request := []byte(`{"messages":[
+		{"role":"assistant","content":[{"type":"tool_use","id":"functions.read:0","name":"read","input":{}},{"type":"tool_use","id":"toolu_01","name":"ls","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"functions.read:0","content":"x"},{"type":"tool_result","tool_use_id":"toolu_01","content":"y"}]}]}`)
+
+	out, ids := SanitizeToolCallIDs("claude", request)
+	if len(ids) != 1 {
+		t.Fatalf("expected 1 replaced ID, got %v", ids)
+	}
+	safe := gjson.GetBytes(out, "messages.0.content.0.id").String()
+	if !SafeToolCallID(safe) || ids[safe] != "functions.read:0" {
+		t.Fatalf("unsafe ID not replaced: %q (%v)", safe, ids)
+	}
+	if gjson.GetBytes(out, "messages.1.content.0.tool_use_id").String() != safe {
+		t.Fatalf("tool_result not remapped: %s", out)
+	}
+	if gjson.GetBytes(out, "messages.0.content.1.id").String() != "toolu_01" {
+		t.Fatalf("safe ID changed: %s", out)
+	}
+	if again, _ := SanitizeToolCallIDs("claude", request); string(again) != string(out) {
+		t.Fatal("replacement is not stable across requests")
+	}
+
+	response := []byte(`{"content":[{"type":"tool_use","id":"` + safe + `","name":"read","input":{}}]}`)
+	if got := gjson.GetBytes(RestoreToolCallIDs("claude", response, ids), "content.0.id").String(); got != "functions.read:0" {
+		t.Fatalf("ID not restored: %q", got)
+	}
+	event := []byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"id\":\"" + safe + "\",\"name\":\"read\",\"input\":{}}}\n\n")
+	if got := string(NewToolCallIDStream("claude", ids).Chunk(event)); !strings.Contains(got, `"id":"functions.read:0"`) || strings.Contains(got, safe) {
+		t.Fatalf("stream ID not restored: %q", got)
+	}
+}
+
+func TestSanitizeToolCallIDsOpenAI(t *testing.T) {
+	request := []byte(`{"messages":[
+		{"role":"assistant","tool_calls":[{"id":"call.1","type":"function","function":{"name":"f","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"call.1","content":"ok"}]}`)
+	out, ids := SanitizeToolCallIDs("openai", request)
+	safe := gjson.GetBytes(out, "messages.0.tool_calls.0.id").String()
+	if ids[safe] != "call.1" || gjson.GetBytes(out, "messages.1.tool_call_id").String() != safe {
+		t.Fatalf("IDs not remapped: %s (%v)", out, ids)
+	}
+	response := []byte(`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"` + safe + `"}]}}]}`)
+	if got := gjson.GetBytes(RestoreToolCallIDs("openai", response, ids), "choices.0.delta.tool_calls.0.id").String(); got != "call.1" {
+		t.Fatalf("ID not restored: %q", got)
+	}
+}
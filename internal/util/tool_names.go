@@ -0,0 +1,277 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ToolNameMaxLength is the longest tool name every supported upstream accepts.
+const ToolNameMaxLength = 64
+
+var toolNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// SafeToolName reports whether name is accepted by every supported upstream:
+// letters, digits, underscores and dashes, starting with a letter or an
+// underscore, at most ToolNameMaxLength characters.
+func SafeToolName(name string) bool {
+	if name == "" || len(name) > ToolNameMaxLength || toolNameInvalid.MatchString(name) {
+		return false
+	}
+	first := name[0]
+	return first == '_' || (first >= 'a' && first <= 'z') || (first >= 'A' && first <= 'Z')
+}
+
+// sanitizeToolName maps name to a safe tool name. Distinct names may map to
+// the same result; SanitizeToolNames makes them unique.
+func sanitizeToolName(name string) string {
+	out := toolNameInvalid.ReplaceAllString(name, "_")
+	if out == "" || !(out[0] == '_' || (out[0] >= 'a' && out[0] <= 'z') || (out[0] >= 'A' && out[0] <= 'Z')) {
+		out = "_" + out
+	}
+	if len(out) > ToolNameMaxLength {
+		out = out[:ToolNameMaxLength]
+	}
+	return out
+}
+
+// SanitizeToolNames renames the tools of a claude, openai, openai-response,
+// gemini or gemini-cli request whose names are not SafeToolName, e.g. MCP
+// tools such as "server.repo/search", in declarations, tool_choice and the
+// tool calls of the history. It returns the request and a map from each new
+// name to the original one, which RestoreToolNames uses on the response. The
+// map is nil when no name was changed.
+func SanitizeToolNames(format string, request []byte) ([]byte, map[string]string) {
+	paths := requestToolNamePaths(format, gjson.ParseBytes(request))
+	used := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		if name := gjson.GetBytes(request, path).String(); SafeToolName(name) {
+			used[name] = struct{}{}
+		}
+	}
+	renamed := make(map[string]string)
+	var restore map[string]string
+	out := request
+	for _, path := range paths {
+		name := gjson.GetBytes(request, path).String()
+		if SafeToolName(name) {
+			continue
+		}
+		safe, ok := renamed[name]
+		if !ok {
+			safe = uniqueSafeToolName(sanitizeToolName(name), used)
+			used[safe] = struct{}{}
+			renamed[name] = safe
+			if restore == nil {
+				restore = make(map[string]string)
+			}
+			restore[safe] = name
+		}
+		if updated, err := sjson.SetBytes(out, path, safe); err == nil {
+			out = updated
+		}
+	}
+	return out, restore
+}
+
+func uniqueSafeToolName(candidate string, used map[string]struct{}) string {
+	if _, ok := used[candidate]; !ok {
+		return candidate
+	}
+	for i := 1; ; i++ {
+		suffix := "_" + strconv.Itoa(i)
+		base := candidate
+		if allowed := ToolNameMaxLength - len(suffix); len(base) > allowed {
+			base = base[:allowed]
+		}
+		if _, ok := used[base+suffix]; !ok {
+			return base + suffix
+		}
+	}
+}
+
+// requestToolNamePaths returns the paths of every tool name in a request.
+func requestToolNamePaths(format string, root gjson.Result) []string {
+	var paths []string
+	add := func(path string, value gjson.Result) {
+		if value.Type == gjson.String {
+			paths = append(paths, path)
+		}
+	}
+	switch format {
+	case "claude":
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			add(fmt.Sprintf("tools.%d.name", i.Int()), tool.Get("name"))
+			return true
+		})
+		if root.Get("tool_choice.type").String() == "tool" {
+			add("tool_choice.name", root.Get("tool_choice.name"))
+		}
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			message.Get("content").ForEach(func(j, block gjson.Result) bool {
+				if block.Get("type").String() == "tool_use" {
+					add(fmt.Sprintf("messages.%d.content.%d.name", i.Int(), j.Int()), block.Get("name"))
+				}
+				return true
+			})
+			return true
+		})
+	case "openai":
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			add(fmt.Sprintf("tools.%d.function.name", i.Int()), tool.Get("function.name"))
+			return true
+		})
+		add("tool_choice.function.name", root.Get("tool_choice.function.name"))
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			message.Get("tool_calls").ForEach(func(j, call gjson.Result) bool {
+				add(fmt.Sprintf("messages.%d.tool_calls.%d.function.name", i.Int(), j.Int()), call.Get("function.name"))
+				return true
+			})
+			return true
+		})
+	case "openai-response":
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			if tool.Get("type").String() == "function" {
+				add(fmt.Sprintf("tools.%d.name", i.Int()), tool.Get("name"))
+			}
+			return true
+		})
+		if root.Get("tool_choice.type").String() == "function" {
+			add("tool_choice.name", root.Get("tool_choice.name"))
+		}
+		root.Get("input").ForEach(func(i, item gjson.Result) bool {
+			if item.Get("type").String() == "function_call" {
+				add(fmt.Sprintf("input.%d.name", i.Int()), item.Get("name"))
+			}
+			return true
+		})
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if format == "gemini-cli" {
+			prefix = "request."
+			root = root.Get("request")
+		}
+		root.Get("tools").ForEach(func(i, tool gjson.Result) bool {
+			for _, key := range []string{"functionDeclarations", "function_declarations"} {
+				tool.Get(key).ForEach(func(j, decl gjson.Result) bool {
+					add(fmt.Sprintf("%stools.%d.%s.%d.name", prefix, i.Int(), key, j.Int()), decl.Get("name"))
+					return true
+				})
+			}
+			return true
+		})
+		root.Get("toolConfig.functionCallingConfig.allowedFunctionNames").ForEach(func(i, name gjson.Result) bool {
+			add(fmt.Sprintf("%stoolConfig.functionCallingConfig.allowedFunctionNames.%d", prefix, i.Int()), name)
+			return true
+		})
+		root.Get("contents").ForEach(func(i, content gjson.Result) bool {
+			content.Get("parts").ForEach(func(j, part gjson.Result) bool {
+				for _, key := range []string{"functionCall", "functionResponse"} {
+					add(fmt.Sprintf("%scontents.%d.parts.%d.%s.name", prefix, i.Int(), j.Int(), key), part.Get(key+".name"))
+				}
+				return true
+			})
+			return true
+		})
+	}
+	return paths
+}
+
+// RestoreToolNames puts the original tool names back into a non-streaming
+// response or a single stream event, given the map returned by
+// SanitizeToolNames.
+func RestoreToolNames(format string, payload []byte, names map[string]string) []byte {
+	if len(names) == 0 || !gjson.ValidBytes(payload) {
+		return payload
+	}
+	out := payload
+	for _, path := range responseToolNamePaths(format, gjson.ParseBytes(payload)) {
+		if original, ok := names[gjson.GetBytes(payload, path).String()]; ok {
+			if updated, err := sjson.SetBytes(out, path, original); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out
+}
+
+// responseToolNamePaths returns the paths of the tool names in a response or
+// stream event.
+func responseToolNamePaths(format string, root gjson.Result) []string {
+	var paths []string
+	switch format {
+	case "claude":
+		root.Get("content").ForEach(func(i, block gjson.Result) bool {
+			if block.Get("type").String() == "tool_use" {
+				paths = append(paths, fmt.Sprintf("content.%d.name", i.Int()))
+			}
+			return true
+		})
+		if root.Get("content_block.type").String() == "tool_use" {
+			paths = append(paths, "content_block.name")
+		}
+	case "openai":
+		root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+			for _, key := range []string{"message", "delta"} {
+				choice.Get(key + ".tool_calls").ForEach(func(j, _ gjson.Result) bool {
+					paths = append(paths, fmt.Sprintf("choices.%d.%s.tool_calls.%d.function.name", i.Int(), key, j.Int()))
+					return true
+				})
+			}
+			return true
+		})
+	case "openai-response":
+		for _, path := range []string{"output", "response.output"} {
+			root.Get(path).ForEach(func(i, item gjson.Result) bool {
+				if item.Get("type").String() == "function_call" {
+					paths = append(paths, fmt.Sprintf("%s.%d.name", path, i.Int()))
+				}
+				return true
+			})
+		}
+		if root.Get("item.type").String() == "function_call" {
+			paths = append(paths, "item.name")
+		}
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if format == "gemini-cli" && root.Get("response").Exists() {
+			prefix = "response."
+			root = root.Get("response")
+		}
+		root.Get("candidates").ForEach(func(i, candidate gjson.Result) bool {
+			candidate.Get("content.parts").ForEach(func(j, part gjson.Result) bool {
+				if part.Get("functionCall").Exists() {
+					paths = append(paths, fmt.Sprintf("%scandidates.%d.content.parts.%d.functionCall.name", prefix, i.Int(), j.Int()))
+				}
+				return true
+			})
+			return true
+		})
+	}
+	return paths
+}
+
+// ToolNameStream applies RestoreToolNames to a streamed response.
+type ToolNameStream struct {
+	format string
+	names  map[string]string
+}
+
+// NewToolNameStream returns a rewriter for a stream in the given format.
+func NewToolNameStream(format string, names map[string]string) *ToolNameStream {
+	return &ToolNameStream{format: format, names: names}
+}
+
+// Chunk rewrites one stream chunk, given either as a bare JSON event or as
+// SSE lines.
+func (s *ToolNameStream) Chunk(payload []byte) []byte {
+	if s == nil {
+		return payload
+	}
+	return filterStreamEvents(payload, func(event []byte) ([]byte, bool) {
+		return RestoreToolNames(s.format, event, s.names), true
+	})
+}
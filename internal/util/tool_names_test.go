@@ -0,0 +1,60 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSanitizeToolNamesClaude(t *testing.T) {
+	long := "mcp__" + strings.Repeat("x", 70)
+	request := []byte(`{"tools":[{"name":"server.repo/search"},{"name":"server_repo_search"},{"name":"` + long + `"},{"name":"Bash"}],` +
+		`"tool_choice":{"type":"tool","name":"server.repo/search"},` +
+		`"messages":[{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"server.repo/search","input":{}}]}]}`)
+
+	out, names := SanitizeToolNames("claude", request)
+	if len(names) != 2 {
+		t.Fatalf("expected 2 renamed tools, got %v", names)
+	}
+	renamed := gjson.GetBytes(out, "tools.0.name").String()
+	if renamed == "server_repo_search" || !SafeToolName(renamed) || names[renamed] != "server.repo/search" {
+		t.Fatalf("dotted name not made unique and safe: %q (%v)", renamed, names)
+	}
+	if gjson.GetBytes(out, "tool_choice.name").String() != renamed || gjson.GetBytes(out, "messages.0.content.0.name").String() != renamed {
+		t.Fatalf("references not renamed: %s", out)
+	}
+	if short := gjson.GetBytes(out, "tools.2.name").String(); len(short) > ToolNameMaxLength || names[short] != long {
+		t.Fatalf("long name not shortened: %q", short)
+	}
+	if gjson.GetBytes(out, "tools.1.name").String() != "server_repo_search" || gjson.GetBytes(out, "tools.3.name").String() != "Bash" {
+		t.Fatalf("safe names changed: %s", out)
+	}
+
+	response := []byte(`{"content":[{"type":"tool_use","id":"t2","name":"` + renamed + `","input":{}},{"type":"tool_use","id":"t3","name":"Bash","input":{}}]}`)
+	restored := RestoreToolNames("claude", response, names)
+	if gjson.GetBytes(restored, "content.0.name").String() != "server.repo/search" || gjson.GetBytes(restored, "content.1.name").String() != "Bash" {
+		t.Fatalf("names not restored: %s", restored)
+	}
+
+	if _, none := SanitizeToolNames("claude", []byte(`{"tools":[{"name":"Bash"}]}`)); none != nil {
+		t.Fatalf("safe tools renamed: %v", none)
+	}
+}
+
+func TestToolNameStreamOpenAI(t *testing.T) {
+	request := []byte(`{"tools":[{"type":"function","function":{"name":"fs.read"}}]}`)
+	_, names := SanitizeToolNames("openai", request)
+	if names["fs_read"] != "fs.read" {
+		t.Fatalf("unexpected mapping %v", names)
+	}
+	chunk := []byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"c1\",\"function\":{\"name\":\"fs_read\",\"arguments\":\"\"}}]}}]}\n\n")
+	out := NewToolNameStream("openai", names).Chunk(chunk)
+	if !strings.Contains(string(out), `"name":"fs.read"`) || !strings.HasPrefix(string(out), "data: ") {
+		t.Fatalf("stream chunk not restored: %q", out)
+	}
+	var stream *ToolNameStream
+	if got := stream.Chunk(chunk); string(got) != string(chunk) {
+		t.Fatalf("nil stream changed the chunk")
+	}
+}
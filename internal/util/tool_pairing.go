@@ -0,0 +1,289 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ToolPairPlaceholder is the content used for synthesized tool results.
+const ToolPairPlaceholder = "[tool result unavailable]"
+
+// Tool pair repair kinds reported by RepairClaudeToolPairs and RepairOpenAIToolPairs.
+const (
+	ToolPairSynthesizedResult = "synthesized_result"
+	ToolPairDroppedResult     = "dropped_result"
+)
+
+// ToolPairRepair records a single change made while repairing a transcript.
+type ToolPairRepair struct {
+	// Kind is ToolPairSynthesizedResult or ToolPairDroppedResult.
+	Kind string `json:"kind"`
+	// ToolUseID is the tool call identifier affected by the repair.
+	ToolUseID string `json:"tool_use_id"`
+	// MessageIndex is the index of the message in the original transcript that
+	// triggered the repair (the assistant turn for synthesized results, the
+	// result-bearing turn for dropped results).
+	MessageIndex int `json:"message_index"`
+}
+
+// RepairClaudeToolPairs makes tool_use / tool_result blocks in a Claude Messages
+// payload consistent. Every tool_use in an assistant turn that is followed by
+// another turn receives a matching tool_result before the next assistant turn (a
+// placeholder marked is_error is synthesized in the first user turn when
+// missing), and tool_result blocks that reference no tool_use of the preceding
+// assistant turn are dropped. Results may be split across several user turns or
+// follow an interleaved system turn. A trailing assistant turn is left
+// untouched. The payload is returned unchanged when no repair is necessary.
+func RepairClaudeToolPairs(payload []byte) ([]byte, []ToolPairRepair) {
+	messages := gjson.GetBytes(payload, "messages")
+	if !messages.IsArray() {
+		return payload, nil
+	}
+	items := messages.Array()
+	var repairs []ToolPairRepair
+	out := make([]string, 0, len(items)+1)
+	var pending []string
+	pendingIndex := -1
+	known := map[string]struct{}{}
+
+	for i, msg := range items {
+		raw := msg.Raw
+		role := msg.Get("role").String()
+
+		if role == "user" {
+			present := map[string]struct{}{}
+			content := msg.Get("content")
+			if content.IsArray() {
+				kept := make([]string, 0, len(content.Array()))
+				dropped := false
+				for _, block := range content.Array() {
+					if block.Get("type").String() != "tool_result" {
+						kept = append(kept, block.Raw)
+						continue
+					}
+					id := block.Get("tool_use_id").String()
+					if _, ok := known[id]; !ok {
+						repairs = append(repairs, ToolPairRepair{Kind: ToolPairDroppedResult, ToolUseID: id, MessageIndex: i})
+						dropped = true
+						continue
+					}
+					present[id] = struct{}{}
+					kept = append(kept, block.Raw)
+				}
+				if dropped {
+					if len(kept) == 0 {
+						kept = append(kept, `{"type":"text","text":"`+ToolPairPlaceholder+`"}`)
+					}
+					raw, _ = sjson.SetRaw(raw, "content", "["+strings.Join(kept, ",")+"]")
+				}
+			}
+			if len(pending) > 0 {
+				for id := range spanResultIDs(items, i+1, claudeResultIDs) {
+					present[id] = struct{}{}
+				}
+				if missing := missingIDs(pending, present); len(missing) > 0 {
+					raw = prependClaudeToolResults(raw, missing)
+					for _, id := range missing {
+						repairs = append(repairs, ToolPairRepair{Kind: ToolPairSynthesizedResult, ToolUseID: id, MessageIndex: pendingIndex})
+					}
+				}
+				pending = nil
+			}
+			out = append(out, raw)
+			continue
+		}
+
+		if role != "assistant" {
+			// System and other turns keep the calls of the last assistant turn
+			// open; their results may still follow.
+			out = append(out, raw)
+			continue
+		}
+		if len(pending) > 0 {
+			synthetic := prependClaudeToolResults(`{"role":"user","content":[]}`, pending)
+			out = append(out, synthetic)
+			for _, id := range pending {
+				repairs = append(repairs, ToolPairRepair{Kind: ToolPairSynthesizedResult, ToolUseID: id, MessageIndex: pendingIndex})
+			}
+		}
+		pending, known = nil, map[string]struct{}{}
+		msg.Get("content").ForEach(func(_, block gjson.Result) bool {
+			if block.Get("type").String() == "tool_use" {
+				if id := block.Get("id").String(); id != "" {
+					if _, seen := known[id]; !seen {
+						pending = append(pending, id)
+						known[id] = struct{}{}
+					}
+				}
+			}
+			return true
+		})
+		pendingIndex = i
+		out = append(out, raw)
+	}
+
+	if len(repairs) == 0 {
+		return payload, nil
+	}
+	updated, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, nil
+	}
+	return updated, repairs
+}
+
+// RepairOpenAIToolPairs applies the same consistency rules as
+// RepairClaudeToolPairs to an OpenAI Chat Completions payload, where tool calls
+// live in assistant "tool_calls" and results are separate "tool" role messages.
+func RepairOpenAIToolPairs(payload []byte) ([]byte, []ToolPairRepair) {
+	messages := gjson.GetBytes(payload, "messages")
+	if !messages.IsArray() {
+		return payload, nil
+	}
+	items := messages.Array()
+	var repairs []ToolPairRepair
+	out := make([]string, 0, len(items)+1)
+	var pending []string
+	pendingIndex := -1
+	known := map[string]struct{}{}
+	present := map[string]struct{}{}
+
+	// synthesize adds placeholders for the open calls not answered by now or
+	// by a later result before the next assistant turn.
+	synthesize := func(later map[string]struct{}) {
+		for _, id := range missingIDs(pending, present) {
+			if _, ok := later[id]; ok {
+				continue
+			}
+			msg, _ := sjson.Set(`{"role":"tool"}`, "tool_call_id", id)
+			msg, _ = sjson.Set(msg, "content", ToolPairPlaceholder)
+			out = append(out, msg)
+			repairs = append(repairs, ToolPairRepair{Kind: ToolPairSynthesizedResult, ToolUseID: id, MessageIndex: pendingIndex})
+		}
+		pending = nil
+	}
+
+	for i, msg := range items {
+		role := msg.Get("role").String()
+		if role == "tool" {
+			id := msg.Get("tool_call_id").String()
+			if _, ok := known[id]; !ok {
+				repairs = append(repairs, ToolPairRepair{Kind: ToolPairDroppedResult, ToolUseID: id, MessageIndex: i})
+				continue
+			}
+			present[id] = struct{}{}
+			out = append(out, msg.Raw)
+			continue
+		}
+		if role != "assistant" {
+			// Results split by a user or system turn still belong to the last
+			// assistant turn.
+			if len(pending) > 0 {
+				synthesize(spanResultIDs(items, i+1, openAIResultIDs))
+			}
+			out = append(out, msg.Raw)
+			continue
+		}
+		synthesize(nil)
+		known, present = map[string]struct{}{}, map[string]struct{}{}
+		msg.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+			if id := call.Get("id").String(); id != "" {
+				if _, seen := known[id]; !seen {
+					pending = append(pending, id)
+					known[id] = struct{}{}
+				}
+			}
+			return true
+		})
+		pendingIndex = i
+		out = append(out, msg.Raw)
+	}
+	// A trailing assistant turn without results is left as-is; only results that
+	// already started arriving are completed.
+	if len(present) > 0 {
+		synthesize(nil)
+	}
+
+	if len(repairs) == 0 {
+		return payload, nil
+	}
+	updated, err := sjson.SetRawBytes(payload, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	if err != nil {
+		return payload, nil
+	}
+	return updated, repairs
+}
+
+// spanResultIDs returns the tool call IDs answered by results from items[start]
+// up to the next assistant turn.
+func spanResultIDs(items []gjson.Result, start int, results func(gjson.Result) []string) map[string]struct{} {
+	ids := map[string]struct{}{}
+	for _, msg := range items[min(start, len(items)):] {
+		if msg.Get("role").String() == "assistant" {
+			break
+		}
+		for _, id := range results(msg) {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+func claudeResultIDs(msg gjson.Result) []string {
+	if msg.Get("role").String() != "user" {
+		return nil
+	}
+	var ids []string
+	msg.Get("content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == "tool_result" {
+			ids = append(ids, block.Get("tool_use_id").String())
+		}
+		return true
+	})
+	return ids
+}
+
+func openAIResultIDs(msg gjson.Result) []string {
+	if msg.Get("role").String() != "tool" {
+		return nil
+	}
+	return []string{msg.Get("tool_call_id").String()}
+}
+
+func missingIDs(pending []string, present map[string]struct{}) []string {
+	var missing []string
+	for _, id := range pending {
+		if _, ok := present[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// prependClaudeToolResults inserts placeholder tool_result blocks at the start
+// of a user message, converting string content to a text block when needed.
+func prependClaudeToolResults(message string, ids []string) string {
+	blocks := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		block, _ := sjson.Set(`{"type":"tool_result","is_error":true}`, "tool_use_id", id)
+		block, _ = sjson.Set(block, "content", ToolPairPlaceholder)
+		blocks = append(blocks, block)
+	}
+	content := gjson.Get(message, "content")
+	switch {
+	case content.IsArray():
+		for _, block := range content.Array() {
+			blocks = append(blocks, block.Raw)
+		}
+	case content.Type == gjson.String && content.String() != "":
+		text, _ := sjson.Set(`{"type":"text"}`, "text", content.String())
+		blocks = append(blocks, text)
+	}
+	updated, err := sjson.SetRaw(message, "content", "["+strings.Join(blocks, ",")+"]")
+	if err != nil {
+		return message
+	}
+	return updated
+}
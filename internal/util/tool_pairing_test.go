@@ -0,0 +1,99 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRepairClaudeToolPairs(t *testing.T) {
+	input := []byte(`{"messages":[
+		{"role":"user","content":"run both"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"a","name":"x","input":{}},{"type":"tool_use","id":"b","name":"y","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"a","content":"ok"},{"type":"tool_result","tool_use_id":"zzz","content":"stale"}]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"c","name":"x","input":{}}]},
+		{"role":"assistant","content":"done"}
+	]}`)
+	out, repairs := RepairClaudeToolPairs(input)
+	if len(repairs) != 3 {
+		t.Fatalf("expected 3 repairs, got %d: %+v", len(repairs), repairs)
+	}
+	if got := gjson.GetBytes(out, "messages.2.content.#").Int(); got != 2 {
+		t.Fatalf("expected 2 blocks in repaired user turn, got %d: %s", got, out)
+	}
+	if got := gjson.GetBytes(out, "messages.2.content.0.tool_use_id").String(); got != "b" {
+		t.Fatalf("expected synthesized result for b first, got %q", got)
+	}
+	if !gjson.GetBytes(out, "messages.2.content.0.is_error").Bool() {
+		t.Fatalf("synthesized result must be marked is_error")
+	}
+	if got := gjson.GetBytes(out, "messages.2.content.1.tool_use_id").String(); got != "a" {
+		t.Fatalf("expected original result for a, got %q", got)
+	}
+	if got := gjson.GetBytes(out, "messages.4.role").String(); got != "user" {
+		t.Fatalf("expected synthesized user turn before trailing assistant, got %q", got)
+	}
+	if got := gjson.GetBytes(out, "messages.4.content.0.tool_use_id").String(); got != "c" {
+		t.Fatalf("expected synthesized result for c, got %q", got)
+	}
+}
+
+func TestRepairClaudeToolPairs_NoChange(t *testing.T) {
+	input := []byte(`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":[{"type":"tool_use","id":"a","name":"x","input":{}}]}]}`)
+	out, repairs := RepairClaudeToolPairs(input)
+	if len(repairs) != 0 || string(out) != string(input) {
+		t.Fatalf("trailing tool_use must be left untouched, got %s (%+v)", out, repairs)
+	}
+}
+
+func TestRepairClaudeToolPairs_ResultsSplitAcrossUserTurns(t *testing.T) {
+	input := []byte(`{"messages":[
+		{"role":"user","content":"run both"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"a","name":"x","input":{}},{"type":"tool_use","id":"b","name":"y","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"a","content":"ok"}]},
+		{"role":"system","content":"reminder"},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"b","content":"ok"}]},
+		{"role":"assistant","content":"done"}
+	]}`)
+	out, repairs := RepairClaudeToolPairs(input)
+	if len(repairs) != 0 || string(out) != string(input) {
+		t.Fatalf("split results must be kept, got %s (%+v)", out, repairs)
+	}
+}
+
+func TestRepairOpenAIToolPairs_ResultsAfterSystemTurn(t *testing.T) {
+	input := []byte(`{"messages":[
+		{"role":"user","content":"hi"},
+		{"role":"assistant","tool_calls":[{"id":"a","type":"function","function":{"name":"x","arguments":"{}"}},{"id":"b","type":"function","function":{"name":"y","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"a","content":"ok"},
+		{"role":"system","content":"reminder"},
+		{"role":"tool","tool_call_id":"b","content":"ok"}
+	]}`)
+	out, repairs := RepairOpenAIToolPairs(input)
+	if len(repairs) != 0 || string(out) != string(input) {
+		t.Fatalf("results after a system turn must be kept, got %s (%+v)", out, repairs)
+	}
+}
+
+func TestRepairOpenAIToolPairs(t *testing.T) {
+	input := []byte(`{"messages":[
+		{"role":"user","content":"hi"},
+		{"role":"assistant","tool_calls":[{"id":"a","type":"function","function":{"name":"x","arguments":"{}"}},{"id":"b","type":"function","function":{"name":"y","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"a","content":"ok"},
+		{"role":"tool","tool_call_id":"nope","content":"stale"},
+		{"role":"user","content":"next"}
+	]}`)
+	out, repairs := RepairOpenAIToolPairs(input)
+	if len(repairs) != 2 {
+		t.Fatalf("expected 2 repairs, got %d: %+v", len(repairs), repairs)
+	}
+	if got := gjson.GetBytes(out, "messages.#").Int(); got != 5 {
+		t.Fatalf("expected 5 messages, got %d: %s", got, out)
+	}
+	if got := gjson.GetBytes(out, "messages.3.tool_call_id").String(); got != "b" {
+		t.Fatalf("expected synthesized tool message for b, got %q", got)
+	}
+	if got := gjson.GetBytes(out, "messages.4.content").String(); got != "next" {
+		t.Fatalf("expected user turn preserved, got %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// minToolResultChunkBytes keeps part markers from dominating very small limits.
+const minToolResultChunkBytes = 256
+
+// ChunkToolResults splits tool result text longer than maxBytes into several
+// text parts of at most maxBytes each, kept in order inside the same tool
+// result so it still answers its tool call. Every part starts with a marker
+// naming the tool call and the part's position, e.g.
+// "[tool result call_1 part 2/3]", and every part but the last ends with a
+// continuation marker. Non-text parts such as images are kept in place.
+// Tool results given as a plain string are split only when splitStrings is
+// set, since turning them into arrays of parts is only safe for backends that
+// take the schema as is; translators to other schemas read string results.
+// Supported formats are claude, openai and openai-response. It returns the
+// payload and the number of tool results that were split.
+func ChunkToolResults(format string, payload []byte, maxBytes int, splitStrings bool) ([]byte, int) {
+	if maxBytes <= 0 || len(payload) <= maxBytes {
+		return payload, 0
+	}
+	maxBytes = max(maxBytes, minToolResultChunkBytes)
+	out := payload
+	count := 0
+	chunk := func(path string, content gjson.Result, callID, partType string) {
+		if content.Type == gjson.String && !splitStrings {
+			return
+		}
+		if parts, ok := chunkToolResultContent(content, callID, partType, maxBytes); ok {
+			if updated, err := sjson.SetRawBytes(out, path, parts); err == nil {
+				out = updated
+				count++
+			}
+		}
+	}
+	root := gjson.ParseBytes(payload)
+	switch format {
+	case "claude":
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			message.Get("content").ForEach(func(j, block gjson.Result) bool {
+				if block.Get("type").String() == "tool_result" {
+					chunk(fmt.Sprintf("messages.%d.content.%d.content", i.Int(), j.Int()), block.Get("content"), block.Get("tool_use_id").String(), "text")
+				}
+				return true
+			})
+			return true
+		})
+	case "openai":
+		root.Get("messages").ForEach(func(i, message gjson.Result) bool {
+			if message.Get("role").String() == "tool" {
+				chunk(fmt.Sprintf("messages.%d.content", i.Int()), message.Get("content"), message.Get("tool_call_id").String(), "text")
+			}
+			return true
+		})
+	case "openai-response":
+		root.Get("input").ForEach(func(i, item gjson.Result) bool {
+			if item.Get("type").String() == "function_call_output" {
+				chunk(fmt.Sprintf("input.%d.output", i.Int()), item.Get("output"), item.Get("call_id").String(), "input_text")
+			}
+			return true
+		})
+	}
+	return out, count
+}
+
+// chunkToolResultContent returns the tool result content as a JSON array of
+// parts with oversized text split, or false when nothing needed splitting.
+func chunkToolResultContent(content gjson.Result, callID, partType string, maxBytes int) ([]byte, bool) {
+	var parts []string
+	switch {
+	case content.Type == gjson.String:
+		if len(content.String()) <= maxBytes {
+			return nil, false
+		}
+		template := fmt.Sprintf(`{"type":%q}`, partType)
+		parts = splitToolResultText(template, content.String(), callID, maxBytes)
+	case content.IsArray():
+		split := false
+		for _, part := range content.Array() {
+			text := part.Get("text")
+			if text.Type != gjson.String || len(text.String()) <= maxBytes {
+				parts = append(parts, part.Raw)
+				continue
+			}
+			split = true
+			parts = append(parts, splitToolResultText(part.Raw, text.String(), callID, maxBytes)...)
+		}
+		if !split {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return []byte("[" + strings.Join(parts, ",") + "]"), true
+}
+
+// splitToolResultText returns copies of the template part carrying the
+// marked pieces of text. Cache control stays on the last piece only.
+func splitToolResultText(template, text, callID string, maxBytes int) []string {
+	// Room for both markers with six-digit part numbers.
+	budget := max(maxBytes-2*len(callID)-80, minToolResultChunkBytes/2)
+	pieces := splitTextPieces(text, budget)
+	parts := make([]string, 0, len(pieces))
+	for i, piece := range pieces {
+		marked := fmt.Sprintf("[tool result %s part %d/%d]\n%s", callID, i+1, len(pieces), piece)
+		if i < len(pieces)-1 {
+			marked += fmt.Sprintf("\n[continued in tool result %s part %d/%d]", callID, i+2, len(pieces))
+		}
+		part, err := sjson.Set(template, "text", marked)
+		if err != nil {
+			continue
+		}
+		if i < len(pieces)-1 {
+			part, _ = sjson.Delete(part, "cache_control")
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// splitTextPieces cuts text into pieces of at most size bytes at rune
+// boundaries, preferring a line break in the last quarter of a piece.
+func splitTextPieces(text string, size int) []string {
+	var pieces []string
+	for len(text) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if nl := strings.LastIndexByte(text[cut-cut/4:cut], '\n'); nl >= 0 {
+			cut = cut - cut/4 + nl + 1
+		}
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	return append(pieces, text)
+}
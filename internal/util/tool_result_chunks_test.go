@@ -0,0 +1,77 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestChunkToolResults(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d of the build output", i))
+	}
+	output := strings.Join(lines, "\n")
+
+	cases := []struct {
+		format, payload, path, partType string
+	}{
+		{"claude", `{"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":%q},{"type":"text","text":"go on"}]}]}`, "messages.0.content.0.content", "text"},
+		{"openai", `{"messages":[{"role":"tool","tool_call_id":"call_1","content":%q}]}`, "messages.0.content", "text"},
+		{"openai-response", `{"input":[{"type":"function_call_output","call_id":"call_1","output":%q}]}`, "input.0.output", "input_text"},
+	}
+	for _, tc := range cases {
+		payload := []byte(fmt.Sprintf(tc.payload, output))
+		if _, count := ChunkToolResults(tc.format, payload, 1024, false); count != 0 {
+			t.Fatalf("%s: string tool result split without splitStrings", tc.format)
+		}
+		out, count := ChunkToolResults(tc.format, payload, 1024, true)
+		if count != 1 {
+			t.Fatalf("%s: count = %d, want 1", tc.format, count)
+		}
+		parts := gjson.GetBytes(out, tc.path).Array()
+		if len(parts) < 3 {
+			t.Fatalf("%s: got %d parts: %s", tc.format, len(parts), out)
+		}
+		var joined strings.Builder
+		for i, part := range parts {
+			text := part.Get("text").String()
+			if part.Get("type").String() != tc.partType || len(text) > 1024 {
+				t.Fatalf("%s: part %d = %s", tc.format, i, part.Raw)
+			}
+			header, body, _ := strings.Cut(text, "\n")
+			if !strings.HasPrefix(header, "[tool result ") || !strings.HasSuffix(header, fmt.Sprintf(" part %d/%d]", i+1, len(parts))) {
+				t.Fatalf("%s: part %d marker = %q", tc.format, i, header)
+			}
+			if i < len(parts)-1 {
+				body = body[:strings.LastIndex(body, "\n[continued in")]
+			}
+			joined.WriteString(body)
+		}
+		if joined.String() != output {
+			t.Fatalf("%s: reassembled output differs", tc.format)
+		}
+	}
+}
+
+func TestChunkToolResultsKeepsSmallAndNonTextParts(t *testing.T) {
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"image","source":{"type":"base64","data":"AAAA"}},{"type":"text","text":"` + strings.Repeat("x", 600) + `","cache_control":{"type":"ephemeral"}}]},{"type":"tool_result","tool_use_id":"toolu_2","content":"short"}]}]}`)
+	out, count := ChunkToolResults("claude", payload, 256, false)
+	if count != 1 {
+		t.Fatalf("count = %d, want 1", count)
+	}
+	parts := gjson.GetBytes(out, "messages.0.content.0.content").Array()
+	if len(parts) < 4 || parts[0].Get("type").String() != "image" {
+		t.Fatalf("unexpected parts: %s", out)
+	}
+	for i, part := range parts[1:] {
+		if hasCache := part.Get("cache_control").Exists(); hasCache != (i == len(parts)-2) {
+			t.Fatalf("part %d cache_control = %v", i+1, hasCache)
+		}
+	}
+	if got := gjson.GetBytes(out, "messages.0.content.1.content").String(); got != "short" {
+		t.Fatalf("small tool result changed: %q", got)
+	}
+}
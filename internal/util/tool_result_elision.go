@@ -0,0 +1,82 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OmittedToolResultText replaces the tool result bodies ElideToolResults drops.
+const OmittedToolResultText = "[tool result omitted to fit the context window]"
+
+// ElidableToolResults returns the number of tool results ElideToolResults can
+// drop from a request.
+func ElidableToolResults(format string, payload []byte) int {
+	return len(elidableToolResultPaths(format, payload))
+}
+
+// ElideToolResults replaces the bodies of the oldest n tool results of a
+// claude, openai or openai-response request with OmittedToolResultText. The
+// results stay in place, so their tool calls remain answered. Results that
+// answer the latest assistant turn are kept, as are results already omitted.
+// It returns the payload and the number of results replaced.
+func ElideToolResults(format string, payload []byte, n int) ([]byte, int) {
+	paths := elidableToolResultPaths(format, payload)
+	if n > len(paths) {
+		n = len(paths)
+	}
+	out := payload
+	count := 0
+	for _, path := range paths[:max(n, 0)] {
+		if updated, err := sjson.SetBytes(out, path, OmittedToolResultText); err == nil {
+			out = updated
+			count++
+		}
+	}
+	return out, count
+}
+
+// elidableToolResultPaths returns, oldest first, the paths of the tool result
+// bodies that come before the latest assistant turn.
+func elidableToolResultPaths(format string, payload []byte) []string {
+	listPath, items := ConversationItems(format, payload)
+	if listPath == "" {
+		return nil
+	}
+	lastAssistant := -1
+	for i, item := range items {
+		if item.Get("role").String() == "assistant" || item.Get("type").String() == "function_call" {
+			lastAssistant = i
+		}
+	}
+	var paths []string
+	add := func(path string, body gjson.Result) {
+		if body.Type != gjson.String || body.String() != OmittedToolResultText {
+			paths = append(paths, path)
+		}
+	}
+	for i, item := range items[:max(lastAssistant, 0)] {
+		switch format {
+		case "claude":
+			if item.Get("role").String() != "user" {
+				continue
+			}
+			item.Get("content").ForEach(func(j, block gjson.Result) bool {
+				if block.Get("type").String() == "tool_result" {
+					add(fmt.Sprintf("%s.%d.content.%d.content", listPath, i, j.Int()), block.Get("content"))
+				}
+				return true
+			})
+		case "openai":
+			if item.Get("role").String() == "tool" {
+				add(fmt.Sprintf("%s.%d.content", listPath, i), item.Get("content"))
+			}
+		case "openai-response":
+			if item.Get("type").String() == "function_call_output" {
+				add(fmt.Sprintf("%s.%d.output", listPath, i), item.Get("output"))
+			}
+		}
+	}
+	return paths
+}
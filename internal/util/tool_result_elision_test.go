@@ -0,0 +1,66 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestElideToolResults(t *testing.T) {
+	cases := []struct {
+		format, payload string
+		paths           []string
+		latest          string
+	}{
+		{
+			"claude",
+			`{"messages":[{"role":"user","content":"go"},` +
+				`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{}},{"type":"tool_use","id":"t2","name":"read","input":{}}]},` +
+				`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"one"},{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"two"}]}]},` +
+				`{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"read","input":{}}]},` +
+				`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","content":"three"}]}]}`,
+			[]string{"messages.2.content.0.content", "messages.2.content.1.content"},
+			"messages.4.content.0.content",
+		},
+		{
+			"openai",
+			`{"messages":[{"role":"user","content":"go"},` +
+				`{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"read","arguments":"{}"}}]},` +
+				`{"role":"tool","tool_call_id":"c1","content":"one"},` +
+				`{"role":"assistant","tool_calls":[{"id":"c2","type":"function","function":{"name":"read","arguments":"{}"}}]},` +
+				`{"role":"tool","tool_call_id":"c2","content":"two"}]}`,
+			[]string{"messages.2.content"},
+			"messages.4.content",
+		},
+		{
+			"openai-response",
+			`{"input":[{"role":"user","content":"go"},` +
+				`{"type":"function_call","call_id":"c1","name":"read","arguments":"{}"},` +
+				`{"type":"function_call_output","call_id":"c1","output":"one"},` +
+				`{"type":"function_call","call_id":"c2","name":"read","arguments":"{}"},` +
+				`{"type":"function_call_output","call_id":"c2","output":"two"}]}`,
+			[]string{"input.2.output"},
+			"input.4.output",
+		},
+	}
+	for _, tc := range cases {
+		payload := []byte(tc.payload)
+		if got := ElidableToolResults(tc.format, payload); got != len(tc.paths) {
+			t.Fatalf("%s: elidable = %d, want %d", tc.format, got, len(tc.paths))
+		}
+		out, count := ElideToolResults(tc.format, payload, 1)
+		if count != 1 || gjson.GetBytes(out, tc.paths[0]).String() != OmittedToolResultText {
+			t.Fatalf("%s: oldest result not omitted (%d): %s", tc.format, count, out)
+		}
+		if len(tc.paths) > 1 && gjson.GetBytes(out, tc.paths[1]).String() == OmittedToolResultText {
+			t.Fatalf("%s: omitted more than asked: %s", tc.format, out)
+		}
+		out, count = ElideToolResults(tc.format, payload, 10)
+		if count != len(tc.paths) || gjson.GetBytes(out, tc.latest).String() == OmittedToolResultText {
+			t.Fatalf("%s: latest result omitted or count %d: %s", tc.format, count, out)
+		}
+		if again := ElidableToolResults(tc.format, out); again != 0 {
+			t.Fatalf("%s: %d results still elidable after omitting all", tc.format, again)
+		}
+	}
+}
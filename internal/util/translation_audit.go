@@ -0,0 +1,180 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// TranslationLoss describes a request field that a translation dropped or changed.
+type TranslationLoss struct {
+	// Field is the field in the client's request schema.
+	Field string `json:"field"`
+	// Change is "dropped" or "altered".
+	Change string `json:"change"`
+	// Detail explains an alteration.
+	Detail string `json:"detail,omitempty"`
+}
+
+// requestSummary holds the request features compared by AuditTranslation.
+type requestSummary struct {
+	// params maps a canonical parameter name to its client-facing field and value.
+	params   map[string][2]string
+	thinking bool
+	// tools maps tool names to descriptions.
+	tools map[string]string
+}
+
+// auditParams lists, per schema, the field holding each canonical sampling parameter.
+var auditParams = map[string]map[string]string{
+	"claude": {
+		"temperature": "temperature", "top_p": "top_p", "top_k": "top_k",
+		"stop": "stop_sequences", "max_tokens": "max_tokens",
+	},
+	"openai": {
+		"temperature": "temperature", "top_p": "top_p", "top_k": "top_k", "stop": "stop",
+		"max_tokens": "max_tokens|max_completion_tokens",
+		"seed":       "seed", "frequency_penalty": "frequency_penalty", "presence_penalty": "presence_penalty",
+	},
+	"openai-response": {
+		"temperature": "temperature", "top_p": "top_p", "max_tokens": "max_output_tokens",
+	},
+	"gemini": {
+		"temperature": "generationConfig.temperature", "top_p": "generationConfig.topP", "top_k": "generationConfig.topK",
+		"stop": "generationConfig.stopSequences", "max_tokens": "generationConfig.maxOutputTokens",
+		"seed": "generationConfig.seed", "frequency_penalty": "generationConfig.frequencyPenalty",
+		"presence_penalty": "generationConfig.presencePenalty",
+	},
+}
+
+// auditSchema maps request schemas to the schema whose field layout they share.
+func auditSchema(format string) (string, string) {
+	switch format {
+	case "codex":
+		return "openai-response", ""
+	case "gemini-cli", "antigravity":
+		return "gemini", "request."
+	}
+	return format, ""
+}
+
+func summarizeRequest(format string, payload []byte) (requestSummary, bool) {
+	schema, prefix := auditSchema(format)
+	fields, ok := auditParams[schema]
+	if !ok {
+		return requestSummary{}, false
+	}
+	root := gjson.ParseBytes(payload)
+	summary := requestSummary{params: make(map[string][2]string), tools: make(map[string]string)}
+	for name, paths := range fields {
+		for _, path := range strings.Split(paths, "|") {
+			if value := root.Get(prefix + path); value.Exists() && value.Type != gjson.Null {
+				summary.params[name] = [2]string{path, normalizeAuditValue(value)}
+				break
+			}
+		}
+	}
+	addTool := func(name, description gjson.Result) {
+		if name.String() != "" {
+			summary.tools[name.String()] = description.String()
+		}
+	}
+	switch schema {
+	case "claude":
+		summary.thinking = root.Get("thinking.type").String() == "enabled"
+		root.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+			addTool(tool.Get("name"), tool.Get("description"))
+			return true
+		})
+	case "openai":
+		effort := root.Get("reasoning_effort").String()
+		summary.thinking = effort != "" && effort != "none"
+		root.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+			if IsBuiltinToolType(tool.Get("type").String()) {
+				addTool(tool.Get("type"), gjson.Result{})
+			}
+			addTool(tool.Get("function.name"), tool.Get("function.description"))
+			return true
+		})
+	case "openai-response":
+		effort := root.Get("reasoning.effort").String()
+		summary.thinking = effort != "" && effort != "none"
+		root.Get("tools").ForEach(func(_, tool gjson.Result) bool {
+			if IsBuiltinToolType(tool.Get("type").String()) {
+				addTool(tool.Get("type"), gjson.Result{})
+			}
+			addTool(tool.Get("name"), tool.Get("description"))
+			return true
+		})
+	case "gemini":
+		thinking := root.Get(prefix + "generationConfig.thinkingConfig")
+		summary.thinking = thinking.Get("includeThoughts").Bool() || thinking.Get("thinkingBudget").Int() != 0 || thinking.Get("thinkingLevel").Exists()
+		root.Get(prefix + "tools").ForEach(func(_, tool gjson.Result) bool {
+			tool.Get("functionDeclarations").ForEach(func(_, fn gjson.Result) bool {
+				addTool(fn.Get("name"), fn.Get("description"))
+				return true
+			})
+			return true
+		})
+	}
+	return summary, true
+}
+
+// normalizeAuditValue renders a value so that equivalent encodings compare
+// equal: numbers by value and a single stop string like a one-element list.
+func normalizeAuditValue(value gjson.Result) string {
+	switch {
+	case value.Type == gjson.Number:
+		return fmt.Sprintf("%g", value.Float())
+	case value.Type == gjson.String:
+		return fmt.Sprintf("[%q]", value.String())
+	case value.IsArray():
+		var parts []string
+		value.ForEach(func(_, item gjson.Result) bool {
+			parts = append(parts, fmt.Sprintf("%q", item.String()))
+			return true
+		})
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	return value.Raw
+}
+
+// AuditTranslation compares a request in schema from with its translation to
+// schema to and lists the sampling parameters, thinking settings and tools the
+// translation dropped or changed. It returns nil when either schema is not
+// covered by the audit.
+func AuditTranslation(from, to string, in, out []byte) []TranslationLoss {
+	src, okSrc := summarizeRequest(from, in)
+	dst, okDst := summarizeRequest(to, out)
+	if !okSrc || !okDst {
+		return nil
+	}
+	var losses []TranslationLoss
+	for name, param := range src.params {
+		translated, ok := dst.params[name]
+		switch {
+		case !ok:
+			losses = append(losses, TranslationLoss{Field: param[0], Change: "dropped"})
+		case translated[1] != param[1]:
+			losses = append(losses, TranslationLoss{Field: param[0], Change: "altered", Detail: fmt.Sprintf("%s sent as %s", param[1], translated[1])})
+		}
+	}
+	if src.thinking && !dst.thinking {
+		losses = append(losses, TranslationLoss{Field: "thinking", Change: "dropped", Detail: "thinking is disabled upstream"})
+	}
+	for name, description := range src.tools {
+		translated, ok := dst.tools[name]
+		switch {
+		case !ok && IsBuiltinToolType(name) && description == "":
+			losses = append(losses, TranslationLoss{Field: "tools." + name, Change: "dropped", Detail: "built-in tool not supported upstream; set builtin-tools to convert to declare it as a function"})
+		case !ok:
+			losses = append(losses, TranslationLoss{Field: "tools." + name, Change: "dropped", Detail: "tool missing or renamed upstream"})
+		case len(translated) < len(description):
+			losses = append(losses, TranslationLoss{Field: "tools." + name + ".description", Change: "altered", Detail: fmt.Sprintf("truncated from %d to %d characters", len(description), len(translated))})
+		}
+	}
+	sort.Slice(losses, func(i, j int) bool { return losses[i].Field < losses[j].Field })
+	return losses
+}
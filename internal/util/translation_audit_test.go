@@ -0,0 +1,32 @@
+package util
+
+import "testing"
+
+func TestAuditTranslation(t *testing.T) {
+	in := []byte(`{"temperature":0.5,"top_k":40,"stop_sequences":["END"],"max_tokens":1024,"thinking":{"type":"enabled","budget_tokens":2048},` +
+		`"tools":[{"name":"read","description":"Read a file from disk"},{"name":"write","description":"Write"}]}`)
+	out := []byte(`{"temperature":0.5,"stop":"END","max_tokens":512,"tools":[{"type":"function","function":{"name":"read","description":"Read a"}}]}`)
+
+	losses := AuditTranslation("claude", "openai", in, out)
+	want := []TranslationLoss{
+		{Field: "max_tokens", Change: "altered", Detail: "1024 sent as 512"},
+		{Field: "thinking", Change: "dropped", Detail: "thinking is disabled upstream"},
+		{Field: "tools.read.description", Change: "altered", Detail: "truncated from 21 to 6 characters"},
+		{Field: "tools.write", Change: "dropped", Detail: "tool missing or renamed upstream"},
+		{Field: "top_k", Change: "dropped"},
+	}
+	if len(losses) != len(want) {
+		t.Fatalf("losses = %+v", losses)
+	}
+	for i := range want {
+		if losses[i] != want[i] {
+			t.Fatalf("loss %d = %+v, want %+v", i, losses[i], want[i])
+		}
+	}
+
+	gemini := []byte(`{"request":{"generationConfig":{"temperature":0.5,"topK":40,"stopSequences":["END"],"maxOutputTokens":1024,"thinkingConfig":{"thinkingBudget":2048}},` +
+		`"tools":[{"functionDeclarations":[{"name":"read","description":"Read a file from disk"},{"name":"write","description":"Write"}]}]}}`)
+	if losses = AuditTranslation("claude", "gemini-cli", in, gemini); len(losses) != 0 {
+		t.Fatalf("lossless translation reported %+v", losses)
+	}
+}
@@ -0,0 +1,188 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// upstreamTLSCache keeps one client TLS configuration and one direct transport
+// per distinct setting, so certificate files are read once and connections to
+// upstreams stay pooled.
+var upstreamTLSCache = struct {
+	sync.Mutex
+	configs    map[string]*tls.Config
+	transports map[string]*http.Transport
+}{configs: make(map[string]*tls.Config), transports: make(map[string]*http.Transport)}
+
+// UpstreamTLSConfig returns the client TLS configuration for upstream
+// connections, or nil when cfg leaves the defaults in place.
+func UpstreamTLSConfig(cfg config.UpstreamTLSConfig) (*tls.Config, error) {
+	key := upstreamTLSKey(cfg)
+	if key == "" {
+		return nil, nil
+	}
+	upstreamTLSCache.Lock()
+	defer upstreamTLSCache.Unlock()
+	if tlsConfig, ok := upstreamTLSCache.configs[key]; ok {
+		return tlsConfig, nil
+	}
+	tlsConfig, err := buildUpstreamTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	upstreamTLSCache.configs[key] = tlsConfig
+	return tlsConfig, nil
+}
+
+// ApplyUpstreamTLS installs the upstream TLS configuration on transport. When
+// the configuration cannot be loaded the transport refuses every TLS
+// handshake rather than falling back to the defaults.
+func ApplyUpstreamTLS(transport *http.Transport, cfg config.UpstreamTLSConfig) {
+	if transport == nil {
+		return
+	}
+	tlsConfig, err := UpstreamTLSConfig(cfg)
+	if err != nil {
+		log.Errorf("configure upstream TLS failed, refusing TLS upstreams: %v", err)
+		tlsConfig = refusingTLSConfig(err)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+}
+
+// UpstreamTransport returns a shared direct transport using the upstream TLS
+// configuration, or nil when the default transport applies. When the
+// configuration cannot be loaded the returned transport refuses every TLS
+// handshake.
+func UpstreamTransport(cfg config.UpstreamTLSConfig) http.RoundTripper {
+	key := upstreamTLSKey(cfg)
+	if key == "" {
+		return nil
+	}
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	tlsConfig, err := UpstreamTLSConfig(cfg)
+	if err != nil {
+		log.Errorf("configure upstream TLS failed, refusing TLS upstreams: %v", err)
+		transport := base.Clone()
+		transport.TLSClientConfig = refusingTLSConfig(err)
+		return transport
+	}
+	upstreamTLSCache.Lock()
+	defer upstreamTLSCache.Unlock()
+	if transport, ok := upstreamTLSCache.transports[key]; ok {
+		return transport
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = tlsConfig.Clone()
+	upstreamTLSCache.transports[key] = transport
+	return transport
+}
+
+// refusingTLSConfig fails every handshake with cause, so a broken CA bundle,
+// client certificate or pin never degrades to unpinned default verification.
+func refusingTLSConfig(cause error) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		VerifyConnection: func(tls.ConnectionState) error {
+			return fmt.Errorf("upstream TLS is misconfigured: %w", cause)
+		},
+	}
+}
+
+func upstreamTLSKey(cfg config.UpstreamTLSConfig) string {
+	parts := []string{strings.TrimSpace(cfg.CAFile), strings.TrimSpace(cfg.CertFile), strings.TrimSpace(cfg.KeyFile)}
+	for _, pin := range cfg.PinnedSHA256 {
+		if pin = strings.TrimSpace(pin); pin != "" {
+			parts = append(parts, pin)
+		}
+	}
+	key := strings.Join(parts, "|")
+	if strings.Trim(key, "|") == "" {
+		return ""
+	}
+	return key
+}
+
+func buildUpstreamTLSConfig(cfg config.UpstreamTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile := strings.TrimSpace(cfg.CAFile); caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if err = appendCertsFromFile(pool, caFile); err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	certFile, keyFile := strings.TrimSpace(cfg.CertFile), strings.TrimSpace(cfg.KeyFile)
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load upstream client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	var pins [][]byte
+	for _, pin := range cfg.PinnedSHA256 {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		if pin == "" {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid upstream pin %q: want base64 of a SHA-256 digest", pin)
+		}
+		pins = append(pins, digest)
+	}
+	if len(pins) > 0 {
+		// Runs after the regular chain verification.
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, cert := range state.PeerCertificates {
+				digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				for _, pin := range pins {
+					if bytes.Equal(digest[:], pin) {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("upstream certificate for %s does not match any pinned key", state.ServerName)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// LoadCertPool reads a PEM bundle into a new certificate pool.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if err := appendCertsFromFile(pool, path); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+func appendCertsFromFile(pool *x509.CertPool, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read CA bundle: %w", err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("CA bundle %s contains no PEM certificates", path)
+	}
+	return nil
+}
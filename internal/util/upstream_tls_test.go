@@ -0,0 +1,60 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestUpstreamTransportCustomCAAndPins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cert := server.Certificate()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatalf("write CA: %v", err)
+	}
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(digest[:])
+
+	get := func(cfg config.UpstreamTLSConfig) error {
+		rt := UpstreamTransport(cfg)
+		if rt == nil {
+			t.Fatalf("expected a transport for %+v", cfg)
+		}
+		resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+	if err := get(config.UpstreamTLSConfig{CAFile: caFile, PinnedSHA256: []string{pin}}); err != nil {
+		t.Fatalf("trusted, pinned server: %v", err)
+	}
+	other := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	if err := get(config.UpstreamTLSConfig{CAFile: caFile, PinnedSHA256: []string{other}}); err == nil {
+		t.Fatalf("expected a pin mismatch to fail")
+	}
+	if UpstreamTransport(config.UpstreamTLSConfig{}) != nil {
+		t.Fatalf("expected no transport without settings")
+	}
+	if err := get(config.UpstreamTLSConfig{CAFile: caFile, PinnedSHA256: []string{"not-a-pin"}}); err == nil {
+		t.Fatalf("expected an invalid pin to refuse the upstream")
+	}
+
+	transport := &http.Transport{}
+	ApplyUpstreamTLS(transport, config.UpstreamTLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		t.Fatalf("expected a missing CA bundle to refuse the upstream")
+	}
+}
@@ -0,0 +1,185 @@
+package util
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// Defaults of the upstream connection pool. The per-host idle limit is well
+// above net/http's default of 2, which forces new connections as soon as a few
+// streams to the same provider overlap.
+const (
+	defaultUpstreamMaxIdleConns        = 100
+	defaultUpstreamMaxIdleConnsPerHost = 32
+	defaultUpstreamIdleConnTimeout     = 90 * time.Second
+	defaultUpstreamDialTimeout         = 30 * time.Second
+	defaultUpstreamTLSHandshakeTimeout = 10 * time.Second
+	defaultUpstreamTLSSessionCacheSize = 256
+	upstreamTCPKeepAlive               = 30 * time.Second
+)
+
+// UpstreamConnSnapshot reports how upstream requests obtained their connection.
+type UpstreamConnSnapshot struct {
+	// Requests counts requests sent through the shared transports.
+	Requests int64 `json:"requests"`
+	// Reused counts requests served on an existing connection.
+	Reused int64 `json:"reused"`
+	// New counts requests that had to open a connection.
+	New int64 `json:"new"`
+}
+
+var upstreamConns struct {
+	requests atomic.Int64
+	reused   atomic.Int64
+	created  atomic.Int64
+}
+
+// UpstreamConnections returns the connection reuse counters of the shared
+// upstream transports.
+func UpstreamConnections() UpstreamConnSnapshot {
+	return UpstreamConnSnapshot{
+		Requests: upstreamConns.requests.Load(),
+		Reused:   upstreamConns.reused.Load(),
+		New:      upstreamConns.created.Load(),
+	}
+}
+
+// sharedUpstreamTransports keeps one transport per proxy and setting, so
+// connections are pooled across requests and credentials.
+var sharedUpstreamTransports = struct {
+	sync.Mutex
+	byKey map[string]http.RoundTripper
+}{byKey: make(map[string]http.RoundTripper)}
+
+// SharedUpstreamTransport returns the pooled transport for upstream requests
+// through proxyURL, or direct ones when proxyURL is empty. It carries the
+// upstream TLS settings of cfg, is tuned by its upstream-transport settings and
+// counts connection reuse. With fault injection enabled it also fails requests
+// on purpose.
+func SharedUpstreamTransport(cfg *config.SDKConfig, proxyURL string) (http.RoundTripper, error) {
+	var (
+		noProxy []string
+		tlsCfg  config.UpstreamTLSConfig
+		tuning  config.UpstreamTransportConfig
+		faults  config.FaultInjectionConfig
+	)
+	if cfg != nil {
+		noProxy, tlsCfg, tuning, faults = cfg.NoProxy, cfg.UpstreamTLS, cfg.UpstreamTransport, cfg.FaultInjection
+	}
+	proxyURL = strings.TrimSpace(proxyURL)
+	key := strings.Join([]string{proxyURL, strings.Join(noProxy, ","), upstreamTLSKey(tlsCfg), fmt.Sprintf("%+v", tuning), fmt.Sprintf("%+v", faults)}, "\n")
+
+	sharedUpstreamTransports.Lock()
+	defer sharedUpstreamTransports.Unlock()
+	if rt, ok := sharedUpstreamTransports.byKey[key]; ok {
+		return rt, nil
+	}
+	var transport *http.Transport
+	if proxyURL == "" {
+		base, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("default transport is not an *http.Transport")
+		}
+		transport = base.Clone()
+	} else {
+		var err error
+		if transport, err = NewProxyTransport(proxyURL, noProxy); err != nil {
+			return nil, err
+		}
+	}
+	ApplyUpstreamTLS(transport, tlsCfg)
+	TuneTransport(transport, tuning)
+	var rt http.RoundTripper = meteredTransport{base: transport}
+	if faults.Enabled {
+		rt = faultTransport{base: rt, cfg: faults}
+	}
+	sharedUpstreamTransports.byKey[key] = rt
+	return rt, nil
+}
+
+// TuneTransport applies the connection pool settings of cfg to transport. The
+// dial timeout only applies to transports that do not dial through a SOCKS5
+// proxy.
+func TuneTransport(transport *http.Transport, cfg config.UpstreamTransportConfig) {
+	if transport == nil {
+		return
+	}
+	transport.MaxIdleConns = positiveOr(cfg.MaxIdleConns, defaultUpstreamMaxIdleConns)
+	transport.MaxIdleConnsPerHost = positiveOr(cfg.MaxIdleConnsPerHost, defaultUpstreamMaxIdleConnsPerHost)
+	transport.MaxConnsPerHost = max(cfg.MaxConnsPerHost, 0)
+	transport.IdleConnTimeout = secondsOr(cfg.IdleConnTimeoutSeconds, defaultUpstreamIdleConnTimeout)
+	transport.TLSHandshakeTimeout = secondsOr(cfg.TLSHandshakeTimeoutSeconds, defaultUpstreamTLSHandshakeTimeout)
+	transport.ResponseHeaderTimeout = secondsOr(cfg.ResponseHeaderTimeoutSeconds, 0)
+	if transport.DialContext == nil {
+		dialer := &net.Dialer{Timeout: secondsOr(cfg.DialTimeoutSeconds, defaultUpstreamDialTimeout), KeepAlive: upstreamTCPKeepAlive}
+		transport.DialContext = dialer.DialContext
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	if tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(positiveOr(cfg.TLSSessionCacheSize, defaultUpstreamTLSSessionCacheSize))
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// A custom TLS configuration turns off HTTP/2 unless it is requested.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(!cfg.DisableHTTP2)
+	transport.Protocols = protocols
+	transport.ForceAttemptHTTP2 = !cfg.DisableHTTP2
+	if !cfg.DisableHTTP2 {
+		h2 := &http.HTTP2Config{
+			MaxReceiveBufferPerStream:     max(cfg.HTTP2StreamWindowKB, 0) << 10,
+			MaxReceiveBufferPerConnection: max(cfg.HTTP2ConnectionWindowKB, 0) << 10,
+		}
+		if cfg.HTTP2PingSeconds > 0 {
+			h2.SendPingTimeout = time.Duration(cfg.HTTP2PingSeconds) * time.Second
+		}
+		transport.HTTP2 = h2
+	}
+}
+
+func positiveOr(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+// meteredTransport counts whether each request reused a pooled connection.
+type meteredTransport struct {
+	base http.RoundTripper
+}
+
+func (t meteredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	upstreamConns.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				upstreamConns.reused.Add(1)
+			} else {
+				upstreamConns.created.Add(1)
+			}
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestSharedUpstreamTransportPoolsAndCountsReuse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cfg := &config.SDKConfig{UpstreamTransport: config.UpstreamTransportConfig{MaxIdleConnsPerHost: 8, HTTP2StreamWindowKB: 1024}}
+	rt, err := SharedUpstreamTransport(cfg, "")
+	if err != nil {
+		t.Fatalf("shared transport: %v", err)
+	}
+	if again, _ := SharedUpstreamTransport(cfg, ""); again != rt {
+		t.Fatal("expected the same transport for the same settings")
+	}
+	transport := rt.(meteredTransport).base.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 8 || transport.MaxIdleConns != defaultUpstreamMaxIdleConns ||
+		transport.HTTP2.MaxReceiveBufferPerStream != 1<<20 || transport.TLSClientConfig.ClientSessionCache == nil {
+		t.Fatalf("transport not tuned: %+v", transport)
+	}
+
+	before := UpstreamConnections()
+	client := &http.Client{Transport: rt}
+	for i := 0; i < 3; i++ {
+		resp, errGet := client.Get(server.URL)
+		if errGet != nil {
+			t.Fatalf("get: %v", errGet)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	after := UpstreamConnections()
+	if after.Requests-before.Requests != 3 || after.New-before.New != 1 || after.Reused-before.Reused != 2 {
+		t.Fatalf("connection counters %+v -> %+v", before, after)
+	}
+}
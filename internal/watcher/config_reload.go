@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	"gopkg.in/yaml.v3"
@@ -41,6 +42,8 @@ func (w *Watcher) scheduleConfigReload() {
 }
 
 func (w *Watcher) reloadConfigIfChanged() {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
 	data, err := os.ReadFile(w.configPath)
 	if err != nil {
 		log.Errorf("failed to read config file for hash check: %v", err)
@@ -63,28 +66,54 @@ func (w *Watcher) reloadConfigIfChanged() {
 	}
 	log.Infof("config file changed, reloading: %s", w.configPath)
 	if w.reloadConfig() {
-		finalHash := newHash
-		if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
-			sumUpdated := sha256.Sum256(updatedData)
-			finalHash = hex.EncodeToString(sumUpdated[:])
-		} else if errRead != nil {
-			log.WithError(errRead).Debug("failed to compute updated config hash after reload")
-		}
-		w.clientsMutex.Lock()
-		w.lastConfigHash = finalHash
-		w.clientsMutex.Unlock()
-		w.persistConfigAsync()
+		w.commitConfigReload(newHash)
+	}
+}
+
+// Reload reloads the configuration even when the file is unchanged, e.g. on
+// SIGHUP or a management API request. source is recorded in the reload status.
+func (w *Watcher) Reload(source string) error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	log.Infof("config reload requested (%s): %s", source, w.configPath)
+	if err := w.reloadConfigFrom(source); err != nil {
+		return err
 	}
+	w.commitConfigReload("")
+	return nil
+}
+
+// commitConfigReload records the hash of the reloaded file, falling back to
+// hash when the file cannot be read, and persists the config.
+func (w *Watcher) commitConfigReload(hash string) {
+	finalHash := hash
+	if updatedData, errRead := os.ReadFile(w.configPath); errRead == nil && len(updatedData) > 0 {
+		sumUpdated := sha256.Sum256(updatedData)
+		finalHash = hex.EncodeToString(sumUpdated[:])
+	} else if errRead != nil {
+		log.WithError(errRead).Debug("failed to compute updated config hash after reload")
+	}
+	w.clientsMutex.Lock()
+	w.lastConfigHash = finalHash
+	w.clientsMutex.Unlock()
+	w.persistConfigAsync()
 }
 
 func (w *Watcher) reloadConfig() bool {
+	return w.reloadConfigFrom(ReloadSourceFile) == nil
+}
+
+// reloadConfigFrom loads the config file, swaps it in and records the outcome
+// in the reload status.
+func (w *Watcher) reloadConfigFrom(source string) error {
 	log.Debug("=========================== CONFIG RELOAD ============================")
 	log.Debugf("starting config reload from: %s", w.configPath)
 
 	newConfig, errLoadConfig := config.LoadConfig(w.configPath)
 	if errLoadConfig != nil {
 		log.Errorf("failed to reload config: %v", errLoadConfig)
-		return false
+		recordReload(source, errLoadConfig, nil)
+		return errLoadConfig
 	}
 
 	if w.mirroredAuthDir != "" {
@@ -109,13 +138,14 @@ func (w *Watcher) reloadConfig() bool {
 		_, affectedOAuthProviders = diff.DiffOAuthExcludedModelChanges(oldConfig.OAuthExcludedModels, newConfig.OAuthExcludedModels)
 	}
 
-	util.SetLogLevel(newConfig)
+	logging.SetLogLevel(newConfig)
 	if oldConfig != nil && oldConfig.Debug != newConfig.Debug {
 		log.Debugf("log level updated - debug mode changed from %t to %t", oldConfig.Debug, newConfig.Debug)
 	}
 
+	var details []string
 	if oldConfig != nil {
-		details := diff.BuildConfigChangeDetails(oldConfig, newConfig)
+		details = diff.BuildConfigChangeDetails(oldConfig, newConfig)
 		if len(details) > 0 {
 			log.Debugf("config changes detected:")
 			for _, d := range details {
@@ -131,5 +161,6 @@ func (w *Watcher) reloadConfig() bool {
 
 	log.Infof("config successfully reloaded, triggering client reload")
 	w.reloadClients(authDirChanged, affectedOAuthProviders, forceAuthRefresh)
-	return true
+	recordReload(source, nil, details)
+	return nil
 }
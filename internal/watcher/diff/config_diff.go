@@ -72,6 +72,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	} else if !reflect.DeepEqual(trimStrings(oldCfg.APIKeys), trimStrings(newCfg.APIKeys)) {
 		changes = append(changes, "api-keys: values updated (count unchanged, redacted)")
 	}
+	if !reflect.DeepEqual(oldCfg.APIKeyPolicies, newCfg.APIKeyPolicies) {
+		changes = append(changes, fmt.Sprintf("api-key-policies: updated (%d -> %d entries, redacted)", len(oldCfg.APIKeyPolicies), len(newCfg.APIKeyPolicies)))
+	}
+	if oldCfg.APIKeysFile != newCfg.APIKeysFile {
+		changes = append(changes, fmt.Sprintf("api-keys-file: %s -> %s", oldCfg.APIKeysFile, newCfg.APIKeysFile))
+	}
 	if len(oldCfg.GeminiKey) != len(newCfg.GeminiKey) {
 		changes = append(changes, fmt.Sprintf("gemini-api-key count: %d -> %d", len(oldCfg.GeminiKey), len(newCfg.GeminiKey)))
 	} else {
@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoWatcher is returned by ReloadNow when no watcher is running.
+var ErrNoWatcher = errors.New("config watcher is not running")
+
+// running is the started watcher that ReloadNow uses.
+var running atomic.Pointer[Watcher]
+
+// ReloadNow reloads the configuration through the running watcher.
+func ReloadNow(source string) error {
+	w := running.Load()
+	if w == nil {
+		return ErrNoWatcher
+	}
+	return w.Reload(source)
+}
+
+// Reload sources recorded in ReloadStatus.
+const (
+	ReloadSourceFile   = "file"
+	ReloadSourceSignal = "signal"
+	ReloadSourceAPI    = "api"
+)
+
+// ReloadStatus describes the outcome of configuration reloads.
+type ReloadStatus struct {
+	// Generation counts successful reloads since startup.
+	Generation  int64     `json:"generation"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	// LastSource is what triggered the last attempt: file, signal or api.
+	LastSource string `json:"last_source,omitempty"`
+	// LastError is the error of the last attempt; empty when it succeeded.
+	LastError string `json:"last_error,omitempty"`
+	// LastChanges lists the redacted config changes applied by the last
+	// successful reload.
+	LastChanges []string `json:"last_changes,omitempty"`
+}
+
+var reloadStatus struct {
+	mu     sync.Mutex
+	status ReloadStatus
+}
+
+// CurrentReloadStatus returns a copy of the reload status.
+func CurrentReloadStatus() ReloadStatus {
+	reloadStatus.mu.Lock()
+	defer reloadStatus.mu.Unlock()
+	status := reloadStatus.status
+	status.LastChanges = append([]string(nil), status.LastChanges...)
+	return status
+}
+
+func recordReload(source string, err error, changes []string) {
+	reloadStatus.mu.Lock()
+	defer reloadStatus.mu.Unlock()
+	now := time.Now()
+	status := &reloadStatus.status
+	status.LastAttempt = now
+	status.LastSource = source
+	if err != nil {
+		status.LastError = err.Error()
+		return
+	}
+	status.Generation++
+	status.LastSuccess = now
+	status.LastError = ""
+	status.LastChanges = append([]string(nil), changes...)
+}
@@ -8,8 +8,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/geminicli"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // FileSynthesizer generates Auth entries from OAuth JSON files.
@@ -50,6 +52,10 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 		if errRead != nil || len(data) == 0 {
 			continue
 		}
+		if data, errRead = misc.DecryptCredentials(data); errRead != nil {
+			log.Warnf("skipping auth file %s: %v", name, errRead)
+			continue
+		}
 		var metadata map[string]any
 		if errUnmarshal := json.Unmarshal(data, &metadata); errUnmarshal != nil {
 			continue
@@ -34,6 +34,7 @@ type Watcher struct {
 	config            *config.Config
 	clientsMutex      sync.RWMutex
 	configReloadMu    sync.Mutex
+	reloadMu          sync.Mutex
 	configReloadTimer *time.Timer
 	reloadCallback    func(*config.Config)
 	watcher           *fsnotify.Watcher
@@ -108,11 +109,16 @@ func NewWatcher(configPath, authDir string, reloadCallback func(*config.Config))
 
 // Start begins watching the configuration file and authentication directory
 func (w *Watcher) Start(ctx context.Context) error {
-	return w.start(ctx)
+	if err := w.start(ctx); err != nil {
+		return err
+	}
+	running.Store(w)
+	return nil
 }
 
 // Stop stops the file watcher
 func (w *Watcher) Stop() error {
+	running.CompareAndSwap(w, nil)
 	w.stopDispatch()
 	w.stopConfigReloadTimer()
 	return w.watcher.Close()
@@ -264,6 +264,52 @@ func TestReloadConfigIfChanged_TriggersOnChangeAndSkipsUnchanged(t *testing.T) {
 	}
 }
 
+func TestReloadForcesUnchangedConfigAndRecordsStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	authDir := filepath.Join(tmpDir, "auth")
+	if err := os.MkdirAll(authDir, 0o755); err != nil {
+		t.Fatalf("failed to create auth dir: %v", err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8080\nauth-dir: "+authDir+"\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	reloads := 0
+	w := &Watcher{
+		configPath:     configPath,
+		authDir:        authDir,
+		reloadCallback: func(*config.Config) { reloads++ },
+	}
+	w.reloadConfigIfChanged()
+	before := CurrentReloadStatus()
+
+	if err := w.Reload(ReloadSourceAPI); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if reloads != 2 {
+		t.Fatalf("expected forced reload to trigger callback, callback count %d", reloads)
+	}
+	status := CurrentReloadStatus()
+	if status.Generation != before.Generation+1 || status.LastSource != ReloadSourceAPI || status.LastError != "" {
+		t.Fatalf("unexpected status after reload: %+v", status)
+	}
+
+	if err := os.WriteFile(configPath, []byte("port: [\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := w.Reload(ReloadSourceSignal); err == nil {
+		t.Fatal("expected invalid config to fail reload")
+	}
+	status = CurrentReloadStatus()
+	if status.Generation != before.Generation+1 || status.LastSource != ReloadSourceSignal || status.LastError == "" {
+		t.Fatalf("unexpected status after failed reload: %+v", status)
+	}
+	if w.config == nil || w.config.Port != 8080 {
+		t.Fatalf("expected previous config to stay active, got %+v", w.config)
+	}
+}
+
 func TestStartAndStopSuccess(t *testing.T) {
 	tmpDir := t.TempDir()
 	authDir := filepath.Join(tmpDir, "auth")
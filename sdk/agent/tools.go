@@ -0,0 +1,23 @@
+// Package agent exposes the agent mode tool registry so external projects can
+// register their own server-side tools next to the built-in ones.
+package agent
+
+import internalagent "github.com/router-for-me/CLIProxyAPI/v6/internal/agent"
+
+// Tool is a tool the proxy executes on behalf of the model in agent mode.
+type Tool = internalagent.Tool
+
+// Schema describes a tool to the model.
+type Schema = internalagent.Schema
+
+// RegisterTool makes a tool available to agent mode. A tool with the same name,
+// including a built-in one, is replaced. Whether it is offered to the model is
+// controlled by agent-mode.tools in the configuration.
+func RegisterTool(tool Tool) {
+	internalagent.Register(tool)
+}
+
+// RegisteredTools returns the names of all registered tools.
+func RegisteredTools() []string {
+	return internalagent.Names()
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agent"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mcp"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const defaultAgentMaxDepth = 4
+
+// ExecuteWithAgentLoop executes a non-streaming request like ExecuteWithAuthManager.
+// With agent mode enabled for a Claude Messages request, calls of the proxy's
+// built-in tools are executed locally and the conversation is sent upstream
+// again, up to the configured number of tool rounds; the executed steps are
+// prepended to the final response.
+func (h *BaseAPIHandler) ExecuteWithAgentLoop(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil || !h.Cfg.AgentMode.Enabled || handlerType != "claude" {
+		return h.ExecuteWithAuthManager(ctx, handlerType, modelName, rawJSON, alt)
+	}
+	return h.ExecuteIdempotent(ctx, handlerType, modelName, rawJSON, func(ctx context.Context) ([]byte, *interfaces.ErrorMessage) {
+		return h.runAgentLoop(ctx, handlerType, modelName, rawJSON, alt)
+	})
+}
+
+// runAgentLoop sends the request and its tool rounds upstream.
+func (h *BaseAPIHandler) runAgentLoop(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	cfg := h.Cfg.AgentMode
+	maxDepth := cfg.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultAgentMaxDepth
+	}
+
+	mcp.Default().Sync(ctx, h.Cfg.MCPServers)
+	clientTools := agent.ClientToolNames(rawJSON)
+	tools := make(map[string]agent.Tool)
+	var offered []agent.Tool
+	for _, tool := range agent.Tools(cfg.Tools) {
+		if _, taken := clientTools[tool.Name()]; taken {
+			continue
+		}
+		tools[tool.Name()] = tool
+		offered = append(offered, tool)
+	}
+	request := agent.FlattenSteps(rawJSON)
+	if len(offered) == 0 {
+		return h.ExecuteWithAuthManager(ctx, handlerType, modelName, request, alt)
+	}
+	request = agent.InjectTools(request, offered)
+
+	var transcript agent.Transcript
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, handlerType, modelName, request, alt)
+	for round := 1; errMsg == nil; round++ {
+		calls, ok := agent.PendingCalls(resp, tools)
+		if !ok || round > maxDepth {
+			break
+		}
+		results := agent.Execute(ctx, calls, tools)
+		logging.Entry(ctx).Debugf("agent mode: executed %d tool call(s) in round %d", len(results), round)
+		transcript.Add(resp, results)
+		request = agent.Continue(request, resp, results)
+		if round == maxDepth {
+			// Last round: make the model answer with what it has.
+			request, _ = sjson.SetBytes(request, "tool_choice", map[string]any{"type": "none"})
+		}
+		resp, errMsg = h.ExecuteWithAuthManager(ctx, handlerType, modelName, request, alt)
+	}
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if !gjson.ValidBytes(resp) {
+		return resp, nil
+	}
+	return transcript.Finish(resp), nil
+}
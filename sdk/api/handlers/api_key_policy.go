@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// keyRequestLog keeps the start times of the last minute's requests per
+// client API key.
+var keyRequestLog = struct {
+	sync.Mutex
+	starts map[string][]time.Time
+}{starts: make(map[string][]time.Time)}
+
+// applyKeyPolicy checks a request against the api-key-policies entry of the
+// calling key and its tenant, and returns the model name to route, which
+// carries the credential prefix of the key or tenant when it is bound to one.
+// Rate limits apply only when countRequest is set.
+func (h *BaseAPIHandler) applyKeyPolicy(ctx context.Context, modelName string, countRequest bool) (string, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil {
+		return modelName, nil
+	}
+	apiKey := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	policy := h.Cfg.KeyPolicy(apiKey)
+	if policy == nil {
+		return modelName, nil
+	}
+	entry := logging.Entry(ctx).WithField("api_key_name", policy.Name)
+	tenant := h.Cfg.TenantOf(apiKey)
+
+	prefix := strings.TrimSpace(policy.Prefix)
+	if prefix == "" && tenant != nil {
+		prefix = strings.TrimSpace(tenant.Prefix)
+	}
+	requested := modelName
+	if prefix != "" {
+		requested = strings.TrimPrefix(modelName, prefix+"/")
+		modelName = prefix + "/" + requested
+	}
+	if !policy.AllowsModel(thinking.ParseSuffix(requested).ModelName) {
+		entry.Warnf("api key policy: model %s is not allowed", requested)
+		return modelName, &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("model %s is not allowed for this API key", requested)}
+	}
+	if countRequest && policy.RequestsPerMinute > 0 {
+		if wait := reserveKeyRequest(apiKey, policy.RequestsPerMinute, time.Now()); wait > 0 {
+			entry.WithFields(log.Fields{"limit": policy.RequestsPerMinute}).Warn("api key policy: rate limit exceeded")
+			addon := http.Header{}
+			addon.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return modelName, &interfaces.ErrorMessage{
+				StatusCode: http.StatusTooManyRequests,
+				Error:      fmt.Errorf("rate limit of %d requests per minute exceeded for this API key", policy.RequestsPerMinute),
+				Addon:      addon,
+			}
+		}
+	}
+	if countRequest && tenant != nil && tenant.RequestsPerMinute > 0 {
+		if wait := reserveKeyRequest(tenantRateKey(tenant.ID), tenant.RequestsPerMinute, time.Now()); wait > 0 {
+			entry.WithFields(log.Fields{"limit": tenant.RequestsPerMinute}).Warn("api key policy: tenant rate limit exceeded")
+			addon := http.Header{}
+			addon.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return modelName, &interfaces.ErrorMessage{
+				StatusCode: http.StatusTooManyRequests,
+				Error:      fmt.Errorf("rate limit of %d requests per minute exceeded for this tenant", tenant.RequestsPerMinute),
+				Addon:      addon,
+			}
+		}
+	}
+	return modelName, nil
+}
+
+// tenantRateKey is the request log key of a tenant. It cannot collide with a
+// client API key, which never contains a NUL byte.
+func tenantRateKey(id string) string {
+	return "tenant\x00" + id
+}
+
+// reserveKeyRequest records a request for apiKey when fewer than limit
+// requests started within the minute before now. Otherwise it returns how
+// long until the oldest of them leaves the window.
+func reserveKeyRequest(apiKey string, limit int, now time.Time) time.Duration {
+	keyRequestLog.Lock()
+	defer keyRequestLog.Unlock()
+	starts := keyRequestLog.starts[apiKey]
+	cutoff := now.Add(-time.Minute)
+	kept := starts[:0]
+	for _, start := range starts {
+		if start.After(cutoff) {
+			kept = append(kept, start)
+		}
+	}
+	if len(kept) >= limit {
+		keyRequestLog.starts[apiKey] = kept
+		return kept[len(kept)-limit].Sub(cutoff)
+	}
+	keyRequestLog.starts[apiKey] = append(kept, now)
+	return 0
+}
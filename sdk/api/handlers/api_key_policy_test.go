@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestApplyKeyPolicy(t *testing.T) {
+	cfg := &config.SDKConfig{APIKeyPolicies: []config.APIKeyPolicy{
+		{APIKey: "team-a", Models: []string{"claude-*"}, RequestsPerMinute: 2, Prefix: "teamA"},
+	}}
+	h := NewBaseAPIHandlers(cfg, nil)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("apiKey", "team-a")
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	model, errMsg := h.applyKeyPolicy(ctx, "claude-sonnet-4-5(8192)", true)
+	if errMsg != nil || model != "teamA/claude-sonnet-4-5(8192)" {
+		t.Fatalf("allowed model: %q, %v", model, errMsg)
+	}
+	if _, errMsg = h.applyKeyPolicy(ctx, "gpt-5", false); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a model outside the allowlist, got %v", errMsg)
+	}
+	if model, errMsg = h.applyKeyPolicy(ctx, "teamA/claude-opus-4-1", true); errMsg != nil || model != "teamA/claude-opus-4-1" {
+		t.Fatalf("prefixed model: %q, %v", model, errMsg)
+	}
+	_, errMsg = h.applyKeyPolicy(ctx, "claude-opus-4-1", true)
+	if errMsg == nil || errMsg.StatusCode != http.StatusTooManyRequests || errMsg.Addon.Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %+v", errMsg)
+	}
+
+	// Keys without a policy are unaffected.
+	c.Set("apiKey", "other")
+	if model, errMsg = h.applyKeyPolicy(ctx, "gpt-5", true); errMsg != nil || model != "gpt-5" {
+		t.Fatalf("unscoped key: %q, %v", model, errMsg)
+	}
+}
+
+func TestApplyKeyPolicyTenant(t *testing.T) {
+	cfg := &config.SDKConfig{
+		APIKeyPolicies: []config.APIKeyPolicy{
+			{APIKey: "acme-1", Tenant: "acme"},
+			{APIKey: "acme-2", Tenant: "acme", Prefix: "own"},
+		},
+		Tenants: []config.Tenant{{ID: "acme", RequestsPerMinute: 2, Prefix: "acme"}},
+	}
+	h := NewBaseAPIHandlers(cfg, nil)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Set("apiKey", "acme-1")
+	ctx, cancel := h.GetContextWithCancel(nil, c, context.Background())
+	defer cancel()
+	if got := c.GetString("tenant"); got != "acme" {
+		t.Fatalf("tenant = %q", got)
+	}
+
+	if model, errMsg := h.applyKeyPolicy(ctx, "gpt-5", true); errMsg != nil || model != "acme/gpt-5" {
+		t.Fatalf("tenant prefix: %q, %v", model, errMsg)
+	}
+	// The key's own prefix wins, and both keys share the tenant's limit.
+	c.Set("apiKey", "acme-2")
+	if model, errMsg := h.applyKeyPolicy(ctx, "gpt-5", true); errMsg != nil || model != "own/gpt-5" {
+		t.Fatalf("key prefix: %q, %v", model, errMsg)
+	}
+	c.Set("apiKey", "acme-1")
+	if _, errMsg := h.applyKeyPolicy(ctx, "gpt-5", true); errMsg == nil || errMsg.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the tenant limit, got %+v", errMsg)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// assistantPrefillMetadataKey carries the prefill text from the request pass
+// to the response, where it is prepended to the completion.
+const assistantPrefillMetadataKey = "assistant_prefill"
+
+const defaultPrefillHint = "Continue your previous message from exactly where it stops. Do not repeat any of it and do not add a preamble."
+
+// applyAssistantPrefill makes prefill-based prompting work on backends that do
+// not continue a trailing assistant message: the message stays in the history
+// and a user turn asks the model to continue it. Models served by a native
+// Claude backend handle prefill themselves and are left alone.
+func (h *BaseAPIHandler) applyAssistantPrefill(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil || !h.Cfg.AssistantPrefill.Enabled || handlerType != "claude" {
+		return rawJSON, nil
+	}
+	prefill, ok := util.ClaudeAssistantPrefill(rawJSON)
+	if !ok || h.servedNatively(payloadModel(ctx, rawJSON), "claude") {
+		return rawJSON, nil
+	}
+	hint := h.Cfg.AssistantPrefill.Hint
+	if hint == "" {
+		hint = defaultPrefillHint
+	}
+	out, err := sjson.SetBytes(rawJSON, "messages.-1", map[string]any{"role": "user", "content": hint})
+	if err != nil {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("assistant prefill: asking the model to continue %d characters", len(prefill))
+	if h.Cfg.AssistantPrefill.OmitPrefill {
+		return out, nil
+	}
+	return out, map[string]any{assistantPrefillMetadataKey: prefill}
+}
+
+// servedNatively reports whether every provider of model is provider.
+func (h *BaseAPIHandler) servedNatively(model, provider string) bool {
+	providers, _, errMsg := h.getRequestDetails(model)
+	if errMsg != nil || len(providers) == 0 {
+		return false
+	}
+	for _, p := range providers {
+		if p != provider {
+			return false
+		}
+	}
+	return true
+}
+
+// assistantPrefill returns the prefill to prepend to the response, if any.
+func assistantPrefill(meta map[string]any) string {
+	prefill, _ := meta[assistantPrefillMetadataKey].(string)
+	return prefill
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// attachmentsMetadataKey records how many attachments were replaced, by kind.
+const attachmentsMetadataKey = "attachments_replaced"
+
+// nativeAttachmentProviders lists, per request schema, the provider that reads
+// document and audio blocks of that schema itself.
+var nativeAttachmentProviders = map[string]string{
+	"claude":          "claude",
+	"openai-response": "codex",
+}
+
+// applyAttachmentPolicy replaces document and audio blocks according to the
+// configured per-kind policy, unless the model is served by a backend that
+// accepts them natively.
+func (h *BaseAPIHandler) applyAttachmentPolicy(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	cfg := h.Cfg.Attachments
+	if attachmentPolicy(cfg.Document) == util.AttachmentPass && attachmentPolicy(cfg.Audio) == util.AttachmentPass {
+		return rawJSON, nil
+	}
+	if native, ok := nativeAttachmentProviders[handlerType]; ok && h.servedNatively(payloadModel(ctx, rawJSON), native) {
+		return rawJSON, nil
+	}
+	out, replaced := util.RewriteAttachments(handlerType, rawJSON, func(kind string) string {
+		if kind == util.AttachmentAudio {
+			return attachmentPolicy(cfg.Audio)
+		}
+		return attachmentPolicy(cfg.Document)
+	})
+	if len(replaced) == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("attachments: replaced %v", replaced)
+	return out, map[string]any{attachmentsMetadataKey: replaced}
+}
+
+// attachmentPolicy normalizes a configured policy, defaulting to pass.
+func attachmentPolicy(value string) string {
+	switch policy := strings.ToLower(strings.TrimSpace(value)); policy {
+	case util.AttachmentText, util.AttachmentMarker:
+		return policy
+	default:
+		return util.AttachmentPass
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/inspect"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"golang.org/x/net/context"
+)
+
+// auditTrail collects the audit record of one request alongside its
+// inspection. Completions are hashed incrementally in hash mode and kept up to
+// the configured size in full mode.
+type auditTrail struct {
+	logger              *audit.Logger
+	record              audit.Record
+	prompt              []byte
+	completion          []byte
+	completionHash      hash.Hash
+	completionTruncated bool
+}
+
+// startAuditTrail returns a trail for the request, or nil when auditing is off.
+func (h *BaseAPIHandler) startAuditTrail(ctx context.Context) *auditTrail {
+	logger := audit.Default()
+	if logger == nil {
+		return nil
+	}
+	trail := &auditTrail{logger: logger}
+	if logger.Content() == audit.ContentHash {
+		trail.completionHash = sha256.New()
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if apiKey := ginCtx.GetString("apiKey"); apiKey != "" {
+			trail.record.KeyHash = audit.Digest([]byte(apiKey))
+			if h != nil && h.Cfg != nil {
+				if policy := h.Cfg.KeyPolicy(apiKey); policy != nil {
+					trail.record.Client = policy.Name
+				}
+				if tenant := h.Cfg.TenantOf(apiKey); tenant != nil {
+					trail.record.Tenant = tenant.ID
+				}
+			}
+		}
+		trail.record.ClientIP = ginCtx.ClientIP()
+	}
+	return trail
+}
+
+// request records the payload forwarded upstream.
+func (t *auditTrail) request(payload []byte) {
+	if t == nil || t.logger.Content() == audit.ContentNone {
+		return
+	}
+	t.prompt = payload
+}
+
+// response records response bytes as returned to the client.
+func (t *auditTrail) response(payload []byte) {
+	if t == nil {
+		return
+	}
+	switch {
+	case t.completionHash != nil:
+		t.completionHash.Write(payload)
+	case t.logger.Content() == audit.ContentFull:
+		limit := t.logger.MaxBodyBytes()
+		if room := limit - len(t.completion); len(payload) > room {
+			payload = payload[:max(room, 0)]
+			t.completionTruncated = true
+		}
+		t.completion = append(t.completion, payload...)
+	}
+}
+
+// finish writes the audit record using the summary gathered by the inspection.
+func (t *auditTrail) finish(ctx context.Context, summary *inspect.Record, errMsg *interfaces.ErrorMessage) {
+	if t == nil || summary == nil {
+		return
+	}
+	record := t.record
+	record.Time = summary.Time
+	record.RequestID = summary.ID
+	record.Format = summary.Format
+	record.Model = summary.Model
+	record.UpstreamModel = summary.UpstreamModel
+	record.Providers = summary.Providers
+	record.Stream = summary.Stream
+	record.Status = summary.Status
+	record.Error = summary.Error
+	record.LatencyMS = summary.LatencyMS
+	record.Usage = audit.Usage(summary.Usage)
+	record.StopReason = summary.StopReason
+	record.Outcome = "success"
+	if record.Status >= http.StatusBadRequest {
+		record.Outcome = "error"
+	}
+	switch t.logger.Content() {
+	case audit.ContentHash:
+		if len(t.prompt) > 0 {
+			record.PromptHash = audit.Digest(t.prompt)
+		}
+		if errMsg == nil {
+			record.CompletionHash = hex.EncodeToString(t.completionHash.Sum(nil))
+		}
+	case audit.ContentFull:
+		prompt := t.prompt
+		if limit := t.logger.MaxBodyBytes(); len(prompt) > limit {
+			prompt, record.PromptTruncated = prompt[:limit], true
+		}
+		record.Prompt = string(prompt)
+		record.Completion, record.CompletionTruncated = string(t.completion), t.completionTruncated
+	}
+	if err := t.logger.Write(&record); err != nil {
+		logging.Entry(ctx).Warnf("audit: failed to write record: %v", err)
+	}
+}
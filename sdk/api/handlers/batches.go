@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultBatchConcurrency    = 4
+	defaultBatchMaxRequests    = 10000
+	defaultBatchRetentionHours = 24
+	defaultBatchMaxInputBytes  = 200 << 20
+)
+
+// batchEndpoints maps the URLs accepted in batch input lines to handler types.
+var batchEndpoints = map[string]string{
+	"/v1/chat/completions": "openai",
+	"/v1/messages":         "claude",
+	"/v1/responses":        "openai-response",
+}
+
+// batchCallerKeys are the request values set by client authentication that a
+// batch carries to its requests.
+var batchCallerKeys = []string{"apiKey", "accessProvider", "accessMetadata"}
+
+// batchManager returns the handler's batch manager, creating it on first use.
+func (h *BaseAPIHandler) batchManager() *batch.Manager {
+	h.batchesOnce.Do(func() {
+		engine := gin.New()
+		h.batches = batch.NewManager(func(ctx context.Context, caller batch.Caller, url string, body []byte) (int, []byte) {
+			return h.runBatchRequest(h.batchRequestContext(ctx, engine, caller, url), url, body)
+		}, h.batchLimits)
+	})
+	return h.batches
+}
+
+// batchCaller identifies the client of a batch request.
+func batchCaller(c *gin.Context) batch.Caller {
+	caller := batch.Caller{APIKey: c.GetString("apiKey"), ClientIP: c.ClientIP()}
+	for _, key := range batchCallerKeys {
+		if value, ok := c.Get(key); ok {
+			if caller.Keys == nil {
+				caller.Keys = make(map[string]any, len(batchCallerKeys))
+			}
+			caller.Keys[key] = value
+		}
+	}
+	return caller
+}
+
+// batchRequestContext returns the context one batch line runs with. The
+// client's gin context is gone by then, so a detached one is built carrying
+// the caller's authentication values; key policies, tenant attribution, the
+// rate-limit queue and auditing then see the batch's creator.
+func (h *BaseAPIHandler) batchRequestContext(ctx context.Context, engine *gin.Engine, caller batch.Caller, url string) context.Context {
+	ginCtx := gin.CreateTestContextOnly(httptest.NewRecorder(), engine)
+	ginCtx.Request, _ = http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if caller.ClientIP != "" {
+		ginCtx.Request.RemoteAddr = net.JoinHostPort(caller.ClientIP, "0")
+	}
+	for key, value := range caller.Keys {
+		ginCtx.Set(key, value)
+	}
+	ctx = context.WithValue(ctx, "gin", ginCtx)
+	if h.Cfg != nil {
+		if tenant := h.Cfg.TenantOf(caller.APIKey); tenant != nil {
+			ginCtx.Set(logging.GinTenantKey, tenant.ID)
+			ctx = logging.WithLogFields(ctx, log.Fields{logging.FieldTenant: tenant.ID})
+		}
+	}
+	return ctx
+}
+
+// batchLimits reads the batch settings from the current configuration.
+func (h *BaseAPIHandler) batchLimits() batch.Limits {
+	limits := batch.Limits{
+		Concurrency: defaultBatchConcurrency,
+		MaxRequests: defaultBatchMaxRequests,
+		Retention:   defaultBatchRetentionHours * time.Hour,
+	}
+	if h.Cfg == nil {
+		return limits
+	}
+	cfg := h.Cfg.Batches
+	if cfg.Concurrency > 0 {
+		limits.Concurrency = cfg.Concurrency
+	}
+	if cfg.MaxRequests > 0 {
+		limits.MaxRequests = cfg.MaxRequests
+	}
+	if cfg.RetentionHours > 0 {
+		limits.Retention = time.Duration(cfg.RetentionHours) * time.Hour
+	}
+	limits.RequestsPerMinute = cfg.RequestsPerMinute
+	return limits
+}
+
+// runBatchRequest executes one batch line as a non-streaming request.
+func (h *BaseAPIHandler) runBatchRequest(ctx context.Context, url string, body []byte) (int, []byte) {
+	handlerType := batchEndpoints[url]
+	if updated, err := sjson.DeleteBytes(body, "stream"); err == nil {
+		body = updated
+	}
+	modelName := gjson.GetBytes(body, "model").String()
+	out, errMsg := h.ExecuteWithAuthManager(ctx, handlerType, modelName, body, "")
+	if errMsg == nil {
+		return http.StatusOK, out
+	}
+	status := errMsg.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	errText := http.StatusText(status)
+	if errMsg.Error != nil {
+		errText = errMsg.Error.Error()
+	}
+	return status, BuildErrorResponseBody(status, errText)
+}
+
+// batchMaxInputBytes returns the largest body CreateBatch reads.
+func (h *BaseAPIHandler) batchMaxInputBytes() int64 {
+	if h.Cfg != nil && h.Cfg.Batches.MaxInputBytes > 0 {
+		return h.Cfg.Batches.MaxInputBytes
+	}
+	return defaultBatchMaxInputBytes
+}
+
+// CreateBatch accepts a JSONL body of OpenAI batch request lines
+// ({"custom_id", "method", "url", "body"}) and starts processing them in the
+// background. Lines without a url use the "endpoint" query parameter, which
+// defaults to /v1/chat/completions.
+func (h *BaseAPIHandler) CreateBatch(c *gin.Context) {
+	limit := h.batchMaxInputBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	input, err := c.GetRawData()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBatchError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch input exceeds %d bytes", limit))
+			return
+		}
+		writeBatchError(c, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	endpoint := strings.TrimSpace(c.Query("endpoint"))
+	if endpoint == "" {
+		endpoint = "/v1/chat/completions"
+	}
+	var metadata map[string]string
+	if description := strings.TrimSpace(c.Query("description")); description != "" {
+		metadata = map[string]string{"description": description}
+	}
+	created, err := h.batchManager().Create(batchCaller(c), input, endpoint, func(url string) bool {
+		_, ok := batchEndpoints[url]
+		return ok
+	}, metadata)
+	if err != nil {
+		writeBatchError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, created)
+}
+
+// ListBatches lists the caller's batches, newest first.
+func (h *BaseAPIHandler) ListBatches(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": h.batchManager().List(c.GetString("apiKey"))})
+}
+
+// GetBatch returns the status of one of the caller's batches. Batches of other
+// API keys are reported as not found.
+func (h *BaseAPIHandler) GetBatch(c *gin.Context) {
+	found, ok := h.batchManager().Get(c.GetString("apiKey"), c.Param("id"))
+	if !ok {
+		writeBatchError(c, http.StatusNotFound, "batch not found")
+		return
+	}
+	c.JSON(http.StatusOK, found)
+}
+
+// CancelBatch stops a running batch of the caller.
+func (h *BaseAPIHandler) CancelBatch(c *gin.Context) {
+	cancelled, ok := h.batchManager().Cancel(c.GetString("apiKey"), c.Param("id"))
+	if !ok {
+		writeBatchError(c, http.StatusNotFound, "batch not found")
+		return
+	}
+	c.JSON(http.StatusOK, cancelled)
+}
+
+// GetBatchOutput returns the results of the finished requests of one of the
+// caller's batches as JSONL.
+func (h *BaseAPIHandler) GetBatchOutput(c *gin.Context) {
+	output, ok := h.batchManager().Output(c.GetString("apiKey"), c.Param("id"))
+	if !ok {
+		writeBatchError(c, http.StatusNotFound, "batch not found")
+		return
+	}
+	c.Data(http.StatusOK, "application/jsonl", output)
+}
+
+func writeBatchError(c *gin.Context, status int, message string) {
+	c.Data(status, "application/json", BuildErrorResponseBody(status, message))
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+)
+
+func TestBatchRequestContextRunsAsCaller(t *testing.T) {
+	cfg := &config.SDKConfig{APIKeyPolicies: []config.APIKeyPolicy{
+		{APIKey: "team-a", Models: []string{"claude-*"}, Tenant: "acme"},
+	}}
+	h := NewBaseAPIHandlers(cfg, nil)
+	caller := batch.Caller{APIKey: "team-a", ClientIP: "10.1.2.3", Keys: map[string]any{"apiKey": "team-a"}}
+
+	ctx := h.batchRequestContext(context.Background(), gin.New(), caller, "/v1/messages")
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok {
+		t.Fatal("runner context has no gin context")
+	}
+	if ginCtx.GetString("apiKey") != "team-a" || ginCtx.GetString(logging.GinTenantKey) != "acme" || ginCtx.ClientIP() != "10.1.2.3" {
+		t.Fatalf("runner context keys = %v, ip = %q", ginCtx.Keys, ginCtx.ClientIP())
+	}
+	if _, errMsg := h.applyKeyPolicy(ctx, "gpt-5", false); errMsg == nil || errMsg.StatusCode != http.StatusForbidden {
+		t.Fatalf("key policy not applied to batch requests: %v", errMsg)
+	}
+}
+
+func TestBatchEndpointsAreScopedToAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&config.SDKConfig{}, nil)
+	serve := func(apiKey, method, path, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set("apiKey", apiKey) })
+		router.POST("/v1/batches", h.CreateBatch)
+		router.GET("/v1/batches", h.ListBatches)
+		router.GET("/v1/batches/:id", h.GetBatch)
+		router.POST("/v1/batches/:id/cancel", h.CancelBatch)
+		router.GET("/v1/batches/:id/output", h.GetBatchOutput)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	created := serve("key-a", http.MethodPost, "/v1/batches", `{"body":{"model":"none"}}`)
+	if created.Code != http.StatusOK {
+		t.Fatalf("create = %d %s", created.Code, created.Body)
+	}
+	id := gjson.Get(created.Body.String(), "id").String()
+
+	for _, path := range []string{"/v1/batches/" + id, "/v1/batches/" + id + "/output"} {
+		if rec := serve("key-b", http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("GET %s by another key = %d", path, rec.Code)
+		}
+	}
+	if rec := serve("key-b", http.MethodPost, "/v1/batches/"+id+"/cancel", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("cancel by another key = %d", rec.Code)
+	}
+	if rec := serve("key-b", http.MethodGet, "/v1/batches", ""); gjson.Get(rec.Body.String(), "data.#").Int() != 0 {
+		t.Fatalf("list by another key = %s", rec.Body)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := serve("key-a", http.MethodGet, "/v1/batches/"+id, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET by owner = %d", rec.Code)
+		}
+		if gjson.Get(rec.Body.String(), "status").String() == batch.StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch did not finish: %s", rec.Body)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCreateBatchRejectsOversizedInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&config.SDKConfig{Batches: config.BatchConfig{MaxInputBytes: 64}}, nil)
+	router := gin.New()
+	router.POST("/v1/batches", h.CreateBatch)
+
+	rec := httptest.NewRecorder()
+	body := strings.Repeat(`{"body":{"model":"none"}}`+"\n", 10)
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized batch = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// builtinToolsConvertedKey records in execution metadata the built-in tool
+// types declared as function tools.
+const builtinToolsConvertedKey = "builtin_tools_converted"
+
+// applyBuiltinTools declares OpenAI built-in tools such as code_interpreter as
+// function tools when builtin-tools is "convert", so upstreams that do not run
+// them still offer them to the model instead of dropping them. Otherwise they
+// are forwarded, and strict translation rejects requests that would lose them.
+func (h *BaseAPIHandler) applyBuiltinTools(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil || strings.ToLower(strings.TrimSpace(h.Cfg.BuiltinTools)) != util.BuiltinToolsConvert {
+		return rawJSON, nil
+	}
+	out, converted := util.ConvertBuiltinTools(handlerType, rawJSON)
+	if len(converted) == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("builtin tools: declared %s as function tools", strings.Join(converted, ", "))
+	return out, map[string]any{builtinToolsConvertedKey: converted}
+}
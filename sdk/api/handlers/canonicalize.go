@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// canonicalIgnoredFields are top-level request fields that do not change the
+// generated content and are therefore left out of the canonical form.
+var canonicalIgnoredFields = []string{"stream", "stream_options", "user", "metadata"}
+
+// canonicalFormats lists the request schemas accepted by Canonicalize.
+var canonicalFormats = map[string]struct{}{
+	"openai": {}, "openai-response": {}, "claude": {}, "gemini": {},
+}
+
+// Canonicalize serves POST /v1/canonicalize. It applies the same payload passes
+// and model resolution as an executed request (role mapping, transcript repair,
+// sanitization, secret masking, ...) and returns the resulting canonical JSON
+// and its SHA-256 hash without contacting any upstream. Clients can use the
+// hash as a cache or dedup key that matches exactly what the proxy would send.
+//
+// The body is {"format": "openai", "model": "...", "request": {...}}; format
+// defaults to "openai" and model to the request's own "model" field.
+func (h *BaseAPIHandler) Canonicalize(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(body) {
+		h.WriteErrorResponse(c, canonicalizeError("request body must be a JSON object"))
+		return
+	}
+	envelope := gjson.ParseBytes(body)
+	request := envelope.Get("request")
+	if !request.IsObject() {
+		h.WriteErrorResponse(c, canonicalizeError(`"request" must be a JSON object`))
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(envelope.Get("format").String()))
+	if format == "" {
+		format = "openai"
+	}
+	if _, ok := canonicalFormats[format]; !ok {
+		h.WriteErrorResponse(c, canonicalizeError(fmt.Sprintf("unsupported format %q", format)))
+		return
+	}
+	modelName := strings.TrimSpace(envelope.Get("model").String())
+	if modelName == "" {
+		modelName = strings.TrimSpace(request.Get("model").String())
+	}
+	if modelName == "" {
+		h.WriteErrorResponse(c, canonicalizeError("model is required"))
+		return
+	}
+
+	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		return
+	}
+	ctx := context.WithValue(c.Request.Context(), "gin", c)
+	payload, meta := h.preparePayload(ctx, format, modelName, []byte(request.Raw))
+	if format != "gemini" {
+		if updated, errSet := sjson.SetBytes(payload, "model", normalizedModel); errSet == nil {
+			payload = updated
+		}
+	}
+	canonical, err := canonicalJSON(payload)
+	if err != nil {
+		h.WriteErrorResponse(c, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err})
+		return
+	}
+	sum := sha256.Sum256(canonical)
+
+	transformations := make([]string, 0, len(meta))
+	for key := range meta {
+		transformations = append(transformations, key)
+	}
+	sort.Strings(transformations)
+
+	// PureJSON keeps the canonical bytes identical to the hashed ones.
+	c.PureJSON(http.StatusOK, gin.H{
+		"format":          format,
+		"model":           normalizedModel,
+		"providers":       providers,
+		"hash":            "sha256:" + hex.EncodeToString(sum[:]),
+		"canonical":       json.RawMessage(canonical),
+		"ignored_fields":  canonicalIgnoredFields,
+		"transformations": transformations,
+	})
+}
+
+func canonicalizeError(message string) *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(message)}
+}
+
+// canonicalJSON re-encodes payload with sorted object keys, no insignificant
+// whitespace and no HTML escaping, dropping canonicalIgnoredFields. Numbers
+// keep their original literal form.
+func canonicalJSON(payload []byte) ([]byte, error) {
+	for _, field := range canonicalIgnoredFields {
+		payload, _ = sjson.DeleteBytes(payload, field)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+func TestCanonicalize_StableHashAcrossEquivalentRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modelRegistry := registry.GetGlobalRegistry()
+	modelRegistry.RegisterClient("test-canonicalize", "openai", []*registry.ModelInfo{{ID: "canonical-model"}})
+	t.Cleanup(func() { modelRegistry.UnregisterClient("test-canonicalize") })
+
+	h := NewBaseAPIHandlers(&config.SDKConfig{}, nil)
+	canonicalize := func(body string) gjson.Result {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/canonicalize", strings.NewReader(body))
+		h.Canonicalize(c)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+		return gjson.Parse(rec.Body.String())
+	}
+
+	first := canonicalize(`{"request":{"model":"canonical-model","stream":true,"messages":[{"role":"user","content":"a<b"}],"temperature":0.50}}`)
+	second := canonicalize(`{"format":"openai","request":{"temperature":0.50,  "messages":[{"content":"a<b","role":"user"}],"model":"canonical-model","user":"u1"}}`)
+	if first.Get("hash").String() != second.Get("hash").String() {
+		t.Fatalf("hashes differ:\n%s\n%s", first.Get("canonical").Raw, second.Get("canonical").Raw)
+	}
+	if got := first.Get("canonical").Raw; got != `{"messages":[{"content":"a<b","role":"user"}],"model":"canonical-model","temperature":0.50}` {
+		t.Fatalf("canonical = %s", got)
+	}
+
+	third := canonicalize(`{"request":{"model":"canonical-model","messages":[{"role":"user","content":"other"}]}}`)
+	if third.Get("hash").String() == first.Get("hash").String() {
+		t.Fatal("different requests share a hash")
+	}
+}
@@ -11,9 +11,13 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
@@ -123,14 +127,78 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 }
 
 // ClaudeModels handles the Claude models listing endpoint.
-// It returns a JSON response containing available Claude models and their specifications.
+// It returns the available models in the Anthropic Models API shape, paged
+// with the limit, after_id and before_id query parameters.
 //
 // Parameters:
 //   - c: The Gin context for the request.
 func (h *ClaudeCodeAPIHandler) ClaudeModels(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"data": h.Models(),
+	models := h.Models()
+	data := make([]gin.H, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			continue
+		}
+		displayName, _ := model["display_name"].(string)
+		if displayName == "" {
+			displayName = id
+		}
+		created, _ := model["created"].(int64)
+		data = append(data, gin.H{
+			"type":         "model",
+			"id":           id,
+			"display_name": displayName,
+			"created_at":   time.Unix(created, 0).UTC().Format(time.RFC3339),
+		})
+	}
+
+	// Newest first, as Anthropic lists them; ids keep the paging stable.
+	sort.SliceStable(data, func(i, j int) bool {
+		if data[i]["created_at"] != data[j]["created_at"] {
+			return data[i]["created_at"].(string) > data[j]["created_at"].(string)
+		}
+		return data[i]["id"].(string) < data[j]["id"].(string)
 	})
+
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{Error: handlers.ErrorDetail{Message: "limit must be between 1 and 1000", Type: "invalid_request_error"}})
+			return
+		}
+		limit = parsed
+	}
+	start, end := 0, len(data)
+	for i, model := range data {
+		if afterID := c.Query("after_id"); afterID != "" && model["id"] == afterID {
+			start = i + 1
+		}
+		if beforeID := c.Query("before_id"); beforeID != "" && model["id"] == beforeID {
+			end = i
+		}
+	}
+	if start > end {
+		start = end
+	}
+	hasMore := false
+	if end-start > limit {
+		hasMore = true
+		if c.Query("before_id") != "" && c.Query("after_id") == "" {
+			start = end - limit
+		} else {
+			end = start + limit
+		}
+	}
+	page := data[start:end]
+
+	response := gin.H{"data": page, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		response["first_id"] = page[0]["id"]
+		response["last_id"] = page[len(page)-1]["id"]
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // handleNonStreamingResponse handles non-streaming content generation requests for Claude models.
@@ -150,7 +218,7 @@ func (h *ClaudeCodeAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSO
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 
-	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
+	resp, errMsg := h.ExecuteWithAgentLoop(cliCtx, h.HandlerType(), modelName, rawJSON, alt)
 	stopKeepAlive()
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
@@ -240,8 +308,10 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 			return
 		case chunk, ok := <-dataChan:
 			if !ok {
-				// Stream closed without data? Send DONE or just headers.
+				// The stream closed without data; tell the client instead of
+				// leaving it waiting for a message that never comes.
 				setSSEHeaders()
+				writeClaudeStreamError(c.Writer, http.StatusBadGateway, errIncompleteStream)
 				flusher.Flush()
 				cliCancel(nil)
 				return
@@ -251,42 +321,75 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 			setSSEHeaders()
 
 			// Write the first chunk
+			state := &claudeStreamState{}
 			if len(chunk) > 0 {
+				state.observe(chunk)
 				_, _ = c.Writer.Write(chunk)
 				flusher.Flush()
 			}
 
 			// Continue streaming the rest
-			h.forwardClaudeStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+			h.forwardClaudeStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, state)
 			return
 		}
 	}
 }
 
-func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+func (h *ClaudeCodeAPIHandler) forwardClaudeStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, state *claudeStreamState) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
 		WriteChunk: func(chunk []byte) {
 			if len(chunk) == 0 {
 				return
 			}
+			state.observe(chunk)
 			_, _ = c.Writer.Write(chunk)
 		},
+		// Headers are already sent, so the failure is reported the way the
+		// Anthropic API does it: an error event, after which the stream ends.
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
 				return
 			}
-			status := http.StatusInternalServerError
-			if errMsg.StatusCode > 0 {
-				status = errMsg.StatusCode
+			writeClaudeStreamError(c.Writer, errMsg.StatusCode, errMsg.Error)
+		},
+		// An upstream that stops before message_stop would leave Claude SDK
+		// clients waiting for the rest of the message.
+		WriteDone: func() {
+			if !state.stopped {
+				writeClaudeStreamError(c.Writer, http.StatusBadGateway, errIncompleteStream)
 			}
-			c.Status(status)
-
-			errorBytes, _ := json.Marshal(h.toClaudeError(errMsg))
-			_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", errorBytes)
+		},
+		// Anthropic clients expect ping events rather than SSE comments while the
+		// upstream is silent (e.g. during long tool argument generation).
+		WriteKeepAlive: func() {
+			_, _ = c.Writer.Write([]byte("event: ping\ndata: {\"type\": \"ping\"}\n\n"))
 		},
 	})
 }
 
+// errIncompleteStream is reported when the upstream stream ends before the
+// message is complete.
+var errIncompleteStream = errors.New("upstream stream ended before the message was complete")
+
+// claudeStreamState tracks what a forwarded Claude stream has sent.
+type claudeStreamState struct {
+	// stopped is set once a message_stop or error event went out.
+	stopped bool
+}
+
+func (s *claudeStreamState) observe(chunk []byte) {
+	if bytes.Contains(chunk, []byte(`"message_stop"`)) || bytes.HasPrefix(bytes.TrimSpace(chunk), []byte("event: error")) {
+		s.stopped = true
+	}
+}
+
+// writeClaudeStreamError writes an Anthropic error event for a failure with
+// the given HTTP status.
+func writeClaudeStreamError(w io.Writer, status int, err error) {
+	errorBytes, _ := json.Marshal(toClaudeError(status, err))
+	_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", errorBytes)
+}
+
 type claudeErrorDetail struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
@@ -297,12 +400,45 @@ type claudeErrorResponse struct {
 	Error claudeErrorDetail `json:"error"`
 }
 
-func (h *ClaudeCodeAPIHandler) toClaudeError(msg *interfaces.ErrorMessage) claudeErrorResponse {
-	return claudeErrorResponse{
-		Type: "error",
-		Error: claudeErrorDetail{
-			Type:    "api_error",
-			Message: msg.Error.Error(),
-		},
+// toClaudeError builds the Anthropic error body for a failure. An upstream
+// error body is unwrapped so the client sees its message, and its type when
+// the upstream is Anthropic; otherwise the type follows the status.
+func toClaudeError(status int, err error) claudeErrorResponse {
+	detail := claudeErrorDetail{Type: claudeErrorType(status)}
+	if err != nil {
+		detail.Message = err.Error()
+	}
+	if parsed := gjson.Parse(detail.Message); parsed.IsObject() {
+		if message := parsed.Get("error.message"); message.Exists() {
+			detail.Message = message.String()
+		}
+		if parsed.Get("type").String() == "error" {
+			if typ := parsed.Get("error.type").String(); typ != "" {
+				detail.Type = typ
+			}
+		}
+	}
+	return claudeErrorResponse{Type: "error", Error: detail}
+}
+
+// claudeErrorType maps an HTTP status to the Anthropic error type.
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
 	}
 }
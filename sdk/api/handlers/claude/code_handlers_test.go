@@ -0,0 +1,161 @@
+package claude
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestClaudeModels_AnthropicShapeAndPaging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	modelRegistry := registry.GetGlobalRegistry()
+	modelRegistry.RegisterClient("test-claude-models", "claude", []*registry.ModelInfo{
+		{ID: "models-old", Created: 1700000000, DisplayName: "Old Model"},
+		{ID: "models-mid", Created: 1710000000},
+		{ID: "models-new", Created: 1720000000, DisplayName: "New Model"},
+	})
+	t.Cleanup(func() { modelRegistry.UnregisterClient("test-claude-models") })
+
+	h := NewClaudeCodeAPIHandler(&handlers.BaseAPIHandler{})
+	list := func(query string) gjson.Result {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v1/models"+query, nil)
+		h.ClaudeModels(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d, body %s", query, w.Code, w.Body.String())
+		}
+		return gjson.Parse(w.Body.String())
+	}
+
+	body := list("")
+	if got := body.Get("data.#.id").String(); got != `["models-new","models-mid","models-old"]` {
+		t.Fatalf("ids = %s", got)
+	}
+	first := body.Get("data.0")
+	if first.Get("type").String() != "model" || first.Get("display_name").String() != "New Model" || first.Get("created_at").String() != "2024-07-03T09:46:40Z" {
+		t.Fatalf("first model = %s", first.Raw)
+	}
+	if name := body.Get("data.1.display_name").String(); name != "models-mid" {
+		t.Fatalf("display_name fallback = %q", name)
+	}
+	if body.Get("has_more").Bool() || body.Get("first_id").String() != "models-new" || body.Get("last_id").String() != "models-old" {
+		t.Fatalf("page fields = %s", body.Raw)
+	}
+
+	page := list("?limit=1&after_id=models-new")
+	if page.Get("data.#.id").String() != `["models-mid"]` || !page.Get("has_more").Bool() {
+		t.Fatalf("after_id page = %s", page.Raw)
+	}
+	page = list("?limit=1&before_id=models-old")
+	if page.Get("data.#.id").String() != `["models-mid"]` || !page.Get("has_more").Bool() {
+		t.Fatalf("before_id page = %s", page.Raw)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/models?limit=0", nil)
+	h.ClaudeModels(c)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status %d", w.Code)
+	}
+}
+
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e statusError) Error() string   { return e.body }
+func (e statusError) StatusCode() int { return e.status }
+
+// scriptedStreamExecutor streams the given chunks, then fails with err when set.
+type scriptedStreamExecutor struct {
+	chunks []string
+	err    error
+}
+
+func (e scriptedStreamExecutor) Execute(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (e scriptedStreamExecutor) ExecuteCount(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (e scriptedStreamExecutor) ExecuteStream(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, len(e.chunks)+1)
+	for _, chunk := range e.chunks {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+	}
+	if e.err != nil {
+		ch <- coreexecutor.StreamChunk{Err: e.err}
+	}
+	close(ch)
+	return ch, nil
+}
+
+type claudeModels struct{}
+
+func (claudeModels) GetModelProviders(string) []string { return []string{"claude"} }
+
+func TestClaudeStreamReportsFailuresAsErrorEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	start := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"m\"}}\n\n"
+	stream := func(exec scriptedStreamExecutor) string {
+		t.Helper()
+		base := handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil, handlers.WithExecutor(exec), handlers.WithModelRegistry(claudeModels{}))
+		h := NewClaudeCodeAPIHandler(base)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m","stream":true}`))
+		h.handleStreamingResponse(c, []byte(`{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+		return w.Body.String()
+	}
+	lastEvent := func(body string) gjson.Result {
+		t.Helper()
+		events := strings.Split(strings.TrimSpace(body), "\n\n")
+		last := events[len(events)-1]
+		if !strings.HasPrefix(last, "event: error\ndata: ") {
+			t.Fatalf("stream does not end with an error event: %q", body)
+		}
+		return gjson.Parse(strings.TrimPrefix(last, "event: error\ndata: "))
+	}
+
+	body := stream(scriptedStreamExecutor{
+		chunks: []string{start},
+		err:    statusError{status: http.StatusTooManyRequests, body: `{"error":{"message":"slow down"}}`},
+	})
+	event := lastEvent(body)
+	if event.Get("type").String() != "error" || event.Get("error.type").String() != "rate_limit_error" || event.Get("error.message").String() != "slow down" {
+		t.Fatalf("error event = %s", event.Raw)
+	}
+
+	body = stream(scriptedStreamExecutor{
+		chunks: []string{start},
+		err:    statusError{status: 529, body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`},
+	})
+	if event = lastEvent(body); event.Get("error.type").String() != "overloaded_error" || event.Get("error.message").String() != "Overloaded" {
+		t.Fatalf("upstream error event = %s", event.Raw)
+	}
+
+	body = stream(scriptedStreamExecutor{chunks: []string{start}})
+	if event = lastEvent(body); event.Get("error.type").String() != "api_error" {
+		t.Fatalf("truncated stream event = %s", event.Raw)
+	}
+
+	body = stream(scriptedStreamExecutor{chunks: []string{start, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"}})
+	if strings.Contains(body, "event: error") {
+		t.Fatalf("complete stream reported an error: %q", body)
+	}
+}
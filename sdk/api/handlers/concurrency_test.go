@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// echoStreamExecutor streams back the words of the last user message and a
+// call of the tool whose ID the request carries, so every stream's output
+// depends on its own request state only.
+type echoStreamExecutor struct{ staticExecutor }
+
+func (echoStreamExecutor) ExecuteStream(ctx context.Context, _ []string, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	messages := gjson.GetBytes(req.Payload, "messages").Array()
+	words := strings.Fields(messages[len(messages)-1].Get("content").String())
+	callID := gjson.GetBytes(req.Payload, "messages.1.tool_calls.0.id").String()
+	ch := make(chan coreexecutor.StreamChunk)
+	go func() {
+		defer close(ch)
+		send := func(payload string) bool {
+			select {
+			case ch <- coreexecutor.StreamChunk{Payload: []byte(payload)}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, word := range words {
+			if !send(fmt.Sprintf(`{"id":"c","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":%q}}]}`, word+" ")) {
+				return
+			}
+		}
+		send(fmt.Sprintf(`{"id":"c","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":%q,"type":"function","function":{"name":"read","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`, callID))
+	}()
+	return ch, nil
+}
+
+// TestConcurrentStreamsKeepTheirOwnState runs many streams through one handler
+// at once, with the passes that keep per-request state enabled. Run it with
+// -race to check the shared handler state.
+func TestConcurrentStreamsKeepTheirOwnState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.SDKConfig{
+		RoleAlternation: "merge",
+		Sanitize:        config.SanitizeConfig{Outbound: []config.SanitizeFilter{{Name: "strip-ansi"}}},
+		RateLimitQueue:  config.RateLimitQueueConfig{Enabled: true},
+	}
+	h := NewBaseAPIHandlers(cfg, nil, WithExecutor(echoStreamExecutor{}), WithModelRegistry(staticModels{"m": {"openai"}}))
+
+	const streams, rounds = 24, 5
+	var wg sync.WaitGroup
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+				c.Set("apiKey", fmt.Sprintf("key-%d", i%4))
+				ctx, cancel := h.GetContextWithCancel(nil, c, context.Background())
+
+				callID := fmt.Sprintf("functions.read:%d", i)
+				want := fmt.Sprintf("stream %d round %d", i, round)
+				request := fmt.Sprintf(`{"model":"m","stream":true,"messages":[`+
+					`{"role":"user","content":"go"},`+
+					`{"role":"assistant","content":null,"tool_calls":[{"id":%q,"type":"function","function":{"name":"read","arguments":"{}"}}]},`+
+					`{"role":"tool","tool_call_id":%q,"content":"done"},`+
+					`{"role":"user","content":"\u001b[1m%s\u001b[0m"}]}`, callID, callID, want)
+
+				dataChan, errChan := h.ExecuteStreamWithAuthManager(ctx, "openai", "m", []byte(request), "")
+				var text strings.Builder
+				var gotID string
+				for chunk := range dataChan {
+					text.WriteString(gjson.GetBytes(chunk, "choices.0.delta.content").String())
+					if id := gjson.GetBytes(chunk, "choices.0.delta.tool_calls.0.id"); id.Exists() {
+						gotID = id.String()
+					}
+				}
+				for errMsg := range errChan {
+					t.Errorf("stream %d: %v", i, errMsg.Error)
+				}
+				cancel()
+				if got := strings.TrimSpace(text.String()); got != want {
+					t.Errorf("stream %d text = %q, want %q", i, got, want)
+				}
+				if gotID != callID {
+					t.Errorf("stream %d tool call id = %q, want %q", i, gotID, callID)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
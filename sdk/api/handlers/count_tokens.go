@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"golang.org/x/net/context"
+)
+
+// Token counting modes selected by SDKConfig.CountTokens.
+const (
+	countTokensUpstream = "upstream"
+	countTokensLocal    = "local"
+	countTokensFallback = "fallback"
+)
+
+func countTokensMode(cfg *config.SDKConfig) string {
+	if cfg == nil {
+		return countTokensUpstream
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(cfg.CountTokens)); mode {
+	case countTokensLocal, countTokensFallback:
+		return mode
+	default:
+		return countTokensUpstream
+	}
+}
+
+// countTokensLocally estimates input tokens without calling an upstream. The
+// request is normalized through the OpenAI chat translator so system prompts,
+// tools and tool results are counted the same way for every source schema.
+func (h *BaseAPIHandler) countTokensLocally(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	count, err := localPromptTokens(handlerType, modelName, rawJSON)
+	if err != nil {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+	usage := []byte(fmt.Sprintf(`{"usage":{"prompt_tokens":%d,"completion_tokens":0,"total_tokens":%d}}`, count, count))
+	from := sdktranslator.FromString(handlerType)
+	return []byte(sdktranslator.TranslateTokenCount(ctx, sdktranslator.FromString("openai"), from, count, usage)), nil
+}
+
+// localPromptTokens counts the prompt tokens of a request in the client's
+// schema with the built-in tokenizer.
+func localPromptTokens(handlerType, modelName string, rawJSON []byte) (int64, error) {
+	from := sdktranslator.FromString(handlerType)
+	to := sdktranslator.FromString("openai")
+	baseModel := thinking.ParseSuffix(modelName).ModelName
+	translated := rawJSON
+	if from != to {
+		translated = sdktranslator.TranslateRequest(from, to, baseModel, cloneBytes(rawJSON), false)
+	}
+	return executor.CountPromptTokens(baseModel, translated)
+}
+
+// countTokensAfterFailure serves a local estimate when the upstream count failed.
+func (h *BaseAPIHandler) countTokensAfterFailure(ctx context.Context, handlerType, modelName string, rawJSON []byte, cause error) ([]byte, *interfaces.ErrorMessage) {
+	logging.Entry(ctx).Debugf("count tokens: upstream count failed, using local estimate: %v", cause)
+	return h.countTokensLocally(ctx, handlerType, modelName, rawJSON)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestExecuteCountWithAuthManager_LocalMode(t *testing.T) {
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{CountTokens: "local"}}
+	small := []byte(`{"model":"claude-sonnet-4-5-20250929","messages":[{"role":"user","content":"hello"}]}`)
+	large := []byte(`{"model":"claude-sonnet-4-5-20250929","system":"You are a careful assistant that explains things step by step.",
+		"tools":[{"name":"read_file","description":"Read a file from disk","input_schema":{"type":"object","properties":{"path":{"type":"string"}}}}],
+		"messages":[{"role":"user","content":"hello"}]}`)
+
+	out, errMsg := h.ExecuteCountWithAuthManager(context.Background(), "claude", "claude-sonnet-4-5-20250929", small, "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	smallCount := gjson.GetBytes(out, "input_tokens").Int()
+	if smallCount <= 0 {
+		t.Fatalf("expected input_tokens in claude format, got %s", out)
+	}
+	out, _ = h.ExecuteCountWithAuthManager(context.Background(), "claude", "claude-sonnet-4-5-20250929", large, "")
+	if got := gjson.GetBytes(out, "input_tokens").Int(); got <= smallCount+10 {
+		t.Fatalf("system prompt and tools not counted: %d vs %d", got, smallCount)
+	}
+}
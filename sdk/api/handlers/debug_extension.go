@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// debugHeader requests debug extensions; "translate" attaches translation details.
+	debugHeader = "X-CLIProxy-Debug"
+	// debugResponseField is the response field holding the translation details.
+	debugResponseField = "cliproxy_debug"
+)
+
+// translationDebug collects what the proxy did to one non-streaming request.
+// A nil *translationDebug means the client did not ask for it.
+type translationDebug struct {
+	RequestedModel string         `json:"requested_model"`
+	UpstreamModel  string         `json:"upstream_model,omitempty"`
+	Providers      []string       `json:"providers,omitempty"`
+	Actions        map[string]any `json:"actions,omitempty"`
+	UpstreamMS     int64          `json:"upstream_latency_ms"`
+
+	upstreamStart time.Time
+}
+
+// startTranslationDebug returns a collector when the request carries
+// X-CLIProxy-Debug: translate.
+func startTranslationDebug(ctx context.Context, modelName string) *translationDebug {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return nil
+	}
+	for _, value := range strings.Split(ginCtx.GetHeader(debugHeader), ",") {
+		if strings.EqualFold(strings.TrimSpace(value), "translate") {
+			return &translationDebug{RequestedModel: modelName}
+		}
+	}
+	return nil
+}
+
+// prepared records the payload pass actions and marks the start of the upstream call.
+func (d *translationDebug) prepared(meta map[string]any) {
+	if d == nil {
+		return
+	}
+	for key, value := range meta {
+		d.action(key, value)
+	}
+	d.upstreamStart = time.Now()
+}
+
+// routed records the backend that answered and the upstream latency.
+func (d *translationDebug) routed(target failoverTarget) {
+	if d == nil {
+		return
+	}
+	d.UpstreamMS = time.Since(d.upstreamStart).Milliseconds()
+	d.UpstreamModel = target.model
+	d.Providers = append([]string(nil), target.providers...)
+}
+
+// action records a transformation applied to the request or response.
+func (d *translationDebug) action(key string, value any) {
+	if d == nil {
+		return
+	}
+	if d.Actions == nil {
+		d.Actions = make(map[string]any)
+	}
+	d.Actions[key] = value
+}
+
+// attach adds the collected details to a JSON object response.
+func (d *translationDebug) attach(ctx context.Context, payload []byte) []byte {
+	if d == nil || !gjson.ValidBytes(payload) || !gjson.ParseBytes(payload).IsObject() {
+		return payload
+	}
+	out, err := sjson.SetBytes(payload, debugResponseField, d)
+	if err != nil {
+		logging.Entry(ctx).Debugf("debug extension not attached: %v", err)
+		return payload
+	}
+	return out
+}
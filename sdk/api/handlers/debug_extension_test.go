@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+type staticModels map[string][]string
+
+func (m staticModels) GetModelProviders(model string) []string { return m[model] }
+
+type staticExecutor struct{ payload []byte }
+
+func (e staticExecutor) Execute(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: e.payload}, nil
+}
+
+func (e staticExecutor) ExecuteCount(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: e.payload}, nil
+}
+
+func (e staticExecutor) ExecuteStream(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk)
+	close(ch)
+	return ch, nil
+}
+
+func TestExecuteWithAuthManager_DebugExtension(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.SDKConfig{ModelProfiles: []config.ModelProfile{{Models: []string{"debug-model"}, MaxHistoryTurns: 1}}}
+	h := NewBaseAPIHandlers(cfg, nil,
+		WithExecutor(staticExecutor{payload: []byte(`{"id":"msg_1","type":"message","content":[{"type":"text","text":"hi"}]}`)}),
+		WithModelRegistry(staticModels{"debug-model": {"claude"}}))
+	request := []byte(`{"model":"debug-model","messages":[{"role":"user","content":"a"},{"role":"assistant","content":"b"},{"role":"user","content":"c"}]}`)
+
+	out, errMsg := h.ExecuteWithAuthManager(context.Background(), "claude", "debug-model", request, "")
+	if errMsg != nil || gjson.GetBytes(out, debugResponseField).Exists() {
+		t.Fatalf("debug attached without the header: %s (%v)", out, errMsg)
+	}
+
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Request.Header.Set(debugHeader, "translate")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	out, errMsg = h.ExecuteWithAuthManager(ctx, "claude", "debug-model", request, "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	debug := gjson.GetBytes(out, debugResponseField)
+	if debug.Get("upstream_model").String() != "debug-model" || debug.Get("providers.0").String() != "claude" {
+		t.Fatalf("debug = %s", debug.Raw)
+	}
+	if debug.Get("actions."+historyTrimmedMetadataKey).Int() != 2 {
+		t.Fatalf("actions = %s", debug.Get("actions").Raw)
+	}
+	if gjson.GetBytes(out, "content.0.text").String() != "hi" {
+		t.Fatalf("response body changed: %s", out)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"golang.org/x/net/context"
+)
+
+// Executor runs requests against the providers serving a model.
+// *coreauth.Manager implements it.
+type Executor interface {
+	Execute(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error)
+	ExecuteCount(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error)
+	ExecuteStream(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error)
+}
+
+// ModelRegistry resolves which providers serve a model. The global
+// *registry.ModelRegistry implements it.
+type ModelRegistry interface {
+	GetModelProviders(modelID string) []string
+}
+
+// Clock supplies the current time to time-dependent routing state such as
+// failover circuit breakers.
+type Clock interface {
+	Now() time.Time
+}
+
+// Option customizes a BaseAPIHandler created by NewBaseAPIHandlers.
+type Option func(*BaseAPIHandler)
+
+// WithExecutor routes executions through executor instead of the auth manager.
+func WithExecutor(executor Executor) Option {
+	return func(h *BaseAPIHandler) { h.executor = executor }
+}
+
+// WithModelRegistry resolves model providers through models instead of the global registry.
+func WithModelRegistry(models ModelRegistry) Option {
+	return func(h *BaseAPIHandler) { h.models = models }
+}
+
+// WithClock replaces the wall clock used by failover circuit breakers.
+func WithClock(clock Clock) Option {
+	return func(h *BaseAPIHandler) { h.breakers.SetClock(clock.Now) }
+}
+
+// exec returns the executor used for upstream calls.
+func (h *BaseAPIHandler) exec() Executor {
+	if h.executor != nil {
+		return h.executor
+	}
+	return h.AuthManager
+}
+
+// modelProviders returns the providers serving model.
+func (h *BaseAPIHandler) modelProviders(model string) []string {
+	if h.models == nil {
+		return util.GetProviderName(model)
+	}
+	if model == "" {
+		return nil
+	}
+	return h.models.GetModelProviders(model)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sanitize"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	// dlpMetadataKey records redaction counts per rule in execution metadata.
+	dlpMetadataKey = "dlp_redactions"
+	// dlpRedactionsHeader reports the total number of masked secrets to the client.
+	dlpRedactionsHeader = "X-CLIProxy-DLP-Redactions"
+)
+
+var (
+	dlpMu     sync.Mutex
+	dlpKey    string
+	dlpMasker *sanitize.SecretMasker
+)
+
+func secretMaskerFor(cfg config.DLPConfig) *sanitize.SecretMasker {
+	key := fmt.Sprintf("%+v|%s", cfg.Rules, cfg.Replacement)
+	dlpMu.Lock()
+	defer dlpMu.Unlock()
+	if dlpMasker != nil && dlpKey == key {
+		return dlpMasker
+	}
+	masker, errs := sanitize.NewSecretMasker(cfg.Rules, cfg.Replacement)
+	for _, err := range errs {
+		log.Warnf("dlp: ignoring rule: %v", err)
+	}
+	dlpMasker, dlpKey = masker, key
+	return masker
+}
+
+// applySecretMasking masks credentials in request text when DLP is enabled and
+// reports how many were masked through metadata and a response header.
+func (h *BaseAPIHandler) applySecretMasking(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil || !h.Cfg.DLP.Enabled {
+		return rawJSON, nil
+	}
+	out, counts := secretMaskerFor(h.Cfg.DLP).Mask(handlerType, rawJSON)
+	if len(counts) == 0 {
+		return rawJSON, nil
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(dlpRedactionsHeader, strconv.Itoa(total))
+	}
+	logging.Entry(ctx).WithField("rules", counts).Infof("dlp: masked %d secret(s) before forwarding", total)
+	return out, map[string]any{dlpMetadataKey: counts}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultFailoverErrorThreshold = 3
+	defaultFailoverOpenDuration   = 60 * time.Second
+)
+
+// failoverTarget is one backend of a fallback chain resolved to its providers.
+type failoverTarget struct {
+	model     string
+	providers []string
+}
+
+// failoverSettings returns the chain configured for model and the effective breaker limits.
+func failoverSettings(cfg *config.SDKConfig, model string) (fallbacks []string, threshold int, openFor time.Duration) {
+	threshold, openFor = defaultFailoverErrorThreshold, defaultFailoverOpenDuration
+	if cfg == nil {
+		return nil, threshold, openFor
+	}
+	if cfg.Failover.ErrorThreshold > 0 {
+		threshold = cfg.Failover.ErrorThreshold
+	}
+	if cfg.Failover.OpenSeconds > 0 {
+		openFor = time.Duration(cfg.Failover.OpenSeconds) * time.Second
+	}
+	base := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	for _, chain := range cfg.Failover.Chains {
+		if strings.EqualFold(strings.TrimSpace(chain.Model), base) {
+			return chain.Fallbacks, threshold, openFor
+		}
+	}
+	return nil, threshold, openFor
+}
+
+// failoverTargets returns the primary target followed by the resolvable
+// fallbacks configured for modelName. The thinking suffix of the request is
+// carried over to fallbacks that do not specify their own.
+func (h *BaseAPIHandler) failoverTargets(modelName string, primary failoverTarget) []failoverTarget {
+	fallbacks, _, _ := failoverSettings(h.Cfg, modelName)
+	if len(fallbacks) == 0 {
+		return nil
+	}
+	suffix := thinking.ParseSuffix(modelName)
+	targets := []failoverTarget{primary}
+	for _, fallback := range fallbacks {
+		fallback = strings.TrimSpace(fallback)
+		if fallback == "" {
+			continue
+		}
+		if suffix.HasSuffix && !thinking.ParseSuffix(fallback).HasSuffix {
+			fallback = fmt.Sprintf("%s(%s)", fallback, suffix.RawSuffix)
+		}
+		providers, normalized, errMsg := h.getRequestDetails(fallback)
+		if errMsg != nil {
+			log.Debugf("failover: skipping fallback %s for %s: %v", fallback, modelName, errMsg.Error)
+			continue
+		}
+		targets = append(targets, failoverTarget{model: normalized, providers: providers})
+	}
+	return targets
+}
+
+// upstreamUnavailable reports whether err indicates the backend itself is
+// failing (network errors, auth and quota errors, 5xx) rather than a problem
+// with the request.
+func upstreamUnavailable(err error) bool {
+	status := statusFromError(err)
+	if status == 0 {
+		return true
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusPaymentRequired,
+		http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= http.StatusInternalServerError
+	}
+}
+
+// withFailover runs exec against the primary target and, when a fallback chain
+// is configured for modelName, against the fallbacks whose circuits allow it
+// until one succeeds. It returns the target that produced the result.
+func withFailover[T any](ctx context.Context, h *BaseAPIHandler, modelName string, primary failoverTarget, exec func(failoverTarget) (T, error)) (T, failoverTarget, error) {
+	targets := h.failoverTargets(modelName, primary)
+	if len(targets) == 0 || h.breakers == nil {
+		result, err := exec(primary)
+		return result, primary, err
+	}
+	_, threshold, openFor := failoverSettings(h.Cfg, modelName)
+
+	var zero T
+	var lastErr error
+	for i, target := range targets {
+		if ctx != nil && ctx.Err() != nil {
+			return zero, target, ctx.Err()
+		}
+		allowed, probe := h.breakers.Allow(target.model, openFor)
+		if !allowed {
+			log.Debugf("failover: circuit for %s is open, skipping", target.model)
+			continue
+		}
+		if probe {
+			log.Infof("failover: probing %s for recovery", target.model)
+		}
+		if i > 0 {
+			log.Infof("failover: routing %s request to fallback %s", modelName, target.model)
+		}
+		result, err := exec(target)
+		if err == nil {
+			if h.breakers.Success(target.model) {
+				log.Infof("failover: circuit for %s closed", target.model)
+			}
+			return result, target, nil
+		}
+		if !upstreamUnavailable(err) {
+			h.breakers.Release(target.model)
+			return zero, target, err
+		}
+		lastErr = err
+		if h.breakers.Failure(target.model, threshold) {
+			log.Warnf("failover: circuit for %s opened: %v", target.model, err)
+		}
+	}
+	if lastErr != nil {
+		return zero, primary, lastErr
+	}
+	return zero, primary, &coreauth.Error{
+		Code:       "backend_unavailable",
+		Message:    fmt.Sprintf("all backends for model %s are unavailable", modelName),
+		Retryable:  true,
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestWithFailover_RoutesToFallback(t *testing.T) {
+	modelRegistry := registry.GetGlobalRegistry()
+	modelRegistry.RegisterClient("test-failover-primary", "claude", []*registry.ModelInfo{{ID: "failover-primary"}})
+	modelRegistry.RegisterClient("test-failover-secondary", "openai", []*registry.ModelInfo{{ID: "failover-secondary"}})
+	t.Cleanup(func() {
+		modelRegistry.UnregisterClient("test-failover-primary")
+		modelRegistry.UnregisterClient("test-failover-secondary")
+	})
+
+	cfg := &config.SDKConfig{Failover: config.FailoverConfig{
+		ErrorThreshold: 1,
+		Chains:         []config.FailoverChain{{Model: "failover-primary", Fallbacks: []string{"failover-secondary"}}},
+	}}
+	h := NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil))
+	primary := failoverTarget{model: "failover-primary", providers: []string{"claude"}}
+
+	var calls []string
+	exec := func(target failoverTarget) (string, error) {
+		calls = append(calls, target.model)
+		if target.model == "failover-primary" {
+			return "", &coreauth.Error{Message: "overloaded", HTTPStatus: http.StatusServiceUnavailable}
+		}
+		return "ok", nil
+	}
+
+	result, target, err := withFailover(context.Background(), h, "failover-primary", primary, exec)
+	if err != nil || result != "ok" || target.model != "failover-secondary" {
+		t.Fatalf("unexpected result %q from %q: %v", result, target.model, err)
+	}
+
+	// The primary circuit is now open, so the next request goes straight to the fallback.
+	calls = nil
+	if _, _, err = withFailover(context.Background(), h, "failover-primary", primary, exec); err != nil {
+		t.Fatalf("second request failed: %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "failover-secondary" {
+		t.Fatalf("calls = %v, want only the fallback", calls)
+	}
+}
+
+func TestWithFailover_ClientErrorsDoNotFailOver(t *testing.T) {
+	cfg := &config.SDKConfig{Failover: config.FailoverConfig{
+		Chains: []config.FailoverChain{{Model: "failover-client-error", Fallbacks: []string{"unknown-model"}}},
+	}}
+	h := NewBaseAPIHandlers(cfg, coreauth.NewManager(nil, nil, nil))
+	badRequest := &coreauth.Error{Message: "bad request", HTTPStatus: http.StatusBadRequest}
+
+	calls := 0
+	_, _, err := withFailover(context.Background(), h, "failover-client-error", failoverTarget{model: "failover-client-error"}, func(failoverTarget) (string, error) {
+		calls++
+		return "", badRequest
+	})
+	if !errors.Is(err, badRequest) || calls != 1 {
+		t.Fatalf("err = %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"golang.org/x/net/context"
+)
+
+// featureEnabled evaluates a feature flag for the client API key of the request.
+func (h *BaseAPIHandler) featureEnabled(ctx context.Context, name string) bool {
+	if h == nil || h.Cfg == nil {
+		return featureflags.Enabled(nil, name, "")
+	}
+	apiKey := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	return featureflags.Enabled(h.Cfg.FeatureFlags, name, apiKey)
+}
@@ -14,14 +14,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/batch"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/circuit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratequeue"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
 
@@ -115,6 +120,15 @@ func StreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// StreamingCancelGrace returns how long the upstream call may keep running after
+// the client disconnects. Returning 0 cancels immediately (default when unset).
+func StreamingCancelGrace(cfg *config.SDKConfig) time.Duration {
+	if cfg == nil || cfg.Streaming.CancelGraceSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(cfg.Streaming.CancelGraceSeconds) * time.Second
+}
+
 // NonStreamingKeepAliveInterval returns the keep-alive interval for non-streaming responses.
 // Returning 0 disables keep-alives (default when unset).
 func NonStreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
@@ -169,6 +183,48 @@ func mergeMetadata(base, overlay map[string]any) map[string]any {
 	return out
 }
 
+// payloadPass rewrites an inbound payload before execution and may report
+// metadata describing what it changed.
+type payloadPass func(h *BaseAPIHandler, ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any)
+
+// payloadPasses run in order on every executed request. Role normalization and
+// transcript repair run first so later passes see a well-formed conversation;
+// the history window runs last so it measures the prompt as forwarded.
+var payloadPasses = []payloadPass{
+	(*BaseAPIHandler).applyRoleMap,
+	(*BaseAPIHandler).applyMidTranscriptSystem,
+	(*BaseAPIHandler).applySamplingExtensions,
+	(*BaseAPIHandler).applyBuiltinTools,
+	(*BaseAPIHandler).applyToolPairRepair,
+	(*BaseAPIHandler).applyRoleAlternation,
+	(*BaseAPIHandler).applyToolChoiceNone,
+	(*BaseAPIHandler).applyToolNameMapping,
+	(*BaseAPIHandler).applyToolCallIDMapping,
+	(*BaseAPIHandler).applyModelProfile,
+	(*BaseAPIHandler).applyAssistantPrefill,
+	(*BaseAPIHandler).applySystemInjections,
+	(*BaseAPIHandler).applyShellOutputPolicy,
+	(*BaseAPIHandler).applyInlineImageLimits,
+	(*BaseAPIHandler).applyAttachmentPolicy,
+	(*BaseAPIHandler).applyOutboundSanitize,
+	(*BaseAPIHandler).applySecretMasking,
+	(*BaseAPIHandler).applyPromptGuard,
+	(*BaseAPIHandler).applyHistoryWindow,
+}
+
+// preparePayload applies all payload passes for a request to modelName and
+// merges their metadata.
+func (h *BaseAPIHandler) preparePayload(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, map[string]any) {
+	ctx = context.WithValue(ctx, payloadModelKey{}, modelName)
+	var meta map[string]any
+	for _, pass := range payloadPasses {
+		var passMeta map[string]any
+		rawJSON, passMeta = pass(h, ctx, handlerType, rawJSON)
+		meta = mergeMetadata(meta, passMeta)
+	}
+	return rawJSON, meta
+}
+
 // BaseAPIHandler contains the handlers for API endpoints.
 // It holds a pool of clients to interact with the backend service and manages
 // load balancing, client selection, and configuration.
@@ -178,6 +234,26 @@ type BaseAPIHandler struct {
 
 	// Cfg holds the current application configuration.
 	Cfg *config.SDKConfig
+
+	// breakers tracks circuit state of backends in configured fallback chains.
+	// It survives config reloads so open circuits stay open.
+	breakers *circuit.Breaker
+
+	// rateLimits queues requests to models rate limited upstream; created on first use.
+	rateLimits    *ratequeue.Scheduler
+	rateQueueOnce sync.Once
+
+	// executor and models override AuthManager and the global model registry when set.
+	executor Executor
+	models   ModelRegistry
+
+	// batches runs /v1/batches jobs; created on first use.
+	batches     *batch.Manager
+	batchesOnce sync.Once
+
+	// idempotent holds responses replayed for Idempotency-Key retries; created on first use.
+	idempotent      *idempotencyCache
+	idempotencyOnce sync.Once
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -186,14 +262,20 @@ type BaseAPIHandler struct {
 // Parameters:
 //   - cliClients: A slice of AI service clients
 //   - cfg: The application configuration
+//   - opts: Optional overrides of the handler's dependencies
 //
 // Returns:
 //   - *BaseAPIHandler: A new API handlers instance
-func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *BaseAPIHandler {
-	return &BaseAPIHandler{
+func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager, opts ...Option) *BaseAPIHandler {
+	h := &BaseAPIHandler{
 		Cfg:         cfg,
 		AuthManager: authManager,
+		breakers:    circuit.New(),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // UpdateClients updates the handlers' client list and configuration.
@@ -255,18 +337,37 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			parentCtx = logging.WithRequestID(parentCtx, requestID)
 		}
 	}
-	newCtx, cancel := context.WithCancel(parentCtx)
+	// The watcher reads cancelCtx, never newCtx, which is rebound below.
+	cancelCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
+		grace := StreamingCancelGrace(h.Cfg)
 		go func() {
 			select {
 			case <-requestCtx.Done():
-				cancel()
-			case <-newCtx.Done():
+			case <-cancelCtx.Done():
+				return
 			}
+			// Give the upstream call a chance to finish before tearing it down.
+			if grace > 0 {
+				timer := time.NewTimer(grace)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+				case <-cancelCtx.Done():
+					return
+				}
+			}
+			cancel()
 		}()
 	}
-	newCtx = context.WithValue(newCtx, "gin", c)
+	newCtx := context.WithValue(cancelCtx, "gin", c)
 	newCtx = context.WithValue(newCtx, "handler", handler)
+	if c != nil {
+		if tenant := h.Cfg.TenantOf(c.GetString("apiKey")); tenant != nil {
+			c.Set(logging.GinTenantKey, tenant.ID)
+			newCtx = logging.WithLogFields(newCtx, log.Fields{logging.FieldTenant: tenant.ID})
+		}
+	}
 	return newCtx, func(params ...interface{}) {
 		if h.Cfg.RequestLog && len(params) == 1 {
 			if existing, exists := c.Get("API_RESPONSE"); exists {
@@ -379,12 +480,53 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
-func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (out []byte, errMsg *interfaces.ErrorMessage) {
+	if call, leader := h.idempotentCall(ctx, handlerType, modelName, rawJSON); call != nil {
+		if !leader {
+			return call.wait(ctx)
+		}
+		ttl := h.idempotencyTTL()
+		defer func() { call.complete(out, errMsg, ttl) }()
+	}
+	insp := h.startInspection(ctx, handlerType, modelName, false)
+	defer func() { insp.finish(out, errMsg) }()
+	modelName, arm := h.applyTrafficSplit(ctx, modelName, rawJSON)
+	if modelName, errMsg = h.applyKeyPolicy(ctx, modelName, true); errMsg != nil {
+		return nil, errMsg
+	}
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
+	ctx = logging.WithLogFields(ctx, log.Fields{logging.FieldModel: normalizedModel, logging.FieldStage: logging.StageRequest})
 	reqMeta := requestExecutionMetadata(ctx)
+	if rawJSON, errMsg = expandToolManifest(ctx, handlerType, rawJSON); errMsg != nil {
+		return nil, errMsg
+	}
+	planMode := h.trackPlanMode(ctx, handlerType, rawJSON)
+	rawJSON, prepMeta := h.preparePayload(ctx, handlerType, modelName, rawJSON)
+	rawJSON, limitMeta, errMsg := h.enforceRequestLimits(ctx, handlerType, modelName, rawJSON)
+	prepMeta = mergeMetadata(prepMeta, limitMeta)
+	insp.prepared(normalizedModel, providers, rawJSON, prepMeta)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	mod := h.moderationPolicy()
+	blocked, modMeta := mod.checkRequest(ctx, handlerType, rawJSON)
+	if blocked {
+		insp.action("moderation_blocked", "inbound")
+		return mod.refusal(handlerType, modelName)
+	}
+	prepMeta = mergeMetadata(prepMeta, modMeta)
+	debug := startTranslationDebug(ctx, modelName)
+	debug.prepared(prepMeta)
+	if errMsg = h.checkSamplingExtensions(ctx, prepMeta); errMsg != nil {
+		return nil, errMsg
+	}
+	if errMsg = h.checkStrictTranslation(ctx, handlerType, normalizedModel, providers, rawJSON, false); errMsg != nil {
+		return nil, errMsg
+	}
+	h.startShadow(ctx, handlerType, modelName, rawJSON, prepMeta)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -395,9 +537,33 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		OriginalRequest: cloneBytes(rawJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
-	opts.Metadata = reqMeta
-	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+	opts.Metadata = mergeMetadata(reqMeta, prepMeta)
+	primary := failoverTarget{model: normalizedModel, providers: providers}
+	resp, target, err := withFailover(ctx, h, modelName, primary, func(target failoverTarget) (coreexecutor.Response, error) {
+		targetReq := req
+		targetReq.Model = target.model
+		return withRateLimitQueue(ctx, h, target.model, func() (coreexecutor.Response, error) {
+			return h.exec().Execute(ctx, target.providers, targetReq, opts)
+		})
+	})
+	insp.routed(target)
+	debug.routed(target)
 	if err != nil {
+		var level string
+		resp, level, err = retryRepaired(ctx, h, handlerType, req.Payload, err, func(payload []byte) (coreexecutor.Response, error) {
+			repairedReq, repairedOpts := req, opts
+			repairedReq.Model, repairedReq.Payload = target.model, payload
+			repairedOpts.OriginalRequest = cloneBytes(payload)
+			return h.exec().Execute(ctx, target.providers, repairedReq, repairedOpts)
+		})
+		if level != "" {
+			insp.action("transcript_repaired", level)
+			debug.action("transcript_repaired", level)
+		}
+	}
+	arm.record(err != nil)
+	if err != nil {
+		err = h.diagnoseRejection(ctx, handlerType, target, req.Payload, err)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
@@ -412,16 +578,68 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		}
 		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
-	return cloneBytes(resp.Payload), nil
+	ctx = logging.WithLogFields(ctx, log.Fields{logging.FieldStage: logging.StageResponse})
+	payload := cloneBytes(resp.Payload)
+	if h.featureEnabled(ctx, featureflags.ToolCallDedup) {
+		if deduped, removed := util.DedupeToolCalls(handlerType, payload); removed > 0 {
+			logging.Entry(ctx).Debugf("removed %d duplicate tool call(s) from the response", removed)
+			insp.action("tool_calls_deduplicated", removed)
+			debug.action("tool_calls_deduplicated", removed)
+			payload = deduped
+		}
+	}
+	if limited, dropped := limitParallelToolCalls(ctx, handlerType, rawJSON, payload); dropped > 0 {
+		insp.action("tool_calls_dropped", dropped)
+		debug.action("tool_calls_dropped", dropped)
+		payload = limited
+	}
+	if textOnly, dropped := dropForbiddenToolCalls(ctx, handlerType, prepMeta, payload); dropped > 0 {
+		insp.action("tool_calls_dropped", dropped)
+		debug.action("tool_calls_dropped", dropped)
+		payload = textOnly
+	}
+	payload, errMsg = h.enforceStrictTools(ctx, handlerType, rawJSON, payload)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	if stripped, ok := h.newEchoStripper(ctx, handlerType, rawJSON).Response(payload); ok {
+		recordEchoStrip(ctx, handlerType)
+		insp.action("echo_stripped", true)
+		debug.action("echo_stripped", true)
+		payload = stripped
+	}
+	if prefill := assistantPrefill(prepMeta); prefill != "" {
+		payload = util.PrependClaudeText(payload, prefill)
+	}
+	payload = restoreToolNames(handlerType, prepMeta, payload)
+	payload = restoreToolCallIDs(handlerType, prepMeta, payload)
+	if mod.checkResponse(ctx, handlerType, payload) {
+		insp.action("moderation_blocked", "outbound")
+		debug.action("moderation_blocked", "outbound")
+		return mod.refusal(handlerType, modelName)
+	}
+	return planMode.attach(ctx, debug.attach(ctx, h.sanitizeResponse(handlerType, payload, false))), nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	modelName, errMsg := h.applyKeyPolicy(ctx, modelName, false)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	mode := countTokensMode(h.Cfg)
+	if mode == countTokensLocal {
+		return h.countTokensLocally(ctx, handlerType, modelName, rawJSON)
+	}
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
+		if mode == countTokensFallback {
+			return h.countTokensAfterFailure(ctx, handlerType, modelName, rawJSON, errMsg.Error)
+		}
 		return nil, errMsg
 	}
+	ctx = logging.WithLogFields(ctx, log.Fields{logging.FieldModel: normalizedModel, logging.FieldStage: logging.StageRequest})
 	reqMeta := requestExecutionMetadata(ctx)
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -434,7 +652,10 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
-	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
+	resp, err := h.exec().ExecuteCount(ctx, providers, req, opts)
+	if err != nil && mode == countTokensFallback {
+		return h.countTokensAfterFailure(ctx, handlerType, modelName, rawJSON, err)
+	}
 	if err != nil {
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -456,14 +677,58 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
-	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	insp := h.startInspection(ctx, handlerType, modelName, true)
+	modelName, arm := h.applyTrafficSplit(ctx, modelName, rawJSON)
+	modelName, errMsg := h.applyKeyPolicy(ctx, modelName, true)
+	providers, normalizedModel := []string(nil), ""
+	if errMsg == nil {
+		providers, normalizedModel, errMsg = h.getRequestDetails(modelName)
+	}
 	if errMsg != nil {
+		insp.finish(nil, errMsg)
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
+	ctx = logging.WithLogFields(ctx, log.Fields{logging.FieldModel: normalizedModel, logging.FieldStage: logging.StageRequest})
 	reqMeta := requestExecutionMetadata(ctx)
+	if rawJSON, errMsg = expandToolManifest(ctx, handlerType, rawJSON); errMsg != nil {
+		insp.finish(nil, errMsg)
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	planMode := h.trackPlanMode(ctx, handlerType, rawJSON)
+	rawJSON, prepMeta := h.preparePayload(ctx, handlerType, modelName, rawJSON)
+	rawJSON, limitMeta, errMsg := h.enforceRequestLimits(ctx, handlerType, modelName, rawJSON)
+	prepMeta = mergeMetadata(prepMeta, limitMeta)
+	insp.prepared(normalizedModel, providers, rawJSON, prepMeta)
+	mod := h.moderationPolicy()
+	if errMsg == nil {
+		blocked, modMeta := mod.checkRequest(ctx, handlerType, rawJSON)
+		if blocked {
+			insp.action("moderation_blocked", "inbound")
+			insp.finish(nil, nil)
+			return mod.streamRefusal(handlerType, modelName)
+		}
+		prepMeta = mergeMetadata(prepMeta, modMeta)
+		if errMsg = h.checkSamplingExtensions(ctx, prepMeta); errMsg == nil {
+			errMsg = h.checkStrictTranslation(ctx, handlerType, normalizedModel, providers, rawJSON, true)
+		}
+	}
+	if errMsg != nil {
+		insp.finish(nil, errMsg)
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, errChan
+	}
+	h.startShadow(ctx, handlerType, modelName, rawJSON, prepMeta)
+	if h.featureEnabled(ctx, featureflags.IncrementalToolArguments) {
+		ctx = context.WithValue(ctx, util.IncrementalToolArgumentsKey, true)
+	}
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
 		Payload: cloneBytes(rawJSON),
@@ -474,9 +739,35 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		OriginalRequest: cloneBytes(rawJSON),
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
-	opts.Metadata = reqMeta
-	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	opts.Metadata = mergeMetadata(reqMeta, prepMeta)
+	primary := failoverTarget{model: normalizedModel, providers: providers}
+	chunks, target, err := withFailover(ctx, h, modelName, primary, func(target failoverTarget) (<-chan coreexecutor.StreamChunk, error) {
+		targetReq := req
+		targetReq.Model = target.model
+		return withRateLimitQueue(ctx, h, target.model, func() (<-chan coreexecutor.StreamChunk, error) {
+			return h.executeStreamHedged(ctx, target.providers, targetReq, opts)
+		})
+	})
+	insp.routed(target)
 	if err != nil {
+		var level string
+		var repaired []byte
+		chunks, level, err = retryRepaired(ctx, h, handlerType, req.Payload, err, func(payload []byte) (<-chan coreexecutor.StreamChunk, error) {
+			repaired = payload
+			repairedReq, repairedOpts := req, opts
+			repairedReq.Model, repairedReq.Payload = target.model, payload
+			repairedOpts.OriginalRequest = cloneBytes(payload)
+			return h.executeStreamHedged(ctx, target.providers, repairedReq, repairedOpts)
+		})
+		if level != "" {
+			insp.action("transcript_repaired", level)
+			// Bootstrap retries resend the history that was accepted.
+			req.Payload, opts.OriginalRequest = cloneBytes(repaired), cloneBytes(repaired)
+		}
+	}
+	if err != nil {
+		arm.record(true)
+		err = h.diagnoseRejection(ctx, handlerType, target, req.Payload, err)
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
@@ -490,31 +781,60 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				addon = hdr.Clone()
 			}
 		}
-		errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+		errMsg := &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+		insp.finish(nil, errMsg)
+		errChan <- errMsg
 		close(errChan)
 		return nil, errChan
 	}
+	// Bootstrap retries stay on the backend that accepted the stream.
+	providers, req.Model = target.providers, target.model
+	// Settings applied while streaming are resolved now, so a config reload
+	// does not change a response midway.
+	inbound := h.inboundPipeline()
+	lines := inbound.LineStream(handlerType)
+	echo := h.newEchoStripper(ctx, handlerType, rawJSON)
+	moderated := mod.newStream(handlerType)
+	maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
 	dataChan := make(chan []byte)
 	errChan := make(chan *interfaces.ErrorMessage, 1)
 	go func() {
 		defer close(dataChan)
 		defer close(errChan)
+		var streamErrMsg *interfaces.ErrorMessage
+		defer func() {
+			insp.finish(nil, streamErrMsg)
+			arm.record(streamErrMsg != nil)
+		}()
 		sentPayload := false
-		bootstrapRetries := 0
-		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
-
-		bootstrapEligible := func(err error) bool {
-			status := statusFromError(err)
-			if status == 0 {
-				return true
+		strictTools := newStrictToolStream(handlerType, rawJSON)
+		singleToolCall := newParallelToolCallStream(handlerType, rawJSON)
+		noToolCalls := newNoToolCallStream(handlerType, prepMeta)
+		toolNames := newToolNameStream(handlerType, prepMeta)
+		toolCallIDs := newToolCallIDStream(handlerType, prepMeta)
+		defer func() {
+			if dropped := singleToolCall.Dropped(); dropped > 0 {
+				logging.Entry(ctx).Warnf("parallel_tool_calls=false: dropped %d extra tool call(s) from the stream", dropped)
+				insp.action("tool_calls_dropped", dropped)
 			}
-			switch status {
-			case http.StatusUnauthorized, http.StatusForbidden, http.StatusPaymentRequired,
-				http.StatusRequestTimeout, http.StatusTooManyRequests:
-				return true
-			default:
-				return status >= http.StatusInternalServerError
+			if dropped := noToolCalls.Dropped(); dropped > 0 {
+				logging.Entry(ctx).Warnf("tool_choice none: dropped %d tool call(s) from the stream", dropped)
+				insp.action("tool_calls_dropped", dropped)
 			}
+		}()
+		prefill := assistantPrefill(prepMeta)
+		respCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldStage: logging.StageResponse})
+		bootstrapRetries := 0
+		resume := h.newStreamResume(handlerType)
+		var seams []streamSeam
+		resumeWith := func(cause string) bool {
+			next, seam, resumed := h.resumeStream(respCtx, resume, providers, req, opts, cause)
+			if resumed {
+				chunks = next
+				seams = append(seams, seam)
+				insp.action(streamResumedAction, seams)
+			}
+			return resumed
 		}
 
 	outer:
@@ -525,6 +845,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				if ctx != nil {
 					select {
 					case <-ctx.Done():
+						// 499: the client closed the request.
+						streamErrMsg = &interfaces.ErrorMessage{StatusCode: 499, Error: ctx.Err()}
 						return
 					case chunk, ok = <-chunks:
 					}
@@ -532,6 +854,9 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					chunk, ok = <-chunks
 				}
 				if !ok {
+					if sentPayload && resume.Interrupted() && resumeWith("stream ended early") {
+						continue outer
+					}
 					return
 				}
 				if chunk.Err != nil {
@@ -539,15 +864,17 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					// Safe bootstrap recovery: if the upstream fails before any payload bytes are sent,
 					// retry a few times (to allow auth rotation / transient recovery) and then attempt model fallback.
 					if !sentPayload {
-						if bootstrapRetries < maxBootstrapRetries && bootstrapEligible(streamErr) {
+						if bootstrapRetries < maxBootstrapRetries && upstreamUnavailable(streamErr) {
 							bootstrapRetries++
-							retryChunks, retryErr := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+							retryChunks, retryErr := h.exec().ExecuteStream(ctx, providers, req, opts)
 							if retryErr == nil {
 								chunks = retryChunks
 								continue outer
 							}
 							streamErr = retryErr
 						}
+					} else if resumeWith(streamErr.Error()) {
+						continue outer
 					}
 
 					status := http.StatusInternalServerError
@@ -562,12 +889,55 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							addon = hdr.Clone()
 						}
 					}
-					errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon}
+					streamErrMsg = &interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon}
+					errChan <- streamErrMsg
 					return
 				}
 				if len(chunk.Payload) > 0 {
 					sentPayload = true
-					dataChan <- cloneBytes(chunk.Payload)
+					chunk.Payload = resume.Chunk(cloneBytes(chunk.Payload))
+					if len(chunk.Payload) == 0 {
+						continue
+					}
+					payload, stripped := echo.Chunk(cloneBytes(chunk.Payload))
+					if stripped {
+						recordEchoStrip(respCtx, handlerType)
+						insp.action("echo_stripped", true)
+					}
+					if prefill != "" {
+						var prepended bool
+						if payload, prepended = util.PrependClaudeStreamText(payload, prefill); prepended {
+							prefill = ""
+						}
+					}
+					if planMode != nil {
+						var attached bool
+						if payload, attached = planMode.attachStream(respCtx, payload); attached {
+							planMode = nil
+						}
+					}
+					payload = lines.Chunk(toolCallIDs.Chunk(toolNames.Chunk(singleToolCall.Chunk(noToolCalls.Chunk(payload)))))
+					if stop, blocked := moderated.chunk(respCtx, payload); blocked {
+						insp.action("moderation_blocked", "outbound")
+						if stop == nil {
+							streamErrMsg = mod.errorMessage()
+							errChan <- streamErrMsg
+							return
+						}
+						insp.response(stop)
+						dataChan <- stop
+						return
+					}
+					if len(payload) > 0 {
+						payload = sanitizeResponseWith(inbound, handlerType, payload, true)
+						insp.response(payload)
+						dataChan <- payload
+					}
+					if errMsg := strictTools.observe(chunk.Payload); errMsg != nil {
+						streamErrMsg = errMsg
+						errChan <- errMsg
+						return
+					}
 				}
 			}
 		}
@@ -588,6 +958,9 @@ func statusFromError(err error) int {
 }
 
 func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, err *interfaces.ErrorMessage) {
+	if model, allowed, ok := h.providerRoute(modelName); ok {
+		return h.routedRequestDetails(modelName, model, allowed)
+	}
 	resolvedModelName := modelName
 	initialSuffix := thinking.ParseSuffix(modelName)
 	if initialSuffix.ModelName == "auto" {
@@ -604,14 +977,14 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	parsed := thinking.ParseSuffix(resolvedModelName)
 	baseModel := strings.TrimSpace(parsed.ModelName)
 
-	providers = util.GetProviderName(baseModel)
+	providers = h.modelProviders(baseModel)
 	// Fallback: if baseModel has no provider but differs from resolvedModelName,
 	// try using the full model name. This handles edge cases where custom models
 	// may be registered with their full suffixed name (e.g., "my-model(8192)").
 	// Evaluated in Story 11.8: This fallback is intentionally preserved to support
 	// custom model registrations that include thinking suffixes.
 	if len(providers) == 0 && baseModel != resolvedModelName {
-		providers = util.GetProviderName(resolvedModelName)
+		providers = h.modelProviders(resolvedModelName)
 	}
 
 	if len(providers) == 0 {
@@ -669,6 +1042,9 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 	}
 
 	body := BuildErrorResponseBody(status, errText)
+	if msg != nil {
+		body = withDiagnosis(body, msg.Error)
+	}
 	// Append first to preserve upstream response logs, then drop duplicate payloads if already recorded.
 	var previous []byte
 	if existing, exists := c.Get("API_RESPONSE"); exists {
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"golang.org/x/net/context"
+)
+
+// hedgeDelay returns how long a stream may go without its first chunk before a
+// hedged duplicate is started, or 0 when hedging is disabled.
+func (h *BaseAPIHandler) hedgeDelay() time.Duration {
+	if h == nil || h.Cfg == nil || h.Cfg.Streaming.HedgeDelayMS <= 0 {
+		return 0
+	}
+	return time.Duration(h.Cfg.Streaming.HedgeDelayMS) * time.Millisecond
+}
+
+// executeStreamHedged starts a stream and, if no chunk arrives within the hedge
+// delay, starts the same request again. The auth selector rotates, so the
+// duplicate normally runs on another account. Whichever stream produces a
+// chunk first is returned and the other is cancelled.
+func (h *BaseAPIHandler) executeStreamHedged(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	delay := h.hedgeDelay()
+	if delay <= 0 {
+		return h.exec().ExecuteStream(ctx, providers, req, opts)
+	}
+	usage.RecordHedgeCandidate()
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	primary, err := h.exec().ExecuteStream(primaryCtx, providers, req, opts)
+	if err != nil {
+		cancelPrimary()
+		return nil, err
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case chunk, ok := <-primary:
+		return prependChunk(chunk, ok, primary, cancelPrimary), nil
+	case <-ctx.Done():
+		cancelPrimary()
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	hedge, err := h.exec().ExecuteStream(hedgeCtx, providers, req, opts)
+	if err != nil {
+		cancelHedge()
+		logging.Entry(ctx).Debugf("hedged stream could not start: %v", err)
+		chunk, ok := <-primary
+		return prependChunk(chunk, ok, primary, cancelPrimary), nil
+	}
+	logging.Entry(ctx).Debugf("no first chunk after %s, started hedged stream", delay)
+
+	// A stream that fails before producing data drops out of the race as long
+	// as the other one is still running.
+	var lastErr coreexecutor.StreamChunk
+	for primary != nil || hedge != nil {
+		select {
+		case chunk, ok := <-primary:
+			if ok && chunk.Err == nil {
+				abandonStream(hedge, cancelHedge)
+				usage.RecordHedge(false)
+				return prependChunk(chunk, ok, primary, cancelPrimary), nil
+			}
+			if ok {
+				lastErr = chunk
+			}
+			abandonStream(primary, cancelPrimary)
+			primary = nil
+		case chunk, ok := <-hedge:
+			if ok && chunk.Err == nil {
+				abandonStream(primary, cancelPrimary)
+				usage.RecordHedge(true)
+				logging.Entry(ctx).Debug("hedged stream won")
+				return prependChunk(chunk, ok, hedge, cancelHedge), nil
+			}
+			if ok {
+				lastErr = chunk
+			}
+			abandonStream(hedge, cancelHedge)
+			hedge = nil
+		case <-ctx.Done():
+			abandonStream(primary, cancelPrimary)
+			abandonStream(hedge, cancelHedge)
+			return nil, ctx.Err()
+		}
+	}
+	usage.RecordHedge(false)
+	out := make(chan coreexecutor.StreamChunk, 1)
+	if lastErr.Err != nil {
+		out <- lastErr
+	}
+	close(out)
+	return out, nil
+}
+
+// prependChunk returns a channel yielding first (if ok) and then the rest of
+// rest. cancel is called once rest is exhausted.
+func prependChunk(first coreexecutor.StreamChunk, ok bool, rest <-chan coreexecutor.StreamChunk, cancel context.CancelFunc) <-chan coreexecutor.StreamChunk {
+	out := make(chan coreexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+		if !ok {
+			return
+		}
+		out <- first
+		for chunk := range rest {
+			out <- chunk
+		}
+	}()
+	return out
+}
+
+// abandonStream cancels a losing stream and drains it so its producer exits.
+func abandonStream(stream <-chan coreexecutor.StreamChunk, cancel context.CancelFunc) {
+	if stream == nil {
+		return
+	}
+	cancel()
+	go func() {
+		for range stream {
+		}
+	}()
+}
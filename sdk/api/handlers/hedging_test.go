@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// stallFirstExecutor never answers its first stream and answers later streams
+// immediately.
+type stallFirstExecutor struct {
+	mu        sync.Mutex
+	calls     int
+	cancelled chan struct{}
+}
+
+func (e *stallFirstExecutor) Execute(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (e *stallFirstExecutor) ExecuteCount(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (e *stallFirstExecutor) ExecuteStream(ctx context.Context, _ []string, _ coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	e.mu.Lock()
+	e.calls++
+	call := e.calls
+	e.mu.Unlock()
+
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	if call == 1 {
+		go func() {
+			defer close(ch)
+			<-ctx.Done()
+			close(e.cancelled)
+		}()
+		return ch, nil
+	}
+	ch <- coreexecutor.StreamChunk{Payload: []byte("fast ")}
+	ch <- coreexecutor.StreamChunk{Payload: []byte("answer")}
+	close(ch)
+	return ch, nil
+}
+
+func TestExecuteStreamHedged_DuplicateWinsAndPrimaryIsCancelled(t *testing.T) {
+	executor := &stallFirstExecutor{cancelled: make(chan struct{})}
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		Streaming: sdkconfig.StreamingConfig{HedgeDelayMS: 10},
+	}, nil, WithExecutor(executor))
+
+	chunks, err := handler.executeStreamHedged(context.Background(), []string{"codex"}, coreexecutor.Request{}, coreexecutor.Options{})
+	if err != nil {
+		t.Fatalf("executeStreamHedged: %v", err)
+	}
+	var got []byte
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected error: %v", chunk.Err)
+		}
+		got = append(got, chunk.Payload...)
+	}
+	if string(got) != "fast answer" {
+		t.Fatalf("payload = %q", got)
+	}
+	select {
+	case <-executor.cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("stalled primary stream was not cancelled")
+	}
+}
+
+func TestExecuteStreamHedged_DisabledUsesSingleStream(t *testing.T) {
+	executor := &stallFirstExecutor{cancelled: make(chan struct{})}
+	executor.calls = 1
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil, WithExecutor(executor))
+
+	chunks, err := handler.executeStreamHedged(context.Background(), []string{"codex"}, coreexecutor.Request{}, coreexecutor.Options{})
+	if err != nil {
+		t.Fatalf("executeStreamHedged: %v", err)
+	}
+	for range chunks {
+	}
+	if executor.calls != 2 {
+		t.Fatalf("expected one stream, got %d", executor.calls-1)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// windowToolResultsMetadataKey records how many tool result bodies the
+	// history window omitted.
+	windowToolResultsMetadataKey = "history_window_tool_results_omitted"
+	// windowMessagesMetadataKey records how many messages the history window dropped.
+	windowMessagesMetadataKey = "history_window_messages_dropped"
+
+	defaultWindowReserveTokens = 4096
+)
+
+// historyWindowBudget returns the number of prompt tokens a request may use:
+// the model's context window less the room kept for the response. It is 0
+// when the window is unknown or too small for the reserve.
+func (h *BaseAPIHandler) historyWindowBudget(rawJSON []byte, model string) int64 {
+	window := 0
+	if profile := h.modelProfile(model); profile != nil {
+		window = profile.ContextTokens
+	}
+	if window <= 0 {
+		window = registry.LookupModelInfo(thinking.ParseSuffix(model).ModelName).Capabilities().ContextWindow
+	}
+	if window <= 0 {
+		window = h.Cfg.HistoryWindow.ContextTokens
+	}
+	reserve := 0
+	for _, path := range []string{"max_tokens", "max_completion_tokens", "max_output_tokens"} {
+		if value := gjson.GetBytes(rawJSON, path).Int(); value > 0 {
+			reserve = int(value)
+			break
+		}
+	}
+	if reserve == 0 {
+		reserve = h.Cfg.HistoryWindow.ReserveTokens
+	}
+	if reserve <= 0 {
+		reserve = defaultWindowReserveTokens
+	}
+	if window <= reserve {
+		return 0
+	}
+	return int64(window - reserve)
+}
+
+// applyHistoryWindow fits the conversation into the model's context window.
+// The prompt is measured with the local tokenizer. When it is over budget the
+// bodies of the oldest tool results are omitted, as few as needed; when that
+// is not enough the oldest turns are dropped as well, keeping the latest
+// user turn whatever its size.
+func (h *BaseAPIHandler) applyHistoryWindow(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil || !h.Cfg.HistoryWindow.Enabled {
+		return rawJSON, nil
+	}
+	model := payloadModel(ctx, rawJSON)
+	if model == "" {
+		return rawJSON, nil
+	}
+	budget := h.historyWindowBudget(rawJSON, model)
+	if budget <= 0 {
+		return rawJSON, nil
+	}
+	tokens, err := localPromptTokens(handlerType, model, rawJSON)
+	if err != nil {
+		logging.Entry(ctx).Debugf("history window: cannot measure prompt: %v", err)
+		return rawJSON, nil
+	}
+	if tokens <= budget {
+		return rawJSON, nil
+	}
+	fits := func(payload []byte) bool {
+		count, errCount := localPromptTokens(handlerType, model, payload)
+		return errCount == nil && count <= budget
+	}
+
+	out, omitted := rawJSON, 0
+	if elidable := util.ElidableToolResults(handlerType, rawJSON); elidable > 0 {
+		n := sort.Search(elidable, func(i int) bool {
+			payload, _ := util.ElideToolResults(handlerType, rawJSON, i+1)
+			return fits(payload)
+		})
+		out, omitted = util.ElideToolResults(handlerType, rawJSON, min(n+1, elidable))
+	}
+	dropped := 0
+	if omitted == 0 || !fits(out) {
+		if turns := util.CountUserTurns(handlerType, out); turns > 1 {
+			i := sort.Search(turns-1, func(i int) bool {
+				payload, _ := util.TrimHistoryTurns(handlerType, out, turns-1-i)
+				return fits(payload)
+			})
+			out, dropped = util.TrimHistoryTurns(handlerType, out, max(turns-1-i, 1))
+		}
+	}
+	if omitted == 0 && dropped == 0 {
+		logging.Entry(ctx).Infof("history window: prompt of %d tokens exceeds the budget of %d and cannot be trimmed", tokens, budget)
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Infof("history window: prompt of %d tokens exceeds the budget of %d; omitted %d tool result(s), dropped %d message(s)", tokens, budget, omitted, dropped)
+	meta := map[string]any{}
+	if omitted > 0 {
+		meta[windowToolResultsMetadataKey] = omitted
+	}
+	if dropped > 0 {
+		meta[windowMessagesMetadataKey] = dropped
+	}
+	return out, meta
+}
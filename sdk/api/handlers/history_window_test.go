@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyHistoryWindow(t *testing.T) {
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		HistoryWindow: sdkconfig.HistoryWindowConfig{Enabled: true, ReserveTokens: 200},
+		ModelProfiles: []sdkconfig.ModelProfile{{Models: []string{"window-*"}, ContextTokens: 1500}},
+	}, nil)
+	long := strings.Repeat("alpha beta gamma delta ", 500)
+	toolTurn := `{"role":"user","content":"go"},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"read","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"c1","content":"` + long + `"},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"c2","type":"function","function":{"name":"read","arguments":"{}"}}]},` +
+		`{"role":"tool","tool_call_id":"c2","content":"latest"}`
+
+	request := []byte(`{"model":"window-test","messages":[{"role":"system","content":"be brief"},` + toolTurn + `]}`)
+	out, meta := handler.applyHistoryWindow(context.Background(), "openai", request)
+	if meta[windowToolResultsMetadataKey] != 1 || meta[windowMessagesMetadataKey] != nil {
+		t.Fatalf("meta = %v", meta)
+	}
+	if gjson.GetBytes(out, "messages.3.content").String() == long || gjson.GetBytes(out, "messages.5.content").String() != "latest" {
+		t.Fatalf("tool results = %s", out)
+	}
+
+	request = []byte(`{"model":"window-test","messages":[{"role":"user","content":"` + long + `"},{"role":"assistant","content":"noted"},` + toolTurn + `]}`)
+	out, meta = handler.applyHistoryWindow(context.Background(), "openai", request)
+	if meta[windowMessagesMetadataKey] != 2 || gjson.GetBytes(out, "messages.0.content").String() != "go" {
+		t.Fatalf("meta = %v, out = %s", meta, out)
+	}
+
+	// A large max_tokens leaves less room for the prompt.
+	small := []byte(`{"model":"window-test","max_tokens":1400,"messages":[{"role":"user","content":"hello"},{"role":"assistant","content":"` + strings.Repeat("word ", 200) + `"},{"role":"user","content":"again"}]}`)
+	if _, meta = handler.applyHistoryWindow(context.Background(), "openai", small); meta[windowMessagesMetadataKey] != 2 {
+		t.Fatalf("reserve not applied: %v", meta)
+	}
+	small = []byte(`{"model":"window-test","messages":[{"role":"user","content":"hello"}]}`)
+	if out, meta = handler.applyHistoryWindow(context.Background(), "openai", small); meta != nil || string(out) != string(small) {
+		t.Fatalf("request within budget changed: %v", meta)
+	}
+	if _, meta = handler.applyHistoryWindow(context.Background(), "openai", []byte(`{"model":"unknown-model","messages":[{"role":"user","content":"`+long+`"}]}`)); meta != nil {
+		t.Fatalf("model without a known window trimmed: %v", meta)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"golang.org/x/net/context"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	defaultIdempotencyTTL     = 10 * time.Minute
+	defaultIdempotencyEntries = 1000
+)
+
+// idempotentCall is one non-streaming request identified by its client's
+// Idempotency-Key. Duplicates wait on done and share its result.
+type idempotentCall struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	out         []byte
+	errMsg      *interfaces.ErrorMessage
+	expires     time.Time
+
+	cache *idempotencyCache
+	key   string
+}
+
+// idempotencyCache holds in-flight and completed idempotent requests of this
+// process. It survives config reloads.
+type idempotencyCache struct {
+	mu    sync.Mutex
+	calls map[string]*idempotentCall
+}
+
+func (h *BaseAPIHandler) idempotency() *idempotencyCache {
+	h.idempotencyOnce.Do(func() {
+		h.idempotent = &idempotencyCache{calls: make(map[string]*idempotentCall)}
+	})
+	return h.idempotent
+}
+
+// idempotentCall returns the call of a request carrying an Idempotency-Key
+// header, or nil when the header is absent or idempotency is disabled. The
+// caller executes the request and completes the call when leader is true;
+// otherwise it waits for the result of the first request with that key.
+func (h *BaseAPIHandler) idempotentCall(ctx context.Context, handlerType, modelName string, rawJSON []byte) (*idempotentCall, bool) {
+	if h == nil || h.Cfg == nil || !h.Cfg.Idempotency.Enabled || ctx == nil || ctx.Value(idempotencyAppliedKey{}) != nil {
+		return nil, false
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return nil, false
+	}
+	idemKey := strings.TrimSpace(ginCtx.GetHeader(idempotencyKeyHeader))
+	if idemKey == "" {
+		return nil, false
+	}
+	key := ginCtx.GetString("apiKey") + "\n" + idemKey
+	fingerprint := sha256.Sum256(bytes.Join([][]byte{[]byte(handlerType), []byte(modelName), rawJSON}, []byte{0}))
+
+	cache := h.idempotency()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	if call, found := cache.calls[key]; found && (call.expires.IsZero() || now.Before(call.expires)) {
+		if call.fingerprint != fingerprint {
+			body := `{"error":{"message":"Idempotency-Key was already used for a different request","type":"invalid_request_error","code":"idempotency_key_reused"}}`
+			done := make(chan struct{})
+			close(done)
+			return &idempotentCall{done: done, errMsg: &interfaces.ErrorMessage{StatusCode: http.StatusUnprocessableEntity, Error: errors.New(body)}}, false
+		}
+		logging.Entry(ctx).Debug("idempotency: serving a duplicate request from the first one")
+		return call, false
+	}
+	call := &idempotentCall{fingerprint: fingerprint, done: make(chan struct{}), cache: cache, key: key}
+	cache.calls[key] = call
+	cache.evict(now, h.idempotencyMaxEntries())
+	return call, true
+}
+
+// idempotencyAppliedKey marks a context whose client request already holds
+// its Idempotency-Key, so executions on it skip the check.
+type idempotencyAppliedKey struct{}
+
+// ExecuteIdempotent applies the client's Idempotency-Key once to a request
+// served by several upstream executions, such as choice fan-out or agent
+// rounds. execute runs on a context that skips the key check, so those
+// executions are neither merged with each other nor rejected as key reuses.
+func (h *BaseAPIHandler) ExecuteIdempotent(ctx context.Context, handlerType, modelName string, rawJSON []byte, execute func(ctx context.Context) ([]byte, *interfaces.ErrorMessage)) (out []byte, errMsg *interfaces.ErrorMessage) {
+	if call, leader := h.idempotentCall(ctx, handlerType, modelName, rawJSON); call != nil {
+		if !leader {
+			return call.wait(ctx)
+		}
+		ttl := h.idempotencyTTL()
+		defer func() { call.complete(out, errMsg, ttl) }()
+	}
+	return execute(context.WithValue(ctx, idempotencyAppliedKey{}, true))
+}
+
+func (h *BaseAPIHandler) idempotencyTTL() time.Duration {
+	if seconds := h.Cfg.Idempotency.TTLSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultIdempotencyTTL
+}
+
+func (h *BaseAPIHandler) idempotencyMaxEntries() int {
+	if entries := h.Cfg.Idempotency.MaxEntries; entries > 0 {
+		return entries
+	}
+	return defaultIdempotencyEntries
+}
+
+// evict drops expired responses and, above maxEntries, the completed ones
+// closest to expiry. In-flight calls are kept. The caller holds mu.
+func (c *idempotencyCache) evict(now time.Time, maxEntries int) {
+	for key, call := range c.calls {
+		if !call.expires.IsZero() && !now.Before(call.expires) {
+			delete(c.calls, key)
+		}
+	}
+	for len(c.calls) > maxEntries {
+		oldestKey := ""
+		var oldest time.Time
+		for key, call := range c.calls {
+			if !call.expires.IsZero() && (oldestKey == "" || call.expires.Before(oldest)) {
+				oldestKey, oldest = key, call.expires
+			}
+		}
+		if oldestKey == "" {
+			return
+		}
+		delete(c.calls, oldestKey)
+	}
+}
+
+// complete records the result of the leading request. Successful responses are
+// replayed until ttl passes; failures are handed to the waiting duplicates
+// only, so a later retry executes again.
+func (c *idempotentCall) complete(out []byte, errMsg *interfaces.ErrorMessage, ttl time.Duration) {
+	c.cache.mu.Lock()
+	c.out, c.errMsg = cloneBytes(out), errMsg
+	if errMsg != nil {
+		if c.cache.calls[c.key] == c {
+			delete(c.cache.calls, c.key)
+		}
+	} else {
+		c.expires = time.Now().Add(ttl)
+	}
+	c.cache.mu.Unlock()
+	close(c.done)
+}
+
+// wait returns the result of the leading request. Replayed responses carry the
+// Idempotent-Replayed header.
+func (c *idempotentCall) wait(ctx context.Context) ([]byte, *interfaces.ErrorMessage) {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		// 499: the client closed the request.
+		return nil, &interfaces.ErrorMessage{StatusCode: 499, Error: ctx.Err()}
+	}
+	if c.errMsg != nil {
+		return nil, c.errMsg
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(idempotentReplayedHeader, "true")
+	}
+	return cloneBytes(c.out), nil
+}
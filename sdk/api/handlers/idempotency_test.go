@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// countingExecutor counts upstream calls and holds each until release is closed.
+type countingExecutor struct {
+	staticExecutor
+	calls   *atomic.Int32
+	release chan struct{}
+	fail    *atomic.Bool
+}
+
+func (e countingExecutor) Execute(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	e.calls.Add(1)
+	<-e.release
+	if e.fail.Load() {
+		return coreexecutor.Response{}, errors.New("upstream failed")
+	}
+	return e.staticExecutor.Execute(ctx, providers, req, opts)
+}
+
+func idempotentContext(key string) (context.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Request.Header.Set(idempotencyKeyHeader, key)
+	ginCtx.Set("apiKey", "client-key")
+	return context.WithValue(context.Background(), "gin", ginCtx), recorder
+}
+
+func TestExecuteWithAuthManager_Idempotency(t *testing.T) {
+	exec := countingExecutor{
+		staticExecutor: staticExecutor{payload: []byte(`{"id":"msg_1"}`)},
+		calls:          new(atomic.Int32),
+		release:        make(chan struct{}),
+		fail:           new(atomic.Bool),
+	}
+	cfg := &config.SDKConfig{Idempotency: config.IdempotencyConfig{Enabled: true}}
+	h := NewBaseAPIHandlers(cfg, nil, WithExecutor(exec), WithModelRegistry(staticModels{"m": {"claude"}}))
+	request := []byte(`{"model":"m","messages":[{"role":"user","content":"a"}]}`)
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 3)
+	errs := make([]*interfaces.ErrorMessage, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, _ := idempotentContext("k1")
+			results[i], errs[i] = h.ExecuteWithAuthManager(ctx, "claude", "m", request, "")
+		}(i)
+	}
+	for exec.calls.Load() == 0 {
+		runtime.Gosched()
+	}
+	close(exec.release)
+	wg.Wait()
+	if n := exec.calls.Load(); n != 1 {
+		t.Fatalf("concurrent duplicates made %d upstream calls", n)
+	}
+	for i := range results {
+		if errs[i] != nil || string(results[i]) != `{"id":"msg_1"}` {
+			t.Fatalf("result %d = %s (%v)", i, results[i], errs[i])
+		}
+	}
+
+	ctx, recorder := idempotentContext("k1")
+	if out, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "m", request, ""); errMsg != nil || string(out) != `{"id":"msg_1"}` {
+		t.Fatalf("retry not replayed: %s (%v)", out, errMsg)
+	}
+	if exec.calls.Load() != 1 || recorder.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("retry reached the upstream or lacks the replay header")
+	}
+
+	ctx, _ = idempotentContext("k1")
+	other := []byte(`{"model":"m","messages":[{"role":"user","content":"b"}]}`)
+	if _, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "m", other, ""); errMsg == nil || errMsg.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with a different body = %v", errMsg)
+	}
+
+	exec.fail.Store(true)
+	for i := 0; i < 2; i++ {
+		ctx, _ = idempotentContext("k2")
+		if _, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "m", request, ""); errMsg == nil {
+			t.Fatal("expected the upstream error")
+		}
+	}
+	if n := exec.calls.Load(); n != 3 {
+		t.Fatalf("failed request was cached: %d upstream calls", n)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+const (
+	// inlineImagesMetadataKey records how many inline images were downscaled.
+	inlineImagesMetadataKey = "inline_images_downscaled"
+	// inlineImageTransformsKey records what was done to each of them.
+	inlineImageTransformsKey = "inline_image_transforms"
+)
+
+// applyInlineImageLimits downscales inline base64 images larger than the
+// configured limits. None of the supported upstreams accept file references
+// issued by this proxy, so oversized images are always shrunk in place.
+func (h *BaseAPIHandler) applyInlineImageLimits(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	cfg := h.Cfg.InlineImages
+	if cfg.MaxBytes <= 0 && cfg.MaxPixels <= 0 {
+		return rawJSON, nil
+	}
+	out, transforms := util.ProcessInlineImages(handlerType, rawJSON, util.ImageLimits{
+		MaxBytes:       cfg.MaxBytes,
+		MaxDimension:   cfg.MaxDimension,
+		MaxPixels:      cfg.MaxPixels,
+		PreserveFormat: cfg.PreserveFormat,
+	})
+	if len(transforms) == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("downscaled %d inline image(s), payload %d -> %d bytes", len(transforms), len(rawJSON), len(out))
+	return out, map[string]any{inlineImagesMetadataKey: len(transforms), inlineImageTransformsKey: transforms}
+}
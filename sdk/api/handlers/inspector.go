@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/inspect"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultInspectorEntries  = 100
+	defaultInspectorBodySize = 256 << 10
+)
+
+// inspection collects the request inspector record of one request and feeds
+// the audit log. All methods are no-ops on a nil inspection, which is used
+// when both the inspector and the audit log are disabled.
+type inspection struct {
+	ctx     context.Context
+	record  *inspect.Record
+	start   time.Time
+	store   bool
+	limit   int
+	maxBody int
+	audit   *auditTrail
+}
+
+// startInspection begins recording a request for the request inspector and
+// the audit log.
+func (h *BaseAPIHandler) startInspection(ctx context.Context, handlerType, modelName string, stream bool) *inspection {
+	store := h != nil && h.Cfg != nil && h.Cfg.RequestInspector.Enabled
+	trail := h.startAuditTrail(ctx)
+	if !store && trail == nil {
+		return nil
+	}
+	in := &inspection{ctx: ctx, start: time.Now(), store: store, limit: defaultInspectorEntries, maxBody: defaultInspectorBodySize, audit: trail}
+	if store {
+		cfg := h.Cfg.RequestInspector
+		if cfg.MaxEntries > 0 {
+			in.limit = cfg.MaxEntries
+		}
+		if cfg.MaxBodyBytes > 0 {
+			in.maxBody = cfg.MaxBodyBytes
+		}
+	}
+	id := logging.GetRequestID(ctx)
+	if id == "" {
+		id = logging.GenerateRequestID()
+	}
+	in.record = &inspect.Record{ID: id, Time: in.start, Format: handlerType, Model: modelName, Stream: stream}
+	return in
+}
+
+// prepared records the resolved model and the payload after all payload passes,
+// together with the actions those passes reported.
+func (in *inspection) prepared(model string, providers []string, payload []byte, meta map[string]any) {
+	if in == nil {
+		return
+	}
+	in.record.UpstreamModel = model
+	in.record.Providers = append([]string(nil), providers...)
+	if in.store {
+		in.record.SetRequest(payload, in.maxBody)
+	}
+	in.audit.request(payload)
+	for key, value := range meta {
+		in.action(key, value)
+	}
+}
+
+// routed records the backend that served the request after failover.
+func (in *inspection) routed(target failoverTarget) {
+	if in == nil || target.model == "" {
+		return
+	}
+	in.record.UpstreamModel = target.model
+	in.record.Providers = append([]string(nil), target.providers...)
+}
+
+// action records a transformation applied to the request or response.
+func (in *inspection) action(key string, value any) {
+	if in == nil {
+		return
+	}
+	if in.record.Actions == nil {
+		in.record.Actions = make(map[string]any)
+	}
+	in.record.Actions[key] = value
+}
+
+// shadowOf marks the record as the shadow copy of the primary request and
+// moves it to the context of the shadow request.
+func (in *inspection) shadowOf(ctx context.Context, id, primaryID string) {
+	if in == nil {
+		return
+	}
+	in.ctx = ctx
+	in.record.ID = id
+	in.action("shadow_of", primaryID)
+	if in.audit != nil {
+		in.audit.record.ShadowOf = primaryID
+	}
+}
+
+// response records response bytes as returned to the client.
+func (in *inspection) response(payload []byte) {
+	if in == nil || len(payload) == 0 {
+		return
+	}
+	in.record.Observe(in.record.Format, payload)
+	if in.store {
+		in.record.AppendResponse(payload, in.maxBody)
+	}
+	in.audit.response(payload)
+}
+
+// finish completes the record, stores it and writes the audit record. out is
+// the complete non-streaming response, if any.
+func (in *inspection) finish(out []byte, errMsg *interfaces.ErrorMessage) {
+	if in == nil {
+		return
+	}
+	in.response(out)
+	in.record.LatencyMS = time.Since(in.start).Milliseconds()
+	in.record.Status = http.StatusOK
+	if errMsg != nil {
+		in.record.Status = errMsg.StatusCode
+		if errMsg.Error != nil {
+			in.record.Error = errMsg.Error.Error()
+		}
+	}
+	if in.store {
+		inspect.Default().Add(in.record, in.limit)
+	}
+	in.audit.finish(in.ctx, in.record, errMsg)
+}
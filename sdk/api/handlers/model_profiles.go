@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// historyTrimmedMetadataKey records how many messages a profile trimmed.
+	historyTrimmedMetadataKey = "history_messages_trimmed"
+	// toolsDroppedMetadataKey records the tools a profile dropped.
+	toolsDroppedMetadataKey = "tools_dropped"
+)
+
+// payloadModelKey carries the resolved model name to the payload passes.
+type payloadModelKey struct{}
+
+// payloadModel returns the model a payload is executed against: the name
+// resolved by the handler, or the payload's model field when none was
+// recorded. Gemini payloads carry no model field; their model is in the URL.
+func payloadModel(ctx context.Context, rawJSON []byte) string {
+	if model, ok := ctx.Value(payloadModelKey{}).(string); ok && model != "" {
+		return model
+	}
+	return gjson.GetBytes(rawJSON, "model").String()
+}
+
+// modelProfile returns the first profile matching model.
+func (h *BaseAPIHandler) modelProfile(model string) *config.ModelProfile {
+	if h == nil || h.Cfg == nil || len(h.Cfg.ModelProfiles) == 0 {
+		return nil
+	}
+	if model == "" {
+		return nil
+	}
+	for i := range h.Cfg.ModelProfiles {
+		for _, pattern := range h.Cfg.ModelProfiles[i].Models {
+			if matchModelPattern(pattern, model) {
+				return &h.Cfg.ModelProfiles[i]
+			}
+		}
+	}
+	return nil
+}
+
+// applyModelProfile trims history and tool declarations as the model's profile
+// requires. The profile's tool-choice and sanitize settings are read by the
+// passes they replace.
+func (h *BaseAPIHandler) applyModelProfile(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	profile := h.modelProfile(payloadModel(ctx, rawJSON))
+	if profile == nil {
+		return rawJSON, nil
+	}
+	var meta map[string]any
+	out, trimmed := util.TrimHistoryTurns(handlerType, rawJSON, profile.MaxHistoryTurns)
+	if trimmed > 0 {
+		meta = mergeMetadata(meta, map[string]any{historyTrimmedMetadataKey: trimmed})
+	}
+	out, dropped := util.LimitTools(handlerType, out, profile.MaxTools)
+	if len(dropped) > 0 {
+		meta = mergeMetadata(meta, map[string]any{toolsDroppedMetadataKey: dropped})
+	}
+	if meta != nil {
+		logging.Entry(ctx).Debugf("model profile: trimmed %d message(s), dropped tools %v", trimmed, dropped)
+	}
+	return out, meta
+}
+
+// matchModelPattern matches model names case-insensitively against a pattern
+// in which '*' matches any run of characters.
+func matchModelPattern(pattern, model string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	model = strings.ToLower(model)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == model
+	}
+	if !strings.HasPrefix(model, parts[0]) {
+		return false
+	}
+	model = model[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(model, part)
+		if idx < 0 {
+			return false
+		}
+		model = model[idx+len(part):]
+	}
+	return strings.HasSuffix(model, parts[len(parts)-1])
+}
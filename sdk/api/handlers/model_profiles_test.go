@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyModelProfile(t *testing.T) {
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ModelProfiles: []sdkconfig.ModelProfile{
+			{Models: []string{"gpt-*"}, MaxTools: 5},
+			{Models: []string{"claude-*-sonnet*", "claude-3-7-*"}, MaxHistoryTurns: 1, MaxTools: 1},
+		},
+	}, nil)
+	request := []byte(`{"model":"Claude-3-7-Sonnet-20250219","messages":[{"role":"user","content":"old"},{"role":"assistant","content":"a"},{"role":"user","content":"new"}],"tools":[{"name":"a"},{"name":"b"}]}`)
+
+	out, meta := handler.applyModelProfile(context.Background(), "claude", request)
+	if meta[historyTrimmedMetadataKey] != 2 {
+		t.Fatalf("meta = %v", meta)
+	}
+	if got := gjson.GetBytes(out, "messages.#").Int(); got != 1 {
+		t.Fatalf("messages = %d", got)
+	}
+	if got := gjson.GetBytes(out, "tools.#.name").String(); got != `["a"]` {
+		t.Fatalf("tools = %s", got)
+	}
+
+	other := []byte(`{"model":"gemini-2.5-pro","messages":[{"role":"user","content":"old"}]}`)
+	if _, meta = handler.applyModelProfile(context.Background(), "claude", other); meta != nil {
+		t.Fatalf("unmatched model shaped: %v", meta)
+	}
+}
+
+func TestModelProfileUsesResolvedModelForGemini(t *testing.T) {
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		ModelProfiles: []sdkconfig.ModelProfile{{Models: []string{"gemini-2.5-*"}, MaxHistoryTurns: 1}},
+	}, nil)
+	// Gemini requests name the model in the URL, not the body.
+	request := []byte(`{"contents":[{"role":"user","parts":[{"text":"old"}]},{"role":"model","parts":[{"text":"a"}]},{"role":"user","parts":[{"text":"new"}]}]}`)
+
+	out, meta := handler.preparePayload(context.Background(), "gemini", "gemini-2.5-pro", request)
+	if meta[historyTrimmedMetadataKey] != 2 {
+		t.Fatalf("meta = %v", meta)
+	}
+	if got := gjson.GetBytes(out, "contents.0.parts.0.text").String(); got != "new" {
+		t.Fatalf("contents = %s", out)
+	}
+	if _, meta = handler.preparePayload(context.Background(), "gemini", "gemini-2.0-flash", request); meta[historyTrimmedMetadataKey] != nil {
+		t.Fatalf("unmatched model shaped: %v", meta)
+	}
+}
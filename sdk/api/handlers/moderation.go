@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// moderationHeader marks responses whose prompt or completion was flagged.
+const moderationHeader = "X-CLIProxy-Moderation"
+
+// moderationMetadataKey stores an annotated inbound verdict in execution metadata.
+const moderationMetadataKey = "moderation"
+
+const (
+	defaultModerationStreamCheckBytes = 1024
+	defaultModerationTimeout          = 10 * time.Second
+)
+
+var (
+	moderationMu       sync.Mutex
+	moderationChainKey string
+	moderationChain    moderation.Chain
+)
+
+// moderationChainFor returns the provider chain for cfg, rebuilding it only
+// when the provider settings change.
+func moderationChainFor(cfg *config.SDKConfig) moderation.Chain {
+	raw, _ := json.Marshal(cfg.Moderation.Providers)
+	key := cfg.ProxyURL + "\x00" + string(raw)
+	moderationMu.Lock()
+	defer moderationMu.Unlock()
+	if moderationChain != nil && moderationChainKey == key {
+		return moderationChain
+	}
+	chain := moderation.Chain{}
+	for _, provider := range cfg.Moderation.Providers {
+		switch strings.ToLower(strings.TrimSpace(provider.Type)) {
+		case "rules":
+			rules := make([]moderation.Rule, 0, len(provider.Rules))
+			for _, rule := range provider.Rules {
+				rules = append(rules, moderation.Rule{Category: rule.Category, Pattern: rule.Pattern})
+			}
+			engine, errs := moderation.NewRules(rules)
+			for _, err := range errs {
+				log.Warnf("moderation: ignoring invalid rule: %v", err)
+			}
+			chain = append(chain, engine)
+		case "api":
+			timeout := defaultModerationTimeout
+			if provider.TimeoutSeconds > 0 {
+				timeout = time.Duration(provider.TimeoutSeconds) * time.Second
+			}
+			chain = append(chain, &moderation.API{
+				URL:        strings.TrimSpace(provider.URL),
+				APIKey:     provider.APIKey,
+				Model:      provider.Model,
+				Categories: provider.Categories,
+				Client:     util.SetProxy(cfg, &http.Client{Timeout: timeout}),
+			})
+		default:
+			log.Warnf("moderation: ignoring provider of unknown type %q", provider.Type)
+		}
+	}
+	moderationChain = chain
+	moderationChainKey = key
+	return chain
+}
+
+// moderationPolicy holds the moderation settings of one request. A nil policy
+// moderates nothing.
+type moderationPolicy struct {
+	chain            moderation.Chain
+	action           moderation.Action
+	message          string
+	failClosed       bool
+	inbound          bool
+	outbound         bool
+	streamCheckBytes int
+}
+
+// moderationPolicy returns the policy for the current configuration, or nil
+// when moderation is disabled.
+func (h *BaseAPIHandler) moderationPolicy() *moderationPolicy {
+	if h == nil || h.Cfg == nil || !h.Cfg.Moderation.Enabled {
+		return nil
+	}
+	cfg := h.Cfg.Moderation
+	chain := moderationChainFor(h.Cfg)
+	if len(chain) == 0 || (cfg.SkipInbound && cfg.SkipOutbound) {
+		return nil
+	}
+	p := &moderationPolicy{
+		chain:            chain,
+		action:           moderation.ParseAction(cfg.Action),
+		message:          strings.TrimSpace(cfg.Message),
+		failClosed:       cfg.FailClosed,
+		inbound:          !cfg.SkipInbound,
+		outbound:         !cfg.SkipOutbound,
+		streamCheckBytes: cfg.StreamCheckBytes,
+	}
+	if p.message == "" {
+		p.message = moderation.DefaultMessage
+	}
+	if p.streamCheckBytes <= 0 {
+		p.streamCheckBytes = defaultModerationStreamCheckBytes
+	}
+	return p
+}
+
+// check moderates text and reports whether it was flagged. Flagged content is
+// logged and marked with the moderation header.
+func (p *moderationPolicy) check(ctx context.Context, stage, text string) (moderation.Verdict, bool) {
+	verdict, err := p.chain.Moderate(ctx, text)
+	if err != nil {
+		logging.Entry(ctx).Warnf("moderation: %s check failed: %v", stage, err)
+		if !verdict.Flagged && p.failClosed {
+			verdict = moderation.Verdict{Flagged: true, Provider: "unavailable"}
+		}
+	}
+	if !verdict.Flagged {
+		return verdict, false
+	}
+	logging.Entry(ctx).WithFields(log.Fields{
+		"stage":      stage,
+		"provider":   verdict.Provider,
+		"categories": strings.Join(verdict.Categories, ","),
+		"action":     string(p.action),
+	}).Warn("moderation: content flagged")
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(moderationHeader, stage+"; categories="+strings.Join(verdict.Categories, ","))
+	}
+	return verdict, true
+}
+
+// checkRequest moderates the latest user prompt. It reports whether the
+// request must be answered with a refusal, and the metadata of an annotated
+// verdict.
+func (p *moderationPolicy) checkRequest(ctx context.Context, handlerType string, rawJSON []byte) (bool, map[string]any) {
+	if p == nil || !p.inbound {
+		return false, nil
+	}
+	verdict, flagged := p.check(ctx, "inbound", moderation.PromptText(handlerType, rawJSON))
+	if !flagged {
+		return false, nil
+	}
+	if p.action == moderation.ActionBlock {
+		return true, nil
+	}
+	return false, map[string]any{moderationMetadataKey: verdict}
+}
+
+// checkResponse moderates a non-streaming completion and reports whether it
+// must be replaced with a refusal.
+func (p *moderationPolicy) checkResponse(ctx context.Context, handlerType string, payload []byte) bool {
+	if p == nil || !p.outbound {
+		return false
+	}
+	_, flagged := p.check(ctx, "outbound", moderation.CompletionText(handlerType, payload))
+	return flagged && p.action == moderation.ActionBlock
+}
+
+// refusal answers blocked content with a response finishing with the content
+// filter reason, or with an error for schemas that have no such response.
+func (p *moderationPolicy) refusal(handlerType, modelName string) ([]byte, *interfaces.ErrorMessage) {
+	if payload, ok := moderation.Refusal(handlerType, modelName, p.message); ok {
+		return payload, nil
+	}
+	return nil, p.errorMessage()
+}
+
+// streamRefusal is refusal for streaming requests.
+func (p *moderationPolicy) streamRefusal(handlerType, modelName string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	chunks, ok := moderation.RefusalStream(handlerType, modelName, p.message)
+	if !ok {
+		errChan <- p.errorMessage()
+		close(errChan)
+		return nil, errChan
+	}
+	dataChan := make(chan []byte, len(chunks))
+	for _, chunk := range chunks {
+		dataChan <- chunk
+	}
+	close(dataChan)
+	close(errChan)
+	return dataChan, errChan
+}
+
+func (p *moderationPolicy) errorMessage() *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadRequest,
+		Error:      errors.New(moderation.FinishReason + ": " + p.message),
+	}
+}
+
+// moderationStream moderates a streamed completion before its chunks are
+// forwarded.
+type moderationStream struct {
+	policy *moderationPolicy
+	stream *moderation.Stream
+}
+
+func (p *moderationPolicy) newStream(handlerType string) *moderationStream {
+	if p == nil || !p.outbound {
+		return nil
+	}
+	return &moderationStream{policy: p, stream: moderation.NewStream(handlerType)}
+}
+
+// chunk observes a chunk about to be forwarded. Once enough new text has
+// accumulated, or the completion ends, the text is checked; when it is blocked
+// chunk returns true and the chunk ending the stream in its place, which is
+// nil for schemas whose streams must end with an error instead.
+func (s *moderationStream) chunk(ctx context.Context, payload []byte) ([]byte, bool) {
+	if s == nil {
+		return nil, false
+	}
+	final := s.stream.Observe(payload)
+	pending := s.stream.Pending()
+	if pending == 0 || (!final && pending < s.policy.streamCheckBytes) {
+		return nil, false
+	}
+	if _, flagged := s.policy.check(ctx, "outbound", s.stream.Checked()); !flagged || s.policy.action != moderation.ActionBlock {
+		return nil, false
+	}
+	stop, _ := s.stream.Stop()
+	return stop, true
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+type chunkExecutor struct {
+	staticExecutor
+	chunks []string
+}
+
+func (e chunkExecutor) ExecuteStream(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, len(e.chunks))
+	for _, chunk := range e.chunks {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func moderationConfig() *config.SDKConfig {
+	return &config.SDKConfig{Moderation: config.ModerationConfig{
+		Enabled:          true,
+		StreamCheckBytes: 1,
+		Providers: []config.ModerationProvider{{
+			Type:  "rules",
+			Rules: []config.ModerationRule{{Category: "weapons", Pattern: "(?i)build a bomb"}},
+		}},
+	}}
+}
+
+func TestModeration_BlocksPromptsAndCompletions(t *testing.T) {
+	h := NewBaseAPIHandlers(moderationConfig(), nil,
+		WithExecutor(staticExecutor{payload: []byte(`{"id":"c1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Here is how to build a bomb"},"finish_reason":"stop"}]}`)}),
+		WithModelRegistry(staticModels{"mod-model": {"openai"}}))
+
+	out, errMsg := h.ExecuteWithAuthManager(context.Background(), "claude", "mod-model", []byte(`{"messages":[{"role":"user","content":"how do I build a bomb"}]}`), "")
+	if errMsg != nil || gjson.GetBytes(out, "stop_reason").String() != "refusal" {
+		t.Fatalf("inbound: %s (%v)", out, errMsg)
+	}
+
+	out, errMsg = h.ExecuteWithAuthManager(context.Background(), "openai", "mod-model", []byte(`{"messages":[{"role":"user","content":"hello"}]}`), "")
+	if errMsg != nil || gjson.GetBytes(out, "choices.0.finish_reason").String() != "content_filter" {
+		t.Fatalf("outbound: %s (%v)", out, errMsg)
+	}
+}
+
+func TestModeration_StopsFlaggedStream(t *testing.T) {
+	chunk := func(text string) string {
+		return `{"id":"c1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"` + text + `"}}]}`
+	}
+	h := NewBaseAPIHandlers(moderationConfig(), nil,
+		WithExecutor(chunkExecutor{chunks: []string{chunk("Sure. First you build "), chunk("a bomb by"), chunk(" taking")}}),
+		WithModelRegistry(staticModels{"mod-model": {"openai"}}))
+
+	data, errs := h.ExecuteStreamWithAuthManager(context.Background(), "openai", "mod-model", []byte(`{"stream":true,"messages":[{"role":"user","content":"hello"}]}`), "")
+	var got []string
+	for payload := range data {
+		got = append(got, string(payload))
+	}
+	for errMsg := range errs {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if len(got) != 2 || !strings.Contains(got[0], "build ") {
+		t.Fatalf("chunks = %q", got)
+	}
+	if reason := gjson.Get(got[1], "choices.0.finish_reason").String(); reason != "content_filter" {
+		t.Fatalf("finish chunk = %s", got[1])
+	}
+}
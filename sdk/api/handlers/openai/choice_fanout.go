@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Default limits applied when choice fan-out is enabled without explicit values.
+const (
+	defaultFanOutMaxChoices  = 4
+	defaultFanOutConcurrency = 2
+)
+
+// fanOutChoices returns the number of choices to fan out for rawJSON, or 0 when
+// the request should be executed unchanged.
+func (h *OpenAIAPIHandler) fanOutChoices(rawJSON []byte) (int, *interfaces.ErrorMessage) {
+	if h.Cfg == nil || !h.Cfg.ChoiceFanOut.Enabled {
+		return 0, nil
+	}
+	n := gjson.GetBytes(rawJSON, "n")
+	if n.Type != gjson.Number || n.Int() <= 1 {
+		return 0, nil
+	}
+	limit := h.Cfg.ChoiceFanOut.MaxChoices
+	if limit <= 0 {
+		limit = defaultFanOutMaxChoices
+	}
+	if n.Int() > int64(limit) {
+		return 0, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("n must be at most %d", limit),
+		}
+	}
+	return int(n.Int()), nil
+}
+
+// executeFanOut issues n single-choice requests, at most the configured
+// concurrency at a time, and merges them into one multi-choice response.
+// The first failure cancels the remaining requests and is returned as is.
+func (h *OpenAIAPIHandler) executeFanOut(ctx context.Context, modelName string, rawJSON []byte, alt string, n int) ([]byte, *interfaces.ErrorMessage) {
+	single, _ := sjson.DeleteBytes(rawJSON, "n")
+	concurrency := h.Cfg.ChoiceFanOut.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultFanOutConcurrency
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr *interfaces.ErrorMessage
+	)
+	results := make([][]byte, n)
+	slots := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+			resp, errMsg := h.ExecuteWithAuthManager(ctx, h.HandlerType(), modelName, single, alt)
+			if errMsg != nil {
+				once.Do(func() {
+					firstErr = errMsg
+					cancel()
+				})
+				return
+			}
+			results[i] = resp
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	for _, resp := range results {
+		if resp == nil {
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusRequestTimeout, Error: context.Cause(ctx)}
+		}
+	}
+	return mergeChoiceResponses(results), nil
+}
+
+// mergeChoiceResponses combines single-choice chat completions into one
+// response with sequentially indexed choices. Usage follows OpenAI's n > 1
+// semantics: the prompt is counted once and completion tokens are summed.
+func mergeChoiceResponses(responses [][]byte) []byte {
+	out := responses[0]
+	choices := "[]"
+	index := 0
+	var completionTokens, reasoningTokens int64
+	for _, resp := range responses {
+		gjson.GetBytes(resp, "choices").ForEach(func(_, choice gjson.Result) bool {
+			merged, _ := sjson.Set(choice.Raw, "index", index)
+			choices, _ = sjson.SetRaw(choices, "-1", merged)
+			index++
+			return true
+		})
+		completionTokens += gjson.GetBytes(resp, "usage.completion_tokens").Int()
+		reasoningTokens += gjson.GetBytes(resp, "usage.completion_tokens_details.reasoning_tokens").Int()
+	}
+	out, _ = sjson.SetRawBytes(out, "choices", []byte(choices))
+	if gjson.GetBytes(out, "usage").Exists() {
+		promptTokens := gjson.GetBytes(out, "usage.prompt_tokens").Int()
+		out, _ = sjson.SetBytes(out, "usage.completion_tokens", completionTokens)
+		out, _ = sjson.SetBytes(out, "usage.total_tokens", promptTokens+completionTokens)
+		if reasoningTokens > 0 {
+			out, _ = sjson.SetBytes(out, "usage.completion_tokens_details.reasoning_tokens", reasoningTokens)
+		}
+	}
+	return out
+}
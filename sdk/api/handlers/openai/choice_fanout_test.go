@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+func TestMergeChoiceResponses(t *testing.T) {
+	responses := [][]byte{
+		[]byte(`{"id":"a","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"one"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":3,"total_tokens":13}}`),
+		[]byte(`{"id":"b","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"two"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`),
+	}
+	out := mergeChoiceResponses(responses)
+
+	if got := gjson.GetBytes(out, "id").String(); got != "a" {
+		t.Fatalf("id = %q, want first response id", got)
+	}
+	choices := gjson.GetBytes(out, "choices").Array()
+	if len(choices) != 2 || choices[1].Get("index").Int() != 1 || choices[1].Get("message.content").String() != "two" {
+		t.Fatalf("unexpected choices: %s", gjson.GetBytes(out, "choices").Raw)
+	}
+	usage := gjson.GetBytes(out, "usage")
+	if usage.Get("prompt_tokens").Int() != 10 || usage.Get("completion_tokens").Int() != 8 || usage.Get("total_tokens").Int() != 18 {
+		t.Fatalf("unexpected usage: %s", usage.Raw)
+	}
+}
+
+// fanOutExecutor answers each upstream call with a distinct choice.
+type fanOutExecutor struct {
+	wsExecutor
+	calls *atomic.Int32
+}
+
+func (e fanOutExecutor) Execute(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	n := e.calls.Add(1)
+	return coreexecutor.Response{Payload: []byte(fmt.Sprintf(`{"id":"c","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"answer %d"},"finish_reason":"stop"}]}`, n))}, nil
+}
+
+func TestFanOutWithIdempotencyKeyRunsEachChoice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exec := fanOutExecutor{calls: new(atomic.Int32)}
+	cfg := &config.SDKConfig{
+		ChoiceFanOut: config.ChoiceFanOutConfig{Enabled: true},
+		Idempotency:  config.IdempotencyConfig{Enabled: true},
+	}
+	base := handlers.NewBaseAPIHandlers(cfg, nil, handlers.WithExecutor(exec), handlers.WithModelRegistry(wsModels{}))
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", NewOpenAIAPIHandler(base).ChatCompletions)
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-test","n":3,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	contents := gjson.Get(rec.Body.String(), "choices.#.message.content").Array()
+	if len(contents) != 3 || contents[0].String() == contents[1].String() || contents[1].String() == contents[2].String() {
+		t.Fatalf("choices = %v", contents)
+	}
+	if retry := send(); retry.Body.String() != rec.Body.String() || exec.calls.Load() != 3 {
+		t.Fatalf("retry not replayed: %d upstream calls, body %s", exec.calls.Load(), retry.Body)
+	}
+}
@@ -62,7 +62,7 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 	// Get all available models
 	allModels := h.Models()
 
-	// Filter to only include the 4 required fields: id, object, created, owned_by
+	// Keep the required fields (id, object, created, owned_by) and capability metadata
 	filteredModels := make([]map[string]any, len(allModels))
 	for i, model := range allModels {
 		filteredModel := map[string]any{
@@ -80,6 +80,13 @@ func (h *OpenAIAPIHandler) OpenAIModels(c *gin.Context) {
 			filteredModel["owned_by"] = ownedBy
 		}
 
+		// Capability metadata lets CLIs that autodetect models configure themselves.
+		for _, key := range []string{"context_length", "upstream_model", "capabilities"} {
+			if value, exists := model[key]; exists {
+				filteredModel[key] = value
+			}
+		}
+
 		filteredModels[i] = filteredModel
 	}
 
@@ -425,7 +432,20 @@ func (h *OpenAIAPIHandler) handleNonStreamingResponse(c *gin.Context, rawJSON []
 
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
-	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	choices, errMsg := h.fanOutChoices(rawJSON)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	var resp []byte
+	if choices > 1 {
+		resp, errMsg = h.ExecuteIdempotent(cliCtx, h.HandlerType(), modelName, rawJSON, func(ctx context.Context) ([]byte, *interfaces.ErrorMessage) {
+			return h.executeFanOut(ctx, modelName, rawJSON, h.GetAlt(c), choices)
+		})
+	} else {
+		resp, errMsg = h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	}
 	if errMsg != nil {
 		h.WriteErrorResponse(c, errMsg)
 		cliCancel(errMsg.Error)
@@ -458,6 +478,7 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 	modelName := gjson.GetBytes(rawJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, h.GetAlt(c))
+	usage := newStreamUsage(rawJSON)
 
 	setSSEHeaders := func() {
 		c.Header("Content-Type", "text/event-stream")
@@ -487,10 +508,11 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 			}
 			return
 		case chunk, ok := <-dataChan:
+			framer := newSSEFramer(c.Writer, usage)
 			if !ok {
 				// Stream closed without data? Send DONE or just headers.
 				setSSEHeaders()
-				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+				framer.finish()
 				flusher.Flush()
 				cliCancel(nil)
 				return
@@ -499,11 +521,11 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 			// Success! Commit to streaming headers.
 			setSSEHeaders()
 
-			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
+			framer.chunk(chunk)
 			flusher.Flush()
 
 			// Continue streaming the rest
-			h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan)
+			h.handleStreamResult(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, framer)
 			return
 		}
 	}
@@ -591,9 +613,10 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			}
 			return
 		case chunk, ok := <-dataChan:
+			framer := newSSEFramer(c.Writer, nil)
 			if !ok {
 				setSSEHeaders()
-				_, _ = fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+				framer.finish()
 				flusher.Flush()
 				cliCancel(nil)
 				return
@@ -603,11 +626,10 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			setSSEHeaders()
 
 			// Write the first chunk
-			converted := convertChatCompletionsStreamChunkToCompletions(chunk)
-			if converted != nil {
-				_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(converted))
-				flusher.Flush()
+			for _, converted := range convertCompletionsStreamChunk(chunk) {
+				framer.event(converted)
 			}
+			flusher.Flush()
 
 			done := make(chan struct{})
 			var doneOnce sync.Once
@@ -624,14 +646,12 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 						if !ok {
 							return
 						}
-						converted := convertChatCompletionsStreamChunkToCompletions(chunk)
-						if converted == nil {
-							continue
-						}
-						select {
-						case <-done:
-							return
-						case convertedChan <- converted:
+						for _, converted := range convertCompletionsStreamChunk(chunk) {
+							select {
+							case <-done:
+								return
+							case convertedChan <- converted:
+							}
 						}
 					}
 				}
@@ -640,16 +660,28 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			h.handleStreamResult(c, flusher, func(err error) {
 				stop()
 				cliCancel(err)
-			}, convertedChan, errChan)
+			}, convertedChan, errChan, framer)
 			return
 		}
 	}
 }
-func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
+
+// convertCompletionsStreamChunk converts the chat completion events carried
+// by a stream chunk to completions events.
+func convertCompletionsStreamChunk(chunk []byte) [][]byte {
+	payloads, _ := ssePayloads(chunk)
+	converted := make([][]byte, 0, len(payloads))
+	for _, payload := range payloads {
+		if out := convertChatCompletionsStreamChunkToCompletions(payload); out != nil {
+			converted = append(converted, out)
+		}
+	}
+	return converted
+}
+
+func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, framer *sseFramer) {
 	h.ForwardStream(c, flusher, cancel, data, errs, handlers.StreamForwardOptions{
-		WriteChunk: func(chunk []byte) {
-			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(chunk))
-		},
+		WriteChunk: framer.chunk,
 		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
 			if errMsg == nil {
 				return
@@ -662,11 +694,8 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			if errMsg.Error != nil && errMsg.Error.Error() != "" {
 				errText = errMsg.Error.Error()
 			}
-			body := handlers.BuildErrorResponseBody(status, errText)
-			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(body))
-		},
-		WriteDone: func() {
-			_, _ = fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+			framer.fail(handlers.BuildErrorResponseBody(status, errText))
 		},
+		WriteDone: framer.finish,
 	})
 }
@@ -0,0 +1,264 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// defaultWSPingInterval is used when streaming keep-alives are disabled.
+const defaultWSPingInterval = 30 * time.Second
+
+const (
+	// defaultWSReadLimit caps a frame when no request size limit rejects
+	// larger requests.
+	defaultWSReadLimit = 32 << 20
+	// wsEnvelopeBytes is the room left for the frame around the request body.
+	wsEnvelopeBytes = 4 << 10
+)
+
+// wsReadLimit returns the largest frame a client may send. When
+// request-limits rejects requests over max-total-bytes, frames are capped just
+// above it so WebSocket clients get the same limit as HTTP clients; with the
+// truncate action a larger request may still be shortened to fit, so only the
+// default cap applies unless the limit is higher.
+func wsReadLimit(cfg *config.SDKConfig) int64 {
+	if cfg == nil || cfg.RequestLimits.MaxTotalBytes <= 0 {
+		return defaultWSReadLimit
+	}
+	limit := int64(cfg.RequestLimits.MaxTotalBytes) + wsEnvelopeBytes
+	if strings.EqualFold(strings.TrimSpace(cfg.RequestLimits.Action), "truncate") {
+		return max(limit, defaultWSReadLimit)
+	}
+	return limit
+}
+
+var wsStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// Clients authenticate with API keys, not cookies, so cross-origin
+	// upgrades carry no ambient credentials.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsMessage is a frame exchanged on /v1/chat/stream.
+//
+// Clients send {"type":"request","id":"...","body":{chat completion request}}
+// and {"type":"cancel","id":"..."}. The server answers each request with
+// "chunk" frames carrying the same chat.completion.chunk objects the SSE
+// endpoint emits, followed by a "done" or an "error" frame.
+type wsMessage struct {
+	Type  string          `json:"type"`
+	ID    string          `json:"id,omitempty"`
+	Body  json.RawMessage `json:"body,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// wsStreamConn serializes writes to a websocket connection.
+type wsStreamConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (w *wsStreamConn) send(msg wsMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_ = w.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return w.conn.WriteJSON(msg)
+}
+
+func (w *wsStreamConn) ping() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+}
+
+// ChatStreamWebSocket serves chat completion streams over a WebSocket for
+// clients that cannot use SSE. One request runs at a time per connection;
+// the server pings the client and drops connections that stop answering.
+func (h *OpenAIAPIHandler) ChatStreamWebSocket(c *gin.Context) {
+	conn, err := wsStreamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logging.Entry(c.Request.Context()).Debugf("chat stream websocket upgrade failed: %v", err)
+		return
+	}
+	ws := &wsStreamConn{conn: conn}
+	defer func() { _ = conn.Close() }()
+	conn.SetReadLimit(wsReadLimit(h.Cfg))
+
+	interval := handlers.StreamingKeepAliveInterval(h.Cfg)
+	if interval <= 0 {
+		interval = defaultWSPingInterval
+	}
+	extendDeadline := func() { _ = conn.SetReadDeadline(time.Now().Add(2 * interval)) }
+	extendDeadline()
+	conn.SetPongHandler(func(string) error {
+		extendDeadline()
+		return nil
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if ws.ping() != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var (
+		activeMu sync.Mutex
+		activeID string
+		cancel   context.CancelFunc
+		finished chan struct{}
+	)
+	defer func() {
+		activeMu.Lock()
+		if cancel != nil {
+			cancel()
+		}
+		done := finished
+		activeMu.Unlock()
+		if done != nil {
+			<-done
+		}
+	}()
+
+	for {
+		var msg wsMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				logging.Entry(c.Request.Context()).Debugf("chat stream websocket closed: %v", err)
+			}
+			return
+		}
+		extendDeadline()
+		switch msg.Type {
+		case "cancel":
+			activeMu.Lock()
+			if cancel != nil && (msg.ID == "" || msg.ID == activeID) {
+				cancel()
+			}
+			activeMu.Unlock()
+		case "request":
+			activeMu.Lock()
+			busy := finished != nil
+			if !busy {
+				var ctx context.Context
+				ctx, cancel = context.WithCancel(context.Background())
+				activeID = msg.ID
+				finished = make(chan struct{})
+				go func(id string, body []byte, ctx context.Context, done chan struct{}) {
+					defer close(done)
+					h.streamToWebSocket(ctx, c, ws, id, body)
+					activeMu.Lock()
+					cancel()
+					cancel, finished, activeID = nil, nil, ""
+					activeMu.Unlock()
+				}(msg.ID, msg.Body, ctx, finished)
+			}
+			activeMu.Unlock()
+			if busy {
+				_ = ws.send(wsError(msg.ID, http.StatusConflict, "a request is already streaming on this connection"))
+			}
+		default:
+			_ = ws.send(wsError(msg.ID, http.StatusBadRequest, "unknown message type "+msg.Type))
+		}
+	}
+}
+
+// streamToWebSocket runs one chat completion stream and forwards its chunks.
+func (h *OpenAIAPIHandler) streamToWebSocket(ctx context.Context, c *gin.Context, ws *wsStreamConn, id string, body []byte) {
+	if !gjson.ValidBytes(body) || !gjson.ParseBytes(body).IsObject() {
+		_ = ws.send(wsError(id, http.StatusBadRequest, "body must be a chat completion request object"))
+		return
+	}
+	if updated, err := sjson.SetBytes(body, "stream", true); err == nil {
+		body = updated
+	}
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, ctx)
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), gjson.GetBytes(body, "model").String(), body, "")
+	for {
+		select {
+		case <-ctx.Done():
+			cliCancel(ctx.Err())
+			_ = ws.send(wsError(id, 499, "request cancelled"))
+			return
+		case errMsg, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			if errMsg == nil {
+				continue
+			}
+			_ = ws.send(wsErrorMessage(id, errMsg))
+			cliCancel(errMsg.Error)
+			return
+		case chunk, ok := <-dataChan:
+			if !ok {
+				// A terminal error may still be pending after the data channel closes.
+				if errChan != nil {
+					if errMsg, ok := <-errChan; ok && errMsg != nil {
+						_ = ws.send(wsErrorMessage(id, errMsg))
+						cliCancel(errMsg.Error)
+						return
+					}
+				}
+				_ = ws.send(wsMessage{Type: "done", ID: id})
+				cliCancel(nil)
+				return
+			}
+			frame := wsMessage{Type: "chunk", ID: id, Data: chunk}
+			if !json.Valid(chunk) {
+				frame.Data, _ = json.Marshal(string(chunk))
+			}
+			if ws.send(frame) != nil {
+				cliCancel(errors.New("websocket write failed"))
+				return
+			}
+		}
+	}
+}
+
+func wsErrorMessage(id string, errMsg *interfaces.ErrorMessage) wsMessage {
+	status := http.StatusInternalServerError
+	if errMsg.StatusCode > 0 {
+		status = errMsg.StatusCode
+	}
+	text := http.StatusText(status)
+	if errMsg.Error != nil && errMsg.Error.Error() != "" {
+		text = errMsg.Error.Error()
+	}
+	return wsError(id, status, text)
+}
+
+func wsError(id string, status int, text string) wsMessage {
+	body := handlers.BuildErrorResponseBody(status, text)
+	if detail := gjson.GetBytes(body, "error"); detail.Exists() {
+		body = []byte(detail.Raw)
+	}
+	return wsMessage{Type: "error", ID: id, Error: body}
+}
@@ -0,0 +1,116 @@
+package openai
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type wsModels struct{}
+
+func (wsModels) GetModelProviders(string) []string { return []string{"openai"} }
+
+type wsExecutor struct{}
+
+func (wsExecutor) Execute(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (wsExecutor) ExecuteCount(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (wsExecutor) ExecuteStream(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	ch <- coreexecutor.StreamChunk{Payload: []byte(`{"object":"chat.completion.chunk","choices":[{"delta":{"content":"he"}}]}`)}
+	ch <- coreexecutor.StreamChunk{Payload: []byte(`{"object":"chat.completion.chunk","choices":[{"delta":{"content":"llo"}}]}`)}
+	close(ch)
+	return ch, nil
+}
+
+func TestChatStreamWebSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	base := handlers.NewBaseAPIHandlers(&config.SDKConfig{}, nil, handlers.WithExecutor(wsExecutor{}), handlers.WithModelRegistry(wsModels{}))
+	engine := gin.New()
+	engine.GET("/v1/chat/stream", NewOpenAIAPIHandler(base).ChatStreamWebSocket)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/chat/stream", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err = conn.WriteJSON(wsMessage{Type: "request", ID: "r1", Body: []byte(`{"model":"gpt-test","messages":[{"role":"user","content":"hi"}]}`)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var types []string
+	var text string
+	for {
+		var msg wsMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if msg.ID != "r1" {
+			t.Fatalf("frame id = %q", msg.ID)
+		}
+		types = append(types, msg.Type)
+		if msg.Type == "chunk" {
+			text += string(msg.Data[strings.Index(string(msg.Data), `"content":"`)+11 : strings.LastIndex(string(msg.Data), `"}}`)])
+		}
+		if msg.Type != "chunk" {
+			break
+		}
+	}
+	if strings.Join(types, ",") != "chunk,chunk,done" || text != "hello" {
+		t.Fatalf("frames = %v, text = %q", types, text)
+	}
+
+	if err = conn.WriteJSON(wsMessage{Type: "bogus", ID: "r2"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var msg wsMessage
+	if err = conn.ReadJSON(&msg); err != nil || msg.Type != "error" {
+		t.Fatalf("unknown type answered with %+v (%v)", msg, err)
+	}
+}
+
+func TestChatStreamWebSocketReadLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.SDKConfig{RequestLimits: config.RequestLimitsConfig{MaxTotalBytes: 1000}}
+	if got := wsReadLimit(cfg); got != 1000+wsEnvelopeBytes {
+		t.Fatalf("read limit = %d", got)
+	}
+	if got := wsReadLimit(&config.SDKConfig{RequestLimits: config.RequestLimitsConfig{MaxTotalBytes: 1000, Action: "truncate"}}); got != defaultWSReadLimit {
+		t.Fatalf("truncate read limit = %d", got)
+	}
+
+	base := handlers.NewBaseAPIHandlers(cfg, nil, handlers.WithExecutor(wsExecutor{}), handlers.WithModelRegistry(wsModels{}))
+	engine := gin.New()
+	engine.GET("/v1/chat/stream", NewOpenAIAPIHandler(base).ChatStreamWebSocket)
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/chat/stream", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	big := `{"model":"gpt-test","messages":[{"role":"user","content":"` + strings.Repeat("x", 8000) + `"}]}`
+	if err = conn.WriteJSON(wsMessage{Type: "request", ID: "r1", Body: []byte(big)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var msg wsMessage
+	err = conn.ReadJSON(&msg)
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("oversized frame answered with %+v (%v)", msg, err)
+	}
+}
@@ -0,0 +1,123 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// sseDone is the payload of the event that ends an OpenAI stream.
+var sseDone = []byte("[DONE]")
+
+// ssePayloads returns the event payloads carried by a stream chunk. Chunks are
+// normally one bare JSON object, but executors may hand over SSE text with
+// "data:" lines, several events at once or stray blank lines; event, id and
+// comment lines are dropped. A [DONE] payload is dropped and reported, since
+// the framer writes its own.
+func ssePayloads(chunk []byte) (payloads [][]byte, done bool) {
+	chunk = bytes.TrimSpace(chunk)
+	if len(chunk) == 0 {
+		return nil, false
+	}
+	if !bytes.HasPrefix(chunk, []byte("data:")) && !bytes.HasPrefix(chunk, []byte("event:")) && !bytes.HasPrefix(chunk, []byte(":")) {
+		if bytes.Equal(chunk, sseDone) {
+			return nil, true
+		}
+		return [][]byte{chunk}, false
+	}
+	var data [][]byte
+	flush := func() {
+		if len(data) == 0 {
+			return
+		}
+		payload := bytes.Join(data, []byte("\n"))
+		data = nil
+		if bytes.Equal(bytes.TrimSpace(payload), sseDone) {
+			done = true
+			return
+		}
+		payloads = append(payloads, payload)
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		switch {
+		case len(bytes.TrimSpace(line)) == 0:
+			flush()
+		case bytes.HasPrefix(line, []byte("data:")):
+			value := line[len("data:"):]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+			data = append(data, value)
+		}
+	}
+	flush()
+	return payloads, done
+}
+
+// sseFramer writes an OpenAI stream: every event as "data: " lines followed
+// by a blank line, and a single final "data: [DONE]".
+type sseFramer struct {
+	w     io.Writer
+	usage *streamUsage
+	done  bool
+}
+
+func newSSEFramer(w io.Writer, usage *streamUsage) *sseFramer {
+	return &sseFramer{w: w, usage: usage}
+}
+
+// chunk writes the events carried by an upstream chunk.
+func (f *sseFramer) chunk(chunk []byte) {
+	payloads, _ := ssePayloads(chunk)
+	for _, payload := range payloads {
+		f.event(f.usage.chunk(payload))
+	}
+}
+
+// event writes one payload as an SSE event. JSON is compacted so it fits on
+// one line; other multi-line text gets one data line per line.
+func (f *sseFramer) event(payload []byte) {
+	if f.done || len(payload) == 0 {
+		return
+	}
+	if bytes.ContainsAny(payload, "\r\n") {
+		var compact bytes.Buffer
+		if json.Compact(&compact, payload) == nil {
+			payload = compact.Bytes()
+		}
+	}
+	var buf bytes.Buffer
+	for _, line := range bytes.Split(bytes.ReplaceAll(payload, []byte("\r\n"), []byte("\n")), []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	_, _ = f.w.Write(buf.Bytes())
+}
+
+// finish writes the pending usage chunk and the [DONE] event, once.
+func (f *sseFramer) finish() {
+	if f.done {
+		return
+	}
+	if final := f.usage.final(); final != nil {
+		f.event(final)
+	}
+	f.end()
+}
+
+// fail writes an error event and the [DONE] event, once.
+func (f *sseFramer) fail(body []byte) {
+	if f.done {
+		return
+	}
+	f.event(body)
+	f.end()
+}
+
+func (f *sseFramer) end() {
+	_, _ = f.w.Write([]byte("data: [DONE]\n\n"))
+	f.done = true
+}
@@ -0,0 +1,189 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// sdkStreamEvents decodes an OpenAI stream the way the official SDKs do: lines
+// are read up to a blank line, "data:" values (one leading space stripped)
+// are joined with newlines, comment lines are ignored and an event is only
+// dispatched by a blank line, so a trailing event without one is lost. A
+// [DONE] event ends the stream, and an event carrying an error fails it. Each
+// other event must decode as JSON.
+func sdkStreamEvents(t *testing.T, body string) (events []gjson.Result, done bool, streamErr string) {
+	t.Helper()
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+			payload := bytes.TrimSuffix(data.Bytes(), []byte("\n"))
+			data.Reset()
+			if bytes.HasPrefix(payload, []byte("[DONE]")) {
+				return events, true, streamErr
+			}
+			if errObj := gjson.GetBytes(payload, "error"); errObj.Exists() {
+				return events, false, errObj.Get("message").String()
+			}
+			if !json.Valid(payload) {
+				t.Fatalf("event is not JSON: %q in %q", payload, body)
+			}
+			events = append(events, gjson.ParseBytes(payload))
+			continue
+		}
+		name, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		if name == "data" {
+			data.WriteString(value)
+			data.WriteByte('\n')
+		}
+	}
+	return events, false, streamErr
+}
+
+// framingExecutor streams the given raw chunks, then fails with err when set.
+type framingExecutor struct {
+	chunks []string
+	err    error
+}
+
+func (framingExecutor) Execute(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (framingExecutor) ExecuteCount(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, nil
+}
+
+func (e framingExecutor) ExecuteStream(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, len(e.chunks)+1)
+	for _, chunk := range e.chunks {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(chunk)}
+	}
+	if e.err != nil {
+		ch <- coreexecutor.StreamChunk{Err: e.err}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestSSEPayloads(t *testing.T) {
+	cases := []struct {
+		chunk string
+		want  []string
+		done  bool
+	}{
+		{chunk: `{"a":1}`, want: []string{`{"a":1}`}},
+		{chunk: "  \n", want: nil},
+		{chunk: "[DONE]", done: true},
+		{chunk: "data: {\"a\":1}\n\n", want: []string{`{"a":1}`}},
+		{chunk: "data:{\"a\":1}\r\n\r\ndata: {\"b\":2}\n\ndata: [DONE]\n\n", want: []string{`{"a":1}`, `{"b":2}`}, done: true},
+		{chunk: ": ping\nevent: delta\nid: 3\ndata: one\ndata: two", want: []string{"one\ntwo"}},
+	}
+	for _, tc := range cases {
+		payloads, done := ssePayloads([]byte(tc.chunk))
+		var got []string
+		for _, payload := range payloads {
+			got = append(got, string(payload))
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") || done != tc.done {
+			t.Errorf("ssePayloads(%q) = %q, %v; want %q, %v", tc.chunk, got, done, tc.want, tc.done)
+		}
+	}
+}
+
+func TestOpenAIStreamFraming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stream := func(path, request string, exec framingExecutor) string {
+		t.Helper()
+		base := handlers.NewBaseAPIHandlers(&config.SDKConfig{}, nil, handlers.WithExecutor(exec), handlers.WithModelRegistry(wsModels{}))
+		h := NewOpenAIAPIHandler(base)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(request))
+		if path == "/v1/completions" {
+			h.handleCompletionsStreamingResponse(c, []byte(request))
+		} else {
+			h.handleStreamingResponse(c, []byte(request))
+		}
+		return w.Body.String()
+	}
+	chunk := func(content string) string {
+		out, _ := json.Marshal(map[string]any{
+			"id": "c", "object": "chat.completion.chunk", "model": "m",
+			"choices": []any{map[string]any{"index": 0, "delta": map[string]any{"content": content}}},
+		})
+		return string(out)
+	}
+	awkward := []string{
+		chunk("a"),
+		"data: " + chunk("b") + "\n\n",
+		"\n",
+		"{\n  \"id\": \"c\",\n  \"object\": \"chat.completion.chunk\",\n  \"choices\": [{\"index\": 0, \"delta\": {\"content\": \"c\\nd\"}}]\n}",
+		"data: " + chunk("e") + "\n\ndata: " + chunk("f"),
+		"data: [DONE]\n\n",
+	}
+	chat := `{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+
+	body := stream("/v1/chat/completions", chat, framingExecutor{chunks: awkward})
+	if !strings.HasSuffix(body, "\n\ndata: [DONE]\n\n") || strings.Count(body, "[DONE]") != 1 {
+		t.Fatalf("stream does not end with a single [DONE]: %q", body)
+	}
+	events, done, streamErr := sdkStreamEvents(t, body)
+	var text strings.Builder
+	for _, event := range events {
+		text.WriteString(event.Get("choices.0.delta.content").String())
+	}
+	if !done || streamErr != "" || text.String() != "abc\ndef" {
+		t.Fatalf("decoded text = %q, done = %v, err = %q from %q", text.String(), done, streamErr, body)
+	}
+	for _, frame := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		if !strings.HasPrefix(frame, "data: ") || strings.Contains(frame, "\n") {
+			t.Fatalf("frame %q is not a single data line", frame)
+		}
+	}
+
+	withUsage := `{"model":"m","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+	usageChunk := `{"id":"c","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"total_tokens":4}}`
+	events, done, _ = sdkStreamEvents(t, stream("/v1/chat/completions", withUsage, framingExecutor{chunks: []string{"data: " + usageChunk + "\n\n"}}))
+	if !done || len(events) != 2 || events[1].Get("usage.total_tokens").Int() != 4 || len(events[1].Get("choices").Array()) != 0 {
+		t.Fatalf("usage events = %v, done = %v", events, done)
+	}
+
+	body = stream("/v1/chat/completions", chat, framingExecutor{chunks: []string{chunk("a")}, err: errors.New("upstream went away")})
+	if _, _, streamErr = sdkStreamEvents(t, body); streamErr != "upstream went away" || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("failed stream = %q, decoded error = %q", body, streamErr)
+	}
+
+	if body = stream("/v1/chat/completions", chat, framingExecutor{}); body != "data: [DONE]\n\n" {
+		t.Fatalf("empty stream = %q", body)
+	}
+
+	completion := `{"model":"m","stream":true,"prompt":"hi"}`
+	body = stream("/v1/completions", completion, framingExecutor{chunks: awkward})
+	events, done, _ = sdkStreamEvents(t, body)
+	text.Reset()
+	for _, event := range events {
+		text.WriteString(event.Get("choices.0.text").String())
+	}
+	if !done || text.String() != "abc\ndef" || strings.Count(body, "[DONE]") != 1 {
+		t.Fatalf("completions text = %q, done = %v from %q", text.String(), done, body)
+	}
+}
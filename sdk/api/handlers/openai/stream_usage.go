@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// streamUsage implements stream_options.include_usage for chat completion
+// streams. Translated streams attach usage to the chunk that carries the
+// finish reason; a client that asked for usage instead expects usage to be
+// null on every chunk and reported once in a final chunk with empty choices.
+// All methods are no-ops on a nil streamUsage, which is used when the client
+// did not ask for usage and chunks are forwarded unchanged.
+type streamUsage struct {
+	usage   string
+	id      string
+	model   string
+	created int64
+	sent    bool
+}
+
+// newStreamUsage returns the usage tracker for a chat completion request, or
+// nil when the request did not set stream_options.include_usage.
+func newStreamUsage(rawJSON []byte) *streamUsage {
+	if !gjson.GetBytes(rawJSON, "stream_options.include_usage").Bool() {
+		return nil
+	}
+	return &streamUsage{}
+}
+
+// chunk records the usage of a stream chunk and returns the chunk with its
+// usage set to null. A usage-only chunk from an upstream that honours
+// include_usage itself is returned unchanged.
+func (s *streamUsage) chunk(chunk []byte) []byte {
+	if s == nil || !gjson.ValidBytes(chunk) {
+		return chunk
+	}
+	root := gjson.ParseBytes(chunk)
+	if root.Get("object").String() != "chat.completion.chunk" {
+		return chunk
+	}
+	if id := root.Get("id").String(); id != "" {
+		s.id = id
+	}
+	if model := root.Get("model").String(); model != "" {
+		s.model = model
+	}
+	if created := root.Get("created").Int(); created > 0 {
+		s.created = created
+	}
+	usage := root.Get("usage")
+	if usage.IsObject() {
+		if choices := root.Get("choices"); choices.IsArray() && len(choices.Array()) == 0 {
+			s.sent = true
+			return chunk
+		}
+		s.usage = usage.Raw
+	}
+	if usage.Exists() && usage.Type == gjson.Null {
+		return chunk
+	}
+	if updated, err := sjson.SetRawBytes(chunk, "usage", []byte("null")); err == nil {
+		return updated
+	}
+	return chunk
+}
+
+// final returns the usage chunk to send before [DONE], or nil when usage was
+// already sent or never reported.
+func (s *streamUsage) final() []byte {
+	if s == nil || s.sent || s.usage == "" {
+		return nil
+	}
+	s.sent = true
+	out := []byte(`{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[]}`)
+	out, _ = sjson.SetBytes(out, "id", s.id)
+	out, _ = sjson.SetBytes(out, "created", s.created)
+	out, _ = sjson.SetBytes(out, "model", s.model)
+	out, _ = sjson.SetRawBytes(out, "usage", []byte(s.usage))
+	return out
+}
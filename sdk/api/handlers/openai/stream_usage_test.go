@@ -0,0 +1,42 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestStreamUsageMovesUsageToFinalChunk(t *testing.T) {
+	if newStreamUsage([]byte(`{"stream":true}`)) != nil {
+		t.Fatal("usage tracked without include_usage")
+	}
+	var disabled *streamUsage
+	chunk := []byte(`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"total_tokens":5}}`)
+	if string(disabled.chunk(chunk)) != string(chunk) || disabled.final() != nil {
+		t.Fatal("disabled tracker changed the stream")
+	}
+
+	usage := newStreamUsage([]byte(`{"stream":true,"stream_options":{"include_usage":true}}`))
+	first := usage.chunk([]byte(`{"id":"c1","object":"chat.completion.chunk","created":7,"model":"m","choices":[{"index":0,"delta":{"content":"hi"}}]}`))
+	if got := gjson.GetBytes(first, "usage"); !got.Exists() || got.Type != gjson.Null {
+		t.Fatalf("content chunk usage = %s", first)
+	}
+	last := usage.chunk(chunk)
+	if got := gjson.GetBytes(last, "usage"); got.Type != gjson.Null {
+		t.Fatalf("finish chunk still carries usage: %s", last)
+	}
+	final := usage.final()
+	if gjson.GetBytes(final, "choices.#").Int() != 0 || gjson.GetBytes(final, "usage.total_tokens").Int() != 5 ||
+		gjson.GetBytes(final, "id").String() != "c1" || gjson.GetBytes(final, "created").Int() != 7 {
+		t.Fatalf("final chunk = %s", final)
+	}
+	if usage.final() != nil {
+		t.Fatal("usage chunk sent twice")
+	}
+
+	passthrough := newStreamUsage([]byte(`{"stream_options":{"include_usage":true}}`))
+	upstream := []byte(`{"id":"c2","object":"chat.completion.chunk","choices":[],"usage":{"total_tokens":3}}`)
+	if string(passthrough.chunk(upstream)) != string(upstream) || passthrough.final() != nil {
+		t.Fatal("upstream usage chunk was not passed through once")
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// limitParallelToolCalls enforces parallel_tool_calls=false on a non-streaming
+// response. Upstreams without an equivalent switch may still return several
+// calls; all but the first are dropped. It returns the payload and the number
+// of calls dropped.
+func limitParallelToolCalls(ctx context.Context, handlerType string, request, response []byte) ([]byte, int) {
+	if !util.ParallelToolCallsDisabled(handlerType, request) {
+		return response, 0
+	}
+	out, dropped := util.LimitToolCalls(handlerType, response)
+	if dropped > 0 {
+		logging.Entry(ctx).Warnf("parallel_tool_calls=false: dropped %d extra tool call(s) returned by upstream", dropped)
+	}
+	return out, dropped
+}
+
+// newParallelToolCallStream returns the streaming counterpart of
+// limitParallelToolCalls, or nil when the request allows parallel calls.
+func newParallelToolCallStream(handlerType string, request []byte) *util.SingleToolCallStream {
+	if !util.ParallelToolCallsDisabled(handlerType, request) {
+		return nil
+	}
+	return util.NewSingleToolCallStream(handlerType)
+}
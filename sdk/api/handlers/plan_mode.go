@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/planmode"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// planModeHeader carries the plan mode phase of the conversation.
+	planModeHeader = "X-CLIProxy-Plan-Mode"
+	// planModeResponseField is the response field holding the plan mode state
+	// of conversations that used plan mode.
+	planModeResponseField = "cliproxy_plan_mode"
+)
+
+// planModeStatus is the plan mode state reported to clients.
+type planModeStatus struct {
+	Phase    planmode.Phase `json:"phase"`
+	Plan     string         `json:"plan,omitempty"`
+	Approved int            `json:"approved"`
+	Rejected int            `json:"rejected"`
+}
+
+// trackPlanMode advances the plan mode tracker of the request's conversation
+// with the client transcript and sets the plan mode response header. It
+// returns nil for requests that are not Claude Messages requests with a
+// metadata.user_id, which identifies the Claude Code session.
+func (h *BaseAPIHandler) trackPlanMode(ctx context.Context, handlerType string, rawJSON []byte) *planModeStatus {
+	if handlerType != "claude" || !h.featureEnabled(ctx, featureflags.PlanModeTracking) {
+		return nil
+	}
+	userID := strings.TrimSpace(gjson.GetBytes(rawJSON, "metadata.user_id").String())
+	if userID == "" {
+		return nil
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	apiKey := ""
+	if ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	key := audit.Digest([]byte(apiKey + "\n" + userID))
+	tracker := planmode.Update(key, gjson.GetBytes(rawJSON, "messages"), time.Now())
+	if ginCtx != nil {
+		ginCtx.Header(planModeHeader, string(tracker.Phase))
+	}
+	if !tracker.Active() && tracker.Approved+tracker.Rejected == 0 {
+		return nil
+	}
+	return &planModeStatus{Phase: tracker.Phase, Plan: tracker.Plan, Approved: tracker.Approved, Rejected: tracker.Rejected}
+}
+
+// attach adds the plan mode state to a JSON object response.
+func (s *planModeStatus) attach(ctx context.Context, payload []byte) []byte {
+	if s == nil || !gjson.ValidBytes(payload) || !gjson.ParseBytes(payload).IsObject() {
+		return payload
+	}
+	out, err := sjson.SetBytes(payload, planModeResponseField, s)
+	if err != nil {
+		logging.Entry(ctx).Debugf("plan mode state not attached: %v", err)
+		return payload
+	}
+	return out
+}
+
+// attachStream adds the plan mode state to the message of the message_start
+// event in a Claude Messages stream chunk. It reports whether the event was
+// found, so callers attach the state once per stream.
+func (s *planModeStatus) attachStream(ctx context.Context, chunk []byte) ([]byte, bool) {
+	if s == nil {
+		return chunk, false
+	}
+	lines := bytes.Split(chunk, []byte("\n"))
+	for i, line := range lines {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if gjson.GetBytes(data, "type").String() != "message_start" {
+			continue
+		}
+		updated, err := sjson.SetBytes(data, "message."+planModeResponseField, s)
+		if err != nil {
+			logging.Entry(ctx).Debugf("plan mode state not attached: %v", err)
+			return chunk, false
+		}
+		lines[i] = append([]byte("data: "), updated...)
+		return bytes.Join(lines, []byte("\n")), true
+	}
+	return chunk, false
+}
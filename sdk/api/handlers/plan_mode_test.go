@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// claudeStreamExecutor streams a short Claude Messages response.
+type claudeStreamExecutor struct{ staticExecutor }
+
+func (claudeStreamExecutor) ExecuteStream(context.Context, []string, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, 3)
+	ch <- coreexecutor.StreamChunk{Payload: []byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"role\":\"assistant\",\"content\":[]}}\n\n")}
+	ch <- coreexecutor.StreamChunk{Payload: []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"planning\"}}\n\n")}
+	ch <- coreexecutor.StreamChunk{Payload: []byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")}
+	close(ch)
+	return ch, nil
+}
+
+func TestExecuteStreamWithAuthManager_ReportsPlanMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&config.SDKConfig{}, nil, WithExecutor(claudeStreamExecutor{}), WithModelRegistry(staticModels{"plan-model": {"claude"}}))
+	request := []byte(`{"model":"plan-model","stream":true,"metadata":{"user_id":"stream-plan-session"},"messages":[` +
+		`{"role":"user","content":"plan it"},` +
+		`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"EnterPlanMode","input":{}}]},` +
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"Entered plan mode"}]}]}`)
+
+	rec := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(rec)
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(ctx, "claude", "plan-model", request, "")
+	var events []string
+	for chunk := range dataChan {
+		events = append(events, string(chunk))
+	}
+	for errMsg := range errChan {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if phase := ginCtx.Writer.Header().Get(planModeHeader); phase != "planning" {
+		t.Fatalf("plan mode header = %q", phase)
+	}
+	if len(events) == 0 || strings.Count(strings.Join(events, ""), planModeResponseField) != 1 {
+		t.Fatalf("plan mode state attached %d times: %q", strings.Count(strings.Join(events, ""), planModeResponseField), events)
+	}
+	_, data, _ := strings.Cut(events[0], "data: ")
+	if phase := gjson.Get(strings.TrimSpace(data), "message."+planModeResponseField+".phase").String(); phase != "planning" {
+		t.Fatalf("message_start = %s", events[0])
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/promptguard"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// promptGuardMetadataKey stores scanner findings in execution metadata so
+// executors and usage plugins can observe them.
+const promptGuardMetadataKey = "prompt_guard_findings"
+
+var (
+	promptGuardMu       sync.Mutex
+	promptGuardPatterns string
+	promptGuardScanner  *promptguard.Scanner
+)
+
+// promptGuardScannerFor returns a scanner for the configured extra patterns,
+// recompiling only when the pattern list changes.
+func promptGuardScannerFor(patterns []string) *promptguard.Scanner {
+	if len(patterns) == 0 {
+		return promptguard.Default()
+	}
+	key := strings.Join(patterns, "\x00")
+	promptGuardMu.Lock()
+	defer promptGuardMu.Unlock()
+	if promptGuardScanner != nil && promptGuardPatterns == key {
+		return promptGuardScanner
+	}
+	scanner, errs := promptguard.NewScanner(patterns)
+	for _, err := range errs {
+		log.Warnf("prompt guard: ignoring invalid pattern: %v", err)
+	}
+	promptGuardScanner = scanner
+	promptGuardPatterns = key
+	return scanner
+}
+
+// applyPromptGuard scans tool descriptions and tool results in rawJSON using the
+// policy configured for the calling API key. Findings are logged as structured
+// warnings and returned as metadata; the payload is rewritten only in neutralize mode.
+func (h *BaseAPIHandler) applyPromptGuard(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	apiKey := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	mode := promptguard.ParseMode(h.Cfg.PromptGuard.ModeForKey(apiKey))
+	if mode == promptguard.ModeOff {
+		return rawJSON, nil
+	}
+	out, findings := promptGuardScannerFor(h.Cfg.PromptGuard.Patterns).Scan(handlerType, rawJSON, mode)
+	if len(findings) == 0 {
+		return rawJSON, nil
+	}
+	entry := logging.Entry(ctx).WithFields(log.Fields{
+		"api_key": util.HideAPIKey(apiKey),
+		"mode":    string(mode),
+	})
+	for _, finding := range findings {
+		entry.WithFields(log.Fields{
+			"location": finding.Location,
+			"kind":     finding.Kind,
+			"rule":     finding.Rule,
+		}).Warnf("prompt guard: suspicious content: %q", finding.Excerpt)
+	}
+	return out, map[string]any{promptGuardMetadataKey: findings}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+// providerRoute matches a model name against the configured provider routes.
+// It returns the model name without the prefix and the providers allowed to
+// serve it. Names the registry knows as a whole are never rerouted.
+func (h *BaseAPIHandler) providerRoute(modelName string) (string, []string, bool) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.ProviderRoutes) == 0 {
+		return "", nil, false
+	}
+	prefix, model, found := strings.Cut(modelName, "/")
+	if !found || strings.TrimSpace(model) == "" {
+		return "", nil, false
+	}
+	if len(h.modelProviders(strings.TrimSpace(thinking.ParseSuffix(modelName).ModelName))) > 0 {
+		return "", nil, false
+	}
+	for _, route := range h.Cfg.ProviderRoutes {
+		if strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(route.Prefix), "/"), prefix) {
+			return model, route.Providers, true
+		}
+	}
+	return "", nil, false
+}
+
+// routedRequestDetails resolves a model routed by prefix and keeps only the
+// providers its route allows.
+func (h *BaseAPIHandler) routedRequestDetails(modelName, model string, allowed []string) ([]string, string, *interfaces.ErrorMessage) {
+	providers, normalizedModel, errMsg := h.getRequestDetails(model)
+	if errMsg != nil {
+		return nil, "", &interfaces.ErrorMessage{StatusCode: errMsg.StatusCode, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+	}
+	kept := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		for _, name := range allowed {
+			if strings.EqualFold(strings.TrimSpace(name), provider) {
+				kept = append(kept, provider)
+				break
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return nil, "", &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("model %s is not served by providers %s", model, strings.Join(allowed, ", ")),
+		}
+	}
+	return kept, normalizedModel, nil
+}
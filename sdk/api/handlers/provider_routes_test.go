@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestProviderRoutesRestrictProviders(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{ProviderRoutes: []sdkconfig.ProviderRoute{
+		{Prefix: "gemini/", Providers: []string{"gemini-cli"}},
+		{Prefix: "openai", Providers: []string{"codex"}},
+	}}
+	h := NewBaseAPIHandlers(cfg, nil, WithModelRegistry(staticModels{
+		"gemini-2.5-pro":     {"antigravity", "gemini-cli"},
+		"gpt-5":              {"codex", "openai-compat"},
+		"teamA/gpt-5":        {"codex"},
+		"gemini/custom-name": {"openai-compat"},
+	}))
+
+	providers, model, errMsg := h.getRequestDetails("Gemini/gemini-2.5-pro(8192)")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if model != "gemini-2.5-pro(8192)" || !reflect.DeepEqual(providers, []string{"gemini-cli"}) {
+		t.Fatalf("got %q %v", model, providers)
+	}
+
+	providers, model, _ = h.getRequestDetails("gemini/custom-name")
+	if model != "gemini/custom-name" || !reflect.DeepEqual(providers, []string{"openai-compat"}) {
+		t.Fatalf("registered prefixed name rerouted: %q %v", model, providers)
+	}
+
+	if _, _, errMsg = h.getRequestDetails("openai/gemini-2.5-pro"); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a model the route's providers do not serve, got %v", errMsg)
+	}
+	if _, _, errMsg = h.getRequestDetails("openai/unknown"); errMsg == nil {
+		t.Fatal("expected error for unknown routed model")
+	}
+}
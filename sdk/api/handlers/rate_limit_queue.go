@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratequeue"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultRateLimitMaxWait      = 30 * time.Second
+	defaultRateLimitInterval     = 200 * time.Millisecond
+	defaultRateLimitRetrySeconds = 5
+)
+
+// rateLimitQueueError is returned when a request waited for a rate-limited
+// model longer than rate-limit-queue.max-wait-seconds.
+type rateLimitQueueError struct {
+	model string
+	retry time.Duration
+}
+
+func (e *rateLimitQueueError) retrySeconds() int {
+	return int(math.Ceil(e.retry.Seconds()))
+}
+
+func (e *rateLimitQueueError) Error() string {
+	data, _ := json.Marshal(map[string]any{"error": map[string]any{
+		"code":          "rate_limit_queue_timeout",
+		"message":       fmt.Sprintf("Model %s is rate limited upstream; the request waited too long in the queue", e.model),
+		"model":         e.model,
+		"retry_seconds": e.retrySeconds(),
+	}})
+	return string(data)
+}
+
+func (e *rateLimitQueueError) StatusCode() int { return http.StatusTooManyRequests }
+
+func (e *rateLimitQueueError) Headers() http.Header {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Retry-After", strconv.Itoa(e.retrySeconds()))
+	return headers
+}
+
+// rateQueue returns the scheduler of rate-limited models, creating it on
+// first use so it survives config reloads.
+func (h *BaseAPIHandler) rateQueue() *ratequeue.Scheduler {
+	h.rateQueueOnce.Do(func() {
+		h.rateLimits = ratequeue.New(defaultRateLimitInterval)
+		h.rateLimits.SetObserver(usage.SetRateLimitQueueDepth)
+	})
+	return h.rateLimits
+}
+
+// withRateLimitQueue runs exec once the model is not held back by an earlier
+// 429, waiting its turn among the client API keys when it is. A 429 returned
+// by exec holds the model back for its Retry-After.
+func withRateLimitQueue[T any](ctx context.Context, h *BaseAPIHandler, model string, exec func() (T, error)) (T, error) {
+	if h == nil || h.Cfg == nil || !h.Cfg.RateLimitQueue.Enabled {
+		return exec()
+	}
+	cfg := h.Cfg.RateLimitQueue
+	queue := h.rateQueue()
+	interval := defaultRateLimitInterval
+	if cfg.ReleaseIntervalMS > 0 {
+		interval = time.Duration(cfg.ReleaseIntervalMS) * time.Millisecond
+	}
+	queue.SetInterval(interval)
+	maxWait := defaultRateLimitMaxWait
+	if cfg.MaxWaitSeconds > 0 {
+		maxWait = time.Duration(cfg.MaxWaitSeconds) * time.Second
+	}
+	defaultRetry := time.Duration(defaultRateLimitRetrySeconds) * time.Second
+	if cfg.DefaultRetrySeconds > 0 {
+		defaultRetry = time.Duration(cfg.DefaultRetrySeconds) * time.Second
+	}
+
+	caller := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		caller = ginCtx.GetString("apiKey")
+	}
+	queued, err := queue.Wait(ctx, model, caller, maxWait)
+	if queued {
+		usage.RecordRateLimitQueued(errors.Is(err, ratequeue.ErrTimeout))
+	}
+	if err != nil {
+		var zero T
+		if errors.Is(err, ratequeue.ErrTimeout) {
+			return zero, &rateLimitQueueError{model: model, retry: defaultRetry}
+		}
+		return zero, err
+	}
+
+	result, err := exec()
+	if statusFromError(err) == http.StatusTooManyRequests {
+		retry := retryAfterOf(err)
+		if retry <= 0 {
+			retry = defaultRetry
+		}
+		queue.Throttle(model, retry)
+		usage.RecordRateLimitThrottle()
+		log.Debugf("rate limit queue: holding back %s for %s", model, retry)
+	}
+	return result, err
+}
+
+// retryAfterOf returns the delay an upstream error asks for, from its
+// RetryAfter method or its Retry-After header in seconds.
+func retryAfterOf(err error) time.Duration {
+	if ra, ok := err.(interface{ RetryAfter() *time.Duration }); ok && ra != nil {
+		if d := ra.RetryAfter(); d != nil {
+			return *d
+		}
+	}
+	if he, ok := err.(interface{ Headers() http.Header }); ok && he != nil {
+		if seconds, errParse := strconv.Atoi(strings.TrimSpace(he.Headers().Get("Retry-After"))); errParse == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/diagnose"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const defaultDiagnosisMaxProbes = 6
+
+var defaultDiagnosisPatterns = []string{"improperly formed request", "malformed"}
+
+// diagnosedError carries the diagnosis of an upstream rejection to the error
+// response while keeping the status and headers of the original error.
+type diagnosedError struct {
+	err    error
+	detail string
+}
+
+func (e *diagnosedError) Error() string     { return e.err.Error() }
+func (e *diagnosedError) Unwrap() error     { return e.err }
+func (e *diagnosedError) Diagnosis() string { return e.detail }
+
+func (e *diagnosedError) StatusCode() int { return statusFromError(e.err) }
+
+func (e *diagnosedError) Headers() http.Header {
+	if he, ok := e.err.(interface{ Headers() http.Header }); ok && he != nil {
+		return he.Headers()
+	}
+	return nil
+}
+
+// diagnosisPattern reports whether err is a 400 whose message matches one of
+// the configured patterns.
+func (h *BaseAPIHandler) diagnosisPattern(err error) bool {
+	if h == nil || h.Cfg == nil || !h.Cfg.RequestDiagnosis.Enabled || statusFromError(err) != http.StatusBadRequest {
+		return false
+	}
+	patterns := h.Cfg.RequestDiagnosis.Patterns
+	if len(patterns) == 0 {
+		patterns = defaultDiagnosisPatterns
+	}
+	text := strings.ToLower(err.Error())
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" && strings.Contains(text, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnoseRejection explains a 400 the upstream returned without a usable
+// reason. It checks the payload as sent and, when configured, bisects its
+// history against the backend that rejected it. The finding is logged and,
+// with expose-detail, attached to the returned error.
+func (h *BaseAPIHandler) diagnoseRejection(ctx context.Context, handlerType string, target failoverTarget, payload []byte, err error) error {
+	if !h.diagnosisPattern(err) {
+		return err
+	}
+	cfg := h.Cfg.RequestDiagnosis
+	var findings []string
+	if problems := diagnose.Check(handlerType, payload); len(problems) > 0 {
+		findings = append(findings, problems...)
+	}
+	if cfg.Bisect {
+		maxProbes := cfg.MaxProbes
+		if maxProbes <= 0 {
+			maxProbes = defaultDiagnosisMaxProbes
+		}
+		probe := cloneBytes(payload)
+		if updated, errDelete := sjson.DeleteBytes(probe, "stream"); errDelete == nil {
+			probe = updated
+		}
+		fails := func(shortened []byte) bool {
+			_, errProbe := h.exec().Execute(ctx, target.providers, coreexecutor.Request{
+				Model:   target.model,
+				Payload: shortened,
+			}, coreexecutor.Options{
+				OriginalRequest: cloneBytes(shortened),
+				SourceFormat:    sdktranslator.FromString(handlerType),
+				Metadata:        requestExecutionMetadata(nil),
+			})
+			return statusFromError(errProbe) == http.StatusBadRequest
+		}
+		if finding, ok := diagnose.Bisect(handlerType, probe, maxProbes, fails); ok {
+			findings = append(findings, finding.String())
+		}
+	}
+	if len(findings) == 0 {
+		logging.Entry(ctx).Warn("request diagnosis: upstream rejected the request, no cause found")
+		return err
+	}
+	detail := strings.Join(findings, "; ")
+	logging.Entry(ctx).Warnf("request diagnosis: upstream rejected the request: %s", detail)
+	if !cfg.ExposeDetail {
+		return err
+	}
+	return &diagnosedError{err: err, detail: detail}
+}
+
+// withDiagnosis adds the diagnosis of a rejected request to an error body
+// shaped as {"error":{...}}. Other bodies are returned unchanged.
+func withDiagnosis(body []byte, err error) []byte {
+	de, ok := err.(interface{ Diagnosis() string })
+	if !ok || de.Diagnosis() == "" || !gjson.GetBytes(body, "error").IsObject() {
+		return body
+	}
+	if updated, errSet := sjson.SetBytes(body, "error.detail", de.Diagnosis()); errSet == nil {
+		return updated
+	}
+	return body
+}
+
+// retryRepaired retries a request the upstream rejected with a diagnosable 400
+// once per repair level, most conservative first, when repair is configured.
+// exec sends a rewritten client payload. It returns the first accepted result
+// with the level that produced it, or the original error and "" when every
+// level fails or changes nothing.
+func retryRepaired[T any](ctx context.Context, h *BaseAPIHandler, handlerType string, payload []byte, err error, exec func([]byte) (T, error)) (T, string, error) {
+	var zero T
+	if !h.diagnosisPattern(err) || !h.Cfg.RequestDiagnosis.Repair {
+		return zero, "", err
+	}
+	for _, level := range diagnose.RepairLevels {
+		if ctx.Err() != nil {
+			break
+		}
+		repaired, ok := diagnose.Repair(handlerType, payload, level)
+		if !ok {
+			continue
+		}
+		result, errRetry := exec(repaired)
+		if errRetry == nil {
+			logging.Entry(ctx).Warnf("request repair: upstream accepted the request after %s; the original history was rejected: %v", level, err)
+			return result, level, nil
+		}
+		logging.Entry(ctx).Debugf("request repair: %s did not help: %v", level, errRetry)
+		if statusFromError(errRetry) != http.StatusBadRequest {
+			break
+		}
+	}
+	return zero, "", err
+}
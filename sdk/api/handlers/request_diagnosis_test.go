@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// rejectingExecutor rejects every request whose history contains a poisoned turn.
+type rejectingExecutor struct {
+	staticExecutor
+	probes *int
+}
+
+func (e rejectingExecutor) Execute(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	*e.probes++
+	if bytes.Contains(req.Payload, []byte("poisoned")) {
+		return coreexecutor.Response{}, &coreauth.Error{Message: "Improperly formed request.", HTTPStatus: http.StatusBadRequest}
+	}
+	return e.staticExecutor.Execute(ctx, providers, req, opts)
+}
+
+func TestRequestDiagnosisExposesBisectedTurn(t *testing.T) {
+	probes := 0
+	cfg := &config.SDKConfig{RequestDiagnosis: config.RequestDiagnosisConfig{Enabled: true, Bisect: true, ExposeDetail: true}}
+	h := NewBaseAPIHandlers(cfg, nil,
+		WithExecutor(rejectingExecutor{staticExecutor: staticExecutor{payload: []byte(`{}`)}, probes: &probes}),
+		WithModelRegistry(staticModels{"diag-model": {"claude"}}))
+	request := []byte(`{"model":"diag-model","messages":[{"role":"user","content":"a"},{"role":"assistant","content":"poisoned"},{"role":"user","content":"b"}]}`)
+
+	_, errMsg := h.ExecuteWithAuthManager(context.Background(), "claude", "diag-model", request, "")
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the upstream 400, got %+v", errMsg)
+	}
+	if probes != 2 {
+		t.Fatalf("probes = %d, want the original request and one probe", probes)
+	}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	h.WriteErrorResponse(ginCtx, errMsg)
+	body := recorder.Body.Bytes()
+	if got := gjson.GetBytes(body, "error.message").String(); got != "Improperly formed request." {
+		t.Fatalf("message = %q", got)
+	}
+	if got := gjson.GetBytes(body, "error.detail").String(); got != "the request first fails once turns 1-2 are included, the first being the assistant turn (1 probe(s))" {
+		t.Fatalf("detail = %q", got)
+	}
+
+	cfg.RequestDiagnosis.ExposeDetail = false
+	_, errMsg = h.ExecuteWithAuthManager(context.Background(), "claude", "diag-model", request, "")
+	recorder = httptest.NewRecorder()
+	ginCtx, _ = gin.CreateTestContext(recorder)
+	h.WriteErrorResponse(ginCtx, errMsg)
+	if gjson.GetBytes(recorder.Body.Bytes(), "error.detail").Exists() {
+		t.Fatalf("detail exposed while disabled: %s", recorder.Body.Bytes())
+	}
+}
+
+func TestRequestRepairRetriesWithStrippedToolCalls(t *testing.T) {
+	probes := 0
+	cfg := &config.SDKConfig{RequestDiagnosis: config.RequestDiagnosisConfig{Enabled: true, Repair: true}}
+	h := NewBaseAPIHandlers(cfg, nil,
+		WithExecutor(rejectingExecutor{staticExecutor: staticExecutor{payload: []byte(`{"ok":true}`)}, probes: &probes}),
+		WithModelRegistry(staticModels{"diag-model": {"claude"}}))
+	request := []byte(`{"model":"diag-model","messages":[
+		{"role":"user","content":"a"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{"file":"poisoned"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]},
+		{"role":"assistant","content":"done"},
+		{"role":"user","content":"b"}]}`)
+
+	out, errMsg := h.ExecuteWithAuthManager(context.Background(), "claude", "diag-model", request, "")
+	if errMsg != nil {
+		t.Fatalf("expected the repaired retry to succeed, got %+v", errMsg)
+	}
+	if !gjson.GetBytes(out, "ok").Bool() || probes != 2 {
+		t.Fatalf("out = %s, probes = %d", out, probes)
+	}
+
+	probes = 0
+	cfg.RequestDiagnosis.Repair = false
+	if _, errMsg = h.ExecuteWithAuthManager(context.Background(), "claude", "diag-model", request, ""); errMsg == nil || probes != 1 {
+		t.Fatalf("repair disabled: err = %+v, probes = %d", errMsg, probes)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	// limitTruncatedMetadataKey records how many messages request limits dropped.
+	limitTruncatedMetadataKey = "limit_messages_truncated"
+	// toolResultsChunkedMetadataKey records how many tool results were split into parts.
+	toolResultsChunkedMetadataKey = "tool_results_chunked"
+)
+
+// enforceRequestLimits checks a prepared request for modelName against the
+// configured size limits. Oversized tool results are split into parts first.
+// Depending on the action, oversized conversations lose their oldest turns or
+// the request is rejected with a 413 in the client's error format.
+func (h *BaseAPIHandler) enforceRequestLimits(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, map[string]any, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil, nil
+	}
+	limits := h.Cfg.RequestLimits
+	if limits == (config.RequestLimitsConfig{}) {
+		return rawJSON, nil, nil
+	}
+	var meta map[string]any
+	if chunked, n := util.ChunkToolResults(handlerType, rawJSON, limits.MaxToolResultBytes, h.servedUntranslated(handlerType, modelName)); n > 0 {
+		logging.Entry(ctx).Debugf("request limits: split %d oversized tool result(s) into parts", n)
+		rawJSON, meta = chunked, map[string]any{toolResultsChunkedMetadataKey: n}
+	}
+	if n := countTools(handlerType, rawJSON); limits.MaxTools > 0 && n > limits.MaxTools {
+		return rawJSON, nil, requestTooLarge(handlerType, fmt.Sprintf("request declares %d tools, the limit is %d", n, limits.MaxTools))
+	}
+	_, messages := util.ConversationItems(handlerType, rawJSON)
+	if limits.MaxMessageBytes > 0 {
+		for i, message := range messages {
+			if len(message.Raw) > limits.MaxMessageBytes {
+				return rawJSON, nil, requestTooLarge(handlerType, fmt.Sprintf("message %d is %d bytes, the limit is %d", i, len(message.Raw), limits.MaxMessageBytes))
+			}
+		}
+	}
+
+	problem := func(payload []byte) string {
+		if _, items := util.ConversationItems(handlerType, payload); limits.MaxMessages > 0 && len(items) > limits.MaxMessages {
+			return fmt.Sprintf("request has %d messages, the limit is %d", len(items), limits.MaxMessages)
+		}
+		if limits.MaxTotalBytes > 0 && len(payload) > limits.MaxTotalBytes {
+			return fmt.Sprintf("request is %d bytes, the limit is %d", len(payload), limits.MaxTotalBytes)
+		}
+		return ""
+	}
+	reason := problem(rawJSON)
+	if reason == "" {
+		return rawJSON, meta, nil
+	}
+	if !strings.EqualFold(strings.TrimSpace(limits.Action), "truncate") {
+		return rawJSON, nil, requestTooLarge(handlerType, reason)
+	}
+	// Keep as many recent turns as fit; a request whose last turn alone is
+	// too large is rejected.
+	for keep := util.CountUserTurns(handlerType, rawJSON) - 1; keep > 0; keep-- {
+		out, removed := util.TrimHistoryTurns(handlerType, rawJSON, keep)
+		if removed > 0 && problem(out) == "" {
+			logging.Entry(ctx).Infof("request limits: dropped %d oldest message(s) (%s)", removed, reason)
+			return out, mergeMetadata(meta, map[string]any{limitTruncatedMetadataKey: removed}), nil
+		}
+	}
+	return rawJSON, nil, requestTooLarge(handlerType, reason)
+}
+
+// countTools returns the number of tool declarations in a request.
+func countTools(handlerType string, rawJSON []byte) int {
+	tools := gjson.GetBytes(rawJSON, "tools")
+	if handlerType == "gemini-cli" {
+		tools = gjson.GetBytes(rawJSON, "request.tools")
+	}
+	if !strings.HasPrefix(handlerType, "gemini") {
+		return len(tools.Array())
+	}
+	n := 0
+	for _, tool := range tools.Array() {
+		n += len(tool.Get("functionDeclarations").Array())
+	}
+	return n
+}
+
+// requestTooLarge builds a 413 whose body follows the error schema of the
+// client's API.
+func requestTooLarge(handlerType, reason string) *interfaces.ErrorMessage {
+	message := "request too large: " + reason
+	var body any
+	switch handlerType {
+	case "claude":
+		body = map[string]any{"type": "error", "error": map[string]any{"type": "request_too_large", "message": message}}
+	case "gemini", "gemini-cli":
+		body = map[string]any{"error": map[string]any{"code": http.StatusRequestEntityTooLarge, "message": message, "status": "INVALID_ARGUMENT"}}
+	default:
+		body = map[string]any{"error": map[string]any{"message": message, "type": "invalid_request_error", "code": "request_too_large"}}
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusRequestEntityTooLarge, Error: fmt.Errorf("%s", message)}
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusRequestEntityTooLarge, Error: fmt.Errorf("%s", raw)}
+}
+
+// servedUntranslated reports whether every backend of model takes requests in
+// the handler's schema, so the request reaches them without translation.
+func (h *BaseAPIHandler) servedUntranslated(handlerType, model string) bool {
+	providers, _, errMsg := h.getRequestDetails(model)
+	if errMsg != nil || len(providers) == 0 {
+		return false
+	}
+	for _, provider := range providers {
+		target := providerRequestFormat(provider)
+		if target == "codex" {
+			target = "openai-response"
+		}
+		if target != handlerType {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestEnforceRequestLimits(t *testing.T) {
+	request := []byte(`{"messages":[{"role":"user","content":"one"},{"role":"assistant","content":"a"},{"role":"user","content":"two"},{"role":"assistant","content":"b"},{"role":"user","content":"three"}],"tools":[{"name":"a"},{"name":"b"}]}`)
+
+	reject := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestLimits: sdkconfig.RequestLimitsConfig{MaxMessages: 3}}, nil)
+	_, _, errMsg := reject.enforceRequestLimits(context.Background(), "claude", "m", request)
+	if errMsg == nil || errMsg.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("errMsg = %+v", errMsg)
+	}
+	if body := errMsg.Error.Error(); gjson.Get(body, "type").String() != "error" || gjson.Get(body, "error.type").String() != "request_too_large" {
+		t.Fatalf("claude error body = %s", body)
+	}
+
+	truncate := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestLimits: sdkconfig.RequestLimitsConfig{MaxMessages: 3, Action: "truncate"}}, nil)
+	out, meta, errMsg := truncate.enforceRequestLimits(context.Background(), "claude", "m", request)
+	if errMsg != nil || meta[limitTruncatedMetadataKey] != 2 || gjson.GetBytes(out, "messages.0.content").String() != "two" {
+		t.Fatalf("truncate: err=%v meta=%v out=%s", errMsg, meta, out)
+	}
+
+	chunk := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestLimits: sdkconfig.RequestLimitsConfig{MaxToolResultBytes: 256}}, nil,
+		WithModelRegistry(staticModels{"compat-model": {"openrouter"}, "claude-model": {"claude"}}))
+	big := []byte(`{"messages":[{"role":"tool","tool_call_id":"call_1","content":"` + strings.Repeat("y", 1000) + `"}]}`)
+	out, meta, errMsg = chunk.enforceRequestLimits(context.Background(), "openai", "compat-model", big)
+	if errMsg != nil || meta[toolResultsChunkedMetadataKey] != 1 || !gjson.GetBytes(out, "messages.0.content").IsArray() {
+		t.Fatalf("chunk: err=%v meta=%v out=%s", errMsg, meta, out)
+	}
+	// A translated request keeps its string tool results.
+	if out, meta, errMsg = chunk.enforceRequestLimits(context.Background(), "openai", "claude-model", big); errMsg != nil || meta != nil || string(out) != string(big) {
+		t.Fatalf("chunk for translated backend: err=%v meta=%v", errMsg, meta)
+	}
+
+	tools := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestLimits: sdkconfig.RequestLimitsConfig{MaxTools: 1, Action: "truncate"}}, nil)
+	if _, _, errMsg = tools.enforceRequestLimits(context.Background(), "openai", "m", request); errMsg == nil || gjson.Get(errMsg.Error.Error(), "error.code").String() != "request_too_large" {
+		t.Fatalf("tool limit: %+v", errMsg)
+	}
+}
+
+func TestChunkedToolResultsSurviveTranslation(t *testing.T) {
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %03d of the build output", i))
+	}
+	output := strings.Join(lines, "\n")
+	sources := map[string]string{
+		"claude": `{"model":"m","messages":[{"role":"user","content":"build it"},` +
+			`{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"build","input":{}}]},` +
+			`{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":%q}]}]}`,
+		"openai": `{"model":"m","messages":[{"role":"user","content":"build it"},` +
+			`{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"build","arguments":"{}"}}]},` +
+			`{"role":"tool","tool_call_id":"call_1","content":%q}]}`,
+		"openai-response": `{"model":"m","input":[{"role":"user","content":"build it"},` +
+			`{"type":"function_call","call_id":"call_1","name":"build","arguments":"{}"},` +
+			`{"type":"function_call_output","call_id":"call_1","output":%q}]}`,
+	}
+	native := map[string]string{"claude": "claude", "openai": "openrouter", "openai-response": "codex"}
+
+	for source, template := range sources {
+		for _, provider := range []string{"claude", "openrouter", "codex", "gemini"} {
+			h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestLimits: sdkconfig.RequestLimitsConfig{MaxToolResultBytes: 1024}}, nil,
+				WithModelRegistry(staticModels{"m": {provider}}))
+			request := []byte(fmt.Sprintf(template, output))
+			out, meta, errMsg := h.enforceRequestLimits(context.Background(), source, "m", request)
+			if errMsg != nil {
+				t.Fatalf("%s -> %s: %v", source, provider, errMsg)
+			}
+			if chunked := meta[toolResultsChunkedMetadataKey] != nil; chunked != (native[source] == provider) {
+				t.Fatalf("%s -> %s: chunked = %v", source, provider, chunked)
+			}
+			target := providerRequestFormat(provider)
+			translated := sdktranslator.TranslateRequest(sdktranslator.FromString(source), sdktranslator.FromString(target), "m", out, false)
+			text := string(translated)
+			for _, line := range []string{lines[0], lines[99]} {
+				if !strings.Contains(text, line) {
+					t.Fatalf("%s -> %s: tool output lost %q: %s", source, target, line, text)
+				}
+			}
+			if strings.Contains(text, `\"type\":`) {
+				t.Fatalf("%s -> %s: parts sent as text: %s", source, target, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// roleAlternationMetadataKey stores the turns merged or inserted to make the
+// history alternate.
+const roleAlternationMetadataKey = "role_alternation_fixes"
+
+// applyRoleAlternation merges adjacent same-role turns, and at the strict level
+// starts the history with a user turn, for upstreams that require strictly
+// alternating history. It runs after tool pair repair, which may add turns.
+func (h *BaseAPIHandler) applyRoleAlternation(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	level := strings.ToLower(strings.TrimSpace(h.Cfg.RoleAlternation))
+	out, fixes := util.EnforceRoleAlternation(handlerType, rawJSON, level)
+	if len(fixes) == 0 {
+		return rawJSON, nil
+	}
+	entry := logging.Entry(ctx)
+	for _, fix := range fixes {
+		entry.Debugf("role alternation: %s %s turn (message %d)", fix.Kind, fix.Role, fix.MessageIndex)
+	}
+	return out, map[string]any{roleAlternationMetadataKey: fixes}
+}
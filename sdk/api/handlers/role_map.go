@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// roleMappingsMetadataKey stores applied role rewrites in execution metadata.
+const roleMappingsMetadataKey = "role_mappings"
+
+// applyRoleMap rewrites non-standard message roles (named participants,
+// legacy roles such as "function") using the configured role map so they are
+// not silently dropped or treated as user turns by the translators.
+func (h *BaseAPIHandler) applyRoleMap(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	out, mappings, unmapped := util.NormalizeRoles(handlerType, rawJSON, h.Cfg.RoleMap)
+	entry := logging.Entry(ctx)
+	for _, role := range unmapped {
+		entry.Warnf("role map: no mapping for unsupported role %q; configure role-map to handle it", role)
+	}
+	if len(mappings) == 0 {
+		return rawJSON, nil
+	}
+	for _, mapping := range mappings {
+		entry.Warnf("role map: mapped role %q to %q (message %d)", mapping.From, mapping.To, mapping.MessageIndex)
+	}
+	return out, map[string]any{roleMappingsMetadataKey: mappings}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// samplingExtensionsUnknownKey records in execution metadata the extension
+// parameters the client schema could not carry.
+const samplingExtensionsUnknownKey = "sampling_extensions_unknown"
+
+// applySamplingExtensions moves provider-specific sampling parameters sent in
+// extra_body, such as top_k or repetition_penalty, to the request fields the
+// translators read.
+func (h *BaseAPIHandler) applySamplingExtensions(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	out, moved, unknown := util.HoistSamplingExtensions(handlerType, rawJSON)
+	if len(moved) == 0 && len(unknown) == 0 {
+		return rawJSON, nil
+	}
+	if len(moved) > 0 {
+		logging.Entry(ctx).Debugf("applied extension sampling parameters: %s", strings.Join(moved, ", "))
+	}
+	if len(unknown) == 0 {
+		return out, nil
+	}
+	logging.Entry(ctx).Debugf("dropped unsupported extension parameters: %s", strings.Join(unknown, ", "))
+	return out, map[string]any{samplingExtensionsUnknownKey: unknown}
+}
+
+// checkSamplingExtensions fails with 422 in strict translation mode when the
+// request carried extension parameters that were dropped.
+func (h *BaseAPIHandler) checkSamplingExtensions(ctx context.Context, meta map[string]any) *interfaces.ErrorMessage {
+	unknown, _ := meta[samplingExtensionsUnknownKey].([]string)
+	if len(unknown) == 0 || !h.strictTranslation(ctx) {
+		return nil
+	}
+	message := fmt.Sprintf("strict translation: unsupported extension parameters %s", strings.Join(unknown, ", "))
+	body, err := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message":    message,
+			"type":       "invalid_request_error",
+			"code":       "unsupported_parameter",
+			"parameters": unknown,
+		},
+	})
+	if err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusUnprocessableEntity, Error: fmt.Errorf("%s", message)}
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusUnprocessableEntity, Error: fmt.Errorf("%s", body)}
+}
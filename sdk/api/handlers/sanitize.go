@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sanitize"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// sanitizeMetadataKey records how many request text fields the outbound
+// sanitization pipeline changed.
+const sanitizeMetadataKey = "sanitized_fields"
+
+var (
+	sanitizeMu        sync.Mutex
+	sanitizePipelines = map[string]*sanitize.Pipeline{}
+)
+
+// sanitizePipelineFor returns the compiled pipeline for a filter list, building
+// it once per distinct configuration.
+func sanitizePipelineFor(filters []config.SanitizeFilter) *sanitize.Pipeline {
+	if len(filters) == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%+v", filters)
+	sanitizeMu.Lock()
+	defer sanitizeMu.Unlock()
+	if pipeline, ok := sanitizePipelines[key]; ok {
+		return pipeline
+	}
+	pipeline, errs := sanitize.New(filters)
+	for _, err := range errs {
+		log.Warnf("sanitize: ignoring filter: %v", err)
+	}
+	sanitizePipelines[key] = pipeline
+	return pipeline
+}
+
+// applyOutboundSanitize runs the outbound sanitization pipeline, or the one of
+// the model's profile, on request text.
+func (h *BaseAPIHandler) applyOutboundSanitize(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	filters := h.Cfg.Sanitize.Outbound
+	if profile := h.modelProfile(payloadModel(ctx, rawJSON)); profile != nil && profile.Sanitize != nil {
+		filters = profile.Sanitize
+	}
+	pipeline := sanitizePipelineFor(filters)
+	if pipeline.Empty() {
+		return rawJSON, nil
+	}
+	out, changed := pipeline.Request(handlerType, rawJSON)
+	if changed == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("sanitize: rewrote %d request text field(s) with %v", changed, pipeline.Names())
+	return out, map[string]any{sanitizeMetadataKey: changed}
+}
+
+// sanitizeResponse runs the inbound sanitization pipeline on assistant text in a
+// response body or stream chunk.
+func (h *BaseAPIHandler) sanitizeResponse(handlerType string, payload []byte, stream bool) []byte {
+	return sanitizeResponseWith(h.inboundPipeline(), handlerType, payload, stream)
+}
+
+// inboundPipeline returns the configured inbound sanitization pipeline, or nil.
+func (h *BaseAPIHandler) inboundPipeline() *sanitize.Pipeline {
+	if h == nil || h.Cfg == nil {
+		return nil
+	}
+	return sanitizePipelineFor(h.Cfg.Sanitize.Inbound)
+}
+
+// sanitizeResponseWith runs pipeline on assistant text in a response body or
+// stream chunk.
+func sanitizeResponseWith(pipeline *sanitize.Pipeline, handlerType string, payload []byte, stream bool) []byte {
+	if pipeline.Empty() {
+		return payload
+	}
+	out, _ := pipeline.Response(handlerType, payload, stream)
+	return out
+}
+
+// newEchoStripper returns the prompt echo stripper for the response to request,
+// or nil when echo detection is disabled for it.
+func (h *BaseAPIHandler) newEchoStripper(ctx context.Context, handlerType string, request []byte) *sanitize.EchoStripper {
+	if h == nil || h.Cfg == nil || !h.Cfg.EchoDedup.Enabled || !h.featureEnabled(ctx, featureflags.EchoDedup) {
+		return nil
+	}
+	return sanitize.NewEchoStripper(h.Cfg.EchoDedup, handlerType, request)
+}
+
+func recordEchoStrip(ctx context.Context, handlerType string) {
+	usage.RecordEchoStrip(handlerType)
+	logging.Entry(ctx).Debugf("echo dedup: stripped prompt echo from %s response", handlerType)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultShadowMaxConcurrent = 4
+	defaultShadowTimeout       = 300 * time.Second
+)
+
+// shadowInFlight counts the running shadow requests.
+var shadowInFlight atomic.Int64
+
+// shadowRandom decides whether a request is shadowed. Tests replace it.
+var shadowRandom = func() float64 { return rand.Float64() * 100 }
+
+// shadowTarget returns the model a copy of the request should be sent to.
+func (h *BaseAPIHandler) shadowTarget(modelName string) (string, bool) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.Shadow.Rules) == 0 {
+		return "", false
+	}
+	base := strings.TrimSpace(thinking.ParseSuffix(modelName).ModelName)
+	for _, rule := range h.Cfg.Shadow.Rules {
+		model := strings.TrimSpace(rule.Model)
+		if model != "*" && !strings.EqualFold(model, base) {
+			continue
+		}
+		target := strings.TrimSpace(rule.ShadowModel)
+		if target == "" || rule.Percent <= 0 || shadowRandom() >= rule.Percent {
+			return "", false
+		}
+		return target, true
+	}
+	return "", false
+}
+
+// startShadow sends a copy of a prepared request to the shadow model of the
+// first matching rule, in the background and without streaming. The copy is
+// recorded like any request, with its audit record pointing at the primary
+// request; its outcome never reaches the client.
+func (h *BaseAPIHandler) startShadow(ctx context.Context, handlerType, modelName string, rawJSON []byte, prepMeta map[string]any) {
+	target, ok := h.shadowTarget(modelName)
+	if !ok {
+		return
+	}
+	limit := int64(h.Cfg.Shadow.MaxConcurrent)
+	if limit <= 0 {
+		limit = defaultShadowMaxConcurrent
+	}
+	if shadowInFlight.Add(1) > limit {
+		shadowInFlight.Add(-1)
+		logging.Entry(ctx).Debug("shadow: skipped, too many shadow requests in flight")
+		return
+	}
+	timeout := defaultShadowTimeout
+	if h.Cfg.Shadow.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.Cfg.Shadow.TimeoutSeconds) * time.Second
+	}
+	primaryID := logging.GetRequestID(ctx)
+	shadowID := logging.GenerateRequestID()
+	// The inspection reads the client's gin context, which must not be used
+	// once the primary request has finished, so it is started here.
+	insp := h.startInspection(ctx, handlerType, target, false)
+	payload := cloneBytes(rawJSON)
+	if updated, err := sjson.DeleteBytes(payload, "stream"); err == nil {
+		payload = updated
+	}
+	meta := mergeMetadata(requestExecutionMetadata(nil), prepMeta)
+
+	go func() {
+		defer shadowInFlight.Add(-1)
+		runCtx, cancel := context.WithTimeout(logging.WithRequestID(context.Background(), shadowID), timeout)
+		defer cancel()
+		insp.shadowOf(runCtx, shadowID, primaryID)
+		start := time.Now()
+		out, errMsg := h.executeShadow(runCtx, handlerType, target, payload, meta, insp)
+		insp.finish(out, errMsg)
+		entry := logging.Entry(runCtx).WithFields(log.Fields{
+			"shadow_of":  primaryID,
+			"model":      target,
+			"latency_ms": time.Since(start).Milliseconds(),
+		})
+		if errMsg != nil {
+			entry.Infof("shadow: request failed with status %d: %v", errMsg.StatusCode, errMsg.Error)
+			return
+		}
+		entry.Info("shadow: request completed")
+	}()
+}
+
+func (h *BaseAPIHandler) executeShadow(ctx context.Context, handlerType, modelName string, payload []byte, meta map[string]any, insp *inspection) ([]byte, *interfaces.ErrorMessage) {
+	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	insp.prepared(normalizedModel, providers, payload, nil)
+	resp, err := h.exec().Execute(ctx, providers, coreexecutor.Request{
+		Model:   normalizedModel,
+		Payload: payload,
+	}, coreexecutor.Options{
+		OriginalRequest: cloneBytes(payload),
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        meta,
+	})
+	if err != nil {
+		status := statusFromError(err)
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		return nil, &interfaces.ErrorMessage{StatusCode: status, Error: err}
+	}
+	return resp.Payload, nil
+}
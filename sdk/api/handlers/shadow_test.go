@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// shadowExecutor answers every request and reports the shadow copies it saw.
+type shadowExecutor struct {
+	staticExecutor
+	shadowed chan coreexecutor.Request
+}
+
+func (e shadowExecutor) Execute(ctx context.Context, providers []string, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	if req.Model == "shadow-model" {
+		e.shadowed <- req
+	}
+	return e.staticExecutor.Execute(ctx, providers, req, opts)
+}
+
+func TestShadowSendsCopyWithoutStreaming(t *testing.T) {
+	previous := shadowRandom
+	shadowRandom = func() float64 { return 10 }
+	defer func() { shadowRandom = previous }()
+
+	exec := shadowExecutor{
+		staticExecutor: staticExecutor{payload: []byte(`{"id":"msg_1","type":"message","content":[{"type":"text","text":"hi"}]}`)},
+		shadowed:       make(chan coreexecutor.Request, 2),
+	}
+	cfg := &config.SDKConfig{Shadow: config.ShadowConfig{Rules: []config.ShadowRule{
+		{Model: "other-model", ShadowModel: "unused", Percent: 100},
+		{Model: "*", ShadowModel: "shadow-model", Percent: 50},
+	}}}
+	h := NewBaseAPIHandlers(cfg, nil, WithExecutor(exec),
+		WithModelRegistry(staticModels{"primary-model": {"claude"}, "shadow-model": {"gemini"}}))
+
+	request := []byte(`{"model":"primary-model","stream":true,"messages":[{"role":"user","content":"a"}]}`)
+	out, errMsg := h.ExecuteWithAuthManager(context.Background(), "claude", "primary-model", request, "")
+	if errMsg != nil || gjson.GetBytes(out, "id").String() != "msg_1" {
+		t.Fatalf("primary response changed: %s (%v)", out, errMsg)
+	}
+	select {
+	case req := <-exec.shadowed:
+		if gjson.GetBytes(req.Payload, "stream").Exists() {
+			t.Fatalf("shadow copy still streams: %s", req.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shadow request was not sent")
+	}
+
+	shadowRandom = func() float64 { return 60 }
+	if _, errMsg = h.ExecuteWithAuthManager(context.Background(), "claude", "primary-model", request, ""); errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	select {
+	case <-exec.shadowed:
+		t.Fatal("request outside the shadow percentage was shadowed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sanitize"
+	"golang.org/x/net/context"
+)
+
+// shellBlocksMetadataKey stores the number of shortened shell and hook blocks.
+const shellBlocksMetadataKey = "shell_blocks_shortened"
+
+// applyShellOutputPolicy shortens oversized shell output and hook result
+// blocks using the policy configured for the calling API key. It runs early so
+// later passes and upstream size limits see the reduced payload.
+func (h *BaseAPIHandler) applyShellOutputPolicy(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	apiKey := ""
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		apiKey = ginCtx.GetString("apiKey")
+	}
+	policy := sanitize.NewShellBlockPolicy(h.Cfg.ShellOutput.PolicyForKey(apiKey))
+	if policy == nil {
+		return rawJSON, nil
+	}
+	out, count := policy.Apply(handlerType, rawJSON)
+	if count == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("shell output: shortened %d block(s) (%d -> %d bytes)", count, len(rawJSON), len(out))
+	return out, map[string]any{shellBlocksMetadataKey: count}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 )
 
 type StreamForwardOptions struct {
@@ -25,7 +26,8 @@ type StreamForwardOptions struct {
 	WriteDone func()
 
 	// WriteKeepAlive optionally writes a keep-alive heartbeat. It should not flush.
-	// When nil, a standard SSE comment heartbeat is used.
+	// When nil, a standard SSE comment heartbeat is used. Heartbeats are only sent
+	// after the upstream has been silent for a full keep-alive interval.
 	WriteKeepAlive func()
 }
 
@@ -53,18 +55,38 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 	if opts.KeepAliveInterval != nil {
 		keepAliveInterval = *opts.KeepAliveInterval
 	}
-	var keepAlive *time.Ticker
+	// The keep-alive timer measures upstream silence: it is re-armed whenever a
+	// real chunk is forwarded, so heartbeats stop as soon as data resumes.
+	var keepAlive *time.Timer
 	var keepAliveC <-chan time.Time
 	if keepAliveInterval > 0 {
-		keepAlive = time.NewTicker(keepAliveInterval)
+		keepAlive = time.NewTimer(keepAliveInterval)
 		defer keepAlive.Stop()
 		keepAliveC = keepAlive.C
 	}
+	resetKeepAlive := func() {
+		if keepAlive == nil {
+			return
+		}
+		if !keepAlive.Stop() {
+			select {
+			case <-keepAlive.C:
+			default:
+			}
+		}
+		keepAlive.Reset(keepAliveInterval)
+	}
 
 	var terminalErr *interfaces.ErrorMessage
 	for {
 		select {
 		case <-c.Request.Context().Done():
+			completed := h.drainAfterDisconnect(data, errs)
+			usage.RecordStreamAbort(completed)
+			if completed {
+				cancel(nil)
+				return
+			}
 			cancel(c.Request.Context().Err())
 			return
 		case chunk, ok := <-data:
@@ -96,6 +118,7 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 			}
 			writeChunk(chunk)
 			flusher.Flush()
+			resetKeepAlive()
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
@@ -116,6 +139,39 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		case <-keepAliveC:
 			writeKeepAlive()
 			flusher.Flush()
+			keepAlive.Reset(keepAliveInterval)
+		}
+	}
+}
+
+// drainAfterDisconnect consumes the remaining upstream stream for up to the
+// configured cancel grace period once the client has gone away. Draining lets
+// the executor and translators run to completion (closing partial tool calls and
+// reporting usage) instead of being cut off mid-event. It reports whether the
+// upstream finished within the grace period.
+func (h *BaseAPIHandler) drainAfterDisconnect(data <-chan []byte, errs <-chan *interfaces.ErrorMessage) bool {
+	grace := StreamingCancelGrace(h.Cfg)
+	if grace <= 0 {
+		return false
+	}
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-data:
+			if !ok {
+				return true
+			}
+		case errMsg, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if errMsg != nil {
+				return false
+			}
+		case <-timer.C:
+			return false
 		}
 	}
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestForwardStreamKeepAliveOnlyDuringSilence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", "/v1/messages", nil)
+
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage)
+	go func() {
+		// Steady traffic faster than the keep-alive interval, then a long pause.
+		for i := 0; i < 6; i++ {
+			data <- []byte("chunk")
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(120 * time.Millisecond)
+		data <- []byte("chunk")
+		close(data)
+	}()
+
+	var events []string
+	interval := 40 * time.Millisecond
+	h := &BaseAPIHandler{}
+	h.ForwardStream(c, c.Writer, func(error) {}, data, errs, StreamForwardOptions{
+		KeepAliveInterval: &interval,
+		WriteChunk:        func([]byte) { events = append(events, "chunk") },
+		WriteKeepAlive:    func() { events = append(events, "ping") },
+	})
+
+	firstPing := -1
+	for i, event := range events {
+		if event == "ping" {
+			firstPing = i
+			break
+		}
+	}
+	if firstPing < 6 {
+		t.Fatalf("keep-alive emitted while data was flowing: %v", events)
+	}
+	if events[len(events)-1] != "chunk" {
+		t.Fatalf("expected stream to end with resumed data, got %v", events)
+	}
+}
+
+func TestForwardStreamDrainsWithinCancelGrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	reqCtx, disconnect := context.WithCancel(context.Background())
+	c.Request = httptest.NewRequest("POST", "/v1/messages", nil).WithContext(reqCtx)
+
+	data := make(chan []byte)
+	errs := make(chan *interfaces.ErrorMessage)
+	go func() {
+		disconnect()
+		time.Sleep(20 * time.Millisecond)
+		data <- []byte("tail")
+		close(data)
+	}()
+
+	before := usage.StreamAborts()
+	var cancelErr error
+	cancelled := false
+	h := &BaseAPIHandler{Cfg: &sdkconfig.SDKConfig{Streaming: sdkconfig.StreamingConfig{CancelGraceSeconds: 2}}}
+	h.ForwardStream(c, c.Writer, func(err error) { cancelled, cancelErr = true, err }, data, errs, StreamForwardOptions{})
+
+	if !cancelled || cancelErr != nil {
+		t.Fatalf("expected clean completion after drain, cancelled=%v err=%v", cancelled, cancelErr)
+	}
+	after := usage.StreamAborts()
+	if after.CompletedInGrace != before.CompletedInGrace+1 || after.UpstreamCancelled != before.UpstreamCancelled {
+		t.Fatalf("unexpected abort counters: before=%+v after=%+v", before, after)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"golang.org/x/net/context"
+)
+
+// streamResumedAction records in the inspector where a resumed stream was stitched.
+const streamResumedAction = "stream_resumed"
+
+// streamSeam describes where a continuation was joined to a stream.
+type streamSeam struct {
+	Attempt    int    `json:"attempt"`
+	TextOffset int    `json:"text_offset"`
+	Cause      string `json:"cause"`
+}
+
+// newStreamResume returns the tracker used to continue a broken stream, or nil
+// when resuming is disabled or the format cannot be resumed.
+func (h *BaseAPIHandler) newStreamResume(handlerType string) *util.StreamResume {
+	if h == nil || h.Cfg == nil || h.Cfg.Streaming.ResumeAttempts <= 0 {
+		return nil
+	}
+	return util.NewStreamResume(handlerType)
+}
+
+// resumeStream reissues a stream that broke off mid-message with the text
+// received so far, so the model continues where the client's copy stops. It
+// returns the seam, or false when the stream cannot be resumed or the attempts
+// are used up.
+func (h *BaseAPIHandler) resumeStream(ctx context.Context, resume *util.StreamResume, providers []string, req coreexecutor.Request, opts coreexecutor.Options, cause string) (<-chan coreexecutor.StreamChunk, streamSeam, bool) {
+	if resume == nil || resume.Resumes() >= h.Cfg.Streaming.ResumeAttempts {
+		return nil, streamSeam{}, false
+	}
+	offset := resume.TextLength()
+	payload, ok := resume.Continue(req.Payload, defaultPrefillHint)
+	if !ok {
+		return nil, streamSeam{}, false
+	}
+	req.Payload = payload
+	opts.OriginalRequest = cloneBytes(payload)
+	chunks, err := h.exec().ExecuteStream(ctx, providers, req, opts)
+	if err != nil {
+		logging.Entry(ctx).Warnf("stream resume: continuation failed: %v", err)
+		return nil, streamSeam{}, false
+	}
+	logging.Entry(ctx).Warnf("stream resume: upstream stream broke off (%s) after %d bytes of text, continuing", cause, offset)
+	return chunks, streamSeam{Attempt: resume.Resumes(), TextOffset: offset, Cause: cause}, true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// brokenStreamExecutor breaks its first stream off mid-message and records the
+// continuation request.
+type brokenStreamExecutor struct {
+	staticExecutor
+	continued chan []byte
+}
+
+func (e brokenStreamExecutor) ExecuteStream(_ context.Context, _ []string, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, 3)
+	if len(gjson.GetBytes(req.Payload, "messages").Array()) == 1 {
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello, wor"}}]}`)}
+		ch <- coreexecutor.StreamChunk{Err: errors.New("connection reset")}
+	} else {
+		e.continued <- req.Payload
+		ch <- coreexecutor.StreamChunk{Payload: []byte(`{"id":"c2","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"ld!"},"finish_reason":"stop"}]}`)}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestExecuteStreamWithAuthManager_ResumesBrokenStream(t *testing.T) {
+	exec := brokenStreamExecutor{continued: make(chan []byte, 1)}
+	cfg := &config.SDKConfig{Streaming: config.StreamingConfig{ResumeAttempts: 1}}
+	h := NewBaseAPIHandlers(cfg, nil, WithExecutor(exec), WithModelRegistry(staticModels{"m": {"openai"}}))
+
+	request := []byte(`{"model":"m","stream":true,"messages":[{"role":"user","content":"greet"}]}`)
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(context.Background(), "openai", "m", request, "")
+	var text strings.Builder
+	for chunk := range dataChan {
+		if id := gjson.GetBytes(chunk, "id").String(); id != "c1" {
+			t.Fatalf("continuation chunk kept its own id %q", id)
+		}
+		text.WriteString(gjson.GetBytes(chunk, "choices.0.delta.content").String())
+	}
+	for errMsg := range errChan {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	if text.String() != "Hello, world!" {
+		t.Fatalf("stitched text = %q", text.String())
+	}
+	continued := <-exec.continued
+	if gjson.GetBytes(continued, "messages.1.content").String() != "Hello, wor" || gjson.GetBytes(continued, "messages.2.role").String() != "user" {
+		t.Fatalf("unexpected continuation request: %s", continued)
+	}
+}
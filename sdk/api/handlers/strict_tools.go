@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/toolschema"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// strictToolSchemas returns the declared argument schema of every tool the
+// client marked with "strict": true, keyed by tool name.
+func strictToolSchemas(handlerType string, rawJSON []byte) map[string]string {
+	var schemas map[string]string
+	add := func(name string, schema gjson.Result) {
+		if name == "" {
+			return
+		}
+		if schemas == nil {
+			schemas = make(map[string]string)
+		}
+		raw := schema.Raw
+		if !schema.Exists() {
+			raw = `{"type":"object"}`
+		}
+		schemas[name] = raw
+	}
+	gjson.GetBytes(rawJSON, "tools").ForEach(func(_, tool gjson.Result) bool {
+		switch handlerType {
+		case "openai":
+			if tool.Get("function.strict").Bool() {
+				add(tool.Get("function.name").String(), tool.Get("function.parameters"))
+			}
+		case "openai-response":
+			if tool.Get("strict").Bool() {
+				add(tool.Get("name").String(), tool.Get("parameters"))
+			}
+		case "claude":
+			if tool.Get("strict").Bool() {
+				add(tool.Get("name").String(), tool.Get("input_schema"))
+			}
+		}
+		return true
+	})
+	return schemas
+}
+
+// strictToolError builds the error returned when a strict tool call cannot be repaired.
+func strictToolError(name string, problems []string) *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadGateway,
+		Error:      fmt.Errorf("tool call %q does not match its strict schema: %s", name, strings.Join(problems, "; ")),
+	}
+}
+
+// enforceStrictTools validates tool call arguments in a non-streaming response
+// against the schemas of strict tools declared in the request. Trivial issues
+// are repaired in place; anything else fails the request instead of handing
+// malformed arguments to the client.
+func (h *BaseAPIHandler) enforceStrictTools(ctx context.Context, handlerType string, request, response []byte) ([]byte, *interfaces.ErrorMessage) {
+	schemas := strictToolSchemas(handlerType, request)
+	if len(schemas) == 0 {
+		return response, nil
+	}
+	type toolCall struct {
+		name     string
+		argsPath string
+		args     string
+		object   bool
+	}
+	var calls []toolCall
+	root := gjson.ParseBytes(response)
+	switch handlerType {
+	case "openai":
+		root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+			choice.Get("message.tool_calls").ForEach(func(j, call gjson.Result) bool {
+				path := "choices." + i.String() + ".message.tool_calls." + j.String() + ".function.arguments"
+				calls = append(calls, toolCall{name: call.Get("function.name").String(), argsPath: path, args: call.Get("function.arguments").String()})
+				return true
+			})
+			return true
+		})
+	case "openai-response":
+		root.Get("output").ForEach(func(i, item gjson.Result) bool {
+			if item.Get("type").String() == "function_call" {
+				calls = append(calls, toolCall{name: item.Get("name").String(), argsPath: "output." + i.String() + ".arguments", args: item.Get("arguments").String()})
+			}
+			return true
+		})
+	case "claude":
+		root.Get("content").ForEach(func(i, block gjson.Result) bool {
+			if block.Get("type").String() == "tool_use" {
+				calls = append(calls, toolCall{name: block.Get("name").String(), argsPath: "content." + i.String() + ".input", args: block.Get("input").Raw, object: true})
+			}
+			return true
+		})
+	}
+
+	out := response
+	entry := logging.Entry(ctx)
+	for _, call := range calls {
+		schema, ok := schemas[call.name]
+		if !ok {
+			continue
+		}
+		result := toolschema.Validate(schema, []byte(call.args))
+		if !result.Valid() {
+			entry.Warnf("strict tools: rejecting tool call %q: %v", call.name, result.Problems)
+			return nil, strictToolError(call.name, result.Problems)
+		}
+		if len(result.Repairs) == 0 {
+			continue
+		}
+		entry.Infof("strict tools: repaired tool call %q: %v", call.name, result.Repairs)
+		var err error
+		if call.object {
+			out, err = sjson.SetRawBytes(out, call.argsPath, result.Arguments)
+		} else {
+			out, err = sjson.SetBytes(out, call.argsPath, string(result.Arguments))
+		}
+		if err != nil {
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: err}
+		}
+	}
+	return out, nil
+}
+
+// strictToolStream accumulates streamed tool call arguments for strict tools
+// and validates each call once it is complete. Arguments already delivered
+// cannot be repaired, so any mismatch ends the stream with an error.
+type strictToolStream struct {
+	handlerType string
+	schemas     map[string]string
+	names       map[string]string
+	args        map[string]*strings.Builder
+}
+
+// newStrictToolStream returns nil when the request declares no strict tools.
+func newStrictToolStream(handlerType string, request []byte) *strictToolStream {
+	schemas := strictToolSchemas(handlerType, request)
+	if len(schemas) == 0 {
+		return nil
+	}
+	return &strictToolStream{
+		handlerType: handlerType,
+		schemas:     schemas,
+		names:       make(map[string]string),
+		args:        make(map[string]*strings.Builder),
+	}
+}
+
+// observe inspects one stream chunk and returns an error once a completed
+// strict tool call fails validation.
+func (s *strictToolStream) observe(chunk []byte) *interfaces.ErrorMessage {
+	if s == nil {
+		return nil
+	}
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(line), []byte("data:")))
+		if len(line) == 0 || !gjson.ValidBytes(line) {
+			continue
+		}
+		if errMsg := s.observeEvent(gjson.ParseBytes(line)); errMsg != nil {
+			return errMsg
+		}
+	}
+	return nil
+}
+
+func (s *strictToolStream) observeEvent(event gjson.Result) *interfaces.ErrorMessage {
+	switch s.handlerType {
+	case "openai":
+		var errMsg *interfaces.ErrorMessage
+		event.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			choiceKey := choice.Get("index").String()
+			choice.Get("delta.tool_calls").ForEach(func(_, call gjson.Result) bool {
+				key := choiceKey + "/" + call.Get("index").String()
+				s.append(key, call.Get("function.name").String(), call.Get("function.arguments").String())
+				return true
+			})
+			if choice.Get("finish_reason").String() != "" {
+				errMsg = s.completeWithPrefix(choiceKey + "/")
+			}
+			return errMsg == nil
+		})
+		return errMsg
+	case "claude":
+		key := event.Get("index").String()
+		switch event.Get("type").String() {
+		case "content_block_start":
+			if event.Get("content_block.type").String() == "tool_use" {
+				s.append(key, event.Get("content_block.name").String(), "")
+			}
+		case "content_block_delta":
+			if _, ok := s.args[key]; ok && event.Get("delta.type").String() == "input_json_delta" {
+				s.append(key, "", event.Get("delta.partial_json").String())
+			}
+		case "content_block_stop":
+			return s.complete(key)
+		}
+	case "openai-response":
+		if event.Get("type").String() == "response.output_item.done" && event.Get("item.type").String() == "function_call" {
+			return s.validate(event.Get("item.name").String(), event.Get("item.arguments").String())
+		}
+	}
+	return nil
+}
+
+func (s *strictToolStream) append(key, name, args string) {
+	builder, ok := s.args[key]
+	if !ok {
+		builder = &strings.Builder{}
+		s.args[key] = builder
+	}
+	if name != "" {
+		s.names[key] = name
+	}
+	builder.WriteString(args)
+}
+
+func (s *strictToolStream) completeWithPrefix(prefix string) *interfaces.ErrorMessage {
+	for key := range s.args {
+		if strings.HasPrefix(key, prefix) {
+			if errMsg := s.complete(key); errMsg != nil {
+				return errMsg
+			}
+		}
+	}
+	return nil
+}
+
+func (s *strictToolStream) complete(key string) *interfaces.ErrorMessage {
+	builder, ok := s.args[key]
+	if !ok {
+		return nil
+	}
+	name := s.names[key]
+	delete(s.args, key)
+	delete(s.names, key)
+	return s.validate(name, builder.String())
+}
+
+func (s *strictToolStream) validate(name, args string) *interfaces.ErrorMessage {
+	schema, ok := s.schemas[name]
+	if !ok {
+		return nil
+	}
+	result := toolschema.Validate(schema, []byte(args))
+	problems := append(result.Problems, result.Repairs...)
+	if len(problems) == 0 {
+		return nil
+	}
+	return strictToolError(name, problems)
+}
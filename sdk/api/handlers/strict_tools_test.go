@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const strictOpenAIRequest = `{"tools":[{"type":"function","function":{"name":"get_weather","strict":true,
+	"parameters":{"type":"object","additionalProperties":false,"required":["city","days"],
+	"properties":{"city":{"type":"string"},"days":{"type":"integer"}}}}}]}`
+
+func TestEnforceStrictTools_RepairsAndRejects(t *testing.T) {
+	h := &BaseAPIHandler{}
+	resp := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\",\"days\":\"2\"}"}}]}}]}`)
+	out, errMsg := h.enforceStrictTools(context.Background(), "openai", []byte(strictOpenAIRequest), resp)
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %v", errMsg.Error)
+	}
+	args := gjson.Parse(gjson.GetBytes(out, "choices.0.message.tool_calls.0.function.arguments").String())
+	if args.Get("days").Type != gjson.Number || args.Get("days").Int() != 2 {
+		t.Fatalf("arguments not repaired: %s", out)
+	}
+
+	bad := []byte(`{"choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"get_weather","arguments":"{\"days\":2}"}}]}}]}`)
+	if _, errMsg = h.enforceStrictTools(context.Background(), "openai", []byte(strictOpenAIRequest), bad); errMsg == nil {
+		t.Fatalf("expected missing required property to be rejected")
+	}
+}
+
+func TestStrictToolStream_ClaudeRejectsInvalidArguments(t *testing.T) {
+	request := []byte(`{"tools":[{"name":"lookup","strict":true,"input_schema":{"type":"object","required":["id"],"properties":{"id":{"type":"integer"}}}}]}`)
+	stream := newStrictToolStream("claude", request)
+	chunks := []string{
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"t1\",\"name\":\"lookup\",\"input\":{}}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"id\\\":\"}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"\\\"abc\\\"}\"}}\n\n",
+	}
+	for _, chunk := range chunks {
+		if errMsg := stream.observe([]byte(chunk)); errMsg != nil {
+			t.Fatalf("unexpected error before block stop: %v", errMsg.Error)
+		}
+	}
+	errMsg := stream.observe([]byte("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n"))
+	if errMsg == nil {
+		t.Fatalf("expected invalid streamed arguments to be reported")
+	}
+	if newStrictToolStream("claude", []byte(`{"tools":[{"name":"lookup"}]}`)) != nil {
+		t.Fatalf("non-strict tools must not be tracked")
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"golang.org/x/net/context"
+)
+
+// strictTranslationHeader turns strict translation on or off for one request.
+const strictTranslationHeader = "X-CLIProxy-Strict"
+
+// providerRequestFormats maps providers to the request schema their executor
+// translates to. Providers not listed speak the OpenAI chat schema.
+var providerRequestFormats = map[string]string{
+	"claude":      "claude",
+	"codex":       "codex",
+	"gemini":      "gemini",
+	"vertex":      "gemini",
+	"aistudio":    "gemini",
+	"gemini-cli":  "gemini-cli",
+	"antigravity": "antigravity",
+}
+
+func providerRequestFormat(provider string) string {
+	if format, ok := providerRequestFormats[provider]; ok {
+		return format
+	}
+	return "openai"
+}
+
+// strictTranslation reports whether lossy translations must be rejected for
+// this request. The header overrides the configured default.
+func (h *BaseAPIHandler) strictTranslation(ctx context.Context) bool {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		switch strings.ToLower(strings.TrimSpace(ginCtx.GetHeader(strictTranslationHeader))) {
+		case "true", "1", "on":
+			return true
+		case "false", "0", "off":
+			return false
+		}
+	}
+	return h != nil && h.Cfg != nil && h.Cfg.StrictTranslation
+}
+
+// checkStrictTranslation translates the request for every backend that may
+// serve it and fails with 422 when any translation drops or alters a field,
+// listing the affected fields in the error body.
+func (h *BaseAPIHandler) checkStrictTranslation(ctx context.Context, handlerType, model string, providers []string, rawJSON []byte, stream bool) *interfaces.ErrorMessage {
+	if !h.strictTranslation(ctx) {
+		return nil
+	}
+	type targetLoss struct {
+		util.TranslationLoss
+		Target string `json:"target"`
+	}
+	var losses []targetLoss
+	seen := make(map[string]bool)
+	for _, provider := range providers {
+		target := providerRequestFormat(provider)
+		if seen[target] || target == handlerType {
+			continue
+		}
+		seen[target] = true
+		translated := sdktranslator.TranslateRequest(sdktranslator.FromString(handlerType), sdktranslator.FromString(target), model, rawJSON, stream)
+		for _, loss := range util.AuditTranslation(handlerType, target, rawJSON, translated) {
+			losses = append(losses, targetLoss{TranslationLoss: loss, Target: target})
+		}
+	}
+	if len(losses) == 0 {
+		return nil
+	}
+	sort.SliceStable(losses, func(i, j int) bool { return losses[i].Target < losses[j].Target })
+	fields := make([]string, 0, len(losses))
+	for _, loss := range losses {
+		fields = append(fields, loss.Field)
+	}
+	message := fmt.Sprintf("strict translation: the upstream request would differ from yours in %s", strings.Join(fields, ", "))
+	body, err := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    "invalid_request_error",
+			"code":    "lossy_translation",
+			"losses":  losses,
+		},
+	})
+	if err != nil {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusUnprocessableEntity, Error: fmt.Errorf("%s", message)}
+	}
+	logging.Entry(ctx).Debugf("rejected lossy translation: %s", strings.Join(fields, ", "))
+	return &interfaces.ErrorMessage{StatusCode: http.StatusUnprocessableEntity, Error: fmt.Errorf("%s", body)}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+func TestCheckStrictTranslation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+	request := []byte(`{"model":"m","max_tokens":100,"top_k":5,"messages":[{"role":"user","content":"hi"}]}`)
+
+	if errMsg := handler.checkStrictTranslation(context.Background(), "claude", "m", []string{"openrouter"}, request, false); errMsg != nil {
+		t.Fatalf("strict mode is off by default, got %v", errMsg.Error)
+	}
+
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Request.Header.Set(strictTranslationHeader, "true")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	errMsg := handler.checkStrictTranslation(ctx, "claude", "m", []string{"openrouter"}, request, false)
+	if errMsg == nil || errMsg.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %+v", errMsg)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if loss := gjson.GetBytes(body, `error.losses.#(field=="top_k")`); loss.Get("change").String() != "dropped" || loss.Get("target").String() != "openai" {
+		t.Fatalf("body = %s", body)
+	}
+
+	if errMsg = handler.checkStrictTranslation(ctx, "claude", "m", []string{"claude"}, request, false); errMsg != nil {
+		t.Fatalf("native backend rejected: %v", errMsg.Error)
+	}
+}
+
+func TestSamplingExtensionsStrictMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+	request := []byte(`{"model":"m","messages":[],"extra_body":{"top_k":40,"mirostat":2}}`)
+
+	out, meta := handler.applySamplingExtensions(context.Background(), "openai", request)
+	if gjson.GetBytes(out, "top_k").Int() != 40 || gjson.GetBytes(out, "extra_body").Exists() {
+		t.Fatalf("out = %s", out)
+	}
+	if errMsg := handler.checkSamplingExtensions(context.Background(), meta); errMsg != nil {
+		t.Fatalf("unknown parameters rejected outside strict mode: %v", errMsg.Error)
+	}
+
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ginCtx.Request.Header.Set(strictTranslationHeader, "true")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	errMsg := handler.checkSamplingExtensions(ctx, meta)
+	if errMsg == nil || errMsg.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %+v", errMsg)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if got := gjson.GetBytes(body, "error.parameters").Raw; got != `["mirostat"]` {
+		t.Fatalf("body = %s", body)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+// systemInjectionsHeader switches injections for one request, e.g.
+// "context-files=off, tool-choice=on".
+const systemInjectionsHeader = "X-CLIProxy-System-Injections"
+
+// System prompt injections, in the order their text appears in the prompt.
+const (
+	injectionContextFiles = "context-files"
+	injectionToolManifest = "tool-manifest"
+	injectionToolChoice   = "tool-choice"
+)
+
+const (
+	// contextFilesMetadataKey records the project files attached to the system prompt.
+	contextFilesMetadataKey = "context_files"
+	// systemInjectionsMetadataKey records which injections were applied.
+	systemInjectionsMetadataKey = "system_injections"
+
+	defaultContextFilesMaxBytes = 64 << 10
+)
+
+var defaultInjectionTemplates = map[string]string{
+	injectionContextFiles: "The client attached the following project files as context:\n{{files}}",
+	injectionToolManifest: "You can call the following tools:\n{{tools}}",
+	injectionToolChoice:   "You must respond with a call to {{tool}}.",
+}
+
+// applySystemInjections adds the proxy's own text to the system prompt:
+// client-attached context files, a tool manifest and a forced tool choice
+// directive, each subject to the configured policy and the per-request
+// header. It runs before sanitization and secret masking so those passes
+// also cover the injected text.
+func (h *BaseAPIHandler) applySystemInjections(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	cfg := h.Cfg.SystemInjections
+	if profile := h.modelProfile(payloadModel(ctx, rawJSON)); profile != nil && profile.ToolChoice != nil {
+		cfg.ToolChoice = *profile.ToolChoice
+	}
+	overrides := injectionOverrides(ctx)
+	enabled := func(name string, policy config.InjectionPolicy, byDefault bool) bool {
+		if on, ok := overrides[name]; ok {
+			return on
+		}
+		if policy.Enabled != nil {
+			return *policy.Enabled
+		}
+		return byDefault
+	}
+	remaining := -1
+	if cfg.MaxBytes > 0 {
+		remaining = cfg.MaxBytes
+	}
+	texts := make(map[string]string)
+	add := func(name, text string) {
+		if remaining >= 0 {
+			if len(text) > remaining {
+				return
+			}
+			remaining -= len(text)
+		}
+		texts[name] = text
+	}
+
+	// The directive and the manifest are short, so they are budgeted before
+	// context files, which are truncated to whatever is left.
+	if enabled(injectionToolChoice, cfg.ToolChoice, false) {
+		if target, forced := forcedToolChoice(handlerType, rawJSON); forced {
+			add(injectionToolChoice, renderInjection(injectionToolChoice, cfg.ToolChoice, "{{tool}}", target))
+		}
+	}
+	if enabled(injectionToolManifest, cfg.ToolManifest, false) {
+		if manifest := toolManifest(handlerType, rawJSON); manifest != "" {
+			add(injectionToolManifest, renderInjection(injectionToolManifest, cfg.ToolManifest, "{{tools}}", manifest))
+		}
+	}
+	var meta map[string]any
+	if !h.Cfg.ContextFiles.Disabled {
+		maxBytes := h.Cfg.ContextFiles.MaxBytes
+		if maxBytes <= 0 {
+			maxBytes = defaultContextFilesMaxBytes
+		}
+		if remaining >= 0 {
+			overhead := len(renderInjection(injectionContextFiles, cfg.ContextFiles, "{{files}}", ""))
+			maxBytes = min(maxBytes, remaining-overhead)
+		}
+		out, block, files := util.TakeContextFiles(rawJSON, maxBytes)
+		text := renderInjection(injectionContextFiles, cfg.ContextFiles, "{{files}}", block)
+		// Tags and truncation markers are not counted by TakeContextFiles, so
+		// shrink the content limit until the rendered text fits.
+		for attempt := 0; remaining >= 0 && len(text) > remaining && maxBytes > 0 && attempt < 3; attempt++ {
+			maxBytes -= len(text) - remaining
+			out, block, files = util.TakeContextFiles(rawJSON, maxBytes)
+			text = renderInjection(injectionContextFiles, cfg.ContextFiles, "{{files}}", block)
+		}
+		rawJSON = out
+		if len(files) > 0 && maxBytes > 0 && enabled(injectionContextFiles, cfg.ContextFiles, true) {
+			add(injectionContextFiles, text)
+			if _, ok := texts[injectionContextFiles]; ok {
+				meta = map[string]any{contextFilesMetadataKey: files}
+			}
+		}
+	}
+
+	var parts, applied []string
+	for _, name := range []string{injectionContextFiles, injectionToolManifest, injectionToolChoice} {
+		if text, ok := texts[name]; ok {
+			parts = append(parts, text)
+			applied = append(applied, name)
+		}
+	}
+	if len(parts) == 0 {
+		return rawJSON, meta
+	}
+	out, ok := util.AppendSystemText(handlerType, rawJSON, strings.Join(parts, "\n\n"))
+	if !ok {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("system prompt injections applied: %s", strings.Join(applied, ", "))
+	return out, mergeMetadata(meta, map[string]any{systemInjectionsMetadataKey: applied})
+}
+
+// renderInjection fills the policy template, or the default one, with value.
+func renderInjection(name string, policy config.InjectionPolicy, placeholder, value string) string {
+	template := policy.Template
+	if strings.TrimSpace(template) == "" {
+		template = defaultInjectionTemplates[name]
+	}
+	if !strings.Contains(template, placeholder) {
+		return template
+	}
+	return strings.ReplaceAll(template, placeholder, value)
+}
+
+// injectionOverrides parses the per-request injection header.
+func injectionOverrides(ctx context.Context) map[string]bool {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return nil
+	}
+	header := ginCtx.GetHeader(systemInjectionsHeader)
+	if header == "" {
+		return nil
+	}
+	overrides := make(map[string]bool)
+	for _, item := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(item, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			overrides[name] = true
+		case "off", "false", "0":
+			overrides[name] = false
+		}
+	}
+	return overrides
+}
+
+// forcedToolChoice reports whether the request forces a tool call and
+// describes the target for the tool-choice directive.
+func forcedToolChoice(handlerType string, rawJSON []byte) (string, bool) {
+	anyTool := "one of the provided tools"
+	named := func(name string) (string, bool) { return fmt.Sprintf("the tool %q", name), true }
+	switch handlerType {
+	case "claude":
+		choice := gjson.GetBytes(rawJSON, "tool_choice")
+		switch choice.Get("type").String() {
+		case "any":
+			return anyTool, true
+		case "tool":
+			return named(choice.Get("name").String())
+		}
+	case "openai", "openai-response":
+		choice := gjson.GetBytes(rawJSON, "tool_choice")
+		if choice.Type == gjson.String {
+			return anyTool, choice.String() == "required"
+		}
+		if name := choice.Get("function.name").String(); name != "" {
+			return named(name)
+		}
+		if name := choice.Get("name").String(); name != "" {
+			return named(name)
+		}
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if handlerType == "gemini-cli" {
+			prefix = "request."
+		}
+		calling := gjson.GetBytes(rawJSON, prefix+"toolConfig.functionCallingConfig")
+		if strings.EqualFold(calling.Get("mode").String(), "ANY") {
+			if allowed := calling.Get("allowedFunctionNames").Array(); len(allowed) == 1 {
+				return named(allowed[0].String())
+			}
+			return anyTool, true
+		}
+	}
+	return "", false
+}
+
+// toolManifest lists the request's tools as "- name: description" lines.
+func toolManifest(handlerType string, rawJSON []byte) string {
+	var lines []string
+	addTool := func(name, description string) {
+		if name == "" {
+			return
+		}
+		line := "- " + name
+		if description = strings.TrimSpace(description); description != "" {
+			line += ": " + strings.SplitN(description, "\n", 2)[0]
+		}
+		lines = append(lines, line)
+	}
+	switch handlerType {
+	case "claude", "openai-response":
+		for _, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+			addTool(tool.Get("name").String(), tool.Get("description").String())
+		}
+	case "openai":
+		for _, tool := range gjson.GetBytes(rawJSON, "tools").Array() {
+			addTool(tool.Get("function.name").String(), tool.Get("function.description").String())
+		}
+	case "gemini", "gemini-cli":
+		path := "tools"
+		if handlerType == "gemini-cli" {
+			path = "request.tools"
+		}
+		for _, tool := range gjson.GetBytes(rawJSON, path).Array() {
+			for _, fn := range tool.Get("functionDeclarations").Array() {
+				addTool(fn.Get("name").String(), fn.Get("description").String())
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplySystemInjections(t *testing.T) {
+	on := true
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{SystemInjections: config.SystemInjectionsConfig{
+		MaxBytes:   200,
+		ToolChoice: config.InjectionPolicy{Enabled: &on},
+	}}}
+	request := []byte(`{"system":"sys","tools":[{"name":"lookup","description":"Find a record"}],"tool_choice":{"type":"tool","name":"lookup"},` +
+		`"context_files":[{"path":"README.md","content":"` + strings.Repeat("x", 200) + `"}],"messages":[]}`)
+
+	out, meta := h.applySystemInjections(context.Background(), "claude", request)
+	system := gjson.GetBytes(out, "system").String()
+	if gjson.GetBytes(out, "context_files").Exists() {
+		t.Fatalf("context_files not removed: %s", out)
+	}
+	if !strings.HasSuffix(system, `You must respond with a call to the tool "lookup".`) || !strings.Contains(system, `<context_file path="README.md">`) {
+		t.Fatalf("system = %q", system)
+	}
+	if injected := len(system) - len("sys\n\n") - len("\n\n"); injected > 200 {
+		t.Fatalf("injected %d bytes, cap is 200", injected)
+	}
+	if applied, _ := meta[systemInjectionsMetadataKey].([]string); strings.Join(applied, ",") != "context-files,tool-choice" {
+		t.Fatalf("meta = %v", meta)
+	}
+
+	// The request header switches injections for one request.
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Request.Header.Set(systemInjectionsHeader, "context-files=off, tool-choice=off, tool-manifest=on")
+	ctx := context.WithValue(context.Background(), "gin", c)
+	out, _ = h.applySystemInjections(ctx, "claude", request)
+	system = gjson.GetBytes(out, "system").String()
+	if system != "sys\n\nYou can call the following tools:\n- lookup: Find a record" || gjson.GetBytes(out, "context_files").Exists() {
+		t.Fatalf("with header: %s", out)
+	}
+}
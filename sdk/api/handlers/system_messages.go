@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// midTranscriptSystemMetadataKey stores the number of system messages moved
+// out of the middle of the history.
+const midTranscriptSystemMetadataKey = "mid_transcript_system_moved"
+
+// applyMidTranscriptSystem moves system messages that clients insert between
+// turns into a labeled block of the system prompt or the next user turn, so
+// upstreams without such messages neither reject them nor read them as plain
+// user text. It runs before role alternation, which merges the turns left
+// adjacent.
+func (h *BaseAPIHandler) applyMidTranscriptSystem(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil {
+		return rawJSON, nil
+	}
+	target := strings.ToLower(strings.TrimSpace(h.Cfg.MidTranscriptSystem))
+	out, moved := util.MergeMidTranscriptSystem(handlerType, rawJSON, target)
+	if moved == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("mid-transcript system: moved %d system message(s) to the %s", moved, target)
+	return out, map[string]any{midTranscriptSystemMetadataKey: moved}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// toolChoiceNoneMetadataKey records in execution metadata that the request
+// forbade tool calls, since the request reaching the response side may no
+// longer carry its tool_choice.
+const toolChoiceNoneMetadataKey = "tool_choice_none"
+
+// applyToolChoiceNone strips the tools of requests with tool_choice "none".
+// Several upstreams and translations ignore or rewrite that choice and call
+// tools anyway.
+func (h *BaseAPIHandler) applyToolChoiceNone(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if !util.ToolChoiceNone(handlerType, rawJSON) || !h.featureEnabled(ctx, featureflags.ToolChoiceNone) {
+		return rawJSON, nil
+	}
+	if out, stripped := util.StripTools(handlerType, rawJSON); stripped {
+		logging.Entry(ctx).Debug("tool_choice none: removed tool declarations from the request")
+		rawJSON = out
+	}
+	return rawJSON, map[string]any{toolChoiceNoneMetadataKey: true}
+}
+
+func toolChoiceNone(meta map[string]any) bool {
+	none, _ := meta[toolChoiceNoneMetadataKey].(bool)
+	return none
+}
+
+// dropForbiddenToolCalls removes tool calls from a non-streaming response to a
+// request with tool_choice "none". It returns the payload and the number of
+// calls dropped.
+func dropForbiddenToolCalls(ctx context.Context, handlerType string, meta map[string]any, response []byte) ([]byte, int) {
+	if !toolChoiceNone(meta) {
+		return response, 0
+	}
+	out, dropped := util.DropToolCalls(handlerType, response)
+	if dropped > 0 {
+		logging.Entry(ctx).Warnf("tool_choice none: dropped %d tool call(s) returned by upstream", dropped)
+	}
+	return out, dropped
+}
+
+// newNoToolCallStream returns the streaming counterpart of
+// dropForbiddenToolCalls, or nil when the request allows tool calls.
+func newNoToolCallStream(handlerType string, meta map[string]any) *util.NoToolCallStream {
+	if !toolChoiceNone(meta) {
+		return nil
+	}
+	return util.NewNoToolCallStream(handlerType)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// toolCallIDsMetadataKey records in execution metadata the tool call IDs
+// replaced for the upstream, mapping each new ID to the client's.
+const toolCallIDsMetadataKey = "tool_call_ids_mapped"
+
+// applyToolCallIDMapping replaces tool call IDs some upstreams reject, such as
+// IDs with dots or colons minted by another provider.
+func (h *BaseAPIHandler) applyToolCallIDMapping(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if !h.featureEnabled(ctx, featureflags.ToolCallIDMapping) {
+		return rawJSON, nil
+	}
+	out, ids := util.SanitizeToolCallIDs(handlerType, rawJSON)
+	if len(ids) == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("replaced %d tool call ID(s) for the upstream", len(ids))
+	return out, map[string]any{toolCallIDsMetadataKey: ids}
+}
+
+func mappedToolCallIDs(meta map[string]any) map[string]string {
+	ids, _ := meta[toolCallIDsMetadataKey].(map[string]string)
+	return ids
+}
+
+// restoreToolCallIDs puts the client's tool call IDs back into a
+// non-streaming response.
+func restoreToolCallIDs(handlerType string, meta map[string]any, response []byte) []byte {
+	return util.RestoreToolCallIDs(handlerType, response, mappedToolCallIDs(meta))
+}
+
+// newToolCallIDStream returns the streaming counterpart of
+// restoreToolCallIDs, or nil when no ID was replaced.
+func newToolCallIDStream(handlerType string, meta map[string]any) *util.ToolCallIDStream {
+	ids := mappedToolCallIDs(meta)
+	if len(ids) == 0 {
+		return nil
+	}
+	return util.NewToolCallIDStream(handlerType, ids)
+}
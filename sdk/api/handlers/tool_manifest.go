@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/toolmanifest"
+	"golang.org/x/net/context"
+)
+
+// expandToolManifest restores the full tool list of an OpenAI request sent by
+// another proxy with tool-manifest-delta enabled. A delta whose base list is no
+// longer known is rejected with 409, upon which the sender resends all tools.
+func expandToolManifest(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	if handlerType != "openai" {
+		return rawJSON, nil
+	}
+	out, err := toolmanifest.DefaultStore().Expand(rawJSON)
+	if err == nil {
+		return out, nil
+	}
+	status := http.StatusBadRequest
+	if errors.Is(err, toolmanifest.ErrMismatch) {
+		status = http.StatusConflict
+		logging.Entry(ctx).Debug("tool manifest: unknown base tool list, asking the sender for all tools")
+	}
+	body := fmt.Sprintf(`{"error":{"message":%q,"type":"invalid_request_error","code":%q}}`, err.Error(), toolmanifest.MismatchCode)
+	return nil, &interfaces.ErrorMessage{StatusCode: status, Error: errors.New(body)}
+}
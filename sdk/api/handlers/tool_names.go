@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// toolNamesMetadataKey records in execution metadata the tools renamed for the
+// upstream, mapping each new name to the one the client declared.
+const toolNamesMetadataKey = "tool_names_mapped"
+
+// applyToolNameMapping renames tools whose names some upstreams reject, such
+// as MCP tools with dots or slashes in their names.
+func (h *BaseAPIHandler) applyToolNameMapping(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if !h.featureEnabled(ctx, featureflags.ToolNameMapping) {
+		return rawJSON, nil
+	}
+	out, names := util.SanitizeToolNames(handlerType, rawJSON)
+	if len(names) == 0 {
+		return rawJSON, nil
+	}
+	logging.Entry(ctx).Debugf("renamed %d tool(s) for the upstream", len(names))
+	return out, map[string]any{toolNamesMetadataKey: names}
+}
+
+func mappedToolNames(meta map[string]any) map[string]string {
+	names, _ := meta[toolNamesMetadataKey].(map[string]string)
+	return names
+}
+
+// restoreToolNames puts the client's tool names back into a non-streaming
+// response.
+func restoreToolNames(handlerType string, meta map[string]any, response []byte) []byte {
+	return util.RestoreToolNames(handlerType, response, mappedToolNames(meta))
+}
+
+// newToolNameStream returns the streaming counterpart of restoreToolNames, or
+// nil when no tool was renamed.
+func newToolNameStream(handlerType string, meta map[string]any) *util.ToolNameStream {
+	names := mappedToolNames(meta)
+	if len(names) == 0 {
+		return nil
+	}
+	return util.NewToolNameStream(handlerType, names)
+}
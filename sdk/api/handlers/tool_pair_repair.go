@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflags"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"golang.org/x/net/context"
+)
+
+// toolPairRepairMetadataKey stores transcript repairs in execution metadata.
+const toolPairRepairMetadataKey = "tool_pair_repairs"
+
+// applyToolPairRepair fixes orphan tool calls and tool results in Claude and
+// OpenAI chat transcripts before they are translated. Upstreams reject such
+// transcripts outright, so the repair runs unless explicitly disabled or turned
+// off by the tool-pair-repair feature flag.
+func (h *BaseAPIHandler) applyToolPairRepair(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, map[string]any) {
+	if h == nil || h.Cfg == nil || h.Cfg.DisableToolPairRepair || !h.featureEnabled(ctx, featureflags.ToolPairRepair) {
+		return rawJSON, nil
+	}
+	var (
+		out     []byte
+		repairs []util.ToolPairRepair
+	)
+	switch handlerType {
+	case "claude":
+		out, repairs = util.RepairClaudeToolPairs(rawJSON)
+	case "openai":
+		out, repairs = util.RepairOpenAIToolPairs(rawJSON)
+	default:
+		return rawJSON, nil
+	}
+	if len(repairs) == 0 {
+		return rawJSON, nil
+	}
+	entry := logging.Entry(ctx)
+	for _, repair := range repairs {
+		entry.Infof("repaired transcript: %s for tool call %q (message %d)", repair.Kind, repair.ToolUseID, repair.MessageIndex)
+	}
+	return out, map[string]any{toolPairRepairMetadataKey: repairs}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/context"
+)
+
+// trafficArmHeader names the arm a split request was assigned to.
+const trafficArmHeader = "X-CLIProxy-Traffic-Arm"
+
+// conversationStartPaths locate the first user turn of a request in the
+// supported client schemas.
+var conversationStartPaths = []string{
+	`messages.#(role=="user").content`,
+	`input.#(role=="user").content`,
+	`contents.#(role=="user").parts`,
+	`contents.0.parts`,
+	`request.contents.#(role=="user").parts`,
+	`request.contents.0.parts`,
+}
+
+// trafficArm is the assignment of one request to an arm of a traffic split.
+// A nil arm records nothing.
+type trafficArm struct {
+	split   string
+	model   string
+	started time.Time
+}
+
+// applyTrafficSplit replaces the requested model with the arm of its traffic
+// split that the conversation is assigned to. The assignment hashes the client
+// API key and the first user turn, so later requests of a conversation land on
+// the same arm without any stored state.
+func (h *BaseAPIHandler) applyTrafficSplit(ctx context.Context, modelName string, rawJSON []byte) (string, *trafficArm) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.TrafficSplits) == 0 {
+		return modelName, nil
+	}
+	parsed := thinking.ParseSuffix(modelName)
+	base := strings.TrimSpace(parsed.ModelName)
+	for _, split := range h.Cfg.TrafficSplits {
+		if !strings.EqualFold(strings.TrimSpace(split.Model), base) {
+			continue
+		}
+		total := 0
+		for _, arm := range split.Arms {
+			if arm.Weight > 0 && strings.TrimSpace(arm.Model) != "" {
+				total += arm.Weight
+			}
+		}
+		if total == 0 {
+			return modelName, nil
+		}
+		apiKey := ""
+		ginCtx, _ := ctx.Value("gin").(*gin.Context)
+		if ginCtx != nil {
+			apiKey = ginCtx.GetString("apiKey")
+		}
+		hasher := fnv.New64a()
+		_, _ = hasher.Write([]byte(base + "\x00" + apiKey + "\x00" + conversationStart(rawJSON)))
+		bucket := int(hasher.Sum64() % uint64(total))
+		for _, arm := range split.Arms {
+			if arm.Weight <= 0 || strings.TrimSpace(arm.Model) == "" {
+				continue
+			}
+			if bucket >= arm.Weight {
+				bucket -= arm.Weight
+				continue
+			}
+			target := strings.TrimSpace(arm.Model)
+			if ginCtx != nil {
+				ginCtx.Header(trafficArmHeader, target)
+			}
+			logging.Entry(ctx).Debugf("traffic split: %s assigned to %s", base, target)
+			if parsed.HasSuffix {
+				target += "(" + parsed.RawSuffix + ")"
+			}
+			return target, &trafficArm{split: base, model: strings.TrimSpace(arm.Model), started: time.Now()}
+		}
+	}
+	return modelName, nil
+}
+
+// conversationStart returns the raw first user turn of a request.
+func conversationStart(rawJSON []byte) string {
+	if input := gjson.GetBytes(rawJSON, "input"); input.Type == gjson.String {
+		return input.Raw
+	}
+	for _, path := range conversationStartPaths {
+		if value := gjson.GetBytes(rawJSON, path); value.Exists() {
+			return value.Raw
+		}
+	}
+	return ""
+}
+
+// record counts the executed request for the arm's metrics.
+func (a *trafficArm) record(failed bool) {
+	if a == nil {
+		return
+	}
+	usage.RecordTrafficArm(a.split, a.model, failed, time.Since(a.started).Milliseconds())
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestTrafficSplitIsStickyAndWeighted(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{TrafficSplits: []sdkconfig.TrafficSplit{{
+		Model: "base",
+		Arms:  []sdkconfig.TrafficArm{{Model: "base", Weight: 90}, {Model: "candidate", Weight: 10}},
+	}}}, nil)
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		first := fmt.Sprintf(`{"messages":[{"role":"user","content":"task %d"}]}`, i)
+		model, arm := h.applyTrafficSplit(context.Background(), "base", []byte(first))
+		if arm == nil {
+			t.Fatal("expected an arm assignment")
+		}
+		later := fmt.Sprintf(`{"messages":[{"role":"system","content":"s"},{"role":"user","content":"task %d"},{"role":"assistant","content":"a"},{"role":"user","content":"more"}]}`, i)
+		if again, _ := h.applyTrafficSplit(context.Background(), "base", []byte(later)); again != model {
+			t.Fatalf("conversation %d moved from %s to %s", i, model, again)
+		}
+		counts[model]++
+	}
+	if counts["candidate"] < 50 || counts["candidate"] > 150 {
+		t.Fatalf("unexpected split %v", counts)
+	}
+
+	if model, arm := h.applyTrafficSplit(context.Background(), "other", nil); model != "other" || arm != nil {
+		t.Fatalf("unsplit model changed to %s", model)
+	}
+}
+
+func TestTrafficSplitKeepsSuffixAndRecordsArm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{TrafficSplits: []sdkconfig.TrafficSplit{{
+		Model: "split-model",
+		Arms:  []sdkconfig.TrafficArm{{Model: "arm-only", Weight: 1}, {Model: "never", Weight: 0}},
+	}}}, nil)
+
+	model, arm := h.applyTrafficSplit(ctx, "split-model(high)", []byte(`{"messages":[{"role":"user","content":"hi"}]}`))
+	if model != "arm-only(high)" {
+		t.Fatalf("model = %s", model)
+	}
+	if got := recorder.Header().Get(trafficArmHeader); got != "arm-only" {
+		t.Fatalf("header = %q", got)
+	}
+	before := usage.TrafficArms()["split-model"]["arm-only"]
+	arm.record(true)
+	after := usage.TrafficArms()["split-model"]["arm-only"]
+	if after.Requests != before.Requests+1 || after.Failures != before.Failures+1 {
+		t.Fatalf("arm counters not updated: %+v -> %+v", before, after)
+	}
+}
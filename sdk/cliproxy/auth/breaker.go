@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// CircuitBreakerStatus describes the circuit breaker state of one auth.
+type CircuitBreakerStatus struct {
+	ID                  string     `json:"id"`
+	AuthIndex           string     `json:"auth_index"`
+	Provider            string     `json:"provider"`
+	Label               string     `json:"label,omitempty"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Trips               int64      `json:"trips"`
+	Rejected            int64      `json:"rejected"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	ProbeAt             *time.Time `json:"probe_at,omitempty"`
+}
+
+// breakerSettings returns whether the per-auth circuit breaker is enabled and its limits.
+func (m *Manager) breakerSettings() (enabled bool, threshold int, cooldown time.Duration) {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || !cfg.CircuitBreaker.Enabled || m.breakers == nil {
+		return false, 0, 0
+	}
+	threshold, cooldown = defaultBreakerFailureThreshold, defaultBreakerCooldown
+	if cfg.CircuitBreaker.FailureThreshold > 0 {
+		threshold = cfg.CircuitBreaker.FailureThreshold
+	}
+	if cfg.CircuitBreaker.CooldownSeconds > 0 {
+		cooldown = time.Duration(cfg.CircuitBreaker.CooldownSeconds) * time.Second
+	}
+	return true, threshold, cooldown
+}
+
+// breakerGate filters selection candidates whose circuit is open and
+// remembers when the earliest of them admits a probe.
+type breakerGate struct {
+	m        *Manager
+	enabled  bool
+	cooldown time.Duration
+	blocked  []string
+	probeAt  time.Time
+}
+
+func (m *Manager) newBreakerGate() *breakerGate {
+	enabled, _, cooldown := m.breakerSettings()
+	return &breakerGate{m: m, enabled: enabled, cooldown: cooldown}
+}
+
+// blocks reports whether the circuit of authID currently rejects requests.
+func (g *breakerGate) blocks(authID string) bool {
+	if !g.enabled || g.m.breakers.Ready(authID, g.cooldown) {
+		return false
+	}
+	g.blocked = append(g.blocked, authID)
+	if at, ok := g.m.breakers.RetryAt(authID, g.cooldown); ok && (g.probeAt.IsZero() || at.Before(g.probeAt)) {
+		g.probeAt = at
+	}
+	return true
+}
+
+// claim admits a request for the selected auth, turning it into the recovery
+// probe when its circuit was open.
+func (g *breakerGate) claim(auth *Auth, provider, model string) error {
+	if !g.enabled {
+		return nil
+	}
+	allowed, probe := g.m.breakers.Allow(auth.ID, g.cooldown)
+	if !allowed {
+		// Another request claimed the probe after the candidate was filtered.
+		return newCircuitOpenError(provider, model, g.cooldown)
+	}
+	if probe {
+		log.Infof("circuit breaker: probing auth %s (%s)", auth.EnsureIndex(), auth.Provider)
+	}
+	return nil
+}
+
+// err returns the fail-fast error for a selection that found no candidate, or
+// nil when no candidate was filtered by an open circuit.
+func (g *breakerGate) err(provider, model string) error {
+	if len(g.blocked) == 0 {
+		return nil
+	}
+	for _, authID := range g.blocked {
+		g.m.breakers.Reject(authID)
+	}
+	retryIn := time.Until(g.probeAt)
+	if g.probeAt.IsZero() || retryIn < 0 {
+		retryIn = 0
+	}
+	if provider == "mixed" {
+		provider = ""
+	}
+	return newCircuitOpenError(provider, model, retryIn)
+}
+
+// recordBreakerResult feeds an execution result to the auth's circuit.
+// Upstream 5xx responses, timeouts and transport errors count as failures;
+// other errors leave the circuit unchanged.
+func (m *Manager) recordBreakerResult(ctx context.Context, result Result) {
+	enabled, threshold, _ := m.breakerSettings()
+	if !enabled || result.AuthID == "" {
+		return
+	}
+	if result.Success {
+		if m.breakers.Success(result.AuthID) {
+			log.Infof("circuit breaker: auth %s recovered, circuit closed", m.authIndexFor(result.AuthID))
+		}
+		return
+	}
+	if (ctx != nil && ctx.Err() != nil) || !breakerFailure(result.Error) {
+		m.breakers.Release(result.AuthID)
+		return
+	}
+	if m.breakers.Failure(result.AuthID, threshold) {
+		log.Warnf("circuit breaker: auth %s opened after upstream failure: %s", m.authIndexFor(result.AuthID), result.Error.Message)
+	}
+}
+
+func breakerFailure(err *Error) bool {
+	status := statusCodeFromResult(err)
+	return status == 0 || status == http.StatusRequestTimeout || status >= http.StatusInternalServerError
+}
+
+func (m *Manager) authIndexFor(authID string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if auth, ok := m.auths[authID]; ok && auth != nil && auth.Index != "" {
+		return auth.Index
+	}
+	return authID
+}
+
+// CircuitBreakers reports the circuit state of every registered auth that has
+// seen a breaker-relevant failure, sorted by provider and auth index.
+func (m *Manager) CircuitBreakers() []CircuitBreakerStatus {
+	if m == nil {
+		return nil
+	}
+	_, _, cooldown := m.breakerSettings()
+	snapshots := m.breakers.Snapshots()
+	out := make([]CircuitBreakerStatus, 0, len(snapshots))
+	m.mu.RLock()
+	for _, snap := range snapshots {
+		auth, ok := m.auths[snap.Key]
+		if !ok || auth == nil {
+			continue
+		}
+		status := CircuitBreakerStatus{
+			ID:                  auth.ID,
+			AuthIndex:           auth.Index,
+			Provider:            auth.Provider,
+			Label:               auth.Label,
+			State:               snap.State,
+			ConsecutiveFailures: snap.ConsecutiveFailures,
+			Trips:               snap.Trips,
+			Rejected:            snap.Rejected,
+			OpenedAt:            snap.OpenedAt,
+		}
+		if at, open := m.breakers.RetryAt(snap.Key, cooldown); open {
+			status.ProbeAt = &at
+		}
+		out = append(out, status)
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].AuthIndex < out[j].AuthIndex
+	})
+	return out
+}
+
+// ResetCircuitBreaker closes the circuit of the auth with the given ID or auth
+// index and clears its counters. It reports whether such an auth exists.
+func (m *Manager) ResetCircuitBreaker(idOrIndex string) bool {
+	if m == nil || idOrIndex == "" {
+		return false
+	}
+	m.mu.RLock()
+	authID := ""
+	for id, auth := range m.auths {
+		if auth != nil && (id == idOrIndex || auth.Index == idOrIndex) {
+			authID = id
+			break
+		}
+	}
+	m.mu.RUnlock()
+	if authID == "" {
+		return false
+	}
+	m.breakers.Reset(authID)
+	return true
+}
+
+// circuitOpenError is returned without contacting upstream when every
+// candidate auth for a request has an open circuit.
+type circuitOpenError struct {
+	provider string
+	model    string
+	retryIn  time.Duration
+}
+
+func newCircuitOpenError(provider, model string, retryIn time.Duration) *circuitOpenError {
+	return &circuitOpenError{provider: provider, model: model, retryIn: retryIn}
+}
+
+func (e *circuitOpenError) retrySeconds() int {
+	return int(math.Max(1, math.Ceil(e.retryIn.Seconds())))
+}
+
+func (e *circuitOpenError) Error() string {
+	modelName := e.model
+	if modelName == "" {
+		modelName = "requested model"
+	}
+	message := fmt.Sprintf("All credentials for model %s are failing upstream and temporarily disabled", modelName)
+	if e.provider != "" {
+		message = fmt.Sprintf("%s via provider %s", message, e.provider)
+	}
+	errorBody := map[string]any{
+		"code":          "circuit_open",
+		"message":       message,
+		"model":         e.model,
+		"retry_seconds": e.retrySeconds(),
+	}
+	if e.provider != "" {
+		errorBody["provider"] = e.provider
+	}
+	data, err := json.Marshal(map[string]any{"error": errorBody})
+	if err != nil {
+		return fmt.Sprintf(`{"error":{"code":"circuit_open","message":"%s"}}`, message)
+	}
+	return string(data)
+}
+
+func (e *circuitOpenError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e *circuitOpenError) Headers() http.Header {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Retry-After", strconv.Itoa(e.retrySeconds()))
+	return headers
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type breakerTestExecutor struct {
+	calls   atomic.Int32
+	failing atomic.Bool
+}
+
+func (e *breakerTestExecutor) Identifier() string { return "claude" }
+
+func (e *breakerTestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.calls.Add(1)
+	if e.failing.Load() {
+		return cliproxyexecutor.Response{}, &Error{Message: "bad gateway", HTTPStatus: http.StatusBadGateway}
+	}
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func (e *breakerTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *breakerTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *breakerTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *breakerTestExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestManagerCircuitBreakerFailsFastAndProbes(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewManager(nil, nil, nil)
+	m.breakers.SetClock(func() time.Time { return now })
+	m.SetConfig(&internalconfig.Config{CircuitBreaker: internalconfig.CircuitBreakerConfig{
+		Enabled: true, FailureThreshold: 1, CooldownSeconds: 30,
+	}})
+	executor := &breakerTestExecutor{}
+	executor.failing.Store(true)
+	m.RegisterExecutor(executor)
+	for _, id := range []string{"a1", "a2"} {
+		if _, err := m.Register(ctx, &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("Register(%s) error = %v", id, err)
+		}
+	}
+
+	if _, err := m.Execute(ctx, []string{"claude"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{}); err == nil {
+		t.Fatal("expected upstream failure")
+	}
+	if got := executor.calls.Load(); got != 2 {
+		t.Fatalf("executor calls = %d, want 2", got)
+	}
+
+	// Both circuits are open: the request fails fast without reaching upstream.
+	_, err := m.Execute(ctx, []string{"claude"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+	var openErr *circuitOpenError
+	if !errors.As(err, &openErr) || openErr.StatusCode() != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want circuit open error", err)
+	}
+	if openErr.Headers().Get("Retry-After") != "30" {
+		t.Fatalf("Retry-After = %q", openErr.Headers().Get("Retry-After"))
+	}
+	if got := executor.calls.Load(); got != 2 {
+		t.Fatalf("executor calls = %d after fail-fast, want 2", got)
+	}
+
+	// After the cooldown a probe reaches the recovered upstream and closes its circuit.
+	// The transient-error retry window of the auths is cleared to isolate the breaker.
+	now = now.Add(31 * time.Second)
+	m.mu.Lock()
+	for _, auth := range m.auths {
+		auth.Unavailable, auth.NextRetryAfter = false, time.Time{}
+	}
+	m.mu.Unlock()
+	executor.failing.Store(false)
+	if _, err = m.Execute(ctx, []string{"claude"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{}); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	states := map[string]string{}
+	for _, status := range m.CircuitBreakers() {
+		states[status.ID] = status.State
+	}
+	closed := 0
+	for _, state := range states {
+		if state == "closed" {
+			closed++
+		}
+	}
+	if len(states) != 2 || closed != 1 {
+		t.Fatalf("breaker states = %v, want one closed after the probe", states)
+	}
+}
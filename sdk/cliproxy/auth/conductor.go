@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/circuit"
 	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
@@ -135,6 +136,12 @@ type Manager struct {
 
 	// Auto refresh state
 	refreshCancel context.CancelFunc
+
+	// shared coordinates cooldowns and cursors with other replicas when set.
+	shared SharedState
+
+	// breakers tracks per-auth circuit state when the circuit breaker is enabled.
+	breakers *circuit.Breaker
 }
 
 // NewManager constructs a manager with optional custom selector and hook.
@@ -152,6 +159,7 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		breakers:        circuit.New(),
 	}
 	// atomic.Value requires non-nil initial value.
 	manager.runtimeConfig.Store(&internalconfig.Config{})
@@ -168,6 +176,7 @@ func (m *Manager) SetSelector(selector Selector) {
 	}
 	m.mu.Lock()
 	m.selector = selector
+	attachSharedCursor(selector, m.shared)
 	m.mu.Unlock()
 }
 
@@ -585,7 +594,7 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldAuthID: auth.ID, logging.FieldStage: logging.StageUpstream})
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -634,7 +643,7 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldAuthID: auth.ID, logging.FieldStage: logging.StageUpstream})
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -683,7 +692,7 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldAuthID: auth.ID, logging.FieldStage: logging.StageUpstream})
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -749,7 +758,7 @@ func (m *Manager) executeWithProvider(ctx context.Context, provider string, req
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldAuthID: auth.ID, logging.FieldStage: logging.StageUpstream})
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -798,7 +807,7 @@ func (m *Manager) executeCountWithProvider(ctx context.Context, provider string,
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldAuthID: auth.ID, logging.FieldStage: logging.StageUpstream})
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -847,7 +856,7 @@ func (m *Manager) executeStreamWithProvider(ctx context.Context, provider string
 		debugLogAuthSelection(entry, auth, provider, req.Model)
 
 		tried[auth.ID] = struct{}{}
-		execCtx := ctx
+		execCtx := logging.WithLogFields(ctx, log.Fields{logging.FieldAuthID: auth.ID, logging.FieldStage: logging.StageUpstream})
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
@@ -1291,12 +1300,17 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	if result.AuthID == "" {
 		return
 	}
+	m.recordBreakerResult(ctx, result)
 
 	shouldResumeModel := false
 	shouldSuspendModel := false
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	// publishCooldown is set when the model availability changed in a way other
+	// replicas should learn about; sharedUntil is zero for a recovery.
+	publishCooldown := false
+	var sharedUntil time.Time
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
@@ -1305,6 +1319,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		if result.Success {
 			if result.Model != "" {
 				state := ensureModelState(auth, result.Model)
+				publishCooldown = state.Unavailable || !state.NextRetryAfter.IsZero()
 				resetModelState(state, now)
 				updateAggregatedAvailability(auth, now)
 				if !hasModelError(auth, now) {
@@ -1380,6 +1395,10 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				auth.Status = StatusError
 				auth.UpdatedAt = now
 				updateAggregatedAvailability(auth, now)
+				if shouldSuspendModel && !state.NextRetryAfter.IsZero() {
+					publishCooldown = true
+					sharedUntil = state.NextRetryAfter
+				}
 			} else {
 				applyAuthFailureState(auth, result.Error, result.RetryAfter, now)
 			}
@@ -1400,6 +1419,11 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	} else if shouldSuspendModel {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
+	if publishCooldown {
+		if shared := m.sharedState(); shared != nil {
+			shared.PublishModelCooldown(ctx, result.AuthID, result.Model, sharedUntil, suspendReason)
+		}
+	}
 
 	m.hook.OnResult(ctx, result)
 }
@@ -1692,6 +1716,7 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
+	gate := m.newBreakerGate()
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
@@ -1702,10 +1727,16 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if gate.blocks(candidate.ID) {
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if errOpen := gate.err(provider, model); errOpen != nil {
+			return nil, nil, errOpen
+		}
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
@@ -1727,6 +1758,9 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		}
 		m.mu.Unlock()
 	}
+	if errClaim := gate.claim(authCopy, provider, model); errClaim != nil {
+		return nil, nil, errClaim
+	}
 	return authCopy, executor, nil
 }
 
@@ -1754,6 +1788,7 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
+	gate := m.newBreakerGate()
 	for _, candidate := range m.auths {
 		if candidate == nil || candidate.Disabled {
 			continue
@@ -1774,10 +1809,16 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if gate.blocks(candidate.ID) {
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if errOpen := gate.err("mixed", model); errOpen != nil {
+			return nil, nil, "", errOpen
+		}
 		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
@@ -1805,6 +1846,9 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		}
 		m.mu.Unlock()
 	}
+	if errClaim := gate.claim(authCopy, providerKey, model); errClaim != nil {
+		return nil, nil, "", errClaim
+	}
 	return authCopy, executor, providerKey, nil
 }
 
@@ -2211,15 +2255,10 @@ func executorKeyFromAuth(auth *Auth) string {
 	return strings.ToLower(strings.TrimSpace(auth.Provider))
 }
 
-// logEntryWithRequestID returns a logrus entry with request_id field if available in context.
+// logEntryWithRequestID returns a logrus entry tagged with the request ID and
+// correlation fields carried by ctx.
 func logEntryWithRequestID(ctx context.Context) *log.Entry {
-	if ctx == nil {
-		return log.NewEntry(log.StandardLogger())
-	}
-	if reqID := logging.GetRequestID(ctx); reqID != "" {
-		return log.WithField("request_id", reqID)
-	}
-	return log.NewEntry(log.StandardLogger())
+	return logging.Entry(ctx)
 }
 
 func debugLogAuthSelection(entry *log.Entry, auth *Auth, provider string, model string) {
@@ -16,9 +16,12 @@ import (
 )
 
 // RoundRobinSelector provides a simple provider scoped round-robin selection strategy.
+// When a SharedState is attached through Manager.SetSharedState, the cursor is
+// shared with other replicas so traffic stays evenly spread across credentials.
 type RoundRobinSelector struct {
 	mu      sync.Mutex
 	cursors map[string]int
+	shared  SharedState
 }
 
 // FillFirstSelector selects the first available credential (deterministic ordering).
@@ -178,7 +181,6 @@ func getAvailableAuths(auths []*Auth, provider, model string, now time.Time) ([]
 
 // Pick selects the next available auth for the provider in a round-robin manner.
 func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, auths []*Auth) (*Auth, error) {
-	_ = ctx
 	_ = opts
 	now := time.Now()
 	available, err := getAvailableAuths(auths, provider, model, now)
@@ -187,6 +189,14 @@ func (s *RoundRobinSelector) Pick(ctx context.Context, provider, model string, o
 	}
 	key := provider + ":" + model
 	s.mu.Lock()
+	shared := s.shared
+	s.mu.Unlock()
+	if shared != nil {
+		if next, ok := shared.NextCursor(ctx, key); ok && next > 0 {
+			return available[int((next-1)%int64(len(available)))], nil
+		}
+	}
+	s.mu.Lock()
 	if s.cursors == nil {
 		s.cursors = make(map[string]int)
 	}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// SharedState coordinates account health and selection state between proxy
+// replicas running behind a load balancer. Implementations must be safe for
+// concurrent use and should fail open: errors are handled internally so a
+// shared-state outage degrades to per-replica behavior.
+type SharedState interface {
+	// PublishModelCooldown announces that authID cannot serve model until the
+	// given time. A zero until announces that the model recovered.
+	PublishModelCooldown(ctx context.Context, authID, model string, until time.Time, reason string)
+
+	// NextCursor atomically increments the shared counter for key and returns
+	// the new value. ok is false when the backend is unavailable.
+	NextCursor(ctx context.Context, key string) (value int64, ok bool)
+}
+
+// SetSharedState attaches a shared-state backend. Cooldowns recorded by
+// MarkResult are published to it and the round-robin selector draws its cursor
+// from it. Passing nil restores purely local behavior.
+func (m *Manager) SetSharedState(state SharedState) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.shared = state
+	attachSharedCursor(m.selector, state)
+	m.mu.Unlock()
+}
+
+func (m *Manager) sharedState() SharedState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.shared
+}
+
+func attachSharedCursor(selector Selector, state SharedState) {
+	if rr, ok := selector.(*RoundRobinSelector); ok && rr != nil {
+		rr.mu.Lock()
+		rr.shared = state
+		rr.mu.Unlock()
+	}
+}
+
+// ApplySharedCooldown applies a cooldown announced by another replica to the
+// local auth state. A zero until clears the model cooldown. The change is not
+// persisted or re-published.
+func (m *Manager) ApplySharedCooldown(authID, model string, until time.Time, reason string) {
+	if m == nil || authID == "" || model == "" {
+		return
+	}
+	now := time.Now()
+	clear := until.IsZero() || !until.After(now)
+
+	m.mu.Lock()
+	auth, ok := m.auths[authID]
+	if !ok || auth == nil {
+		m.mu.Unlock()
+		return
+	}
+	state := ensureModelState(auth, model)
+	if clear {
+		resetModelState(state, now)
+	} else {
+		state.Unavailable = true
+		state.Status = StatusError
+		state.StatusMessage = reason
+		state.NextRetryAfter = until
+		state.UpdatedAt = now
+		if reason == "quota" {
+			state.Quota.Exceeded = true
+			state.Quota.Reason = reason
+			state.Quota.NextRecoverAt = until
+		}
+	}
+	updateAggregatedAvailability(auth, now)
+	auth.UpdatedAt = now
+	m.mu.Unlock()
+
+	reg := registry.GetGlobalRegistry()
+	if clear {
+		reg.ClearModelQuotaExceeded(authID, model)
+		reg.ResumeClientModel(authID, model)
+		return
+	}
+	if reason == "quota" {
+		reg.SetModelQuotaExceeded(authID, model)
+	}
+	if reason != "" {
+		reg.SuspendClientModel(authID, model, reason)
+	}
+}
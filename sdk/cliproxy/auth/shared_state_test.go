@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type fakeSharedState struct {
+	mu        sync.Mutex
+	cooldowns []time.Time
+	cursor    int64
+}
+
+func (f *fakeSharedState) PublishModelCooldown(_ context.Context, _, _ string, until time.Time, _ string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cooldowns = append(f.cooldowns, until)
+}
+
+func (f *fakeSharedState) NextCursor(context.Context, string) (int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cursor++
+	return f.cursor, true
+}
+
+func TestManagerPublishesAndAppliesSharedCooldowns(t *testing.T) {
+	ctx := context.Background()
+	shared := &fakeSharedState{}
+	m := NewManager(nil, nil, nil)
+	m.SetSharedState(shared)
+	if _, err := m.Register(ctx, &Auth{ID: "a1", Provider: "claude"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	m.MarkResult(ctx, Result{AuthID: "a1", Provider: "claude", Model: "m", Error: &Error{HTTPStatus: 429, Message: "quota"}})
+	m.MarkResult(ctx, Result{AuthID: "a1", Provider: "claude", Model: "m", Success: true})
+	m.MarkResult(ctx, Result{AuthID: "a1", Provider: "claude", Model: "m", Success: true})
+	if len(shared.cooldowns) != 2 || shared.cooldowns[0].IsZero() || !shared.cooldowns[1].IsZero() {
+		t.Fatalf("published cooldowns = %v, want one cooldown then one recovery", shared.cooldowns)
+	}
+
+	m.ApplySharedCooldown("a1", "m", time.Now().Add(time.Minute), "quota")
+	auth, _ := m.GetByID("a1")
+	if blocked, _, _ := isAuthBlockedForModel(auth, "m", time.Now()); !blocked {
+		t.Fatal("expected remote cooldown to block the model locally")
+	}
+	m.ApplySharedCooldown("a1", "m", time.Time{}, "")
+	auth, _ = m.GetByID("a1")
+	if blocked, _, _ := isAuthBlockedForModel(auth, "m", time.Now()); blocked {
+		t.Fatal("expected remote recovery to unblock the model")
+	}
+}
+
+func TestRoundRobinSelectorUsesSharedCursor(t *testing.T) {
+	selector := &RoundRobinSelector{}
+	attachSharedCursor(selector, &fakeSharedState{cursor: 1})
+	auths := []*Auth{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	got, err := selector.Pick(context.Background(), "gemini", "", cliproxyexecutor.Options{}, auths)
+	if err != nil {
+		t.Fatalf("Pick() error = %v", err)
+	}
+	// The shared counter returns 2, so the second available auth is chosen.
+	if got.ID != "b" {
+		t.Fatalf("Pick() auth.ID = %q, want %q", got.ID, "b")
+	}
+}
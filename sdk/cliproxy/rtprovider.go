@@ -1,27 +1,39 @@
 package cliproxy
 
 import (
-	"context"
-	"net"
 	"net/http"
-	"net/url"
 	"strings"
 	"sync"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/proxy"
 )
 
 // defaultRoundTripperProvider returns a per-auth HTTP RoundTripper based on
-// the Auth.ProxyURL value. It caches transports per proxy URL string.
+// the Auth.ProxyURL value, using the shared upstream transport of that proxy.
 type defaultRoundTripperProvider struct {
-	mu    sync.RWMutex
-	cache map[string]http.RoundTripper
+	mu  sync.RWMutex
+	cfg config.SDKConfig
 }
 
 func newDefaultRoundTripperProvider() *defaultRoundTripperProvider {
-	return &defaultRoundTripperProvider{cache: make(map[string]http.RoundTripper)}
+	return &defaultRoundTripperProvider{}
+}
+
+// configure applies the no-proxy list, upstream TLS and transport settings of cfg.
+func (p *defaultRoundTripperProvider) configure(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cfg = config.SDKConfig{
+		NoProxy:           append([]string(nil), cfg.NoProxy...),
+		UpstreamTLS:       cfg.UpstreamTLS,
+		UpstreamTransport: cfg.UpstreamTransport,
+	}
 }
 
 // RoundTripperFor implements coreauth.RoundTripperProvider.
@@ -34,44 +46,12 @@ func (p *defaultRoundTripperProvider) RoundTripperFor(auth *coreauth.Auth) http.
 		return nil
 	}
 	p.mu.RLock()
-	rt := p.cache[proxyStr]
+	cfg := p.cfg
 	p.mu.RUnlock()
-	if rt != nil {
-		return rt
-	}
-	// Parse the proxy URL to determine the scheme.
-	proxyURL, errParse := url.Parse(proxyStr)
-	if errParse != nil {
-		log.Errorf("parse proxy URL failed: %v", errParse)
-		return nil
-	}
-	var transport *http.Transport
-	// Handle different proxy schemes.
-	if proxyURL.Scheme == "socks5" {
-		// Configure SOCKS5 proxy with optional authentication.
-		username := proxyURL.User.Username()
-		password, _ := proxyURL.User.Password()
-		proxyAuth := &proxy.Auth{User: username, Password: password}
-		dialer, errSOCKS5 := proxy.SOCKS5("tcp", proxyURL.Host, proxyAuth, proxy.Direct)
-		if errSOCKS5 != nil {
-			log.Errorf("create SOCKS5 dialer failed: %v", errSOCKS5)
-			return nil
-		}
-		// Set up a custom transport using the SOCKS5 dialer.
-		transport = &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return dialer.Dial(network, addr)
-			},
-		}
-	} else if proxyURL.Scheme == "http" || proxyURL.Scheme == "https" {
-		// Configure HTTP or HTTPS proxy.
-		transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-	} else {
-		log.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+	rt, err := util.SharedUpstreamTransport(&cfg, proxyStr)
+	if err != nil {
+		log.Errorf("configure proxy failed: %v", err)
 		return nil
 	}
-	p.mu.Lock()
-	p.cache[proxyStr] = transport
-	p.mu.Unlock()
-	return transport
+	return rt
 }
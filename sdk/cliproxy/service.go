@@ -8,14 +8,21 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/convstore"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelmanifest"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
-	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	usagestats "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/wsrelay"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -81,11 +88,20 @@ type Service struct {
 	// coreManager handles core authentication and execution.
 	coreManager *coreauth.Manager
 
+	// roundTrippers builds the per-auth proxy transports.
+	roundTrippers *defaultRoundTripperProvider
+
 	// shutdownOnce ensures shutdown is called only once.
 	shutdownOnce sync.Once
 
 	// wsGateway manages websocket Gemini providers.
 	wsGateway *wsrelay.Manager
+
+	// sharedState shares account health with other replicas when configured.
+	sharedState *sharedstate.Redis
+
+	// convStore persists conversation state across restarts when configured.
+	convStore *convstore.Store
 }
 
 // RegisterUsagePlugin registers a usage plugin on the global usage manager.
@@ -437,6 +453,12 @@ func (s *Service) Run(ctx context.Context) error {
 			log.Warnf("failed to load auth store: %v", errLoad)
 		}
 	}
+	s.startSharedState(ctx)
+	s.startConversationStore(ctx)
+	if err := s.applyAuditConfig(s.cfg); err != nil {
+		return fmt.Errorf("cliproxy: %w", err)
+	}
+	s.startModelManifest(ctx)
 
 	tokenResult, err := s.tokenProvider.Load(ctx, s.cfg)
 	if err != nil && !errors.Is(err, context.Canceled) {
@@ -552,6 +574,12 @@ func (s *Service) Run(ctx context.Context) error {
 		s.cfgMu.Lock()
 		s.cfg = newCfg
 		s.cfgMu.Unlock()
+		if errAudit := s.applyAuditConfig(newCfg); errAudit != nil {
+			log.Errorf("audit log not reconfigured, keeping the previous settings: %v", errAudit)
+		}
+		if s.roundTrippers != nil {
+			s.roundTrippers.configure(newCfg)
+		}
 		if s.coreManager != nil {
 			s.coreManager.SetConfig(newCfg)
 			s.coreManager.SetOAuthModelAlias(newCfg.OAuthModelAlias)
@@ -576,6 +604,7 @@ func (s *Service) Run(ctx context.Context) error {
 		return fmt.Errorf("cliproxy: failed to start watcher: %w", err)
 	}
 	log.Info("file watcher started for config and auth directory changes")
+	go s.reloadOnSignal(watcherCtx)
 
 	// Prefer core auth manager auto refresh if available.
 	if s.coreManager != nil {
@@ -652,11 +681,197 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			}
 		}
 
+		if s.sharedState != nil {
+			if err := s.sharedState.Close(); err != nil {
+				log.Warnf("failed to close shared state: %v", err)
+			}
+		}
+
 		usage.StopDefault()
+
+		if s.convStore != nil {
+			saveUsageStatistics(s.convStore)
+			convstore.SetDefault(nil)
+			if err := s.convStore.Close(); err != nil {
+				log.Warnf("failed to close conversation store: %v", err)
+			}
+		}
+
+		if logger := audit.Default(); logger != nil {
+			audit.SetDefault(nil)
+			if err := logger.Close(); err != nil {
+				log.Warnf("failed to close audit log: %v", err)
+			}
+		}
 	})
 	return shutdownErr
 }
 
+// startSharedState connects the configured shared-state backend and wires it
+// into the core auth manager. Failures are logged and the replica keeps running
+// with local state only.
+func (s *Service) startSharedState(ctx context.Context) {
+	if s.coreManager == nil || s.cfg == nil || strings.TrimSpace(s.cfg.SharedState.Redis.Addr) == "" {
+		return
+	}
+	shared, err := sharedstate.NewRedis(ctx, s.cfg.SharedState.Redis)
+	if err != nil {
+		log.Warnf("shared state disabled: %v", err)
+		return
+	}
+	s.sharedState = shared
+	s.coreManager.SetSharedState(shared)
+	go shared.Run(ctx, s.coreManager.ApplySharedCooldown)
+	log.Infof("shared state enabled via redis at %s", s.cfg.SharedState.Redis.Addr)
+}
+
+// conversationStoreFlushInterval controls how often usage statistics are saved
+// to the conversation store.
+const conversationStoreFlushInterval = time.Minute
+
+// conversationStoreCompactInterval is how often expired entries are compacted
+// away. The store also compacts itself as its journal grows.
+const conversationStoreCompactInterval = time.Hour
+
+// maxPersistedUsageDetails caps the request details saved per model. Restored
+// statistics are rebuilt from the saved details, so totals after a restart
+// cover the most recent requests only.
+const maxPersistedUsageDetails = 1000
+
+// startConversationStore opens the configured conversation store, restores the
+// saved usage statistics and installs the store for conversation ID lookups.
+func (s *Service) startConversationStore(ctx context.Context) {
+	if s.cfg == nil || strings.TrimSpace(s.cfg.ConversationStore.Path) == "" {
+		return
+	}
+	path := strings.TrimSpace(s.cfg.ConversationStore.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(s.configPath), path)
+	}
+	retention := time.Duration(s.cfg.ConversationStore.RetentionHours) * time.Hour
+	store, err := convstore.Open(path, retention)
+	if err != nil {
+		log.Warnf("conversation store disabled: %v", err)
+		return
+	}
+	s.convStore = store
+	convstore.SetDefault(store)
+
+	var snapshot usagestats.StatisticsSnapshot
+	if store.GetJSON(convstore.BucketUsage, "statistics", &snapshot) {
+		result := usagestats.GetRequestStatistics().MergeSnapshot(snapshot)
+		log.Infof("restored usage statistics from conversation store (%d added, %d skipped)", result.Added, result.Skipped)
+	}
+	go func() {
+		flush := time.NewTicker(conversationStoreFlushInterval)
+		defer flush.Stop()
+		compact := time.NewTicker(conversationStoreCompactInterval)
+		defer compact.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-flush.C:
+				saveUsageStatistics(store)
+			case <-compact.C:
+				if err := store.Compact(); err != nil {
+					log.Warnf("conversation store compaction failed: %v", err)
+				}
+			}
+		}
+	}()
+	log.Infof("conversation store enabled at %s", path)
+}
+
+// saveUsageStatistics writes the current usage statistics to store.
+func saveUsageStatistics(store *convstore.Store) {
+	if !usagestats.StatisticsEnabled() {
+		return
+	}
+	snapshot := usagestats.GetRequestStatistics().Snapshot()
+	snapshot.LimitDetails(maxPersistedUsageDetails)
+	if err := store.PutJSON(convstore.BucketUsage, "statistics", snapshot); err != nil {
+		log.Warnf("failed to save usage statistics: %v", err)
+	}
+}
+
+// reloadOnSignal reloads the configuration whenever the process receives
+// SIGHUP, until ctx is done.
+func (s *Service) reloadOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if err := s.watcher.Reload(watcher.ReloadSourceSignal); err != nil {
+				log.Errorf("config reload on SIGHUP failed: %v", err)
+			}
+		}
+	}
+}
+
+// applyAuditConfig opens, replaces or closes the audit log to match cfg. An
+// audit log that cannot be opened leaves the current one in place and is
+// returned, so the service does not start without the auditing it was
+// configured for.
+func (s *Service) applyAuditConfig(cfg *config.Config) error {
+	current := audit.Default()
+	if cfg == nil || !cfg.Audit.Enabled {
+		if current != nil {
+			audit.SetDefault(nil)
+			_ = current.Close()
+			log.Info("audit log disabled")
+		}
+		return nil
+	}
+	if current.Matches(cfg.Audit) {
+		return nil
+	}
+	logger, err := audit.Open(cfg.Audit, filepath.Dir(s.configPath))
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	audit.SetDefault(logger)
+	if current != nil {
+		_ = current.Close()
+	}
+	log.Infof("audit log enabled at %s", logger.Path())
+	return nil
+}
+
+// startModelManifest launches the remote model manifest sync when configured.
+// The last accepted manifest is cached next to the config file.
+func (s *Service) startModelManifest(ctx context.Context) {
+	if s.cfg == nil || strings.TrimSpace(s.cfg.ModelManifest.URL) == "" {
+		return
+	}
+	cachePath := filepath.Join(filepath.Dir(s.configPath), "model-manifest.cache")
+	syncer, err := modelmanifest.New(s.cfg.ModelManifest, s.cfg.ProxyURL, cachePath, s.refreshRegisteredModels)
+	if err != nil {
+		log.Warnf("model manifest sync disabled: %v", err)
+		return
+	}
+	syncer.Start(ctx)
+	log.Infof("model manifest sync enabled from %s", s.cfg.ModelManifest.URL)
+}
+
+// refreshRegisteredModels re-registers the models of every enabled auth so
+// updated model definitions take effect.
+func (s *Service) refreshRegisteredModels() {
+	if s.coreManager == nil {
+		return
+	}
+	for _, a := range s.coreManager.List() {
+		if a == nil || a.Disabled {
+			continue
+		}
+		s.registerModelsForAuth(a)
+	}
+}
+
 func (s *Service) ensureAuthDir() error {
 	info, err := os.Stat(s.cfg.AuthDir)
 	if err != nil {
@@ -709,7 +924,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	var models []*ModelInfo
 	switch provider {
 	case "gemini":
-		models = registry.GetGeminiModels()
+		models = registry.MergeManifestModels("gemini", registry.GetGeminiModels())
 		if entry := s.resolveConfigGeminiKey(a); entry != nil {
 			if len(entry.Models) > 0 {
 				models = buildGeminiConfigModels(entry)
@@ -721,7 +936,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		models = applyExcludedModels(models, excluded)
 	case "vertex":
 		// Vertex AI Gemini supports the same model identifiers as Gemini.
-		models = registry.GetGeminiVertexModels()
+		models = registry.MergeManifestModels("vertex", registry.GetGeminiVertexModels())
 		if authKind == "apikey" {
 			if entry := s.resolveConfigVertexCompatKey(a); entry != nil && len(entry.Models) > 0 {
 				models = buildVertexCompatConfigModels(entry)
@@ -729,10 +944,10 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		}
 		models = applyExcludedModels(models, excluded)
 	case "gemini-cli":
-		models = registry.GetGeminiCLIModels()
+		models = registry.MergeManifestModels("gemini-cli", registry.GetGeminiCLIModels())
 		models = applyExcludedModels(models, excluded)
 	case "aistudio":
-		models = registry.GetAIStudioModels()
+		models = registry.MergeManifestModels("aistudio", registry.GetAIStudioModels())
 		models = applyExcludedModels(models, excluded)
 	case "antigravity":
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -740,7 +955,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		cancel()
 		models = applyExcludedModels(models, excluded)
 	case "claude":
-		models = registry.GetClaudeModels()
+		models = registry.MergeManifestModels("claude", registry.GetClaudeModels())
 		if entry := s.resolveConfigClaudeKey(a); entry != nil {
 			if len(entry.Models) > 0 {
 				models = buildClaudeConfigModels(entry)
@@ -751,7 +966,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		}
 		models = applyExcludedModels(models, excluded)
 	case "codex":
-		models = registry.GetOpenAIModels()
+		models = registry.MergeManifestModels("codex", registry.GetOpenAIModels())
 		if entry := s.resolveConfigCodexKey(a); entry != nil {
 			if len(entry.Models) > 0 {
 				models = buildCodexConfigModels(entry)
@@ -762,10 +977,10 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		}
 		models = applyExcludedModels(models, excluded)
 	case "qwen":
-		models = registry.GetQwenModels()
+		models = registry.MergeManifestModels("qwen", registry.GetQwenModels())
 		models = applyExcludedModels(models, excluded)
 	case "iflow":
-		models = registry.GetIFlowModels()
+		models = registry.MergeManifestModels("iflow", registry.GetIFlowModels())
 		models = applyExcludedModels(models, excluded)
 	default:
 		// Handle OpenAI-compatibility providers by name using config
@@ -828,6 +1043,9 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 							DisplayName: modelID,
 							UserDefined: true,
 						})
+						if modelID != m.Name {
+							ms[len(ms)-1].UpstreamID = m.Name
+						}
 					}
 					// Register and return
 					if len(ms) > 0 {
@@ -1161,6 +1379,9 @@ func buildConfigModels[T modelEntry](models []T, ownedBy, modelType string) []*M
 			DisplayName: display,
 			UserDefined: true,
 		}
+		if name != "" && name != alias {
+			info.UpstreamID = name
+		}
 		if name != "" {
 			if upstream := registry.LookupStaticModelInfo(name); upstream != nil && upstream.Thinking != nil {
 				info.Thinking = upstream.Thinking
@@ -1311,6 +1532,9 @@ func applyOAuthModelAlias(cfg *config.Config, provider, authKind string, models
 			seen[aliasKey] = struct{}{}
 			clone := *model
 			clone.ID = mappedID
+			if clone.UpstreamID == "" {
+				clone.UpstreamID = id
+			}
 			if clone.Name != "" {
 				clone.Name = rewriteModelInfoName(clone.Name, id, mappedID)
 			}
@@ -28,6 +28,9 @@ func TestApplyOAuthModelAlias_Rename(t *testing.T) {
 	if out[0].Name != "models/g5" {
 		t.Fatalf("expected model name %q, got %q", "models/g5", out[0].Name)
 	}
+	if out[0].UpstreamID != "gpt-5" {
+		t.Fatalf("expected upstream id %q, got %q", "gpt-5", out[0].UpstreamID)
+	}
 }
 
 func TestApplyOAuthModelAlias_ForkAddsAlias(t *testing.T) {
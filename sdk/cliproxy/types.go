@@ -89,6 +89,7 @@ type WatcherWrapper struct {
 	snapshotAuths         func() []*coreauth.Auth
 	setUpdateQueue        func(queue chan<- watcher.AuthUpdate)
 	dispatchRuntimeUpdate func(update watcher.AuthUpdate) bool
+	reload                func(source string) error
 }
 
 // Start proxies to the underlying watcher Start implementation.
@@ -139,6 +140,14 @@ func (w *WatcherWrapper) SnapshotAuths() []*coreauth.Auth {
 	return w.snapshotAuths()
 }
 
+// Reload reloads the configuration file regardless of whether it changed.
+func (w *WatcherWrapper) Reload(source string) error {
+	if w == nil || w.reload == nil {
+		return nil
+	}
+	return w.reload(source)
+}
+
 // SetAuthUpdateQueue registers the channel used to propagate auth updates.
 func (w *WatcherWrapper) SetAuthUpdateQueue(queue chan<- watcher.AuthUpdate) {
 	if w == nil || w.setUpdateQueue == nil {
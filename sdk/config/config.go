@@ -13,8 +13,24 @@ type AccessProvider = internalconfig.AccessProvider
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type ModelProfile = internalconfig.ModelProfile
+type RequestLimitsConfig = internalconfig.RequestLimitsConfig
+type HistoryWindowConfig = internalconfig.HistoryWindowConfig
+type ProviderRoute = internalconfig.ProviderRoute
+type TrafficSplit = internalconfig.TrafficSplit
+type TrafficArm = internalconfig.TrafficArm
+type ShadowConfig = internalconfig.ShadowConfig
+type ShadowRule = internalconfig.ShadowRule
+type RequestDiagnosisConfig = internalconfig.RequestDiagnosisConfig
+type IdempotencyConfig = internalconfig.IdempotencyConfig
+type RateLimitQueueConfig = internalconfig.RateLimitQueueConfig
+type Tenant = internalconfig.Tenant
 type TLSConfig = internalconfig.TLSConfig
+type UpstreamTLSConfig = internalconfig.UpstreamTLSConfig
+type UpstreamTransportConfig = internalconfig.UpstreamTransportConfig
+type FaultInjectionConfig = internalconfig.FaultInjectionConfig
 type RemoteManagement = internalconfig.RemoteManagement
+type UsageStoreConfig = internalconfig.UsageStoreConfig
 type AmpCode = internalconfig.AmpCode
 type OAuthModelAlias = internalconfig.OAuthModelAlias
 type PayloadConfig = internalconfig.PayloadConfig
@@ -0,0 +1,39 @@
+package translator
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// RequestOptions tunes TranslateRequestWithOptions.
+type RequestOptions struct {
+	// Deterministic makes synthesized identifiers (tool call IDs, the Claude Code
+	// account/session pair) and timestamps reproducible so identical inputs
+	// translate to byte-identical output.
+	Deterministic bool
+
+	// Seed fixes the identifier source in deterministic mode. When empty the seed
+	// is derived from the model and raw request, so each distinct input still
+	// gets its own stable identifiers.
+	Seed string
+
+	// Now overrides the clock in deterministic mode. Nil pins it to a fixed instant.
+	Now func() time.Time
+}
+
+// TranslateRequestWithOptions translates a request on the default registry,
+// honoring opts. The deterministic source travels on the context of this call
+// only, so concurrent translations keep their random identifiers.
+func TranslateRequestWithOptions(from, to Format, model string, rawJSON []byte, stream bool, opts RequestOptions) []byte {
+	if !opts.Deterministic {
+		return TranslateRequest(from, to, model, rawJSON, stream)
+	}
+	seed := opts.Seed
+	if seed == "" {
+		seed = from.String() + "\x00" + to.String() + "\x00" + model + "\x00" + string(rawJSON)
+	}
+	ctx := util.WithDeterministicIDs(context.Background(), seed, opts.Now)
+	return TranslateRequestContext(ctx, from, to, model, rawJSON, stream)
+}
@@ -7,16 +7,18 @@ import (
 
 // Registry manages translation functions across schemas.
 type Registry struct {
-	mu        sync.RWMutex
-	requests  map[Format]map[Format]RequestTransform
-	responses map[Format]map[Format]ResponseTransform
+	mu          sync.RWMutex
+	requests    map[Format]map[Format]RequestTransform
+	requestsCtx map[Format]map[Format]RequestContextTransform
+	responses   map[Format]map[Format]ResponseTransform
 }
 
 // NewRegistry constructs an empty translator registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		requests:  make(map[Format]map[Format]RequestTransform),
-		responses: make(map[Format]map[Format]ResponseTransform),
+		requests:    make(map[Format]map[Format]RequestTransform),
+		requestsCtx: make(map[Format]map[Format]RequestContextTransform),
+		responses:   make(map[Format]map[Format]ResponseTransform),
 	}
 }
 
@@ -38,12 +40,41 @@ func (r *Registry) Register(from, to Format, request RequestTransform, response
 	r.responses[from][to] = response
 }
 
+// RegisterContext stores a context-aware request transform and its response
+// transforms between two formats.
+func (r *Registry) RegisterContext(from, to Format, request RequestContextTransform, response ResponseTransform) {
+	r.Register(from, to, nil, response)
+	if request == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.requestsCtx[from]; !ok {
+		r.requestsCtx[from] = make(map[Format]RequestContextTransform)
+	}
+	r.requestsCtx[from][to] = request
+	delete(r.requests[from], to)
+}
+
 // TranslateRequest converts a payload between schemas, returning the original payload
 // if no translator is registered.
 func (r *Registry) TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	return r.TranslateRequestContext(context.Background(), from, to, model, rawJSON, stream)
+}
+
+// TranslateRequestContext is TranslateRequest with a context handed to
+// context-aware request transforms.
+func (r *Registry) TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	if byTarget, ok := r.requestsCtx[from]; ok {
+		if fn, isOk := byTarget[to]; isOk && fn != nil {
+			return fn(ctx, model, rawJSON, stream)
+		}
+	}
 	if byTarget, ok := r.requests[from]; ok {
 		if fn, isOk := byTarget[to]; isOk && fn != nil {
 			return fn(model, rawJSON, stream)
@@ -116,11 +147,21 @@ func Register(from, to Format, request RequestTransform, response ResponseTransf
 	defaultRegistry.Register(from, to, request, response)
 }
 
+// RegisterContext attaches a context-aware request transform to the default registry.
+func RegisterContext(from, to Format, request RequestContextTransform, response ResponseTransform) {
+	defaultRegistry.RegisterContext(from, to, request, response)
+}
+
 // TranslateRequest is a helper on the default registry.
 func TranslateRequest(from, to Format, model string, rawJSON []byte, stream bool) []byte {
 	return defaultRegistry.TranslateRequest(from, to, model, rawJSON, stream)
 }
 
+// TranslateRequestContext is a helper on the default registry.
+func TranslateRequestContext(ctx context.Context, from, to Format, model string, rawJSON []byte, stream bool) []byte {
+	return defaultRegistry.TranslateRequestContext(ctx, from, to, model, rawJSON, stream)
+}
+
 // HasResponseTransformer inspects the default registry.
 func HasResponseTransformer(from, to Format) bool {
 	return defaultRegistry.HasResponseTransformer(from, to)
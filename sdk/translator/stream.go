@@ -0,0 +1,71 @@
+package translator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxStreamLine is the longest upstream line ConvertStream accepts.
+const maxStreamLine = 50 << 20
+
+// StreamOptions describes the stream ConvertStream translates.
+type StreamOptions struct {
+	// From and To have the meaning they have for TranslateStream.
+	From Format
+	To   Format
+	// Model is the requested model name.
+	Model string
+	// OriginalRequest is the client request; Request is the request sent upstream.
+	OriginalRequest []byte
+	Request         []byte
+}
+
+// ConvertStream translates an upstream stream read from r line by line and
+// writes the resulting events to w as SSE. Chunks that carry no SSE field are
+// sent as "data:" events. w is flushed after every line that produced output
+// when it implements http.Flusher, so a ResponseWriter receives each event as
+// soon as it is translated. It returns when r is exhausted, ctx is done or a
+// read or write fails.
+func (r *Registry) ConvertStream(ctx context.Context, src io.Reader, w io.Writer, opts StreamOptions) error {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLine)
+	flusher, _ := w.(http.Flusher)
+	var param any
+	var event bytes.Buffer
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunks := r.TranslateStream(ctx, opts.From, opts.To, opts.Model, opts.OriginalRequest, opts.Request, bytes.Clone(scanner.Bytes()), &param)
+		event.Reset()
+		for _, chunk := range chunks {
+			if chunk == "" {
+				continue
+			}
+			if !strings.HasPrefix(chunk, "event:") && !strings.HasPrefix(chunk, "data:") {
+				event.WriteString("data: ")
+			}
+			event.WriteString(strings.TrimRight(chunk, "\n"))
+			event.WriteString("\n\n")
+		}
+		if event.Len() == 0 {
+			continue
+		}
+		if _, err := w.Write(event.Bytes()); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return scanner.Err()
+}
+
+// ConvertStream is a helper on the default registry.
+func ConvertStream(ctx context.Context, src io.Reader, w io.Writer, opts StreamOptions) error {
+	return defaultRegistry.ConvertStream(ctx, src, w, opts)
+}
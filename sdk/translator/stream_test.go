@@ -0,0 +1,43 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConvertStreamWritesTranslatedEvents(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("client", "upstream", nil, ResponseTransform{
+		Stream: func(_ context.Context, model string, _, _, rawJSON []byte, param *any) []string {
+			line := strings.TrimPrefix(string(rawJSON), "data: ")
+			if line == "" {
+				return nil
+			}
+			if *param == nil {
+				*param = 0
+			}
+			*param = (*param).(int) + 1
+			if line == "split" {
+				return []string{`{"part":1}`, "event: done\ndata: {}\n"}
+			}
+			return []string{fmt.Sprintf(`{"model":%q,"n":%d}`, model, (*param).(int))}
+		},
+	})
+
+	upstream := strings.NewReader("data: a\n\ndata: split\n")
+	recorder := httptest.NewRecorder()
+	err := registry.ConvertStream(context.Background(), upstream, recorder, StreamOptions{From: "upstream", To: "client", Model: "m"})
+	if err != nil {
+		t.Fatalf("ConvertStream: %v", err)
+	}
+	want := "data: {\"model\":\"m\",\"n\":1}\n\ndata: {\"part\":1}\n\nevent: done\ndata: {}\n\n"
+	if got := recorder.Body.String(); got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+	if !recorder.Flushed {
+		t.Fatal("writer was not flushed")
+	}
+}
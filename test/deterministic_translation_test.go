@@ -0,0 +1,79 @@
+package test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+var deterministicGeminiInput = []byte(`{
+		"contents":[
+			{"role":"user","parts":[{"text":"list files"}]},
+			{"role":"model","parts":[{"functionCall":{"name":"ls","args":{"path":"."}}}]},
+			{"role":"user","parts":[{"functionResponse":{"name":"ls","response":{"result":"a.go"}}}]}
+		]
+	}`)
+
+func TestTranslateRequestWithOptions_Deterministic(t *testing.T) {
+	in := deterministicGeminiInput
+	opts := sdktranslator.RequestOptions{Deterministic: true}
+	translate := func(o sdktranslator.RequestOptions) []byte {
+		return sdktranslator.TranslateRequestWithOptions(sdktranslator.FormatGemini, sdktranslator.FormatClaude, "claude-sonnet-4-5", in, false, o)
+	}
+
+	first, second := translate(opts), translate(opts)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("deterministic translations differ:\n%s\n%s", first, second)
+	}
+	toolID := gjson.GetBytes(first, "messages.1.content.0.id").String()
+	if toolID == "" || gjson.GetBytes(first, "messages.2.content.0.tool_use_id").String() != toolID {
+		t.Fatalf("expected paired synthesized tool IDs, got %s", first)
+	}
+
+	seeded := translate(sdktranslator.RequestOptions{Deterministic: true, Seed: "other"})
+	if gjson.GetBytes(seeded, "messages.1.content.0.id").String() == toolID {
+		t.Fatal("expected a different seed to change synthesized IDs")
+	}
+	if bytes.Equal(translate(sdktranslator.RequestOptions{}), translate(sdktranslator.RequestOptions{})) {
+		t.Fatal("expected non-deterministic translations to synthesize fresh IDs")
+	}
+}
+
+func TestTranslateRequestWithOptions_DoesNotLeakIntoConcurrentTranslations(t *testing.T) {
+	seeded := sdktranslator.TranslateRequestWithOptions(sdktranslator.FormatGemini, sdktranslator.FormatClaude, "claude-sonnet-4-5", deterministicGeminiInput, false, sdktranslator.RequestOptions{Deterministic: true})
+	seededID := gjson.GetBytes(seeded, "messages.1.content.0.id").String()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				sdktranslator.TranslateRequestWithOptions(sdktranslator.FormatGemini, sdktranslator.FormatClaude, "claude-sonnet-4-5", deterministicGeminiInput, false, sdktranslator.RequestOptions{Deterministic: true})
+			}
+		}
+	}()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		out := sdktranslator.TranslateRequest(sdktranslator.FormatGemini, sdktranslator.FormatClaude, "claude-sonnet-4-5", deterministicGeminiInput, false)
+		id := gjson.GetBytes(out, "messages.1.content.0.id").String()
+		if id == seededID || seen[id] {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("plain translation %d reused a synthesized ID %q", i, id)
+		}
+		seen[id] = true
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIToClaude_DeveloperRoleMergedIntoSystem(t *testing.T) {
+	in := []byte(`{
+		"model":"claude-sonnet-4-5",
+		"user":"end-user-42",
+		"messages":[
+			{"role":"system","content":"Be brief."},
+			{"role":"developer","content":"Answer in French."},
+			{"role":"user","content":"hi"}
+		]
+	}`)
+
+	out := sdktranslator.TranslateRequest(sdktranslator.FormatOpenAI, sdktranslator.FormatClaude, "claude-sonnet-4-5", in, false)
+
+	if got := gjson.GetBytes(out, "messages.0.content.#").Int(); got != 2 {
+		t.Fatalf("expected system and developer text merged into one block, got %d: %s", got, out)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content.1.text").String(); got != "Answer in French." {
+		t.Fatalf("expected developer guidance in system block, got %q", got)
+	}
+	userID := gjson.GetBytes(out, "metadata.user_id").String()
+	other := sdktranslator.TranslateRequest(sdktranslator.FormatOpenAI, sdktranslator.FormatClaude, "claude-sonnet-4-5", []byte(`{"user":"someone-else","messages":[{"role":"user","content":"hi"}]}`), false)
+	if !strings.HasPrefix(userID, "user_") || userID == gjson.GetBytes(other, "metadata.user_id").String() {
+		t.Fatalf("expected metadata.user_id derived from the OpenAI user, got %q", userID)
+	}
+}
+
+func TestClaudeToOpenAI_MetadataUserIDPropagated(t *testing.T) {
+	in := []byte(`{"model":"gpt-4o","metadata":{"user_id":"user_abc"},"messages":[{"role":"user","content":"hi"}]}`)
+	out := sdktranslator.TranslateRequest(sdktranslator.FormatClaude, sdktranslator.FormatOpenAI, "gpt-4o", in, false)
+	if got := gjson.GetBytes(out, "user").String(); got != "user_abc" {
+		t.Fatalf("expected user=user_abc, got %q: %s", got, out)
+	}
+}
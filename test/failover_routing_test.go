@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/test/mocks"
+)
+
+func TestFailoverRouting_OpensCircuitAndRecovers(t *testing.T) {
+	cfg := &config.SDKConfig{Failover: config.FailoverConfig{
+		ErrorThreshold: 2,
+		OpenSeconds:    30,
+		Chains:         []config.FailoverChain{{Model: "primary-model", Fallbacks: []string{"backup-model"}}},
+	}}
+	registry := mocks.NewModelRegistry(map[string][]string{
+		"primary-model": {"claude"},
+		"backup-model":  {"openai-compatibility"},
+	})
+	clock := mocks.NewClock(time.Unix(1_700_000_000, 0))
+
+	primaryDown := true
+	executor := &mocks.Executor{
+		ExecuteFunc: func(_ context.Context, _ []string, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+			if req.Model == "primary-model" && primaryDown {
+				return cliproxyexecutor.Response{}, &coreauth.Error{Message: "upstream down", HTTPStatus: http.StatusBadGateway}
+			}
+			return cliproxyexecutor.Response{Payload: []byte(`{"model":"` + req.Model + `"}`)}, nil
+		},
+	}
+	h := handlers.NewBaseAPIHandlers(cfg, nil,
+		handlers.WithExecutor(executor),
+		handlers.WithModelRegistry(registry),
+		handlers.WithClock(clock),
+	)
+
+	execute := func() string {
+		t.Helper()
+		out, errMsg := h.ExecuteWithAuthManager(context.Background(), "openai", "primary-model", []byte(`{"model":"primary-model","messages":[]}`), "")
+		if errMsg != nil {
+			t.Fatalf("request failed: %v", errMsg.Error)
+		}
+		return string(out)
+	}
+	models := func() []string {
+		var out []string
+		for _, call := range executor.Calls() {
+			out = append(out, call.Request.Model)
+		}
+		return out
+	}
+
+	// Two failures open the primary circuit; both requests are served by the fallback.
+	execute()
+	execute()
+	if got := execute(); got != `{"model":"backup-model"}` {
+		t.Fatalf("response = %s", got)
+	}
+	want := []string{"primary-model", "backup-model", "primary-model", "backup-model", "backup-model"}
+	if got := models(); !slices.Equal(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+
+	// After the open period a single probe reaches the recovered primary.
+	primaryDown = false
+	clock.Advance(31 * time.Second)
+	if got := execute(); got != `{"model":"primary-model"}` {
+		t.Fatalf("probe response = %s", got)
+	}
+	if got := execute(); got != `{"model":"primary-model"}` {
+		t.Fatalf("response after recovery = %s", got)
+	}
+}
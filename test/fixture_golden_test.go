@@ -0,0 +1,32 @@
+package test
+
+import (
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/test/shared"
+)
+
+// TestCapturedFixtures replays every golden captured with CLIPROXY_CAPTURE_FIXTURES
+// through the translator registry and fails when a translation drifts.
+func TestCapturedFixtures(t *testing.T) {
+	fixtures, err := shared.LoadFixtures(shared.DefaultFixtureDir)
+	if err != nil {
+		t.Fatalf("load fixtures: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Skip("no captured fixtures")
+	}
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(fixture.Name, func(t *testing.T) {
+			if errReq := fixture.CheckRequestTranslation(); errReq != nil {
+				t.Error(errReq)
+			}
+			if errResp := fixture.CheckResponseTranslation(); errResp != nil {
+				t.Error(errResp)
+			}
+		})
+	}
+}
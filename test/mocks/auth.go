@@ -0,0 +1,123 @@
+package mocks
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+var (
+	_ coreauth.ProviderExecutor    = (*ProviderExecutor)(nil)
+	_ cliproxy.TokenClientProvider = (*TokenProvider)(nil)
+)
+
+// ProviderExecutor is a mock coreauth.ProviderExecutor for exercising the auth
+// manager's selection, retry and cooldown logic without upstream calls.
+type ProviderExecutor struct {
+	Provider string
+
+	ExecuteFunc       func(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+	ExecuteStreamFunc func(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error)
+	RefreshFunc       func(ctx context.Context, auth *coreauth.Auth) (*coreauth.Auth, error)
+	CountTokensFunc   func(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+	HttpRequestFunc   func(ctx context.Context, auth *coreauth.Auth, req *http.Request) (*http.Response, error)
+
+	mu      sync.Mutex
+	authIDs []string
+}
+
+func (e *ProviderExecutor) record(auth *coreauth.Auth) {
+	id := ""
+	if auth != nil {
+		id = auth.ID
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.authIDs = append(e.authIDs, id)
+}
+
+// AuthIDs returns the IDs of the auths used, in call order.
+func (e *ProviderExecutor) AuthIDs() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.authIDs...)
+}
+
+// Identifier implements coreauth.ProviderExecutor.
+func (e *ProviderExecutor) Identifier() string { return e.Provider }
+
+// Execute implements coreauth.ProviderExecutor.
+func (e *ProviderExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.record(auth)
+	if e.ExecuteFunc == nil {
+		return cliproxyexecutor.Response{}, nil
+	}
+	return e.ExecuteFunc(ctx, auth, req, opts)
+}
+
+// ExecuteStream implements coreauth.ProviderExecutor.
+func (e *ProviderExecutor) ExecuteStream(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	e.record(auth)
+	if e.ExecuteStreamFunc == nil {
+		ch := make(chan cliproxyexecutor.StreamChunk)
+		close(ch)
+		return ch, nil
+	}
+	return e.ExecuteStreamFunc(ctx, auth, req, opts)
+}
+
+// Refresh implements coreauth.ProviderExecutor. Without RefreshFunc the auth is returned unchanged.
+func (e *ProviderExecutor) Refresh(ctx context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	if e.RefreshFunc == nil {
+		return auth, nil
+	}
+	return e.RefreshFunc(ctx, auth)
+}
+
+// CountTokens implements coreauth.ProviderExecutor.
+func (e *ProviderExecutor) CountTokens(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.record(auth)
+	if e.CountTokensFunc == nil {
+		return cliproxyexecutor.Response{}, nil
+	}
+	return e.CountTokensFunc(ctx, auth, req, opts)
+}
+
+// HttpRequest implements coreauth.ProviderExecutor.
+func (e *ProviderExecutor) HttpRequest(ctx context.Context, auth *coreauth.Auth, req *http.Request) (*http.Response, error) {
+	if e.HttpRequestFunc == nil {
+		return nil, http.ErrNotSupported
+	}
+	return e.HttpRequestFunc(ctx, auth, req)
+}
+
+// TokenProvider is a mock cliproxy.TokenClientProvider.
+type TokenProvider struct {
+	LoadFunc func(ctx context.Context, cfg *config.Config) (*cliproxy.TokenClientResult, error)
+
+	mu    sync.Mutex
+	loads int
+}
+
+// Load implements cliproxy.TokenClientProvider.
+func (p *TokenProvider) Load(ctx context.Context, cfg *config.Config) (*cliproxy.TokenClientResult, error) {
+	p.mu.Lock()
+	p.loads++
+	p.mu.Unlock()
+	if p.LoadFunc == nil {
+		return &cliproxy.TokenClientResult{}, nil
+	}
+	return p.LoadFunc(ctx, cfg)
+}
+
+// Loads returns how many times Load was called.
+func (p *TokenProvider) Loads() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loads
+}
@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+var _ handlers.Clock = (*Clock)(nil)
+
+// Clock is a manually advanced handlers.Clock.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock stopped at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now implements handlers.Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,6 @@
+// Package mocks provides hand-written test doubles for the interfaces that
+// routing and failover depend on: handler executors, model registries, clocks,
+// provider executors and token client providers. Each mock records its calls
+// and delegates to an optional function field, returning zero values when the
+// field is nil. Compile-time assertions keep them in sync with the interfaces.
+package mocks
@@ -0,0 +1,76 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+var _ handlers.Executor = (*Executor)(nil)
+
+// ExecutorCall records one call made to an Executor.
+type ExecutorCall struct {
+	Method    string
+	Providers []string
+	Request   cliproxyexecutor.Request
+	Options   cliproxyexecutor.Options
+}
+
+// Executor is a mock handlers.Executor.
+type Executor struct {
+	ExecuteFunc       func(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+	ExecuteCountFunc  func(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+	ExecuteStreamFunc func(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error)
+
+	mu    sync.Mutex
+	calls []ExecutorCall
+}
+
+func (e *Executor) record(method string, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, ExecutorCall{
+		Method:    method,
+		Providers: append([]string(nil), providers...),
+		Request:   req,
+		Options:   opts,
+	})
+}
+
+// Calls returns a copy of the calls recorded so far.
+func (e *Executor) Calls() []ExecutorCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ExecutorCall(nil), e.calls...)
+}
+
+// Execute implements handlers.Executor.
+func (e *Executor) Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.record("Execute", providers, req, opts)
+	if e.ExecuteFunc == nil {
+		return cliproxyexecutor.Response{}, nil
+	}
+	return e.ExecuteFunc(ctx, providers, req, opts)
+}
+
+// ExecuteCount implements handlers.Executor.
+func (e *Executor) ExecuteCount(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.record("ExecuteCount", providers, req, opts)
+	if e.ExecuteCountFunc == nil {
+		return cliproxyexecutor.Response{}, nil
+	}
+	return e.ExecuteCountFunc(ctx, providers, req, opts)
+}
+
+// ExecuteStream implements handlers.Executor.
+func (e *Executor) ExecuteStream(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	e.record("ExecuteStream", providers, req, opts)
+	if e.ExecuteStreamFunc == nil {
+		ch := make(chan cliproxyexecutor.StreamChunk)
+		close(ch)
+		return ch, nil
+	}
+	return e.ExecuteStreamFunc(ctx, providers, req, opts)
+}
@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+var _ handlers.ModelRegistry = (*ModelRegistry)(nil)
+
+// ModelRegistry is a mock handlers.ModelRegistry backed by a static model to
+// providers table. Model names are matched case-insensitively.
+type ModelRegistry struct {
+	mu     sync.RWMutex
+	models map[string][]string
+}
+
+// NewModelRegistry returns a registry serving the given model to providers table.
+func NewModelRegistry(models map[string][]string) *ModelRegistry {
+	r := &ModelRegistry{models: make(map[string][]string, len(models))}
+	for model, providers := range models {
+		r.Set(model, providers...)
+	}
+	return r
+}
+
+// Set replaces the providers serving model. No providers removes the model.
+func (r *ModelRegistry) Set(model string, providers ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.models == nil {
+		r.models = make(map[string][]string)
+	}
+	key := strings.ToLower(model)
+	if len(providers) == 0 {
+		delete(r.models, key)
+		return
+	}
+	r.models[key] = append([]string(nil), providers...)
+}
+
+// GetModelProviders implements handlers.ModelRegistry.
+func (r *ModelRegistry) GetModelProviders(modelID string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.models[strings.ToLower(modelID)]...)
+}
@@ -0,0 +1,165 @@
+// Package shared provides helpers reused by the integration tests under test/.
+// It loads request/response goldens captured by the executors when the
+// CLIPROXY_CAPTURE_FIXTURES environment variable is set and replays them
+// against the translator registry.
+package shared
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DefaultFixtureDir is the directory, relative to the test package, that holds captured goldens.
+const DefaultFixtureDir = "testdata/fixtures"
+
+// volatilePaths lists top-level fields that change on every call (identifiers and
+// timestamps) and are therefore ignored when comparing translated responses.
+var volatilePaths = []string{"id", "created", "created_at", "responseId", "createTime", "system_fingerprint"}
+
+// Fixture is a captured request/response pair.
+type Fixture struct {
+	// Name is the file name the fixture was loaded from.
+	Name string `json:"-"`
+
+	Provider          string          `json:"provider"`
+	SourceFormat      string          `json:"source_format"`
+	TargetFormat      string          `json:"target_format"`
+	Model             string          `json:"model"`
+	Stream            bool            `json:"stream"`
+	CapturedAt        string          `json:"captured_at"`
+	Request           json.RawMessage `json:"request"`
+	TranslatedRequest json.RawMessage `json:"translated_request"`
+	UpstreamRequest   json.RawMessage `json:"upstream_request"`
+	UpstreamResponse  json.RawMessage `json:"upstream_response"`
+	Response          json.RawMessage `json:"response"`
+}
+
+// LoadFixtures reads every *.json fixture from dir in lexical order.
+// A missing directory yields an empty slice rather than an error.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read fixture dir: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	fixtures := make([]Fixture, 0, len(names))
+	for _, name := range names {
+		fixture, errLoad := LoadFixture(filepath.Join(dir, name))
+		if errLoad != nil {
+			return nil, errLoad
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// LoadFixture reads a single fixture file.
+func LoadFixture(path string) (Fixture, error) {
+	var fixture Fixture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, fmt.Errorf("read fixture %s: %w", path, err)
+	}
+	if err = json.Unmarshal(data, &fixture); err != nil {
+		return fixture, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	fixture.Name = filepath.Base(path)
+	return fixture, nil
+}
+
+// CheckRequestTranslation re-runs the request translator for the fixture and
+// returns a descriptive error when the output differs from the golden.
+func (f Fixture) CheckRequestTranslation() error {
+	if isNull(f.TranslatedRequest) {
+		return nil
+	}
+	from := sdktranslator.FromString(f.SourceFormat)
+	to := sdktranslator.FromString(f.TargetFormat)
+	got := sdktranslator.TranslateRequest(from, to, f.Model, rawPayload(f.Request), f.Stream)
+	return diffJSON("request", rawPayload(f.TranslatedRequest), got)
+}
+
+// CheckResponseTranslation re-runs the non-stream response translator for the
+// fixture and returns a descriptive error when the output differs from the golden.
+func (f Fixture) CheckResponseTranslation() error {
+	if isNull(f.Response) || isNull(f.UpstreamResponse) {
+		return nil
+	}
+	from := sdktranslator.FromString(f.SourceFormat)
+	to := sdktranslator.FromString(f.TargetFormat)
+	var param any
+	got := sdktranslator.TranslateNonStream(context.Background(), to, from, f.Model, rawPayload(f.Request), rawPayload(f.UpstreamRequest), rawPayload(f.UpstreamResponse), &param)
+	return diffJSON("response", rawPayload(f.Response), []byte(got))
+}
+
+// rawPayload returns the bytes stored in a fixture field, unquoting payloads
+// that were captured as JSON strings (for example raw SSE streams).
+func rawPayload(raw json.RawMessage) []byte {
+	if isNull(raw) {
+		return nil
+	}
+	if result := gjson.ParseBytes(raw); result.Type == gjson.String {
+		return []byte(result.String())
+	}
+	return bytes.Clone(raw)
+}
+
+func isNull(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
+
+// diffJSON compares two payloads structurally after removing volatile fields.
+func diffJSON(kind string, want, got []byte) error {
+	want, got = stripVolatile(want), stripVolatile(got)
+	var wantValue, gotValue any
+	if errWant := json.Unmarshal(want, &wantValue); errWant != nil {
+		if bytes.Equal(bytes.TrimSpace(want), bytes.TrimSpace(got)) {
+			return nil
+		}
+		return fmt.Errorf("%s mismatch:\nwant: %s\ngot:  %s", kind, want, got)
+	}
+	if errGot := json.Unmarshal(got, &gotValue); errGot != nil {
+		return fmt.Errorf("%s is not valid JSON: %v\ngot: %s", kind, errGot, got)
+	}
+	wantNorm, _ := json.Marshal(wantValue)
+	gotNorm, _ := json.Marshal(gotValue)
+	if !bytes.Equal(wantNorm, gotNorm) {
+		return fmt.Errorf("%s mismatch:\nwant: %s\ngot:  %s", kind, wantNorm, gotNorm)
+	}
+	return nil
+}
+
+func stripVolatile(payload []byte) []byte {
+	if !gjson.ValidBytes(payload) {
+		return payload
+	}
+	out := payload
+	for _, path := range volatilePaths {
+		if updated, errDel := sjson.DeleteBytes(out, path); errDel == nil {
+			out = updated
+		}
+	}
+	return out
+}